@@ -4,8 +4,13 @@
 //
 //	svpn connect --gateway http://localhost:8080 --key wallet.key
 //	svpn status  --gateway http://localhost:8080 --key wallet.key
+//	svpn whoami  --gateway http://localhost:8080 --key wallet.key
 //	svpn disconnect --gateway http://localhost:8080 --key wallet.key
 //	svpn keygen  --out wallet.key
+//	svpn config validate sovereign-vpn.conf
+//	svpn config show sovereign-vpn.conf
+//	svpn verify-sig --message-file m.txt --sig 0x... --domain 6529vpn.io
+//	svpn version --gateway http://localhost:8080
 package main
 
 import (
@@ -13,21 +18,33 @@ import (
 	"fmt"
 	"log"
 	"net"
-	"net/url"
 	"os"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/maybehotcarl/sovereign-vpn/client/pkg/api"
+	"github.com/maybehotcarl/sovereign-vpn/client/pkg/buildinfo"
+	"github.com/maybehotcarl/sovereign-vpn/client/pkg/siwe"
+	"github.com/maybehotcarl/sovereign-vpn/client/pkg/state"
 	"github.com/maybehotcarl/sovereign-vpn/client/pkg/wallet"
 	"github.com/maybehotcarl/sovereign-vpn/client/pkg/wgconf"
 )
 
+// Set at link time: -ldflags "-X main.version=... -X main.commit=... -X main.buildTime=..."
+var (
+	version   string
+	commit    string
+	buildTime string
+)
+
 func main() {
 	log.SetFlags(0)
 
 	if len(os.Args) < 2 {
 		printUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	switch os.Args[1] {
@@ -35,20 +52,34 @@ func main() {
 		cmdConnect(os.Args[2:])
 	case "disconnect":
 		cmdDisconnect(os.Args[2:])
+	case "rekey":
+		cmdRekey(os.Args[2:])
+	case "sessions":
+		cmdSessions(os.Args[2:])
 	case "status":
 		cmdStatus(os.Args[2:])
+	case "whoami":
+		cmdWhoami(os.Args[2:])
 	case "keygen":
 		cmdKeygen(os.Args[2:])
 	case "health":
 		cmdHealth(os.Args[2:])
 	case "nodes":
 		cmdNodes(os.Args[2:])
+	case "node":
+		cmdNode(os.Args[2:])
+	case "config":
+		cmdConfig(os.Args[2:])
+	case "verify-sig":
+		cmdVerifySig(os.Args[2:])
+	case "version", "--version":
+		cmdVersion(os.Args[2:])
 	case "help", "--help", "-h":
 		printUsage()
 	default:
 		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", os.Args[1])
 		printUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 }
 
@@ -61,39 +92,92 @@ Usage:
 Commands:
   connect      Authenticate and connect to VPN
   disconnect   Disconnect from VPN
+  rekey        Move the connected peer to a fresh WireGuard key pair
+  sessions     List the devices this wallet has connected
   status       Check VPN connection status
+  whoami       Show this wallet's access tier and what grants it
   nodes        List available VPN nodes
+  node         Operator tasks on your own gateway (node cleanup-sessions)
   keygen       Generate a new Ethereum wallet
   health       Check gateway health
+  config       Validate or show a WireGuard config (config validate|show <path>)
+  verify-sig   Check a signed SIWE message offline, as the gateway would
+  version      Show this build, and the gateway's with --gateway
 
-Flags (connect/disconnect/status):
+Flags (connect/disconnect/rekey/sessions/status/whoami):
   --gateway    Gateway URL (default: http://localhost:8080)
   --key        Path to wallet key file
-  --session-token Session token from a prior 'connect' (required for status/disconnect)
+  --ledger     Sign with a connected Ledger (Ethereum app open) instead of --key (connect/whoami)
+  --ledger-path Derivation path of the Ledger account (default: m/44'/60'/0'/0/0)
+  --walletconnect Sign with a mobile wallet by scanning a WalletConnect QR code (connect/whoami)
+  --walletconnect-project WalletConnect project ID (default: $SVPN_WALLETCONNECT_PROJECT_ID)
+  --session-token Session token from a prior 'connect' (required for status; disconnect and sessions use the saved session; whoami signs a fresh challenge without one)
   --wg-conf    Path to write WireGuard config (default: sovereign-vpn.conf)
-  --auto-node  Automatically select the best available node
-  --region     Preferred region for auto-node selection (e.g. us-east)`)
+  --auto-node  Select the available node with the best reported quality
+  --region     Preferred region for auto-node selection (e.g. us-east)
+  --keepalive  PersistentKeepalive seconds for the written config (0 = off)
+  --require-no-logs Refuse nodes that do not assert a no-logs DNS resolver; with --auto-node, pick only such nodes (connect)
+  --all        Disconnect every device connected with this wallet (disconnect)
+  --feedback   Report measured latency and loss to the node before disconnecting, to help rank nodes (disconnect)
+  --rotate-keys Generate a new WireGuard key pair instead of reusing the stored one
+  --state-dir  Where the WireGuard key and last session are kept (default: ~/.svpn)
+
+rekey uses the session saved by the last connect and rewrites its config in
+place; the tunnel keeps its address and expiry. Restart it to use the new key.
+
+sessions lists every device connected with this wallet; remove one with
+'svpn disconnect --wg-pubkey <key>'.
+
+Flags (verify-sig):
+  --message-file File holding the exact EIP-4361 message that was signed
+  --sig        Hex-encoded 65-byte signature (0x-prefixed)
+  --domain     Domain the gateway expects (siwe_domain)
+  --uri        URI the gateway expects (siwe_uri, default: https://<domain>)
+  --chain-id   Chain ID the gateway expects (default: 1)
+
+Flags (node cleanup-sessions):
+  --gateway    Your gateway's URL
+  --admin-token The gateway's --admin-token (default: $SVPN_ADMIN_TOKEN)
+  --dry-run    List the stale on-chain sessions without closing them
+
+node cleanup-sessions closes on-chain sessions the gateway opened that no
+longer have a connected peer, e.g. after a client crash or a restart.
+
+Every command accepts --json to print its result, or the error, as JSON
+on stdout. Progress messages stay on stderr.
+
+Exit codes:
+  0  success
+  1  other error (also: config validate found problems)
+  2  bad flags or arguments
+  3  access denied (no qualifying card, banned, region, ...)
+  4  authentication rejected (signature, challenge or session token)
+  5  gateway unreachable`)
 }
 
 func cmdConnect(args []string) {
 	fs := flag.NewFlagSet("connect", flag.ExitOnError)
 	gateway := fs.String("gateway", "http://localhost:8080", "Gateway URL")
-	keyFile := fs.String("key", "", "Path to wallet private key file")
+	signer := addSignerFlags(fs)
 	wgConfPath := fs.String("wg-conf", "sovereign-vpn.conf", "Path to write WireGuard config")
 	autoNode := fs.Bool("auto-node", false, "Automatically select the best available node")
 	region := fs.String("region", "", "Preferred region for auto-node selection (e.g. us-east)")
+	rotateKeys := fs.Bool("rotate-keys", false, "Generate a new WireGuard key pair instead of reusing the stored one")
+	stateDir := fs.String("state-dir", state.DefaultDir(), "Directory for the WireGuard key and last session")
+	keepalive := fs.Int("keepalive", wgconf.DefaultPersistentKeepalive, "PersistentKeepalive in seconds (0 = off; default: the gateway's suggestion, else 25)")
+	requireNoLogs := fs.Bool("require-no-logs", false, "Refuse nodes that do not assert a no-query-logs DNS resolver")
+	asJSON := jsonFlag(fs)
 	fs.Parse(args)
+	out := output{json: *asJSON}
 
-	if *keyFile == "" {
-		log.Fatal("--key is required (use 'svpn keygen' to create one)")
+	if !signer.chosen() {
+		out.exit(exitUsage, "--key, --ledger or --walletconnect is required (use 'svpn keygen' to create a key)")
 	}
 
 	// Load wallet
-	w, err := wallet.FromKeyFile(*keyFile)
-	if err != nil {
-		log.Fatalf("Failed to load wallet: %v", err)
-	}
-	log.Printf("Wallet: %s", w.AddressHex())
+	w, closeSigner := signer.open(out)
+	defer closeSigner()
+	log.Printf("Wallet: %s", w.Address().Hex())
 
 	// Auto-node selection: fetch node list and pick the best one
 	targetGateway := *gateway
@@ -102,17 +186,23 @@ func cmdConnect(args []string) {
 		discovery := api.NewClient(*gateway)
 
 		var resp *api.NodesResponse
+		var err error
 		if *region != "" {
 			resp, err = discovery.ListNodesByRegion(*region)
 		} else {
 			resp, err = discovery.ListNodes()
 		}
+		if err == nil && *requireNoLogs {
+			resp.Nodes = slices.DeleteFunc(resp.Nodes, func(n api.NodeInfo) bool { return !assertsNoLogs(n.DNSPolicy) })
+			resp.Count = len(resp.Nodes)
+		}
 		if err != nil {
 			log.Printf("Warning: auto-node discovery failed: %v (using --gateway)", err)
 		} else if resp.Count > 0 {
-			// Select the first eligible node in returned order.
+			// Quality scores are reported by each node about itself, so they
+			// are shown by "svpn nodes" but not trusted to pick one.
 			selected := resp.Nodes[0]
-			gatewayURL, err := gatewayURLFromEndpoint(selected.Endpoint)
+			gatewayURL, err := selected.GatewayURL()
 			if err != nil {
 				log.Printf("Warning: invalid node endpoint %q: %v (using --gateway)", selected.Endpoint, err)
 			} else {
@@ -126,185 +216,664 @@ func cmdConnect(args []string) {
 	}
 
 	client := api.NewClient(targetGateway)
+	client.SetChallengeHook(showTerms)
 
-	// Step 1: Get challenge
-	log.Println("Requesting authentication challenge...")
-	challenge, err := client.GetChallenge(w.AddressHex())
-	if err != nil {
-		log.Fatalf("Challenge failed: %v", err)
+	// Check the node's advertised DNS policy before signing in, so a node
+	// that makes no no-logs claim never gets a peer for this key.
+	if *requireNoLogs {
+		info, err := client.Version()
+		if err != nil {
+			out.fatal("Could not read the node's DNS policy", err)
+		}
+		if !assertsNoLogs(info.DNSPolicy) {
+			out.exit(exitDenied, "Node does not assert a no-logs DNS resolver (--require-no-logs); not connecting")
+		}
 	}
 
-	// Step 2: Sign challenge
-	log.Println("Signing challenge with wallet...")
-	signature, err := w.SignMessage(challenge.Message)
-	if err != nil {
-		log.Fatalf("Signing failed: %v", err)
+	// Steps 1-3: Get a challenge, sign it, verify the signature + check NFT.
+	// A challenge that expired while the wallet was signing is replaced once.
+	log.Println("Signing in: requesting a challenge for the wallet to sign...")
+	verify, err := client.SignIn(w)
+	if api.IsChallengeExpired(err) {
+		log.Println("Challenge expired before it was verified; requesting a fresh one...")
+		verify, err = client.SignIn(w)
 	}
-
-	// Step 3: Verify signature + check NFT
-	log.Println("Verifying signature and checking NFT access...")
-	verify, err := client.Verify(challenge.Message, signature)
 	if err != nil {
-		log.Fatalf("Verification failed: %v", err)
+		out.fatal("Sign-in failed", err)
 	}
 
 	log.Printf("Access tier: %s (expires %s)", verify.Tier, verify.ExpiresAt)
 
 	if verify.Tier == "denied" {
-		log.Fatal("Access denied: no qualifying Memes card found in this wallet")
+		out.exit(exitDenied, "Access denied: no qualifying Memes card found in this wallet")
 	}
 
-	// Step 4: Generate WireGuard keypair
-	log.Println("Generating WireGuard keypair...")
-	keys, err := wgconf.GenerateKeyPair()
+	// Step 4: Load the stored WireGuard keypair so the gateway renews the
+	// same peer, or generate one on first use / --rotate-keys.
+	keys, generated, err := wgconf.LoadOrGenerateKeyPair(state.KeyPath(*stateDir), *rotateKeys)
 	if err != nil {
-		log.Fatalf("Key generation failed: %v", err)
+		out.fatal("WireGuard key setup failed", err)
+	}
+	if generated {
+		log.Printf("Generated WireGuard keypair (%s)", state.KeyPath(*stateDir))
+	} else {
+		log.Printf("Reusing WireGuard keypair (%s)", state.KeyPath(*stateDir))
 	}
 
 	// Step 5: Connect to VPN
 	log.Println("Requesting VPN connection...")
 	conn, err := client.Connect(verify.SessionToken, keys.PublicKey)
+	if api.IsSessionExpired(err) {
+		log.Println("Session expired before the tunnel was set up; signing in again...")
+		if verify, err = client.SignIn(w); err == nil {
+			conn, err = client.Connect(verify.SessionToken, keys.PublicKey)
+		}
+	}
+	if api.IsNodeDraining(err) {
+		out.exit(exitError, "VPN connect failed: node is draining for maintenance; pick another node or use --auto-node")
+	}
 	if err != nil {
-		log.Fatalf("VPN connect failed: %v", err)
+		out.fatal("VPN connect failed", err)
+	}
+	if *requireNoLogs && !assertsNoLogs(conn.DNSPolicy) {
+		if err := client.Disconnect(verify.SessionToken, keys.PublicKey); err != nil {
+			log.Printf("Warning: failed to release the peer: %v", err)
+		}
+		out.exit(exitDenied, "Node does not assert a no-logs DNS resolver (--require-no-logs); not connecting")
 	}
 
-	// Step 6: Write WireGuard config
+	// Step 6: Write WireGuard config. An explicit --keepalive wins over the
+	// gateway's suggestion.
+	if !flagSet(fs, "keepalive") && conn.PersistentKeepalive > 0 {
+		*keepalive = conn.PersistentKeepalive
+	}
+	if *keepalive <= 0 {
+		*keepalive = wgconf.KeepaliveOff
+	}
+	endpoint := wgconf.PickEndpoint(conn.ServerEndpoint, conn.AlternateEndpoints)
+	if endpoint != conn.ServerEndpoint {
+		log.Printf("Preferred endpoint %s is unreachable from this network, using %s", conn.ServerEndpoint, endpoint)
+	}
 	cfg := &wgconf.Config{
 		PrivateKey:      keys.PrivateKey,
 		ClientAddress:   conn.ClientAddress,
 		DNS:             conn.DNS,
 		ServerPublicKey: conn.ServerPublicKey,
-		ServerEndpoint:  conn.ServerEndpoint,
+		ServerEndpoint:  endpoint,
 		AllowedIPs:      conn.AllowedIPs,
+
+		PersistentKeepalive: *keepalive,
 	}
 
 	if err := cfg.WriteFile(*wgConfPath); err != nil {
-		log.Fatalf("Failed to write WireGuard config: %v", err)
+		out.fatal("Failed to write WireGuard config", err)
+	}
+
+	if err := state.SaveSession(*stateDir, &state.Session{
+		Gateway:         targetGateway,
+		SessionToken:    verify.SessionToken,
+		PublicKey:       keys.PublicKey,
+		ServerPublicKey: conn.ServerPublicKey,
+		ClientAddress:   conn.ClientAddress,
+		ExpiresAt:       conn.ExpiresAt,
+		ConfigPath:      *wgConfPath,
+	}); err != nil {
+		log.Printf("Warning: failed to save session state: %v", err)
+	}
+
+	out.print(connectOutput{
+		Gateway:         targetGateway,
+		Address:         w.Address().Hex(),
+		Tier:            conn.Tier,
+		SessionToken:    verify.SessionToken,
+		ClientAddress:   conn.ClientAddress,
+		PublicKey:       keys.PublicKey,
+		ServerPublicKey: conn.ServerPublicKey,
+		ServerEndpoint:  endpoint,
+		ExpiresAt:       conn.ExpiresAt,
+		ConfigPath:      *wgConfPath,
+	}, func() { printConnected(conn, verify.SessionToken, keys.PublicKey, endpoint, *wgConfPath) })
+}
+
+// connectOutput is the --json result of connect.
+type connectOutput struct {
+	Gateway         string `json:"gateway"`
+	Address         string `json:"address"`
+	Tier            string `json:"tier"`
+	SessionToken    string `json:"session_token"`
+	ClientAddress   string `json:"client_address"`
+	PublicKey       string `json:"public_key"`
+	ServerPublicKey string `json:"server_public_key"`
+	ServerEndpoint  string `json:"server_endpoint"`
+	ExpiresAt       string `json:"expires_at"`
+	ConfigPath      string `json:"config_path"`
+}
+
+// assertsNoLogs reports whether a node's advertised DNS policy claims its
+// resolver keeps no query logs. A node advertising nothing does not.
+func assertsNoLogs(p *api.DNSPolicy) bool {
+	return p != nil && p.NoQueryLogs
+}
+
+// showTerms points the user at the gateway's terms of service, if any,
+// before they sign the challenge that accepts them.
+func showTerms(challenge *api.ChallengeResponse) {
+	if challenge.Terms == nil {
+		return
 	}
+	log.Printf("Signing accepts this node's terms of service: %s", challenge.Terms.URL)
+	if challenge.Terms.SHA256 != "" {
+		log.Printf("  (version sha256:%s)", challenge.Terms.SHA256)
+	}
+}
 
+func printConnected(conn *api.ConnectResponse, sessionToken, publicKey, endpoint, wgConfPath string) {
 	fmt.Println()
 	fmt.Println("=== VPN Connected ===")
 	fmt.Printf("  Tier:           %s\n", conn.Tier)
-	fmt.Printf("  Session Token:  %s\n", verify.SessionToken)
+	fmt.Printf("  Session Token:  %s\n", sessionToken)
 	fmt.Printf("  Client IP:      %s\n", conn.ClientAddress)
-	fmt.Printf("  Server:         %s\n", conn.ServerEndpoint)
+	fmt.Printf("  WG Public Key:  %s\n", publicKey)
+	fmt.Printf("  Server:         %s\n", endpoint)
 	fmt.Printf("  Expires:        %s\n", conn.ExpiresAt)
-	fmt.Printf("  Config written: %s\n", *wgConfPath)
+	if conn.DNSPolicy != nil {
+		fmt.Printf("  DNS policy:     %s\n", describeDNSPolicy(conn.DNSPolicy))
+	}
+	fmt.Printf("  Config written: %s\n", wgConfPath)
 	fmt.Println()
 	fmt.Println("To activate the VPN tunnel, run:")
-	fmt.Printf("  sudo wg-quick up ./%s\n", *wgConfPath)
+	fmt.Printf("  sudo wg-quick up ./%s\n", wgConfPath)
 	fmt.Println()
 	fmt.Println("To disconnect:")
-	fmt.Printf("  sudo wg-quick down ./%s\n", *wgConfPath)
+	fmt.Printf("  sudo wg-quick down ./%s\n", wgConfPath)
 }
 
 func cmdDisconnect(args []string) {
 	fs := flag.NewFlagSet("disconnect", flag.ExitOnError)
 	gateway := fs.String("gateway", "http://localhost:8080", "Gateway URL")
-	sessionToken := fs.String("session-token", "", "Session token from a prior connect command")
-	pubKey := fs.String("wg-pubkey", "", "WireGuard public key to disconnect")
+	sessionToken := fs.String("session-token", "", "Session token from a prior connect command (default: last saved session)")
+	pubKey := fs.String("wg-pubkey", "", "WireGuard public key to disconnect (default: the stored key; all of the session's peers if none)")
+	stateDir := fs.String("state-dir", state.DefaultDir(), "Directory for the WireGuard key and last session")
+	all := fs.Bool("all", false, "Disconnect every device connected with this wallet")
+	sendFeedback := fs.Bool("feedback", false, "Measure latency and loss to the gateway and report them before disconnecting")
+	asJSON := jsonFlag(fs)
 	fs.Parse(args)
+	out := output{json: *asJSON}
 
-	if *sessionToken == "" || *pubKey == "" {
-		log.Fatal("--session-token and --wg-pubkey are required")
+	// Fill anything not given on the command line from the session saved
+	// by the last connect.
+	saved, err := state.LoadSession(*stateDir)
+	if err != nil {
+		log.Printf("Warning: failed to read saved session: %v", err)
+	}
+	if saved != nil {
+		if !flagSet(fs, "gateway") && saved.Gateway != "" {
+			*gateway = saved.Gateway
+		}
+		if *sessionToken == "" {
+			*sessionToken = saved.SessionToken
+		}
+		if *pubKey == "" {
+			*pubKey = saved.PublicKey
+		}
+	}
+
+	if *sessionToken == "" {
+		out.exit(exitUsage, "--session-token is required (no saved session found; run 'svpn connect' first)")
 	}
 
 	client := api.NewClient(*gateway)
-	if err := client.Disconnect(*sessionToken, *pubKey); err != nil {
-		log.Fatalf("Disconnect failed: %v", err)
+	if *sendFeedback && *pubKey != "" && !*all {
+		reportQuality(client, *sessionToken, *pubKey)
 	}
 
-	fmt.Println("Disconnected from VPN.")
+	result := disconnectOutput{Disconnected: true}
+	if *all {
+		n, err := client.DisconnectAll(*sessionToken)
+		if err != nil {
+			out.fatal("Disconnect failed", err)
+		}
+		log.Printf("Removed %d device(s)", n)
+		result.PeersRemoved = &n
+	} else if err := client.Disconnect(*sessionToken, *pubKey); err != nil {
+		out.fatal("Disconnect failed", err)
+	}
+
+	if saved != nil && saved.SessionToken == *sessionToken {
+		if err := state.ClearSession(*stateDir); err != nil {
+			log.Printf("Warning: failed to clear saved session: %v", err)
+		}
+	}
+
+	out.print(result, func() { fmt.Println("Disconnected from VPN.") })
+}
+
+// feedbackProbes is how many requests reportQuality times.
+const feedbackProbes = 10
+
+// reportQuality times feedbackProbes requests to the gateway and reports
+// the median round trip and the fraction of requests that failed as
+// feedback on the peer pubKey. Failures are only logged: feedback is optional.
+func reportQuality(client *api.Client, sessionToken, pubKey string) {
+	var rtts []time.Duration
+	for range feedbackProbes {
+		if rtt, err := client.Ping(); err == nil {
+			rtts = append(rtts, rtt)
+		}
+	}
+	if len(rtts) == 0 {
+		log.Printf("Warning: gateway did not answer; no feedback sent")
+		return
+	}
+	slices.Sort(rtts)
+	report := api.FeedbackReport{
+		LatencyMS:   float64(rtts[len(rtts)/2].Microseconds()) / 1000,
+		FailureRate: float64(feedbackProbes-len(rtts)) / feedbackProbes,
+	}
+	if err := client.Feedback(sessionToken, pubKey, report); err != nil {
+		log.Printf("Warning: failed to send feedback: %v", err)
+		return
+	}
+	log.Printf("Sent feedback: %.0fms, %.0f%% failed", report.LatencyMS, report.FailureRate*100)
+}
+
+// disconnectOutput is the --json result of disconnect.
+type disconnectOutput struct {
+	Disconnected bool `json:"disconnected"`
+	PeersRemoved *int `json:"peers_removed,omitempty"` // set with --all
+}
+
+func cmdRekey(args []string) {
+	fs := flag.NewFlagSet("rekey", flag.ExitOnError)
+	stateDir := fs.String("state-dir", state.DefaultDir(), "Directory for the WireGuard key and last session")
+	asJSON := jsonFlag(fs)
+	fs.Parse(args)
+	out := output{json: *asJSON}
+
+	saved, err := state.LoadSession(*stateDir)
+	if err != nil {
+		out.fatal("Failed to read saved session", err)
+	}
+	if saved == nil || saved.SessionToken == "" || saved.PublicKey == "" {
+		out.exit(exitUsage, "no saved session found; run 'svpn connect' first")
+	}
+
+	// Only store the new key once the gateway has accepted it, so a failed
+	// rekey leaves the saved key matching the connected peer.
+	keys, err := wgconf.GenerateKeyPair()
+	if err != nil {
+		out.fatal("WireGuard key setup failed", err)
+	}
+
+	log.Println("Requesting rekey...")
+	client := api.NewClient(saved.Gateway)
+	conn, err := client.Rekey(saved.SessionToken, saved.PublicKey, keys.PublicKey)
+	if err != nil {
+		out.fatal("Rekey failed", err)
+	}
+	if err := wgconf.SaveKeyPair(state.KeyPath(*stateDir), keys); err != nil {
+		out.fatal("Failed to save WireGuard key", err)
+	}
+
+	// Keep the endpoint and keepalive chosen at connect time.
+	cfg, err := wgconf.LoadConfigFile(saved.ConfigPath)
+	if err != nil {
+		log.Printf("Warning: failed to read %s, writing a fresh config: %v", saved.ConfigPath, err)
+		cfg = &wgconf.Config{
+			ServerEndpoint:      wgconf.PickEndpoint(conn.ServerEndpoint, conn.AlternateEndpoints),
+			PersistentKeepalive: wgconf.DefaultPersistentKeepalive,
+		}
+	}
+	cfg.PrivateKey = keys.PrivateKey
+	cfg.ClientAddress = conn.ClientAddress
+	cfg.DNS = conn.DNS
+	cfg.ServerPublicKey = conn.ServerPublicKey
+	cfg.AllowedIPs = conn.AllowedIPs
+	if err := cfg.WriteFile(saved.ConfigPath); err != nil {
+		out.fatal("Failed to write WireGuard config", err)
+	}
+
+	saved.PublicKey = keys.PublicKey
+	saved.ClientAddress = conn.ClientAddress
+	saved.ExpiresAt = conn.ExpiresAt
+	if err := state.SaveSession(*stateDir, saved); err != nil {
+		log.Printf("Warning: failed to save session state: %v", err)
+	}
+
+	out.print(rekeyOutput{
+		PublicKey:     keys.PublicKey,
+		ClientAddress: conn.ClientAddress,
+		ExpiresAt:     conn.ExpiresAt,
+		ConfigPath:    saved.ConfigPath,
+	}, func() {
+		fmt.Printf("Rekeyed: %s now uses %s (expires %s)\n", conn.ClientAddress, keys.PublicKey, conn.ExpiresAt)
+		fmt.Printf("Restart the tunnel to use the new key:\n  sudo wg-quick down ./%s && sudo wg-quick up ./%s\n", saved.ConfigPath, saved.ConfigPath)
+	})
+}
+
+// rekeyOutput is the --json result of rekey.
+type rekeyOutput struct {
+	PublicKey     string `json:"public_key"`
+	ClientAddress string `json:"client_address"`
+	ExpiresAt     string `json:"expires_at"`
+	ConfigPath    string `json:"config_path"`
+}
+
+func cmdSessions(args []string) {
+	fs := flag.NewFlagSet("sessions", flag.ExitOnError)
+	gateway := fs.String("gateway", "http://localhost:8080", "Gateway URL")
+	sessionToken := fs.String("session-token", "", "Session token from a prior connect command (default: last saved session)")
+	stateDir := fs.String("state-dir", state.DefaultDir(), "Directory for the WireGuard key and last session")
+	asJSON := jsonFlag(fs)
+	fs.Parse(args)
+	out := output{json: *asJSON}
+
+	saved, err := state.LoadSession(*stateDir)
+	if err != nil {
+		log.Printf("Warning: failed to read saved session: %v", err)
+	}
+	if saved != nil {
+		if !flagSet(fs, "gateway") && saved.Gateway != "" {
+			*gateway = saved.Gateway
+		}
+		if *sessionToken == "" {
+			*sessionToken = saved.SessionToken
+		}
+	}
+	if *sessionToken == "" {
+		out.exit(exitUsage, "--session-token is required (no saved session found; run 'svpn connect' first)")
+	}
+
+	devices, err := api.NewClient(*gateway).Sessions(*sessionToken)
+	if err != nil {
+		out.fatal("Listing devices failed", err)
+	}
+
+	out.print(devices, func() {
+		if len(devices) == 0 {
+			fmt.Println("No connected devices.")
+			return
+		}
+		for _, d := range devices {
+			marker := " "
+			if d.Current {
+				marker = "*"
+			}
+			handshake := d.LastHandshake
+			if handshake == "" {
+				handshake = "never"
+			}
+			fmt.Printf("%s %s  %-15s connected %s, expires %s, last handshake %s, rx %d B, tx %d B\n",
+				marker, d.PublicKey, d.AssignedIP, d.CreatedAt, d.ExpiresAt, handshake, d.BytesReceived, d.BytesSent)
+		}
+		fmt.Println("\n* this session. Remove a device with: svpn disconnect --wg-pubkey <key>")
+	})
+}
+
+// parseInterspersed parses fs from args, allowing flags before, between and
+// after positional arguments, and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// flagSet reports whether name was passed explicitly on the command line.
+func flagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
 func cmdStatus(args []string) {
 	fs := flag.NewFlagSet("status", flag.ExitOnError)
 	gateway := fs.String("gateway", "http://localhost:8080", "Gateway URL")
 	sessionToken := fs.String("session-token", "", "Session token from a prior connect command")
+	asJSON := jsonFlag(fs)
 	fs.Parse(args)
+	out := output{json: *asJSON}
 
 	if *sessionToken == "" {
-		log.Fatal("--session-token is required")
+		out.exit(exitUsage, "--session-token is required")
 	}
 
 	client := api.NewClient(*gateway)
 	status, err := client.Status(*sessionToken)
 	if err != nil {
-		log.Fatalf("Status check failed: %v", err)
+		out.fatal("Status check failed", err)
 	}
 
-	if status.Connected {
-		fmt.Printf("Connected (tier=%s, expires=%s)\n", status.Tier, status.ExpiresAt)
+	out.print(status, func() {
+		if status.Connected {
+			fmt.Printf("Connected (tier=%s, expires=%s)\n", status.Tier, status.ExpiresAt)
+		} else {
+			fmt.Printf("Not connected: %s\n", status.Reason)
+		}
+	})
+}
+
+func cmdWhoami(args []string) {
+	fs := flag.NewFlagSet("whoami", flag.ExitOnError)
+	gateway := fs.String("gateway", "http://localhost:8080", "Gateway URL")
+	signer := addSignerFlags(fs)
+	sessionToken := fs.String("session-token", "", "Session token from a prior connect command (default: sign a fresh challenge with --key, --ledger or --walletconnect)")
+	asJSON := jsonFlag(fs)
+	fs.Parse(args)
+	out := output{json: *asJSON}
+
+	client := api.NewClient(*gateway)
+	client.SetChallengeHook(showTerms)
+
+	var diag *api.DiagnoseResponse
+	var err error
+	if *sessionToken != "" {
+		diag, err = client.Diagnose(*sessionToken)
 	} else {
-		fmt.Printf("Not connected: %s\n", status.Reason)
+		if !signer.chosen() {
+			out.exit(exitUsage, "--key, --ledger, --walletconnect or --session-token is required")
+		}
+		w, closeSigner := signer.open(out)
+		defer closeSigner()
+		// A denied wallet gets no session, so sign a challenge and pass it
+		// to the diagnose endpoint directly.
+		diag, err = client.DiagnoseAs(w)
+	}
+	if err != nil {
+		out.fatal("Access check failed", err)
+	}
+
+	out.print(diag, func() { printWhoami(diag) })
+	if !diag.Decision.Allowed {
+		os.Exit(exitDenied)
 	}
 }
 
-func gatewayURLFromEndpoint(endpoint string) (string, error) {
-	if endpoint == "" {
-		return "", fmt.Errorf("empty endpoint")
+func printWhoami(diag *api.DiagnoseResponse) {
+	access := "DENIED"
+	if diag.Decision.Allowed {
+		access = "allowed"
 	}
-	host := endpoint
-	if h, _, err := net.SplitHostPort(endpoint); err == nil {
-		host = h
-	} else if h, _, err := net.SplitHostPort(endpoint + ":51820"); err == nil {
-		host = h
+	fmt.Printf("Wallet:  %s\n", diag.Address)
+	fmt.Printf("Access:  %s (tier=%s)\n", access, diag.Decision.Tier)
+	fmt.Printf("Reason:  %s\n", diag.Decision.Detail)
+
+	for _, check := range diag.Checks {
+		fmt.Println()
+		fmt.Printf("Source %s: %s\n", check.Source, check.Tier)
+		printWhoamiWallet("wallet", check.Wallet)
+		for _, v := range check.Vaults {
+			printWhoamiWallet("vault ", v)
+		}
+		if check.DelegationError != "" {
+			fmt.Printf("  delegation lookup failed: %s\n", check.DelegationError)
+		}
 	}
-	if host == "" {
-		return "", fmt.Errorf("missing host")
+
+	if rep := diag.RepBan; rep != nil {
+		fmt.Println()
+		if rep.Error != "" {
+			fmt.Printf("6529 rep (%s): lookup failed: %s\n", rep.Category, rep.Error)
+		} else {
+			fmt.Printf("6529 rep (%s): %d\n", rep.Category, rep.Rating)
+		}
 	}
-	if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
-		host = "[" + host + "]"
+}
+
+func printWhoamiWallet(label string, w api.DiagnoseWallet) {
+	line := fmt.Sprintf("  %s %s  %s", label, w.Address, w.Tier)
+	if len(w.TokenIDs) > 0 {
+		ids := make([]string, len(w.TokenIDs))
+		for i, id := range w.TokenIDs {
+			ids[i] = fmt.Sprint(id)
+		}
+		line += "  cards " + strings.Join(ids, ", ")
 	}
-	u := &url.URL{Scheme: "https", Host: host}
-	return u.String(), nil
+	if w.Error != "" {
+		line += "  error: " + w.Error
+	}
+	fmt.Println(line)
 }
 
 func cmdKeygen(args []string) {
 	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
 	outFile := fs.String("out", "", "Output file for private key")
+	asJSON := jsonFlag(fs)
 	fs.Parse(args)
+	out := output{json: *asJSON}
 
 	w, err := wallet.Generate()
 	if err != nil {
-		log.Fatalf("Key generation failed: %v", err)
+		out.fatal("Key generation failed", err)
 	}
 
-	fmt.Printf("Address: %s\n", w.AddressHex())
-
+	result := keygenOutput{Address: w.AddressHex(), KeyFile: *outFile}
 	if *outFile != "" {
 		if err := w.SaveKeyFile(*outFile); err != nil {
-			log.Fatalf("Failed to save key: %v", err)
+			out.fatal("Failed to save key", err)
 		}
-		fmt.Printf("Private key saved to: %s\n", *outFile)
 	} else {
-		fmt.Printf("Private key: %s\n", w.PrivateKeyHex())
-		fmt.Println("(Use --out <file> to save to a file)")
+		result.PrivateKey = w.PrivateKeyHex()
 	}
+
+	out.print(result, func() {
+		fmt.Printf("Address: %s\n", result.Address)
+		if result.KeyFile != "" {
+			fmt.Printf("Private key saved to: %s\n", result.KeyFile)
+		} else {
+			fmt.Printf("Private key: %s\n", result.PrivateKey)
+			fmt.Println("(Use --out <file> to save to a file)")
+		}
+	})
+}
+
+// keygenOutput is the --json result of keygen. The private key is only
+// included when it was not written to a file.
+type keygenOutput struct {
+	Address    string `json:"address"`
+	KeyFile    string `json:"key_file,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"`
 }
 
 func cmdHealth(args []string) {
 	fs := flag.NewFlagSet("health", flag.ExitOnError)
 	gateway := fs.String("gateway", "http://localhost:8080", "Gateway URL")
+	asJSON := jsonFlag(fs)
 	fs.Parse(args)
+	out := output{json: *asJSON}
 
 	client := api.NewClient(*gateway)
 	health, err := client.Health()
 	if err != nil {
-		log.Fatalf("Health check failed: %v", err)
+		out.fatal("Health check failed", err)
+	}
+
+	out.print(health, func() { printHealth(health) })
+}
+
+func printHealth(health map[string]any) {
+	fmt.Printf("Gateway health: %v\n", health["status"])
+
+	keys := make([]string, 0, len(health))
+	for k := range health {
+		if k != "status" && k != "dependencies" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("  %s: %v\n", k, health[k])
+	}
+
+	deps, _ := health["dependencies"].(map[string]any)
+	if len(deps) == 0 {
+		return
 	}
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-	fmt.Println("Gateway health:")
-	for k, v := range health {
-		fmt.Printf("  %s: %v\n", k, v)
+	fmt.Println("Dependencies:")
+	for _, name := range names {
+		dep, _ := deps[name].(map[string]any)
+		line := fmt.Sprintf("  %-18s %-5v %4vms", name, dep["status"], dep["latency_ms"])
+		if critical, _ := dep["critical"].(bool); critical {
+			line += "  (critical)"
+		}
+		if msg, ok := dep["error"].(string); ok && msg != "" {
+			line += "  " + msg
+		}
+		fmt.Println(line)
 	}
 }
 
+type versionOutput struct {
+	Client  buildinfo.Info  `json:"client"`
+	Gateway *buildinfo.Info `json:"gateway,omitempty"`
+}
+
+func cmdVersion(args []string) {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	gateway := fs.String("gateway", "", "Also show this gateway's build")
+	asJSON := jsonFlag(fs)
+	fs.Parse(args)
+	out := output{json: *asJSON}
+
+	result := versionOutput{Client: buildinfo.Resolve(version, commit, buildTime)}
+	if *gateway != "" {
+		info, err := api.NewClient(*gateway).Version()
+		if err != nil {
+			out.fatal("Gateway version request failed", err)
+		}
+		result.Gateway = &info.Info
+	}
+
+	out.print(result, func() {
+		fmt.Printf("svpn %s\n", result.Client)
+		if result.Gateway != nil {
+			fmt.Printf("gateway %s\n", result.Gateway)
+		}
+	})
+}
+
 func cmdNodes(args []string) {
 	fs := flag.NewFlagSet("nodes", flag.ExitOnError)
 	gateway := fs.String("gateway", "http://localhost:8080", "Gateway URL")
 	region := fs.String("region", "", "Filter by region (e.g., us-east)")
+	asJSON := jsonFlag(fs)
 	fs.Parse(args)
+	out := output{json: *asJSON}
 
 	client := api.NewClient(*gateway)
 
@@ -316,9 +885,48 @@ func cmdNodes(args []string) {
 		resp, err = client.ListNodes()
 	}
 	if err != nil {
-		log.Fatalf("Failed to list nodes: %v", err)
+		out.fatal("Failed to list nodes", err)
+	}
+
+	out.print(resp, func() { printNodes(resp) })
+}
+
+func cmdNode(args []string) {
+	if len(args) == 0 || args[0] != "cleanup-sessions" {
+		output{}.exit(exitUsage, "usage: svpn node cleanup-sessions [--gateway URL] [--admin-token TOKEN] [--dry-run] [--json]")
+	}
+	fs := flag.NewFlagSet("node cleanup-sessions", flag.ExitOnError)
+	gateway := fs.String("gateway", "http://localhost:8080", "Gateway URL")
+	adminToken := fs.String("admin-token", os.Getenv("SVPN_ADMIN_TOKEN"), "Gateway admin token (default: $SVPN_ADMIN_TOKEN)")
+	dryRun := fs.Bool("dry-run", false, "List stale sessions without closing them")
+	asJSON := jsonFlag(fs)
+	fs.Parse(args[1:])
+	out := output{json: *asJSON}
+	if *adminToken == "" {
+		out.exit(exitUsage, "--admin-token or SVPN_ADMIN_TOKEN is required")
+	}
+
+	sweep, err := api.NewClient(*gateway).CleanupSessions(*adminToken, *dryRun)
+	if err != nil {
+		out.fatal("Session cleanup failed", err)
 	}
+	out.print(sweep, func() {
+		fmt.Printf("Node %s: %d active on-chain session(s), %d with a connected peer\n", sweep.Node, sweep.Active, sweep.Live)
+		switch {
+		case len(sweep.Stale) == 0:
+			fmt.Println("No stale sessions.")
+		case sweep.DryRun:
+			fmt.Printf("Would close %d stale session(s): %v\n", len(sweep.Stale), sweep.Stale)
+		default:
+			fmt.Printf("Closing %d stale session(s): %v\n", len(sweep.Stale), sweep.Stale)
+		}
+		if len(sweep.Unreadable) > 0 {
+			fmt.Printf("Could not read session(s) %v; try again later\n", sweep.Unreadable)
+		}
+	})
+}
 
+func printNodes(resp *api.NodesResponse) {
 	if resp.Count == 0 {
 		fmt.Println("No active nodes found.")
 		return
@@ -327,8 +935,161 @@ func cmdNodes(args []string) {
 	fmt.Printf("Active nodes: %d\n\n", resp.Count)
 	for i, n := range resp.Nodes {
 		fmt.Printf("  [%d] %s\n", i+1, n.Endpoint)
-		fmt.Printf("      Region:   %s\n", n.Region)
+		switch {
+		case n.RegionVerified:
+			fmt.Printf("      Region:   %s (verified)\n", n.Region)
+		case n.GeoCountry != "":
+			fmt.Printf("      Region:   %s (geolocates to %s)\n", n.Region, n.GeoCountry)
+		default:
+			fmt.Printf("      Region:   %s\n", n.Region)
+		}
 		fmt.Printf("      Operator: %s\n", n.Operator)
+		if n.GatewayVersion != "" {
+			fmt.Printf("      Version:  %s\n", n.GatewayVersion)
+		}
+		if n.DNSPolicy != nil {
+			fmt.Printf("      DNS:      %s\n", describeDNSPolicy(n.DNSPolicy))
+		}
+		if q := n.Quality; q != nil {
+			fmt.Printf("      Quality:  %d/100 (%.0fms, %.1f%% failed, %d reports)\n", q.Score, q.LatencyMS, q.FailureRate*100, q.Reports)
+		}
 		fmt.Println()
 	}
 }
+
+// describeDNSPolicy formats what a node asserts about its resolver, e.g.
+// "local resolver, no query logs".
+func describeDNSPolicy(p *api.DNSPolicy) string {
+	var claims []string
+	if p.LocalResolver {
+		claims = append(claims, "local resolver")
+	}
+	if p.NoQueryLogs {
+		claims = append(claims, "no query logs")
+	}
+	if p.DoH {
+		claims = append(claims, "DoH upstream")
+	}
+	if len(claims) == 0 {
+		return "no assertions"
+	}
+	return strings.Join(claims, ", ")
+}
+
+func cmdConfig(args []string) {
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	asJSON := jsonFlag(fs)
+	positional := parseInterspersed(fs, args)
+	out := output{json: *asJSON}
+	if len(positional) != 2 {
+		out.exit(exitUsage, "usage: svpn config validate|show <path> [--json]")
+	}
+	action, path := positional[0], positional[1]
+
+	f, err := os.Open(path)
+	if err != nil {
+		out.fatal("Failed to read config", err)
+	}
+	defer f.Close()
+
+	switch action {
+	case "validate":
+		problems, err := wgconf.Validate(f, net.LookupHost)
+		if err != nil {
+			out.fatal("Failed to read config", err)
+		}
+		result := configValidateOutput{Path: path, Valid: len(problems) == 0, Problems: make([]configProblem, len(problems))}
+		for i, p := range problems {
+			result.Problems[i] = configProblem{Line: p.Line, Message: p.Message}
+		}
+		out.print(result, func() {
+			if len(problems) == 0 {
+				fmt.Printf("%s: OK\n", path)
+				return
+			}
+			for _, p := range problems {
+				fmt.Printf("%s: %s\n", path, p)
+			}
+			fmt.Printf("%d problem(s) found\n", len(problems))
+		})
+		if len(problems) > 0 {
+			os.Exit(exitError)
+		}
+	case "show":
+		redacted, err := wgconf.Redact(f)
+		if err != nil {
+			out.fatal("Failed to read config", err)
+		}
+		out.print(configShowOutput{Path: path, Config: redacted}, func() { fmt.Print(redacted) })
+	default:
+		out.exit(exitUsage, fmt.Sprintf("unknown config action: %s (want validate or show)", action))
+	}
+}
+
+// configValidateOutput is the --json result of config validate.
+type configValidateOutput struct {
+	Path     string          `json:"path"`
+	Valid    bool            `json:"valid"`
+	Problems []configProblem `json:"problems"`
+}
+
+type configProblem struct {
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// configShowOutput is the --json result of config show; keys are redacted.
+type configShowOutput struct {
+	Path   string `json:"path"`
+	Config string `json:"config"`
+}
+
+// cmdVerifySig runs the gateway's SIWE signature recovery and field checks
+// against a message on disk, so a failed sign-in can be debugged without a
+// gateway. The nonce is not checked: only the gateway that issued it can.
+func cmdVerifySig(args []string) {
+	fs := flag.NewFlagSet("verify-sig", flag.ExitOnError)
+	messageFile := fs.String("message-file", "", "File holding the signed EIP-4361 message")
+	sig := fs.String("sig", "", "Hex-encoded signature (0x-prefixed)")
+	domain := fs.String("domain", "", "Expected SIWE domain")
+	uri := fs.String("uri", "", "Expected SIWE URI (default: https://<domain>)")
+	chainID := fs.Int("chain-id", 1, "Expected chain ID")
+	asJSON := jsonFlag(fs)
+	fs.Parse(args)
+	out := output{json: *asJSON}
+
+	if *messageFile == "" || *sig == "" || *domain == "" {
+		out.exit(exitUsage, "usage: svpn verify-sig --message-file <path> --sig 0x... --domain <domain> [--uri <uri>] [--chain-id <id>]")
+	}
+	if *uri == "" {
+		*uri = "https://" + *domain
+	}
+
+	msg, err := os.ReadFile(*messageFile)
+	if err != nil {
+		out.fatal("Failed to read message", err)
+	}
+
+	want := siwe.Expected{Domain: *domain, URI: *uri, ChainID: *chainID}
+	signer, err := siwe.Check(string(msg), strings.TrimSpace(*sig), want, time.Now().UTC())
+	if err != nil {
+		out.print(verifySigOutput{Error: err.Error()}, func() {
+			fmt.Printf("Signature: INVALID\n")
+			fmt.Printf("  %v\n", err)
+		})
+		os.Exit(exitAuth)
+	}
+
+	out.print(verifySigOutput{Valid: true, Address: signer.Hex()}, func() {
+		fmt.Printf("Signature: valid\n")
+		fmt.Printf("  Recovered address: %s\n", signer.Hex())
+		fmt.Printf("  Domain, URI, chain ID and timestamps match (nonce not checked)\n")
+	})
+}
+
+// verifySigOutput is the --json result of verify-sig.
+type verifySigOutput struct {
+	Valid   bool   `json:"valid"`
+	Address string `json:"address,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
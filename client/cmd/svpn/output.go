@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/maybehotcarl/sovereign-vpn/client/pkg/api"
+)
+
+// Exit codes besides 0 for success. Scripts wrapping svpn may rely on
+// these, so keep them stable.
+const (
+	exitError   = 1 // anything not covered below
+	exitUsage   = 2 // bad flags or arguments (also what package flag uses)
+	exitDenied  = 3 // the wallet has no access, or the gateway refused it
+	exitAuth    = 4 // signature, challenge or session token rejected
+	exitNetwork = 5 // gateway unreachable
+)
+
+var exitKinds = map[int]string{
+	exitError:   "error",
+	exitUsage:   "usage",
+	exitDenied:  "denied",
+	exitAuth:    "auth",
+	exitNetwork: "network",
+}
+
+// errorOutput is what --json prints to stdout when a command fails.
+type errorOutput struct {
+	Error    string `json:"error"`
+	Kind     string `json:"kind"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// output writes a command's result either for people or, with --json, as a
+// single JSON document on stdout. Progress logging stays on stderr.
+type output struct {
+	json bool
+}
+
+// jsonFlag registers --json on fs.
+func jsonFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("json", false, "Print the result as JSON on stdout")
+}
+
+// print writes v as JSON with --json and calls human otherwise.
+func (o output) print(v any, human func()) {
+	if !o.json {
+		human()
+		return
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// fatal reports "what: err" and exits with the code exitCodeFor picks.
+func (o output) fatal(what string, err error) {
+	o.exit(exitCodeFor(err), what+": "+err.Error())
+}
+
+// exit reports msg and exits with code.
+func (o output) exit(code int, msg string) {
+	if o.json {
+		o.print(errorOutput{Error: msg, Kind: exitKinds[code], ExitCode: code}, nil)
+	} else {
+		log.Print(msg)
+	}
+	os.Exit(code)
+}
+
+// exitCodeFor classifies err for the exit status.
+func exitCodeFor(err error) int {
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusUnauthorized:
+			return exitAuth
+		case http.StatusForbidden:
+			return exitDenied
+		}
+		return exitError
+	}
+	// The HTTP client reports every transport failure as a *url.Error.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return exitNetwork
+	}
+	return exitError
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+
+	"github.com/maybehotcarl/sovereign-vpn/client/pkg/wallet"
+	"github.com/maybehotcarl/sovereign-vpn/client/pkg/walletconnect"
+)
+
+// walletConnectTimeout bounds pairing: scanning the QR code and approving
+// the session in the wallet.
+const walletConnectTimeout = 5 * time.Minute
+
+// signerFlags choose what signs the SIWE challenge: a key file, a Ledger or
+// a mobile wallet over WalletConnect.
+type signerFlags struct {
+	keyFile       *string
+	ledger        *bool
+	ledgerPath    *string
+	walletConnect *bool
+	wcProject     *string
+}
+
+func addSignerFlags(fs *flag.FlagSet) signerFlags {
+	return signerFlags{
+		keyFile:       fs.String("key", "", "Path to wallet private key file"),
+		ledger:        fs.Bool("ledger", false, "Sign with a connected Ledger instead of --key"),
+		ledgerPath:    fs.String("ledger-path", wallet.DefaultLedgerPath, "Derivation path of the Ledger account"),
+		walletConnect: fs.Bool("walletconnect", false, "Sign with a mobile wallet over WalletConnect instead of --key"),
+		wcProject:     fs.String("walletconnect-project", os.Getenv("SVPN_WALLETCONNECT_PROJECT_ID"), "WalletConnect project ID (default: $SVPN_WALLETCONNECT_PROJECT_ID)"),
+	}
+}
+
+// chosen reports whether any signer was selected.
+func (f signerFlags) chosen() bool {
+	return *f.keyFile != "" || *f.ledger || *f.walletConnect
+}
+
+// open returns the selected signer and a function releasing it, exiting on
+// failure.
+func (f signerFlags) open(out output) (wallet.Signer, func()) {
+	n := 0
+	for _, set := range []bool{*f.keyFile != "", *f.ledger, *f.walletConnect} {
+		if set {
+			n++
+		}
+	}
+	if n > 1 {
+		out.exit(exitUsage, "--key, --ledger and --walletconnect cannot be combined")
+	}
+
+	switch {
+	case *f.ledger:
+		l, err := wallet.OpenLedger(*f.ledgerPath)
+		if err != nil {
+			out.fatal("Failed to open Ledger", err)
+		}
+		log.Println("Using Ledger: confirm the sign-in message on the device when asked")
+		return l, func() { l.Close() }
+	case *f.walletConnect:
+		s, err := pairWalletConnect(*f.wcProject)
+		if err != nil {
+			out.fatal("WalletConnect failed", err)
+		}
+		return s, func() { s.Close() }
+	}
+	w, err := wallet.FromKeyFile(*f.keyFile)
+	if err != nil {
+		out.fatal("Failed to load wallet", err)
+	}
+	return w, func() {}
+}
+
+// pairWalletConnect shows a pairing QR code on stderr and waits for the
+// user to approve the session in their wallet.
+func pairWalletConnect(projectID string) (*walletconnect.Session, error) {
+	if projectID == "" {
+		return nil, errors.New("--walletconnect-project or SVPN_WALLETCONNECT_PROJECT_ID is required (create a project ID at cloud.reown.com)")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), walletConnectTimeout)
+	defer cancel()
+
+	pairing, err := walletconnect.Pair(ctx, walletconnect.Config{
+		ProjectID: projectID,
+		Metadata: walletconnect.Metadata{
+			Name:        "Sovereign VPN",
+			Description: "Sign in to Sovereign VPN with your Memes card",
+			URL:         "https://6529.io",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Scan this QR code with your wallet app, or paste the URI below into it:")
+	if qr, err := qrcode.New(pairing.URI, qrcode.Low); err == nil {
+		fmt.Fprint(os.Stderr, qr.ToSmallString(false))
+	}
+	fmt.Fprintln(os.Stderr, pairing.URI)
+
+	session, err := pairing.Approve(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("no wallet approved the session within %s", walletConnectTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Wallet connected: approve the sign-in request in your wallet app when asked")
+	return session, nil
+}
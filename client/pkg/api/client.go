@@ -4,16 +4,24 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/client/pkg/buildinfo"
+	"github.com/maybehotcarl/sovereign-vpn/client/pkg/wallet"
 )
 
 // Client communicates with the Sovereign VPN gateway.
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL     string
+	httpClient  *http.Client
+	onChallenge func(*ChallengeResponse)
 }
 
 // NewClient creates a gateway API client.
@@ -30,6 +38,13 @@ func NewClient(baseURL string) *Client {
 type ChallengeResponse struct {
 	Message string `json:"message"`
 	Nonce   string `json:"nonce"`
+	Terms   *Terms `json:"terms,omitempty"` // terms of service signing Message accepts; nil if none
+}
+
+// Terms identifies the terms of service a gateway's sign-in accepts.
+type Terms struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256,omitempty"`
 }
 
 // AnonymousChallengeResponse is returned by POST /auth/anonymous/challenge.
@@ -59,6 +74,19 @@ type ConnectResponse struct {
 	AllowedIPs      string `json:"allowed_ips"`
 	ExpiresAt       string `json:"expires_at"`
 	Tier            string `json:"tier"`
+
+	AlternateEndpoints  []string `json:"alternate_endpoints,omitempty"`  // further endpoints, e.g. the other address family
+	PersistentKeepalive int      `json:"persistent_keepalive,omitempty"` // gateway's suggestion; 0 if none
+
+	DNSPolicy *DNSPolicy `json:"dns_policy,omitempty"` // operator's DNS assertions; nil if none
+}
+
+// DNSPolicy is what a node's operator asserts about the resolver handed to
+// clients. It is self-reported and cannot be verified remotely.
+type DNSPolicy struct {
+	LocalResolver bool `json:"local_resolver"` // queries are resolved on the node
+	NoQueryLogs   bool `json:"no_query_logs"`  // the resolver keeps no query logs
+	DoH           bool `json:"doh"`            // upstream lookups use DNS-over-HTTPS
 }
 
 // AnonymousConnectRequest is the body for POST /vpn/anonymous/connect.
@@ -82,6 +110,11 @@ type AnonymousConnectResponse struct {
 	AllowedIPs      string `json:"allowed_ips"`
 	ExpiresAt       string `json:"expires_at"`
 	Tier            string `json:"tier"`
+
+	AlternateEndpoints  []string `json:"alternate_endpoints,omitempty"`  // further endpoints, e.g. the other address family
+	PersistentKeepalive int      `json:"persistent_keepalive,omitempty"` // gateway's suggestion; 0 if none
+
+	DNSPolicy *DNSPolicy `json:"dns_policy,omitempty"` // operator's DNS assertions; nil if none
 }
 
 // StatusResponse is returned by GET /vpn/status.
@@ -92,9 +125,79 @@ type StatusResponse struct {
 	Reason    string `json:"reason,omitempty"`
 }
 
+// Device is one connected device in GET /vpn/sessions.
+type Device struct {
+	PublicKey     string `json:"public_key"`
+	AssignedIP    string `json:"assigned_ip"`
+	CreatedAt     string `json:"created_at"`
+	ExpiresAt     string `json:"expires_at"`
+	LastHandshake string `json:"last_handshake,omitempty"`
+	BytesReceived uint64 `json:"bytes_received"`
+	BytesSent     uint64 `json:"bytes_sent"`
+	Current       bool   `json:"current"` // connected with this session
+}
+
+// SessionSweep is returned by POST /admin/sessions/cleanup.
+type SessionSweep struct {
+	Node       string   `json:"node"`
+	DryRun     bool     `json:"dry_run"`
+	Active     int      `json:"active"`               // active on-chain sessions attributed to the node
+	Live       int      `json:"live"`                 // of those, with a peer connected
+	Stale      []uint64 `json:"stale"`                // closed, or would be under dry run
+	Unreadable []uint64 `json:"unreadable,omitempty"` // could not be read; retried next sweep
+}
+
+// DiagnoseResponse is returned by GET /access/diagnose.
+type DiagnoseResponse struct {
+	Address         string           `json:"address"`
+	Checks          []DiagnoseCheck  `json:"checks"`
+	FreeTierEnabled bool             `json:"free_tier_enabled"`
+	RepBan          *DiagnoseRepBan  `json:"rep_ban,omitempty"`
+	Decision        DiagnoseDecision `json:"decision"`
+}
+
+// DiagnoseCheck is one access source and the vaults delegating to the wallet.
+type DiagnoseCheck struct {
+	Source          string           `json:"source"`
+	Wallet          DiagnoseWallet   `json:"wallet"`
+	Vaults          []DiagnoseWallet `json:"vaults,omitempty"`
+	DelegationError string           `json:"delegation_error,omitempty"`
+	Tier            string           `json:"tier"`
+}
+
+// DiagnoseWallet is the tier of one wallet and the cards it holds.
+type DiagnoseWallet struct {
+	Address  string  `json:"address"`
+	Tier     string  `json:"tier"`
+	TokenIDs []int64 `json:"token_ids,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// DiagnoseRepBan is the gateway's 6529 rep check, if it runs one.
+type DiagnoseRepBan struct {
+	Category string `json:"category"`
+	Rating   int64  `json:"rating"`
+	Banned   bool   `json:"banned"`
+	Error    string `json:"error,omitempty"`
+}
+
+// DiagnoseDecision is the final access decision.
+type DiagnoseDecision struct {
+	Allowed        bool   `json:"allowed"`
+	Tier           string `json:"tier"`
+	DecidingFactor string `json:"deciding_factor"`
+	Detail         string `json:"detail"`
+}
+
 // ErrorResponse is the standard error format.
+// Code is set on some responses, e.g. "feature_disabled" when the gateway
+// does not offer an optional endpoint, "temporarily_unavailable" when a
+// dependency is down and the request may be retried, "challenge_expired"
+// when Verify was given a stale challenge, or "session_expired" when Connect
+// was given a session too close to expiry.
 type ErrorResponse struct {
 	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
 }
 
 // GetChallenge requests a SIWE challenge message for the given address.
@@ -159,6 +262,39 @@ func (c *Client) Verify(message, signature string) (*VerifyResponse, error) {
 	return &result, nil
 }
 
+// SetChallengeHook makes SignIn and DiagnoseAs pass each challenge to fn
+// before asking the signer to sign it, e.g. to show the terms it accepts.
+func (c *Client) SetChallengeHook(fn func(*ChallengeResponse)) {
+	c.onChallenge = fn
+}
+
+// SignIn gets a challenge for s's address, has s sign it and verifies the
+// signature, creating a session. A challenge that expired while s was
+// signing fails Verify with an error IsChallengeExpired reports.
+func (c *Client) SignIn(s wallet.Signer) (*VerifyResponse, error) {
+	message, signature, err := c.signChallenge(s)
+	if err != nil {
+		return nil, err
+	}
+	return c.Verify(message, signature)
+}
+
+// signChallenge gets a challenge for s's address and has s sign it.
+func (c *Client) signChallenge(s wallet.Signer) (message, signature string, err error) {
+	challenge, err := c.GetChallenge(s.Address().Hex())
+	if err != nil {
+		return "", "", fmt.Errorf("requesting challenge: %w", err)
+	}
+	if c.onChallenge != nil {
+		c.onChallenge(challenge)
+	}
+	signature, err = s.SignMessage(challenge.Message)
+	if err != nil {
+		return "", "", fmt.Errorf("signing challenge: %w", err)
+	}
+	return challenge.Message, signature, nil
+}
+
 // Connect requests a VPN connection with the given session token and WireGuard public key.
 func (c *Client) Connect(sessionToken, publicKey string) (*ConnectResponse, error) {
 	body, _ := json.Marshal(map[string]string{
@@ -182,6 +318,32 @@ func (c *Client) Connect(sessionToken, publicKey string) (*ConnectResponse, erro
 	return &result, nil
 }
 
+// Rekey moves the session's connected peer from oldPublicKey to
+// newPublicKey. The returned configuration keeps the peer's address and
+// expiry.
+func (c *Client) Rekey(sessionToken, oldPublicKey, newPublicKey string) (*ConnectResponse, error) {
+	body, _ := json.Marshal(map[string]string{
+		"session_token":  sessionToken,
+		"old_public_key": oldPublicKey,
+		"new_public_key": newPublicKey,
+	})
+	resp, err := c.post("/vpn/rekey", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var result ConnectResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding rekey response: %w", err)
+	}
+	return &result, nil
+}
+
 // AnonymousConnect requests a VPN connection using an anonymous proof.
 func (c *Client) AnonymousConnect(req AnonymousConnectRequest) (*AnonymousConnectResponse, error) {
 	body, err := json.Marshal(req)
@@ -206,12 +368,14 @@ func (c *Client) AnonymousConnect(req AnonymousConnectRequest) (*AnonymousConnec
 	return &result, nil
 }
 
-// Disconnect terminates a VPN connection.
+// Disconnect terminates a VPN connection. An empty publicKey removes every
+// peer the session's wallet has connected.
 func (c *Client) Disconnect(sessionToken, publicKey string) error {
-	body, _ := json.Marshal(map[string]string{
-		"session_token": sessionToken,
-		"public_key":    publicKey,
-	})
+	req := map[string]string{"session_token": sessionToken}
+	if publicKey != "" {
+		req["public_key"] = publicKey
+	}
+	body, _ := json.Marshal(req)
 	resp, err := c.post("/vpn/disconnect", body)
 	if err != nil {
 		return err
@@ -224,6 +388,73 @@ func (c *Client) Disconnect(sessionToken, publicKey string) error {
 	return nil
 }
 
+// FeedbackReport is one connection quality measurement sent to
+// POST /vpn/feedback.
+type FeedbackReport struct {
+	LatencyMS      float64 // round trip to the gateway
+	FailureRate    float64 // fraction of probe requests that failed, 0 to 1
+	ThroughputKbps float64 // 0 if not measured
+}
+
+// Feedback reports the connection quality measured on the session's peer
+// publicKey, feeding the node's quality score.
+func (c *Client) Feedback(sessionToken, publicKey string, report FeedbackReport) error {
+	body, _ := json.Marshal(map[string]any{
+		"session_token":   sessionToken,
+		"public_key":      publicKey,
+		"latency_ms":      report.LatencyMS,
+		"failure_rate":    report.FailureRate,
+		"throughput_kbps": report.ThroughputKbps,
+	})
+	resp, err := c.post("/vpn/feedback", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return c.parseError(resp)
+	}
+	return nil
+}
+
+// Ping times one GET /livez round trip to the gateway.
+func (c *Client) Ping() (time.Duration, error) {
+	start := time.Now()
+	resp, err := c.httpClient.Get(c.baseURL + "/livez")
+	if err != nil {
+		return 0, fmt.Errorf("livez request: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("livez: status %d", resp.StatusCode)
+	}
+	return time.Since(start), nil
+}
+
+// DisconnectAll removes every peer the session's wallet has connected, on
+// any device, and returns how many were removed.
+func (c *Client) DisconnectAll(sessionToken string) (int, error) {
+	body, _ := json.Marshal(map[string]string{"session_token": sessionToken})
+	resp, err := c.post("/vpn/disconnect-all", body)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, c.parseError(resp)
+	}
+	var result struct {
+		PeersRemoved int `json:"peers_removed"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("decoding disconnect-all response: %w", err)
+	}
+	return result.PeersRemoved, nil
+}
+
 // Status checks the VPN connection status.
 func (c *Client) Status(sessionToken string) (*StatusResponse, error) {
 	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/vpn/status", nil)
@@ -248,6 +479,111 @@ func (c *Client) Status(sessionToken string) (*StatusResponse, error) {
 	return &result, nil
 }
 
+// Sessions lists the devices the session's wallet has connected to the
+// gateway, oldest first.
+func (c *Client) Sessions(sessionToken string) ([]Device, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/vpn/sessions", nil)
+	if err != nil {
+		return nil, fmt.Errorf("building sessions request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+sessionToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sessions request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var result struct {
+		Devices []Device `json:"devices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding sessions response: %w", err)
+	}
+	return result.Devices, nil
+}
+
+// CleanupSessions asks the gateway, as its operator, to close its on-chain
+// sessions that have no connected peer. With dryRun it only reports them.
+func (c *Client) CleanupSessions(adminToken string, dryRun bool) (*SessionSweep, error) {
+	u := c.baseURL + "/admin/sessions/cleanup"
+	if dryRun {
+		u += "?dry_run=true"
+	}
+	req, err := http.NewRequest(http.MethodPost, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building cleanup request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cleanup request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var result SessionSweep
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding cleanup response: %w", err)
+	}
+	return &result, nil
+}
+
+// Diagnose explains the access decision for the wallet behind a session.
+func (c *Client) Diagnose(sessionToken string) (*DiagnoseResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/access/diagnose", nil)
+	if err != nil {
+		return nil, fmt.Errorf("building diagnose request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+sessionToken)
+	return c.diagnose(req)
+}
+
+// DiagnoseSigned explains the access decision for the wallet that signed a
+// fresh SIWE challenge. It works for denied wallets, which get no session.
+// The challenge's nonce is spent.
+func (c *Client) DiagnoseSigned(message, signature string) (*DiagnoseResponse, error) {
+	q := url.Values{"message": {message}, "signature": {signature}}
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/access/diagnose?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("building diagnose request: %w", err)
+	}
+	return c.diagnose(req)
+}
+
+// DiagnoseAs explains the access decision for s's wallet, signing a fresh
+// challenge with s and passing it to DiagnoseSigned.
+func (c *Client) DiagnoseAs(s wallet.Signer) (*DiagnoseResponse, error) {
+	message, signature, err := c.signChallenge(s)
+	if err != nil {
+		return nil, err
+	}
+	return c.DiagnoseSigned(message, signature)
+}
+
+func (c *Client) diagnose(req *http.Request) (*DiagnoseResponse, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("diagnose request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var result DiagnoseResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding diagnose response: %w", err)
+	}
+	return &result, nil
+}
+
 // Health checks gateway health.
 func (c *Client) Health() (map[string]any, error) {
 	resp, err := c.httpClient.Get(c.baseURL + "/health")
@@ -263,6 +599,32 @@ func (c *Client) Health() (map[string]any, error) {
 	return result, nil
 }
 
+// VersionResponse is returned by GET /version: the gateway's build, plus
+// the DNS policy it advertises so clients can check it before connecting.
+type VersionResponse struct {
+	buildinfo.Info
+	DNSPolicy *DNSPolicy `json:"dns_policy,omitempty"`
+}
+
+// Version fetches the gateway's build info and advertised DNS policy.
+func (c *Client) Version() (*VersionResponse, error) {
+	resp, err := c.httpClient.Get(c.baseURL + "/version")
+	if err != nil {
+		return nil, fmt.Errorf("version request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var result VersionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding version response: %w", err)
+	}
+	return &result, nil
+}
+
 // NodesResponse is returned by GET /nodes.
 type NodesResponse struct {
 	Nodes []NodeInfo `json:"nodes"`
@@ -271,12 +633,51 @@ type NodesResponse struct {
 
 // NodeInfo represents a VPN node from the registry.
 type NodeInfo struct {
-	Operator     string `json:"operator"`
-	Endpoint     string `json:"endpoint"`
-	WgPubKey     string `json:"wg_pub_key"`
-	Region       string `json:"region"`
-	CardEligible bool   `json:"card_eligible"`
-	Active       bool   `json:"active"`
+	Operator       string `json:"operator"`
+	Endpoint       string `json:"endpoint"`
+	WgPubKey       string `json:"wg_pub_key"`
+	Region         string `json:"region"`
+	RegionVerified bool   `json:"region_verified"`       // endpoint geolocates to the region's country
+	GeoCountry     string `json:"geo_country,omitempty"` // where the endpoint geolocates to, if looked up
+	CardEligible   bool   `json:"card_eligible"`
+	Active         bool   `json:"active"`
+	GatewayVersion string `json:"gateway_version,omitempty"`
+
+	DNSPolicy *DNSPolicy   `json:"dns_policy,omitempty"`
+	Quality   *NodeQuality `json:"quality,omitempty"` // from client feedback; nil until the node has enough
+}
+
+// NodeQuality is a node's aggregated client feedback: median latency and
+// failure rate over recent reporters, and a 0-100 score from them.
+type NodeQuality struct {
+	Reports        int     `json:"reports"`
+	LatencyMS      float64 `json:"latency_ms"`
+	FailureRate    float64 `json:"failure_rate"`
+	ThroughputKbps float64 `json:"throughput_kbps,omitempty"`
+	Score          int     `json:"score"`
+}
+
+// GatewayURL returns the HTTPS base URL of the gateway API behind the
+// node's registered WireGuard endpoint: the same host, default port.
+func (n NodeInfo) GatewayURL() (string, error) {
+	if n.Endpoint == "" {
+		return "", fmt.Errorf("empty endpoint")
+	}
+	host := n.Endpoint
+	if h, _, err := net.SplitHostPort(n.Endpoint); err == nil {
+		host = h
+	} else if h, _, err := net.SplitHostPort(n.Endpoint + ":51820"); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "" {
+		return "", fmt.Errorf("missing host")
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u := &url.URL{Scheme: "https", Host: host}
+	return u.String(), nil
 }
 
 // ListNodes fetches all active VPN nodes from the gateway.
@@ -329,12 +730,57 @@ func (c *Client) post(path string, body []byte) (*http.Response, error) {
 	return resp, nil
 }
 
+// Error is a non-200 response from the gateway. Transport failures are
+// returned as ordinary wrapped errors instead.
+type Error struct {
+	StatusCode int
+	Message    string
+	Code       string // ErrorResponse.Code, if the gateway sent one
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("gateway error (%d): %s", e.StatusCode, e.Message)
+}
+
+// CodeChallengeExpired is the Error.Code of a Verify rejected because its
+// challenge expired or was already used.
+const CodeChallengeExpired = "challenge_expired"
+
+// IsChallengeExpired reports whether err is a Verify failure that signing a
+// fresh challenge would fix.
+func IsChallengeExpired(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == CodeChallengeExpired
+}
+
+// CodeSessionExpired is the Error.Code of a Connect rejected because the
+// session expires too soon to provision a peer on.
+const CodeSessionExpired = "session_expired"
+
+// IsSessionExpired reports whether err is a Connect failure that signing in
+// again would fix.
+func IsSessionExpired(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == CodeSessionExpired
+}
+
+// CodeNodeDraining is the Error.Code of a Connect refused because the node
+// is draining for maintenance.
+const CodeNodeDraining = "node_draining"
+
+// IsNodeDraining reports whether err is a Connect failure that connecting
+// to another node would avoid.
+func IsNodeDraining(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == CodeNodeDraining
+}
+
 func (c *Client) parseError(resp *http.Response) error {
 	body, _ := io.ReadAll(resp.Body)
 
 	var errResp ErrorResponse
 	if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
-		return fmt.Errorf("gateway error (%d): %s", resp.StatusCode, errResp.Error)
+		return &Error{StatusCode: resp.StatusCode, Message: errResp.Error, Code: errResp.Code}
 	}
-	return fmt.Errorf("gateway error (%d): %s", resp.StatusCode, string(body))
+	return &Error{StatusCode: resp.StatusCode, Message: string(body)}
 }
@@ -2,9 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
 func TestGetChallenge(t *testing.T) {
@@ -26,6 +29,7 @@ func TestGetChallenge(t *testing.T) {
 		json.NewEncoder(w).Encode(ChallengeResponse{
 			Message: "test-message",
 			Nonce:   "test-nonce",
+			Terms:   &Terms{URL: "https://example.com/terms"},
 		})
 	}))
 	defer ts.Close()
@@ -41,6 +45,9 @@ func TestGetChallenge(t *testing.T) {
 	if resp.Nonce != "test-nonce" {
 		t.Errorf("expected test-nonce, got %s", resp.Nonce)
 	}
+	if resp.Terms == nil || resp.Terms.URL != "https://example.com/terms" {
+		t.Errorf("expected terms URL, got %+v", resp.Terms)
+	}
 }
 
 func TestVerify(t *testing.T) {
@@ -73,6 +80,81 @@ func TestVerify(t *testing.T) {
 	}
 }
 
+// fakeSigner signs every message with a fixed signature, or fails with err.
+type fakeSigner struct {
+	addr   common.Address
+	err    error
+	signed []string
+}
+
+func (f *fakeSigner) Address() common.Address { return f.addr }
+
+func (f *fakeSigner) SignMessage(message string) (string, error) {
+	f.signed = append(f.signed, message)
+	if f.err != nil {
+		return "", f.err
+	}
+	return "0xsig", nil
+}
+
+func TestSignIn(t *testing.T) {
+	signer := &fakeSigner{addr: common.HexToAddress("0x1234")}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		switch r.URL.Path {
+		case "/auth/challenge":
+			if req["address"] != signer.addr.Hex() {
+				t.Errorf("challenge for %s, want %s", req["address"], signer.addr.Hex())
+			}
+			json.NewEncoder(w).Encode(ChallengeResponse{Message: "challenge-1", Terms: &Terms{URL: "https://example.com/terms"}})
+		case "/auth/verify":
+			if req["message"] != "challenge-1" || req["signature"] != "0xsig" {
+				t.Errorf("verify got %v", req)
+			}
+			json.NewEncoder(w).Encode(VerifyResponse{SessionToken: "tok", Tier: "paid"})
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL)
+	var terms *Terms
+	c.SetChallengeHook(func(ch *ChallengeResponse) {
+		if len(signer.signed) != 0 {
+			t.Error("challenge hook ran after signing")
+		}
+		terms = ch.Terms
+	})
+	resp, err := c.SignIn(signer)
+	if err != nil {
+		t.Fatalf("SignIn: %v", err)
+	}
+	if terms == nil || terms.URL != "https://example.com/terms" {
+		t.Errorf("challenge hook saw terms %+v", terms)
+	}
+	if resp.SessionToken != "tok" || len(signer.signed) != 1 || signer.signed[0] != "challenge-1" {
+		t.Errorf("unexpected sign-in: %+v, signed %q", resp, signer.signed)
+	}
+}
+
+func TestSignInSignerFails(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/auth/challenge" {
+			t.Errorf("unexpected request to %s after signing failed", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(ChallengeResponse{Message: "challenge-1"})
+	}))
+	defer ts.Close()
+
+	denied := errors.New("user rejected")
+	_, err := NewClient(ts.URL).SignIn(&fakeSigner{err: denied})
+	if !errors.Is(err, denied) {
+		t.Fatalf("SignIn error = %v, want %v", err, denied)
+	}
+}
+
 func TestGetAnonymousChallenge(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -190,6 +272,49 @@ func TestAnonymousConnect(t *testing.T) {
 	}
 }
 
+func TestRekey(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/vpn/rekey" {
+			t.Errorf("expected /vpn/rekey, got %s", r.URL.Path)
+		}
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["old_public_key"] != "old-key" || req["new_public_key"] != "new-key" {
+			t.Errorf("unexpected rekey request: %v", req)
+		}
+		json.NewEncoder(w).Encode(ConnectResponse{ClientAddress: "10.8.0.2/24", ExpiresAt: "2026-01-01T00:00:00Z"})
+	}))
+	defer ts.Close()
+
+	resp, err := NewClient(ts.URL).Rekey("tok", "old-key", "new-key")
+	if err != nil {
+		t.Fatalf("Rekey: %v", err)
+	}
+	if resp.ClientAddress != "10.8.0.2/24" {
+		t.Errorf("expected address 10.8.0.2/24, got %s", resp.ClientAddress)
+	}
+}
+
+func TestFeedback(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/vpn/feedback" {
+			t.Errorf("expected /vpn/feedback, got %s", r.URL.Path)
+		}
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["public_key"] != "pub-key" || req["latency_ms"] != 42.5 || req["failure_rate"] != 0.1 {
+			t.Errorf("unexpected feedback request: %v", req)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "recorded"})
+	}))
+	defer ts.Close()
+
+	err := NewClient(ts.URL).Feedback("tok", "pub-key", FeedbackReport{LatencyMS: 42.5, FailureRate: 0.1})
+	if err != nil {
+		t.Fatalf("Feedback: %v", err)
+	}
+}
+
 func TestDisconnect(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -204,6 +329,43 @@ func TestDisconnect(t *testing.T) {
 	}
 }
 
+func TestDisconnectWithoutPublicKey(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["session_token"] != "tok" {
+			t.Errorf("expected session_token tok, got %q", req["session_token"])
+		}
+		if _, ok := req["public_key"]; ok {
+			t.Errorf("expected public_key to be omitted, got %q", req["public_key"])
+		}
+		json.NewEncoder(w).Encode(map[string]any{"status": "disconnected", "peers_removed": 2})
+	}))
+	defer ts.Close()
+
+	if err := NewClient(ts.URL).Disconnect("tok", ""); err != nil {
+		t.Fatalf("Disconnect: %v", err)
+	}
+}
+
+func TestDisconnectAll(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/vpn/disconnect-all" {
+			t.Errorf("expected /vpn/disconnect-all, got %s", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"status": "disconnected", "peers_removed": 3})
+	}))
+	defer ts.Close()
+
+	n, err := NewClient(ts.URL).DisconnectAll("tok")
+	if err != nil {
+		t.Fatalf("DisconnectAll: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 peers removed, got %d", n)
+	}
+}
+
 func TestStatus(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.RawQuery != "" {
@@ -231,6 +393,120 @@ func TestStatus(t *testing.T) {
 	}
 }
 
+func TestSessions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/vpn/sessions" {
+			t.Errorf("expected /vpn/sessions, got %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer tok" {
+			t.Errorf("expected Authorization header, got %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"devices": []Device{
+				{PublicKey: "laptop", AssignedIP: "10.8.0.2"},
+				{PublicKey: "phone", AssignedIP: "10.8.0.3", Current: true},
+			},
+			"count": 2,
+		})
+	}))
+	defer ts.Close()
+
+	devices, err := NewClient(ts.URL).Sessions("tok")
+	if err != nil {
+		t.Fatalf("Sessions: %v", err)
+	}
+	if len(devices) != 2 || devices[0].PublicKey != "laptop" || !devices[1].Current {
+		t.Errorf("unexpected devices: %+v", devices)
+	}
+}
+
+func TestCleanupSessions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/admin/sessions/cleanup" {
+			t.Errorf("expected POST /admin/sessions/cleanup, got %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer admin" {
+			t.Errorf("expected admin token, got %q", got)
+		}
+		dryRun := r.URL.Query().Get("dry_run") == "true"
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(SessionSweep{Node: "0xb0b", DryRun: dryRun, Active: 3, Live: 1, Stale: []uint64{4, 7}})
+	}))
+	defer ts.Close()
+
+	sweep, err := NewClient(ts.URL).CleanupSessions("admin", true)
+	if err != nil {
+		t.Fatalf("CleanupSessions: %v", err)
+	}
+	if !sweep.DryRun || sweep.Active != 3 || len(sweep.Stale) != 2 || sweep.Stale[1] != 7 {
+		t.Errorf("unexpected sweep: %+v", sweep)
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/access/diagnose" {
+			t.Errorf("expected /access/diagnose, got %s", r.URL.Path)
+		}
+		auth := r.Header.Get("Authorization")
+		q := r.URL.Query()
+		if auth == "" && (q.Get("message") != "line one\nline two" || q.Get("signature") != "0xsig") {
+			t.Errorf("expected bearer token or signed message, got query %q", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(DiagnoseResponse{
+			Address: "0x1234",
+			Checks: []DiagnoseCheck{{
+				Source: "direct",
+				Wallet: DiagnoseWallet{Address: "0x1234", Tier: "paid", TokenIDs: []int64{7, 9}},
+				Tier:   "paid",
+			}},
+			Decision: DiagnoseDecision{Allowed: true, Tier: "paid", DecidingFactor: "granted"},
+		})
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL)
+	resp, err := c.Diagnose("0xABC")
+	if err != nil {
+		t.Fatalf("Diagnose: %v", err)
+	}
+	if !resp.Decision.Allowed || len(resp.Checks[0].Wallet.TokenIDs) != 2 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	if _, err := c.DiagnoseSigned("line one\nline two", "0xsig"); err != nil {
+		t.Fatalf("DiagnoseSigned: %v", err)
+	}
+}
+
+func TestDiagnoseAs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth/challenge":
+			json.NewEncoder(w).Encode(ChallengeResponse{Message: "challenge-1"})
+		case "/access/diagnose":
+			q := r.URL.Query()
+			if q.Get("message") != "challenge-1" || q.Get("signature") != "0xsig" {
+				t.Errorf("diagnose got query %q", r.URL.RawQuery)
+			}
+			json.NewEncoder(w).Encode(DiagnoseResponse{Address: "0x1234"})
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	resp, err := NewClient(ts.URL).DiagnoseAs(&fakeSigner{addr: common.HexToAddress("0x1234")})
+	if err != nil {
+		t.Fatalf("DiagnoseAs: %v", err)
+	}
+	if resp.Address != "0x1234" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
 func TestHealth(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/health" {
@@ -254,6 +530,43 @@ func TestHealth(t *testing.T) {
 	}
 }
 
+func TestVersion(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/version" {
+			t.Errorf("expected /version, got %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"version":"v1.2.0","commit":"abc123","go_version":"go1.24.0","dns_policy":{"no_query_logs":true}}`))
+	}))
+	defer ts.Close()
+
+	info, err := NewClient(ts.URL).Version()
+	if err != nil {
+		t.Fatalf("Version: %v", err)
+	}
+	if info.Version != "v1.2.0" || info.Commit != "abc123" {
+		t.Errorf("got %+v", info)
+	}
+	if info.DNSPolicy == nil || !info.DNSPolicy.NoQueryLogs {
+		t.Errorf("DNS policy = %+v, want no_query_logs", info.DNSPolicy)
+	}
+}
+
+func TestNodeGatewayURL(t *testing.T) {
+	for endpoint, want := range map[string]string{
+		"vpn.example.org:51820": "https://vpn.example.org",
+		"vpn.example.org":       "https://vpn.example.org",
+		"[2001:db8::1]:51820":   "https://[2001:db8::1]",
+	} {
+		if got, err := (NodeInfo{Endpoint: endpoint}).GatewayURL(); err != nil || got != want {
+			t.Errorf("GatewayURL(%q) = %q, %v; want %q", endpoint, got, err, want)
+		}
+	}
+	if _, err := (NodeInfo{}).GatewayURL(); err == nil {
+		t.Error("GatewayURL with no endpoint: expected error")
+	}
+}
+
 func TestErrorParsing(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -270,4 +583,61 @@ func TestErrorParsing(t *testing.T) {
 	if err.Error() != "gateway error (403): access denied" {
 		t.Errorf("unexpected error message: %s", err.Error())
 	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("expected *Error with status 403, got %#v", err)
+	}
+}
+
+func TestIsChallengeExpired(t *testing.T) {
+	code := CodeChallengeExpired
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "invalid or expired nonce", Code: code})
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL)
+	if _, err := c.Verify("msg", "0xsig"); !IsChallengeExpired(err) {
+		t.Errorf("IsChallengeExpired(%v) = false, want true", err)
+	}
+	code = ""
+	if _, err := c.Verify("msg", "0xsig"); err == nil || IsChallengeExpired(err) {
+		t.Errorf("IsChallengeExpired(%v) = true for a 401 without the code", err)
+	}
+}
+
+func TestIsSessionExpired(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "session expires too soon to connect, re-authenticate", Code: CodeSessionExpired})
+	}))
+	defer ts.Close()
+
+	_, err := NewClient(ts.URL).Connect("token", "pubkey")
+	if !IsSessionExpired(err) {
+		t.Errorf("IsSessionExpired(%v) = false, want true", err)
+	}
+	if IsChallengeExpired(err) {
+		t.Errorf("IsChallengeExpired(%v) = true for an expiring session", err)
+	}
+}
+
+func TestIsNodeDraining(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ErrorResponse{Error: "node draining", Code: CodeNodeDraining})
+	}))
+	defer ts.Close()
+
+	_, err := NewClient(ts.URL).Connect("token", "pubkey")
+	if !IsNodeDraining(err) {
+		t.Errorf("IsNodeDraining(%v) = false, want true", err)
+	}
+	if IsSessionExpired(err) {
+		t.Errorf("IsSessionExpired(%v) = true for a draining node", err)
+	}
 }
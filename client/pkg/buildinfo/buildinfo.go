@@ -0,0 +1,75 @@
+// Package buildinfo describes which build of svpn is running. The main
+// package holds version, commit and build time variables set at link time:
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Resolve fills what was not stamped from the VCS data the go command
+// embeds, so a plain go build from a checkout still reports its commit.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Info is a binary's build identity, in the shape the gateway serves on
+// GET /version.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+	Modified  bool   `json:"modified,omitempty"` // built from a tree with uncommitted changes
+}
+
+// Resolve returns the Info for the running binary from its link-time
+// values. An empty version becomes "dev"; an empty commit or build time
+// falls back to the embedded VCS revision and commit time.
+func Resolve(version, commit, buildTime string) Info {
+	info := Info{
+		Version:   version,
+		Commit:    commit,
+		BuildTime: buildTime,
+		GoVersion: runtime.Version(),
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true" && commit == ""
+			}
+		}
+	}
+	return info
+}
+
+// String formats the Info on one line, e.g. "v1.2.0 (commit 1a2b3c4d5e6f,
+// built 2026-01-02T15:04:05Z, go1.24.0)".
+func (i Info) String() string {
+	s := i.Version + " ("
+	if i.Commit != "" {
+		commit := i.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		if i.Modified {
+			commit += "-dirty"
+		}
+		s += "commit " + commit + ", "
+	}
+	if i.BuildTime != "" {
+		s += "built " + i.BuildTime + ", "
+	}
+	return s + i.GoVersion + ")"
+}
@@ -0,0 +1,20 @@
+package buildinfo
+
+import "testing"
+
+func TestResolveAndString(t *testing.T) {
+	info := Resolve("", "", "")
+	if info.Version != "dev" || info.GoVersion == "" {
+		t.Errorf("Resolve with nothing stamped = %+v, want version dev and a Go version", info)
+	}
+
+	info = Resolve("v1.2.0", "0123456789abcdef0123", "2026-01-02T15:04:05Z")
+	if info.Commit != "0123456789abcdef0123" || info.Modified {
+		t.Errorf("stamped commit not kept: %+v", info)
+	}
+	info.GoVersion = "go1.24.0"
+	want := "v1.2.0 (commit 0123456789ab, built 2026-01-02T15:04:05Z, go1.24.0)"
+	if got := info.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,124 @@
+// Package siwe checks signed EIP-4361 (Sign-In with Ethereum) messages the
+// way the gateway does, so a failed sign-in can be debugged offline.
+package siwe
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maybehotcarl/sovereign-vpn/client/pkg/wallet"
+)
+
+// maxClockSkew is how far in the future a message's issued-at time may be,
+// as the gateway allows.
+const maxClockSkew = 5 * time.Minute
+
+// Expected holds the values a gateway requires of a message, from its
+// siwe_domain, siwe_uri and chain ID settings.
+type Expected struct {
+	Domain  string
+	URI     string
+	ChainID int
+}
+
+// Message holds the fields of an EIP-4361 message the gateway checks.
+type Message struct {
+	Domain         string
+	Address        string
+	URI            string
+	ChainID        int
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime time.Time
+}
+
+// Check recovers the signer of message and checks it against want at now:
+// the signer must be the message's address, and its domain, URI, chain ID
+// and timestamps must be ones the gateway accepts. The nonce is not checked;
+// only the gateway that issued it can. It returns the signer.
+func Check(message, signature string, want Expected, now time.Time) (common.Address, error) {
+	signer, err := wallet.RecoverSigner(message, signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	msg, err := ParseMessage(message)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("parsing SIWE message: %w", err)
+	}
+
+	switch {
+	case !strings.EqualFold(signer.Hex(), msg.Address):
+		return common.Address{}, fmt.Errorf("recovered address %s does not match message address %s", signer.Hex(), msg.Address)
+	case msg.Domain != want.Domain:
+		return common.Address{}, fmt.Errorf("domain mismatch: got %q, expected %q", msg.Domain, want.Domain)
+	case msg.URI != want.URI:
+		return common.Address{}, fmt.Errorf("uri mismatch: got %q, expected %q", msg.URI, want.URI)
+	case msg.ChainID != want.ChainID:
+		return common.Address{}, fmt.Errorf("chain ID mismatch: got %d, expected %d", msg.ChainID, want.ChainID)
+	case msg.IssuedAt.After(now.Add(maxClockSkew)):
+		return common.Address{}, fmt.Errorf("issued-at is in the future")
+	case now.After(msg.ExpirationTime):
+		return common.Address{}, fmt.Errorf("siwe message has expired")
+	}
+	return signer, nil
+}
+
+// ParseMessage extracts the fields Check needs from an EIP-4361 message.
+// Lines it does not need, such as the statement, are ignored.
+func ParseMessage(message string) (*Message, error) {
+	lines := strings.Split(message, "\n")
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("message too short")
+	}
+
+	// Line 0: "{domain} wants you to sign in with your Ethereum account:"
+	domain, _, ok := strings.Cut(lines[0], " wants you to sign in")
+	if !ok {
+		return nil, fmt.Errorf("invalid domain line: %q", lines[0])
+	}
+	msg := &Message{Domain: domain, Address: strings.TrimSpace(lines[1])}
+	if !common.IsHexAddress(msg.Address) {
+		return nil, fmt.Errorf("invalid address: %q", msg.Address)
+	}
+
+	for _, line := range lines[2:] {
+		key, value, _ := strings.Cut(line, ": ")
+		var err error
+		switch key {
+		case "URI":
+			msg.URI = value
+		case "Chain ID":
+			if msg.ChainID, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("invalid chain ID")
+			}
+		case "Nonce":
+			msg.Nonce = value
+		case "Issued At":
+			if msg.IssuedAt, err = time.Parse(time.RFC3339, value); err != nil {
+				return nil, fmt.Errorf("invalid issued-at timestamp")
+			}
+		case "Expiration Time":
+			if msg.ExpirationTime, err = time.Parse(time.RFC3339, value); err != nil {
+				return nil, fmt.Errorf("invalid expiration timestamp")
+			}
+		}
+	}
+
+	switch {
+	case msg.URI == "":
+		return nil, fmt.Errorf("uri not found in message")
+	case msg.ChainID == 0:
+		return nil, fmt.Errorf("chain ID not found in message")
+	case msg.Nonce == "":
+		return nil, fmt.Errorf("nonce not found in message")
+	case msg.IssuedAt.IsZero():
+		return nil, fmt.Errorf("issued-at not found in message")
+	case msg.ExpirationTime.IsZero():
+		return nil, fmt.Errorf("expiration time not found in message")
+	}
+	return msg, nil
+}
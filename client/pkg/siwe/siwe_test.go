@@ -0,0 +1,99 @@
+package siwe
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/client/pkg/wallet"
+)
+
+var testExpected = Expected{Domain: "6529vpn.io", URI: "https://6529vpn.io", ChainID: 1}
+
+// testMessage is a message as the gateway formats it, issued at issuedAt
+// and valid for five minutes.
+func testMessage(domain, address string, issuedAt time.Time) string {
+	return fmt.Sprintf(`%s wants you to sign in with your Ethereum account:
+%s
+
+Sign in to Sovereign VPN with your Ethereum account.
+
+URI: https://6529vpn.io
+Version: 1
+Chain ID: 1
+Nonce: abc123
+Issued At: %s
+Expiration Time: %s`, domain, address, issuedAt.Format(time.RFC3339), issuedAt.Add(5*time.Minute).Format(time.RFC3339))
+}
+
+func TestCheckAcceptsSignedMessage(t *testing.T) {
+	w, _ := wallet.Generate()
+	now := time.Now().UTC().Truncate(time.Second)
+	msg := testMessage("6529vpn.io", w.AddressHex(), now)
+	sig, err := w.SignMessage(msg)
+	if err != nil {
+		t.Fatalf("SignMessage: %v", err)
+	}
+
+	signer, err := Check(msg, sig, testExpected, now)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if signer != w.Address() {
+		t.Errorf("signer = %s, want %s", signer.Hex(), w.AddressHex())
+	}
+}
+
+func TestCheckRejects(t *testing.T) {
+	w, _ := wallet.Generate()
+	other, _ := wallet.Generate()
+	now := time.Now().UTC().Truncate(time.Second)
+	sign := func(msg string) string {
+		sig, _ := w.SignMessage(msg)
+		return sig
+	}
+	valid := testMessage("6529vpn.io", w.AddressHex(), now)
+
+	tests := map[string]struct {
+		message, signature string
+		want               Expected
+		now                time.Time
+		errSubstr          string
+	}{
+		"tampered message": {valid, sign(strings.Replace(valid, "abc123", "xyz", 1)), testExpected, now, "does not match"},
+		"other signer":     {testMessage("6529vpn.io", other.AddressHex(), now), sign(testMessage("6529vpn.io", other.AddressHex(), now)), testExpected, now, "does not match"},
+		"wrong domain":     {testMessage("evil.example", w.AddressHex(), now), sign(testMessage("evil.example", w.AddressHex(), now)), testExpected, now, "domain mismatch"},
+		"wrong chain":      {valid, sign(valid), Expected{"6529vpn.io", "https://6529vpn.io", 11155111}, now, "chain ID mismatch"},
+		"expired":          {valid, sign(valid), testExpected, now.Add(10 * time.Minute), "expired"},
+		"from the future":  {valid, sign(valid), testExpected, now.Add(-10 * time.Minute), "future"},
+		"bad signature":    {valid, "0xdeadbeef", testExpected, now, "65 bytes"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Check(tt.message, tt.signature, tt.want, tt.now)
+			if err == nil || !strings.Contains(err.Error(), tt.errSubstr) {
+				t.Fatalf("Check = %v, want an error mentioning %q", err, tt.errSubstr)
+			}
+		})
+	}
+}
+
+func TestParseMessageRequiresFields(t *testing.T) {
+	w, _ := wallet.Generate()
+	valid := testMessage("6529vpn.io", w.AddressHex(), time.Now().UTC())
+	for _, field := range []string{"URI", "Chain ID", "Nonce", "Issued At", "Expiration Time"} {
+		var kept []string
+		for _, line := range strings.Split(valid, "\n") {
+			if !strings.HasPrefix(line, field+": ") {
+				kept = append(kept, line)
+			}
+		}
+		if _, err := ParseMessage(strings.Join(kept, "\n")); err == nil {
+			t.Errorf("message without %s parsed", field)
+		}
+	}
+	if _, err := ParseMessage("not a siwe message"); err == nil {
+		t.Error("garbage parsed")
+	}
+}
@@ -0,0 +1,86 @@
+// Package state persists client state between svpn invocations: the
+// WireGuard key pair and the last session, so reconnects keep the same peer
+// and disconnect does not need the key passed by hand.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DirEnv overrides the default state directory (~/.svpn).
+const DirEnv = "SVPN_HOME"
+
+const (
+	keyFile     = "wg.key"
+	sessionFile = "session.json"
+)
+
+// DefaultDir returns $SVPN_HOME, or ~/.svpn if unset.
+func DefaultDir() string {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".svpn"
+	}
+	return filepath.Join(home, ".svpn")
+}
+
+// KeyPath returns where the client's WireGuard private key is kept.
+func KeyPath(dir string) string {
+	return filepath.Join(dir, keyFile)
+}
+
+// Session is what the client remembers about its last connect.
+type Session struct {
+	Gateway         string `json:"gateway"`
+	SessionToken    string `json:"session_token"`
+	PublicKey       string `json:"public_key"`        // the client's WireGuard key registered with the gateway
+	ServerPublicKey string `json:"server_public_key"` // the gateway's WireGuard key
+	ClientAddress   string `json:"client_address"`
+	ExpiresAt       string `json:"expires_at"`
+	ConfigPath      string `json:"config_path"`
+}
+
+// SaveSession writes the session to dir, creating it if needed.
+func SaveSession(dir string, s *Session) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("creating state dir: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, sessionFile), append(data, '\n'), 0600)
+}
+
+// LoadSession reads the last saved session. It returns nil, nil if there is
+// none.
+func LoadSession(dir string) (*Session, error) {
+	data, err := os.ReadFile(filepath.Join(dir, sessionFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", sessionFile, err)
+	}
+	return &s, nil
+}
+
+// ClearSession forgets the saved session. The key pair is kept.
+func ClearSession(dir string) error {
+	err := os.Remove(filepath.Join(dir, sessionFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), ".svpn")
+
+	if s, err := LoadSession(dir); s != nil || err != nil {
+		t.Fatalf("expected no session yet, got %+v, %v", s, err)
+	}
+
+	want := &Session{
+		Gateway:      "https://vpn.example.com",
+		SessionToken: "tok",
+		PublicKey:    "client-pub",
+		ConfigPath:   "sovereign-vpn.conf",
+	}
+	if err := SaveSession(dir, want); err != nil {
+		t.Fatalf("SaveSession: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, sessionFile))
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("session file should be 0600, got %o", info.Mode().Perm())
+	}
+
+	got, err := LoadSession(dir)
+	if err != nil {
+		t.Fatalf("LoadSession: %v", err)
+	}
+	if *got != *want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	if err := ClearSession(dir); err != nil {
+		t.Fatalf("ClearSession: %v", err)
+	}
+	if err := ClearSession(dir); err != nil {
+		t.Fatalf("ClearSession twice: %v", err)
+	}
+	if s, _ := LoadSession(dir); s != nil {
+		t.Fatalf("expected session to be cleared, got %+v", s)
+	}
+}
+
+func TestDefaultDirHonorsEnv(t *testing.T) {
+	t.Setenv(DirEnv, "/tmp/svpn-test")
+	if got := DefaultDir(); got != "/tmp/svpn-test" {
+		t.Fatalf("DefaultDir = %q", got)
+	}
+}
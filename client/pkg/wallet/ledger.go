@@ -0,0 +1,239 @@
+package wallet
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/karalabe/hid"
+)
+
+// DefaultLedgerPath is the derivation path of the first account in Ledger
+// Live and most wallets.
+var DefaultLedgerPath = accounts.DefaultBaseDerivationPath.String()
+
+// Ledger signs with an account on a USB-connected Ledger running the
+// Ethereum app. The key never leaves the device; each signature is confirmed
+// on its screen.
+//
+// go-ethereum's accounts/usbwallet only signs transactions and EIP-712 data
+// on a Ledger, so personal_sign, which SIWE needs, is spoken to the app
+// directly over the same HID transport.
+type Ledger struct {
+	device  io.ReadWriteCloser
+	path    accounts.DerivationPath
+	address common.Address
+}
+
+// Ledger USB identifiers, from go-ethereum's usbwallet hub: the legacy
+// product IDs, then the WebUSB ones, whose upper byte is the model and
+// lower byte an interface bitfield.
+const (
+	ledgerVendorID  = 0x2c97
+	ledgerUsagePage = 0xffa0
+)
+
+var ledgerProductIDs = []uint16{
+	0x0000, 0x0001, 0x0004, 0x0005, 0x0006, 0x0007, 0x0008,
+	0x1000, 0x4000, 0x5000, 0x6000, 0x7000, 0x8000,
+}
+
+// Ethereum app instructions.
+const (
+	ledgerInsGetAddress   = 0x02
+	ledgerInsSignPersonal = 0x08
+)
+
+// ledgerChunkSize is the most message data sent in one APDU.
+const ledgerChunkSize = 150
+
+// OpenLedger opens the first connected Ledger and derives the account at
+// path, e.g. "m/44'/60'/0'/0/0". The device must be unlocked with the
+// Ethereum app open.
+func OpenLedger(path string) (*Ledger, error) {
+	derivation, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("parsing derivation path: %w", err)
+	}
+	if !hid.Supported() {
+		return nil, errors.New("this build of svpn has no USB support; build it with cgo enabled to use a Ledger")
+	}
+	infos, err := hid.Enumerate(ledgerVendorID, 0)
+	if err != nil {
+		return nil, fmt.Errorf("listing USB devices: %w", err)
+	}
+	for _, info := range infos {
+		if !isLedgerInterface(info) {
+			continue
+		}
+		device, err := info.Open()
+		if err != nil {
+			return nil, fmt.Errorf("opening Ledger: %w", err)
+		}
+		l, err := newLedger(device, derivation)
+		if err != nil {
+			device.Close()
+			return nil, err
+		}
+		return l, nil
+	}
+	return nil, errors.New("no Ledger found; connect and unlock it")
+}
+
+// isLedgerInterface reports whether info is the Ledger interface that
+// carries APDUs. Windows and macOS report it by usage page, Linux by
+// interface number.
+func isLedgerInterface(info hid.DeviceInfo) bool {
+	for _, id := range ledgerProductIDs {
+		if info.ProductID == id || info.ProductID&0xff00 == id {
+			return info.UsagePage == ledgerUsagePage || info.Interface == 0
+		}
+	}
+	return false
+}
+
+func newLedger(device io.ReadWriteCloser, path accounts.DerivationPath) (*Ledger, error) {
+	l := &Ledger{device: device, path: path}
+	reply, err := l.exchange(ledgerInsGetAddress, 0x00, l.encodePath())
+	if err != nil {
+		return nil, fmt.Errorf("reading Ledger address: %w", err)
+	}
+	// Reply: public key length, public key, address length, hex address.
+	if len(reply) < 1 || len(reply) < 1+int(reply[0]) {
+		return nil, errors.New("reading Ledger address: reply lacks public key")
+	}
+	reply = reply[1+int(reply[0]):]
+	if len(reply) < 1 || int(reply[0]) != 2*common.AddressLength || len(reply) < 1+int(reply[0]) {
+		return nil, errors.New("reading Ledger address: reply lacks address")
+	}
+	if _, err := hex.Decode(l.address[:], reply[1:1+int(reply[0])]); err != nil {
+		return nil, fmt.Errorf("reading Ledger address: %w", err)
+	}
+	return l, nil
+}
+
+// Address returns the derived account's address.
+func (l *Ledger) Address() common.Address {
+	return l.address
+}
+
+// AddressHex returns the checksummed address string.
+func (l *Ledger) AddressHex() string {
+	return l.address.Hex()
+}
+
+// SignMessage signs a message using ERC-191 personal_sign, blocking until
+// the user approves or rejects it on the device.
+func (l *Ledger) SignMessage(message string) (string, error) {
+	data := []byte(message)
+	first := l.encodePath()
+	first = binary.BigEndian.AppendUint32(first, uint32(len(data)))
+
+	var reply []byte
+	var err error
+	for p1 := byte(0x00); ; p1 = 0x80 {
+		chunk := data
+		if len(chunk) > ledgerChunkSize {
+			chunk = chunk[:ledgerChunkSize]
+		}
+		data = data[len(chunk):]
+		if p1 == 0x00 {
+			chunk = append(first, chunk...)
+		}
+		if reply, err = l.exchange(ledgerInsSignPersonal, p1, chunk); err != nil {
+			return "", fmt.Errorf("signing message on Ledger: %w", err)
+		}
+		if len(data) == 0 {
+			break
+		}
+	}
+
+	// Reply: v, r, s. Reorder to r, s, v as personal_sign returns it.
+	if len(reply) != 65 {
+		return "", fmt.Errorf("signing message on Ledger: got a %d-byte signature", len(reply))
+	}
+	sig := append(reply[1:65:65], reply[0])
+	if sig[64] < 27 {
+		sig[64] += 27
+	}
+	return "0x" + hex.EncodeToString(sig), nil
+}
+
+// Close releases the device.
+func (l *Ledger) Close() error {
+	return l.device.Close()
+}
+
+func (l *Ledger) encodePath() []byte {
+	b := []byte{byte(len(l.path))}
+	for _, component := range l.path {
+		b = binary.BigEndian.AppendUint32(b, component)
+	}
+	return b
+}
+
+// ledgerStatusError is a non-success status word from the Ethereum app.
+type ledgerStatusError uint16
+
+func (e ledgerStatusError) Error() string {
+	switch e {
+	case 0x6985:
+		return "rejected on the device"
+	case 0x6d00, 0x6e00, 0x6511, 0x6e01:
+		return "open the Ethereum app on the Ledger"
+	case 0x5515, 0x6b0c:
+		return "unlock the Ledger"
+	}
+	return fmt.Sprintf("Ledger status 0x%04x", uint16(e))
+}
+
+// exchange sends one APDU to the Ethereum app and returns its reply without
+// the status word. APDUs travel in 64-byte HID reports, each headed by
+// channel 0x0101, tag 0x05 and a sequence number; the first report of a
+// message also carries its total length.
+func (l *Ledger) exchange(ins, p1 byte, data []byte) ([]byte, error) {
+	apdu := []byte{0xe0, ins, p1, 0x00, byte(len(data))}
+	apdu = append(apdu, data...)
+	msg := binary.BigEndian.AppendUint16(nil, uint16(len(apdu)))
+	msg = append(msg, apdu...)
+
+	for seq := uint16(0); len(msg) > 0; seq++ {
+		report := make([]byte, 64)
+		copy(report, []byte{0x01, 0x01, 0x05})
+		binary.BigEndian.PutUint16(report[3:], seq)
+		n := copy(report[5:], msg)
+		msg = msg[n:]
+		if _, err := l.device.Write(report); err != nil {
+			return nil, err
+		}
+	}
+
+	var reply []byte
+	total := -1
+	report := make([]byte, 64)
+	for seq := uint16(0); total < 0 || len(reply) < total; seq++ {
+		if _, err := io.ReadFull(l.device, report); err != nil {
+			return nil, err
+		}
+		if report[0] != 0x01 || report[1] != 0x01 || report[2] != 0x05 || binary.BigEndian.Uint16(report[3:]) != seq {
+			return nil, errors.New("malformed reply from Ledger")
+		}
+		payload := report[5:]
+		if seq == 0 {
+			total = int(binary.BigEndian.Uint16(payload))
+			payload = payload[2:]
+		}
+		reply = append(reply, payload[:min(len(payload), total-len(reply))]...)
+	}
+	if len(reply) < 2 {
+		return nil, errors.New("malformed reply from Ledger")
+	}
+	if sw := binary.BigEndian.Uint16(reply[len(reply)-2:]); sw != 0x9000 {
+		return nil, ledgerStatusError(sw)
+	}
+	return reply[:len(reply)-2], nil
+}
@@ -0,0 +1,138 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// fakeLedger emulates the Ethereum app over HID reports, signing with key.
+type fakeLedger struct {
+	t      *testing.T
+	key    *ecdsa.PrivateKey
+	reject bool // answer sign requests with "denied by the user"
+
+	in      []byte // APDU being reassembled from written reports
+	want    int
+	out     bytes.Buffer // reply reports waiting to be read
+	message []byte       // personal message being signed
+	msgLen  int
+}
+
+func (f *fakeLedger) Write(report []byte) (int, error) {
+	if len(report) != 64 || !bytes.Equal(report[:3], []byte{0x01, 0x01, 0x05}) {
+		f.t.Fatalf("malformed report % x", report)
+	}
+	payload := report[5:]
+	if binary.BigEndian.Uint16(report[3:]) == 0 {
+		f.want = int(binary.BigEndian.Uint16(payload))
+		f.in = nil
+		payload = payload[2:]
+	}
+	f.in = append(f.in, payload[:min(len(payload), f.want-len(f.in))]...)
+	if len(f.in) == f.want {
+		f.reply(f.handle(f.in))
+	}
+	return len(report), nil
+}
+
+func (f *fakeLedger) Read(b []byte) (int, error) { return f.out.Read(b) }
+func (f *fakeLedger) Close() error               { return nil }
+
+func (f *fakeLedger) handle(apdu []byte) []byte {
+	ins, p1, data := apdu[1], apdu[2], apdu[5:5+int(apdu[4])]
+	switch ins {
+	case ledgerInsGetAddress:
+		f.checkPath(data)
+		pub := crypto.FromECDSAPub(&f.key.PublicKey)
+		addr := strings.TrimPrefix(crypto.PubkeyToAddress(f.key.PublicKey).Hex(), "0x")
+		reply := append([]byte{byte(len(pub))}, pub...)
+		reply = append(reply, byte(len(addr)))
+		return append(append(reply, addr...), 0x90, 0x00)
+	case ledgerInsSignPersonal:
+		if p1 == 0x00 {
+			data = data[f.checkPath(data):]
+			f.msgLen = int(binary.BigEndian.Uint32(data))
+			f.message, data = nil, data[4:]
+		}
+		f.message = append(f.message, data...)
+		if len(f.message) < f.msgLen {
+			return []byte{0x90, 0x00}
+		}
+		if f.reject {
+			return []byte{0x69, 0x85}
+		}
+		sig, err := crypto.Sign(accounts.TextHash(f.message), f.key)
+		if err != nil {
+			f.t.Fatal(err)
+		}
+		reply := append([]byte{sig[64] + 27}, sig[:64]...)
+		return append(reply, 0x90, 0x00)
+	}
+	return []byte{0x6d, 0x00}
+}
+
+// checkPath checks data starts with m/44'/60'/0'/0/1 and returns its length.
+func (f *fakeLedger) checkPath(data []byte) int {
+	want := []byte{5}
+	for _, c := range (accounts.DerivationPath{0x80000000 + 44, 0x80000000 + 60, 0x80000000, 0, 1}) {
+		want = binary.BigEndian.AppendUint32(want, c)
+	}
+	if !bytes.HasPrefix(data, want) {
+		f.t.Fatalf("derivation path % x, want % x", data[:min(len(data), len(want))], want)
+	}
+	return len(want)
+}
+
+func (f *fakeLedger) reply(resp []byte) {
+	msg := binary.BigEndian.AppendUint16(nil, uint16(len(resp)))
+	msg = append(msg, resp...)
+	for seq := uint16(0); len(msg) > 0; seq++ {
+		report := make([]byte, 64)
+		copy(report, []byte{0x01, 0x01, 0x05})
+		binary.BigEndian.PutUint16(report[3:], seq)
+		msg = msg[copy(report[5:], msg):]
+		f.out.Write(report)
+	}
+}
+
+func TestLedgerSignMessage(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	dev := &fakeLedger{t: t, key: key}
+	path, _ := accounts.ParseDerivationPath("m/44'/60'/0'/0/1")
+	l, err := newLedger(dev, path)
+	if err != nil {
+		t.Fatalf("newLedger: %v", err)
+	}
+	if l.Address() != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("address = %s, want %s", l.AddressHex(), crypto.PubkeyToAddress(key.PublicKey).Hex())
+	}
+
+	// Long enough to span several APDUs and several reports per APDU.
+	message := "example.com wants you to sign in with your Ethereum account:\n" + strings.Repeat("x", 400)
+	sigHex, err := l.SignMessage(message)
+	if err != nil {
+		t.Fatalf("SignMessage: %v", err)
+	}
+	sig := hexutil.MustDecode(sigHex)
+	if sig[64] != 27 && sig[64] != 28 {
+		t.Fatalf("v = %d, want 27 or 28", sig[64])
+	}
+	sig[64] -= 27
+	pub, err := crypto.SigToPub(signHash([]byte(message)), sig)
+	if err != nil || crypto.PubkeyToAddress(*pub) != l.Address() {
+		t.Fatalf("signature does not recover to the Ledger address: %v", err)
+	}
+
+	dev.reject = true
+	if _, err := l.SignMessage("short"); !errors.Is(err, ledgerStatusError(0x6985)) {
+		t.Errorf("rejected on device: got %v", err)
+	}
+}
@@ -12,6 +12,22 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
+// Signer is an Ethereum account that can sign SIWE messages. Wallet holds
+// the key in memory; other backends, such as Ledger, keep it elsewhere and
+// may block in SignMessage until the user approves.
+type Signer interface {
+	// Address returns the account that signs.
+	Address() common.Address
+	// SignMessage returns the 0x-prefixed 65-byte ERC-191 personal_sign
+	// signature of message, with v = 27 or 28.
+	SignMessage(message string) (string, error)
+}
+
+var (
+	_ Signer = (*Wallet)(nil)
+	_ Signer = (*Ledger)(nil)
+)
+
 // Wallet holds an Ethereum private key for signing SIWE messages.
 type Wallet struct {
 	privateKey *ecdsa.PrivateKey
@@ -84,6 +100,28 @@ func (w *Wallet) SignMessage(message string) (string, error) {
 	return "0x" + hex.EncodeToString(sig), nil
 }
 
+// RecoverSigner returns the address whose key made signature, an ERC-191
+// personal_sign signature of message as SignMessage returns.
+func RecoverSigner(message, signature string) (common.Address, error) {
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("decoding signature: %w", err)
+	}
+	if len(sig) != 65 {
+		return common.Address{}, fmt.Errorf("signature must be 65 bytes, got %d", len(sig))
+	}
+
+	// go-ethereum expects a recovery ID of 0 or 1, not personal_sign's 27 or 28
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	pubKey, err := crypto.SigToPub(signHash([]byte(message)), sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("recovering public key: %w", err)
+	}
+	return crypto.PubkeyToAddress(*pubKey), nil
+}
+
 // SaveKeyFile writes the private key to a file (hex-encoded).
 func (w *Wallet) SaveKeyFile(path string) error {
 	return os.WriteFile(path, []byte(w.PrivateKeyHex()+"\n"), 0600)
@@ -158,3 +158,25 @@ func TestSignMessageRecoverable(t *testing.T) {
 		t.Errorf("recovered address %s != wallet address %s", recoveredAddr.Hex(), w.AddressHex())
 	}
 }
+
+func TestRecoverSignerInvertsSignMessage(t *testing.T) {
+	w, _ := Generate()
+	sig, err := w.SignMessage("hello sovereign vpn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := RecoverSigner("hello sovereign vpn", sig)
+	if err != nil {
+		t.Fatalf("RecoverSigner: %v", err)
+	}
+	if signer != w.Address() {
+		t.Errorf("signer %s != wallet address %s", signer.Hex(), w.AddressHex())
+	}
+	if other, _ := RecoverSigner("another message", sig); other == w.Address() {
+		t.Error("signature recovered to the wallet for a different message")
+	}
+	if _, err := RecoverSigner("hello sovereign vpn", "0xdeadbeef"); err == nil {
+		t.Error("expected a short signature to be rejected")
+	}
+}
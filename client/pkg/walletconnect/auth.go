@@ -0,0 +1,70 @@
+package walletconnect
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"time"
+)
+
+// relayAuthTTL is how long a relay auth token is valid.
+const relayAuthTTL = 24 * time.Hour
+
+// relayAuthToken is the EdDSA JWT the relay requires on connect: issued by
+// the client's did:key for the relay URL.
+func relayAuthToken(key ed25519.PrivateKey, relayURL string, now time.Time) (string, error) {
+	sub := make([]byte, 32)
+	if _, err := rand.Read(sub); err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(map[string]string{"alg": "EdDSA", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss": didKey(key.Public().(ed25519.PublicKey)),
+		"sub": hex.EncodeToString(sub),
+		"aud": relayURL,
+		"iat": now.Unix(),
+		"exp": now.Add(relayAuthTTL).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	signing := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	return signing + "." + enc.EncodeToString(ed25519.Sign(key, []byte(signing))), nil
+}
+
+// didKey encodes an Ed25519 public key as a did:key: the multicodec prefix
+// 0xed01 and the key, base58btc with the multibase prefix z.
+func didKey(pub ed25519.PublicKey) string {
+	return "did:key:z" + base58(append([]byte{0xed, 0x01}, pub...))
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58 encodes b in the Bitcoin alphabet, one leading 1 per leading zero
+// byte.
+func base58(b []byte) string {
+	n := new(big.Int).SetBytes(b)
+	radix, mod := big.NewInt(58), new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
@@ -0,0 +1,91 @@
+package walletconnect
+
+import (
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// symKey is the ChaCha20-Poly1305 key two peers share on a topic.
+type symKey [32]byte
+
+func newSymKey() (symKey, error) {
+	var k symKey
+	if _, err := rand.Read(k[:]); err != nil {
+		return k, fmt.Errorf("generating key: %w", err)
+	}
+	return k, nil
+}
+
+// topic is the relay topic messages under k are published on.
+func (k symKey) topic() string {
+	h := sha256.Sum256(k[:])
+	return hex.EncodeToString(h[:])
+}
+
+// envelopeType0 is the envelope for peers that already share the key: a
+// type byte, the nonce, then the sealed payload.
+const envelopeType0 = 0x00
+
+// seal encrypts payload into a base64 type-0 envelope.
+func (k symKey) seal(payload []byte) (string, error) {
+	aead, err := chacha20poly1305.New(k[:])
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	env := append([]byte{envelopeType0}, nonce...)
+	env = aead.Seal(env, nonce, payload, nil)
+	return base64.StdEncoding.EncodeToString(env), nil
+}
+
+// open decrypts a base64 type-0 envelope sealed under k.
+func (k symKey) open(message string) ([]byte, error) {
+	env, err := base64.StdEncoding.DecodeString(message)
+	if err != nil {
+		return nil, fmt.Errorf("decoding envelope: %w", err)
+	}
+	if len(env) < 1+chacha20poly1305.NonceSize || env[0] != envelopeType0 {
+		return nil, errors.New("unsupported envelope")
+	}
+	aead, err := chacha20poly1305.New(k[:])
+	if err != nil {
+		return nil, err
+	}
+	nonce, sealed := env[1:1+chacha20poly1305.NonceSize], env[1+chacha20poly1305.NonceSize:]
+	return aead.Open(nil, nonce, sealed, nil)
+}
+
+// deriveSymKey is the session key agreed with the peer holding peerPublic:
+// HKDF-SHA256 over their X25519 shared secret.
+func deriveSymKey(key *ecdh.PrivateKey, peerPublic string) (symKey, error) {
+	var k symKey
+	raw, err := hex.DecodeString(peerPublic)
+	if err != nil {
+		return k, fmt.Errorf("decoding peer key: %w", err)
+	}
+	pub, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return k, fmt.Errorf("decoding peer key: %w", err)
+	}
+	secret, err := key.ECDH(pub)
+	if err != nil {
+		return k, err
+	}
+	derived, err := hkdf.Key(sha256.New, secret, nil, "", len(k))
+	if err != nil {
+		return k, err
+	}
+	copy(k[:], derived)
+	return k, nil
+}
@@ -0,0 +1,249 @@
+package walletconnect
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// rpcMessage is a JSON-RPC request or response, both to the relay and, end
+// to end encrypted, between the peers.
+type rpcMessage struct {
+	ID      int64           `json:"id"`
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// payloadID returns a fresh JSON-RPC id: milliseconds since the epoch with
+// three random digits, as WalletConnect peers expect.
+func payloadID() int64 {
+	var b [2]byte
+	rand.Read(b[:])
+	return time.Now().UnixMilli()*1000 + int64(binary.BigEndian.Uint16(b[:])%1000)
+}
+
+// envelopeMessage is a message published on a topic.
+type envelopeMessage struct {
+	Topic   string `json:"topic"`
+	Message string `json:"message"`
+	Tag     int    `json:"tag"`
+}
+
+// relay is a connection to a WalletConnect relay: JSON-RPC over a
+// WebSocket, publishing to and subscribing on topics.
+type relay struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	nextID  atomic.Int64
+
+	mu      sync.Mutex
+	pending map[int64]chan rpcMessage
+
+	messages chan envelopeMessage // published on subscribed topics
+	done     chan struct{}        // closed when the connection ends
+	err      error                // why, set before done is closed
+}
+
+// dialRelay connects to relayURL, authenticating with a fresh did:key.
+func dialRelay(ctx context.Context, relayURL, projectID string) (*relay, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	token, err := relayAuthToken(key, relayURL, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("signing relay auth token: %w", err)
+	}
+	u, err := url.Parse(relayURL)
+	if err != nil {
+		return nil, fmt.Errorf("relay URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("auth", token)
+	q.Set("projectId", projectID)
+	u.RawQuery = q.Encode()
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("connecting to relay: %w (HTTP %d; check the project ID)", err, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("connecting to relay: %w", err)
+	}
+	r := &relay{
+		conn:     conn,
+		pending:  make(map[int64]chan rpcMessage),
+		messages: make(chan envelopeMessage, 64),
+		done:     make(chan struct{}),
+	}
+	r.nextID.Store(payloadID())
+	go r.read()
+	return r, nil
+}
+
+func (r *relay) read() {
+	for {
+		var m rpcMessage
+		if err := r.conn.ReadJSON(&m); err != nil {
+			r.err = fmt.Errorf("relay connection lost: %w", err)
+			close(r.done)
+			return
+		}
+		if m.Method == "" {
+			r.mu.Lock()
+			ch := r.pending[m.ID]
+			delete(r.pending, m.ID)
+			r.mu.Unlock()
+			if ch != nil {
+				ch <- m
+			}
+			continue
+		}
+		if m.Method != "irn_subscription" {
+			continue
+		}
+		var params struct {
+			Data envelopeMessage `json:"data"`
+		}
+		if json.Unmarshal(m.Params, &params) != nil {
+			continue
+		}
+		r.write(rpcMessage{ID: m.ID, JSONRPC: "2.0", Result: json.RawMessage("true")})
+		select {
+		case r.messages <- params.Data:
+		default:
+			// Nobody is waiting on this many messages; the peer will resend
+			// anything it needs an answer to.
+		}
+	}
+}
+
+func (r *relay) write(m rpcMessage) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	return r.conn.WriteJSON(m)
+}
+
+// call sends a request to the relay and decodes its result into result.
+func (r *relay) call(ctx context.Context, method string, params, result any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	id := r.nextID.Add(1)
+	ch := make(chan rpcMessage, 1)
+	r.mu.Lock()
+	r.pending[id] = ch
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, id)
+		r.mu.Unlock()
+	}()
+
+	if err := r.write(rpcMessage{ID: id, JSONRPC: "2.0", Method: method, Params: raw}); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	select {
+	case m := <-ch:
+		if m.Error != nil {
+			return fmt.Errorf("%s: %w", method, m.Error)
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(m.Result, result)
+	case <-r.done:
+		return r.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *relay) subscribe(ctx context.Context, topic string) error {
+	var id string
+	return r.call(ctx, "irn_subscribe", map[string]string{"topic": topic}, &id)
+}
+
+func (r *relay) publish(ctx context.Context, topic, message string, tag int, ttl time.Duration) error {
+	return r.call(ctx, "irn_publish", map[string]any{
+		"topic":   topic,
+		"message": message,
+		"ttl":     int(ttl.Seconds()),
+		"tag":     tag,
+	}, nil)
+}
+
+// send seals m under key and publishes it on the key's topic.
+func (r *relay) send(ctx context.Context, key symKey, m rpcMessage, tag int) error {
+	m.JSONRPC = "2.0"
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	sealed, err := key.seal(payload)
+	if err != nil {
+		return err
+	}
+	return r.publish(ctx, key.topic(), sealed, tag, messageTTL)
+}
+
+// receive returns the next message published on key's topic. Pings from
+// the peer are answered on the way.
+func (r *relay) receive(ctx context.Context, key symKey) (rpcMessage, error) {
+	topic := key.topic()
+	for {
+		select {
+		case env := <-r.messages:
+			if env.Topic != topic {
+				continue
+			}
+			payload, err := key.open(env.Message)
+			if err != nil {
+				continue
+			}
+			var m rpcMessage
+			if json.Unmarshal(payload, &m) != nil {
+				continue
+			}
+			switch m.Method {
+			case "wc_pairingPing", "wc_sessionPing", "wc_sessionEvent", "wc_sessionExtend", "wc_sessionUpdate":
+				// Acknowledge; the response tag follows the request's.
+				if err := r.send(ctx, key, rpcMessage{ID: m.ID, Result: json.RawMessage("true")}, env.Tag+1); err != nil {
+					return rpcMessage{}, err
+				}
+				continue
+			}
+			return m, nil
+		case <-r.done:
+			return rpcMessage{}, r.err
+		case <-ctx.Done():
+			return rpcMessage{}, ctx.Err()
+		}
+	}
+}
+
+func (r *relay) close() error {
+	return r.conn.Close()
+}
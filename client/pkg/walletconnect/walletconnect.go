@@ -0,0 +1,297 @@
+// Package walletconnect signs SIWE messages with a mobile wallet over
+// WalletConnect v2, so the private key never reaches this machine.
+//
+// Pair connects to the relay and returns a pairing URI for the wallet to
+// scan. Approve waits for the user to accept the session, and the Session
+// it returns implements wallet.Signer by sending personal_sign requests,
+// which the user confirms in the wallet.
+package walletconnect
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// DefaultRelayURL is the public WalletConnect relay.
+const DefaultRelayURL = "wss://relay.walletconnect.org"
+
+// messageTTL is how long the relay holds a message for an offline peer, and
+// how long a pairing or signing request waits for the user.
+const messageTTL = 5 * time.Minute
+
+// Sign API message tags. A response's tag is its request's plus one.
+const (
+	tagSessionPropose        = 1100
+	tagSessionSettleResponse = 1103
+	tagSessionDelete         = 1112
+	tagSessionRequest        = 1108
+)
+
+// Config configures a pairing.
+type Config struct {
+	// ProjectID identifies the app to the relay; create one at
+	// cloud.reown.com. Required.
+	ProjectID string
+	// RelayURL defaults to DefaultRelayURL.
+	RelayURL string
+	// Metadata describes this app in the wallet's approval prompt.
+	Metadata Metadata
+	// ChainID is the EIP-155 chain the session is requested for. Default 1.
+	ChainID int
+}
+
+// Metadata describes the app to the wallet.
+type Metadata struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	URL         string   `json:"url"`
+	Icons       []string `json:"icons"`
+}
+
+// Pairing is a session proposal waiting for a wallet.
+type Pairing struct {
+	// URI is the wc: URI the wallet scans, usually as a QR code.
+	URI string
+
+	relay      *relay
+	key        symKey
+	private    *ecdh.PrivateKey
+	proposalID int64
+	chain      string
+}
+
+// Pair connects to the relay and proposes a session asking for
+// personal_sign on the configured chain.
+func Pair(ctx context.Context, cfg Config) (*Pairing, error) {
+	if cfg.ProjectID == "" {
+		return nil, errors.New("a WalletConnect project ID is required")
+	}
+	if cfg.RelayURL == "" {
+		cfg.RelayURL = DefaultRelayURL
+	}
+	if cfg.ChainID == 0 {
+		cfg.ChainID = 1
+	}
+	if cfg.Metadata.Icons == nil {
+		cfg.Metadata.Icons = []string{}
+	}
+
+	key, err := newSymKey()
+	if err != nil {
+		return nil, err
+	}
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating key: %w", err)
+	}
+	r, err := dialRelay(ctx, cfg.RelayURL, cfg.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	p := &Pairing{
+		relay:      r,
+		key:        key,
+		private:    private,
+		proposalID: payloadID(),
+		chain:      "eip155:" + strconv.Itoa(cfg.ChainID),
+	}
+	if err := p.propose(ctx, cfg.Metadata); err != nil {
+		r.close()
+		return nil, err
+	}
+
+	expiry := time.Now().Add(messageTTL).Unix()
+	p.URI = fmt.Sprintf("wc:%s@2?relay-protocol=irn&symKey=%s&expiryTimestamp=%d",
+		key.topic(), hex.EncodeToString(key[:]), expiry)
+	return p, nil
+}
+
+func (p *Pairing) propose(ctx context.Context, metadata Metadata) error {
+	if err := p.relay.subscribe(ctx, p.key.topic()); err != nil {
+		return err
+	}
+	params, err := json.Marshal(map[string]any{
+		"requiredNamespaces": map[string]any{
+			"eip155": map[string]any{
+				"chains":  []string{p.chain},
+				"methods": []string{"personal_sign"},
+				"events":  []string{},
+			},
+		},
+		"optionalNamespaces": map[string]any{},
+		"relays":             []map[string]string{{"protocol": "irn"}},
+		"proposer": map[string]any{
+			"publicKey": hex.EncodeToString(p.private.PublicKey().Bytes()),
+			"metadata":  metadata,
+		},
+		"expiryTimestamp": time.Now().Add(messageTTL).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	return p.relay.send(ctx, p.key, rpcMessage{ID: p.proposalID, Method: "wc_sessionPropose", Params: params}, tagSessionPropose)
+}
+
+// Approve waits until the wallet accepts the proposal and settles the
+// session, or until ctx ends. The pairing cannot be reused either way.
+func (p *Pairing) Approve(ctx context.Context) (*Session, error) {
+	s, err := p.approve(ctx)
+	if err != nil {
+		p.relay.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (p *Pairing) approve(ctx context.Context) (*Session, error) {
+	var responderKey string
+	for responderKey == "" {
+		m, err := p.relay.receive(ctx, p.key)
+		if err != nil {
+			return nil, err
+		}
+		if m.ID != p.proposalID || m.Method != "" {
+			continue
+		}
+		if m.Error != nil {
+			return nil, fmt.Errorf("wallet rejected the session: %w", m.Error)
+		}
+		var result struct {
+			ResponderPublicKey string `json:"responderPublicKey"`
+		}
+		if err := json.Unmarshal(m.Result, &result); err != nil || result.ResponderPublicKey == "" {
+			return nil, errors.New("wallet sent a malformed session approval")
+		}
+		responderKey = result.ResponderPublicKey
+	}
+
+	sessionKey, err := deriveSymKey(p.private, responderKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.relay.subscribe(ctx, sessionKey.topic()); err != nil {
+		return nil, err
+	}
+	for {
+		m, err := p.relay.receive(ctx, sessionKey)
+		if err != nil {
+			return nil, err
+		}
+		if m.Method != "wc_sessionSettle" {
+			continue
+		}
+		var settle struct {
+			Namespaces map[string]struct {
+				Accounts []string `json:"accounts"`
+			} `json:"namespaces"`
+		}
+		if err := json.Unmarshal(m.Params, &settle); err != nil {
+			return nil, fmt.Errorf("decoding session settlement: %w", err)
+		}
+		account, ok := pickAccount(settle.Namespaces["eip155"].Accounts, p.chain)
+		if !ok {
+			return nil, fmt.Errorf("wallet shared no account on %s", p.chain)
+		}
+		if err := p.relay.send(ctx, sessionKey, rpcMessage{ID: m.ID, Result: json.RawMessage("true")}, tagSessionSettleResponse); err != nil {
+			return nil, err
+		}
+		return &Session{relay: p.relay, key: sessionKey, chain: p.chain, address: account}, nil
+	}
+}
+
+// pickAccount returns the first CAIP-10 account ("eip155:1:0xabc...") on
+// chain.
+func pickAccount(accounts []string, chain string) (common.Address, bool) {
+	for _, a := range accounts {
+		addr, ok := strings.CutPrefix(a, chain+":")
+		if ok && common.IsHexAddress(addr) {
+			return common.HexToAddress(addr), true
+		}
+	}
+	return common.Address{}, false
+}
+
+// Session is an approved WalletConnect session with one account.
+type Session struct {
+	relay   *relay
+	key     symKey
+	chain   string
+	address common.Address
+}
+
+// Address returns the account the wallet shared.
+func (s *Session) Address() common.Address {
+	return s.address
+}
+
+// SignMessage asks the wallet to personal_sign message and waits up to the
+// message TTL for the user to confirm.
+func (s *Session) SignMessage(message string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), messageTTL)
+	defer cancel()
+
+	params, err := json.Marshal(map[string]any{
+		"request": map[string]any{
+			"method": "personal_sign",
+			"params": []string{hexutil.Encode([]byte(message)), s.address.Hex()},
+		},
+		"chainId": s.chain,
+	})
+	if err != nil {
+		return "", err
+	}
+	id := payloadID()
+	if err := s.relay.send(ctx, s.key, rpcMessage{ID: id, Method: "wc_sessionRequest", Params: params}, tagSessionRequest); err != nil {
+		return "", fmt.Errorf("sending signing request: %w", err)
+	}
+	for {
+		m, err := s.relay.receive(ctx, s.key)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return "", errors.New("timed out waiting for the wallet to sign")
+		}
+		if err != nil {
+			return "", err
+		}
+		if m.Method == "wc_sessionDelete" {
+			return "", errors.New("the wallet ended the WalletConnect session")
+		}
+		if m.ID != id || m.Method != "" {
+			continue
+		}
+		if m.Error != nil {
+			return "", fmt.Errorf("wallet declined to sign: %w", m.Error)
+		}
+		var sigHex string
+		if err := json.Unmarshal(m.Result, &sigHex); err != nil {
+			return "", fmt.Errorf("decoding signature: %w", err)
+		}
+		sig, err := hexutil.Decode(sigHex)
+		if err != nil || len(sig) != 65 {
+			return "", fmt.Errorf("wallet returned a malformed signature %q", sigHex)
+		}
+		if sig[64] < 27 {
+			sig[64] += 27
+		}
+		return hexutil.Encode(sig), nil
+	}
+}
+
+// Close ends the session in the wallet and disconnects from the relay.
+func (s *Session) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	params, _ := json.Marshal(map[string]any{"code": 6000, "message": "User disconnected."})
+	s.relay.send(ctx, s.key, rpcMessage{ID: payloadID(), Method: "wc_sessionDelete", Params: params}, tagSessionDelete)
+	return s.relay.close()
+}
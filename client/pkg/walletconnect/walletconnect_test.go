@@ -0,0 +1,277 @@
+package walletconnect
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gorilla/websocket"
+)
+
+// fakeRelay is an in-memory relay: it stores every published message and
+// delivers it to the topic's other subscribers, including later ones.
+type fakeRelay struct {
+	mu      sync.Mutex
+	subs    map[string][]*fakeRelayConn
+	mailbox map[string][]published
+}
+
+type published struct {
+	from *fakeRelayConn
+	msg  envelopeMessage
+}
+
+type fakeRelayConn struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func (c *fakeRelayConn) deliver(msg envelopeMessage) {
+	params, _ := json.Marshal(map[string]any{"id": "sub", "data": msg})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.WriteJSON(rpcMessage{ID: payloadID(), JSONRPC: "2.0", Method: "irn_subscription", Params: params})
+}
+
+func newFakeRelay(t *testing.T) string {
+	t.Helper()
+	f := &fakeRelay{subs: map[string][]*fakeRelayConn{}, mailbox: map[string][]published{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("projectId") != "test-project" || strings.Count(r.URL.Query().Get("auth"), ".") != 2 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		ws, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		c := &fakeRelayConn{conn: ws}
+		for {
+			var m rpcMessage
+			if ws.ReadJSON(&m) != nil {
+				return
+			}
+			var params envelopeMessage
+			json.Unmarshal(m.Params, &params)
+			switch m.Method {
+			case "irn_subscribe":
+				f.mu.Lock()
+				f.subs[params.Topic] = append(f.subs[params.Topic], c)
+				stored := f.mailbox[params.Topic]
+				f.mu.Unlock()
+				c.mu.Lock()
+				c.conn.WriteJSON(rpcMessage{ID: m.ID, JSONRPC: "2.0", Result: json.RawMessage(`"sub"`)})
+				c.mu.Unlock()
+				for _, p := range stored {
+					if p.from != c {
+						c.deliver(p.msg)
+					}
+				}
+			case "irn_publish":
+				f.mu.Lock()
+				f.mailbox[params.Topic] = append(f.mailbox[params.Topic], published{from: c, msg: params})
+				subs := append([]*fakeRelayConn(nil), f.subs[params.Topic]...)
+				f.mu.Unlock()
+				c.mu.Lock()
+				c.conn.WriteJSON(rpcMessage{ID: m.ID, JSONRPC: "2.0", Result: json.RawMessage("true")})
+				c.mu.Unlock()
+				for _, s := range subs {
+					if s != c {
+						s.deliver(params)
+					}
+				}
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// fakeWallet plays the mobile wallet: it scans uri, approves the session
+// for key's account, pings, signs one personal_sign request and waits for
+// the session to be deleted.
+func fakeWallet(ctx context.Context, relayURL, uri string, key *ecdsa.PrivateKey) error {
+	u, err := url.Parse(strings.Replace(uri, "wc:", "wc://", 1))
+	if err != nil {
+		return err
+	}
+	raw, err := hex.DecodeString(u.Query().Get("symKey"))
+	if err != nil || len(raw) != 32 {
+		return fmt.Errorf("bad symKey in %s", uri)
+	}
+	var pairingKey symKey
+	copy(pairingKey[:], raw)
+	if u.User.Username() != pairingKey.topic() {
+		return fmt.Errorf("URI topic does not match its key: %s", uri)
+	}
+
+	r, err := dialRelay(ctx, relayURL, "test-project")
+	if err != nil {
+		return err
+	}
+	defer r.close()
+	if err := r.subscribe(ctx, pairingKey.topic()); err != nil {
+		return err
+	}
+	proposal, err := r.receive(ctx, pairingKey)
+	if err != nil {
+		return err
+	}
+	var propose struct {
+		Proposer struct {
+			PublicKey string `json:"publicKey"`
+		} `json:"proposer"`
+	}
+	if proposal.Method != "wc_sessionPropose" || json.Unmarshal(proposal.Params, &propose) != nil {
+		return fmt.Errorf("expected a session proposal, got %+v", proposal)
+	}
+
+	private, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	sessionKey, err := deriveSymKey(private, propose.Proposer.PublicKey)
+	if err != nil {
+		return err
+	}
+	result, _ := json.Marshal(map[string]any{
+		"relay":              map[string]string{"protocol": "irn"},
+		"responderPublicKey": hex.EncodeToString(private.PublicKey().Bytes()),
+	})
+	if err := r.subscribe(ctx, sessionKey.topic()); err != nil {
+		return err
+	}
+	if err := r.send(ctx, pairingKey, rpcMessage{ID: proposal.ID, Result: result}, 1101); err != nil {
+		return err
+	}
+
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	settle, _ := json.Marshal(map[string]any{
+		"namespaces": map[string]any{"eip155": map[string]any{
+			"accounts": []string{"eip155:1:" + address.Hex()},
+			"methods":  []string{"personal_sign"},
+			"events":   []string{},
+		}},
+	})
+	settleID := payloadID()
+	if err := r.send(ctx, sessionKey, rpcMessage{ID: settleID, Method: "wc_sessionSettle", Params: settle}, 1102); err != nil {
+		return err
+	}
+	pingID := settleID + 1
+	if err := r.send(ctx, sessionKey, rpcMessage{ID: pingID, Method: "wc_sessionPing", Params: json.RawMessage("{}")}, 1114); err != nil {
+		return err
+	}
+
+	answered := map[int64]bool{}
+	for {
+		m, err := r.receive(ctx, sessionKey)
+		if err != nil {
+			return err
+		}
+		switch {
+		case m.Method == "" && (m.ID == settleID || m.ID == pingID):
+			answered[m.ID] = true
+		case m.Method == "wc_sessionRequest":
+			var req struct {
+				Request struct {
+					Method string   `json:"method"`
+					Params []string `json:"params"`
+				} `json:"request"`
+			}
+			json.Unmarshal(m.Params, &req)
+			if req.Request.Method != "personal_sign" || len(req.Request.Params) != 2 {
+				return fmt.Errorf("unexpected request %s", m.Params)
+			}
+			msg, err := hexutil.Decode(req.Request.Params[0])
+			if err != nil {
+				return err
+			}
+			sig, err := crypto.Sign(accounts.TextHash(msg), key)
+			if err != nil {
+				return err
+			}
+			// Some wallets return v as 0/1; the session normalizes it.
+			result, _ := json.Marshal(hexutil.Encode(sig))
+			if err := r.send(ctx, sessionKey, rpcMessage{ID: m.ID, Result: result}, 1109); err != nil {
+				return err
+			}
+		case m.Method == "wc_sessionDelete":
+			if !answered[settleID] || !answered[pingID] {
+				return fmt.Errorf("settlement or ping went unanswered: %v", answered)
+			}
+			return nil
+		}
+	}
+}
+
+func TestSessionSignsThroughWallet(t *testing.T) {
+	relayURL := newFakeRelay(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pairing, err := Pair(ctx, Config{ProjectID: "test-project", RelayURL: relayURL, Metadata: Metadata{Name: "svpn"}})
+	if err != nil {
+		t.Fatalf("Pair: %v", err)
+	}
+	if !strings.HasPrefix(pairing.URI, "wc:") || !strings.Contains(pairing.URI, "@2?relay-protocol=irn&symKey=") {
+		t.Fatalf("URI = %s", pairing.URI)
+	}
+
+	key, _ := crypto.GenerateKey()
+	walletErr := make(chan error, 1)
+	go func() { walletErr <- fakeWallet(ctx, relayURL, pairing.URI, key) }()
+
+	session, err := pairing.Approve(ctx)
+	if err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	if session.Address() != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("session address = %s, want the wallet's", session.Address().Hex())
+	}
+
+	message := "example.com wants you to sign in with your Ethereum account"
+	sigHex, err := session.SignMessage(message)
+	if err != nil {
+		t.Fatalf("SignMessage: %v", err)
+	}
+	sig := hexutil.MustDecode(sigHex)
+	if sig[64] != 27 && sig[64] != 28 {
+		t.Fatalf("v = %d, want 27 or 28", sig[64])
+	}
+	sig[64] -= 27
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte(message)), sig)
+	if err != nil || crypto.PubkeyToAddress(*pub) != session.Address() {
+		t.Fatalf("signature does not recover to the wallet address: %v", err)
+	}
+
+	session.Close()
+	if err := <-walletErr; err != nil {
+		t.Fatalf("wallet: %v", err)
+	}
+}
+
+func TestPairRejectsBadProjectID(t *testing.T) {
+	relayURL := newFakeRelay(t)
+	if _, err := Pair(context.Background(), Config{ProjectID: "other", RelayURL: relayURL}); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Pair with an unknown project ID: %v, want the relay's 401", err)
+	}
+}
+
+func TestDIDKey(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	if did := didKey(pub); !strings.HasPrefix(did, "did:key:z6Mk") {
+		t.Errorf("didKey = %s, want an Ed25519 did:key (z6Mk...)", did)
+	}
+}
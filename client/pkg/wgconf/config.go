@@ -1,11 +1,14 @@
-// Package wgconf generates WireGuard configuration files.
+// Package wgconf generates, parses and validates WireGuard configuration files.
 package wgconf
 
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"golang.org/x/crypto/curve25519"
 )
@@ -37,6 +40,67 @@ func GenerateKeyPair() (*KeyPair, error) {
 	}, nil
 }
 
+// KeyPairFromPrivate derives the key pair for a base64 private key.
+func KeyPairFromPrivate(privateKey string) (*KeyPair, error) {
+	raw, err := base64.StdEncoding.DecodeString(privateKey)
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("invalid WireGuard private key")
+	}
+
+	var priv, pub [32]byte
+	copy(priv[:], raw)
+	curve25519.ScalarBaseMult(&pub, &priv)
+
+	return &KeyPair{
+		PrivateKey: privateKey,
+		PublicKey:  base64.StdEncoding.EncodeToString(pub[:]),
+	}, nil
+}
+
+// LoadOrGenerateKeyPair returns the key pair stored at path, generating and
+// saving a new one if the file does not exist or rotate is set. The file
+// holds the base64 private key only, like `wg genkey` output.
+func LoadOrGenerateKeyPair(path string, rotate bool) (kp *KeyPair, generated bool, err error) {
+	if !rotate {
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			kp, err := KeyPairFromPrivate(strings.TrimSpace(string(data)))
+			if err != nil {
+				return nil, false, fmt.Errorf("reading %s: %w", path, err)
+			}
+			return kp, false, nil
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, false, err
+		}
+	}
+
+	kp, err = GenerateKeyPair()
+	if err != nil {
+		return nil, false, err
+	}
+	if err := SaveKeyPair(path, kp); err != nil {
+		return nil, false, err
+	}
+	return kp, true, nil
+}
+
+// SaveKeyPair stores kp's private key at path in the format
+// LoadOrGenerateKeyPair reads, replacing any key already there.
+func SaveKeyPair(path string, kp *KeyPair) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(kp.PrivateKey+"\n"), 0600)
+}
+
+// DefaultPersistentKeepalive is the keepalive interval (seconds) svpn uses
+// unless told otherwise; it keeps typical NAT mappings open.
+const DefaultPersistentKeepalive = 25
+
+// KeepaliveOff in Config.PersistentKeepalive disables keepalive.
+const KeepaliveOff = -1
+
 // Config holds all values needed to write a WireGuard config file.
 type Config struct {
 	PrivateKey      string
@@ -45,6 +109,10 @@ type Config struct {
 	ServerPublicKey string
 	ServerEndpoint  string
 	AllowedIPs      string
+
+	// PersistentKeepalive is the keepalive interval in seconds; 0 means
+	// DefaultPersistentKeepalive and KeepaliveOff leaves it out.
+	PersistentKeepalive int
 }
 
 // WriteFile writes a wg-quick compatible configuration file.
@@ -55,7 +123,7 @@ func (c *Config) WriteFile(path string) error {
 
 // String returns the wg-quick configuration as a string.
 func (c *Config) String() string {
-	return fmt.Sprintf(`[Interface]
+	s := fmt.Sprintf(`[Interface]
 PrivateKey = %s
 Address = %s
 DNS = %s
@@ -64,6 +132,13 @@ DNS = %s
 PublicKey = %s
 Endpoint = %s
 AllowedIPs = %s
-PersistentKeepalive = 25
 `, c.PrivateKey, c.ClientAddress, c.DNS, c.ServerPublicKey, c.ServerEndpoint, c.AllowedIPs)
+	keepalive := c.PersistentKeepalive
+	if keepalive == 0 {
+		keepalive = DefaultPersistentKeepalive
+	}
+	if keepalive > 0 {
+		s += fmt.Sprintf("PersistentKeepalive = %d\n", keepalive)
+	}
+	return s
 }
@@ -47,6 +47,8 @@ func TestConfigString(t *testing.T) {
 		ServerPublicKey: "c2VydmVycHVia2V5MTIzNDU2Nzg5MDEyMzQ1Njc4",
 		ServerEndpoint:  "vpn.example.com:51820",
 		AllowedIPs:      "0.0.0.0/0, ::/0",
+
+		PersistentKeepalive: DefaultPersistentKeepalive,
 	}
 
 	s := cfg.String()
@@ -112,3 +114,66 @@ func TestConfigWriteFile(t *testing.T) {
 		t.Error("written file should contain the private key")
 	}
 }
+
+func TestLoadOrGenerateKeyPairReusesKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svpn", "wg.key")
+
+	first, generated, err := LoadOrGenerateKeyPair(path, false)
+	if err != nil || !generated {
+		t.Fatalf("first call: generated=%v err=%v", generated, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("key file should be 0600, got %o", info.Mode().Perm())
+	}
+
+	again, generated, err := LoadOrGenerateKeyPair(path, false)
+	if err != nil || generated {
+		t.Fatalf("second call: generated=%v err=%v", generated, err)
+	}
+	if *again != *first {
+		t.Fatalf("expected stored key pair to be reused, got %+v want %+v", again, first)
+	}
+
+	rotated, generated, err := LoadOrGenerateKeyPair(path, true)
+	if err != nil || !generated {
+		t.Fatalf("rotate: generated=%v err=%v", generated, err)
+	}
+	if rotated.PrivateKey == first.PrivateKey {
+		t.Fatal("expected rotate to replace the key")
+	}
+}
+
+func TestKeyPairFromPrivateMatchesGenerated(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	derived, err := KeyPairFromPrivate(kp.PrivateKey)
+	if err != nil {
+		t.Fatalf("KeyPairFromPrivate: %v", err)
+	}
+	if derived.PublicKey != kp.PublicKey {
+		t.Fatalf("public key = %s, want %s", derived.PublicKey, kp.PublicKey)
+	}
+	if _, err := KeyPairFromPrivate("short"); err == nil {
+		t.Fatal("expected invalid key to be rejected")
+	}
+}
+
+func TestConfigStringKeepalive(t *testing.T) {
+	cfg := &Config{PrivateKey: "k", ClientAddress: "10.8.0.2/24", ServerPublicKey: "p", AllowedIPs: "0.0.0.0/0"}
+	if !strings.Contains(cfg.String(), "PersistentKeepalive = 25") {
+		t.Errorf("zero-value config should use the default keepalive, got:\n%s", cfg.String())
+	}
+
+	cfg.PersistentKeepalive = KeepaliveOff
+	if strings.Contains(cfg.String(), "PersistentKeepalive") {
+		t.Errorf("disabled keepalive should not be written, got:\n%s", cfg.String())
+	}
+
+	cfg.PersistentKeepalive = 10
+	if !strings.Contains(cfg.String(), "PersistentKeepalive = 10") {
+		t.Errorf("expected PersistentKeepalive = 10, got:\n%s", cfg.String())
+	}
+}
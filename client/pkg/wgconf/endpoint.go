@@ -0,0 +1,37 @@
+package wgconf
+
+import (
+	"net"
+	"time"
+)
+
+// PickEndpoint chooses the server endpoint to write into the config: the
+// preferred one if this host can route to it, otherwise the first reachable
+// alternate. Dual-stack gateways advertise one endpoint per address family,
+// so a client on an IPv6-only (or IPv4-only) network still gets one it can
+// use. If none look reachable, preferred is returned unchanged.
+func PickEndpoint(preferred string, alternates []string) string {
+	return pickEndpoint(preferred, alternates, routable)
+}
+
+func pickEndpoint(preferred string, alternates []string, reachable func(string) bool) string {
+	for _, ep := range append([]string{preferred}, alternates...) {
+		if ep != "" && reachable(ep) {
+			return ep
+		}
+	}
+	return preferred
+}
+
+// routable reports whether the host has a route to endpoint. Connecting a
+// UDP socket sends no packets but fails straight away when the address
+// family has no route, e.g. an IPv6 endpoint on an IPv4-only network, or
+// when the hostname does not resolve.
+func routable(endpoint string) bool {
+	conn, err := net.DialTimeout("udp", endpoint, 3*time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
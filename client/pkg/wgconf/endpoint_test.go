@@ -0,0 +1,48 @@
+package wgconf
+
+import "testing"
+
+func TestPickEndpoint(t *testing.T) {
+	v4 := "203.0.113.10:51820"
+	v6 := "[2001:db8::1]:51820"
+
+	only := func(ok ...string) func(string) bool {
+		return func(ep string) bool {
+			for _, o := range ok {
+				if ep == o {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	tests := []struct {
+		name       string
+		preferred  string
+		alternates []string
+		reachable  func(string) bool
+		want       string
+	}{
+		{"preferred reachable", v4, []string{v6}, only(v4, v6), v4},
+		{"v6-only network", v4, []string{v6}, only(v6), v6},
+		{"nothing reachable", v4, []string{v6}, only(), v4},
+		{"no alternates", v4, nil, only(), v4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pickEndpoint(tt.preferred, tt.alternates, tt.reachable); got != tt.want {
+				t.Errorf("pickEndpoint = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoutable(t *testing.T) {
+	if !routable("127.0.0.1:51820") {
+		t.Error("expected loopback endpoint to be routable")
+	}
+	if routable("not-an-endpoint") {
+		t.Error("expected malformed endpoint to be unroutable")
+	}
+}
@@ -0,0 +1,101 @@
+package wgconf
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadConfigFile reads a wg-quick file written by WriteFile (or edited by
+// hand) back into a Config.
+func LoadConfigFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseConfig(f)
+}
+
+// ParseConfig parses a single-peer wg-quick configuration into a Config.
+// Key order, key case and comments do not matter; syntax errors, an unknown
+// or missing section and a missing key do. Values are not checked: use
+// Validate for a full report.
+func ParseConfig(r io.Reader) (*Config, error) {
+	lines, err := scanLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var iface map[string]string
+	var peers []map[string]string
+	var current map[string]string
+	for _, l := range lines {
+		switch {
+		case l.bad != "":
+			return nil, fmt.Errorf("malformed config: line %d: expected \"Key = Value\", got %q", l.n, l.bad)
+		case l.header:
+			current = map[string]string{}
+			switch strings.ToLower(l.section) {
+			case "interface":
+				if iface != nil {
+					return nil, fmt.Errorf("malformed config: line %d: duplicate [Interface] section", l.n)
+				}
+				iface = current
+			case "peer":
+				peers = append(peers, current)
+			default:
+				return nil, fmt.Errorf("malformed config: line %d: unknown section [%s]", l.n, l.section)
+			}
+		case current == nil:
+			return nil, fmt.Errorf("malformed config: line %d: entry outside of any section", l.n)
+		default:
+			key := strings.ToLower(l.key)
+			if _, dup := current[key]; !dup {
+				current[key] = l.value
+			}
+		}
+	}
+	if iface == nil {
+		return nil, fmt.Errorf("malformed config: missing [Interface] section")
+	}
+	if len(peers) != 1 {
+		return nil, fmt.Errorf("malformed config: expected exactly one [Peer] section, got %d", len(peers))
+	}
+
+	cfg := &Config{}
+	fields := []struct {
+		section map[string]string
+		name    string
+		key     string
+		dst     *string
+	}{
+		{iface, "Interface", "PrivateKey", &cfg.PrivateKey},
+		{iface, "Interface", "Address", &cfg.ClientAddress},
+		{iface, "Interface", "DNS", &cfg.DNS},
+		{peers[0], "Peer", "PublicKey", &cfg.ServerPublicKey},
+		{peers[0], "Peer", "Endpoint", &cfg.ServerEndpoint},
+		{peers[0], "Peer", "AllowedIPs", &cfg.AllowedIPs},
+	}
+	for _, field := range fields {
+		v, ok := field.section[strings.ToLower(field.key)]
+		if !ok {
+			return nil, fmt.Errorf("malformed config: [%s] is missing %s", field.name, field.key)
+		}
+		*field.dst = v
+	}
+	// A file without keepalive has it off, not at the default.
+	cfg.PersistentKeepalive = KeepaliveOff
+	if v, ok := peers[0]["persistentkeepalive"]; ok && v != "off" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("malformed config: invalid PersistentKeepalive %q", v)
+		}
+		if n > 0 {
+			cfg.PersistentKeepalive = n
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,93 @@
+package wgconf
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigRoundTrip(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	server, _ := GenerateKeyPair()
+	want := &Config{
+		PrivateKey:      kp.PrivateKey,
+		ClientAddress:   "10.8.0.2/24",
+		DNS:             "1.1.1.1",
+		ServerPublicKey: server.PublicKey,
+		ServerEndpoint:  "vpn.example.com:51820",
+		AllowedIPs:      "0.0.0.0/0, ::/0",
+
+		PersistentKeepalive: 15,
+	}
+
+	got, err := ParseConfig(strings.NewReader(want.String()))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if *got != *want {
+		t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", got, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "wg.conf")
+	if err := want.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	loaded, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatalf("LoadConfigFile: %v", err)
+	}
+	if *loaded != *want {
+		t.Fatalf("LoadConfigFile mismatch:\n got %+v\nwant %+v", loaded, want)
+	}
+}
+
+func TestParseConfigToleratesOrderAndComments(t *testing.T) {
+	conf := `# reordered by hand
+[Peer]
+allowedips = 10.0.0.0/8   # split tunnel
+Endpoint = 203.0.113.10:51820
+PublicKey = c2VydmVy
+
+[Interface]
+DNS = 9.9.9.9
+Address = 10.8.0.9/24
+PrivateKey = Y2xpZW50
+`
+	cfg, err := ParseConfig(strings.NewReader(conf))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if cfg.AllowedIPs != "10.0.0.0/8" || cfg.ClientAddress != "10.8.0.9/24" || cfg.PrivateKey != "Y2xpZW50" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	// No PersistentKeepalive line means keepalive is off, and stays off
+	// when the config is written back.
+	if cfg.PersistentKeepalive != KeepaliveOff || strings.Contains(cfg.String(), "PersistentKeepalive") {
+		t.Fatalf("keepalive = %d, want off", cfg.PersistentKeepalive)
+	}
+}
+
+func TestParseConfigRejectsMalformed(t *testing.T) {
+	full := "[Interface]\nPrivateKey = a\nAddress = 10.8.0.2/24\nDNS = 1.1.1.1\n\n" +
+		"[Peer]\nPublicKey = b\nEndpoint = h:1\nAllowedIPs = 0.0.0.0/0\n"
+
+	tests := map[string]string{
+		"garbage line":     strings.Replace(full, "DNS = 1.1.1.1", "DNS 1.1.1.1", 1),
+		"entry before any": "PrivateKey = a\n" + full,
+		"unknown section":  full + "[Bogus]\n",
+		"missing key":      strings.Replace(full, "Endpoint = h:1\n", "", 1),
+		"no peer":          "[Interface]\nPrivateKey = a\nAddress = 10.8.0.2/24\nDNS = 1.1.1.1\n",
+		"two peers":        full + "[Peer]\nPublicKey = c\n",
+		"empty":            "",
+	}
+	for name, conf := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseConfig(strings.NewReader(conf)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+	if _, err := ParseConfig(strings.NewReader(full)); err != nil {
+		t.Fatalf("baseline config should parse: %v", err)
+	}
+}
@@ -0,0 +1,330 @@
+package wgconf
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Problem is a validation failure. Line is 0 when it concerns the file or
+// section as a whole.
+type Problem struct {
+	Line    int
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+}
+
+// line is one non-blank, comment-stripped line of a wg-quick file: a
+// section header, a "Key = Value" entry, or something malformed.
+type line struct {
+	n       int
+	header  bool   // a [Section] line
+	section string // the header's section name
+	key     string
+	value   string
+	bad     string // set when the line is neither
+}
+
+// scanLines splits a wg-quick file into its meaningful lines.
+func scanLines(r io.Reader) ([]line, error) {
+	var lines []line
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		switch {
+		case text == "":
+			continue
+		case strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]"):
+			lines = append(lines, line{n: n, header: true, section: strings.TrimSpace(text[1 : len(text)-1])})
+		default:
+			key, value, ok := strings.Cut(text, "=")
+			if !ok {
+				lines = append(lines, line{n: n, bad: text})
+				continue
+			}
+			lines = append(lines, line{n: n, key: strings.TrimSpace(key), value: strings.TrimSpace(value)})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	return lines, nil
+}
+
+// Keys wg-quick accepts in each section, and those each section needs.
+var (
+	interfaceKeys = []string{"PrivateKey", "Address", "DNS", "ListenPort", "MTU", "Table",
+		"FwMark", "SaveConfig", "PreUp", "PostUp", "PreDown", "PostDown"}
+	peerKeys = []string{"PublicKey", "PresharedKey", "AllowedIPs", "Endpoint", "PersistentKeepalive"}
+
+	requiredKeys = map[string][]string{
+		"interface": {"PrivateKey", "Address"},
+		"peer":      {"PublicKey", "AllowedIPs"},
+	}
+)
+
+// Validate reads a wg-quick configuration and returns every problem found:
+// syntax errors, unknown sections and keys, missing keys, malformed keys,
+// addresses and ports, and endpoints that do not resolve. lookup resolves
+// endpoint hosts; pass nil to skip resolution (e.g. when offline). Only
+// read errors are returned as an error.
+func Validate(r io.Reader, lookup func(host string) ([]string, error)) ([]Problem, error) {
+	lines, err := scanLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	add := func(line int, format string, args ...any) {
+		problems = append(problems, Problem{line, fmt.Sprintf(format, args...)})
+	}
+
+	var (
+		interfaces, peers int
+		section           string // lower-cased name of the current section
+		header            line
+		seen              map[string]bool
+	)
+	closeSection := func() {
+		for _, key := range requiredKeys[section] {
+			if !seen[strings.ToLower(key)] {
+				add(header.n, "[%s] is missing %s", header.section, key)
+			}
+		}
+	}
+	for _, l := range lines {
+		switch {
+		case l.bad != "":
+			add(l.n, "expected \"Key = Value\", got %q", l.bad)
+		case l.header:
+			closeSection()
+			section, header, seen = strings.ToLower(l.section), l, map[string]bool{}
+			switch section {
+			case "interface":
+				if interfaces++; interfaces > 1 {
+					add(l.n, "duplicate [Interface] section")
+				}
+			case "peer":
+				peers++
+			default:
+				add(l.n, "unknown section [%s]", l.section)
+			}
+		case seen == nil:
+			add(l.n, "entry outside of any section")
+		default:
+			seen[strings.ToLower(l.key)] = true
+			switch section {
+			case "interface":
+				validateInterfaceEntry(l, add)
+			case "peer":
+				validatePeerEntry(l, lookup, add)
+			}
+		}
+	}
+	closeSection()
+
+	if interfaces == 0 {
+		add(0, "missing [Interface] section")
+	}
+	if peers == 0 {
+		add(0, "missing [Peer] section")
+	}
+	return problems, nil
+}
+
+func validateInterfaceEntry(l line, add func(int, string, ...any)) {
+	switch strings.ToLower(l.key) {
+	case "privatekey":
+		if err := ValidateKey(l.value); err != nil {
+			add(l.n, "PrivateKey: %v", err)
+		}
+	case "address":
+		for _, a := range splitList(l.value) {
+			if err := validateAddress(a); err != nil {
+				add(l.n, "Address %q: %v", a, err)
+			}
+		}
+	case "listenport":
+		if err := validatePort(l.value); err != nil {
+			add(l.n, "ListenPort: %v", err)
+		}
+	case "mtu":
+		if n, err := strconv.Atoi(l.value); err != nil || n < 576 || n > 65535 {
+			add(l.n, "MTU must be a number between 576 and 65535, got %q", l.value)
+		}
+	default:
+		checkKnownKey(l, "Interface", interfaceKeys, add)
+	}
+}
+
+func validatePeerEntry(l line, lookup func(string) ([]string, error), add func(int, string, ...any)) {
+	switch strings.ToLower(l.key) {
+	case "publickey", "presharedkey":
+		if err := ValidateKey(l.value); err != nil {
+			add(l.n, "%s: %v", l.key, err)
+		}
+	case "allowedips":
+		for _, a := range splitList(l.value) {
+			if err := validateAddress(a); err != nil {
+				add(l.n, "AllowedIPs %q: %v", a, err)
+			}
+		}
+	case "endpoint":
+		if err := validateEndpoint(l.value, lookup); err != nil {
+			add(l.n, "Endpoint %q: %v", l.value, err)
+		}
+	case "persistentkeepalive":
+		if l.value == "off" {
+			return
+		}
+		if n, err := strconv.Atoi(l.value); err != nil || n < 0 || n > 65535 {
+			add(l.n, "PersistentKeepalive must be \"off\" or 0-65535, got %q", l.value)
+		}
+	default:
+		checkKnownKey(l, "Peer", peerKeys, add)
+	}
+}
+
+// ValidateKey checks that s is a base64-encoded 32-byte WireGuard key.
+func ValidateKey(s string) error {
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("not valid base64")
+	}
+	if len(raw) != 32 {
+		return fmt.Errorf("decodes to %d bytes, want 32", len(raw))
+	}
+	return nil
+}
+
+func checkKnownKey(l line, section string, known []string, add func(int, string, ...any)) {
+	for _, k := range known {
+		if strings.EqualFold(l.key, k) {
+			return
+		}
+	}
+	add(l.n, "unknown key %q in [%s]", l.key, section)
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// validateAddress accepts an IP prefix or a bare IP, as wg-quick does.
+func validateAddress(a string) error {
+	if strings.Contains(a, "/") {
+		if _, err := netip.ParsePrefix(a); err != nil {
+			return fmt.Errorf("not a valid CIDR")
+		}
+		return nil
+	}
+	if _, err := netip.ParseAddr(a); err != nil {
+		return fmt.Errorf("not a valid IP address")
+	}
+	return nil
+}
+
+func validatePort(p string) error {
+	n, err := strconv.Atoi(p)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("port must be 1-65535, got %q", p)
+	}
+	return nil
+}
+
+func validateEndpoint(endpoint string, lookup func(string) ([]string, error)) error {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return fmt.Errorf("expected host:port")
+	}
+	if err := validatePort(port); err != nil {
+		return err
+	}
+	if host == "" {
+		return fmt.Errorf("missing host")
+	}
+	if _, err := netip.ParseAddr(host); err == nil || lookup == nil {
+		return nil
+	}
+	if _, err := lookup(host); err != nil {
+		return fmt.Errorf("cannot resolve host: %v", err)
+	}
+	return nil
+}
+
+// redactedKeys are hidden by Redact.
+var redactedKeys = []string{"PrivateKey", "PresharedKey"}
+
+// Redact reads a wg-quick configuration and renders it with aligned values,
+// comments dropped and private and preshared keys replaced by "(hidden)".
+// Malformed lines are kept as they are.
+func Redact(r io.Reader) (string, error) {
+	lines, err := scanLines(r)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	width := keyWidth(lines)
+	for i, l := range lines {
+		switch {
+		case l.bad != "":
+			fmt.Fprintf(&b, "%s\n", l.bad)
+		case l.header:
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "[%s]\n", l.section)
+			width = keyWidth(lines[i+1:])
+		default:
+			value := l.value
+			if isRedacted(l.key) {
+				value = "(hidden)"
+			}
+			fmt.Fprintf(&b, "%-*s = %s\n", width, l.key, value)
+		}
+	}
+	return b.String(), nil
+}
+
+// keyWidth is the length of the longest key before the next section header.
+func keyWidth(lines []line) int {
+	width := 0
+	for _, l := range lines {
+		if l.header {
+			break
+		}
+		width = max(width, len(l.key))
+	}
+	return width
+}
+
+func isRedacted(key string) bool {
+	for _, k := range redactedKeys {
+		if strings.EqualFold(key, k) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package wgconf
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateAcceptsGeneratedConfig(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	server, _ := GenerateKeyPair()
+	cfg := &Config{
+		PrivateKey:      kp.PrivateKey,
+		ClientAddress:   "10.8.0.2/24",
+		DNS:             "1.1.1.1",
+		ServerPublicKey: server.PublicKey,
+		ServerEndpoint:  "203.0.113.10:51820",
+		AllowedIPs:      "0.0.0.0/0, ::/0",
+	}
+	path := filepath.Join(t.TempDir(), "wg.conf")
+	if err := cfg.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	problems, err := Validate(f, nil)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Fatalf("expected generated config to validate, got %v", problems)
+	}
+}
+
+func TestValidateReportsProblems(t *testing.T) {
+	server, _ := GenerateKeyPair()
+	conf := `# edited by hand
+[Interface]
+PrivateKey = not-a-key
+Address = 10.8.0.300/24
+Bogus = 1
+
+[Peer]
+PublicKey = ` + server.PublicKey + `
+AllowedIPs = 0.0.0.0/0, nonsense
+Endpoint = vpn.invalid:99999
+this line is broken
+`
+	problems, err := Validate(strings.NewReader(conf), nil)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	want := map[int]string{
+		3:  "PrivateKey",
+		4:  "Address",
+		5:  "unknown key",
+		9:  "nonsense",
+		10: "port",
+		11: "expected",
+	}
+	got := map[int]string{}
+	for _, p := range problems {
+		got[p.Line] = p.Message
+	}
+	for line, substr := range want {
+		if !strings.Contains(got[line], substr) {
+			t.Errorf("line %d: expected problem mentioning %q, got %q", line, substr, got[line])
+		}
+	}
+	if len(problems) != len(want) {
+		t.Errorf("expected %d problems, got %d: %v", len(want), len(problems), problems)
+	}
+}
+
+func TestValidateResolvesEndpoint(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	conf := "[Interface]\nPrivateKey = " + kp.PrivateKey + "\nAddress = 10.8.0.2\n\n" +
+		"[Peer]\nPublicKey = " + kp.PublicKey + "\nAllowedIPs = 0.0.0.0/0\nEndpoint = vpn.example.com:51820\n"
+
+	failing := func(string) ([]string, error) { return nil, errors.New("no such host") }
+	problems, _ := Validate(strings.NewReader(conf), failing)
+	if len(problems) != 1 || problems[0].Line != 8 || !strings.Contains(problems[0].Message, "resolve") {
+		t.Fatalf("expected one resolution problem on line 8, got %v", problems)
+	}
+
+	ok := func(string) ([]string, error) { return []string{"203.0.113.10"}, nil }
+	if problems, _ := Validate(strings.NewReader(conf), ok); len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+}
+
+func TestValidateSectionProblems(t *testing.T) {
+	problems, _ := Validate(strings.NewReader(""), nil)
+	if len(problems) != 2 {
+		t.Fatalf("expected missing [Interface] and [Peer], got %v", problems)
+	}
+
+	kp, _ := GenerateKeyPair()
+	conf := "PrivateKey = " + kp.PrivateKey + "\n[Interface]\nAddress = 10.8.0.2\n[Bogus]\n[Peer]\nPublicKey = " + kp.PublicKey + "\n"
+	problems, _ = Validate(strings.NewReader(conf), nil)
+	want := []string{"outside of any section", "missing PrivateKey", "unknown section", "missing AllowedIPs"}
+	if len(problems) != len(want) {
+		t.Fatalf("expected %d problems, got %v", len(want), problems)
+	}
+	for i, substr := range want {
+		if !strings.Contains(problems[i].Message, substr) {
+			t.Errorf("problem %d = %q, want it to mention %q", i, problems[i].Message, substr)
+		}
+	}
+}
+
+func TestRedactHidesSecrets(t *testing.T) {
+	kp, _ := GenerateKeyPair()
+	psk, _ := GenerateKeyPair()
+	conf := "[Interface]\nPrivateKey = " + kp.PrivateKey + "\nAddress = 10.8.0.2/24 # client\n\n" +
+		"[Peer]\nPublicKey = " + kp.PublicKey + "\nPresharedKey = " + psk.PrivateKey + "\nAllowedIPs = 0.0.0.0/0\n"
+
+	out, err := Redact(strings.NewReader(conf))
+	if err != nil {
+		t.Fatalf("Redact: %v", err)
+	}
+	if strings.Contains(out, kp.PrivateKey) || strings.Contains(out, psk.PrivateKey) {
+		t.Fatalf("redacted output leaks a secret:\n%s", out)
+	}
+	if !strings.Contains(out, "PresharedKey = (hidden)") {
+		t.Errorf("expected redacted PresharedKey, got:\n%s", out)
+	}
+	if !strings.Contains(out, "Address    = 10.8.0.2/24\n") {
+		t.Errorf("expected aligned Address without its comment, got:\n%s", out)
+	}
+	if !strings.Contains(out, kp.PublicKey) {
+		t.Errorf("expected public key to be shown, got:\n%s", out)
+	}
+}
@@ -45,6 +45,12 @@ contract SessionManager is Ownable2Step, ReentrancyGuard {
     /// @notice User → their current active session ID (0 = no active session).
     mapping(address => uint256) public activeSession;
 
+    /// @dev Node operator → IDs of its active sessions, in no particular order.
+    mapping(address => uint256[]) private _nodeActiveSessions;
+
+    /// @dev Session ID → its index in _nodeActiveSessions[node], plus one (0 = not listed).
+    mapping(uint256 => uint256) private _nodeActiveIndex;
+
     /// @notice Operator revenue share (basis points, e.g., 8000 = 80%).
     uint256 public operatorShareBps;
 
@@ -162,6 +168,7 @@ contract SessionManager is Ownable2Step, ReentrancyGuard {
         });
 
         activeSession[msg.sender] = sessionId;
+        _addNodeSession(node, sessionId);
         totalSessions++;
         totalRevenue += required;
 
@@ -199,6 +206,7 @@ contract SessionManager is Ownable2Step, ReentrancyGuard {
         });
 
         activeSession[user] = sessionId;
+        _addNodeSession(node, sessionId);
         totalSessions++;
 
         emit SessionOpened(sessionId, user, node, 0, duration);
@@ -219,6 +227,7 @@ contract SessionManager is Ownable2Step, ReentrancyGuard {
 
         s.active = false;
         activeSession[s.user] = 0;
+        _removeNodeSession(s.node, sessionId);
 
         // Distribute payment if any
         if (s.payment > 0 && !s.settled) {
@@ -317,6 +326,13 @@ contract SessionManager is Ownable2Step, ReentrancyGuard {
         return activeSession[user];
     }
 
+    /// @notice Get the IDs of a node operator's active sessions, in no particular
+    ///         order. Lets an operator find sessions left open by clients or
+    ///         gateways that went away and close them.
+    function getActiveNodeSessions(address node) external view returns (uint256[] memory) {
+        return _nodeActiveSessions[node];
+    }
+
     /// @notice Check if a session has expired based on its duration.
     function isExpired(uint256 sessionId) external view returns (bool) {
         Session storage s = sessions[sessionId];
@@ -371,6 +387,23 @@ contract SessionManager is Ownable2Step, ReentrancyGuard {
         nodeRegistry = _nodeRegistry;
     }
 
+    function _addNodeSession(address node, uint256 sessionId) internal {
+        _nodeActiveSessions[node].push(sessionId);
+        _nodeActiveIndex[sessionId] = _nodeActiveSessions[node].length;
+    }
+
+    /// @dev Swap-and-pop, so closing costs the same however many sessions a node has.
+    function _removeNodeSession(address node, uint256 sessionId) internal {
+        uint256 index = _nodeActiveIndex[sessionId];
+        if (index == 0) return;
+        uint256[] storage ids = _nodeActiveSessions[node];
+        uint256 last = ids[ids.length - 1];
+        ids[index - 1] = last;
+        _nodeActiveIndex[last] = index;
+        ids.pop();
+        delete _nodeActiveIndex[sessionId];
+    }
+
     function _validateNode(address node) internal view {
         if (nodeRegistry == address(0)) revert NodeRegistryNotConfigured();
         if (!INodeRegistry(nodeRegistry).isRegistered(node)) revert NodeNotRegistered();
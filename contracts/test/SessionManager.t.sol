@@ -299,6 +299,42 @@ contract SessionManagerTest is Test {
         assertEq(sm.activeSession(user1), 2);
     }
 
+    // =========================================================================
+    //                          NODE SESSIONS
+    // =========================================================================
+
+    function test_GetActiveNodeSessions() public {
+        vm.prank(user1);
+        uint256 s1 = sm.openSession{value: 0.001 ether}(nodeOp, 3600);
+        uint256 s2 = sm.openFreeSession(user2, nodeOp, 3600);
+        uint256 s3 = sm.openFreeSession(address(0x3), nodeOp2, 3600);
+
+        uint256[] memory ids = sm.getActiveNodeSessions(nodeOp);
+        assertEq(ids.length, 2);
+        assertEq(ids[0], s1);
+        assertEq(ids[1], s2);
+        assertEq(sm.getActiveNodeSessions(nodeOp2).length, 1);
+        assertEq(sm.getActiveNodeSessions(nodeOp2)[0], s3);
+
+        // Closing the first moves the last into its place
+        vm.prank(nodeOp);
+        sm.closeSession(s1);
+        ids = sm.getActiveNodeSessions(nodeOp);
+        assertEq(ids.length, 1);
+        assertEq(ids[0], s2);
+
+        vm.prank(user2);
+        sm.closeSession(s2);
+        assertEq(sm.getActiveNodeSessions(nodeOp).length, 0);
+
+        // A reopened session is listed again
+        vm.prank(user1);
+        uint256 s4 = sm.openSession{value: 0.001 ether}(nodeOp, 3600);
+        ids = sm.getActiveNodeSessions(nodeOp);
+        assertEq(ids.length, 1);
+        assertEq(ids[0], s4);
+    }
+
     // =========================================================================
     //                          EXPIRY
     // =========================================================================
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ethrpc"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/noderegistry"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/sessionmgr"
+)
+
+// Made-up contract addresses the dev chain answers calls to.
+var (
+	devRegistryAddr = common.HexToAddress("0x00000000000000000000000000000000000de001")
+	devSessionsAddr = common.HexToAddress("0x00000000000000000000000000000000000de002")
+)
+
+// devPricePerHour is what the stub SessionManager quotes (1 gwei per hour).
+var devPricePerHour = big.NewInt(1_000_000_000)
+
+// devChain stands in for the chain in --dev-mode: a NodeRegistry listing
+// only this node and a SessionManager on an in-memory ethrpc.Fake, signed
+// for by a throwaway operator key.
+type devChain struct {
+	rpc      *ethrpc.Fake
+	operator common.Address
+	registry *noderegistry.Registry
+	sessions *sessionmgr.Manager
+}
+
+// newDevChain lists this node at endpoint with wgPubKey in region.
+func newDevChain(endpoint, wgPubKey, region string, chainID int64, cacheTTL time.Duration) (*devChain, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, fmt.Errorf("generating dev operator key: %w", err)
+	}
+	operator := crypto.PubkeyToAddress(key.PublicKey)
+
+	rpc := ethrpc.NewFake()
+	rpc.HandleCalls(devRegistryAddr, noderegistry.StubCalls([]noderegistry.Node{{
+		Operator:      operator,
+		Endpoint:      endpoint,
+		WgPubKey:      wgPubKey,
+		Region:        region,
+		RegisteredAt:  time.Now(),
+		LastHeartbeat: time.Now(),
+		Active:        true,
+	}}))
+	rpc.HandleCalls(devSessionsAddr, sessionmgr.StubCalls(operator, devPricePerHour, 24*time.Hour))
+
+	registry, err := noderegistry.NewRegistryWithClient(rpc, devRegistryAddr.Hex(), cacheTTL)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := sessionmgr.NewWithClient(rpc, devSessionsAddr.Hex(), hex.EncodeToString(crypto.FromECDSA(key)), chainID)
+	if err != nil {
+		return nil, err
+	}
+	return &devChain{rpc: rpc, operator: operator, registry: registry, sessions: sessions}, nil
+}
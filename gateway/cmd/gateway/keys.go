@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/config"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/keyfile"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/noderegistry"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/sessionmgr"
+)
+
+// operatorKeyFile is a signing key loaded from disk, re-read on SIGHUP.
+type operatorKeyFile struct {
+	name     string // "session" or "heartbeat", for messages
+	path     string
+	passFile string
+	passEnv  string
+}
+
+// load reads the key, taking the keystore passphrase from passFile or,
+// failing that, the environment.
+func (k operatorKeyFile) load() (*ecdsa.PrivateKey, error) {
+	if keyfile.Exposed(k.path) {
+		log.Printf("Warning: %s key file %s is readable by other users; chmod 600 it", k.name, k.path)
+	}
+	password, err := keyfile.ReadPassword(k.passFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s key password: %w", k.name, err)
+	}
+	if password == "" {
+		password = config.SecretFromEnv(os.LookupEnv, k.passEnv)
+	}
+	key, err := keyfile.Load(k.path, password)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s key: %w", k.name, err)
+	}
+	return key, nil
+}
+
+func keyHex(key *ecdsa.PrivateKey) string {
+	return hex.EncodeToString(crypto.FromECDSA(key))
+}
+
+// keyRotation swaps re-read key files into the running signers, so an
+// operator can rotate keys with SIGHUP instead of a restart.
+type keyRotation struct {
+	session   *operatorKeyFile // nil if the session key did not come from a file
+	heartbeat *operatorKeyFile // nil if the heartbeat key did not come from a file
+
+	hb *noderegistry.HeartbeatSender
+	sm *sessionmgr.Manager
+	// smSignsWithHeartbeatKey is set when the session manager has no key of
+	// its own and signs with the heartbeat key; otherwise the heartbeat key
+	// only determines its node operator address.
+	smSignsWithHeartbeatKey bool
+}
+
+// reload re-reads the key files. A key that fails to load is left as is.
+func (r *keyRotation) reload() {
+	if r.session == nil && r.heartbeat == nil {
+		log.Printf("SIGHUP: no key files configured, nothing to rotate")
+		return
+	}
+
+	if r.heartbeat != nil && (r.hb != nil || r.sm != nil) {
+		key, err := r.heartbeat.load()
+		if err != nil {
+			log.Printf("SIGHUP: %v; keeping current heartbeat key", err)
+		} else {
+			addr := crypto.PubkeyToAddress(key.PublicKey)
+			if r.hb != nil {
+				r.hb.SetKey(key)
+			}
+			if r.sm != nil {
+				if r.smSignsWithHeartbeatKey {
+					r.sm.SetKey(key)
+				} else {
+					r.sm.SetNodeOperator(addr)
+				}
+			}
+			log.Printf("SIGHUP: heartbeat key rotated, operator is now %s", addr.Hex())
+		}
+	}
+
+	if r.session != nil && r.sm != nil {
+		key, err := r.session.load()
+		if err != nil {
+			log.Printf("SIGHUP: %v; keeping current session key", err)
+		} else {
+			r.sm.SetKey(key)
+			log.Printf("SIGHUP: session key rotated, signer is now %s", crypto.PubkeyToAddress(key.PublicKey).Hex())
+		}
+	}
+}
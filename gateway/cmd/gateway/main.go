@@ -2,54 +2,140 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"google.golang.org/grpc"
 
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/audit"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/buildinfo"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/config"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/connlog"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/delegation"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/feedback"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/gaslimit"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/nftcheck"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/noderegistry"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbox"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/payoutvault"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/preflight"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/rep6529"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/revocation"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/server"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/sessionmgr"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/subscriptionmgr"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/txtracker"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/usage"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/webhook"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/wireguard"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/zkverify"
 )
 
+// Set at link time: -ldflags "-X main.version=... -X main.commit=... -X main.buildTime=..."
+var (
+	version   string
+	commit    string
+	buildTime string
+)
+
 func main() {
+	build := buildinfo.Resolve(version, commit, buildTime)
+
 	configPath := flag.String("config", "", "Path to config JSON file")
+	showVersion := flag.Bool("version", false, "Print the build version and exit")
+	validate := flag.Bool("validate", false, "Validate the config, RPC chain ID and contract bytecode, print a report and exit")
+	devMode := flag.Bool("dev-mode", false, "Run against in-memory stubs of the chain and WireGuard so the API works with no external dependencies (local development only, never production)")
+	devWallets := flag.String("dev-wallets", "", "In --dev-mode, the only wallets granted access, as 0xADDR[=free|paid],... (default: every wallet gets the paid tier)")
 	listenAddr := flag.String("listen", ":8080", "Listen address")
+	var tlsOpts tlsOptions
+	flag.StringVar(&tlsOpts.certFile, "tls-cert", "", "TLS certificate file (PEM); serve HTTPS instead of HTTP, reloaded on SIGHUP")
+	flag.StringVar(&tlsOpts.keyFile, "tls-key", "", "TLS private key file (PEM) for --tls-cert")
+	flag.StringVar(&tlsOpts.acmeDomains, "acme-domain", "", "Comma-separated domains to get Let's Encrypt certificates for (serve HTTPS via ACME)")
+	flag.StringVar(&tlsOpts.acmeCacheDir, "acme-cache-dir", "acme-cache", "Directory for ACME account keys and certificates")
+	flag.StringVar(&tlsOpts.acmeEmail, "acme-email", "", "Contact email for the ACME account (optional)")
+	flag.StringVar(&tlsOpts.acmeHTTPListen, "acme-http-listen", "", "Address answering ACME HTTP-01 challenges and redirecting to HTTPS, e.g. :80 (default: TLS-ALPN-01 only, which needs --listen :443)")
+	grpcListen := flag.String("grpc-listen", "", "gRPC listen address, e.g. :9090 (default: gRPC API disabled)")
 	ethRPC := flag.String("eth-rpc", "", "Ethereum RPC endpoint")
 	ethWS := flag.String("eth-ws", "", "Ethereum WebSocket endpoint for event monitoring")
+	rpcMaxConcurrency := flag.Int("rpc-max-concurrency", -1, "Most Ethereum RPC requests in flight at once across the gateway; more queue (0 = unlimited, default from config: 32)")
+	outboundProxy := flag.String("outbound-proxy", "", "Proxy for outbound RPC and API calls: http://host:port or socks5://host:port (or SVPN_OUTBOUND_PROXY env)")
+	revocationMode := flag.String("revocation-mode", "auto", "Transfer watcher mode: auto, ws, poll, or off (auto uses ws when --eth-ws is ws(s)://, else polls)")
+	revocationPollInterval := flag.Duration("revocation-poll-interval", revocation.DefaultPollInterval, "Block polling interval when revocation uses HTTP polling")
+	policyWatchInterval := flag.Duration("policy-watch-interval", 0, "Poll the AccessPolicy contract this often for token ID changes and flush the access cache when one lands (0 = off; AccessPolicy mode only)")
+	revocationConfirmations := flag.Uint64("revocation-confirmations", 0, "Blocks a transfer must be buried under before its sender is revoked, so reorged-out transfers revoke nobody (0 = act immediately)")
 	policyContract := flag.String("policy-contract", "", "AccessPolicy contract address")
 	memesContract := flag.String("memes-contract", "", "Memes ERC-1155 contract address")
 	chainID := flag.Int("chain-id", 1, "Ethereum chain ID (1=mainnet, 11155111=sepolia)")
 	siweDomain := flag.String("siwe-domain", "", "SIWE domain (default: 6529vpn.io)")
+	siweStatement := flag.String("siwe-statement", "", "Statement shown in the wallet signing prompt (single line; {node} and {region} are filled in)")
+	termsURL := flag.String("terms-url", "", "Terms of service every sign-in accepts; the SIWE statement names them")
+	termsSHA256 := flag.String("terms-sha256", "", "Hex SHA-256 of the terms document, pinning the version accepted")
+	checkBlock := flag.String("check-block", "latest", "Block NFT checks read at: latest, a block number (snapshot), or head-N (N blocks behind head, rides out shallow reorgs)")
 
 	// Direct mode (mainnet) — check Memes ERC-1155 directly without AccessPolicy
 	directMode := flag.Bool("direct-mode", false, "Check Memes ERC-1155 directly (no AccessPolicy contract needed)")
+	directFallback := flag.Bool("direct-fallback", false, "In AccessPolicy mode, check Memes ERC-1155 balances directly when the AccessPolicy call reverts or fails (needs --memes-contract)")
 	thisCardID := flag.Int64("this-card-id", 0, "Token ID for THIS card (free tier). 0 = no free tier")
 	enableFreeTier := flag.Bool("enable-free-tier", false, "Allow THIS-card holders to bypass payment")
-	maxTokenID := flag.Int64("max-token-id", 350, "Highest Memes token ID to check")
+	gracePeriod := flag.Duration("grace-period", 0, "Give a wallet denied this soon after the transfer watcher saw it receive a card one short paid-tier probationary session (default from config: off)")
+	graceSessionTTL := flag.Duration("grace-session-ttl", 0, "Length of a --grace-period probationary session (default from config: 10m)")
+	minConnectTTL := flag.Duration("min-connect-ttl", 0, "Reject /vpn/connect with a re-authenticate error when the session has less than this left; 0 turns the check off (default from config: a tenth of the session's lifetime)")
+	maxTokenID := flag.Int64("max-token-id", 350, "Highest Memes token ID to check; a floor when --max-token-id-refresh is on")
+	maxTokenIDRefresh := flag.Duration("max-token-id-refresh", 0, "How often to read the highest minted Memes token ID on-chain (totalSupply) and raise --max-token-id to it, e.g. 6h (0 = off)")
+	tokenIDs := flag.String("token-ids", "", "Direct mode: comma-separated Memes token IDs and ranges to check, e.g. 1-50,77,90-120, instead of 1..--max-token-id (turns off --max-token-id-refresh)")
+	balanceSource := flag.String("balance-source", nftcheck.BalanceSourceRPC, "Direct mode card lookup: rpc (balanceOfBatch), multicall (Multicall3), or nft-api")
+	nftAPIURL := flag.String("nft-api-url", "", "Alchemy-compatible NFT API base URL incl. key, e.g. https://eth-mainnet.g.alchemy.com/nft/v3/<key> (or SVPN_NFT_API_URL env)")
+	nftCacheWarmWindow := flag.Duration("nft-cache-warm-window", 0, "Re-check wallets seen within this window shortly before their cached access result expires, so returning users sign in on a cache hit; costs one check per warm wallet per cache TTL (0 = off)")
+	nftCacheWarmWorkers := flag.Int("nft-cache-warm-workers", 4, "Concurrent refreshes for --nft-cache-warm-window")
 
 	// WireGuard flags
 	wgInterface := flag.String("wg-interface", "wg0", "WireGuard interface name")
 	wgPubKey := flag.String("wg-pubkey", "", "Server WireGuard public key")
-	wgEndpoint := flag.String("wg-endpoint", "", "Server public endpoint (e.g. vpn.example.com:51820)")
+	wgEndpoint := flag.String("wg-endpoint", "", "Server public endpoint(s), comma-separated, preferred first; list both address families on a dual-stack node (e.g. 203.0.113.10:51820,[2001:db8::1]:51820)")
 	wgSubnet := flag.String("wg-subnet", "10.8.0.0/24", "Client IP subnet")
 	wgDNS := flag.String("wg-dns", "1.1.1.1", "DNS server for clients")
+	wgDNSPolicy := flag.String("wg-dns-policy", "", "Comma-separated DNS assertions advertised to clients: local-resolver, no-logs, doh, or none (empty = advertise nothing)")
+	wgKeepalive := flag.Int("wg-keepalive", 25, "PersistentKeepalive (seconds) suggested to clients; lower it behind strict NAT (0 = leave to the client)")
+	wgBackend := flag.String("wg-backend", "", "WireGuard backend: wg (shell out), netlink (requires a build with -tags wgctrl), memory (no interface, for development) or none (discovery-only: list nodes and sign in, provision no tunnels) (or SVPN_WG_BACKEND env; default: config wg_backend, else wg)")
+	wgExtraInterfaces := flag.String("wg-extra-interfaces", "", "Further WireGuard interfaces to spread peers over, each with its own subnet and listen port: ';'-separated name,subnet,pubkey,endpoint[,endpoint...] (empty pubkey = --wg-pubkey), e.g. wg1,10.9.0.0/24,,203.0.113.10:51821")
+	wgPlacement := flag.String("wg-placement", wireguard.PlacementLeastLoaded, "How new peers are spread over interfaces: least_loaded or round_robin")
+	onPeerAdd := flag.String("on-peer-add", "", "Script run after a peer is added, with its public key, client IP and tier as arguments (also in SVPN_PEER_* env)")
+	onPeerRemove := flag.String("on-peer-remove", "", "Script run after a peer is removed, with the same arguments as --on-peer-add")
+	peerHookTimeout := flag.Duration("peer-hook-timeout", wireguard.DefaultHookTimeout, "How long a peer hook script may run before it is killed")
+	wgSkipCheck := flag.Bool("wg-skip-check", false, "Skip the startup WireGuard interface check (test/dev mode)")
+	maxBodyBytes := flag.Int64("max-body-bytes", 0, "Largest accepted request body in bytes (default from config: 65536)")
+	maxSessions := flag.Int("max-sessions", -1, "Max sessions held in memory, least recently used without a tunnel evicted first; 0 = unlimited (default from config: 100000)")
+	maxCacheEntries := flag.Int("max-cache-entries", -1, "Max entries per NFT/delegation/rep lookup cache; 0 = unlimited (default from config: 100000)")
+	maxPeersFree := flag.Int("max-peers-free", -1, "Max concurrent devices per free-tier wallet, 0 = unlimited (default from config: 1)")
+	maxPeersPaid := flag.Int("max-peers-paid", -1, "Max concurrent devices per paid-tier wallet, 0 = unlimited (default from config: 5)")
+	peerLimitPolicy := flag.String("peer-limit-policy", "", "When a wallet exceeds its device limit: evict_oldest (default) or reject")
+
+	// Data quota flags
+	quotaFreeGB := flag.Float64("quota-free-gb", -1, "Data quota per free-tier wallet per period in GB, 0 = unlimited (default from config: unlimited)")
+	quotaPaidGB := flag.Float64("quota-paid-gb", -1, "Data quota per paid-tier wallet per period in GB, 0 = unlimited (default from config: unlimited)")
+	quotaPeriod := flag.Duration("quota-period", 0, "Data quota accounting period (default from config: 720h)")
+	quotaAction := flag.String("quota-action", "", "When a wallet exceeds its data quota: disconnect (the only action)")
+	feedbackWindow := flag.Duration("feedback-window", feedback.DefaultWindow, "How long client connection-quality reports (POST /vpn/feedback) count towards the score in GET /version (0 = don't accept feedback)")
+	usageDB := flag.String("usage-db", "", "Path to a bbolt file for per-wallet daily usage history (enables GET /vpn/usage)")
+	privateLogs := flag.Bool("private-logs", false, "Leave per-connection lines (sign-ins, connects, disconnects, peer changes) out of the log; the audit log is unaffected (or SVPN_PRIVATE_LOGS env)")
+	auditLogPath := flag.String("audit-log", "", "Append a JSON-lines audit record of every wallet access decision (sign-in, connect, revocation) to this file, reopened on SIGHUP for logrotate, or \"syslog\"")
+
+	// Region access flags
+	region := flag.String("region", "", "Region this gateway serves (e.g. ch-zurich)")
+	nodeName := flag.String("node-name", "", "Name of this node for {node} in the SIWE statement (default: the SIWE domain)")
+	regionTiers := flag.String("region-tiers", "", "Comma-separated region=tier requirements, e.g. ch-zurich=free,us-east=paid")
 
 	// Delegation flags
 	enableDelegation := flag.Bool("delegation", false, "Enable delegation registry lookups")
@@ -59,11 +145,17 @@ func main() {
 	// Node registry flags
 	nodeRegistryContract := flag.String("node-registry", "", "NodeRegistry contract address")
 	nodeRegistryCacheTTL := flag.Duration("node-cache-ttl", 2*time.Minute, "Node registry cache TTL")
+	nodeVersionTTL := flag.Duration("node-version-ttl", 10*time.Minute, "How often to re-fetch each listed node's GET /version for gateway_version in /nodes (0 = don't probe nodes)")
+	geoIPURL := flag.String("geoip-url", "", "IP geolocation API URL with {ip} for the address, e.g. https://ipapi.co/{ip}/json/; sets region_verified in /nodes (empty = don't geolocate nodes)")
+	geoIPTTL := flag.Duration("geoip-ttl", 24*time.Hour, "How often to re-geolocate each listed node")
+	networkStatsInterval := flag.Duration("network-stats-interval", 5*time.Minute, "How often to poll active nodes' GET /health for GET /network/stats (0 = disable the endpoint)")
 
-	// 6529 Rep flags (kept for backwards compatibility; node filtering now uses on-chain card check)
-	_ = flag.Int64("rep-min", rep6529.DefaultMinRep, "Minimum 6529 rep to operate a node")
-	_ = flag.String("rep-category", rep6529.DefaultCategory, "6529 rep category name")
-	_ = flag.String("rep-api-url", rep6529.DefaultBaseURL, "6529 rep API base URL")
+	// 6529 Rep flags (node filtering uses the on-chain card check; these back GET /operator/{addr}/rep)
+	repMin := flag.Int64("rep-min", rep6529.DefaultMinRep, "Minimum 6529 rep to operate a node")
+	repMinRaters := flag.Int64("rep-min-raters", 0, "Minimum distinct raters backing an operator's rep (0 = no minimum)")
+	repMinBackingTDH := flag.Int64("rep-min-backing-tdh", 0, "Minimum combined TDH of an operator's raters (0 = no minimum)")
+	repCategory := flag.String("rep-category", rep6529.DefaultCategory, "6529 rep category name")
+	repAPIURL := flag.String("rep-api-url", rep6529.DefaultBaseURL, "6529 rep API base URL (empty to disable operator rep endpoint)")
 	repCacheTTL := flag.Duration("rep-cache-ttl", 5*time.Minute, "6529 rep cache TTL")
 
 	// User ban check flags
@@ -72,38 +164,109 @@ func main() {
 
 	// CORS flag
 	corsOrigin := flag.String("cors-origin", "", "Allowed CORS origin (e.g. https://6529vpn.io)")
+	noSecurityHeaders := flag.Bool("no-security-headers", false, "Do not send nosniff, Referrer-Policy, X-Frame-Options and CSP headers")
+	hstsMaxAge := flag.Duration("hsts-max-age", 0, "Send Strict-Transport-Security with this max-age, e.g. 8760h; only for HTTPS gateways (default from config: off)")
 
 	// Heartbeat flags (for node operators running a gateway)
-	heartbeatKey := flag.String("heartbeat-key", "", "Private key hex for sending heartbeat txs (node operator mode)")
+	heartbeatKey := flag.String("heartbeat-key", "", "Private key hex for sending heartbeat txs (node operator mode); prefer SVPN_HEARTBEAT_KEY env or --heartbeat-key-file")
+	heartbeatKeyFile := flag.String("heartbeat-key-file", "", "File holding the heartbeat key: hex, or go-ethereum keystore JSON")
+	heartbeatKeyPassFile := flag.String("heartbeat-key-password-file", "", "Keystore passphrase file for --heartbeat-key-file (or SVPN_HEARTBEAT_KEY_PASSWORD env)")
 	heartbeatInterval := flag.Duration("heartbeat-interval", 30*time.Minute, "Heartbeat send interval")
 
+	// Admin flags
+	adminToken := flag.String("admin-token", "", "Bearer token for /admin endpoints (or SVPN_ADMIN_TOKEN env); empty disables them")
+	startDrained := flag.Bool("start-drained", false, "Start refusing new connections, with heartbeats paused, until POST /admin/undrain")
+
+	// Gas flags (heartbeat and SessionManager writes)
+	gasMultiplier := flag.Float64("gas-multiplier", gaslimit.DefaultMultiplier, "Multiplier applied to eth_estimateGas for on-chain writes")
+	gasCeiling := flag.Uint64("gas-ceiling", 0, "Maximum gas limit for on-chain writes (0 = no ceiling)")
+
 	// SessionManager flags
 	sessionManagerContract := flag.String("session-manager", "", "SessionManager contract address (enables on-chain session tracking)")
-	sessionKey := flag.String("session-key", "", "Private key hex for SessionManager txs (contract owner)")
+	sessionKey := flag.String("session-key", "", "Private key hex for SessionManager txs (contract owner); prefer SVPN_SESSION_KEY env or --session-key-file")
+	sessionKeyFile := flag.String("session-key-file", "", "File holding the SessionManager key: hex, or go-ethereum keystore JSON")
+	sessionKeyPassFile := flag.String("session-key-password-file", "", "Keystore passphrase file for --session-key-file (or SVPN_SESSION_KEY_PASSWORD env)")
+	sessionSweepInterval := flag.Duration("session-sweep-interval", 30*time.Minute, "How often to close this node's on-chain sessions that have no connected peer (0 = only via POST /admin/sessions/cleanup)")
+	sessionSweepGrace := flag.Duration("session-sweep-grace", server.DefaultStaleSessionGrace, "How long a free on-chain session may go without a connected peer before it is swept; paid sessions are swept only once they run out")
+	outboxDB := flag.String("outbox-db", "", "Path to a bbolt file recording SessionManager writes until mined, so writes cut short by a restart are replayed")
+	outboxRetry := flag.Duration("outbox-retry-interval", 30*time.Second, "How often unmined SessionManager writes in --outbox-db are checked and retried")
 
 	// SubscriptionManager flags
 	subManagerContract := flag.String("subscription-manager", "", "SubscriptionManager contract address (enables subscription-based access)")
+	subscriptionAccess := flag.Bool("subscription-access", false, "Grant paid tier to wallets with an active subscription even without a Memes card (requires --subscription-manager)")
+	subscriptionCacheTTL := flag.Duration("subscription-cache-ttl", subscriptionmgr.DefaultCacheTTL, "How long SubscriptionManager reads are cached; a cached read up to --subscription-stale-ttl old is served while the RPC fails (0 = no caching)")
+	subscriptionStaleTTL := flag.Duration("subscription-stale-ttl", subscriptionmgr.DefaultStaleTTL, "Oldest cached SubscriptionManager read served when the RPC fails (0 = fail instead)")
+	subscriptionWatchInterval := flag.Duration("subscription-watch-interval", revocation.DefaultPollInterval, "Poll the SubscriptionManager this often for Subscribed, Renewed and TierUpdated events and drop the affected cached reads; events are streamed instead when --eth-ws is a WebSocket endpoint and --revocation-mode allows it (0 = off)")
 
 	// PayoutVault flags
 	payoutVaultContract := flag.String("payout-vault", "", "PayoutVault contract address (enables payout status endpoint)")
 
 	// Operator enrollment storage flags
-	enrollmentDBURL := flag.String("enrollment-db-url", "", "Postgres database URL for durable operator enrollment storage")
+	enrollmentDBURL := flag.String("enrollment-db-url", "", "Postgres database URL for durable operator enrollment storage (or SVPN_ENROLLMENT_DATABASE_URL env)")
+
+	// Webhook flags
+	webhookURL := flag.String("webhook-url", "", "URL to POST session revocation/expiry events to")
+	webhookSecret := flag.String("webhook-secret", "", "HMAC secret for webhook signatures (or SVPN_WEBHOOK_SECRET env)")
 
 	// ZK verification flags
 	zkAPIURL := flag.String("zk-api-url", "", "ZK service API URL (enables ZK proof verification)")
-	zkAPIKey := flag.String("zk-api-key", "", "ZK service API key")
+	zkAPIKey := flag.String("zk-api-key", "", "ZK service API key (or SVPN_ZK_API_KEY env)")
 
 	flag.Parse()
 
-	if *sessionKey == "" {
-		*sessionKey = os.Getenv("SESSION_KEY")
+	if *showVersion {
+		fmt.Println("sovereign-gateway", build)
+		return
 	}
-	if *heartbeatKey == "" {
-		*heartbeatKey = os.Getenv("HEARTBEAT_KEY")
+
+	// Secrets: flag > SVPN_* env (or the legacy unprefixed name)
+	for _, secret := range []struct {
+		flag       *string
+		name       string
+		env        string
+		privateKey bool
+	}{
+		{sessionKey, "session-key", config.EnvSessionKey, true},
+		{heartbeatKey, "heartbeat-key", config.EnvHeartbeatKey, true},
+		{enrollmentDBURL, "enrollment-db-url", config.EnvEnrollmentDBURL, false},
+		{webhookSecret, "webhook-secret", config.EnvWebhookSecret, false},
+		{adminToken, "admin-token", config.EnvAdminToken, false},
+		{nftAPIURL, "nft-api-url", config.EnvNFTAPIURL, false},
+		{zkAPIKey, "zk-api-key", config.EnvZKAPIKey, false},
+	} {
+		if *secret.flag == "" {
+			*secret.flag = config.SecretFromEnv(os.LookupEnv, secret.env)
+		} else if secret.privateKey {
+			log.Printf("Warning: --%s on the command line is visible in process listings; set %s instead", secret.name, secret.env)
+		}
 	}
-	if *enrollmentDBURL == "" {
-		*enrollmentDBURL = os.Getenv("ENROLLMENT_DATABASE_URL")
+
+	// Key files take the place of raw hex keys
+	var rotation keyRotation
+	if *sessionKeyFile != "" {
+		rotation.session = &operatorKeyFile{"session", *sessionKeyFile, *sessionKeyPassFile, config.EnvSessionKeyPassword}
+	}
+	if *heartbeatKeyFile != "" {
+		rotation.heartbeat = &operatorKeyFile{"heartbeat", *heartbeatKeyFile, *heartbeatKeyPassFile, config.EnvHeartbeatKeyPassword}
+	}
+	for _, kf := range []struct {
+		file *operatorKeyFile
+		key  *string
+	}{
+		{rotation.session, sessionKey},
+		{rotation.heartbeat, heartbeatKey},
+	} {
+		if kf.file == nil {
+			continue
+		}
+		if *kf.key != "" {
+			log.Fatalf("--%s-key-file conflicts with a %s key set by flag or environment", kf.file.name, kf.file.name)
+		}
+		key, err := kf.file.load()
+		if err != nil {
+			log.Fatalf("Failed to load key: %v", err)
+		}
+		*kf.key = keyHex(key)
 	}
 
 	// Load config
@@ -117,14 +280,26 @@ func main() {
 	} else {
 		cfg = config.DefaultConfig()
 	}
+	if err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		log.Fatalf("Invalid environment override: %v", err)
+	}
 
 	// Override with flags
 	if *listenAddr != ":8080" || cfg.ListenAddr == "" {
 		cfg.ListenAddr = *listenAddr
 	}
+	if *grpcListen != "" {
+		cfg.GRPCListenAddr = *grpcListen
+	}
 	if *ethRPC != "" {
 		cfg.EthereumRPC = *ethRPC
 	}
+	if *outboundProxy != "" {
+		cfg.OutboundProxy = *outboundProxy
+	}
+	if *rpcMaxConcurrency >= 0 {
+		cfg.RPCMaxConcurrency = *rpcMaxConcurrency
+	}
 	if *policyContract != "" {
 		cfg.AccessPolicyContract = *policyContract
 	}
@@ -135,41 +310,251 @@ func main() {
 		cfg.SIWEDomain = *siweDomain
 		cfg.SIWEUri = "https://" + *siweDomain
 	}
+	if *siweStatement != "" {
+		cfg.SIWEStatement = *siweStatement
+	}
+	if *termsURL != "" {
+		cfg.TermsURL = *termsURL
+	}
+	if *termsSHA256 != "" {
+		cfg.TermsSHA256 = *termsSHA256
+	}
 	if *enableFreeTier {
 		cfg.EnableFreeTier = true
 	}
+	if *gracePeriod > 0 {
+		cfg.GracePeriod = *gracePeriod
+	}
+	if *graceSessionTTL > 0 {
+		cfg.GraceSessionTTL = *graceSessionTTL
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "min-connect-ttl" {
+			cfg.MinConnectTTL = minConnectTTL
+		}
+	})
+	if *noSecurityHeaders {
+		cfg.SecurityHeaders = false
+	}
+	if *hstsMaxAge > 0 {
+		cfg.HSTSMaxAge = *hstsMaxAge
+	}
+	if *maxBodyBytes > 0 {
+		cfg.MaxBodyBytes = *maxBodyBytes
+	}
+	if *maxSessions >= 0 {
+		cfg.MaxSessions = *maxSessions
+	}
+	if *maxCacheEntries >= 0 {
+		cfg.MaxCacheEntries = *maxCacheEntries
+	}
+	if *maxPeersFree >= 0 {
+		cfg.MaxPeersFree = *maxPeersFree
+	}
+	if *maxPeersPaid >= 0 {
+		cfg.MaxPeersPaid = *maxPeersPaid
+	}
+	if *peerLimitPolicy != "" {
+		cfg.PeerLimitPolicy = *peerLimitPolicy
+	}
+	if *wgBackend != "" {
+		cfg.WGBackend = *wgBackend
+	}
+	if *quotaFreeGB >= 0 {
+		cfg.QuotaFreeBytes = uint64(*quotaFreeGB * 1e9)
+	}
+	if *quotaPaidGB >= 0 {
+		cfg.QuotaPaidBytes = uint64(*quotaPaidGB * 1e9)
+	}
+	if *quotaPeriod > 0 {
+		cfg.QuotaPeriod = *quotaPeriod
+	}
+	if *quotaAction != "" {
+		if *quotaAction != config.QuotaActionDisconnect {
+			log.Fatalf("--quota-action must be %s", config.QuotaActionDisconnect)
+		}
+		cfg.QuotaAction = *quotaAction
+	}
+	if *region != "" {
+		cfg.Region = *region
+	}
+	if *nodeName != "" {
+		cfg.NodeName = *nodeName
+	}
+	if *privateLogs {
+		cfg.PrivateLogs = true
+	}
+	if *regionTiers != "" {
+		cfg.RegionTiers = make(map[string]string)
+		for _, entry := range strings.Split(*regionTiers, ",") {
+			name, tier, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			tier = strings.TrimSpace(tier)
+			if !ok || (tier != config.RegionTierFree && tier != config.RegionTierPaid) {
+				log.Fatalf("Invalid --region-tiers entry %q (want region=free or region=paid)", entry)
+			}
+			cfg.RegionTiers[strings.TrimSpace(name)] = tier
+		}
+	}
+	if cfg.PrivateLogs {
+		connlog.SetEnabled(false)
+		log.Printf("Private logs: per-connection lines are not logged")
+	}
+	// Every outbound client below is built after this, so all of them pick
+	// up the proxy and the RPC concurrency limit.
+	if err := outbound.SetProxy(cfg.OutboundProxy); err != nil {
+		log.Fatalf("Invalid outbound proxy: %v", err)
+	}
+	outbound.SetRPCConcurrency(cfg.RPCMaxConcurrency)
+	if cfg.OutboundProxy != "" {
+		proxyURL, _ := outbound.ParseProxy(cfg.OutboundProxy)
+		log.Printf("Outbound RPC and API calls go through %s", proxyURL.Redacted())
+	}
+	if cfg.RPCMaxConcurrency > 0 {
+		log.Printf("Ethereum RPC requests limited to %d in flight", cfg.RPCMaxConcurrency)
+	}
+
+	// Dev mode stubs out the chain, so contract flags have nothing to point at
+	var devTiers map[common.Address]nftcheck.AccessTier
+	if *devMode {
+		for _, f := range []struct {
+			name string
+			set  bool
+		}{
+			{"validate", *validate},
+			{"direct-mode", *directMode},
+			{"direct-fallback", *directFallback},
+			{"node-registry", *nodeRegistryContract != ""},
+			{"session-manager", *sessionManagerContract != ""},
+			{"subscription-manager", *subManagerContract != ""},
+			{"payout-vault", *payoutVaultContract != ""},
+		} {
+			if f.set {
+				log.Fatalf("--%s cannot be used with --dev-mode, which stubs out the chain", f.name)
+			}
+		}
+		var err error
+		if devTiers, err = nftcheck.ParseStaticTiers(*devWallets); err != nil {
+			log.Fatalf("Invalid --dev-wallets: %v", err)
+		}
+		cfg.EnableFreeTier = true
+		*revocationMode = "off"
+		if *repAPIURL == rep6529.DefaultBaseURL {
+			*repAPIURL = "" // only reach out to a rep API the operator named
+		}
+		log.Printf("WARNING: dev mode: access checks, contracts and WireGuard are in-memory stubs; never run this in production")
+	} else if *devWallets != "" {
+		log.Fatal("--dev-wallets requires --dev-mode")
+	}
+
+	// RPC methods the gateway's own endpoint must serve. Revocation polling
+	// probes its endpoint separately below.
+	rpcNeeds := []preflight.MethodNeed{
+		{Method: preflight.MethodChainID, Feature: "chain ID checks"},
+		{Method: preflight.MethodCall, Feature: "NFT access checks"},
+	}
+	sendsTxs := (*nodeRegistryContract != "" && *heartbeatKey != "") ||
+		(*sessionManagerContract != "" && (*sessionKey != "" || *heartbeatKey != ""))
+	if sendsTxs {
+		rpcNeeds = append(rpcNeeds, preflight.MethodNeed{Method: preflight.MethodSendRawTransaction, Feature: "heartbeats and on-chain sessions"})
+	}
+	if *policyWatchInterval > 0 {
+		if *directMode {
+			log.Fatal("--policy-watch-interval needs AccessPolicy mode; direct mode does not read the AccessPolicy contract")
+		}
+		rpcNeeds = append(rpcNeeds, preflight.MethodNeed{Method: preflight.MethodGetLogs, Feature: "AccessPolicy watching"})
+	}
+	if *subManagerContract != "" && *subscriptionCacheTTL > 0 && *subscriptionWatchInterval > 0 {
+		rpcNeeds = append(rpcNeeds, preflight.MethodNeed{Method: preflight.MethodGetLogs, Feature: "subscription event watching"})
+	}
+
+	if *validate {
+		os.Exit(runValidate(cfg, *directMode, int64(*chainID), rpcNeeds, []contractAddr{
+			{"memes_contract", cfg.MemesContract},
+			{"access_policy_contract", cfg.AccessPolicyContract},
+			{"node-registry", *nodeRegistryContract},
+			{"session-manager", *sessionManagerContract},
+			{"subscription-manager", *subManagerContract},
+			{"payout-vault", *payoutVaultContract},
+		}))
+	}
+	if *gasMultiplier < 1 {
+		log.Fatal("--gas-multiplier must be >= 1")
+	}
+	gasPolicy := gaslimit.Policy{Multiplier: *gasMultiplier, Ceiling: *gasCeiling}
+	if err := cfg.ValidateSIWEStatement(); err != nil {
+		log.Fatalf("Invalid SIWE statement: %v", err)
+	}
+	if err := cfg.ValidateTerms(); err != nil {
+		log.Fatalf("Invalid terms of service: %v", err)
+	}
 
-	// In direct mode, AccessPolicy is not required
-	if *directMode {
+	// In direct mode, AccessPolicy is not required; dev mode needs no contracts
+	switch {
+	case *devMode:
+	case *directMode:
 		if cfg.MemesContract == "" {
 			log.Fatal("--memes-contract is required")
 		}
 		if cfg.EthereumRPC == "" {
 			log.Fatal("--eth-rpc is required")
 		}
-	} else {
+	default:
 		if err := cfg.Validate(); err != nil {
 			log.Fatalf("Invalid config: %v", err)
 		}
 	}
 
-	// Create NFT checker (direct mode or AccessPolicy mode)
+	if cfg.GracePeriod > 0 && (cfg.MemesContract == "" || *revocationMode == "off") {
+		log.Printf("Warning: --grace-period needs the transfer watcher (--memes-contract, --revocation-mode not off); no wallet will get a grace session")
+	}
+
+	blockPin, err := nftcheck.ParseBlockPin(*checkBlock)
+	if err != nil {
+		log.Fatalf("Invalid --check-block: %v", err)
+	}
+	if *directFallback && *directMode {
+		log.Fatal("--direct-fallback only applies in AccessPolicy mode, not with --direct-mode")
+	}
+	if blockPin != (nftcheck.BlockPin{}) && (*directMode || *directFallback) && *balanceSource == nftcheck.BalanceSourceNFTAPI {
+		log.Fatal("--check-block cannot be pinned with --balance-source nft-api, which only reports current holdings")
+	}
+
+	// Create NFT checker (direct mode or AccessPolicy mode, optionally
+	// falling back to direct checks)
 	var checker nftcheck.AccessChecker
-	if *directMode {
+	var policyChecker *nftcheck.Checker // AccessPolicy mode only
+	if *directMode || *directFallback {
 		if cfg.MemesContract == "" {
-			log.Fatal("--memes-contract is required in direct mode")
+			log.Fatal("--memes-contract is required in direct mode and for --direct-fallback")
 		}
 		dc, err := nftcheck.NewDirectChecker(cfg.EthereumRPC, cfg.MemesContract, *thisCardID, *maxTokenID, 5*time.Minute)
 		if err != nil {
 			log.Fatalf("Failed to create direct NFT checker: %v", err)
 		}
 		defer dc.Close()
+		if err := dc.UseBalanceSource(*balanceSource, *nftAPIURL); err != nil {
+			log.Fatalf("Invalid --balance-source: %v", err)
+		}
+		dc.SetMaxCacheEntries(cfg.MaxCacheEntries)
+		dc.SetBlockPin(blockPin)
+		scanned := fmt.Sprintf("max-id=%d", *maxTokenID)
+		if *tokenIDs != "" {
+			ids, err := nftcheck.ParseTokenIDs(*tokenIDs)
+			if err != nil {
+				log.Fatalf("Invalid --token-ids: %v", err)
+			}
+			dc.SetTokenIDs(ids)
+			scanned = fmt.Sprintf("token-ids=%d listed", len(ids))
+		} else if *maxTokenIDRefresh > 0 {
+			dc.StartMaxTokenIDDiscovery(*maxTokenIDRefresh)
+			scanned = fmt.Sprintf("max-id=%d, discovery every %s", dc.MaxTokenID(), *maxTokenIDRefresh)
+		}
 		checker = dc
-		log.Printf("Direct mode: checking Memes ERC-1155 at %s (this-card=%d, max-id=%d)", cfg.MemesContract, *thisCardID, *maxTokenID)
+		log.Printf("Direct checks: Memes ERC-1155 at %s (this-card=%d, %s, balances=%s, block=%s)", cfg.MemesContract, *thisCardID, scanned, *balanceSource, blockPin)
 
 		// Configure delegation if enabled
 		if *enableDelegation {
-			ethClient, err := ethclient.Dial(cfg.EthereumRPC)
+			ethClient, err := outbound.Dial(cfg.EthereumRPC)
 			if err != nil {
 				log.Fatalf("Failed to connect to Ethereum for delegation: %v", err)
 			}
@@ -181,24 +566,45 @@ func main() {
 				Enable6529:        *enable6529,
 				MemesContract:     common.HexToAddress(cfg.MemesContract),
 				CacheTTL:          5 * time.Minute,
+				MaxCacheEntries:   cfg.MaxCacheEntries,
 			})
 			if err != nil {
 				log.Fatalf("Failed to create delegation checker: %v", err)
 			}
+			defer delChecker.Close()
 			dc.SetDelegation(delChecker)
 			log.Printf("Delegation enabled (delegate.xyz=%v, 6529=%v)", *enableDelegateXYZ, *enable6529)
 		}
-	} else {
+	}
+	if *devMode {
+		checker = nftcheck.NewStaticChecker(devTiers)
+		if len(devTiers) == 0 {
+			log.Printf("Dev mode access: every wallet gets the paid tier")
+		} else {
+			log.Printf("Dev mode access: %d listed wallet(s), all others denied", len(devTiers))
+		}
+	} else if !*directMode {
 		ac, err := nftcheck.NewChecker(cfg.EthereumRPC, cfg.AccessPolicyContract, 5*time.Minute)
 		if err != nil {
 			log.Fatalf("Failed to create NFT checker: %v", err)
 		}
 		defer ac.Close()
-		checker = ac
+		ac.SetMaxCacheEntries(cfg.MaxCacheEntries)
+		ac.SetBlockPin(blockPin)
+		policyChecker = ac
+		if *directFallback {
+			// Surface policy reverts so they fall back rather than deny
+			ac.SetRevertErrors(true)
+			checker = nftcheck.NewFallbackChecker(ac, checker)
+			log.Printf("AccessPolicy mode: checking %s, direct checks on failure (block=%s)", cfg.AccessPolicyContract, blockPin)
+		} else {
+			checker = ac
+			log.Printf("AccessPolicy mode: checking %s (block=%s)", cfg.AccessPolicyContract, blockPin)
+		}
 
 		// Configure delegation if enabled
 		if *enableDelegation {
-			ethClient, err := ethclient.Dial(cfg.EthereumRPC)
+			ethClient, err := outbound.Dial(cfg.EthereumRPC)
 			if err != nil {
 				log.Fatalf("Failed to connect to Ethereum for delegation: %v", err)
 			}
@@ -210,36 +616,176 @@ func main() {
 				Enable6529:        *enable6529,
 				MemesContract:     common.HexToAddress(cfg.MemesContract),
 				CacheTTL:          5 * time.Minute,
+				MaxCacheEntries:   cfg.MaxCacheEntries,
 			})
 			if err != nil {
 				log.Fatalf("Failed to create delegation checker: %v", err)
 			}
+			defer delChecker.Close()
 			ac.SetDelegation(delChecker)
 			log.Printf("Delegation enabled (delegate.xyz=%v, 6529=%v)", *enableDelegateXYZ, *enable6529)
 		}
 	}
 
+	// Configure SubscriptionManager if contract address is provided (read-only, no key needed)
+	var subMgr *subscriptionmgr.Manager
+	if *subManagerContract != "" {
+		sm, err := subscriptionmgr.New(cfg.EthereumRPC, *subManagerContract, int64(*chainID))
+		if err != nil {
+			log.Fatalf("Failed to create subscription manager: %v", err)
+		}
+		defer sm.Close()
+		sm.SetCacheTTL(*subscriptionCacheTTL)
+		sm.SetStaleTTL(*subscriptionStaleTTL)
+		subMgr = sm
+	}
+
+	// Let an active subscription grant paid access on its own
+	if *subscriptionAccess {
+		if subMgr == nil {
+			log.Fatal("--subscription-access requires --subscription-manager")
+		}
+		checker = nftcheck.NewCompositeChecker(checker, nftcheck.NewSubscriptionChecker(subMgr))
+		log.Printf("Subscription access enabled: active subscribers get paid tier")
+	}
+
+	// Keep recently active wallets' access results warm
+	if *nftCacheWarmWindow > 0 {
+		warmer := nftcheck.NewWarmer(checker, nftcheck.WarmerConfig{
+			CacheTTL:   5 * time.Minute,
+			Window:     *nftCacheWarmWindow,
+			Workers:    *nftCacheWarmWorkers,
+			MaxWallets: cfg.MaxCacheEntries,
+		})
+		warmer.Start()
+		checker = warmer
+		log.Printf("NFT cache warming enabled: wallets seen in the last %s (%d workers)", *nftCacheWarmWindow, *nftCacheWarmWorkers)
+	}
+
 	// Create WireGuard manager
+	serverEndpoint, altEndpoints, err := wireguard.ParseEndpoints(*wgEndpoint)
+	if err != nil {
+		log.Fatalf("Invalid --wg-endpoint: %v", err)
+	}
+	dnsPolicy, err := wireguard.ParseDNSPolicy(*wgDNSPolicy)
+	if err != nil {
+		log.Fatalf("Invalid --wg-dns-policy: %v", err)
+	}
+	extraInterfaces, err := wireguard.ParseInterfaces(*wgExtraInterfaces)
+	if err != nil {
+		log.Fatalf("Invalid --wg-extra-interfaces: %v", err)
+	}
 	wgCfg := wireguard.Config{
 		Interface:       *wgInterface,
 		ServerPublicKey: *wgPubKey,
-		ServerEndpoint:  *wgEndpoint,
+		ServerEndpoint:  serverEndpoint,
 		Subnet:          *wgSubnet,
 		DNS:             *wgDNS,
+		Backend:         cfg.WGBackend,
+
+		AlternateEndpoints:  altEndpoints,
+		PersistentKeepalive: *wgKeepalive,
+		DNSPolicy:           dnsPolicy,
+
+		ExtraInterfaces: extraInterfaces,
+		Placement:       *wgPlacement,
+
+		OnPeerAdd:    *onPeerAdd,
+		OnPeerRemove: *onPeerRemove,
+		HookTimeout:  *peerHookTimeout,
+	}
+	if *devMode {
+		wgCfg.Backend = wireguard.BackendMemory
+		if wgCfg.ServerPublicKey == "" {
+			if _, wgCfg.ServerPublicKey, err = wireguard.GenerateKeyPair(); err != nil {
+				log.Fatalf("Failed to generate dev WireGuard key: %v", err)
+			}
+		}
+		if wgCfg.ServerEndpoint == "" {
+			wgCfg.ServerEndpoint = "127.0.0.1:51820"
+		}
 	}
 
 	wgManager, err := wireguard.NewManager(wgCfg)
 	if err != nil {
 		log.Fatalf("Failed to create WireGuard manager: %v", err)
 	}
+	if wgCfg.Backend == wireguard.BackendNone {
+		log.Printf("Discovery-only gateway: no WireGuard tunnels are provisioned; clients connect through listed nodes")
+	} else if wgCfg.Backend == wireguard.BackendMemory {
+		log.Printf("WireGuard peers are kept in memory only; no interface is configured")
+	} else if *wgSkipCheck {
+		log.Printf("Skipping WireGuard interface check (--wg-skip-check)")
+	} else if err := wgManager.Verify(); err != nil {
+		log.Fatalf("WireGuard interface check failed: %v", err)
+	}
+
+	if *onPeerAdd != "" || *onPeerRemove != "" {
+		log.Printf("Peer hooks enabled (add=%q, remove=%q, timeout=%s)", *onPeerAdd, *onPeerRemove, *peerHookTimeout)
+	}
 
 	// Start expired peer cleanup every minute
 	wgManager.StartCleanupWorker(1 * time.Minute)
 
 	// Create and start server
 	srv := server.New(cfg, checker, wgManager)
+	defer srv.Close()
+	srv.SetBuildInfo(build)
 	srv.SetChainID(*chainID)
 	log.Printf("Free tier enabled: %v", cfg.EnableFreeTier)
+	quotasEnabled := cfg.QuotaFreeBytes > 0 || cfg.QuotaPaidBytes > 0
+	if quotasEnabled {
+		log.Printf("Data quotas enabled: free=%d paid=%d bytes per %s (action=%s)", cfg.QuotaFreeBytes, cfg.QuotaPaidBytes, cfg.QuotaPeriod, cfg.QuotaAction)
+	}
+	if *usageDB != "" {
+		store, err := usage.Open(*usageDB)
+		if err != nil {
+			log.Fatalf("Failed to open usage db: %v", err)
+		}
+		defer store.Close()
+		srv.SetUsageStore(store)
+		log.Printf("Usage history enabled: %s", *usageDB)
+	}
+	if *feedbackWindow > 0 {
+		srv.SetFeedbackStore(feedback.NewStore(*feedbackWindow, 0))
+	}
+	if quotasEnabled || *usageDB != "" {
+		srv.StartUsageWorker(context.Background(), 1*time.Minute)
+	}
+	if cfg.RegionRequiresFree(cfg.Region) {
+		log.Printf("Region %s is free-tier only: paid-tier connects will be refused", cfg.Region)
+	}
+
+	// Ethereum RPC is critical: without it no wallet can be verified
+	var receipts txtracker.ReceiptSource
+	var dev *devChain
+	if *devMode {
+		region := cfg.Region
+		if region == "" {
+			region = "dev"
+		}
+		dev, err = newDevChain(wgCfg.ServerEndpoint, wgCfg.ServerPublicKey, region, int64(*chainID), *nodeRegistryCacheTTL)
+		if err != nil {
+			log.Fatalf("Failed to create dev chain stubs: %v", err)
+		}
+		defer dev.rpc.Close()
+		receipts = dev.rpc
+	} else {
+		healthClient, err := outbound.Dial(cfg.EthereumRPC)
+		if err != nil {
+			log.Fatalf("Failed to connect to Ethereum for health checks: %v", err)
+		}
+		defer healthClient.Close()
+		srv.AddHealthProbe(server.HealthProbe{
+			Name:     "ethereum_rpc",
+			Critical: true,
+			Check: func(ctx context.Context) error {
+				_, err := healthClient.BlockNumber(ctx)
+				return err
+			},
+		})
+		receipts = healthClient
+	}
 
 	if *enrollmentDBURL != "" {
 		enrollmentStore, err := server.NewPostgresOperatorEnrollmentStore(
@@ -257,23 +803,91 @@ func main() {
 		log.Printf("Operator enrollment storage: memory")
 	}
 
+	// Track operator transactions (heartbeats, session open/close) for GET /admin/txs
+	txTracker := txtracker.New(receipts, txtracker.DefaultPollInterval)
+	go txTracker.Start(context.Background())
+	srv.SetTxTracker(txTracker)
+	if *adminToken != "" {
+		srv.SetAdminToken(*adminToken)
+		log.Printf("Admin endpoints enabled")
+	}
+
 	if *corsOrigin != "" {
 		srv.SetCORSOrigin(*corsOrigin)
 		log.Printf("CORS enabled for origin: %s", *corsOrigin)
 	}
 
+	// Audit trail of access decisions, kept apart from this log
+	var auditLog *audit.Logger
+	if *auditLogPath != "" {
+		l, err := audit.Open(*auditLogPath)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		defer l.Close()
+		auditLog = l
+		srv.SetAuditLogger(auditLog)
+		log.Printf("Audit log enabled: %s", *auditLogPath)
+	}
+
+	// Configure outbound webhooks if a URL is provided. The notifier is
+	// closed explicitly on shutdown: the log.Fatal calls below would skip a
+	// deferred Close.
+	var notifier *webhook.Notifier
+	if *webhookURL != "" {
+		notifier, err = webhook.NewNotifier(webhook.Config{
+			URL:    *webhookURL,
+			Secret: *webhookSecret,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create webhook notifier: %v", err)
+		}
+		srv.SetWebhookNotifier(notifier)
+		log.Printf("Webhooks enabled for session revocation and expiry")
+	}
+
+	// Configure operator rep breakdown endpoint
+	if *repAPIURL != "" {
+		operatorRepChecker := rep6529.NewChecker(rep6529.Config{
+			BaseURL:  *repAPIURL,
+			Category: *repCategory,
+			MinRep:   *repMin,
+			CacheTTL: *repCacheTTL,
+
+			MinRaters:     *repMinRaters,
+			MinBackingTDH: *repMinBackingTDH,
+
+			MaxCacheEntries: cfg.MaxCacheEntries,
+		})
+		defer operatorRepChecker.Close()
+		srv.SetOperatorRepChecker(operatorRepChecker)
+		srv.AddHealthProbe(server.HealthProbe{Name: "rep_api", Check: operatorRepChecker.Ping})
+	}
+
 	// Configure user ban check if enabled
 	if *userBanCheck {
 		userRepChecker := rep6529.NewChecker(rep6529.Config{
 			Category: *userBanCategory,
 			MinRep:   1, // placeholder; we check Rating < 0 directly
 			CacheTTL: *repCacheTTL,
+
+			MaxCacheEntries: cfg.MaxCacheEntries,
 		})
+		defer userRepChecker.Close()
 		srv.SetUserRepChecker(userRepChecker)
 		log.Printf("User ban check enabled: category=%q", *userBanCategory)
 	}
 
+	if *startDrained {
+		// Before any heartbeat sender starts, so discovery never sees the node.
+		srv.SetDraining(true, true)
+	}
+
 	// Configure node registry if contract address is provided
+	if *devMode {
+		srv.SetRegistry(dev.registry)
+		log.Printf("Node registry: dev stub listing this node as %s", dev.operator.Hex())
+	}
 	if *nodeRegistryContract != "" {
 		registry, err := noderegistry.NewRegistry(cfg.EthereumRPC, *nodeRegistryContract, *nodeRegistryCacheTTL)
 		if err != nil {
@@ -282,6 +896,23 @@ func main() {
 		defer registry.Close()
 		srv.SetRegistry(registry)
 		log.Printf("Node registry enabled: %s (card-gated)", *nodeRegistryContract)
+		if *nodeVersionTTL > 0 {
+			srv.SetNodeVersionProber(noderegistry.NewVersionProber(*nodeVersionTTL))
+		}
+		if *geoIPURL != "" {
+			verifier, err := noderegistry.NewRegionVerifier(*geoIPURL, *geoIPTTL)
+			if err != nil {
+				log.Fatalf("Invalid --geoip-url: %v", err)
+			}
+			srv.SetRegionVerifier(verifier)
+			log.Printf("Node region verification enabled (re-checked every %s)", *geoIPTTL)
+		}
+		if *networkStatsInterval > 0 {
+			stats := noderegistry.NewStatsCollector(registry, *networkStatsInterval)
+			stats.Start()
+			defer stats.Stop()
+			srv.SetNetworkStatsCollector(stats)
+		}
 
 		// Start heartbeat sender if private key is provided (node operator mode)
 		if *heartbeatKey != "" {
@@ -292,13 +923,24 @@ func main() {
 			if err != nil {
 				log.Fatalf("Failed to create heartbeat sender: %v", err)
 			}
+			hb.SetGasPolicy(gasPolicy)
+			hb.SetTxTracker(txTracker)
+			srv.SetHeartbeatSender(hb)
 			go hb.Start(context.Background())
 			defer hb.Stop()
+			rotation.hb = hb
 			log.Printf("Heartbeat sender started (interval=%s)", *heartbeatInterval)
 		}
 	}
 
 	// Configure SessionManager if contract address is provided
+	var sessionMgr *sessionmgr.Manager
+	if *devMode {
+		dev.sessions.SetTxTracker(txTracker)
+		srv.SetSessionManager(dev.sessions)
+		sessionMgr = dev.sessions
+		log.Printf("SessionManager: dev stub (every wallet has a paid session)")
+	}
 	if *sessionManagerContract != "" {
 		keyHex := *sessionKey
 		if keyHex == "" {
@@ -312,6 +954,8 @@ func main() {
 				log.Fatalf("Failed to create session manager: %v", err)
 			}
 			defer sm.Close()
+			sm.SetGasPolicy(gasPolicy)
+			sm.SetTxTracker(txTracker)
 
 			// If the signer key differs from the heartbeat key, the signer is the
 			// contract owner, not this node. Set the real operator from heartbeat key.
@@ -325,18 +969,34 @@ func main() {
 			}
 
 			srv.SetSessionManager(sm)
+			srv.SetStaleSessionGrace(*sessionSweepGrace)
+			if *sessionSweepInterval > 0 && srv.StartSessionSweeper(context.Background(), *sessionSweepInterval) {
+				log.Printf("Stale on-chain session sweep every %s (grace %s)", *sessionSweepInterval, *sessionSweepGrace)
+			}
+			sessionMgr = sm
+			rotation.sm = sm
+			rotation.smSignsWithHeartbeatKey = *sessionKey == "" || *sessionKey == *heartbeatKey
 			log.Printf("SessionManager enabled: %s", *sessionManagerContract)
 		}
 	}
 
-	// Configure SubscriptionManager if contract address is provided (read-only, no key needed)
-	if *subManagerContract != "" {
-		sm, err := subscriptionmgr.New(cfg.EthereumRPC, *subManagerContract, int64(*chainID))
-		if err != nil {
-			log.Fatalf("Failed to create subscription manager: %v", err)
+	if *outboxDB != "" {
+		if sessionMgr == nil {
+			log.Printf("Warning: --outbox-db set without a SessionManager; ignoring it")
+		} else {
+			ob, err := outbox.Open(*outboxDB)
+			if err != nil {
+				log.Fatalf("Failed to open outbox db: %v", err)
+			}
+			defer ob.Close()
+			sessionMgr.SetOutbox(ob)
+			sessionMgr.ResumeOutbox(context.Background(), *outboxRetry)
+			log.Printf("SessionManager outbox enabled: %s (retry every %s)", *outboxDB, *outboxRetry)
 		}
-		defer sm.Close()
-		srv.SetSubscriptionManager(sm)
+	}
+
+	if subMgr != nil {
+		srv.SetSubscriptionManager(subMgr)
 		log.Printf("SubscriptionManager enabled: %s", *subManagerContract)
 	}
 
@@ -359,26 +1019,132 @@ func main() {
 		log.Printf("ZK verification enabled: %s", *zkAPIURL)
 	}
 
-	// Start transfer event watcher if WebSocket endpoint is configured
-	if *ethWS != "" && cfg.MemesContract != "" {
+	// Start transfer event watcher. WebSocket subscriptions are used when
+	// --eth-ws is a ws(s):// endpoint; otherwise transfers are polled over HTTP.
+	if cfg.MemesContract != "" && *revocationMode != "off" {
 		revoker := server.NewRevoker(srv)
-		watcher, err := revocation.NewWatcher(*ethWS, common.HexToAddress(cfg.MemesContract), revoker)
+		memes := common.HexToAddress(cfg.MemesContract)
+
+		mode := *revocationMode
+		if mode == "auto" {
+			mode = "poll"
+			switch {
+			case *ethWS == "":
+				log.Printf("Revocation: --eth-ws not set; polling transfers over --eth-rpc every %s (set --eth-ws to a ws(s):// endpoint for push revocation)", *revocationPollInterval)
+			case !revocation.SupportsSubscriptions(*ethWS):
+				log.Printf("Revocation: --eth-ws %s is not a WebSocket endpoint; polling transfers every %s", *ethWS, *revocationPollInterval)
+			default:
+				mode = "ws"
+			}
+		}
+
+		switch mode {
+		case "ws":
+			watcher, err := revocation.NewWatcher(*ethWS, memes, revoker)
+			if errors.Is(err, revocation.ErrSubscriptionsUnsupported) {
+				log.Fatalf("Invalid --eth-ws endpoint: %v (use --revocation-mode=poll for HTTP-only providers)", err)
+			} else if err != nil {
+				log.Printf("Warning: failed to start transfer watcher: %v", err)
+			} else {
+				watcher.SetConfirmations(*revocationConfirmations)
+				go watcher.Start(context.Background())
+				defer watcher.Stop()
+				srv.AddHealthProbe(server.HealthProbe{
+					Name:  "revocation_ws",
+					Check: func(context.Context) error { return watcher.Health() },
+				})
+				log.Printf("Transfer event watcher started on %s", cfg.MemesContract)
+			}
+		case "poll":
+			pollURL := *ethWS
+			if pollURL == "" {
+				pollURL = cfg.EthereumRPC
+			}
+			warnMissingRPCMethods(pollURL, []preflight.MethodNeed{{Method: preflight.MethodGetLogs, Feature: "revocation polling"}})
+			poller, err := revocation.NewPollingWatcher(pollURL, memes, revoker, *revocationPollInterval)
+			if err != nil {
+				log.Printf("Warning: failed to start transfer poller: %v", err)
+			} else {
+				poller.SetConfirmations(*revocationConfirmations)
+				go poller.Start(context.Background())
+				defer poller.Stop()
+				srv.AddHealthProbe(server.HealthProbe{
+					Name:  "revocation_poll",
+					Check: func(context.Context) error { return poller.Health() },
+				})
+				log.Printf("Transfer event poller started on %s (interval=%s)", cfg.MemesContract, *revocationPollInterval)
+			}
+		default:
+			log.Fatalf("Invalid --revocation-mode %q (want auto, ws, poll, or off)", *revocationMode)
+		}
+	}
+
+	// Flush the access cache when AccessPolicy governance changes which
+	// cards qualify, rather than serving stale tiers until the TTL.
+	if *policyWatchInterval > 0 && policyChecker != nil {
+		policyWatcher, err := revocation.NewPolicyWatcher(cfg.EthereumRPC, common.HexToAddress(cfg.AccessPolicyContract), policyChecker, *policyWatchInterval)
 		if err != nil {
-			log.Printf("Warning: failed to start transfer watcher: %v", err)
+			log.Printf("Warning: failed to start AccessPolicy watcher: %v", err)
 		} else {
-			go watcher.Start(context.Background())
-			defer watcher.Stop()
-			log.Printf("Transfer event watcher started on %s", cfg.MemesContract)
+			go policyWatcher.Start(context.Background())
+			defer policyWatcher.Stop()
+			srv.AddHealthProbe(server.HealthProbe{
+				Name:  "policy_watch",
+				Check: func(context.Context) error { return policyWatcher.Health() },
+			})
+			log.Printf("AccessPolicy watcher started on %s (interval=%s)", cfg.AccessPolicyContract, *policyWatchInterval)
+		}
+	}
+
+	// Drop cached subscription reads as soon as a wallet subscribes or
+	// renews, so new subscribers are not turned away until the TTL. Events
+	// stream over --eth-ws when transfers would too, and are polled
+	// otherwise.
+	if subMgr != nil && *subscriptionCacheTTL > 0 && *subscriptionWatchInterval > 0 {
+		subContract := common.HexToAddress(*subManagerContract)
+		streamed := false
+		if (*revocationMode == "auto" || *revocationMode == "ws") && *ethWS != "" && revocation.SupportsSubscriptions(*ethWS) {
+			subWatcher, err := revocation.NewSubscriptionStreamWatcher(*ethWS, subContract, subMgr)
+			if err != nil {
+				log.Printf("Warning: failed to stream SubscriptionManager events, polling instead: %v", err)
+			} else {
+				go subWatcher.Start(context.Background())
+				defer subWatcher.Stop()
+				srv.AddHealthProbe(server.HealthProbe{
+					Name:  "subscription_ws",
+					Check: func(context.Context) error { return subWatcher.Health() },
+				})
+				log.Printf("SubscriptionManager event watcher started on %s", *subManagerContract)
+				streamed = true
+			}
+		}
+		if !streamed {
+			subWatcher, err := revocation.NewSubscriptionWatcher(cfg.EthereumRPC, subContract, subMgr, *subscriptionWatchInterval)
+			if err != nil {
+				log.Printf("Warning: failed to start SubscriptionManager watcher: %v", err)
+			} else {
+				go subWatcher.Start(context.Background())
+				defer subWatcher.Stop()
+				srv.AddHealthProbe(server.HealthProbe{
+					Name:  "subscription_watch",
+					Check: func(context.Context) error { return subWatcher.Health() },
+				})
+				log.Printf("SubscriptionManager watcher started on %s (interval=%s)", *subManagerContract, *subscriptionWatchInterval)
+			}
 		}
 	}
 
-	log.Printf("Sovereign VPN Gateway starting")
+	if !*devMode {
+		warnMissingRPCMethods(cfg.EthereumRPC, rpcNeeds)
+	}
+
+	log.Printf("Sovereign VPN Gateway %s starting", build)
 	log.Printf("  Ethereum RPC:  %s", cfg.EthereumRPC)
 	log.Printf("  AccessPolicy:  %s", cfg.AccessPolicyContract)
 	log.Printf("  Memes:         %s", cfg.MemesContract)
 	log.Printf("  Chain ID:      %d", *chainID)
 	log.Printf("  SIWE Domain:   %s", cfg.SIWEDomain)
-	log.Printf("  WG Interface:  %s", *wgInterface)
+	log.Printf("  WG Interface:  %s", strings.Join(wgManager.Interfaces(), ", "))
 	log.Printf("  WG Endpoint:   %s", *wgEndpoint)
 	log.Printf("  WG Subnet:     %s", *wgSubnet)
 	log.Printf("  Delegation:    %v", *enableDelegation)
@@ -407,20 +1173,71 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	var challengeSrv *http.Server
+	var certs *certReloader
 	errCh := make(chan error, 1)
-	go func() {
-		log.Printf("Gateway listening on %s", cfg.ListenAddr)
-		errCh <- httpSrv.ListenAndServe()
-	}()
+	if tlsOpts.enabled() {
+		challengeSrv, certs, err = configureTLS(httpSrv, tlsOpts)
+		if err != nil {
+			log.Fatalf("Invalid TLS setup: %v", err)
+		}
+		if challengeSrv != nil {
+			go func() {
+				log.Printf("ACME HTTP-01 challenges on %s", challengeSrv.Addr)
+				errCh <- challengeSrv.ListenAndServe()
+			}()
+		}
+		go func() {
+			log.Printf("Gateway listening on %s (HTTPS)", cfg.ListenAddr)
+			errCh <- httpSrv.ListenAndServeTLS("", "")
+		}()
+	} else {
+		go func() {
+			log.Printf("Gateway listening on %s", cfg.ListenAddr)
+			errCh <- httpSrv.ListenAndServe()
+		}()
+	}
+
+	var grpcSrv *grpc.Server
+	if cfg.GRPCListenAddr != "" {
+		lis, err := net.Listen("tcp", cfg.GRPCListenAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen for gRPC: %v", err)
+		}
+		grpcSrv = srv.GRPCServer()
+		go func() {
+			log.Printf("gRPC API listening on %s", cfg.GRPCListenAddr)
+			errCh <- grpcSrv.Serve(lis)
+		}()
+	}
 
 	// Wait for interrupt signal
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
+	// SIGHUP re-reads --session-key-file / --heartbeat-key-file and
+	// --tls-cert / --tls-key, and reopens --audit-log
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	go func() {
+		for range hupCh {
+			if certs != nil {
+				certs.reload()
+			}
+			rotation.reload()
+			if err := auditLog.Reopen(); err != nil {
+				log.Printf("SIGHUP: %v; keeping current audit log", err)
+			}
+		}
+	}()
+
 	select {
 	case sig := <-sigCh:
 		log.Printf("Received signal %v, shutting down...", sig)
 	case err := <-errCh:
+		if notifier != nil {
+			notifier.Close()
+		}
 		log.Fatalf("Server error: %v", err)
 	}
 
@@ -431,5 +1248,21 @@ func main() {
 	if err := httpSrv.Shutdown(ctx); err != nil {
 		log.Printf("Shutdown error: %v", err)
 	}
+	if challengeSrv != nil {
+		challengeSrv.Shutdown(ctx)
+	}
+	if grpcSrv != nil {
+		grpcSrv.GracefulStop()
+	}
+	// Let session open/close txs queued by the last requests go out before
+	// the deferred Close calls shut the RPC clients.
+	if sessionMgr != nil {
+		if err := sessionMgr.Drain(ctx); err != nil {
+			log.Printf("Shutdown: gave up waiting for on-chain session txs: %v", err)
+		}
+	}
+	if notifier != nil {
+		notifier.Close()
+	}
 	log.Println("Gateway stopped")
 }
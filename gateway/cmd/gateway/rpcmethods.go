@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/preflight"
+)
+
+// rpcProbeTimeout bounds the startup probe of one endpoint.
+const rpcProbeTimeout = 15 * time.Second
+
+// warnMissingRPCMethods probes url for each needed method and logs the
+// features that will not work on it, so a restricted RPC shows up at startup
+// instead of as opaque errors from the first request that needs it.
+func warnMissingRPCMethods(url string, needs []preflight.MethodNeed) {
+	ctx, cancel := context.WithTimeout(context.Background(), rpcProbeTimeout)
+	defer cancel()
+
+	client, err := outbound.DialRPC(ctx, url)
+	if err != nil {
+		log.Printf("Warning: cannot probe RPC methods on %s: %v", url, err)
+		return
+	}
+	defer client.Close()
+
+	var supported []string
+	for _, res := range preflight.ProbeMethods(ctx, client, needs) {
+		switch {
+		case res.Unsupported():
+			log.Printf("Warning: %s does not support %s; %s will not work", url, res.Method, res.Feature)
+		case res.Err != nil:
+			log.Printf("Warning: could not probe %s on %s: %v", res.Method, url, res.Err)
+		default:
+			supported = append(supported, res.Method)
+		}
+	}
+	if len(supported) > 0 {
+		log.Printf("RPC %s supports %s", url, strings.Join(supported, ", "))
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsOptions selects the gateway's built-in TLS: a certificate and key on
+// disk, or certificates obtained from Let's Encrypt for ACME domains.
+// Neither leaves the gateway on plain HTTP for a reverse proxy to front.
+type tlsOptions struct {
+	certFile string
+	keyFile  string
+
+	acmeDomains    string // comma-separated
+	acmeCacheDir   string
+	acmeEmail      string
+	acmeHTTPListen string // serves HTTP-01 challenges and redirects to HTTPS; empty relies on TLS-ALPN-01
+}
+
+func (o tlsOptions) enabled() bool {
+	return o.certFile != "" || o.keyFile != "" || o.acmeDomains != ""
+}
+
+// configureTLS sets srv.TLSConfig from o. With ACME and an HTTP listen
+// address it also returns the server answering HTTP-01 challenges, which
+// the caller starts; otherwise that server is nil. certs is non-nil when
+// the certificate comes from files, for SIGHUP to reload.
+func configureTLS(srv *http.Server, o tlsOptions) (challengeSrv *http.Server, certs *certReloader, err error) {
+	if o.acmeDomains != "" {
+		if o.certFile != "" || o.keyFile != "" {
+			return nil, nil, fmt.Errorf("--acme-domain cannot be combined with --tls-cert/--tls-key")
+		}
+		var domains []string
+		for _, d := range strings.Split(o.acmeDomains, ",") {
+			if d = strings.TrimSpace(d); d != "" {
+				domains = append(domains, d)
+			}
+		}
+		if len(domains) == 0 {
+			return nil, nil, fmt.Errorf("--acme-domain lists no domains")
+		}
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(domains...),
+			Cache:      autocert.DirCache(o.acmeCacheDir),
+			Email:      o.acmeEmail,
+		}
+		srv.TLSConfig = m.TLSConfig()
+		if o.acmeHTTPListen != "" {
+			challengeSrv = &http.Server{
+				Addr:        o.acmeHTTPListen,
+				Handler:     m.HTTPHandler(nil),
+				ReadTimeout: 10 * time.Second,
+			}
+		}
+		return challengeSrv, nil, nil
+	}
+
+	if o.certFile == "" || o.keyFile == "" {
+		return nil, nil, fmt.Errorf("--tls-cert and --tls-key must be set together")
+	}
+	certs = &certReloader{certFile: o.certFile, keyFile: o.keyFile}
+	if err := certs.load(); err != nil {
+		return nil, nil, err
+	}
+	srv.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.getCertificate,
+	}
+	return nil, certs, nil
+}
+
+// certReloader serves a certificate from files that an external renewer,
+// such as certbot, replaces in place.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func (c *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	c.mu.Lock()
+	c.cert = &cert
+	c.mu.Unlock()
+	return nil
+}
+
+// reload re-reads the files. A pair that fails to load leaves the current
+// certificate in place.
+func (c *certReloader) reload() {
+	if err := c.load(); err != nil {
+		log.Printf("SIGHUP: %v; keeping current certificate", err)
+		return
+	}
+	log.Printf("SIGHUP: reloaded TLS certificate from %s", c.certFile)
+}
+
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/config"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/preflight"
+)
+
+// contractAddr names a configured contract for validation.
+type contractAddr struct {
+	name string
+	addr string
+}
+
+// runValidate checks cfg and the chain it points at, prints a pass/fail
+// report, and returns the process exit code. It never starts the listener.
+func runValidate(cfg *config.Config, directMode bool, chainID int64, rpcNeeds []preflight.MethodNeed, contracts []contractAddr) int {
+	var report preflight.Report
+
+	if directMode {
+		var err error
+		switch {
+		case cfg.MemesContract == "":
+			err = fmt.Errorf("memes_contract is required in direct mode")
+		case cfg.EthereumRPC == "":
+			err = fmt.Errorf("ethereum_rpc is required")
+		}
+		report.Add("config (direct mode)", err)
+	} else {
+		report.Add("config", cfg.Validate())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rpcCheck := fmt.Sprintf("rpc %s (chain id %d)", cfg.EthereumRPC, chainID)
+	client, err := outbound.DialContext(ctx, cfg.EthereumRPC)
+	if err != nil {
+		report.Add(rpcCheck, err)
+	} else {
+		defer client.Close()
+		err = preflight.CheckChainID(ctx, client, chainID)
+		report.Add(rpcCheck, err)
+	}
+	if err == nil {
+		for _, res := range preflight.ProbeMethods(ctx, client.Client(), rpcNeeds) {
+			report.Add(fmt.Sprintf("rpc method %s (%s)", res.Method, res.Feature), res.Err)
+		}
+		for _, c := range contracts {
+			if c.addr == "" {
+				continue
+			}
+			report.Add(c.name+" bytecode", preflight.CheckContract(ctx, client, c.addr))
+		}
+	}
+
+	report.Print(os.Stdout)
+	if !report.OK() {
+		return 1
+	}
+	return 0
+}
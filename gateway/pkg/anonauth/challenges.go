@@ -3,12 +3,15 @@ package anonauth
 import (
 	"sync"
 	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/janitor"
 )
 
 // ChallengeStore keeps short-lived anonymous challenges in memory.
 type ChallengeStore struct {
 	mu         sync.RWMutex
 	challenges map[string]*Challenge
+	stopSweep  func()
 }
 
 // NewChallengeStore creates an empty challenge store with periodic cleanup.
@@ -16,10 +19,15 @@ func NewChallengeStore() *ChallengeStore {
 	cs := &ChallengeStore{
 		challenges: make(map[string]*Challenge),
 	}
-	go cs.cleanup()
+	cs.stopSweep = janitor.Register("anonauth challenges", cs.removeExpired)
 	return cs
 }
 
+// Close stops the periodic cleanup of expired challenges.
+func (cs *ChallengeStore) Close() {
+	cs.stopSweep()
+}
+
 // Set stores a challenge.
 func (cs *ChallengeStore) Set(challenge *Challenge) {
 	cs.mu.Lock()
@@ -50,18 +58,13 @@ func (cs *ChallengeStore) Delete(id string) {
 	cs.mu.Unlock()
 }
 
-func (cs *ChallengeStore) cleanup() {
-	ticker := time.NewTicker(time.Minute)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		now := time.Now().UTC()
-		cs.mu.Lock()
-		for id, challenge := range cs.challenges {
-			if now.After(challenge.ExpiresAt) {
-				delete(cs.challenges, id)
-			}
+// removeExpired deletes challenges that were never answered. Run by the janitor.
+func (cs *ChallengeStore) removeExpired(now time.Time) {
+	cs.mu.Lock()
+	for id, challenge := range cs.challenges {
+		if now.After(challenge.ExpiresAt) {
+			delete(cs.challenges, id)
 		}
-		cs.mu.Unlock()
 	}
+	cs.mu.Unlock()
 }
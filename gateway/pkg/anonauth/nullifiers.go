@@ -3,12 +3,15 @@ package anonauth
 import (
 	"sync"
 	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/janitor"
 )
 
 // NullifierStore tracks nullifiers that have been consumed by the anonymous path.
 type NullifierStore struct {
 	mu         sync.RWMutex
 	nullifiers map[string]time.Time
+	stopSweep  func()
 }
 
 // NewNullifierStore creates a nullifier store with periodic cleanup.
@@ -16,10 +19,15 @@ func NewNullifierStore() *NullifierStore {
 	ns := &NullifierStore{
 		nullifiers: make(map[string]time.Time),
 	}
-	go ns.cleanup()
+	ns.stopSweep = janitor.Register("anonauth nullifiers", ns.removeExpired)
 	return ns
 }
 
+// Close stops the periodic cleanup of expired nullifiers.
+func (ns *NullifierStore) Close() {
+	ns.stopSweep()
+}
+
 // Consume marks a nullifier as used until the provided TTL expires.
 // Returns false if the nullifier is already active.
 func (ns *NullifierStore) Consume(nullifier string, ttl time.Duration) bool {
@@ -75,18 +83,13 @@ func (ns *NullifierStore) Release(nullifier string) {
 	ns.mu.Unlock()
 }
 
-func (ns *NullifierStore) cleanup() {
-	ticker := time.NewTicker(time.Minute)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		now := time.Now().UTC()
-		ns.mu.Lock()
-		for nullifier, expiresAt := range ns.nullifiers {
-			if !now.Before(expiresAt) {
-				delete(ns.nullifiers, nullifier)
-			}
+// removeExpired deletes nullifiers whose TTL has passed. Run by the janitor.
+func (ns *NullifierStore) removeExpired(now time.Time) {
+	ns.mu.Lock()
+	for nullifier, expiresAt := range ns.nullifiers {
+		if !now.Before(expiresAt) {
+			delete(ns.nullifiers, nullifier)
 		}
-		ns.mu.Unlock()
 	}
+	ns.mu.Unlock()
 }
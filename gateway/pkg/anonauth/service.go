@@ -50,6 +50,12 @@ func NewService(challengeTTL time.Duration, nonceLength int, proofType string, p
 	}
 }
 
+// Close stops the background cleanup of the challenge and nullifier stores.
+func (s *Service) Close() {
+	s.challenges.Close()
+	s.nullifiers.Close()
+}
+
 // NewChallenge creates and stores a new anonymous-access challenge.
 func (s *Service) NewChallenge() (*Challenge, error) {
 	id, err := randomToken(24)
@@ -0,0 +1,160 @@
+// Package audit writes an append-only trail of access decisions -- who was
+// granted or denied access to the node, when, and why -- as JSON lines to a
+// dedicated file or syslog, separate from the operational log.
+//
+// Operational logs leave wallet addresses out; the audit log records them,
+// so operators enable it only when they need a paper trail for compliance
+// or abuse investigations. Anonymous sessions are not recorded.
+//
+// Files are opened in append mode and reopened by Reopen, so logrotate can
+// rotate them with a SIGHUP postrotate instead of copytruncate.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+)
+
+// SyslogTarget as the Open path sends records to the local syslog daemon.
+const SyslogTarget = "syslog"
+
+// Events.
+const (
+	EventVerify  = "verify"  // sign-in: the wallet's tier was decided
+	EventConnect = "connect" // a WireGuard peer was requested for a session
+	EventRevoke  = "revoke"  // access was withdrawn, e.g. the card moved away
+	EventRekey   = "rekey"   // a connected peer moved to a new WireGuard key
+)
+
+// Outcomes.
+const (
+	OutcomeGranted = "granted"
+	OutcomeDenied  = "denied"
+	OutcomeRevoked = "revoked"
+)
+
+// Record is one access decision, written as a single JSON line.
+type Record struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	Outcome string    `json:"outcome"`
+	Address string    `json:"address"`
+	Tier    string    `json:"tier,omitempty"`
+	// Source is the check that decided the tier: access_policy, direct,
+	// subscription, zk_proof or grace_period.
+	Source string `json:"source,omitempty"`
+	// Vault is the delegated cold wallet whose cards granted access.
+	Vault string `json:"vault,omitempty"`
+	// Reason explains a denial or revocation, or how a connect was paid for.
+	Reason       string `json:"reason,omitempty"`
+	Probationary bool   `json:"probationary,omitempty"`
+	PeerKey      string `json:"peer_key,omitempty"` // WireGuard public key, connect and rekey only
+	// TermsURL and TermsSHA256 are the terms of service the signed sign-in
+	// message accepted, verify only. The record's time is when.
+	TermsURL    string `json:"terms_url,omitempty"`
+	TermsSHA256 string `json:"terms_sha256,omitempty"`
+}
+
+// Logger appends records to a file or syslog. A nil *Logger discards them,
+// so callers need not check whether auditing is on.
+type Logger struct {
+	path  string
+	clock clock.Clock // nil means the wall clock
+
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+// Open starts an audit log at path, creating the file if needed, or at the
+// local syslog when path is SyslogTarget.
+func Open(path string) (*Logger, error) {
+	w, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Logger{path: path, w: w}, nil
+}
+
+func open(path string) (io.WriteCloser, error) {
+	if path == SyslogTarget {
+		return openSyslog()
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+	return f, nil
+}
+
+// SetClock replaces the clock used to stamp records. Call it before the
+// logger is in use.
+func (l *Logger) SetClock(clk clock.Clock) {
+	l.clock = clk
+}
+
+func (l *Logger) now() time.Time {
+	if l.clock == nil {
+		return time.Now()
+	}
+	return l.clock.Now()
+}
+
+// Log writes rec, stamping it with the current time if Time is unset. A
+// failed write is reported on the operational log; the decision it records
+// has already been made, so it is not returned to the caller.
+func (l *Logger) Log(rec Record) {
+	if l == nil {
+		return
+	}
+	if rec.Time.IsZero() {
+		rec.Time = l.now()
+	}
+	rec.Time = rec.Time.UTC()
+	line, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("[audit] encoding record: %v", err)
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(line); err != nil {
+		log.Printf("[audit] write failed, record lost: %v", err)
+	}
+}
+
+// Reopen closes and reopens the log file, so records go to a fresh file
+// after logrotate has moved the old one away. A file that fails to reopen
+// leaves the current one in use.
+func (l *Logger) Reopen() error {
+	if l == nil || l.path == SyslogTarget {
+		return nil
+	}
+	w, err := open(l.path)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	old := l.w
+	l.w = w
+	l.mu.Unlock()
+	return old.Close()
+}
+
+// Close closes the log.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Close()
+}
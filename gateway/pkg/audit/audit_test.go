@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+)
+
+func readRecords(t *testing.T, path string) []Record {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening %s: %v", path, err)
+	}
+	defer f.Close()
+	var recs []Record
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("line %q is not a JSON record: %v", sc.Text(), err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestLogAppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := os.WriteFile(path, []byte(`{"event":"verify","outcome":"denied","address":"0xold"}`+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer l.Close()
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	l.SetClock(clock.NewFake(now))
+
+	l.Log(Record{Event: EventVerify, Outcome: OutcomeGranted, Address: "0xabc", Tier: "paid", Source: "direct", Vault: "0xdef"})
+	l.Log(Record{Event: EventRevoke, Outcome: OutcomeRevoked, Address: "0xabc", Reason: "nft_transferred"})
+
+	recs := readRecords(t, path)
+	if len(recs) != 3 {
+		t.Fatalf("got %d records, want the existing one plus 2 appended", len(recs))
+	}
+	if got := recs[1]; got.Address != "0xabc" || got.Vault != "0xdef" || got.Source != "direct" || !got.Time.Equal(now) {
+		t.Errorf("record = %+v", got)
+	}
+	if recs[2].Reason != "nft_transferred" {
+		t.Errorf("revoke reason = %q", recs[2].Reason)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("audit log mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestReopenAfterRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer l.Close()
+
+	l.Log(Record{Event: EventVerify, Outcome: OutcomeDenied, Address: "0x1"})
+	rotated := filepath.Join(dir, "audit.log.1")
+	if err := os.Rename(path, rotated); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Reopen(); err != nil {
+		t.Fatalf("Reopen: %v", err)
+	}
+	l.Log(Record{Event: EventVerify, Outcome: OutcomeGranted, Address: "0x2"})
+
+	if recs := readRecords(t, rotated); len(recs) != 1 || recs[0].Address != "0x1" {
+		t.Errorf("rotated file holds %+v, want only the first record", recs)
+	}
+	if recs := readRecords(t, path); len(recs) != 1 || recs[0].Address != "0x2" {
+		t.Errorf("new file holds %+v, want only the second record", recs)
+	}
+}
+
+func TestNilLoggerDiscards(t *testing.T) {
+	var l *Logger
+	l.Log(Record{Event: EventVerify})
+	if err := l.Reopen(); err != nil {
+		t.Errorf("Reopen on nil logger: %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows && !plan9
+
+package audit
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+)
+
+// openSyslog sends each record as one message to the auth facility, which
+// syslog daemons commonly keep apart from general logs.
+func openSyslog() (io.WriteCloser, error) {
+	w, err := syslog.New(syslog.LOG_AUTH|syslog.LOG_INFO, "svpn-audit")
+	if err != nil {
+		return nil, fmt.Errorf("connecting to syslog: %w", err)
+	}
+	return w, nil
+}
@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package audit
+
+import (
+	"fmt"
+	"io"
+)
+
+// openSyslog reports that there is no syslog on this platform.
+func openSyslog() (io.WriteCloser, error) {
+	return nil, fmt.Errorf("%s audit log is not supported on this platform", SyslogTarget)
+}
@@ -1,20 +1,37 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"strings"
 	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/siwe"
 )
 
 // Config holds all gateway configuration.
 type Config struct {
 	// Server settings
-	ListenAddr string `json:"listen_addr"` // e.g. ":8080"
+	ListenAddr     string `json:"listen_addr"`      // e.g. ":8080"
+	GRPCListenAddr string `json:"grpc_listen_addr"` // e.g. ":9090"; empty disables the gRPC API
 
 	// Ethereum RPC endpoint for NFT ownership checks
 	EthereumRPC string `json:"ethereum_rpc"` // e.g. "https://ethereum-rpc.publicnode.com"
 
+	// Proxy for the gateway's own outbound calls (RPC, 6529/ZK/NFT APIs,
+	// webhooks): http://host:port or socks5://host:port. Empty connects
+	// directly, still honoring HTTP_PROXY/HTTPS_PROXY.
+	OutboundProxy string `json:"outbound_proxy,omitempty"`
+
+	// Most Ethereum RPC requests in flight at once, across checks,
+	// delegation, the registry and every contract manager; more wait their
+	// turn. 0 = unlimited.
+	RPCMaxConcurrency int `json:"rpc_max_concurrency"`
+
 	// Memes contract address (ERC-1155)
 	MemesContract string `json:"memes_contract"`
 
@@ -24,29 +41,168 @@ type Config struct {
 	// SIWE settings
 	SIWEDomain     string        `json:"siwe_domain"`      // e.g. "sovereignvpn.network"
 	SIWEUri        string        `json:"siwe_uri"`         // e.g. "https://sovereignvpn.network"
+	SIWEStatement  string        `json:"siwe_statement"`   // Wallet prompt text; empty uses the built-in statement; may use {node} and {region}
 	ChallengeTTL   time.Duration `json:"challenge_ttl"`    // How long a challenge is valid
 	NonceLength    int           `json:"nonce_length"`     // Length of random nonce (min 8)
 	CredentialTTL  time.Duration `json:"credential_ttl"`   // WireGuard credential validity
 	EnableFreeTier bool          `json:"enable_free_tier"` // Allow THIS-card holders to bypass payment
 
+	// TermsURL, if set, makes every sign-in accept the terms of service
+	// published there: the SIWE statement names them, so the signature is
+	// the acceptance. TermsSHA256 pins the version accepted.
+	TermsURL    string `json:"terms_url"`
+	TermsSHA256 string `json:"terms_sha256"` // hex SHA-256 of the terms document; optional
+
+	// MinConnectTTL is the least time a session must have left for
+	// /vpn/connect to provision a peer on it; a session closer to expiry is
+	// told to re-authenticate rather than get a tunnel that dies at once.
+	// Unset means a tenth of the session's lifetime (see MinConnectTTLFor);
+	// 0 turns the check off.
+	MinConnectTTL *time.Duration `json:"min_connect_ttl,omitempty"`
+
+	// A wallet denied within GracePeriod of the transfer watcher seeing it
+	// receive a card, before the checker reflects it (cache, max token ID
+	// lag), gets one paid-tier probationary session of GraceSessionTTL and is
+	// re-checked when that ends. 0 = off: deny by default.
+	GracePeriod     time.Duration `json:"grace_period"`
+	GraceSessionTTL time.Duration `json:"grace_session_ttl"`
+
 	// Rate limiting
 	RateLimitPerMinute int `json:"rate_limit_per_minute"` // Per-IP rate limit
+
+	// Per-identity limits, on top of the per-IP one. Challenges are counted
+	// per claimed address; verify and session endpoints per verified wallet
+	// (or anonymous nullifier). 0 = off.
+	ChallengeRateLimitPerMinute int `json:"challenge_rate_limit_per_minute"`
+	WalletRateLimitPerMinute    int `json:"wallet_rate_limit_per_minute"`
+
+	// Browser hardening headers on every response: nosniff, no-referrer,
+	// no framing and a CSP that allows no content. HSTS is separate and
+	// opt-in, as it breaks browsers on a plain-HTTP gateway: HSTSMaxAge > 0
+	// sends Strict-Transport-Security with that max-age.
+	SecurityHeaders bool          `json:"security_headers"`
+	HSTSMaxAge      time.Duration `json:"hsts_max_age"`
+
+	// PrivateLogs leaves per-connection lines (sign-ins, connects,
+	// disconnects, peer changes) out of the operational log. Those lines
+	// never name a wallet, but their timing still shows when the node is
+	// used. The audit log is unaffected; it is only written when enabled.
+	PrivateLogs bool `json:"private_logs"`
+
+	// Largest accepted request body; bigger bodies get 413. 0 = default (64KB).
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+
+	// Entry caps for the in-memory session store and the NFT, delegation and
+	// rep lookup caches. When full, the least recently used entry is evicted;
+	// sessions owning WireGuard peers are kept, and new sessions refused if
+	// all are. 0 = unlimited, except for the rep cache, which is never swept and
+	// falls back to its own default cap.
+	MaxSessions     int `json:"max_sessions"`
+	MaxCacheEntries int `json:"max_cache_entries"`
+
+	// WGBackend is how WireGuard peers are applied: "wg" (shell out, the
+	// default when empty), "netlink", "memory", or "none" for a
+	// discovery-only gateway that lists nodes and signs wallets in but
+	// provisions no tunnels itself.
+	WGBackend string `json:"wg_backend,omitempty"`
+
+	// Concurrent WireGuard peers (devices) per wallet, by tier. 0 = unlimited.
+	MaxPeersFree    int    `json:"max_peers_free"`
+	MaxPeersPaid    int    `json:"max_peers_paid"`
+	PeerLimitPolicy string `json:"peer_limit_policy"` // "evict_oldest" or "reject"
+
+	// Region this gateway serves, and the minimum tier per region. Regions
+	// mapped to "free" are reserved for free-tier (THIS card) holders.
+	Region      string            `json:"region"`       // e.g. "ch-zurich"
+	NodeName    string            `json:"node_name"`    // Node shown as {node} in siwe_statement; empty uses siwe_domain
+	RegionTiers map[string]string `json:"region_tiers"` // region -> "free" or "paid"
+
+	// Data quota per wallet and accounting period, by tier. 0 = unlimited.
+	QuotaFreeBytes uint64        `json:"quota_free_bytes"`
+	QuotaPaidBytes uint64        `json:"quota_paid_bytes"`
+	QuotaPeriod    time.Duration `json:"quota_period"`
+	QuotaAction    string        `json:"quota_action"` // "disconnect" (the default and only action)
+}
+
+// DefaultMaxBodyBytes is the request body limit when MaxBodyBytes is 0. The
+// largest legitimate body, an anonymous connect with its proof, is a few KB.
+const DefaultMaxBodyBytes = 64 << 10
+
+// Default in-memory store caps. A session or cache entry is well under 1KB,
+// so each store stays in the tens of MB even when full.
+const (
+	DefaultMaxSessions     = 100000
+	DefaultMaxCacheEntries = 100000
+)
+
+// DefaultRPCMaxConcurrency keeps bursts under the connection limits of
+// common hosted RPC plans.
+const DefaultRPCMaxConcurrency = 32
+
+// Peer limit policies: what happens when a wallet connects one device more
+// than its tier allows.
+const (
+	PeerLimitEvictOldest = "evict_oldest"
+	PeerLimitReject      = "reject"
+)
+
+// QuotaActionDisconnect removes a wallet's peers once it uses up its data
+// quota. It is the only quota action: the gateway has no traffic shaping to
+// throttle with.
+const QuotaActionDisconnect = "disconnect"
+
+// DefaultMinConnectDivisor sets MinConnectTTL, when unset, to this
+// fraction of a session's lifetime.
+const DefaultMinConnectDivisor = 10
+
+// MinConnectTTLFor returns the least time a session of the given lifetime
+// must have left for /vpn/connect: MinConnectTTL if set, otherwise
+// lifetime/DefaultMinConnectDivisor. 0 means no minimum.
+func (c *Config) MinConnectTTLFor(lifetime time.Duration) time.Duration {
+	if c.MinConnectTTL != nil {
+		return *c.MinConnectTTL
+	}
+	return lifetime / DefaultMinConnectDivisor
+}
+
+// Region tier requirements used in RegionTiers.
+const (
+	RegionTierFree = "free"
+	RegionTierPaid = "paid"
+)
+
+// RegionRequiresFree reports whether region is reserved for free-tier holders.
+func (c *Config) RegionRequiresFree(region string) bool {
+	return region != "" && c.RegionTiers[region] == RegionTierFree
 }
 
 // DefaultConfig returns a config with sensible defaults for development.
 func DefaultConfig() *Config {
 	return &Config{
-		ListenAddr:           ":8080",
-		EthereumRPC:          "https://ethereum-rpc.publicnode.com",
-		MemesContract:        "",
-		AccessPolicyContract: "",
-		SIWEDomain:           "6529vpn.io",
-		SIWEUri:              "https://6529vpn.io",
-		ChallengeTTL:         5 * time.Minute,
-		NonceLength:          16,
-		CredentialTTL:        24 * time.Hour,
-		EnableFreeTier:       false,
-		RateLimitPerMinute:   30,
+		ListenAddr:                  ":8080",
+		EthereumRPC:                 "https://ethereum-rpc.publicnode.com",
+		RPCMaxConcurrency:           DefaultRPCMaxConcurrency,
+		MemesContract:               "",
+		AccessPolicyContract:        "",
+		SIWEDomain:                  "6529vpn.io",
+		SIWEUri:                     "https://6529vpn.io",
+		ChallengeTTL:                5 * time.Minute,
+		NonceLength:                 16,
+		CredentialTTL:               24 * time.Hour,
+		EnableFreeTier:              false,
+		GraceSessionTTL:             10 * time.Minute,
+		RateLimitPerMinute:          30,
+		ChallengeRateLimitPerMinute: 10,
+		SecurityHeaders:             true,
+		WalletRateLimitPerMinute:    20,
+		MaxBodyBytes:                DefaultMaxBodyBytes,
+		MaxSessions:                 DefaultMaxSessions,
+		MaxCacheEntries:             DefaultMaxCacheEntries,
+		MaxPeersFree:                1,
+		MaxPeersPaid:                5,
+		PeerLimitPolicy:             PeerLimitEvictOldest,
+		QuotaPeriod:                 30 * 24 * time.Hour,
+		QuotaAction:                 QuotaActionDisconnect,
 	}
 }
 
@@ -66,6 +222,39 @@ func LoadFromFile(path string) (*Config, error) {
 	return cfg, nil
 }
 
+// ValidateTerms checks terms_url and terms_sha256. Validate calls it; it is
+// separate for direct mode, which skips the rest of Validate.
+func (c *Config) ValidateTerms() error {
+	if c.TermsURL == "" {
+		if c.TermsSHA256 != "" {
+			return fmt.Errorf("terms_sha256 needs terms_url")
+		}
+		return nil
+	}
+	if u, err := url.Parse(c.TermsURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || strings.ContainsAny(c.TermsURL, " \t\r\n") {
+		return fmt.Errorf("terms_url must be an http(s) URL")
+	}
+	if c.TermsSHA256 != "" {
+		if b, err := hex.DecodeString(c.TermsSHA256); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("terms_sha256 must be %d hex characters", 2*sha256.Size)
+		}
+	}
+	return nil
+}
+
+// ValidateSIWEStatement checks siwe_statement with siwe.ValidateStatement,
+// and that region is set if the statement names it. Validate calls it; it
+// is separate for dev and direct mode, which skip the rest of Validate.
+func (c *Config) ValidateSIWEStatement() error {
+	if err := siwe.ValidateStatement(c.SIWEStatement); err != nil {
+		return fmt.Errorf("siwe_statement: %w", err)
+	}
+	if strings.Contains(c.SIWEStatement, siwe.StatementRegion) && c.Region == "" {
+		return fmt.Errorf("siwe_statement uses %s but region is not set", siwe.StatementRegion)
+	}
+	return nil
+}
+
 // Validate checks that required fields are set.
 func (c *Config) Validate() error {
 	if c.MemesContract == "" {
@@ -77,6 +266,65 @@ func (c *Config) Validate() error {
 	if c.EthereumRPC == "" {
 		return fmt.Errorf("ethereum_rpc is required")
 	}
+	if err := c.ValidateSIWEStatement(); err != nil {
+		return err
+	}
+	if err := c.ValidateTerms(); err != nil {
+		return err
+	}
+	if c.ChallengeRateLimitPerMinute < 0 || c.WalletRateLimitPerMinute < 0 {
+		return fmt.Errorf("challenge_rate_limit_per_minute and wallet_rate_limit_per_minute must be >= 0")
+	}
+	if c.MaxPeersFree < 0 || c.MaxPeersPaid < 0 {
+		return fmt.Errorf("max_peers_free and max_peers_paid must be >= 0")
+	}
+	switch c.PeerLimitPolicy {
+	case "", PeerLimitEvictOldest, PeerLimitReject:
+	default:
+		return fmt.Errorf("peer_limit_policy must be %q or %q", PeerLimitEvictOldest, PeerLimitReject)
+	}
+	for region, tier := range c.RegionTiers {
+		if tier != RegionTierFree && tier != RegionTierPaid {
+			return fmt.Errorf("region_tiers[%s] must be %q or %q", region, RegionTierFree, RegionTierPaid)
+		}
+	}
+	switch c.QuotaAction {
+	case "", QuotaActionDisconnect:
+	default:
+		return fmt.Errorf("quota_action must be %q", QuotaActionDisconnect)
+	}
+	if c.RPCMaxConcurrency < 0 {
+		return fmt.Errorf("rpc_max_concurrency must be >= 0")
+	}
+	if c.MaxBodyBytes < 0 {
+		return fmt.Errorf("max_body_bytes must be >= 0")
+	}
+	if c.GracePeriod < 0 || c.GraceSessionTTL < 0 {
+		return fmt.Errorf("grace_period and grace_session_ttl must be >= 0")
+	}
+	if c.GracePeriod > 0 && c.GraceSessionTTL == 0 {
+		return fmt.Errorf("grace_session_ttl must be > 0 when grace_period is set")
+	}
+	if c.MinConnectTTL != nil {
+		if *c.MinConnectTTL < 0 {
+			return fmt.Errorf("min_connect_ttl must be >= 0")
+		}
+		if *c.MinConnectTTL >= c.CredentialTTL {
+			return fmt.Errorf("min_connect_ttl must be shorter than credential_ttl")
+		}
+		if c.GracePeriod > 0 && *c.MinConnectTTL >= c.GraceSessionTTL {
+			return fmt.Errorf("min_connect_ttl must be shorter than grace_session_ttl when grace_period is set")
+		}
+	}
+	if c.HSTSMaxAge < 0 {
+		return fmt.Errorf("hsts_max_age must be >= 0")
+	}
+	if c.MaxSessions < 0 || c.MaxCacheEntries < 0 {
+		return fmt.Errorf("max_sessions and max_cache_entries must be >= 0")
+	}
+	if c.QuotaPeriod < 0 {
+		return fmt.Errorf("quota_period must be >= 0")
+	}
 	if c.NonceLength < 8 {
 		return fmt.Errorf("nonce_length must be >= 8")
 	}
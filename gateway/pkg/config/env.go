@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Configuration precedence, highest first:
+//
+//	command-line flag > SVPN_* environment variable > config file > default
+//
+// Secrets (private keys, tokens, database URLs) should come from the
+// environment: values passed as flags are visible to anyone who can list
+// processes.
+
+// EnvPrefix is prepended to every environment override.
+const EnvPrefix = "SVPN_"
+
+// Environment variables for secrets that are not part of Config. Each is
+// read by SecretFromEnv, which also honors the older unprefixed name.
+const (
+	EnvSessionKey      = EnvPrefix + "SESSION_KEY"
+	EnvHeartbeatKey    = EnvPrefix + "HEARTBEAT_KEY"
+	EnvWebhookSecret   = EnvPrefix + "WEBHOOK_SECRET"
+	EnvAdminToken      = EnvPrefix + "ADMIN_TOKEN"
+	EnvEnrollmentDBURL = EnvPrefix + "ENROLLMENT_DATABASE_URL"
+	EnvNFTAPIURL       = EnvPrefix + "NFT_API_URL"
+	EnvZKAPIKey        = EnvPrefix + "ZK_API_KEY"
+
+	// Keystore passphrases for --session-key-file / --heartbeat-key-file.
+	EnvSessionKeyPassword   = EnvPrefix + "SESSION_KEY_PASSWORD"
+	EnvHeartbeatKeyPassword = EnvPrefix + "HEARTBEAT_KEY_PASSWORD"
+)
+
+// LookupFunc matches os.LookupEnv.
+type LookupFunc func(key string) (string, bool)
+
+// ApplyEnv overrides config fields from SVPN_* environment variables. Call it
+// after loading the file and before applying flags.
+func (c *Config) ApplyEnv(lookup LookupFunc) error {
+	str := func(name string, dst *string) {
+		if v, ok := lookup(EnvPrefix + name); ok && v != "" {
+			*dst = v
+		}
+	}
+
+	str("LISTEN_ADDR", &c.ListenAddr)
+	str("GRPC_LISTEN_ADDR", &c.GRPCListenAddr)
+	str("ETH_RPC", &c.EthereumRPC)
+	str("OUTBOUND_PROXY", &c.OutboundProxy)
+	str("MEMES_CONTRACT", &c.MemesContract)
+	str("ACCESS_POLICY_CONTRACT", &c.AccessPolicyContract)
+	str("SIWE_STATEMENT", &c.SIWEStatement)
+	str("REGION", &c.Region)
+	str("NODE_NAME", &c.NodeName)
+	str("TERMS_URL", &c.TermsURL)
+	str("TERMS_SHA256", &c.TermsSHA256)
+	str("WG_BACKEND", &c.WGBackend)
+	if v, ok := lookup(EnvPrefix + "SIWE_DOMAIN"); ok && v != "" {
+		c.SIWEDomain = v
+		c.SIWEUri = "https://" + v
+	}
+	str("SIWE_URI", &c.SIWEUri)
+
+	boolean := func(name string, dst *bool) error {
+		if v, ok := lookup(EnvPrefix + name); ok && v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s%s: %w", EnvPrefix, name, err)
+			}
+			*dst = b
+		}
+		return nil
+	}
+	if err := boolean("ENABLE_FREE_TIER", &c.EnableFreeTier); err != nil {
+		return err
+	}
+	return boolean("PRIVATE_LOGS", &c.PrivateLogs)
+}
+
+// SecretFromEnv returns the value of the SVPN_-prefixed variable name, or of
+// its legacy unprefixed form (e.g. SESSION_KEY), or "" if neither is set.
+func SecretFromEnv(lookup LookupFunc, name string) string {
+	if v, ok := lookup(name); ok && v != "" {
+		return v
+	}
+	if v, ok := lookup(name[len(EnvPrefix):]); ok && v != "" {
+		return v
+	}
+	return ""
+}
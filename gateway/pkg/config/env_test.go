@@ -0,0 +1,81 @@
+package config
+
+import "testing"
+
+func mapLookup(env map[string]string) LookupFunc {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func TestApplyEnvOverridesFile(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.EthereumRPC = "https://from-file.example"
+	cfg.MemesContract = "0xfile"
+
+	err := cfg.ApplyEnv(mapLookup(map[string]string{
+		"SVPN_ETH_RPC":          "https://from-env.example",
+		"SVPN_SIWE_DOMAIN":      "vpn.example",
+		"SVPN_ENABLE_FREE_TIER": "true",
+		"SVPN_MEMES_CONTRACT":   "", // empty does not clear the file value
+		"SVPN_OUTBOUND_PROXY":   "socks5://127.0.0.1:1080",
+		"SVPN_PRIVATE_LOGS":     "1",
+		"SVPN_WG_BACKEND":       "none",
+	}))
+	if err != nil {
+		t.Fatalf("ApplyEnv: %v", err)
+	}
+
+	if cfg.EthereumRPC != "https://from-env.example" {
+		t.Errorf("EthereumRPC = %q, want env value", cfg.EthereumRPC)
+	}
+	if cfg.OutboundProxy != "socks5://127.0.0.1:1080" {
+		t.Errorf("OutboundProxy = %q, want env value", cfg.OutboundProxy)
+	}
+	if cfg.MemesContract != "0xfile" {
+		t.Errorf("MemesContract = %q, want file value kept", cfg.MemesContract)
+	}
+	if cfg.SIWEDomain != "vpn.example" || cfg.SIWEUri != "https://vpn.example" {
+		t.Errorf("SIWE domain/uri = %q/%q", cfg.SIWEDomain, cfg.SIWEUri)
+	}
+	if !cfg.EnableFreeTier {
+		t.Error("expected EnableFreeTier from env")
+	}
+	if !cfg.PrivateLogs {
+		t.Error("expected PrivateLogs from env")
+	}
+	if cfg.WGBackend != "none" {
+		t.Errorf("WGBackend = %q, want env value", cfg.WGBackend)
+	}
+	if cfg.ListenAddr != ":8080" {
+		t.Errorf("ListenAddr = %q, want default kept", cfg.ListenAddr)
+	}
+}
+
+func TestApplyEnvRejectsBadBool(t *testing.T) {
+	cfg := DefaultConfig()
+	if err := cfg.ApplyEnv(mapLookup(map[string]string{"SVPN_ENABLE_FREE_TIER": "maybe"})); err == nil {
+		t.Fatal("expected error for invalid boolean")
+	}
+}
+
+func TestSecretFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"prefixed", map[string]string{"SVPN_SESSION_KEY": "new"}, "new"},
+		{"legacy", map[string]string{"SESSION_KEY": "old"}, "old"},
+		{"prefixed wins", map[string]string{"SVPN_SESSION_KEY": "new", "SESSION_KEY": "old"}, "new"},
+		{"unset", map[string]string{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SecretFromEnv(mapLookup(tt.env), EnvSessionKey); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+// Package connlog writes the gateway's per-connection log lines: sign-ins,
+// sessions, connects, disconnects and peer changes. These never carry a
+// wallet address, but their timing is still a record of when people use the
+// node, so an operator can turn them off with SetEnabled(false) and keep only
+// startup, error and aggregate lines. The audit log is separate and is only
+// written when the operator enables it.
+package connlog
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+var disabled atomic.Bool
+
+// SetEnabled turns per-connection log lines on (the default) or off.
+func SetEnabled(on bool) {
+	disabled.Store(!on)
+}
+
+// Enabled reports whether per-connection log lines are written.
+func Enabled() bool {
+	return !disabled.Load()
+}
+
+// Printf logs a per-connection line to the standard logger, unless
+// per-connection lines are turned off. Never pass it a wallet address.
+func Printf(format string, args ...any) {
+	if disabled.Load() {
+		return
+	}
+	log.Output(2, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,30 @@
+package connlog
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSetEnabledSilencesPrintf(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		SetEnabled(true)
+	})
+
+	Printf("VPN connected: tier=%s", "paid")
+	SetEnabled(false)
+	Printf("VPN connected: tier=%s", "free")
+
+	out := buf.String()
+	if !strings.Contains(out, "tier=paid") {
+		t.Errorf("enabled line missing from %q", out)
+	}
+	if strings.Contains(out, "tier=free") {
+		t.Errorf("disabled line written: %q", out)
+	}
+}
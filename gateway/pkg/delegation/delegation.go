@@ -22,7 +22,10 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/janitor"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/lru"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/metrics"
 )
 
 // DelegateXYZV2 is the delegate.xyz v2 registry address (same on all chains).
@@ -36,7 +39,7 @@ var Registry6529 = common.HexToAddress("0x2202CB9c00487e7e8EF21e6d8E914B32e709f4
 // Config holds delegation checker configuration.
 type Config struct {
 	// Ethereum client (shared with nftcheck)
-	Client *ethclient.Client
+	Client ethereum.ContractCaller
 
 	// Which registries to check
 	EnableDelegateXYZ bool
@@ -47,12 +50,16 @@ type Config struct {
 
 	// Cache TTL for delegation lookups
 	CacheTTL time.Duration
+
+	// Maximum cached hot wallets; the least recently used is evicted when
+	// full. 0 means unlimited.
+	MaxCacheEntries int
 }
 
 // Checker queries delegation registries to find cold wallets that have
 // delegated to a given hot wallet.
 type Checker struct {
-	client        *ethclient.Client
+	client        ethereum.ContractCaller
 	memesContract common.Address
 	enableDXYZ    bool
 	enable6529    bool
@@ -61,8 +68,9 @@ type Checker struct {
 	dxyzABI       abi.ABI
 	r6529ABI      abi.ABI
 	cacheTTL      time.Duration
-	mu            sync.RWMutex
-	cache         map[common.Address]cacheEntry
+	mu            sync.Mutex
+	cache         *lru.Cache[common.Address, cacheEntry]
+	stopSweep     func()
 }
 
 type cacheEntry struct {
@@ -151,27 +159,35 @@ func NewChecker(cfg Config) (*Checker, error) {
 		dxyzABI:       dxyzABI,
 		r6529ABI:      r6529ABI,
 		cacheTTL:      cfg.CacheTTL,
-		cache:         make(map[common.Address]cacheEntry),
+		cache:         lru.New[common.Address, cacheEntry](cfg.MaxCacheEntries),
 	}
 
 	if c.cacheTTL == 0 {
 		c.cacheTTL = 5 * time.Minute
 	}
 
-	go c.cleanup()
+	c.stopSweep = janitor.Register("delegation cache", c.removeExpired)
 	return c, nil
 }
 
+// Close stops the periodic cleanup of expired cache entries.
+func (c *Checker) Close() {
+	c.stopSweep()
+}
+
 // FindVaults returns all cold wallet addresses that have delegated to the
 // given hot wallet. Returns an empty slice if no delegations are found.
 func (c *Checker) FindVaults(ctx context.Context, hotWallet common.Address) ([]common.Address, error) {
 	// Check cache first
-	c.mu.RLock()
-	if entry, ok := c.cache[hotWallet]; ok && time.Now().Before(entry.expiresAt) {
-		c.mu.RUnlock()
-		return entry.vaults, nil
+	c.mu.Lock()
+	if entry, ok := c.cache.Get(hotWallet); ok {
+		if time.Now().Before(entry.expiresAt) {
+			c.mu.Unlock()
+			return entry.vaults, nil
+		}
+		c.cache.Remove(hotWallet)
 	}
-	c.mu.RUnlock()
+	c.mu.Unlock()
 
 	var allVaults []common.Address
 
@@ -198,10 +214,10 @@ func (c *Checker) FindVaults(ctx context.Context, hotWallet common.Address) ([]c
 
 	// Cache the result
 	c.mu.Lock()
-	c.cache[hotWallet] = cacheEntry{
+	c.cache.Add(hotWallet, cacheEntry{
 		vaults:    allVaults,
 		expiresAt: time.Now().Add(c.cacheTTL),
-	}
+	}, nil)
 	c.mu.Unlock()
 
 	return allVaults, nil
@@ -216,10 +232,12 @@ func (c *Checker) find6529Vaults(ctx context.Context, hotWallet common.Address)
 		return nil, fmt.Errorf("packing 6529 call: %w", err)
 	}
 
+	start := time.Now()
 	output, err := c.client.CallContract(ctx, ethereum.CallMsg{
 		To:   &c.r6529Addr,
 		Data: callData,
 	}, nil)
+	metrics.ObserveRPC("retrieveDelegationAddresses", start, err)
 	if err != nil {
 		return nil, fmt.Errorf("calling 6529 registry: %w", err)
 	}
@@ -249,10 +267,12 @@ func (c *Checker) findDelegateXYZVaults(ctx context.Context, hotWallet common.Ad
 		return nil, fmt.Errorf("packing delegate.xyz call: %w", err)
 	}
 
+	start := time.Now()
 	output, err := c.client.CallContract(ctx, ethereum.CallMsg{
 		To:   &c.dxyzAddr,
 		Data: callData,
 	}, nil)
+	metrics.ObserveRPC("getIncomingDelegations", start, err)
 	if err != nil {
 		return nil, fmt.Errorf("calling delegate.xyz: %w", err)
 	}
@@ -309,23 +329,24 @@ func (c *Checker) findDelegateXYZVaults(ctx context.Context, hotWallet common.Ad
 // Invalidate removes cached delegation data for a hot wallet.
 func (c *Checker) Invalidate(hotWallet common.Address) {
 	c.mu.Lock()
-	delete(c.cache, hotWallet)
+	c.cache.Remove(hotWallet)
 	c.mu.Unlock()
 }
 
-func (c *Checker) cleanup() {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-	for range ticker.C {
-		c.mu.Lock()
-		now := time.Now()
-		for addr, entry := range c.cache {
-			if now.After(entry.expiresAt) {
-				delete(c.cache, addr)
-			}
-		}
-		c.mu.Unlock()
-	}
+// CacheSize returns the number of cached hot wallets.
+func (c *Checker) CacheSize() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cache.Len()
+}
+
+// removeExpired drops cache entries past their TTL. Run by the janitor.
+func (c *Checker) removeExpired(now time.Time) {
+	c.mu.Lock()
+	c.cache.RemoveFunc(func(_ common.Address, entry cacheEntry) bool {
+		return now.After(entry.expiresAt)
+	})
+	c.mu.Unlock()
 }
 
 func dedupe(addrs []common.Address) []common.Address {
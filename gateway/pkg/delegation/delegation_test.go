@@ -215,6 +215,30 @@ func TestFindVaultsCaching(t *testing.T) {
 	}
 }
 
+func TestFindVaultsCacheCap(t *testing.T) {
+	rpc := mock6529RPC(map[common.Address][]common.Address{})
+	defer rpc.Close()
+
+	client, _ := ethclient.Dial(rpc.URL)
+	defer client.Close()
+
+	checker, _ := NewChecker(Config{
+		Client:          client,
+		Enable6529:      true,
+		CacheTTL:        time.Minute,
+		MaxCacheEntries: 4,
+	})
+
+	for i := 0; i < 20; i++ {
+		if _, err := checker.FindVaults(context.Background(), common.BigToAddress(big.NewInt(int64(i+1)))); err != nil {
+			t.Fatalf("FindVaults: %v", err)
+		}
+	}
+	if n := checker.CacheSize(); n != 4 {
+		t.Fatalf("CacheSize = %d, want 4", n)
+	}
+}
+
 func TestDedupe(t *testing.T) {
 	addr1 := common.HexToAddress("0x1111111111111111111111111111111111111111")
 	addr2 := common.HexToAddress("0x2222222222222222222222222222222222222222")
@@ -0,0 +1,63 @@
+// Package ethrpc defines the Ethereum client the on-chain packages depend
+// on, so they can be unit tested against Fake instead of a JSON-RPC mock
+// server. *ethclient.Client satisfies it.
+package ethrpc
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// Caller reads contract state and owns the connection.
+type Caller interface {
+	ethereum.ContractCaller
+	Close()
+}
+
+// Transactor prices, sends and follows transactions.
+type Transactor interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// Subscriber watches logs and new blocks and owns the connection.
+type Subscriber interface {
+	SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error)
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	Close()
+}
+
+// Client is every part of ethclient.Client the gateway uses. Packages take
+// the narrowest of the interfaces above that they need.
+type Client interface {
+	Caller
+	Transactor
+	Subscriber
+}
+
+var _ Client = (*ethclient.Client)(nil)
+
+// Unpack decodes the output of a call to method and checks the number of
+// values, so a malformed RPC response surfaces as an error rather than an
+// index panic.
+func Unpack(contract abi.ABI, method string, output []byte, want int) ([]interface{}, error) {
+	results, err := contract.Unpack(method, output)
+	if err != nil {
+		return nil, fmt.Errorf("unpacking %s: %w", method, err)
+	}
+	if len(results) != want {
+		return nil, fmt.Errorf("unpacking %s: expected %d result(s), got %d", method, want, len(results))
+	}
+	return results, nil
+}
@@ -0,0 +1,31 @@
+package ethrpc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestUnpackChecksResultCount(t *testing.T) {
+	contract, err := abi.JSON(strings.NewReader(`[{"type":"function","name":"count","inputs":[],"outputs":[{"name":"","type":"uint256"}],"stateMutability":"view"}]`))
+	if err != nil {
+		t.Fatalf("parsing ABI: %v", err)
+	}
+
+	output := hexutil.MustDecode("0x" + strings.Repeat("00", 31) + "07")
+	if _, err := Unpack(contract, "count", output, 2); err == nil {
+		t.Fatal("expected error when result count does not match")
+	}
+	if _, err := Unpack(contract, "count", []byte{0x01}, 1); err == nil {
+		t.Fatal("expected error decoding truncated output")
+	}
+	results, err := Unpack(contract, "count", output, 1)
+	if err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+}
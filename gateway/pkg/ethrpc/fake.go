@@ -0,0 +1,249 @@
+package ethrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// MalformedOutputs are eth_call results no contract method's return values
+// decode from: empty, or not a whole number of 32-byte words. Tests answer
+// every read with each to check it fails with an error rather than a panic.
+var MalformedOutputs = map[string][]byte{
+	"empty":           {},
+	"short":           {0xde, 0xad, 0xbe, 0xef},
+	"word and a byte": append(bytes.Repeat([]byte{0xff}, 32), 0x01),
+	"long noise":      bytes.Repeat([]byte{0xa5}, 200),
+}
+
+// CallHandler answers an eth_call with the ABI-encoded return data.
+type CallHandler func(call ethereum.CallMsg) ([]byte, error)
+
+// Fake is an in-memory Client for tests. Contract reads go to the
+// handler registered for the target address; sent transactions are
+// recorded and bump the pending nonce. It is safe for concurrent use.
+type Fake struct {
+	mu       sync.Mutex
+	handlers map[common.Address]CallHandler
+	head     uint64
+	nonce    uint64
+	gasPrice *big.Int
+	gas      uint64
+	sendErr  error
+	sent     []*types.Transaction
+	subs     []*fakeLogSub
+	heads    []*fakeHeadSub
+	closed   bool
+}
+
+type fakeLogSub struct {
+	query ethereum.FilterQuery
+	ch    chan<- types.Log
+	done  chan struct{}
+}
+
+type fakeHeadSub struct {
+	ch   chan<- *types.Header
+	done chan struct{}
+}
+
+// NewFake returns a Fake at block 1 with a 1 gwei gas price.
+func NewFake() *Fake {
+	return &Fake{
+		handlers: make(map[common.Address]CallHandler),
+		head:     1,
+		gasPrice: big.NewInt(1_000_000_000),
+		gas:      100_000,
+	}
+}
+
+// HandleCalls routes eth_call requests to contract to h.
+func (f *Fake) HandleCalls(contract common.Address, h CallHandler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers[contract] = h
+}
+
+// SetBlockNumber sets the head reported by BlockNumber and announces it to
+// new-head subscribers.
+func (f *Fake) SetBlockNumber(n uint64) {
+	f.mu.Lock()
+	f.head = n
+	heads := slices.Clone(f.heads)
+	f.mu.Unlock()
+	for _, s := range heads {
+		select {
+		case s.ch <- &types.Header{Number: new(big.Int).SetUint64(n)}:
+		case <-s.done:
+		}
+	}
+}
+
+// FailSends makes SendTransaction return err; nil restores success.
+func (f *Fake) FailSends(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sendErr = err
+}
+
+// Sent returns the transactions sent so far.
+func (f *Fake) Sent() []*types.Transaction {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.sent)
+}
+
+// Closed reports whether Close has been called.
+func (f *Fake) Closed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+// EmitLog delivers l to every log subscription whose query matches its
+// address and first topic.
+func (f *Fake) EmitLog(l types.Log) {
+	f.mu.Lock()
+	subs := slices.Clone(f.subs)
+	f.mu.Unlock()
+	for _, s := range subs {
+		if !matches(s.query, l) {
+			continue
+		}
+		select {
+		case s.ch <- l:
+		case <-s.done:
+		}
+	}
+}
+
+func matches(q ethereum.FilterQuery, l types.Log) bool {
+	if len(q.Addresses) > 0 && !slices.Contains(q.Addresses, l.Address) {
+		return false
+	}
+	if len(q.Topics) > 0 && len(q.Topics[0]) > 0 {
+		return len(l.Topics) > 0 && slices.Contains(q.Topics[0], l.Topics[0])
+	}
+	return true
+}
+
+// CallContract implements Client.
+func (f *Fake) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if call.To == nil {
+		return nil, errors.New("fake: eth_call without a target")
+	}
+	f.mu.Lock()
+	h, ok := f.handlers[*call.To]
+	f.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("fake: no handler for calls to %s", call.To.Hex())
+	}
+	return h(call)
+}
+
+// PendingNonceAt implements Client. All accounts share one nonce.
+func (f *Fake) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.nonce, nil
+}
+
+// SuggestGasPrice implements Client.
+func (f *Fake) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return new(big.Int).Set(f.gasPrice), nil
+}
+
+// EstimateGas implements Client.
+func (f *Fake) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.gas, nil
+}
+
+// SendTransaction implements Client.
+func (f *Fake) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sendErr != nil {
+		return f.sendErr
+	}
+	f.sent = append(f.sent, tx)
+	f.nonce++
+	return nil
+}
+
+// TransactionReceipt implements Client: a sent transaction has a
+// successful receipt at the current head, any other ethereum.NotFound.
+func (f *Fake) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, tx := range f.sent {
+		if tx.Hash() == txHash {
+			return &types.Receipt{
+				Status:      types.ReceiptStatusSuccessful,
+				TxHash:      txHash,
+				BlockNumber: new(big.Int).SetUint64(f.head),
+			}, nil
+		}
+	}
+	return nil, ethereum.NotFound
+}
+
+// SubscribeFilterLogs implements Client; logs arrive through
+// EmitLog.
+func (f *Fake) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	s := &fakeLogSub{query: q, ch: ch, done: make(chan struct{})}
+	f.mu.Lock()
+	f.subs = append(f.subs, s)
+	f.mu.Unlock()
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		close(s.done)
+		f.mu.Lock()
+		f.subs = slices.DeleteFunc(f.subs, func(other *fakeLogSub) bool { return other == s })
+		f.mu.Unlock()
+		return nil
+	}), nil
+}
+
+// SubscribeNewHead implements Client; heads arrive through
+// SetBlockNumber.
+func (f *Fake) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	s := &fakeHeadSub{ch: ch, done: make(chan struct{})}
+	f.mu.Lock()
+	f.heads = append(f.heads, s)
+	f.mu.Unlock()
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		close(s.done)
+		f.mu.Lock()
+		f.heads = slices.DeleteFunc(f.heads, func(other *fakeHeadSub) bool { return other == s })
+		f.mu.Unlock()
+		return nil
+	}), nil
+}
+
+// BlockNumber implements Client.
+func (f *Fake) BlockNumber(ctx context.Context) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.head, nil
+}
+
+// Close implements Client.
+func (f *Fake) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+}
@@ -0,0 +1,168 @@
+// Package feedback aggregates the connection quality clients report after
+// using a node into a score that discovery can rank nodes by.
+//
+// Each reporter (a wallet, or an anonymous session's nullifier) counts once:
+// a new report replaces its previous one, so a single user cannot move the
+// score by reporting repeatedly. Reports are kept in memory for a rolling
+// window and are lost on restart.
+package feedback
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/lru"
+)
+
+const (
+	// DefaultWindow is how long a report counts towards the score.
+	DefaultWindow = 7 * 24 * time.Hour
+
+	// MinReports is how many reporters a node needs before it publishes a
+	// score, so no single user decides it.
+	MinReports = 3
+
+	// DefaultMaxReporters caps the reporters remembered; the least recently
+	// reporting is dropped first.
+	DefaultMaxReporters = 10000
+)
+
+// Report is one client's view of a session on the node.
+type Report struct {
+	LatencyMS      float64 `json:"latency_ms"`                // round trip to the gateway
+	FailureRate    float64 `json:"failure_rate"`              // fraction of probe requests that failed, 0 to 1
+	ThroughputKbps float64 `json:"throughput_kbps,omitempty"` // 0 if not measured
+}
+
+// Validate rejects values no real measurement produces.
+func (r Report) Validate() error {
+	switch {
+	case !finite(r.LatencyMS) || r.LatencyMS <= 0 || r.LatencyMS > 60000:
+		return fmt.Errorf("latency_ms must be between 0 and 60000")
+	case !finite(r.FailureRate) || r.FailureRate < 0 || r.FailureRate > 1:
+		return fmt.Errorf("failure_rate must be between 0 and 1")
+	case !finite(r.ThroughputKbps) || r.ThroughputKbps < 0 || r.ThroughputKbps > 100e6:
+		return fmt.Errorf("throughput_kbps must be between 0 and 100000000")
+	}
+	return nil
+}
+
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// Quality is a node's aggregated feedback: the median of each measurement
+// over the window's reporters, and a 0-100 score from latency and failure
+// rate. Throughput mostly reflects the user's own link, so it is reported
+// but not scored.
+type Quality struct {
+	Reports        int     `json:"reports"`
+	LatencyMS      float64 `json:"latency_ms"`
+	FailureRate    float64 `json:"failure_rate"`
+	ThroughputKbps float64 `json:"throughput_kbps,omitempty"`
+	Score          int     `json:"score"`
+}
+
+// Store keeps the latest report from each reporter.
+type Store struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	reports *lru.Cache[string, entry]
+	clock   clock.Clock
+}
+
+type entry struct {
+	report Report
+	at     time.Time
+}
+
+// NewStore creates a store counting reports for window (DefaultWindow if
+// zero) from at most maxReporters reporters (DefaultMaxReporters if zero).
+func NewStore(window time.Duration, maxReporters int) *Store {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	if maxReporters <= 0 {
+		maxReporters = DefaultMaxReporters
+	}
+	return &Store{
+		window:  window,
+		reports: lru.New[string, entry](maxReporters),
+		clock:   clock.Real{},
+	}
+}
+
+// SetClock replaces the clock used to stamp and age out reports.
+func (s *Store) SetClock(c clock.Clock) {
+	s.mu.Lock()
+	s.clock = c
+	s.mu.Unlock()
+}
+
+// Add records r as reporter's current report, replacing any earlier one.
+func (s *Store) Add(reporter string, r Report) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reports.Add(reporter, entry{report: r, at: s.clock.Now()}, nil)
+}
+
+// Quality returns the aggregate over the window, or nil while fewer than
+// MinReports reporters have reported.
+func (s *Store) Quality() *Quality {
+	s.mu.Lock()
+	cutoff := s.clock.Now().Add(-s.window)
+	var latency, failures, throughput []float64
+	s.reports.Range(func(_ string, e entry) bool {
+		if e.at.Before(cutoff) {
+			return true
+		}
+		latency = append(latency, e.report.LatencyMS)
+		failures = append(failures, e.report.FailureRate)
+		if e.report.ThroughputKbps > 0 {
+			throughput = append(throughput, e.report.ThroughputKbps)
+		}
+		return true
+	})
+	s.mu.Unlock()
+
+	if len(latency) < MinReports {
+		return nil
+	}
+	q := &Quality{
+		Reports:        len(latency),
+		LatencyMS:      median(latency),
+		FailureRate:    median(failures),
+		ThroughputKbps: median(throughput),
+	}
+	q.Score = score(q.LatencyMS, q.FailureRate)
+	return q
+}
+
+// score weighs latency and failure rate equally. Latency scores full marks
+// up to 50ms and nothing from 500ms; failures score nothing from 10%.
+func score(latencyMS, failureRate float64) int {
+	latencyPart := 1 - (latencyMS-50)/450
+	failurePart := 1 - failureRate*10
+	return int(math.Round(50*clamp01(latencyPart) + 50*clamp01(failurePart)))
+}
+
+func clamp01(f float64) float64 {
+	return math.Min(1, math.Max(0, f))
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	slices.Sort(values)
+	mid := len(values) / 2
+	if len(values)%2 == 1 {
+		return values[mid]
+	}
+	return (values[mid-1] + values[mid]) / 2
+}
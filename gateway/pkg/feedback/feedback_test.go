@@ -0,0 +1,77 @@
+package feedback
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+)
+
+func TestQualityCountsEachReporterOnce(t *testing.T) {
+	s := NewStore(time.Hour, 0)
+	clk := clock.NewFake(time.Now())
+	s.SetClock(clk)
+
+	s.Add("a", Report{LatencyMS: 40, FailureRate: 0})
+	s.Add("b", Report{LatencyMS: 60, FailureRate: 0.01, ThroughputKbps: 50000})
+	// Repeated reports from one reporter replace each other.
+	for range 10 {
+		s.Add("b", Report{LatencyMS: 5000, FailureRate: 1})
+	}
+	if q := s.Quality(); q != nil {
+		t.Fatalf("published a score from %d reporters: %+v", q.Reports, q)
+	}
+
+	s.Add("c", Report{LatencyMS: 80, FailureRate: 0})
+	q := s.Quality()
+	if q == nil {
+		t.Fatal("no score with MinReports reporters")
+	}
+	if q.Reports != 3 || q.LatencyMS != 80 || q.FailureRate != 0 || q.ThroughputKbps != 0 {
+		t.Errorf("unexpected quality: %+v", q)
+	}
+	if q.Score != score(80, 0) {
+		t.Errorf("score = %d, want %d", q.Score, score(80, 0))
+	}
+
+	// Reports age out of the window.
+	clk.Advance(30 * time.Minute)
+	s.Add("d", Report{LatencyMS: 40, FailureRate: 0})
+	clk.Advance(31 * time.Minute)
+	if q := s.Quality(); q != nil {
+		t.Errorf("expired reports still counted: %+v", q)
+	}
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		latency, loss float64
+		want          int
+	}{
+		{20, 0, 100},
+		{500, 0, 50},
+		{50, 0.1, 50},
+		{1000, 0.5, 0},
+		{275, 0.05, 50},
+	}
+	for _, tt := range tests {
+		if got := score(tt.latency, tt.loss); got != tt.want {
+			t.Errorf("score(%v, %v) = %d, want %d", tt.latency, tt.loss, got, tt.want)
+		}
+	}
+}
+
+func TestReportValidate(t *testing.T) {
+	if err := (Report{LatencyMS: 30, FailureRate: 0.02, ThroughputKbps: 1000}).Validate(); err != nil {
+		t.Errorf("valid report rejected: %v", err)
+	}
+	for _, r := range []Report{
+		{LatencyMS: 0},
+		{LatencyMS: 30, FailureRate: 1.5},
+		{LatencyMS: 30, ThroughputKbps: -1},
+	} {
+		if err := r.Validate(); err == nil {
+			t.Errorf("Validate(%+v) accepted", r)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Package gaslimit sizes transaction gas limits from eth_estimateGas.
+package gaslimit
+
+import (
+	"context"
+	"log"
+	"math"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+// DefaultMultiplier pads the node's estimate so small state changes between
+// estimation and inclusion do not run the tx out of gas.
+const DefaultMultiplier = 1.2
+
+// Estimator is the subset of ethclient.Client used to estimate gas.
+type Estimator interface {
+	EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error)
+}
+
+// Policy controls how an estimate becomes a gas limit.
+type Policy struct {
+	Multiplier float64 // Applied to the estimate (DefaultMultiplier if <= 0)
+	Ceiling    uint64  // Upper bound on the limit (0 = no ceiling)
+}
+
+// DefaultPolicy returns a policy with DefaultMultiplier and no ceiling.
+func DefaultPolicy() Policy {
+	return Policy{Multiplier: DefaultMultiplier}
+}
+
+// Limit estimates msg and returns the padded, capped gas limit. If estimation
+// fails it logs and returns fallback, so a flaky RPC does not block the write.
+func (p Policy) Limit(ctx context.Context, est Estimator, msg ethereum.CallMsg, fallback uint64) uint64 {
+	estimate, err := est.EstimateGas(ctx, msg)
+	if err != nil {
+		log.Printf("[gaslimit] Estimation failed, using fallback limit %d: %v", fallback, err)
+		return fallback
+	}
+	return p.apply(estimate)
+}
+
+func (p Policy) apply(estimate uint64) uint64 {
+	mult := p.Multiplier
+	if mult <= 0 {
+		mult = DefaultMultiplier
+	}
+
+	padded := math.Ceil(float64(estimate) * mult)
+	limit := uint64(math.MaxUint64)
+	if padded < float64(math.MaxUint64) {
+		limit = uint64(padded)
+	}
+
+	if p.Ceiling > 0 && limit > p.Ceiling {
+		log.Printf("[gaslimit] Estimate %d (padded %d) exceeds ceiling, capping at %d", estimate, limit, p.Ceiling)
+		limit = p.Ceiling
+	}
+	return limit
+}
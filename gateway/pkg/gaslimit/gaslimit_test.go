@@ -0,0 +1,43 @@
+package gaslimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+type fakeEstimator struct {
+	gas uint64
+	err error
+}
+
+func (f fakeEstimator) EstimateGas(context.Context, ethereum.CallMsg) (uint64, error) {
+	return f.gas, f.err
+}
+
+func TestLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   Policy
+		est      fakeEstimator
+		fallback uint64
+		want     uint64
+	}{
+		{"default multiplier", DefaultPolicy(), fakeEstimator{gas: 100000}, 150000, 120000},
+		{"zero multiplier uses default", Policy{}, fakeEstimator{gas: 50000}, 150000, 60000},
+		{"custom multiplier rounds up", Policy{Multiplier: 1.5}, fakeEstimator{gas: 33333}, 150000, 50000},
+		{"ceiling caps", Policy{Multiplier: 2, Ceiling: 150000}, fakeEstimator{gas: 100000}, 100000, 150000},
+		{"estimate failure falls back", DefaultPolicy(), fakeEstimator{err: errors.New("execution reverted")}, 150000, 150000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.Limit(context.Background(), tt.est, ethereum.CallMsg{}, tt.fallback)
+			if got != tt.want {
+				t.Fatalf("Limit() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+// Package gatewaypb holds the generated gRPC bindings for the gateway API
+// defined in gateway.proto.
+package gatewaypb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative gateway.proto
@@ -0,0 +1,173 @@
+// Package janitor runs the expiry sweeps of the gateway's in-memory stores
+// from a single goroutine.
+//
+// Each store used to run its own one-minute ticker that locked and scanned
+// its whole map; with several large stores those scans lined up and showed
+// up as periodic latency spikes. The janitor instead spreads the registered
+// sweeps evenly over its interval, one at a time, with random jitter so they
+// never settle into lockstep with each other or with request traffic. Stores
+// still drop expired entries when they are read, so a sweep only reclaims
+// memory held by entries nobody asked for again.
+package janitor
+
+import (
+	"log"
+	"math/rand/v2"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is how often the default janitor visits each store.
+const DefaultInterval = time.Minute
+
+// jitterFraction is the largest relative deviation applied to each wait.
+const jitterFraction = 0.2
+
+// Janitor calls registered sweep functions in turn, visiting each one about
+// once per interval.
+type Janitor struct {
+	interval time.Duration
+
+	mu       sync.Mutex
+	sweepers []*sweeper
+	next     int
+	started  bool
+	stopCh   chan struct{}
+	wakeCh   chan struct{}
+}
+
+type sweeper struct {
+	name  string
+	sweep func(now time.Time)
+}
+
+// New creates a janitor that visits each registered store once per interval.
+// It starts on the first Register.
+func New(interval time.Duration) *Janitor {
+	return &Janitor{
+		interval: interval,
+		stopCh:   make(chan struct{}),
+		wakeCh:   make(chan struct{}, 1),
+	}
+}
+
+var defaultJanitor = New(DefaultInterval)
+
+// Register adds a sweep to the default janitor. See Janitor.Register.
+func Register(name string, sweep func(now time.Time)) (unregister func()) {
+	return defaultJanitor.Register(name, sweep)
+}
+
+// Register adds a sweep that removes expired entries as of now. The sweep
+// runs on the janitor goroutine and should only hold its store's lock for
+// the scan itself. The returned function removes it again.
+func (j *Janitor) Register(name string, sweep func(now time.Time)) (unregister func()) {
+	s := &sweeper{name: name, sweep: sweep}
+
+	j.mu.Lock()
+	j.sweepers = append(j.sweepers, s)
+	if !j.started {
+		j.started = true
+		go j.run()
+	}
+	j.mu.Unlock()
+	j.wake()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			j.mu.Lock()
+			for i, other := range j.sweepers {
+				if other == s {
+					j.sweepers = append(j.sweepers[:i], j.sweepers[i+1:]...)
+					break
+				}
+			}
+			j.mu.Unlock()
+		})
+	}
+}
+
+// Stop ends the janitor goroutine. Registered sweeps no longer run.
+func (j *Janitor) Stop() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	select {
+	case <-j.stopCh:
+	default:
+		close(j.stopCh)
+	}
+}
+
+// wake makes the janitor recompute its wait after the sweeper set changed,
+// so a first registration doesn't wait out the idle poll.
+func (j *Janitor) wake() {
+	select {
+	case j.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (j *Janitor) run() {
+	timer := time.NewTimer(j.wait())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-j.stopCh:
+			return
+		case <-j.wakeCh:
+		case <-timer.C:
+			if s := j.pick(); s != nil {
+				j.runSweep(s)
+			}
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(j.wait())
+	}
+}
+
+// wait returns the jittered gap before the next sweep: the interval divided
+// by the number of stores, so every store is visited once per interval.
+func (j *Janitor) wait() time.Duration {
+	j.mu.Lock()
+	n := len(j.sweepers)
+	j.mu.Unlock()
+
+	gap := j.interval
+	if n > 1 {
+		gap /= time.Duration(n)
+	}
+	jitter := (rand.Float64()*2 - 1) * jitterFraction
+	return gap + time.Duration(float64(gap)*jitter)
+}
+
+// pick returns the next sweeper in round-robin order, or nil if none.
+func (j *Janitor) pick() *sweeper {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.sweepers) == 0 {
+		return nil
+	}
+	if j.next >= len(j.sweepers) {
+		j.next = 0
+	}
+	s := j.sweepers[j.next]
+	j.next++
+	return s
+}
+
+func (j *Janitor) runSweep(s *sweeper) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("[janitor] Sweep %q panicked: %v\n%s", s.name, rec, debug.Stack())
+		}
+	}()
+	s.sweep(time.Now())
+}
@@ -0,0 +1,87 @@
+package janitor
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSweepsAreStaggered(t *testing.T) {
+	j := New(60 * time.Millisecond)
+	defer j.Stop()
+
+	var mu sync.Mutex
+	var runs []time.Time
+	record := func(time.Time) {
+		mu.Lock()
+		runs = append(runs, time.Now())
+		mu.Unlock()
+	}
+	for i := 0; i < 3; i++ {
+		j.Register("store", record)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(runs) < 4 {
+		t.Fatalf("expected each store swept about once per interval, got %d sweeps", len(runs))
+	}
+	// Three stores on a 60ms interval: sweeps ~20ms apart, never together.
+	for i := 1; i < len(runs); i++ {
+		if gap := runs[i].Sub(runs[i-1]); gap < 10*time.Millisecond {
+			t.Fatalf("sweeps %d and %d only %v apart", i-1, i, gap)
+		}
+	}
+}
+
+func TestUnregister(t *testing.T) {
+	j := New(10 * time.Millisecond)
+	defer j.Stop()
+
+	var mu sync.Mutex
+	count := 0
+	unregister := j.Register("store", func(time.Time) {
+		mu.Lock()
+		count++
+		mu.Unlock()
+	})
+
+	time.Sleep(50 * time.Millisecond)
+	unregister()
+	unregister() // safe to call twice
+
+	// Let a sweep that was already picked before unregister finish.
+	time.Sleep(5 * time.Millisecond)
+
+	mu.Lock()
+	before := count
+	mu.Unlock()
+	if before == 0 {
+		t.Fatal("expected sweeps before unregister")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if count != before {
+		t.Fatalf("expected no sweeps after unregister, got %d more", count-before)
+	}
+}
+
+func TestPanickingSweepDoesNotStopJanitor(t *testing.T) {
+	j := New(10 * time.Millisecond)
+	defer j.Stop()
+
+	done := make(chan struct{})
+	var once sync.Once
+	j.Register("broken", func(time.Time) { panic("boom") })
+	j.Register("healthy", func(time.Time) { once.Do(func() { close(done) }) })
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("healthy sweep never ran after a panicking one")
+	}
+}
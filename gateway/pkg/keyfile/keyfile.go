@@ -0,0 +1,69 @@
+// Package keyfile loads operator signing keys from disk, so private keys
+// never have to be passed on the command line.
+package keyfile
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Load reads a private key from path. The file holds either a hex key (with
+// or without 0x, surrounding whitespace ignored) or a go-ethereum keystore
+// JSON, which is decrypted with password.
+func Load(path, password string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key file: %w", err)
+	}
+	data = bytes.TrimSpace(data)
+
+	if bytes.HasPrefix(data, []byte("{")) {
+		key, err := keystore.DecryptKey(data, password)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting keystore %s: %w", path, err)
+		}
+		return key.PrivateKey, nil
+	}
+
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(string(data), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing key file %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// LoadHex is Load returning the key hex-encoded without 0x, the form the
+// contract clients take.
+func LoadHex(path, password string) (string, error) {
+	key, err := Load(path, password)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(crypto.FromECDSA(key)), nil
+}
+
+// ReadPassword reads a keystore passphrase from path, dropping one trailing
+// newline. An empty path returns "".
+func ReadPassword(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading password file: %w", err)
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), nil
+}
+
+// Exposed reports whether path can be read by group or others.
+func Exposed(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().Perm()&0o077 != 0
+}
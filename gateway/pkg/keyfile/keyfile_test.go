@@ -0,0 +1,78 @@
+package keyfile
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func writeFile(t *testing.T, name string, data []byte, perm os.FileMode) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, perm); err != nil {
+		t.Fatalf("writing %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadHexFile(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	want := hex.EncodeToString(crypto.FromECDSA(key))
+
+	for _, content := range []string{want, "0x" + want + "\n", "  " + want + "\r\n"} {
+		path := writeFile(t, "key.hex", []byte(content), 0o600)
+		got, err := LoadHex(path, "")
+		if err != nil {
+			t.Fatalf("LoadHex(%q): %v", content, err)
+		}
+		if got != want {
+			t.Errorf("LoadHex(%q) = %s, want %s", content, got, want)
+		}
+	}
+
+	if _, err := Load(writeFile(t, "bad.hex", []byte("not-a-key"), 0o600), ""); err == nil {
+		t.Error("expected error for malformed key")
+	}
+}
+
+func TestLoadKeystore(t *testing.T) {
+	priv, _ := crypto.GenerateKey()
+	ks := keystore.NewKeyStore(t.TempDir(), keystore.LightScryptN, keystore.LightScryptP)
+	account, err := ks.ImportECDSA(priv, "correct horse")
+	if err != nil {
+		t.Fatalf("ImportECDSA: %v", err)
+	}
+	path := account.URL.Path
+
+	key, err := Load(path, "correct horse")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if crypto.PubkeyToAddress(key.PublicKey) != account.Address {
+		t.Error("decrypted key does not match")
+	}
+	if _, err := Load(path, "wrong"); err == nil {
+		t.Error("expected error for wrong passphrase")
+	}
+}
+
+func TestReadPasswordAndExposed(t *testing.T) {
+	path := writeFile(t, "pass", []byte("s3cret\n"), 0o644)
+	pass, err := ReadPassword(path)
+	if err != nil || pass != "s3cret" {
+		t.Fatalf("ReadPassword = %q, %v", pass, err)
+	}
+	if !Exposed(path) {
+		t.Error("expected 0644 file to be reported as exposed")
+	}
+	if Exposed(writeFile(t, "private", []byte("x"), 0o600)) {
+		t.Error("expected 0600 file to be private")
+	}
+	if pass, err := ReadPassword(""); err != nil || pass != "" {
+		t.Errorf("ReadPassword(\"\") = %q, %v", pass, err)
+	}
+}
@@ -0,0 +1,155 @@
+// Package lru provides a size-bounded map that evicts the least recently used
+// entry when full. It backs the gateway's in-memory session store and lookup
+// caches so a flood of distinct keys cannot grow them without limit.
+package lru
+
+import "container/list"
+
+// Cache is a map with an optional entry cap and least-recently-used
+// eviction. It is not safe for concurrent use; callers hold their own lock.
+type Cache[K comparable, V any] struct {
+	max   int
+	order *list.List // front = most recently used
+	items map[K]*list.Element
+}
+
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// New creates a cache holding at most max entries. max <= 0 means unlimited.
+func New[K comparable, V any](max int) *Cache[K, V] {
+	return &Cache[K, V]{
+		max:   max,
+		order: list.New(),
+		items: make(map[K]*list.Element),
+	}
+}
+
+// Get returns the value for key and marks it as recently used.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*entry[K, V]).value, true
+}
+
+// Peek returns the value for key without changing its recency.
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return el.Value.(*entry[K, V]).value, true
+}
+
+// Add stores value under key and marks it as recently used. If the cache is
+// over its cap afterwards, the least recently used entries are removed and
+// passed to onEvict, which may be nil.
+func (c *Cache[K, V]) Add(key K, value V, onEvict func(K, V)) {
+	if el, ok := c.items[key]; ok {
+		el.Value.(*entry[K, V]).value = value
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value})
+
+	c.evict(onEvict)
+}
+
+// AddKeeping is Add for a cache some of whose entries must not be evicted:
+// those for which keep returns true are skipped. If only such entries are
+// left to evict, value is not stored and AddKeeping returns false.
+func (c *Cache[K, V]) AddKeeping(key K, value V, keep func(K, V) bool, onEvict func(K, V)) bool {
+	if el, ok := c.items[key]; ok {
+		el.Value.(*entry[K, V]).value = value
+		c.order.MoveToFront(el)
+		return true
+	}
+	for el := c.order.Back(); c.max > 0 && c.order.Len() >= c.max && el != nil; {
+		prev := el.Prev()
+		e := el.Value.(*entry[K, V])
+		if !keep(e.key, e.value) {
+			c.order.Remove(el)
+			delete(c.items, e.key)
+			if onEvict != nil {
+				onEvict(e.key, e.value)
+			}
+		}
+		el = prev
+	}
+	if c.max > 0 && c.order.Len() >= c.max {
+		return false
+	}
+	c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value})
+	return true
+}
+
+// Remove deletes key and reports whether it was present.
+func (c *Cache[K, V]) Remove(key K) bool {
+	el, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	c.order.Remove(el)
+	delete(c.items, key)
+	return true
+}
+
+// RemoveFunc deletes every entry for which fn returns true and returns the
+// number removed. Used by the periodic expiry sweeps.
+func (c *Cache[K, V]) RemoveFunc(fn func(K, V) bool) int {
+	removed := 0
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		e := el.Value.(*entry[K, V])
+		if fn(e.key, e.value) {
+			c.order.Remove(el)
+			delete(c.items, e.key)
+			removed++
+		}
+		el = next
+	}
+	return removed
+}
+
+// Range calls fn for each entry, most recently used first, without
+// changing recency, until fn returns false.
+func (c *Cache[K, V]) Range(fn func(K, V) bool) {
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*entry[K, V])
+		if !fn(e.key, e.value) {
+			return
+		}
+	}
+}
+
+// Len returns the number of entries.
+func (c *Cache[K, V]) Len() int {
+	return len(c.items)
+}
+
+// SetMax changes the entry cap, evicting least recently used entries if the
+// cache is now over it. max <= 0 means unlimited.
+func (c *Cache[K, V]) SetMax(max int, onEvict func(K, V)) {
+	c.max = max
+	c.evict(onEvict)
+}
+
+// evict removes least recently used entries until the cache fits its cap.
+func (c *Cache[K, V]) evict(onEvict func(K, V)) {
+	for c.max > 0 && c.order.Len() > c.max {
+		oldest := c.order.Back()
+		e := oldest.Value.(*entry[K, V])
+		c.order.Remove(oldest)
+		delete(c.items, e.key)
+		if onEvict != nil {
+			onEvict(e.key, e.value)
+		}
+	}
+}
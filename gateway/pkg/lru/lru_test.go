@@ -0,0 +1,125 @@
+package lru
+
+import "testing"
+
+func TestCapEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[string, int](2)
+	var evicted []string
+	onEvict := func(k string, _ int) { evicted = append(evicted, k) }
+
+	c.Add("a", 1, onEvict)
+	c.Add("b", 2, onEvict)
+	c.Get("a") // b is now the least recently used
+	c.Add("c", 3, onEvict)
+
+	if c.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", c.Len())
+	}
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Fatalf("evicted = %v, want [b]", evicted)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("b should have been evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %d, %v", v, ok)
+	}
+}
+
+func TestAddKeepingSkipsKeptEntries(t *testing.T) {
+	c := New[string, int](2)
+	var evicted []string
+	onEvict := func(k string, _ int) { evicted = append(evicted, k) }
+	keepA := func(k string, _ int) bool { return k == "a" }
+
+	c.Add("a", 1, nil)
+	c.Add("b", 2, nil)
+	if !c.AddKeeping("c", 3, keepA, onEvict) {
+		t.Fatal("AddKeeping(c) = false, want b evicted to make room")
+	}
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Fatalf("evicted = %v, want [b]", evicted)
+	}
+
+	keepAll := func(string, int) bool { return true }
+	if c.AddKeeping("d", 4, keepAll, onEvict) {
+		t.Fatal("AddKeeping(d) = true with every entry kept")
+	}
+	if _, ok := c.Peek("d"); ok || c.Len() != 2 || len(evicted) != 1 {
+		t.Errorf("cache changed by a refused add: Len = %d, evicted = %v", c.Len(), evicted)
+	}
+}
+
+func TestUpdateDoesNotEvict(t *testing.T) {
+	c := New[string, int](2)
+	c.Add("a", 1, nil)
+	c.Add("b", 2, nil)
+	c.Add("a", 10, nil)
+
+	if c.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", c.Len())
+	}
+	if v, _ := c.Peek("a"); v != 10 {
+		t.Fatalf("Peek(a) = %d, want 10", v)
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	c := New[int, int](0)
+	for i := 0; i < 1000; i++ {
+		c.Add(i, i, nil)
+	}
+	if c.Len() != 1000 {
+		t.Fatalf("Len = %d, want 1000", c.Len())
+	}
+}
+
+func TestSetMaxShrinks(t *testing.T) {
+	c := New[int, int](0)
+	for i := 0; i < 10; i++ {
+		c.Add(i, i, nil)
+	}
+	c.SetMax(3, nil)
+	if c.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", c.Len())
+	}
+	for i := 7; i < 10; i++ {
+		if _, ok := c.Peek(i); !ok {
+			t.Fatalf("newest entry %d was evicted", i)
+		}
+	}
+}
+
+func TestRemoveFunc(t *testing.T) {
+	c := New[int, int](0)
+	for i := 0; i < 10; i++ {
+		c.Add(i, i, nil)
+	}
+	if n := c.RemoveFunc(func(_, v int) bool { return v%2 == 0 }); n != 5 {
+		t.Fatalf("removed %d, want 5", n)
+	}
+	if c.Len() != 5 {
+		t.Fatalf("Len = %d, want 5", c.Len())
+	}
+	if !c.Remove(1) || c.Remove(1) {
+		t.Fatal("Remove should report presence once")
+	}
+}
+
+func TestRange(t *testing.T) {
+	c := New[int, int](0)
+	for i := 0; i < 5; i++ {
+		c.Add(i, i, nil)
+	}
+	var seen []int
+	c.Range(func(k, _ int) bool {
+		seen = append(seen, k)
+		return k > 2
+	})
+	if len(seen) != 3 || seen[0] != 4 || seen[2] != 2 {
+		t.Fatalf("visited %v, want [4 3 2]", seen)
+	}
+	if c.Len() != 5 {
+		t.Fatalf("Len = %d after Range, want 5", c.Len())
+	}
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestRegistryWritesPrometheusText(t *testing.T) {
+	r := NewRegistry()
+	errs := r.NewCounter("test_errors_total", "Errors.", "kind")
+	latency := r.NewHistogram("test_duration_seconds", "Latency.", []float64{0.1, 1}, "path")
+	r.NewGaugeFunc("test_sessions", "Sessions.", "tier", func() map[string]float64 {
+		return map[string]float64{"paid": 2, "free": 1}
+	})
+
+	errs.Inc("timeout")
+	errs.Inc("timeout")
+	errs.Inc(`say "hi"`)
+	latency.Observe(0.05, "direct")
+	latency.Observe(0.5, "direct")
+	latency.Observe(3, "direct")
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_duration_seconds Latency.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{path="direct",le="0.1"} 1
+test_duration_seconds_bucket{path="direct",le="1"} 2
+test_duration_seconds_bucket{path="direct",le="+Inf"} 3
+test_duration_seconds_sum{path="direct"} 3.55
+test_duration_seconds_count{path="direct"} 3
+# HELP test_errors_total Errors.
+# TYPE test_errors_total counter
+test_errors_total{kind="say \"hi\""} 1
+test_errors_total{kind="timeout"} 2
+# HELP test_sessions Sessions.
+# TYPE test_sessions gauge
+test_sessions{tier="free"} 1
+test_sessions{tier="paid"} 2
+`
+	if b.String() != want {
+		t.Fatalf("output:\n%s\nwant:\n%s", b.String(), want)
+	}
+	if got := latency.Count("direct"); got != 3 {
+		t.Errorf("Count = %d, want 3", got)
+	}
+}
+
+func TestRegistryRejectsDuplicates(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("dup_total", "x")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic on duplicate metric name")
+		}
+	}()
+	r.NewCounter("dup_total", "x")
+}
+
+type codedError struct {
+	code int
+	msg  string
+}
+
+func (e codedError) Error() string  { return e.msg }
+func (e codedError) ErrorCode() int { return e.code }
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRPCErrorKind(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("calling: %w", context.DeadlineExceeded), RPCErrorTimeout},
+		{context.Canceled, RPCErrorCanceled},
+		{rpc.HTTPError{StatusCode: 429, Status: "429 Too Many Requests"}, RPCErrorRateLimited},
+		{rpc.HTTPError{StatusCode: 502, Status: "502 Bad Gateway"}, RPCErrorHTTP},
+		{codedError{-32005, "daily request count exceeded"}, RPCErrorRateLimited},
+		{codedError{3, "execution reverted: nope"}, RPCErrorReverted},
+		{codedError{-32000, "execution reverted"}, RPCErrorReverted},
+		{codedError{-32000, "header not found"}, RPCErrorOther},
+		{fmt.Errorf("dial: %w", timeoutError{}), RPCErrorTimeout},
+		{errors.New("boom"), RPCErrorOther},
+	}
+	for _, tt := range tests {
+		if got := RPCErrorKind(tt.err); got != tt.want {
+			t.Errorf("RPCErrorKind(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Error kinds counted by rpcErrors.
+const (
+	RPCErrorTimeout     = "timeout"      // deadline exceeded or network timeout
+	RPCErrorCanceled    = "canceled"     // caller went away
+	RPCErrorRateLimited = "rate_limited" // HTTP 429 or a provider limit code
+	RPCErrorHTTP        = "http"         // any other non-2xx from the endpoint
+	RPCErrorReverted    = "reverted"     // the call reached the EVM and reverted
+	RPCErrorNetwork     = "network"      // connection refused, DNS, reset
+	RPCErrorOther       = "other"
+)
+
+var (
+	rpcDuration = NewHistogram("svpn_rpc_call_duration_seconds",
+		"Duration of Ethereum RPC calls, by contract method.", nil, "method")
+	rpcErrors = NewCounter("svpn_rpc_errors_total",
+		"Failed Ethereum RPC calls, by contract method and error kind.", "method", "kind")
+	rpcQueueWait = NewHistogram("svpn_rpc_queue_wait_seconds",
+		"Time Ethereum RPC requests waited for a slot under the concurrency limit.", nil)
+)
+
+// ObserveRPCQueueWait records how long an RPC request queued for a slot
+// that it started waiting for at start.
+func ObserveRPCQueueWait(start time.Time) {
+	rpcQueueWait.ObserveSince(start)
+}
+
+// ObserveRPC records one RPC call to method that started at start and
+// returned err.
+func ObserveRPC(method string, start time.Time, err error) {
+	rpcDuration.ObserveSince(start, method)
+	if err != nil {
+		rpcErrors.Inc(method, RPCErrorKind(err))
+	}
+}
+
+// RPCErrorKind classifies an RPC error for the svpn_rpc_errors_total counter.
+func RPCErrorKind(err error) string {
+	var httpErr rpc.HTTPError
+	var rpcErr rpc.Error
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return RPCErrorTimeout
+	case errors.Is(err, context.Canceled):
+		return RPCErrorCanceled
+	case errors.As(err, &httpErr):
+		if httpErr.StatusCode == http.StatusTooManyRequests {
+			return RPCErrorRateLimited
+		}
+		return RPCErrorHTTP
+	case errors.As(err, &rpcErr):
+		// -32005 is the de facto "limit exceeded" code (Infura, Alchemy);
+		// 3 is geth's "execution reverted" with revert data.
+		switch rpcErr.ErrorCode() {
+		case -32005:
+			return RPCErrorRateLimited
+		case 3:
+			return RPCErrorReverted
+		}
+		if strings.Contains(rpcErr.Error(), "execution reverted") {
+			return RPCErrorReverted
+		}
+		return RPCErrorOther
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return RPCErrorTimeout
+		}
+		return RPCErrorNetwork
+	}
+	return RPCErrorOther
+}
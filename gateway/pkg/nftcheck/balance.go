@@ -0,0 +1,390 @@
+package nftcheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/metrics"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
+)
+
+// Balance source names accepted by NewBalanceSource.
+const (
+	// BalanceSourceRPC calls balanceOfBatch in batches of balanceBatchSize (default).
+	BalanceSourceRPC = "rpc"
+
+	// BalanceSourceMulticall sends every balanceOfBatch batch in one
+	// Multicall3 eth_call.
+	BalanceSourceMulticall = "multicall"
+
+	// BalanceSourceNFTAPI asks an indexer (Alchemy-compatible getNFTsForOwner)
+	// for the wallet's Memes tokens in one HTTP request.
+	BalanceSourceNFTAPI = "nft-api"
+)
+
+// Multicall3Address is the canonical Multicall3 deployment, at the same
+// address on mainnet and most testnets.
+const Multicall3Address = "0xcA11bde05977b3631167028862bE2a173976CA11"
+
+// balanceBatchSize is how many token IDs go into one balanceOfBatch call,
+// keeping each call well inside node gas limits.
+const balanceBatchSize = 50
+
+// BalanceSource reports which Memes token IDs a wallet holds. The RPC-backed
+// sources read at the block pinned by the calling checker, if any.
+type BalanceSource interface {
+	// OwnedTokens returns the IDs among ids held by wallet, in the order
+	// given.
+	OwnedTokens(ctx context.Context, wallet common.Address, ids []int64) ([]int64, error)
+}
+
+// NewBalanceSource returns the named source. nftAPIURL is only used by
+// BalanceSourceNFTAPI and must include any API key path segment, e.g.
+// "https://eth-mainnet.g.alchemy.com/nft/v3/<key>".
+func NewBalanceSource(name string, caller ethereum.ContractCaller, memesContract common.Address, nftAPIURL string) (BalanceSource, error) {
+	switch name {
+	case "", BalanceSourceRPC:
+		return newRPCBalanceSource(caller, memesContract)
+	case BalanceSourceMulticall:
+		return newMulticallBalanceSource(caller, memesContract, common.HexToAddress(Multicall3Address))
+	case BalanceSourceNFTAPI:
+		if nftAPIURL == "" {
+			return nil, fmt.Errorf("%s balance source requires an API URL", BalanceSourceNFTAPI)
+		}
+		return NewNFTAPIBalanceSource(nftAPIURL, memesContract), nil
+	default:
+		return nil, fmt.Errorf("unknown balance source %q (want %q, %q or %q)", name, BalanceSourceRPC, BalanceSourceMulticall, BalanceSourceNFTAPI)
+	}
+}
+
+// tokenBatches splits ids into balanceOfBatch-sized batches.
+func tokenBatches(ids []int64) [][]int64 {
+	var batches [][]int64
+	for start := 0; start < len(ids); start += balanceBatchSize {
+		end := min(start+balanceBatchSize, len(ids))
+		batches = append(batches, ids[start:end])
+	}
+	return batches
+}
+
+// packBalanceOfBatch encodes balanceOfBatch for wallet over tokenIDs.
+func packBalanceOfBatch(erc1155 abi.ABI, wallet common.Address, tokenIDs []int64) ([]byte, error) {
+	accounts := make([]common.Address, len(tokenIDs))
+	ids := make([]*big.Int, len(tokenIDs))
+	for i, id := range tokenIDs {
+		accounts[i] = wallet
+		ids[i] = big.NewInt(id)
+	}
+	callData, err := erc1155.Pack("balanceOfBatch", accounts, ids)
+	if err != nil {
+		return nil, fmt.Errorf("packing balanceOfBatch: %w", err)
+	}
+	return callData, nil
+}
+
+// unpackBalances decodes balanceOfBatch output for the batch tokenIDs and
+// returns the held IDs.
+func unpackBalances(erc1155 abi.ABI, output []byte, tokenIDs []int64) ([]int64, error) {
+	results, err := erc1155.Unpack("balanceOfBatch", output)
+	if err != nil {
+		return nil, fmt.Errorf("unpacking balanceOfBatch: %w", err)
+	}
+	if len(results) != 1 {
+		return nil, fmt.Errorf("expected 1 result, got %d", len(results))
+	}
+	balances, ok := results[0].([]*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type for balances: %T", results[0])
+	}
+	if len(balances) != len(tokenIDs) {
+		return nil, fmt.Errorf("balanceOfBatch returned %d balances for %d IDs", len(balances), len(tokenIDs))
+	}
+
+	var owned []int64
+	for i, bal := range balances {
+		if bal != nil && bal.Sign() > 0 {
+			owned = append(owned, tokenIDs[i])
+		}
+	}
+	return owned, nil
+}
+
+// rpcBalanceSource calls balanceOfBatch once per batch.
+type rpcBalanceSource struct {
+	caller    ethereum.ContractCaller
+	memesAddr common.Address
+	erc1155   abi.ABI
+}
+
+func newRPCBalanceSource(caller ethereum.ContractCaller, memesAddr common.Address) (*rpcBalanceSource, error) {
+	parsed, err := abi.JSON(strings.NewReader(erc1155ABIJSON))
+	if err != nil {
+		return nil, fmt.Errorf("parsing ERC-1155 ABI: %w", err)
+	}
+	return &rpcBalanceSource{caller: caller, memesAddr: memesAddr, erc1155: parsed}, nil
+}
+
+func (s *rpcBalanceSource) OwnedTokens(ctx context.Context, wallet common.Address, ids []int64) ([]int64, error) {
+	var owned []int64
+	for _, b := range tokenBatches(ids) {
+		callData, err := packBalanceOfBatch(s.erc1155, wallet, b)
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		output, err := s.caller.CallContract(ctx, ethereum.CallMsg{To: &s.memesAddr, Data: callData}, blockFromContext(ctx))
+		metrics.ObserveRPC("balanceOfBatch", start, err)
+		if err != nil {
+			return nil, callError(s.memesAddr, "balanceOfBatch", err)
+		}
+		if len(output) == 0 {
+			return nil, emptyOutputError(s.memesAddr, "balanceOfBatch")
+		}
+		held, err := unpackBalances(s.erc1155, output, b)
+		if err != nil {
+			return nil, err
+		}
+		owned = append(owned, held...)
+	}
+	return owned, nil
+}
+
+const multicall3ABIJSON = `[
+	{
+		"inputs": [{
+			"components": [
+				{"name": "target", "type": "address"},
+				{"name": "allowFailure", "type": "bool"},
+				{"name": "callData", "type": "bytes"}
+			],
+			"name": "calls",
+			"type": "tuple[]"
+		}],
+		"name": "aggregate3",
+		"outputs": [{
+			"components": [
+				{"name": "success", "type": "bool"},
+				{"name": "returnData", "type": "bytes"}
+			],
+			"name": "returnData",
+			"type": "tuple[]"
+		}],
+		"stateMutability": "payable",
+		"type": "function"
+	}
+]`
+
+// multicall3Call mirrors the Multicall3.Call3 tuple for ABI packing.
+type multicall3Call struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+// multicallBalanceSource sends all balanceOfBatch batches in one
+// Multicall3.aggregate3 eth_call.
+type multicallBalanceSource struct {
+	caller        ethereum.ContractCaller
+	memesAddr     common.Address
+	multicallAddr common.Address
+	erc1155       abi.ABI
+	multicall     abi.ABI
+}
+
+func newMulticallBalanceSource(caller ethereum.ContractCaller, memesAddr, multicallAddr common.Address) (*multicallBalanceSource, error) {
+	erc1155, err := abi.JSON(strings.NewReader(erc1155ABIJSON))
+	if err != nil {
+		return nil, fmt.Errorf("parsing ERC-1155 ABI: %w", err)
+	}
+	multicall, err := abi.JSON(strings.NewReader(multicall3ABIJSON))
+	if err != nil {
+		return nil, fmt.Errorf("parsing Multicall3 ABI: %w", err)
+	}
+	return &multicallBalanceSource{
+		caller:        caller,
+		memesAddr:     memesAddr,
+		multicallAddr: multicallAddr,
+		erc1155:       erc1155,
+		multicall:     multicall,
+	}, nil
+}
+
+func (s *multicallBalanceSource) OwnedTokens(ctx context.Context, wallet common.Address, ids []int64) ([]int64, error) {
+	batches := tokenBatches(ids)
+	if len(batches) == 0 {
+		return nil, nil
+	}
+
+	calls := make([]multicall3Call, len(batches))
+	for i, b := range batches {
+		callData, err := packBalanceOfBatch(s.erc1155, wallet, b)
+		if err != nil {
+			return nil, err
+		}
+		calls[i] = multicall3Call{Target: s.memesAddr, CallData: callData}
+	}
+
+	callData, err := s.multicall.Pack("aggregate3", calls)
+	if err != nil {
+		return nil, fmt.Errorf("packing aggregate3: %w", err)
+	}
+	start := time.Now()
+	output, err := s.caller.CallContract(ctx, ethereum.CallMsg{To: &s.multicallAddr, Data: callData}, blockFromContext(ctx))
+	metrics.ObserveRPC("aggregate3", start, err)
+	if err != nil {
+		return nil, callError(s.multicallAddr, "aggregate3", err)
+	}
+	if len(output) == 0 {
+		return nil, emptyOutputError(s.multicallAddr, "aggregate3")
+	}
+
+	results, err := s.multicall.Unpack("aggregate3", output)
+	if err != nil {
+		return nil, fmt.Errorf("unpacking aggregate3: %w", err)
+	}
+	if len(results) != 1 {
+		return nil, fmt.Errorf("expected 1 result, got %d", len(results))
+	}
+	returns, ok := results[0].([]struct {
+		Success    bool   `json:"success"`
+		ReturnData []byte `json:"returnData"`
+	})
+	if !ok {
+		return nil, fmt.Errorf("unexpected type for aggregate3 results: %T", results[0])
+	}
+	if len(returns) != len(batches) {
+		return nil, fmt.Errorf("aggregate3 returned %d results for %d calls", len(returns), len(batches))
+	}
+
+	var owned []int64
+	for i, r := range returns {
+		if !r.Success {
+			return nil, &RevertError{Contract: s.memesAddr, Method: "balanceOfBatch", Reason: fmt.Sprintf("tokens %d-%d", batches[i][0], batches[i][len(batches[i])-1])}
+		}
+		held, err := unpackBalances(s.erc1155, r.ReturnData, batches[i])
+		if err != nil {
+			return nil, err
+		}
+		owned = append(owned, held...)
+	}
+	return owned, nil
+}
+
+// NFTAPIBalanceSource lists a wallet's Memes tokens through an
+// Alchemy-compatible getNFTsForOwner endpoint. Indexers can lag the chain by
+// a few blocks, so DirectChecker still verifies the free-tier card on-chain.
+type NFTAPIBalanceSource struct {
+	baseURL   string
+	memesAddr common.Address
+	client    *http.Client
+}
+
+// NewNFTAPIBalanceSource creates a source for baseURL, e.g.
+// "https://eth-mainnet.g.alchemy.com/nft/v3/<api-key>".
+func NewNFTAPIBalanceSource(baseURL string, memesAddr common.Address) *NFTAPIBalanceSource {
+	return &NFTAPIBalanceSource{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		memesAddr: memesAddr,
+		client:    &http.Client{Timeout: 10 * time.Second, Transport: outbound.Transport()},
+	}
+}
+
+// maxNFTAPIPages stops a misbehaving API from paging forever.
+const maxNFTAPIPages = 20
+
+type nftsForOwnerResponse struct {
+	OwnedNfts []struct {
+		TokenID string `json:"tokenId"`
+		Balance string `json:"balance"`
+	} `json:"ownedNfts"`
+	PageKey string `json:"pageKey"`
+}
+
+func (s *NFTAPIBalanceSource) OwnedTokens(ctx context.Context, wallet common.Address, ids []int64) ([]int64, error) {
+	wanted := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var owned []int64
+	pageKey := ""
+	for page := 0; page < maxNFTAPIPages; page++ {
+		resp, err := s.fetchPage(ctx, wallet, pageKey)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, nft := range resp.OwnedNfts {
+			id, ok, err := parseTokenID(nft.TokenID)
+			if err != nil {
+				return nil, err
+			}
+			if !ok || !wanted[id] || nft.Balance == "0" {
+				continue
+			}
+			owned = append(owned, id)
+		}
+
+		if resp.PageKey == "" {
+			return owned, nil
+		}
+		pageKey = resp.PageKey
+	}
+	return nil, fmt.Errorf("NFT API returned more than %d pages", maxNFTAPIPages)
+}
+
+func (s *NFTAPIBalanceSource) fetchPage(ctx context.Context, wallet common.Address, pageKey string) (*nftsForOwnerResponse, error) {
+	q := url.Values{}
+	q.Set("owner", wallet.Hex())
+	q.Set("contractAddresses[]", s.memesAddr.Hex())
+	q.Set("withMetadata", "false")
+	q.Set("pageSize", "100")
+	if pageKey != "" {
+		q.Set("pageKey", pageKey)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/getNFTsForOwner?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating NFT API request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("NFT API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("NFT API returned status %d", resp.StatusCode)
+	}
+
+	var out nftsForOwnerResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decoding NFT API response: %w", err)
+	}
+	return &out, nil
+}
+
+// parseTokenID accepts decimal or 0x-prefixed hex token IDs, since providers
+// differ. ok is false for IDs that do not fit in an int64, which are outside
+// any Memes range anyway.
+func parseTokenID(s string) (id int64, ok bool, err error) {
+	n, valid := new(big.Int).SetString(s, 0)
+	if !valid {
+		return 0, false, fmt.Errorf("parsing token ID %q", s)
+	}
+	if !n.IsInt64() {
+		return 0, false, nil
+	}
+	return n.Int64(), true, nil
+}
@@ -0,0 +1,215 @@
+package nftcheck
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	testMemes  = common.HexToAddress("0x33FD426905F149f8376e227d0C9D3340AaD17aF1")
+	testWallet = common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+)
+
+// fakeMemes answers balanceOfBatch (directly or inside Multicall3.aggregate3)
+// from a fixed set of held token IDs, counting eth_calls and the token IDs
+// they asked about.
+type fakeMemes struct {
+	t       *testing.T
+	held    map[int64]bool
+	calls   int
+	queried int
+	erc1155 abi.ABI
+	multi   abi.ABI
+}
+
+func newFakeMemes(t *testing.T, held ...int64) *fakeMemes {
+	t.Helper()
+	erc1155, err := abi.JSON(strings.NewReader(erc1155ABIJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	multi, err := abi.JSON(strings.NewReader(multicall3ABIJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeMemes{t: t, held: make(map[int64]bool), erc1155: erc1155, multi: multi}
+	for _, id := range held {
+		f.held[id] = true
+	}
+	return f
+}
+
+func (f *fakeMemes) CallContract(_ context.Context, msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	f.calls++
+	if *msg.To == common.HexToAddress(Multicall3Address) {
+		args, err := f.multi.Methods["aggregate3"].Inputs.Unpack(msg.Data[4:])
+		if err != nil {
+			f.t.Fatalf("unpacking aggregate3 args: %v", err)
+		}
+		calls := args[0].([]struct {
+			Target       common.Address `json:"target"`
+			AllowFailure bool           `json:"allowFailure"`
+			CallData     []byte         `json:"callData"`
+		})
+		type result struct {
+			Success    bool
+			ReturnData []byte
+		}
+		results := make([]result, len(calls))
+		for i, c := range calls {
+			results[i] = result{Success: true, ReturnData: f.balanceOfBatch(c.CallData)}
+		}
+		return f.multi.Methods["aggregate3"].Outputs.Pack(results)
+	}
+	return f.balanceOfBatch(msg.Data), nil
+}
+
+func (f *fakeMemes) balanceOfBatch(data []byte) []byte {
+	args, err := f.erc1155.Methods["balanceOfBatch"].Inputs.Unpack(data[4:])
+	if err != nil {
+		f.t.Fatalf("unpacking balanceOfBatch args: %v", err)
+	}
+	ids := args[1].([]*big.Int)
+	f.queried += len(ids)
+	balances := make([]*big.Int, len(ids))
+	for i, id := range ids {
+		balances[i] = new(big.Int)
+		if f.held[id.Int64()] {
+			balances[i].SetInt64(1)
+		}
+	}
+	out, err := f.erc1155.Methods["balanceOfBatch"].Outputs.Pack(balances)
+	if err != nil {
+		f.t.Fatalf("packing balances: %v", err)
+	}
+	return out
+}
+
+func TestRPCAndMulticallSources(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    string
+		wantCalls int
+	}{
+		{"rpc", BalanceSourceRPC, 3}, // 120 IDs in batches of 50
+		{"multicall", BalanceSourceMulticall, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memes := newFakeMemes(t, 7, 64, 120, 121)
+			src, err := NewBalanceSource(tt.source, memes, testMemes, "")
+			if err != nil {
+				t.Fatalf("NewBalanceSource: %v", err)
+			}
+
+			owned, err := src.OwnedTokens(context.Background(), testWallet, TokenRange(120))
+			if err != nil {
+				t.Fatalf("OwnedTokens: %v", err)
+			}
+			if want := []int64{7, 64, 120}; !reflect.DeepEqual(owned, want) {
+				t.Errorf("owned = %v, want %v", owned, want)
+			}
+			if memes.calls != tt.wantCalls {
+				t.Errorf("expected %d eth_calls, got %d", tt.wantCalls, memes.calls)
+			}
+		})
+	}
+}
+
+func TestSourcesCheckNonContiguousIDs(t *testing.T) {
+	// 64 IDs with gaps: 1-3, 10, 100-159. Held cards outside the list, like
+	// 50, must not be reported.
+	ids := append([]int64{1, 2, 3, 10}, TokenRange(159)[99:]...)
+	for _, name := range []string{BalanceSourceRPC, BalanceSourceMulticall} {
+		t.Run(name, func(t *testing.T) {
+			memes := newFakeMemes(t, 3, 10, 50, 159, 200)
+			src, err := NewBalanceSource(name, memes, testMemes, "")
+			if err != nil {
+				t.Fatalf("NewBalanceSource: %v", err)
+			}
+
+			owned, err := src.OwnedTokens(context.Background(), testWallet, ids)
+			if err != nil {
+				t.Fatalf("OwnedTokens: %v", err)
+			}
+			if want := []int64{3, 10, 159}; !reflect.DeepEqual(owned, want) {
+				t.Errorf("owned = %v, want %v", owned, want)
+			}
+			if memes.queried != len(ids) {
+				t.Errorf("queried %d token IDs, want only the %d listed", memes.queried, len(ids))
+			}
+		})
+	}
+}
+
+func TestNFTAPISourcePaging(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/key/getNFTsForOwner" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("owner") != testWallet.Hex() || q.Get("contractAddresses[]") != testMemes.Hex() {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if q.Get("pageKey") == "" {
+			json.NewEncoder(w).Encode(map[string]any{
+				"ownedNfts": []map[string]string{
+					{"tokenId": "5", "balance": "1"},
+					{"tokenId": "999", "balance": "1"}, // not in the checked set
+				},
+				"pageKey": "next",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"ownedNfts": []map[string]string{
+				{"tokenId": "0x2a", "balance": "2"},
+				{"tokenId": "43", "balance": "0"},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	src := NewNFTAPIBalanceSource(srv.URL+"/key/", testMemes)
+	owned, err := src.OwnedTokens(context.Background(), testWallet, TokenRange(350))
+	if err != nil {
+		t.Fatalf("OwnedTokens: %v", err)
+	}
+	if want := []int64{5, 42}; !reflect.DeepEqual(owned, want) {
+		t.Errorf("owned = %v, want %v", owned, want)
+	}
+}
+
+func TestNFTAPISourceError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	src := NewNFTAPIBalanceSource(srv.URL, testMemes)
+	if _, err := src.OwnedTokens(context.Background(), testWallet, TokenRange(350)); err == nil {
+		t.Fatal("expected error for non-200 response")
+	}
+}
+
+func TestNewBalanceSourceValidation(t *testing.T) {
+	if _, err := NewBalanceSource("bogus", nil, testMemes, ""); err == nil {
+		t.Error("expected error for unknown source")
+	}
+	if _, err := NewBalanceSource(BalanceSourceNFTAPI, nil, testMemes, ""); err == nil {
+		t.Error("expected error for nft-api without URL")
+	}
+}
@@ -0,0 +1,111 @@
+package nftcheck
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// headCacheTTL is how long a head block number is reused for "head-N"
+// pinning, about one slot, so pinning does not double the RPC calls.
+const headCacheTTL = 12 * time.Second
+
+// BlockPin selects the block that ownership checks read state at. The zero
+// value reads the latest block.
+type BlockPin struct {
+	Snapshot uint64 // fixed block number; wins over Lag when set
+	Lag      uint64 // blocks behind head
+}
+
+// ParseBlockPin parses --check-block: "latest" (or empty), a block number
+// for snapshot-based access, or "head-N" to read N blocks behind head, which
+// also keeps shallow reorgs from flipping results.
+func ParseBlockPin(s string) (BlockPin, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "" || s == "latest":
+		return BlockPin{}, nil
+	case strings.HasPrefix(s, "head-"):
+		lag, err := strconv.ParseUint(strings.TrimPrefix(s, "head-"), 10, 64)
+		if err != nil {
+			return BlockPin{}, fmt.Errorf("check block %q: want head-N with N a block count", s)
+		}
+		return BlockPin{Lag: lag}, nil
+	default:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || n == 0 {
+			return BlockPin{}, fmt.Errorf("check block %q: want latest, a block number or head-N", s)
+		}
+		return BlockPin{Snapshot: n}, nil
+	}
+}
+
+func (p BlockPin) String() string {
+	switch {
+	case p.Snapshot > 0:
+		return strconv.FormatUint(p.Snapshot, 10)
+	case p.Lag > 0:
+		return "head-" + strconv.FormatUint(p.Lag, 10)
+	default:
+		return "latest"
+	}
+}
+
+// blockPinner resolves a BlockPin to the block number passed to
+// CallContract, caching the head block for Lag pins.
+type blockPinner struct {
+	pin  BlockPin
+	head func(ctx context.Context) (uint64, error) // ethclient.BlockNumber
+
+	mu        sync.Mutex
+	lastHead  uint64
+	fetchedAt time.Time
+}
+
+// block returns the block to call at; nil means latest.
+func (b *blockPinner) block(ctx context.Context) (*big.Int, error) {
+	if b == nil {
+		return nil, nil
+	}
+	switch {
+	case b.pin.Snapshot > 0:
+		return new(big.Int).SetUint64(b.pin.Snapshot), nil
+	case b.pin.Lag == 0:
+		return nil, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.fetchedAt.IsZero() || time.Since(b.fetchedAt) >= headCacheTTL {
+		head, err := b.head(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("reading head block: %w", err)
+		}
+		b.lastHead, b.fetchedAt = head, time.Now()
+	}
+	if b.lastHead <= b.pin.Lag {
+		return big.NewInt(0), nil
+	}
+	return new(big.Int).SetUint64(b.lastHead - b.pin.Lag), nil
+}
+
+type blockKey struct{}
+
+// withBlock carries the pinned block to BalanceSource implementations, so a
+// wallet's checks all read the same block.
+func withBlock(ctx context.Context, block *big.Int) context.Context {
+	if block == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, blockKey{}, block)
+}
+
+// blockFromContext returns the block set by withBlock, or nil for latest.
+func blockFromContext(ctx context.Context) *big.Int {
+	block, _ := ctx.Value(blockKey{}).(*big.Int)
+	return block
+}
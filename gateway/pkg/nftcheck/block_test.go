@@ -0,0 +1,102 @@
+package nftcheck
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/lru"
+)
+
+func TestParseBlockPin(t *testing.T) {
+	tests := []struct {
+		in   string
+		want BlockPin
+	}{
+		{"", BlockPin{}},
+		{"latest", BlockPin{}},
+		{"19000000", BlockPin{Snapshot: 19000000}},
+		{"head-12", BlockPin{Lag: 12}},
+	}
+	for _, tt := range tests {
+		got, err := ParseBlockPin(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseBlockPin(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+		if tt.in != "" && got.String() != tt.in {
+			t.Errorf("BlockPin(%q).String() = %q", tt.in, got.String())
+		}
+	}
+	for _, bad := range []string{"0", "head-", "head-x", "pending", "-5"} {
+		if _, err := ParseBlockPin(bad); err == nil {
+			t.Errorf("ParseBlockPin(%q): expected error", bad)
+		}
+	}
+}
+
+func TestBlockPinnerCachesHead(t *testing.T) {
+	heads := 0
+	p := &blockPinner{
+		pin: BlockPin{Lag: 10},
+		head: func(context.Context) (uint64, error) {
+			heads++
+			return 1000, nil
+		},
+	}
+	for i := 0; i < 3; i++ {
+		block, err := p.block(context.Background())
+		if err != nil || block.Uint64() != 990 {
+			t.Fatalf("block = %v, %v; want 990", block, err)
+		}
+	}
+	if heads != 1 {
+		t.Errorf("head read %d times, want 1 within the cache TTL", heads)
+	}
+
+	var latest *blockPinner
+	if block, err := latest.block(context.Background()); block != nil || err != nil {
+		t.Errorf("nil pinner = %v, %v; want latest", block, err)
+	}
+}
+
+// blockRecorder records the block number of each eth_call.
+type blockRecorder struct {
+	*fakeMemes
+	blocks []*big.Int
+}
+
+func (r *blockRecorder) CallContract(ctx context.Context, msg ethereum.CallMsg, block *big.Int) ([]byte, error) {
+	r.blocks = append(r.blocks, block)
+	return r.fakeMemes.CallContract(ctx, msg, block)
+}
+
+func TestDirectCheckerReadsAtPinnedBlock(t *testing.T) {
+	rec := &blockRecorder{fakeMemes: newFakeMemes(t, 7)}
+	src, err := NewBalanceSource(BalanceSourceMulticall, rec, testMemes, "")
+	if err != nil {
+		t.Fatalf("NewBalanceSource: %v", err)
+	}
+	c := &DirectChecker{
+		maxTokenID: 120,
+		cacheTTL:   time.Hour,
+		balances:   src,
+		cache:      lru.New[common.Address, cacheEntry](0),
+		pinner:     &blockPinner{pin: BlockPin{Snapshot: 19000000}},
+	}
+
+	if res, err := c.Check(context.Background(), common.HexToAddress("0x1")); err != nil || res.Tier != TierPaid {
+		t.Fatalf("Check = %v, %v; want paid", res.Tier, err)
+	}
+	if len(rec.blocks) == 0 {
+		t.Fatal("no eth_calls made")
+	}
+	for _, b := range rec.blocks {
+		if b == nil || b.Uint64() != 19000000 {
+			t.Fatalf("eth_call at block %v, want 19000000", b)
+		}
+	}
+}
@@ -11,7 +11,13 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/connlog"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/janitor"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/lru"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/metrics"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
 )
 
 // AccessTier represents the user's VPN access level.
@@ -38,6 +44,11 @@ func (t AccessTier) String() string {
 type CheckResult struct {
 	Tier      AccessTier
 	CheckedAt time.Time
+	// Source names the check that decided Tier, as in Explanation.Source.
+	Source string
+	// Vault is the delegated cold wallet whose holdings granted Tier, or the
+	// zero address when the wallet qualified on its own.
+	Vault common.Address
 }
 
 // cacheEntry holds a cached check result.
@@ -46,6 +57,16 @@ type cacheEntry struct {
 	expiresAt time.Time
 }
 
+// Check paths timed by checkDuration.
+const (
+	pathPolicy     = "policy"     // AccessPolicy.checkAccess for one wallet
+	pathDirect     = "direct"     // Memes balance reads for one wallet
+	pathDelegation = "delegation" // vault lookup plus checks of each vault
+)
+
+var checkDuration = metrics.NewHistogram("svpn_nft_check_duration_seconds",
+	"Duration of uncached NFT access checks, by path.", nil, "path")
+
 // DelegationFinder looks up cold wallets that have delegated to a hot wallet.
 type DelegationFinder interface {
 	FindVaults(ctx context.Context, hotWallet common.Address) ([]common.Address, error)
@@ -53,13 +74,18 @@ type DelegationFinder interface {
 
 // Checker queries the AccessPolicy contract to determine a wallet's VPN access tier.
 type Checker struct {
-	client     *ethclient.Client
+	client     ChainReader
 	policyAddr common.Address
 	policyABI  abi.ABI
 	cacheTTL   time.Duration
 	delegation DelegationFinder // optional, nil if delegation not configured
-	mu         sync.RWMutex
-	cache      map[common.Address]cacheEntry
+	pinner     *blockPinner     // nil reads the latest block
+	clock      clock.Clock      // nil means the wall clock
+	revertErrs bool             // return reverts instead of denying
+	flight     checkFlight      // shares cache-miss lookups between concurrent checks
+	mu         sync.Mutex
+	cache      *lru.Cache[common.Address, cacheEntry]
+	stopSweep  func()
 }
 
 // AccessPolicy.checkAccess(address) returns (bool access, bool free)
@@ -76,11 +102,21 @@ const accessPolicyABIJSON = `[{
 
 // NewChecker creates an NFT checker connected to an Ethereum RPC endpoint.
 func NewChecker(rpcURL string, policyAddress string, cacheTTL time.Duration) (*Checker, error) {
-	client, err := ethclient.Dial(rpcURL)
+	client, err := outbound.Dial(rpcURL)
 	if err != nil {
 		return nil, fmt.Errorf("connecting to Ethereum RPC: %w", err)
 	}
+	c, err := NewCheckerWithClient(client, policyAddress, cacheTTL)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return c, nil
+}
 
+// NewCheckerWithClient creates an NFT checker on an existing client, e.g. an
+// ethrpc.Fake in tests. Close closes the client.
+func NewCheckerWithClient(client ChainReader, policyAddress string, cacheTTL time.Duration) (*Checker, error) {
 	parsedABI, err := abi.JSON(strings.NewReader(accessPolicyABIJSON))
 	if err != nil {
 		return nil, fmt.Errorf("parsing ABI: %w", err)
@@ -91,10 +127,10 @@ func NewChecker(rpcURL string, policyAddress string, cacheTTL time.Duration) (*C
 		policyAddr: common.HexToAddress(policyAddress),
 		policyABI:  parsedABI,
 		cacheTTL:   cacheTTL,
-		cache:      make(map[common.Address]cacheEntry),
+		cache:      lru.New[common.Address, cacheEntry](0),
 	}
 
-	go c.cleanup()
+	c.stopSweep = janitor.Register("nftcheck cache", c.removeExpired)
 	return c, nil
 }
 
@@ -103,27 +139,70 @@ func (c *Checker) SetDelegation(d DelegationFinder) {
 	c.delegation = d
 }
 
+// SetClock replaces the clock used to stamp and expire cached results. Call
+// it before the checker is in use.
+func (c *Checker) SetClock(clk clock.Clock) {
+	c.clock = clk
+}
+
+func (c *Checker) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
+
+// SetBlockPin makes checks read contract state at pin instead of the latest
+// block.
+func (c *Checker) SetBlockPin(pin BlockPin) {
+	c.pinner = &blockPinner{pin: pin, head: c.client.BlockNumber}
+}
+
+// SetRevertErrors makes a reverting AccessPolicy call come back from Check
+// as a *RevertError instead of a denial, for a FallbackChecker to act on.
+func (c *Checker) SetRevertErrors(on bool) {
+	c.revertErrs = on
+}
+
 // Check queries the AccessPolicy contract for a wallet's access tier.
 // If delegation is configured and the direct check returns denied,
 // it also checks cold wallets that have delegated to this wallet.
 // Results are cached for cacheTTL duration.
 func (c *Checker) Check(ctx context.Context, wallet common.Address) (CheckResult, error) {
 	// Check cache first
-	c.mu.RLock()
-	if entry, ok := c.cache[wallet]; ok && time.Now().Before(entry.expiresAt) {
-		c.mu.RUnlock()
-		return entry.result, nil
+	c.mu.Lock()
+	if entry, ok := c.cache.Get(wallet); ok {
+		if c.now().Before(entry.expiresAt) {
+			c.mu.Unlock()
+			return entry.result, nil
+		}
+		c.cache.Remove(wallet)
 	}
-	c.mu.RUnlock()
+	c.mu.Unlock()
 
-	// Direct on-chain check
+	return c.flight.do(ctx, wallet, c.Refresh)
+}
+
+// Refresh checks a wallet on-chain, bypassing the cache, and caches the
+// result. A result already cached keeps being served until it is replaced.
+func (c *Checker) Refresh(ctx context.Context, wallet common.Address) (CheckResult, error) {
+	// Direct on-chain check. A revert means the policy contract itself is
+	// broken or misconfigured: deny without caching, so access comes back as
+	// soon as it is fixed. Transport errors are returned for the caller to
+	// retry.
 	tier, err := c.checkOnChain(ctx, wallet)
+	if IsRevert(err) && !c.revertErrs {
+		log.Printf("[nftcheck] %v; denying (check --policy-contract)", err)
+		return CheckResult{Tier: TierDenied, CheckedAt: c.now(), Source: "access_policy"}, nil
+	}
 	if err != nil {
 		return CheckResult{}, err
 	}
 
 	// If direct check denied and delegation is configured, check vault wallets
+	var via common.Address
 	if tier == TierDenied && c.delegation != nil {
+		start := time.Now()
 		vaults, err := c.delegation.FindVaults(ctx, wallet)
 		if err != nil {
 			log.Printf("[nftcheck] delegation lookup failed: %v", err)
@@ -135,26 +214,29 @@ func (c *Checker) Check(ctx context.Context, wallet common.Address) (CheckResult
 				continue
 			}
 			if vaultTier > tier {
-				tier = vaultTier
-				log.Printf("[nftcheck] delegated access elevated tier=%s", tier)
+				tier, via = vaultTier, vault
+				connlog.Printf("[nftcheck] delegated access elevated tier=%s", tier)
 			}
 			if tier == TierFree {
 				break // best possible tier
 			}
 		}
+		checkDuration.ObserveSince(start, pathDelegation)
 	}
 
 	result := CheckResult{
 		Tier:      tier,
-		CheckedAt: time.Now(),
+		CheckedAt: c.now(),
+		Source:    "access_policy",
+		Vault:     via,
 	}
 
 	// Cache the result
 	c.mu.Lock()
-	c.cache[wallet] = cacheEntry{
+	c.cache.Add(wallet, cacheEntry{
 		result:    result,
-		expiresAt: time.Now().Add(c.cacheTTL),
-	}
+		expiresAt: c.now().Add(c.cacheTTL),
+	}, nil)
 	c.mu.Unlock()
 
 	return result, nil
@@ -162,17 +244,29 @@ func (c *Checker) Check(ctx context.Context, wallet common.Address) (CheckResult
 
 // checkOnChain calls AccessPolicy.checkAccess(address) and returns the tier.
 func (c *Checker) checkOnChain(ctx context.Context, wallet common.Address) (AccessTier, error) {
+	defer checkDuration.ObserveSince(time.Now(), pathPolicy)
+
 	callData, err := c.policyABI.Pack("checkAccess", wallet)
 	if err != nil {
 		return TierDenied, fmt.Errorf("packing call data: %w", err)
 	}
 
+	block, err := c.pinner.block(ctx)
+	if err != nil {
+		return TierDenied, err
+	}
+
+	start := time.Now()
 	output, err := c.client.CallContract(ctx, ethereum.CallMsg{
 		To:   &c.policyAddr,
 		Data: callData,
-	}, nil)
+	}, block)
+	metrics.ObserveRPC("checkAccess", start, err)
 	if err != nil {
-		return TierDenied, fmt.Errorf("calling AccessPolicy.checkAccess: %w", err)
+		return TierDenied, callError(c.policyAddr, "AccessPolicy.checkAccess", err)
+	}
+	if len(output) == 0 {
+		return TierDenied, emptyOutputError(c.policyAddr, "AccessPolicy.checkAccess")
 	}
 
 	results, err := c.policyABI.Unpack("checkAccess", output)
@@ -203,38 +297,46 @@ func (c *Checker) checkOnChain(ctx context.Context, wallet common.Address) (Acce
 	}
 }
 
+// SetMaxCacheEntries caps the number of cached results. When full, the least
+// recently used wallet is evicted. 0 means unlimited.
+func (c *Checker) SetMaxCacheEntries(max int) {
+	c.mu.Lock()
+	c.cache.SetMax(max, nil)
+	c.mu.Unlock()
+}
+
 // Invalidate removes a cached result for a wallet (used when transfer events are detected).
 func (c *Checker) Invalidate(wallet common.Address) {
 	c.mu.Lock()
-	delete(c.cache, wallet)
+	c.cache.Remove(wallet)
+	c.mu.Unlock()
+}
+
+// InvalidateAll empties the cache (used when the AccessPolicy itself changes).
+func (c *Checker) InvalidateAll() {
+	c.mu.Lock()
+	c.cache.RemoveFunc(func(common.Address, cacheEntry) bool { return true })
 	c.mu.Unlock()
 }
 
 // CacheSize returns the number of cached entries (for monitoring).
 func (c *Checker) CacheSize() int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return len(c.cache)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cache.Len()
 }
 
 // Close shuts down the Ethereum client connection.
 func (c *Checker) Close() {
+	c.stopSweep()
 	c.client.Close()
 }
 
-// cleanup periodically removes expired cache entries.
-func (c *Checker) cleanup() {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		c.mu.Lock()
-		now := time.Now()
-		for addr, entry := range c.cache {
-			if now.After(entry.expiresAt) {
-				delete(c.cache, addr)
-			}
-		}
-		c.mu.Unlock()
-	}
+// removeExpired drops cache entries past their TTL. Run by the janitor.
+func (c *Checker) removeExpired(now time.Time) {
+	c.mu.Lock()
+	c.cache.RemoveFunc(func(_ common.Address, entry cacheEntry) bool {
+		return now.After(entry.expiresAt)
+	})
+	c.mu.Unlock()
 }
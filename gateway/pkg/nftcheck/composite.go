@@ -0,0 +1,174 @@
+package nftcheck
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// CompositeChecker runs several AccessCheckers and grants the highest tier
+// any of them returns, e.g. free via the THIS card (DirectChecker), else paid
+// via an on-chain subscription (SubscriptionChecker).
+type CompositeChecker struct {
+	checkers []AccessChecker
+}
+
+// NewCompositeChecker combines checkers, consulted in order.
+func NewCompositeChecker(checkers ...AccessChecker) *CompositeChecker {
+	return &CompositeChecker{checkers: checkers}
+}
+
+// Check returns the best tier across all checkers, stopping early on
+// TierFree. A failing checker is skipped as long as another one grants
+// access; if none does, the errors are returned so a transient RPC failure
+// is not mistaken for a denial.
+func (c *CompositeChecker) Check(ctx context.Context, wallet common.Address) (CheckResult, error) {
+	return c.check(ctx, wallet, false)
+}
+
+// Refresh is Check with every checker that caches re-checking on-chain.
+func (c *CompositeChecker) Refresh(ctx context.Context, wallet common.Address) (CheckResult, error) {
+	return c.check(ctx, wallet, true)
+}
+
+func (c *CompositeChecker) check(ctx context.Context, wallet common.Address, refresh bool) (CheckResult, error) {
+	best := CheckResult{Tier: TierDenied, CheckedAt: time.Now()}
+	var errs []error
+
+	for _, checker := range c.checkers {
+		check := checker.Check
+		if r, ok := checker.(Refresher); refresh && ok {
+			check = r.Refresh
+		}
+		result, err := check(ctx, wallet)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if result.Tier > best.Tier {
+			best = result
+		}
+		if best.Tier == TierFree {
+			break
+		}
+	}
+
+	if best.Tier == TierDenied && len(errs) > 0 {
+		return CheckResult{}, errors.Join(errs...)
+	}
+	if len(errs) > 0 {
+		log.Printf("[nftcheck-composite] %d checker(s) failed, granting tier=%s from the rest", len(errs), best.Tier)
+	}
+	return best, nil
+}
+
+// Invalidate clears the wallet from every checker's cache.
+func (c *CompositeChecker) Invalidate(wallet common.Address) {
+	for _, checker := range c.checkers {
+		checker.Invalidate(wallet)
+	}
+}
+
+// Close closes every checker.
+func (c *CompositeChecker) Close() {
+	for _, checker := range c.checkers {
+		checker.Close()
+	}
+}
+
+// FallbackChecker consults a primary checker and turns to a fallback only
+// when the primary fails, e.g. the AccessPolicy contract first, as one cheap
+// call, then the Memes balances directly if the policy reverts or its RPC
+// call errors. Unlike CompositeChecker, a primary denial is final.
+type FallbackChecker struct {
+	primary  AccessChecker
+	fallback AccessChecker
+}
+
+// NewFallbackChecker checks primary, then fallback if primary errors.
+func NewFallbackChecker(primary, fallback AccessChecker) *FallbackChecker {
+	return &FallbackChecker{primary: primary, fallback: fallback}
+}
+
+// Check returns the primary's result, or the fallback's if the primary
+// fails. If both fail, both errors are returned.
+func (c *FallbackChecker) Check(ctx context.Context, wallet common.Address) (CheckResult, error) {
+	return c.check(ctx, wallet, false)
+}
+
+// Refresh is Check with each checker that caches re-checking on-chain.
+func (c *FallbackChecker) Refresh(ctx context.Context, wallet common.Address) (CheckResult, error) {
+	return c.check(ctx, wallet, true)
+}
+
+func (c *FallbackChecker) check(ctx context.Context, wallet common.Address, refresh bool) (CheckResult, error) {
+	check := func(checker AccessChecker) (CheckResult, error) {
+		if r, ok := checker.(Refresher); refresh && ok {
+			return r.Refresh(ctx, wallet)
+		}
+		return checker.Check(ctx, wallet)
+	}
+
+	result, err := check(c.primary)
+	if err == nil {
+		return result, nil
+	}
+	log.Printf("[nftcheck-fallback] primary check failed, using fallback: %v", err)
+	result, fallbackErr := check(c.fallback)
+	if fallbackErr != nil {
+		return CheckResult{}, errors.Join(err, fallbackErr)
+	}
+	return result, nil
+}
+
+// Invalidate clears the wallet from both checkers' caches.
+func (c *FallbackChecker) Invalidate(wallet common.Address) {
+	c.primary.Invalidate(wallet)
+	c.fallback.Invalidate(wallet)
+}
+
+// Close closes both checkers.
+func (c *FallbackChecker) Close() {
+	c.primary.Close()
+	c.fallback.Close()
+}
+
+// SubscriptionSource reports whether a wallet has an active on-chain
+// subscription. Implemented by subscriptionmgr.Manager.
+type SubscriptionSource interface {
+	HasActiveSubscription(ctx context.Context, user common.Address) (bool, error)
+}
+
+// SubscriptionChecker grants TierPaid to wallets with an active subscription.
+// It does not cache and does not own the source, so Close is a no-op.
+type SubscriptionChecker struct {
+	source SubscriptionSource
+}
+
+// NewSubscriptionChecker adapts a subscription source to AccessChecker.
+func NewSubscriptionChecker(source SubscriptionSource) *SubscriptionChecker {
+	return &SubscriptionChecker{source: source}
+}
+
+// Check returns TierPaid if the wallet's subscription is active.
+func (c *SubscriptionChecker) Check(ctx context.Context, wallet common.Address) (CheckResult, error) {
+	active, err := c.source.HasActiveSubscription(ctx, wallet)
+	if err != nil {
+		return CheckResult{}, err
+	}
+	tier := TierDenied
+	if active {
+		tier = TierPaid
+	}
+	return CheckResult{Tier: tier, CheckedAt: time.Now(), Source: "subscription"}, nil
+}
+
+// Invalidate is a no-op; the subscription source keeps its own cache, which
+// follows subscription events rather than token transfers.
+func (c *SubscriptionChecker) Invalidate(common.Address) {}
+
+// Close is a no-op; the caller owns the subscription source.
+func (c *SubscriptionChecker) Close() {}
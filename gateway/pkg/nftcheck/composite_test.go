@@ -0,0 +1,179 @@
+package nftcheck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type mockChecker struct {
+	tier        AccessTier
+	err         error
+	calls       int
+	invalidated int
+	closed      bool
+}
+
+func (m *mockChecker) Check(context.Context, common.Address) (CheckResult, error) {
+	m.calls++
+	if m.err != nil {
+		return CheckResult{}, m.err
+	}
+	return CheckResult{Tier: m.tier}, nil
+}
+
+func (m *mockChecker) Invalidate(common.Address) { m.invalidated++ }
+func (m *mockChecker) Close()                    { m.closed = true }
+
+type mockSubscriptions struct {
+	active bool
+	err    error
+}
+
+func (m mockSubscriptions) HasActiveSubscription(context.Context, common.Address) (bool, error) {
+	return m.active, m.err
+}
+
+func TestCompositeCheckerHighestTier(t *testing.T) {
+	rpcDown := errors.New("rpc down")
+
+	tests := []struct {
+		name     string
+		checkers []*mockChecker
+		wantTier AccessTier
+		wantErr  bool
+	}{
+		{"all denied", []*mockChecker{{tier: TierDenied}, {tier: TierDenied}}, TierDenied, false},
+		{"paid wins over denied", []*mockChecker{{tier: TierDenied}, {tier: TierPaid}}, TierPaid, false},
+		{"free wins over paid", []*mockChecker{{tier: TierPaid}, {tier: TierFree}}, TierFree, false},
+		{"error ignored when another grants", []*mockChecker{{err: rpcDown}, {tier: TierPaid}}, TierPaid, false},
+		{"error surfaces when none grants", []*mockChecker{{err: rpcDown}, {tier: TierDenied}}, TierDenied, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkers := make([]AccessChecker, len(tt.checkers))
+			for i, c := range tt.checkers {
+				checkers[i] = c
+			}
+			result, err := NewCompositeChecker(checkers...).Check(context.Background(), common.Address{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && result.Tier != tt.wantTier {
+				t.Fatalf("tier = %s, want %s", result.Tier, tt.wantTier)
+			}
+		})
+	}
+}
+
+func TestCompositeCheckerShortCircuitsOnFree(t *testing.T) {
+	direct := &mockChecker{tier: TierFree}
+	subs := &mockChecker{tier: TierPaid}
+	c := NewCompositeChecker(direct, subs)
+
+	result, err := c.Check(context.Background(), common.Address{})
+	if err != nil || result.Tier != TierFree {
+		t.Fatalf("Check = %v, %v; want free", result.Tier, err)
+	}
+	if subs.calls != 0 {
+		t.Errorf("expected later checker to be skipped after free, got %d calls", subs.calls)
+	}
+
+	c.Invalidate(common.Address{})
+	c.Close()
+	for _, m := range []*mockChecker{direct, subs} {
+		if m.invalidated != 1 || !m.closed {
+			t.Errorf("expected Invalidate and Close to fan out, got %+v", m)
+		}
+	}
+}
+
+func TestCompositeWithSubscriptionChecker(t *testing.T) {
+	direct := &mockChecker{tier: TierDenied}
+
+	c := NewCompositeChecker(direct, NewSubscriptionChecker(mockSubscriptions{active: true}))
+	result, err := c.Check(context.Background(), common.Address{})
+	if err != nil || result.Tier != TierPaid {
+		t.Fatalf("subscriber without card: got %v, %v; want paid", result.Tier, err)
+	}
+
+	c = NewCompositeChecker(direct, NewSubscriptionChecker(mockSubscriptions{}))
+	result, err = c.Check(context.Background(), common.Address{})
+	if err != nil || result.Tier != TierDenied {
+		t.Fatalf("no card, no subscription: got %v, %v; want denied", result.Tier, err)
+	}
+
+	c = NewCompositeChecker(direct, NewSubscriptionChecker(mockSubscriptions{err: errors.New("rpc down")}))
+	if _, err := c.Check(context.Background(), common.Address{}); err == nil {
+		t.Fatal("expected subscription lookup error to surface")
+	}
+}
+
+// revertingPolicyRPC answers every eth_call the way a node does when the
+// contract reverts.
+func revertingPolicyRPC(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      req.ID,
+			"error":   map[string]any{"code": 3, "message": "execution reverted"},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFallbackCheckerUsesFallbackOnPolicyRevert(t *testing.T) {
+	policy, err := NewChecker(revertingPolicyRPC(t).URL, "0x00000000000000000000000000000000000000aa", time.Minute)
+	if err != nil {
+		t.Fatalf("NewChecker: %v", err)
+	}
+	policy.SetRevertErrors(true)
+	direct := &mockChecker{tier: TierPaid}
+	c := NewFallbackChecker(policy, direct)
+	defer c.Close()
+
+	res, err := c.Check(context.Background(), common.HexToAddress("0xbeef"))
+	if err != nil || res.Tier != TierPaid {
+		t.Fatalf("Check = %v, %v; want the fallback's paid tier", res.Tier, err)
+	}
+	if direct.calls != 1 {
+		t.Errorf("fallback called %d times, want 1", direct.calls)
+	}
+}
+
+func TestFallbackCheckerSemantics(t *testing.T) {
+	rpcDown := errors.New("rpc down")
+
+	// A primary denial is final.
+	fallback := &mockChecker{tier: TierFree}
+	res, err := NewFallbackChecker(&mockChecker{tier: TierDenied}, fallback).Check(context.Background(), common.Address{})
+	if err != nil || res.Tier != TierDenied || fallback.calls != 0 {
+		t.Errorf("primary denial: got %v, %v with %d fallback calls; want denied, no fallback", res.Tier, err, fallback.calls)
+	}
+
+	// A transport error falls back too.
+	res, err = NewFallbackChecker(&mockChecker{err: rpcDown}, &mockChecker{tier: TierFree}).Check(context.Background(), common.Address{})
+	if err != nil || res.Tier != TierFree {
+		t.Errorf("primary error: got %v, %v; want the fallback's free tier", res.Tier, err)
+	}
+
+	// Both failing surfaces the errors rather than a denial.
+	_, err = NewFallbackChecker(&mockChecker{err: rpcDown}, &mockChecker{err: errors.New("also down")}).Check(context.Background(), common.Address{})
+	if !errors.Is(err, rpcDown) {
+		t.Errorf("both failing: got %v, want the joined errors", err)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"math/big"
 	"strings"
 	"sync"
 	"time"
@@ -12,23 +11,36 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/connlog"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/janitor"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/lru"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/metrics"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
 )
 
 // DirectChecker queries an ERC-1155 contract's balanceOfBatch directly,
 // without needing a deployed AccessPolicy contract. This is the preferred
 // mode for mainnet where we check against the real Memes contract.
 type DirectChecker struct {
-	client     *ethclient.Client
+	client     ChainReader
 	memesAddr  common.Address
 	erc1155ABI abi.ABI
-	thisCardID int64 // token ID that grants free tier
-	maxTokenID int64 // highest token ID to check
+	thisCardID int64   // token ID that grants free tier
+	maxTokenID int64   // highest token ID to check; guarded by mu once discovery runs
+	tokenIDs   []int64 // explicit IDs to check instead of 1..maxTokenID; see SetTokenIDs
 	cacheTTL   time.Duration
 	delegation DelegationFinder
+	balances   BalanceSource // lists held token IDs; the free card is always read on-chain
+	pinner     *blockPinner  // nil reads the latest block
+	clock      clock.Clock   // nil means the wall clock
+	flight     checkFlight   // shares cache-miss lookups between concurrent checks
 
-	mu    sync.RWMutex
-	cache map[common.Address]cacheEntry
+	mu            sync.Mutex
+	cache         *lru.Cache[common.Address, cacheEntry]
+	stopSweep     func()
+	stopDiscovery func() // set by StartMaxTokenIDDiscovery
 }
 
 // ERC-1155 balanceOfBatch: check multiple token IDs for one address in a single call
@@ -50,27 +62,44 @@ const erc1155ABIJSON = `[
 //   - thisCardID: token ID that grants free tier (0 = no free tier)
 //   - maxTokenID: highest token ID to check (e.g. 300 for ~300 Memes cards)
 func NewDirectChecker(rpcURL, memesContract string, thisCardID, maxTokenID int64, cacheTTL time.Duration) (*DirectChecker, error) {
-	client, err := ethclient.Dial(rpcURL)
+	client, err := outbound.Dial(rpcURL)
 	if err != nil {
 		return nil, fmt.Errorf("connecting to Ethereum RPC: %w", err)
 	}
+	c, err := NewDirectCheckerWithClient(client, memesContract, thisCardID, maxTokenID, cacheTTL)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return c, nil
+}
 
+// NewDirectCheckerWithClient creates a direct checker on an existing client,
+// e.g. an ethrpc.Fake in tests. Close closes the client.
+func NewDirectCheckerWithClient(client ChainReader, memesContract string, thisCardID, maxTokenID int64, cacheTTL time.Duration) (*DirectChecker, error) {
 	parsed, err := abi.JSON(strings.NewReader(erc1155ABIJSON))
 	if err != nil {
 		return nil, fmt.Errorf("parsing ERC-1155 ABI: %w", err)
 	}
 
+	memesAddr := common.HexToAddress(memesContract)
+	balances, err := newRPCBalanceSource(client, memesAddr)
+	if err != nil {
+		return nil, err
+	}
+
 	c := &DirectChecker{
 		client:     client,
-		memesAddr:  common.HexToAddress(memesContract),
+		memesAddr:  memesAddr,
 		erc1155ABI: parsed,
 		thisCardID: thisCardID,
 		maxTokenID: maxTokenID,
 		cacheTTL:   cacheTTL,
-		cache:      make(map[common.Address]cacheEntry),
+		balances:   balances,
+		cache:      lru.New[common.Address, cacheEntry](0),
 	}
 
-	go c.cleanup()
+	c.stopSweep = janitor.Register("nftcheck-direct cache", c.removeExpired)
 	return c, nil
 }
 
@@ -79,23 +108,76 @@ func (c *DirectChecker) SetDelegation(d DelegationFinder) {
 	c.delegation = d
 }
 
+// SetBalanceSource replaces the default balanceOfBatch scan used to find
+// which Memes cards a wallet holds.
+func (c *DirectChecker) SetBalanceSource(src BalanceSource) {
+	c.balances = src
+}
+
+// UseBalanceSource switches to a named source (see NewBalanceSource) backed
+// by the checker's own RPC client.
+func (c *DirectChecker) UseBalanceSource(name, nftAPIURL string) error {
+	src, err := NewBalanceSource(name, c.client, c.memesAddr, nftAPIURL)
+	if err != nil {
+		return err
+	}
+	c.balances = src
+	return nil
+}
+
+// SetClock replaces the clock used to stamp and expire cached results. Call
+// it before the checker is in use.
+func (c *DirectChecker) SetClock(clk clock.Clock) {
+	c.clock = clk
+}
+
+func (c *DirectChecker) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
+
+// SetBlockPin makes checks read balances at pin instead of the latest block.
+// The NFT API balance source always reports current holdings.
+func (c *DirectChecker) SetBlockPin(pin BlockPin) {
+	c.pinner = &blockPinner{pin: pin, head: c.client.BlockNumber}
+}
+
 // Check queries the Memes contract for a wallet's access tier.
 func (c *DirectChecker) Check(ctx context.Context, wallet common.Address) (CheckResult, error) {
 	// Check cache
-	c.mu.RLock()
-	if entry, ok := c.cache[wallet]; ok && time.Now().Before(entry.expiresAt) {
-		c.mu.RUnlock()
-		return entry.result, nil
+	c.mu.Lock()
+	if entry, ok := c.cache.Get(wallet); ok {
+		if c.now().Before(entry.expiresAt) {
+			c.mu.Unlock()
+			return entry.result, nil
+		}
+		c.cache.Remove(wallet)
 	}
-	c.mu.RUnlock()
+	c.mu.Unlock()
 
+	return c.flight.do(ctx, wallet, c.Refresh)
+}
+
+// Refresh checks a wallet on-chain, bypassing the cache, and caches the
+// result, as Checker.Refresh does.
+func (c *DirectChecker) Refresh(ctx context.Context, wallet common.Address) (CheckResult, error) {
+	// As in Checker.Check, a revert denies without caching and a transport
+	// error is returned.
 	tier, err := c.checkDirect(ctx, wallet)
+	if IsRevert(err) {
+		log.Printf("[nftcheck-direct] %v; denying (check --memes-contract)", err)
+		return CheckResult{Tier: TierDenied, CheckedAt: c.now(), Source: "direct"}, nil
+	}
 	if err != nil {
 		return CheckResult{}, err
 	}
 
 	// If denied and delegation configured, check vaults
+	var via common.Address
 	if tier == TierDenied && c.delegation != nil {
+		start := time.Now()
 		vaults, err := c.delegation.FindVaults(ctx, wallet)
 		if err != nil {
 			log.Printf("[nftcheck-direct] delegation lookup failed: %v", err)
@@ -107,120 +189,134 @@ func (c *DirectChecker) Check(ctx context.Context, wallet common.Address) (Check
 				continue
 			}
 			if vaultTier > tier {
-				tier = vaultTier
-				log.Printf("[nftcheck-direct] delegated access elevated tier=%s", tier)
+				tier, via = vaultTier, vault
+				connlog.Printf("[nftcheck-direct] delegated access elevated tier=%s", tier)
 			}
 			if tier == TierFree {
 				break
 			}
 		}
+		checkDuration.ObserveSince(start, pathDelegation)
 	}
 
-	result := CheckResult{Tier: tier, CheckedAt: time.Now()}
+	result := CheckResult{Tier: tier, CheckedAt: c.now(), Source: "direct", Vault: via}
 
 	c.mu.Lock()
-	c.cache[wallet] = cacheEntry{result: result, expiresAt: time.Now().Add(c.cacheTTL)}
+	c.cache.Add(wallet, cacheEntry{result: result, expiresAt: c.now().Add(c.cacheTTL)}, nil)
 	c.mu.Unlock()
 
 	return result, nil
 }
 
-// checkDirect calls balanceOfBatch to check token ownership.
-// We batch check in groups of 50 to stay within gas limits.
+// checkDirect reads the THIS card balance on-chain, then asks the balance
+// source whether the wallet holds any other Memes card. The free-tier card
+// never depends on an off-chain indexer.
 func (c *DirectChecker) checkDirect(ctx context.Context, wallet common.Address) (AccessTier, error) {
-	hasThisCard := false
-	hasAnyCard := false
-
-	batchSize := int64(50)
-	for start := int64(1); start <= c.maxTokenID; start += batchSize {
-		end := start + batchSize - 1
-		if end > c.maxTokenID {
-			end = c.maxTokenID
-		}
+	tier, _, err := c.lookupTokens(ctx, wallet, false)
+	return tier, err
+}
 
-		count := end - start + 1
-		accounts := make([]common.Address, count)
-		ids := make([]*big.Int, count)
-		for i := int64(0); i < count; i++ {
-			accounts[i] = wallet
-			ids[i] = big.NewInt(start + i)
-		}
+// lookupTokens does the work of checkDirect and returns the held card IDs
+// that decided the tier. Unless all is set it stops at the THIS card, which
+// is all a check needs; Explain sets it to list every card held.
+func (c *DirectChecker) lookupTokens(ctx context.Context, wallet common.Address, all bool) (AccessTier, []int64, error) {
+	defer checkDuration.ObserveSince(time.Now(), pathDirect)
 
-		callData, err := c.erc1155ABI.Pack("balanceOfBatch", accounts, ids)
-		if err != nil {
-			return TierDenied, fmt.Errorf("packing balanceOfBatch: %w", err)
-		}
+	block, err := c.pinner.block(ctx)
+	if err != nil {
+		return TierDenied, nil, err
+	}
+	ctx = withBlock(ctx, block)
 
-		output, err := c.client.CallContract(ctx, ethereum.CallMsg{
-			To:   &c.memesAddr,
-			Data: callData,
-		}, nil)
+	tier := TierDenied
+	var tokens []int64
+	if c.thisCardID > 0 {
+		held, err := c.holdsToken(ctx, wallet, c.thisCardID)
 		if err != nil {
-			return TierDenied, fmt.Errorf("calling balanceOfBatch: %w", err)
+			return TierDenied, nil, err
 		}
-
-		results, err := c.erc1155ABI.Unpack("balanceOfBatch", output)
-		if err != nil {
-			return TierDenied, fmt.Errorf("unpacking balanceOfBatch: %w", err)
+		if held {
+			if !all {
+				return TierFree, []int64{c.thisCardID}, nil
+			}
+			tier, tokens = TierFree, []int64{c.thisCardID}
 		}
+	}
 
-		balances, ok := results[0].([]*big.Int)
-		if !ok {
-			return TierDenied, fmt.Errorf("unexpected type for balances: %T", results[0])
-		}
+	owned, err := c.balances.OwnedTokens(ctx, wallet, c.scanIDs())
+	if err != nil {
+		return TierDenied, nil, err
+	}
+	tokens = append(tokens, owned...)
+	if tier == TierDenied && len(owned) > 0 {
+		tier = TierPaid
+	}
+	return tier, tokens, nil
+}
 
-		for i, bal := range balances {
-			if bal.Sign() > 0 {
-				hasAnyCard = true
-				tokenID := start + int64(i)
-				if tokenID == c.thisCardID {
-					hasThisCard = true
-				}
-			}
-		}
+// holdsToken reports whether wallet has a non-zero balance of tokenID.
+func (c *DirectChecker) holdsToken(ctx context.Context, wallet common.Address, tokenID int64) (bool, error) {
+	callData, err := packBalanceOfBatch(c.erc1155ABI, wallet, []int64{tokenID})
+	if err != nil {
+		return false, err
+	}
 
-		// Early exit if we already found the best tier
-		if hasThisCard {
-			return TierFree, nil
-		}
+	start := time.Now()
+	output, err := c.client.CallContract(ctx, ethereum.CallMsg{
+		To:   &c.memesAddr,
+		Data: callData,
+	}, blockFromContext(ctx))
+	metrics.ObserveRPC("balanceOfBatch", start, err)
+	if err != nil {
+		return false, callError(c.memesAddr, "balanceOfBatch", err)
+	}
+	if len(output) == 0 {
+		return false, emptyOutputError(c.memesAddr, "balanceOfBatch")
 	}
 
-	if hasAnyCard {
-		return TierPaid, nil
+	held, err := unpackBalances(c.erc1155ABI, output, []int64{tokenID})
+	if err != nil {
+		return false, err
 	}
-	return TierDenied, nil
+	return len(held) > 0, nil
+}
+
+// SetMaxCacheEntries caps the number of cached results. When full, the least
+// recently used wallet is evicted. 0 means unlimited.
+func (c *DirectChecker) SetMaxCacheEntries(max int) {
+	c.mu.Lock()
+	c.cache.SetMax(max, nil)
+	c.mu.Unlock()
 }
 
 // Invalidate removes a cached result for a wallet.
 func (c *DirectChecker) Invalidate(wallet common.Address) {
 	c.mu.Lock()
-	delete(c.cache, wallet)
+	c.cache.Remove(wallet)
 	c.mu.Unlock()
 }
 
 // CacheSize returns the number of cached entries.
 func (c *DirectChecker) CacheSize() int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return len(c.cache)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cache.Len()
 }
 
 // Close shuts down the Ethereum client connection.
 func (c *DirectChecker) Close() {
+	if c.stopDiscovery != nil {
+		c.stopDiscovery()
+	}
+	c.stopSweep()
 	c.client.Close()
 }
 
-func (c *DirectChecker) cleanup() {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-	for range ticker.C {
-		c.mu.Lock()
-		now := time.Now()
-		for addr, entry := range c.cache {
-			if now.After(entry.expiresAt) {
-				delete(c.cache, addr)
-			}
-		}
-		c.mu.Unlock()
-	}
+// removeExpired drops cache entries past their TTL. Run by the janitor.
+func (c *DirectChecker) removeExpired(now time.Time) {
+	c.mu.Lock()
+	c.cache.RemoveFunc(func(_ common.Address, entry cacheEntry) bool {
+		return now.After(entry.expiresAt)
+	})
+	c.mu.Unlock()
 }
@@ -0,0 +1,410 @@
+package nftcheck
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/lru"
+)
+
+func TestDirectCheckerCacheCap(t *testing.T) {
+	memes := newFakeMemes(t, 7)
+	src, err := NewBalanceSource(BalanceSourceRPC, memes, testMemes, "")
+	if err != nil {
+		t.Fatalf("NewBalanceSource: %v", err)
+	}
+	c := &DirectChecker{
+		maxTokenID: 10,
+		cacheTTL:   time.Hour,
+		balances:   src,
+		cache:      lru.New[common.Address, cacheEntry](0),
+	}
+	c.SetMaxCacheEntries(3)
+
+	// Denied results are cached too, so a flood of random wallets must not
+	// grow the cache past its cap.
+	ctx := context.Background()
+	for i := 0; i < 50; i++ {
+		if _, err := c.Check(ctx, common.BigToAddress(big.NewInt(int64(i+1)))); err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+	}
+	if n := c.CacheSize(); n != 3 {
+		t.Fatalf("CacheSize = %d, want 3", n)
+	}
+
+	// The most recent wallets are still served from cache.
+	calls := memes.calls
+	if _, err := c.Check(ctx, common.BigToAddress(big.NewInt(50))); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if memes.calls != calls {
+		t.Errorf("expected cache hit for recent wallet, got %d new eth_calls", memes.calls-calls)
+	}
+}
+
+func TestDirectCheckerCacheExpiry(t *testing.T) {
+	memes := newFakeMemes(t, 7)
+	src, err := NewBalanceSource(BalanceSourceRPC, memes, testMemes, "")
+	if err != nil {
+		t.Fatalf("NewBalanceSource: %v", err)
+	}
+	clk := clock.NewFake(time.Now())
+	c := &DirectChecker{
+		maxTokenID: 10,
+		cacheTTL:   time.Minute,
+		balances:   src,
+		cache:      lru.New[common.Address, cacheEntry](0),
+	}
+	c.SetClock(clk)
+
+	ctx := context.Background()
+	wallet := common.HexToAddress("0x1")
+	check := func() {
+		t.Helper()
+		if res, err := c.Check(ctx, wallet); err != nil || res.Tier != TierPaid {
+			t.Fatalf("Check = %v, %v; want paid", res.Tier, err)
+		}
+	}
+
+	check()
+	calls := memes.calls
+	clk.Advance(59 * time.Second)
+	check()
+	if memes.calls != calls {
+		t.Fatalf("expected cache hit within TTL, got %d new eth_calls", memes.calls-calls)
+	}
+
+	clk.Advance(2 * time.Second)
+	check()
+	if memes.calls == calls {
+		t.Fatal("expected a fresh on-chain check after the TTL")
+	}
+
+	// The janitor sweep drops entries by the same clock.
+	clk.Advance(2 * time.Minute)
+	c.removeExpired(clk.Now())
+	if n := c.CacheSize(); n != 0 {
+		t.Errorf("CacheSize after sweep = %d, want 0", n)
+	}
+}
+
+// fakeSupply answers totalSupply(id) for a collection minted up to minted.
+type fakeSupply struct {
+	t      *testing.T
+	minted int64
+	err    error
+	calls  int
+	abi    abi.ABI
+}
+
+func newFakeSupply(t *testing.T, minted int64) *fakeSupply {
+	t.Helper()
+	parsed, err := abi.JSON(strings.NewReader(totalSupplyABIJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeSupply{t: t, minted: minted, abi: parsed}
+}
+
+func (f *fakeSupply) CallContract(_ context.Context, msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	args, err := f.abi.Methods["totalSupply"].Inputs.Unpack(msg.Data[4:])
+	if err != nil {
+		f.t.Fatalf("unpacking totalSupply args: %v", err)
+	}
+	supply := big.NewInt(0)
+	if id := args[0].(*big.Int).Int64(); id >= 1 && id <= f.minted {
+		supply.SetInt64(100)
+	}
+	return f.abi.Methods["totalSupply"].Outputs.Pack(supply)
+}
+
+func TestDiscoverMaxTokenID(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		minted, hint int64
+	}{
+		{1, 0},
+		{350, 350},
+		{412, 350},
+		{412, 500}, // configured too high
+		{5000, 350},
+	} {
+		supply := newFakeSupply(t, tt.minted)
+		got, err := DiscoverMaxTokenID(ctx, supply, testMemes, tt.hint)
+		if err != nil {
+			t.Fatalf("minted=%d hint=%d: %v", tt.minted, tt.hint, err)
+		}
+		if got != tt.minted {
+			t.Errorf("minted=%d hint=%d: discovered %d", tt.minted, tt.hint, got)
+		}
+		if supply.calls > 40 {
+			t.Errorf("minted=%d hint=%d: %d eth_calls", tt.minted, tt.hint, supply.calls)
+		}
+	}
+}
+
+func TestDiscoverMaxTokenIDUnsupported(t *testing.T) {
+	supply := newFakeSupply(t, 0)
+	if _, err := DiscoverMaxTokenID(context.Background(), supply, testMemes, 350); err == nil {
+		t.Error("expected an error when token 1 has no supply")
+	}
+
+	supply.err = errors.New("execution reverted")
+	if _, err := DiscoverMaxTokenID(context.Background(), supply, testMemes, 350); err == nil {
+		t.Error("expected an error when totalSupply reverts")
+	}
+}
+
+func TestRaiseMaxTokenID(t *testing.T) {
+	memes := newFakeMemes(t, 420)
+	src, err := NewBalanceSource(BalanceSourceRPC, memes, testMemes, "")
+	if err != nil {
+		t.Fatalf("NewBalanceSource: %v", err)
+	}
+	c := &DirectChecker{
+		maxTokenID: 350,
+		cacheTTL:   time.Hour,
+		balances:   src,
+		cache:      lru.New[common.Address, cacheEntry](0),
+	}
+	ctx := context.Background()
+	wallet := common.HexToAddress("0x1")
+
+	if res, err := c.Check(ctx, wallet); err != nil || res.Tier != TierDenied {
+		t.Fatalf("Check before raise = %v, %v; want denied", res.Tier, err)
+	}
+
+	// Discovery never lowers the configured floor.
+	if got := c.raiseMaxTokenID(300); got != 350 {
+		t.Fatalf("raiseMaxTokenID(300) = %d, want 350", got)
+	}
+	if got := c.raiseMaxTokenID(420); got != 420 || c.MaxTokenID() != 420 {
+		t.Fatalf("raiseMaxTokenID(420) = %d, MaxTokenID = %d", got, c.MaxTokenID())
+	}
+
+	// The cached denial is dropped so the new card counts straight away.
+	if res, err := c.Check(ctx, wallet); err != nil || res.Tier != TierPaid {
+		t.Fatalf("Check after raise = %v, %v; want paid", res.Tier, err)
+	}
+}
+
+func TestParseTokenIDs(t *testing.T) {
+	got, err := ParseTokenIDs(" 90-92, 7,1-3,2 ")
+	if err != nil {
+		t.Fatalf("ParseTokenIDs: %v", err)
+	}
+	if want := []int64{1, 2, 3, 7, 90, 91, 92}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseTokenIDs = %v, want %v", got, want)
+	}
+	for _, bad := range []string{"", ",", "0", "-3", "5-2", "a", "1-x", "1-20000"} {
+		if _, err := ParseTokenIDs(bad); err == nil {
+			t.Errorf("ParseTokenIDs(%q): expected error", bad)
+		}
+	}
+}
+
+func TestDirectCheckerTokenIDList(t *testing.T) {
+	memes := newFakeMemes(t, 5, 40)
+	src, err := NewBalanceSource(BalanceSourceRPC, memes, testMemes, "")
+	if err != nil {
+		t.Fatalf("NewBalanceSource: %v", err)
+	}
+	c := &DirectChecker{
+		maxTokenID: 350,
+		cacheTTL:   time.Hour,
+		balances:   src,
+		cache:      lru.New[common.Address, cacheEntry](0),
+	}
+	c.SetTokenIDs([]int64{2, 40, 77})
+	ctx := context.Background()
+
+	holder, other := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	_, tokens, err := c.lookupTokens(ctx, holder, true)
+	if err != nil || !reflect.DeepEqual(tokens, []int64{40}) {
+		t.Fatalf("lookupTokens = %v, %v; want [40]", tokens, err)
+	}
+	if memes.queried != 3 {
+		t.Errorf("queried %d token IDs, want only the 3 listed", memes.queried)
+	}
+
+	// Card 5 is held but not listed, so it does not qualify.
+	memes.held = map[int64]bool{5: true}
+	if res, err := c.Check(ctx, other); err != nil || res.Tier != TierDenied {
+		t.Fatalf("Check = %v, %v; want denied for an unlisted card", res.Tier, err)
+	}
+}
+
+// gatedCaller holds every eth_call until release is closed, then answers it
+// from inner, counting calls.
+type gatedCaller struct {
+	inner   *fakeMemes
+	release chan struct{}
+	started chan struct{} // receives once per call
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (g *gatedCaller) CallContract(ctx context.Context, msg ethereum.CallMsg, block *big.Int) ([]byte, error) {
+	g.started <- struct{}{}
+	<-g.release
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.calls++
+	return g.inner.CallContract(ctx, msg, block)
+}
+
+func TestDirectCheckerSharesConcurrentLookups(t *testing.T) {
+	gate := &gatedCaller{inner: newFakeMemes(t, 7), release: make(chan struct{}), started: make(chan struct{}, 100)}
+	src, err := NewBalanceSource(BalanceSourceRPC, gate, testMemes, "")
+	if err != nil {
+		t.Fatalf("NewBalanceSource: %v", err)
+	}
+	c := &DirectChecker{
+		maxTokenID: 10,
+		cacheTTL:   time.Hour,
+		balances:   src,
+		cache:      lru.New[common.Address, cacheEntry](0),
+	}
+	wallet := common.HexToAddress("0x1")
+
+	// A caller that gives up does not fail the lookup for the rest.
+	impatient, cancel := context.WithCancel(context.Background())
+	impatientErr := make(chan error, 1)
+	go func() {
+		_, err := c.Check(impatient, wallet)
+		impatientErr <- err
+	}()
+	<-gate.started
+	cancel()
+	if err := <-impatientErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled Check = %v, want context.Canceled", err)
+	}
+
+	var wg sync.WaitGroup
+	tiers := make(chan AccessTier, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := c.Check(context.Background(), wallet)
+			if err != nil {
+				t.Errorf("Check: %v", err)
+			}
+			tiers <- res.Tier
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(gate.release)
+	wg.Wait()
+	close(tiers)
+
+	for tier := range tiers {
+		if tier != TierPaid {
+			t.Errorf("tier = %s, want paid", tier)
+		}
+	}
+	if gate.calls != 1 {
+		t.Errorf("%d eth_calls for 21 concurrent checks of one wallet, want 1", gate.calls)
+	}
+}
+
+// revertingSource fails every lookup with err.
+type revertingSource struct {
+	err   error
+	calls int
+}
+
+func (s *revertingSource) OwnedTokens(context.Context, common.Address, []int64) ([]int64, error) {
+	s.calls++
+	return nil, s.err
+}
+
+func TestDirectCheckerRevertDeniesWithoutCaching(t *testing.T) {
+	src := &revertingSource{err: &RevertError{Contract: testMemes, Method: "balanceOfBatch"}}
+	c := &DirectChecker{
+		maxTokenID: 10,
+		cacheTTL:   time.Hour,
+		balances:   src,
+		cache:      lru.New[common.Address, cacheEntry](0),
+	}
+	ctx := context.Background()
+	wallet := common.HexToAddress("0x1")
+
+	res, err := c.Check(ctx, wallet)
+	if err != nil || res.Tier != TierDenied {
+		t.Fatalf("Check on revert = %v, %v; want denied with no error", res.Tier, err)
+	}
+	if c.CacheSize() != 0 {
+		t.Error("revert denial was cached")
+	}
+
+	// A transport failure is returned for the caller to retry.
+	src.err = errors.New("connection refused")
+	if _, err := c.Check(ctx, wallet); err == nil || IsRevert(err) {
+		t.Fatalf("Check on transport error = %v, want a non-revert error", err)
+	}
+	if src.calls != 2 {
+		t.Errorf("OwnedTokens called %d times, want 2", src.calls)
+	}
+}
+
+type codedRPCError struct {
+	code int
+	msg  string
+	data any
+}
+
+func (e codedRPCError) Error() string  { return e.msg }
+func (e codedRPCError) ErrorCode() int { return e.code }
+func (e codedRPCError) ErrorData() any { return e.data }
+
+func TestCallErrorClassifiesReverts(t *testing.T) {
+	// Error("not initialized") as returned by geth in the error data.
+	reason, err := abi.JSON(strings.NewReader(`[{"type":"function","name":"Error","inputs":[{"name":"","type":"string"}]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := reason.Pack("Error", "not initialized")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = callError(testMemes, "checkAccess", codedRPCError{3, "execution reverted: not initialized", hexutil.Encode(data)})
+	var revert *RevertError
+	if !errors.As(err, &revert) || revert.Reason != "not initialized" {
+		t.Fatalf("callError(revert) = %v, want RevertError with decoded reason", err)
+	}
+
+	err = callError(testMemes, "checkAccess", codedRPCError{-32000, "execution reverted", nil})
+	if !IsRevert(err) {
+		t.Errorf("callError(reverted without data) = %v, want RevertError", err)
+	}
+
+	for _, transport := range []error{
+		errors.New("dial tcp: connection refused"),
+		context.DeadlineExceeded,
+		codedRPCError{-32005, "rate limited", nil},
+	} {
+		if err := callError(testMemes, "checkAccess", transport); IsRevert(err) || !errors.Is(err, transport) {
+			t.Errorf("callError(%v) = %v, want wrapped transport error", transport, err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package nftcheck
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// WalletCheck is the uncached tier of a single wallet.
+type WalletCheck struct {
+	Wallet   common.Address
+	Tier     AccessTier
+	TokenIDs []int64 // cards behind the tier, for checkers that read balances
+	Err      error
+}
+
+// Explanation breaks one checker's decision down into the lookups behind
+// it: the wallet's own holdings and any delegated vaults.
+type Explanation struct {
+	Source        string        // "access_policy", "direct", "subscription", ...
+	Direct        WalletCheck   // the wallet's own tier
+	Vaults        []WalletCheck // delegated cold wallets, when delegation is configured
+	DelegationErr error         // vault lookup failure, if any
+	Tier          AccessTier    // best tier this source grants
+}
+
+// Explainer is implemented by checkers that can break a decision down for
+// support tooling. Unlike Check, Explain bypasses the cache and always looks
+// up delegated vaults so the caller sees every path to access.
+type Explainer interface {
+	Explain(ctx context.Context, wallet common.Address) []Explanation
+}
+
+// Explain runs checker uncached if it implements Explainer; otherwise it
+// falls back to a plain Check attributed to the wallet itself.
+func Explain(ctx context.Context, checker AccessChecker, wallet common.Address) []Explanation {
+	if e, ok := checker.(Explainer); ok {
+		return e.Explain(ctx, wallet)
+	}
+	result, err := checker.Check(ctx, wallet)
+	return []Explanation{{
+		Source: "checker",
+		Direct: WalletCheck{Wallet: wallet, Tier: result.Tier, Err: err},
+		Tier:   result.Tier,
+	}}
+}
+
+// explainWithDelegation checks the wallet and every delegated vault with
+// lookup, keeping the best tier.
+func explainWithDelegation(ctx context.Context, source string, wallet common.Address, delegation DelegationFinder, lookup func(context.Context, common.Address) WalletCheck) Explanation {
+	direct := lookup(ctx, wallet)
+	exp := Explanation{
+		Source: source,
+		Direct: direct,
+		Tier:   direct.Tier,
+	}
+	if delegation == nil {
+		return exp
+	}
+
+	vaults, err := delegation.FindVaults(ctx, wallet)
+	exp.DelegationErr = err
+	for _, vault := range vaults {
+		check := lookup(ctx, vault)
+		exp.Vaults = append(exp.Vaults, check)
+		if check.Err == nil && check.Tier > exp.Tier {
+			exp.Tier = check.Tier
+		}
+	}
+	return exp
+}
+
+// tierLookup adapts a tier-only lookup for explainWithDelegation.
+func tierLookup(lookup func(context.Context, common.Address) (AccessTier, error)) func(context.Context, common.Address) WalletCheck {
+	return func(ctx context.Context, wallet common.Address) WalletCheck {
+		tier, err := lookup(ctx, wallet)
+		return WalletCheck{Wallet: wallet, Tier: tier, Err: err}
+	}
+}
+
+// Explain reports the AccessPolicy result for the wallet and its vaults.
+func (c *Checker) Explain(ctx context.Context, wallet common.Address) []Explanation {
+	return []Explanation{explainWithDelegation(ctx, "access_policy", wallet, c.delegation, tierLookup(c.checkOnChain))}
+}
+
+// Explain reports the Memes balance result for the wallet and its vaults,
+// with the card IDs each one holds.
+func (c *DirectChecker) Explain(ctx context.Context, wallet common.Address) []Explanation {
+	return []Explanation{explainWithDelegation(ctx, "direct", wallet, c.delegation, func(ctx context.Context, wallet common.Address) WalletCheck {
+		tier, tokens, err := c.lookupTokens(ctx, wallet, true)
+		return WalletCheck{Wallet: wallet, Tier: tier, TokenIDs: tokens, Err: err}
+	})}
+}
+
+// Explain reports whether the wallet has an active subscription.
+func (c *SubscriptionChecker) Explain(ctx context.Context, wallet common.Address) []Explanation {
+	result, err := c.Check(ctx, wallet)
+	return []Explanation{{
+		Source: "subscription",
+		Direct: WalletCheck{Wallet: wallet, Tier: result.Tier, Err: err},
+		Tier:   result.Tier,
+	}}
+}
+
+// Explain concatenates the explanations of every wrapped checker. Unlike
+// Check it does not stop at the first TierFree.
+func (c *CompositeChecker) Explain(ctx context.Context, wallet common.Address) []Explanation {
+	var out []Explanation
+	for _, checker := range c.checkers {
+		out = append(out, Explain(ctx, checker, wallet)...)
+	}
+	return out
+}
+
+// Explain reports the primary's decision and, since it is what a failure
+// would fall back to, the fallback's.
+func (c *FallbackChecker) Explain(ctx context.Context, wallet common.Address) []Explanation {
+	return append(Explain(ctx, c.primary, wallet), Explain(ctx, c.fallback, wallet)...)
+}
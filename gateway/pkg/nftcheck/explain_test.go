@@ -0,0 +1,80 @@
+package nftcheck
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/lru"
+)
+
+type stubFinder struct {
+	vaults []common.Address
+	err    error
+}
+
+func (f stubFinder) FindVaults(context.Context, common.Address) ([]common.Address, error) {
+	return f.vaults, f.err
+}
+
+func TestExplainWithDelegationListsEveryVault(t *testing.T) {
+	hot := common.HexToAddress("0x01")
+	paidVault := common.HexToAddress("0x02")
+	brokenVault := common.HexToAddress("0x03")
+	tiers := map[common.Address]AccessTier{paidVault: TierPaid}
+
+	lookup := func(_ context.Context, wallet common.Address) (AccessTier, error) {
+		if wallet == brokenVault {
+			return TierDenied, errors.New("rpc down")
+		}
+		return tiers[wallet], nil
+	}
+
+	exp := explainWithDelegation(context.Background(), "direct", hot, stubFinder{vaults: []common.Address{brokenVault, paidVault}}, tierLookup(lookup))
+	if exp.Direct.Tier != TierDenied || exp.Tier != TierPaid {
+		t.Fatalf("direct=%s best=%s, want denied/paid", exp.Direct.Tier, exp.Tier)
+	}
+	if len(exp.Vaults) != 2 || exp.Vaults[0].Err == nil || exp.Vaults[1].Tier != TierPaid {
+		t.Fatalf("unexpected vaults: %+v", exp.Vaults)
+	}
+}
+
+func TestExplainFallsBackToCheck(t *testing.T) {
+	exps := Explain(context.Background(), NewCompositeChecker(&mockChecker{tier: TierFree}, NewSubscriptionChecker(mockSubscriptions{active: true})), common.Address{})
+	if len(exps) != 2 {
+		t.Fatalf("expected an explanation per checker, got %d", len(exps))
+	}
+	if exps[0].Source != "checker" || exps[0].Tier != TierFree {
+		t.Errorf("first explanation = %+v", exps[0])
+	}
+	if exps[1].Source != "subscription" || exps[1].Tier != TierPaid {
+		t.Errorf("second explanation = %+v", exps[1])
+	}
+}
+
+func TestDirectExplainListsHeldTokens(t *testing.T) {
+	memes := newFakeMemes(t, 7, 9)
+	src, err := NewBalanceSource(BalanceSourceRPC, memes, testMemes, "")
+	if err != nil {
+		t.Fatalf("NewBalanceSource: %v", err)
+	}
+	c := &DirectChecker{
+		maxTokenID: 10,
+		cacheTTL:   time.Hour,
+		balances:   src,
+		cache:      lru.New[common.Address, cacheEntry](0),
+	}
+
+	exps := c.Explain(context.Background(), common.HexToAddress("0x1"))
+	if len(exps) != 1 {
+		t.Fatalf("expected one explanation, got %d", len(exps))
+	}
+	direct := exps[0].Direct
+	if direct.Err != nil || direct.Tier != TierPaid || !reflect.DeepEqual(direct.TokenIDs, []int64{7, 9}) {
+		t.Errorf("direct = %s %v (err %v), want paid [7 9]", direct.Tier, direct.TokenIDs, direct.Err)
+	}
+}
@@ -0,0 +1,39 @@
+package nftcheck
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/sync/singleflight"
+)
+
+// sharedCheckTimeout bounds a lookup shared by concurrent callers, which
+// runs detached from any one caller's cancellation.
+const sharedCheckTimeout = 30 * time.Second
+
+// checkFlight collapses concurrent cache misses for one wallet into a
+// single on-chain lookup, so a burst of sign-ins from a user's devices
+// costs one round of RPC calls. The zero value is ready to use.
+type checkFlight struct {
+	group singleflight.Group
+}
+
+// do runs refresh for wallet, or waits for the run already in flight. A
+// caller that gives up does not cancel the lookup for the others.
+func (f *checkFlight) do(ctx context.Context, wallet common.Address, refresh func(context.Context, common.Address) (CheckResult, error)) (CheckResult, error) {
+	ch := f.group.DoChan(wallet.Hex(), func() (any, error) {
+		shared, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedCheckTimeout)
+		defer cancel()
+		return refresh(shared, wallet)
+	})
+	select {
+	case <-ctx.Done():
+		return CheckResult{}, ctx.Err()
+	case r := <-ch:
+		if r.Err != nil {
+			return CheckResult{}, r.Err
+		}
+		return r.Val.(CheckResult), nil
+	}
+}
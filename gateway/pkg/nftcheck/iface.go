@@ -3,9 +3,19 @@ package nftcheck
 import (
 	"context"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ethrpc"
 )
 
+// ChainReader is the Ethereum client Checker and DirectChecker read
+// contracts and the head block through. *ethclient.Client satisfies it.
+type ChainReader interface {
+	ethrpc.Caller
+	ethereum.BlockNumberReader
+}
+
 // AccessChecker checks whether a wallet has VPN access.
 // Implemented by both Checker (AccessPolicy mode) and DirectChecker (direct ERC-1155 mode).
 type AccessChecker interface {
@@ -13,3 +23,10 @@ type AccessChecker interface {
 	Invalidate(wallet common.Address)
 	Close()
 }
+
+// Refresher is implemented by checkers that cache results. Refresh checks
+// a wallet bypassing the cache and stores the result, without evicting the
+// cached one first, so concurrent Checks keep hitting the cache meanwhile.
+type Refresher interface {
+	Refresh(ctx context.Context, wallet common.Address) (CheckResult, error)
+}
@@ -0,0 +1,179 @@
+package nftcheck
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/metrics"
+)
+
+// ERC-1155 supply extension (OpenZeppelin ERC1155Supply), implemented by the
+// Memes contract.
+const totalSupplyABIJSON = `[{
+	"inputs": [{"name": "id", "type": "uint256"}],
+	"name": "totalSupply",
+	"outputs": [{"name": "", "type": "uint256"}],
+	"stateMutability": "view",
+	"type": "function"
+}]`
+
+// maxDiscoverableTokenID bounds the search, so a contract that reports a
+// supply for every ID cannot keep it probing forever.
+const maxDiscoverableTokenID = 1 << 20
+
+// discoveryTimeout bounds one discovery run.
+const discoveryTimeout = 30 * time.Second
+
+// DiscoverMaxTokenID returns the highest minted token ID of an ERC-1155
+// collection that mints IDs in sequence, found by a galloping then binary
+// search on totalSupply(id) > 0 starting from hint. It returns an error if
+// the contract has no totalSupply(uint256) or token 1 was never minted.
+func DiscoverMaxTokenID(ctx context.Context, caller ethereum.ContractCaller, contract common.Address, hint int64) (int64, error) {
+	parsed, err := abi.JSON(strings.NewReader(totalSupplyABIJSON))
+	if err != nil {
+		return 0, fmt.Errorf("parsing totalSupply ABI: %w", err)
+	}
+
+	minted := func(id int64) (bool, error) {
+		callData, err := parsed.Pack("totalSupply", big.NewInt(id))
+		if err != nil {
+			return false, fmt.Errorf("packing totalSupply: %w", err)
+		}
+		start := time.Now()
+		output, err := caller.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: callData}, nil)
+		metrics.ObserveRPC("totalSupply", start, err)
+		if err != nil {
+			return false, fmt.Errorf("calling totalSupply(%d): %w", id, err)
+		}
+		results, err := parsed.Unpack("totalSupply", output)
+		if err != nil {
+			return false, fmt.Errorf("unpacking totalSupply: %w", err)
+		}
+		supply, ok := results[0].(*big.Int)
+		if !ok {
+			return false, fmt.Errorf("unexpected type for totalSupply: %T", results[0])
+		}
+		return supply.Sign() > 0, nil
+	}
+
+	// lo is always minted, hi never.
+	lo := int64(1)
+	if ok, err := minted(lo); err != nil {
+		return 0, err
+	} else if !ok {
+		return 0, fmt.Errorf("token 1 has no supply; collection does not mint IDs in sequence")
+	}
+	if hint > lo {
+		ok, err := minted(hint)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			lo = hint
+		}
+	}
+
+	step := int64(16)
+	hi := lo + step
+	for {
+		if hi > maxDiscoverableTokenID {
+			return 0, fmt.Errorf("supply reported beyond token %d; refusing to trust it", maxDiscoverableTokenID)
+		}
+		ok, err := minted(hi)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		lo, step = hi, step*2
+		hi = lo + step
+	}
+
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		ok, err := minted(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
+
+// MaxTokenID returns the highest token ID currently checked.
+func (c *DirectChecker) MaxTokenID() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.maxTokenID
+}
+
+// RefreshMaxTokenID reads the collection's highest minted token ID on-chain
+// and raises the checked range to it. The range never shrinks below the
+// configured --max-token-id, and a contract without totalSupply leaves it
+// as configured.
+func (c *DirectChecker) RefreshMaxTokenID(ctx context.Context) (int64, error) {
+	discovered, err := DiscoverMaxTokenID(ctx, c.client, c.memesAddr, c.MaxTokenID())
+	if err != nil {
+		return c.MaxTokenID(), err
+	}
+	return c.raiseMaxTokenID(discovered), nil
+}
+
+// raiseMaxTokenID sets the checked range to id if that is higher, dropping
+// cached denials that a newly covered card might overturn.
+func (c *DirectChecker) raiseMaxTokenID(id int64) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if id <= c.maxTokenID {
+		return c.maxTokenID
+	}
+	log.Printf("[nftcheck-direct] max token ID raised from %d to %d", c.maxTokenID, id)
+	c.maxTokenID = id
+	c.cache.RemoveFunc(func(_ common.Address, entry cacheEntry) bool {
+		return entry.result.Tier == TierDenied
+	})
+	return id
+}
+
+// StartMaxTokenIDDiscovery refreshes the max token ID in the background,
+// once right away and then every interval until Close, so a slow RPC does
+// not hold up startup. Failures are logged and the current value is kept.
+func (c *DirectChecker) StartMaxTokenIDDiscovery(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.stopDiscovery = cancel
+
+	refresh := func() {
+		runCtx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+		defer cancel()
+		if _, err := c.RefreshMaxTokenID(runCtx); err != nil && ctx.Err() == nil {
+			log.Printf("[nftcheck-direct] max token ID discovery failed, keeping %d: %v", c.MaxTokenID(), err)
+		}
+	}
+
+	go func() {
+		refresh()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				refresh()
+			}
+		}
+	}()
+}
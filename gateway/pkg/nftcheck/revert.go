@@ -0,0 +1,67 @@
+package nftcheck
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// RevertError reports that a contract call reached the chain and failed
+// there: the contract reverted, or the address holds no contract and
+// returned nothing. Unlike a transport error, retrying will not help; the
+// contract or the gateway's configuration of it is wrong.
+type RevertError struct {
+	Contract common.Address
+	Method   string
+	Reason   string // decoded revert reason, if the node returned one
+}
+
+func (e *RevertError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("%s on %s reverted", e.Method, e.Contract.Hex())
+	}
+	return fmt.Sprintf("%s on %s reverted: %s", e.Method, e.Contract.Hex(), e.Reason)
+}
+
+// IsRevert reports whether err is, or wraps, a *RevertError.
+func IsRevert(err error) bool {
+	var revert *RevertError
+	return errors.As(err, &revert)
+}
+
+// callError turns a CallContract failure into a *RevertError when the node
+// says the call reverted, and otherwise wraps it as a transport error.
+func callError(contract common.Address, method string, err error) error {
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return fmt.Errorf("calling %s: %w", method, err)
+	}
+	if rpcErr.ErrorCode() != 3 && !strings.Contains(rpcErr.Error(), "execution reverted") {
+		return fmt.Errorf("calling %s: %w", method, err)
+	}
+
+	revert := &RevertError{Contract: contract, Method: method}
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if data, ok := dataErr.ErrorData().(string); ok {
+			if raw, err := hexutil.Decode(data); err == nil {
+				revert.Reason, _ = abi.UnpackRevert(raw)
+			}
+		}
+	}
+	if revert.Reason == "" {
+		revert.Reason = strings.TrimPrefix(strings.TrimPrefix(rpcErr.Error(), "execution reverted"), ": ")
+	}
+	return revert
+}
+
+// emptyOutputError is returned for a call that succeeded with no data, which
+// is what an address without contract code answers.
+func emptyOutputError(contract common.Address, method string) error {
+	return &RevertError{Contract: contract, Method: method, Reason: "empty return data (no contract at this address?)"}
+}
@@ -0,0 +1,65 @@
+package nftcheck
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// StaticChecker grants fixed tiers to listed wallets without reading the
+// chain, for running the gateway in dev mode. With no wallets listed every
+// wallet gets the paid tier; otherwise unlisted wallets are denied.
+type StaticChecker struct {
+	tiers map[common.Address]AccessTier
+}
+
+// NewStaticChecker creates a checker granting each wallet in tiers its tier.
+func NewStaticChecker(tiers map[common.Address]AccessTier) *StaticChecker {
+	return &StaticChecker{tiers: tiers}
+}
+
+// ParseStaticTiers parses a comma-separated list of wallets, each optionally
+// followed by =free or =paid (default paid), e.g. "0xabc...=free,0xdef...".
+func ParseStaticTiers(s string) (map[common.Address]AccessTier, error) {
+	tiers := make(map[common.Address]AccessTier)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		addr, tierName, hasTier := strings.Cut(entry, "=")
+		if !common.IsHexAddress(addr) {
+			return nil, fmt.Errorf("%q is not a wallet address", addr)
+		}
+		tier := TierPaid
+		if hasTier {
+			switch tierName {
+			case "free":
+				tier = TierFree
+			case "paid":
+			default:
+				return nil, fmt.Errorf("tier %q for %s (want free or paid)", tierName, addr)
+			}
+		}
+		tiers[common.HexToAddress(addr)] = tier
+	}
+	return tiers, nil
+}
+
+// Check implements AccessChecker.
+func (c *StaticChecker) Check(ctx context.Context, wallet common.Address) (CheckResult, error) {
+	tier := TierPaid
+	if len(c.tiers) > 0 {
+		tier = c.tiers[wallet] // TierDenied when unlisted
+	}
+	return CheckResult{Tier: tier, CheckedAt: time.Now(), Source: "static"}, nil
+}
+
+// Invalidate implements AccessChecker; there is nothing cached.
+func (c *StaticChecker) Invalidate(wallet common.Address) {}
+
+// Close implements AccessChecker.
+func (c *StaticChecker) Close() {}
@@ -0,0 +1,49 @@
+package nftcheck
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestParseStaticTiers(t *testing.T) {
+	tiers, err := ParseStaticTiers(" 0x1111111111111111111111111111111111111111=free, 0x2222222222222222222222222222222222222222,")
+	if err != nil {
+		t.Fatalf("ParseStaticTiers: %v", err)
+	}
+	if len(tiers) != 2 {
+		t.Fatalf("got %d wallets, want 2", len(tiers))
+	}
+	if tier := tiers[common.HexToAddress("0x1111111111111111111111111111111111111111")]; tier != TierFree {
+		t.Errorf("first wallet tier = %v, want free", tier)
+	}
+	if tier := tiers[common.HexToAddress("0x2222222222222222222222222222222222222222")]; tier != TierPaid {
+		t.Errorf("second wallet tier = %v, want paid by default", tier)
+	}
+
+	for _, bad := range []string{"0xnope", "0x1111111111111111111111111111111111111111=gold"} {
+		if _, err := ParseStaticTiers(bad); err == nil {
+			t.Errorf("ParseStaticTiers(%q): expected error", bad)
+		}
+	}
+}
+
+func TestStaticChecker(t *testing.T) {
+	listed := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	other := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	ctx := context.Background()
+
+	open := NewStaticChecker(nil)
+	if res, _ := open.Check(ctx, other); res.Tier != TierPaid {
+		t.Errorf("no wallets listed: tier = %v, want paid", res.Tier)
+	}
+
+	c := NewStaticChecker(map[common.Address]AccessTier{listed: TierFree})
+	if res, _ := c.Check(ctx, listed); res.Tier != TierFree || res.Source != "static" {
+		t.Errorf("listed wallet: %+v, want free from static", res)
+	}
+	if res, _ := c.Check(ctx, other); res.Tier != TierDenied {
+		t.Errorf("unlisted wallet: tier = %v, want denied", res.Tier)
+	}
+}
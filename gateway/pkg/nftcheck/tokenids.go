@@ -0,0 +1,93 @@
+package nftcheck
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxTokenIDListLen bounds a parsed token ID list, so a typo such as
+// "1-3000000" fails at startup instead of costing RPC on every check.
+const maxTokenIDListLen = 10000
+
+// TokenRange returns the IDs 1..max, the set range mode checks.
+func TokenRange(max int64) []int64 {
+	if max < 1 {
+		return nil
+	}
+	ids := make([]int64, max)
+	for i := range ids {
+		ids[i] = int64(i) + 1
+	}
+	return ids
+}
+
+// ParseTokenIDs parses a comma-separated list of token IDs and inclusive
+// ranges, e.g. "1-50,77,90-120", into sorted, de-duplicated IDs.
+func ParseTokenIDs(s string) ([]int64, error) {
+	var ids []int64
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.ParseInt(strings.TrimSpace(lo), 10, 64)
+		if err != nil || first < 1 {
+			return nil, fmt.Errorf("token ID %q: want a positive integer or a range like 1-50", part)
+		}
+		last := first
+		if isRange {
+			last, err = strconv.ParseInt(strings.TrimSpace(hi), 10, 64)
+			if err != nil || last < first {
+				return nil, fmt.Errorf("token ID range %q: want low-high with low <= high", part)
+			}
+		}
+		if last-first >= maxTokenIDListLen || len(ids)+int(last-first+1) > maxTokenIDListLen {
+			return nil, fmt.Errorf("token ID list is longer than %d IDs", maxTokenIDListLen)
+		}
+		for id := first; id <= last; id++ {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("token ID list %q is empty", s)
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids), nil
+}
+
+// SetTokenIDs makes the checker look for exactly ids instead of scanning
+// 1..maxTokenID, for qualifying sets with gaps such as burned or excluded
+// cards. Max token ID discovery has no effect in this mode. Call it before
+// the checker is in use.
+func (c *DirectChecker) SetTokenIDs(ids []int64) {
+	c.tokenIDs = slices.Clone(ids)
+}
+
+// TokenIDs returns the explicit token IDs set by SetTokenIDs, or nil in
+// range mode.
+func (c *DirectChecker) TokenIDs() []int64 {
+	return slices.Clone(c.tokenIDs)
+}
+
+// scanIDs returns the IDs a lookup passes to the balance source: the
+// explicit list, or 1..maxTokenID, less the THIS card, which is read
+// on-chain separately.
+func (c *DirectChecker) scanIDs() []int64 {
+	ids := c.tokenIDs
+	if ids == nil {
+		ids = TokenRange(c.MaxTokenID())
+	}
+	if c.thisCardID <= 0 || !slices.Contains(ids, c.thisCardID) {
+		return ids
+	}
+	out := make([]int64, 0, len(ids)-1)
+	for _, id := range ids {
+		if id != c.thisCardID {
+			out = append(out, id)
+		}
+	}
+	return out
+}
@@ -0,0 +1,216 @@
+package nftcheck
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/lru"
+)
+
+// WarmerConfig configures a Warmer.
+type WarmerConfig struct {
+	// CacheTTL is the wrapped checker's cache TTL.
+	CacheTTL time.Duration
+	// Window is how long after its last check a wallet is kept warm.
+	// Default 24h, one session credential lifetime.
+	Window time.Duration
+	// Lead is how long before expiry a result is refreshed. Default a tenth
+	// of CacheTTL.
+	Lead time.Duration
+	// Workers bounds concurrent refreshes, and with them the extra RPC load.
+	// Default 4.
+	Workers int
+	// MaxWallets caps the recency set, dropping the least recently checked
+	// wallet. 0 = unlimited.
+	MaxWallets int
+}
+
+// refreshTimeout bounds one wallet's refresh.
+const refreshTimeout = 30 * time.Second
+
+// Warmer wraps an AccessChecker and re-checks wallets seen within Window
+// shortly before their cached result expires, so a returning user signs in
+// on a cache hit instead of paying RPC latency. Each warm wallet costs one
+// check per CacheTTL.
+type Warmer struct {
+	checker AccessChecker
+	cfg     WarmerConfig
+	clock   clock.Clock // nil means the wall clock
+
+	mu      sync.Mutex
+	wallets *lru.Cache[common.Address, warmEntry]
+	stop    context.CancelFunc
+}
+
+type warmEntry struct {
+	lastSeen  time.Time // last Check from a user
+	expiresAt time.Time // when the wrapped checker's cached result expires
+}
+
+// NewWarmer wraps checker. Call Start to begin refreshing.
+func NewWarmer(checker AccessChecker, cfg WarmerConfig) *Warmer {
+	if cfg.Window <= 0 {
+		cfg.Window = 24 * time.Hour
+	}
+	if cfg.Lead <= 0 {
+		cfg.Lead = cfg.CacheTTL / 10
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	return &Warmer{
+		checker: checker,
+		cfg:     cfg,
+		wallets: lru.New[common.Address, warmEntry](cfg.MaxWallets),
+	}
+}
+
+// SetClock replaces the clock used for recency and expiry. Call it before
+// the warmer is in use.
+func (w *Warmer) SetClock(clk clock.Clock) {
+	w.clock = clk
+}
+
+func (w *Warmer) now() time.Time {
+	if w.clock == nil {
+		return time.Now()
+	}
+	return w.clock.Now()
+}
+
+// Check checks the wallet through the wrapped checker and marks it active.
+func (w *Warmer) Check(ctx context.Context, wallet common.Address) (CheckResult, error) {
+	result, err := w.checker.Check(ctx, wallet)
+	if err != nil {
+		return result, err
+	}
+	w.mu.Lock()
+	w.wallets.Add(wallet, warmEntry{
+		lastSeen:  w.now(),
+		expiresAt: result.CheckedAt.Add(w.cfg.CacheTTL),
+	}, nil)
+	w.mu.Unlock()
+	return result, nil
+}
+
+// Invalidate drops the wallet from the wrapped checker's cache and from the
+// recency set, so it is not re-warmed until its owner checks again.
+func (w *Warmer) Invalidate(wallet common.Address) {
+	w.mu.Lock()
+	w.wallets.Remove(wallet)
+	w.mu.Unlock()
+	w.checker.Invalidate(wallet)
+}
+
+// Explain explains the wrapped checker's decision.
+func (w *Warmer) Explain(ctx context.Context, wallet common.Address) []Explanation {
+	return Explain(ctx, w.checker, wallet)
+}
+
+// Close stops refreshing and closes the wrapped checker.
+func (w *Warmer) Close() {
+	if w.stop != nil {
+		w.stop()
+	}
+	w.checker.Close()
+}
+
+// Start refreshes due wallets until Close, looking for them twice per Lead
+// so each is caught before its result expires.
+func (w *Warmer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.stop = cancel
+	go func() {
+		ticker := time.NewTicker(w.cfg.Lead / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				w.warm(ctx)
+			}
+		}
+	}()
+}
+
+// due drops wallets idle for longer than Window and returns those whose
+// cached result expires within Lead.
+func (w *Warmer) due(now time.Time) []common.Address {
+	var wallets []common.Address
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.wallets.RemoveFunc(func(wallet common.Address, e warmEntry) bool {
+		if now.Sub(e.lastSeen) > w.cfg.Window {
+			return true
+		}
+		if !now.Before(e.expiresAt.Add(-w.cfg.Lead)) {
+			wallets = append(wallets, wallet)
+		}
+		return false
+	})
+	return wallets
+}
+
+// warm refreshes every due wallet with at most Workers at a time and
+// returns once all are done.
+func (w *Warmer) warm(ctx context.Context) {
+	due := w.due(w.now())
+	if len(due) == 0 {
+		return
+	}
+
+	jobs := make(chan common.Address)
+	var wg sync.WaitGroup
+	for i := 0; i < w.cfg.Workers && i < len(due); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for wallet := range jobs {
+				w.refresh(ctx, wallet)
+			}
+		}()
+	}
+	for _, wallet := range due {
+		select {
+		case jobs <- wallet:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func (w *Warmer) refresh(ctx context.Context, wallet common.Address) {
+	if ctx.Err() != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+	defer cancel()
+
+	var result CheckResult
+	var err error
+	if r, ok := w.checker.(Refresher); ok {
+		result, err = r.Refresh(ctx, wallet)
+	} else {
+		w.checker.Invalidate(wallet)
+		result, err = w.checker.Check(ctx, wallet)
+	}
+	if err != nil {
+		log.Printf("[nftcheck-warmer] refresh failed, retrying next round: %v", err)
+		return
+	}
+
+	// Keep lastSeen: only a user's own check extends the window.
+	w.mu.Lock()
+	if e, ok := w.wallets.Peek(wallet); ok {
+		e.expiresAt = result.CheckedAt.Add(w.cfg.CacheTTL)
+		w.wallets.Add(wallet, e, nil)
+	}
+	w.mu.Unlock()
+}
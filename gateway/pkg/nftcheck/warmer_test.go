@@ -0,0 +1,120 @@
+package nftcheck
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+)
+
+// countingChecker grants TierPaid, stamps results with clk and counts
+// refreshes, recording how many ran at once.
+type countingChecker struct {
+	clk *clock.Fake
+
+	mu        sync.Mutex
+	refreshes map[common.Address]int
+	running   int
+	maxActive int
+	hold      chan struct{} // if set, Refresh blocks until it is closed
+}
+
+func (c *countingChecker) Check(context.Context, common.Address) (CheckResult, error) {
+	return CheckResult{Tier: TierPaid, CheckedAt: c.clk.Now()}, nil
+}
+
+func (c *countingChecker) Refresh(_ context.Context, wallet common.Address) (CheckResult, error) {
+	c.mu.Lock()
+	c.refreshes[wallet]++
+	c.running++
+	if c.running > c.maxActive {
+		c.maxActive = c.running
+	}
+	c.mu.Unlock()
+	if c.hold != nil {
+		<-c.hold
+	}
+	c.mu.Lock()
+	c.running--
+	c.mu.Unlock()
+	return CheckResult{Tier: TierPaid, CheckedAt: c.clk.Now()}, nil
+}
+
+func (c *countingChecker) Invalidate(common.Address) {}
+func (c *countingChecker) Close()                    {}
+
+func TestWarmerRefreshesBeforeExpiryWithinWindow(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	inner := &countingChecker{clk: clk, refreshes: map[common.Address]int{}}
+	w := NewWarmer(inner, WarmerConfig{CacheTTL: 10 * time.Minute, Lead: time.Minute, Window: time.Hour})
+	w.SetClock(clk)
+
+	active, idle := common.HexToAddress("0xa"), common.HexToAddress("0xb")
+	w.Check(context.Background(), active)
+	w.Check(context.Background(), idle)
+	w.Invalidate(idle)
+
+	clk.Advance(8 * time.Minute)
+	w.warm(context.Background())
+	if inner.refreshes[active] != 0 {
+		t.Fatalf("refreshed %d times 2m before expiry, want 0 with a 1m lead", inner.refreshes[active])
+	}
+
+	clk.Advance(90 * time.Second)
+	w.warm(context.Background())
+	w.warm(context.Background())
+	if inner.refreshes[active] != 1 {
+		t.Fatalf("refreshed %d times 30s before expiry, want exactly 1", inner.refreshes[active])
+	}
+	if inner.refreshes[idle] != 0 {
+		t.Errorf("invalidated wallet refreshed %d times", inner.refreshes[idle])
+	}
+
+	// Refreshes keep the wallet warm but do not extend its window.
+	for i := 0; i < 6; i++ {
+		clk.Advance(10 * time.Minute)
+		w.warm(context.Background())
+	}
+	before := inner.refreshes[active]
+	clk.Advance(10 * time.Minute)
+	w.warm(context.Background())
+	if inner.refreshes[active] != before {
+		t.Errorf("wallet idle past the window was refreshed again")
+	}
+	if w.wallets.Len() != 0 {
+		t.Errorf("recency set holds %d wallets, want idle ones dropped", w.wallets.Len())
+	}
+}
+
+func TestWarmerBoundsConcurrentRefreshes(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	inner := &countingChecker{clk: clk, refreshes: map[common.Address]int{}, hold: make(chan struct{})}
+	w := NewWarmer(inner, WarmerConfig{CacheTTL: time.Minute, Workers: 3})
+	w.SetClock(clk)
+
+	for i := 1; i <= 20; i++ {
+		w.Check(context.Background(), common.BigToAddress(big.NewInt(int64(i))))
+	}
+	clk.Advance(time.Minute)
+
+	done := make(chan struct{})
+	go func() {
+		w.warm(context.Background())
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(inner.hold)
+	<-done
+
+	if len(inner.refreshes) != 20 {
+		t.Errorf("refreshed %d wallets, want 20", len(inner.refreshes))
+	}
+	if inner.maxActive > 3 {
+		t.Errorf("%d refreshes ran at once, want at most 3 workers", inner.maxActive)
+	}
+}
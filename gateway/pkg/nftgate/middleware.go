@@ -25,6 +25,8 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/connlog"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/nftcheck"
 )
 
@@ -38,6 +40,7 @@ type Session struct {
 	ID             string
 	Token          string
 	Tier           nftcheck.AccessTier
+	Probationary   bool // granted on a recent card transfer-in despite a denial
 	CreatedAt      time.Time
 	ExpiresAt      time.Time
 }
@@ -48,6 +51,7 @@ type Gate struct {
 	credTTL    time.Duration
 	sessions   *SessionStore
 	signingKey [32]byte
+	clock      clock.Clock // nil means the wall clock
 }
 
 // NewGate creates a new NFT gate.
@@ -65,6 +69,23 @@ func NewGate(checker nftcheck.AccessChecker, credentialTTL time.Duration) *Gate
 	}
 }
 
+// Close stops the periodic cleanup of the session store.
+func (g *Gate) Close() {
+	g.sessions.Close()
+}
+
+// SetClock replaces the clock used to stamp and check session expiry.
+func (g *Gate) SetClock(c clock.Clock) {
+	g.clock = c
+}
+
+func (g *Gate) now() time.Time {
+	if g.clock == nil {
+		return time.Now()
+	}
+	return g.clock.Now()
+}
+
 // CheckAccess verifies NFT ownership for a wallet address.
 // Returns the access tier or an error.
 func (g *Gate) CheckAccess(ctx context.Context, wallet common.Address) (nftcheck.AccessTier, error) {
@@ -77,8 +98,19 @@ func (g *Gate) CheckAccess(ctx context.Context, wallet common.Address) (nftcheck
 
 // CreateSession creates a new authenticated session for a verified wallet.
 func (g *Gate) CreateSession(wallet common.Address, tier nftcheck.AccessTier) *Session {
-	now := time.Now()
-	expiresAt := now.Add(g.credTTL)
+	return g.createSession(wallet, tier, g.credTTL, false)
+}
+
+// CreateProbationarySession creates a session lasting ttl, at most the
+// credential TTL, for a wallet whose access is not yet visible to the
+// checker. The wallet is re-checked when it signs in again.
+func (g *Gate) CreateProbationarySession(wallet common.Address, tier nftcheck.AccessTier, ttl time.Duration) *Session {
+	return g.createSession(wallet, tier, min(ttl, g.credTTL), true)
+}
+
+func (g *Gate) createSession(wallet common.Address, tier nftcheck.AccessTier, ttl time.Duration, probationary bool) *Session {
+	now := g.now()
+	expiresAt := now.Add(ttl)
 	id, token, err := g.newSessionToken(expiresAt)
 	if err != nil {
 		log.Printf("[nftgate] Failed to issue session token: %v", err)
@@ -91,12 +123,16 @@ func (g *Gate) CreateSession(wallet common.Address, tier nftcheck.AccessTier) *S
 		ID:           id,
 		Token:        token,
 		Tier:         tier,
+		Probationary: probationary,
 		CreatedAt:    now,
 		ExpiresAt:    expiresAt,
 	}
-	g.sessions.Set(session)
-	log.Printf("[nftgate] Session created: tier=%s expires=%s",
-		tier, session.ExpiresAt.Format(time.RFC3339))
+	if !g.sessions.Set(session) {
+		log.Printf("[nftgate] Session store full of sessions in use; refusing a new session")
+		return nil
+	}
+	connlog.Printf("[nftgate] Session created: tier=%s probationary=%v expires=%s",
+		tier, probationary, session.ExpiresAt.Format(time.RFC3339))
 	return session
 }
 
@@ -111,7 +147,7 @@ type AnonymousSessionParams struct {
 
 // CreateAnonymousSession creates a new authenticated session without binding it to a wallet address.
 func (g *Gate) CreateAnonymousSession(params AnonymousSessionParams) *Session {
-	now := time.Now()
+	now := g.now()
 	expiresAt := now.Add(g.credTTL)
 	if !params.ExpiresAt.IsZero() && params.ExpiresAt.Before(expiresAt) {
 		expiresAt = params.ExpiresAt
@@ -133,8 +169,11 @@ func (g *Gate) CreateAnonymousSession(params AnonymousSessionParams) *Session {
 		CreatedAt:      now,
 		ExpiresAt:      expiresAt,
 	}
-	g.sessions.Set(session)
-	log.Printf("[nftgate] Anonymous session created: tier=%s expires=%s",
+	if !g.sessions.Set(session) {
+		log.Printf("[nftgate] Session store full of sessions in use; refusing a new anonymous session")
+		return nil
+	}
+	connlog.Printf("[nftgate] Anonymous session created: tier=%s expires=%s",
 		params.Tier, session.ExpiresAt.Format(time.RFC3339))
 	return session
 }
@@ -145,7 +184,7 @@ func (g *Gate) GetSession(wallet common.Address) *Session {
 	if session == nil {
 		return nil
 	}
-	if time.Now().After(session.ExpiresAt) {
+	if g.now().After(session.ExpiresAt) {
 		g.sessions.DeleteByAddress(wallet)
 		return nil
 	}
@@ -163,7 +202,7 @@ func (g *Gate) GetSessionByToken(token string) *Session {
 	if session == nil {
 		return nil
 	}
-	now := time.Now()
+	now := g.now()
 	if now.After(session.ExpiresAt) || now.After(expiresAt) {
 		g.sessions.DeleteByID(id)
 		return nil
@@ -174,13 +213,13 @@ func (g *Gate) GetSessionByToken(token string) *Session {
 // RevokeSession removes a session (used when NFT transfer is detected).
 func (g *Gate) RevokeSession(wallet common.Address) {
 	g.sessions.DeleteByAddress(wallet)
-	log.Printf("[nftgate] Session revoked")
+	connlog.Printf("[nftgate] Session revoked")
 }
 
 // DeleteSessionByID removes a session by opaque session ID.
 func (g *Gate) DeleteSessionByID(id string) {
 	g.sessions.DeleteByID(id)
-	log.Printf("[nftgate] Session deleted")
+	connlog.Printf("[nftgate] Session deleted")
 }
 
 // InvalidateCache removes cached NFT check results for a wallet.
@@ -188,11 +227,41 @@ func (g *Gate) InvalidateCache(wallet common.Address) {
 	g.checker.Invalidate(wallet)
 }
 
+// OnSessionExpired registers a callback for sessions that reach their expiry
+// time and are removed by the session store's periodic cleanup.
+func (g *Gate) OnSessionExpired(fn func(*Session)) {
+	g.sessions.SetExpireHook(fn)
+}
+
+// OnSessionEvicted registers a callback for sessions evicted before their
+// expiry to stay under SetMaxSessions.
+func (g *Gate) OnSessionEvicted(fn func(*Session)) {
+	g.sessions.SetEvictHook(fn)
+}
+
+// KeepSessionsInUse registers a check for sessions SetMaxSessions must not
+// evict, e.g. those that own live tunnels. When every session is in use, new
+// sessions are refused. fn must not call back into the gate.
+func (g *Gate) KeepSessionsInUse(fn func(*Session) bool) {
+	g.sessions.SetInUseHook(fn)
+}
+
+// SetMaxSessions caps the number of sessions held in memory. When full, the
+// least recently used session not in use is evicted. 0 means unlimited.
+func (g *Gate) SetMaxSessions(max int) {
+	g.sessions.SetMaxSessions(max)
+}
+
 // ActiveSessionCount returns the number of active sessions.
 func (g *Gate) ActiveSessionCount() int {
 	return g.sessions.Len()
 }
 
+// SessionCountsByTier returns the number of active sessions per tier name.
+func (g *Gate) SessionCountsByTier() map[string]int {
+	return g.sessions.CountByTier()
+}
+
 // HTTPMiddleware returns a standard net/http middleware that checks for a valid session.
 // Requests without a valid session token get 401. Requests with a session for a denied
 // tier get 403. Used by the standalone server.
@@ -2,6 +2,7 @@ package nftgate
 
 import (
 	"context"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/nftcheck"
 )
 
@@ -48,14 +50,16 @@ func TestCreateAndGetSession(t *testing.T) {
 }
 
 func TestGetSessionExpired(t *testing.T) {
+	clk := clock.NewFake(time.Now())
 	g := &Gate{
-		credTTL:  1 * time.Millisecond,
+		credTTL:  time.Minute,
 		sessions: NewSessionStore(),
+		clock:    clk,
 	}
 	addr := common.HexToAddress("0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
 
 	g.CreateSession(addr, nftcheck.TierPaid)
-	time.Sleep(5 * time.Millisecond)
+	clk.Advance(time.Minute + time.Second)
 
 	got := g.GetSession(addr)
 	if got != nil {
@@ -63,6 +67,116 @@ func TestGetSessionExpired(t *testing.T) {
 	}
 }
 
+func TestOnSessionExpired(t *testing.T) {
+	g := &Gate{
+		credTTL:  1 * time.Millisecond,
+		sessions: NewSessionStore(),
+	}
+	addr := common.HexToAddress("0xdddddddddddddddddddddddddddddddddddddddd")
+
+	var expired []*Session
+	g.OnSessionExpired(func(s *Session) { expired = append(expired, s) })
+
+	g.CreateSession(addr, nftcheck.TierPaid)
+	g.sessions.removeExpired(time.Now().Add(time.Second))
+
+	if len(expired) != 1 || expired[0].Address != addr {
+		t.Fatalf("expected expire hook for %s, got %v", addr.Hex(), expired)
+	}
+	if g.ActiveSessionCount() != 0 {
+		t.Errorf("expected expired session removed, got %d", g.ActiveSessionCount())
+	}
+}
+
+func TestMaxSessionsEvictsLeastRecentlyUsed(t *testing.T) {
+	g := testGate()
+	g.SetMaxSessions(2)
+
+	a := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	b := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	c := common.HexToAddress("0x3333333333333333333333333333333333333333")
+
+	g.CreateSession(a, nftcheck.TierPaid)
+	g.CreateSession(b, nftcheck.TierPaid)
+	g.GetSession(a) // b is now the least recently used
+	g.CreateSession(c, nftcheck.TierPaid)
+
+	if n := g.ActiveSessionCount(); n != 2 {
+		t.Fatalf("expected 2 sessions under the cap, got %d", n)
+	}
+	if g.GetSession(b) != nil {
+		t.Error("expected least recently used session to be evicted")
+	}
+	if g.GetSession(a) == nil || g.GetSession(c) == nil {
+		t.Error("expected recently used sessions to survive")
+	}
+
+	// A flood of distinct wallets never grows the store past the cap.
+	for i := 0; i < 100; i++ {
+		g.CreateSession(common.BigToAddress(big.NewInt(int64(1000+i))), nftcheck.TierFree)
+	}
+	if n := g.ActiveSessionCount(); n != 2 {
+		t.Fatalf("expected 2 sessions after flood, got %d", n)
+	}
+	if len(g.sessions.addressToID) != 2 {
+		t.Errorf("expected address index trimmed with evictions, got %d entries", len(g.sessions.addressToID))
+	}
+}
+
+func TestMaxSessionsReportsEvictionsToEvictHook(t *testing.T) {
+	g := testGate()
+	var expired, evicted []common.Address
+	g.OnSessionExpired(func(s *Session) { expired = append(expired, s.Address) })
+	g.OnSessionEvicted(func(s *Session) { evicted = append(evicted, s.Address) })
+
+	a := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	b := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	c := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	g.CreateSession(a, nftcheck.TierPaid)
+	g.CreateSession(b, nftcheck.TierPaid)
+	g.CreateSession(c, nftcheck.TierPaid)
+
+	g.SetMaxSessions(2)
+	if len(evicted) != 1 || evicted[0] != a {
+		t.Fatalf("expected shrinking the cap to report %s, got %v", a.Hex(), evicted)
+	}
+	g.CreateSession(common.HexToAddress("0x4444444444444444444444444444444444444444"), nftcheck.TierFree)
+	if len(evicted) != 2 || evicted[1] != b {
+		t.Fatalf("expected a new session to evict and report %s, got %v", b.Hex(), evicted)
+	}
+	if len(expired) != 0 {
+		t.Errorf("evictions reported as expiries: %v", expired)
+	}
+}
+
+func TestMaxSessionsKeepsSessionsInUse(t *testing.T) {
+	g := testGate()
+	g.SetMaxSessions(2)
+
+	a := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	b := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	inUse := map[common.Address]bool{a: true}
+	g.KeepSessionsInUse(func(s *Session) bool { return inUse[s.Address] })
+
+	g.CreateSession(a, nftcheck.TierPaid)
+	g.CreateSession(b, nftcheck.TierPaid)
+	if g.CreateSession(common.HexToAddress("0x3333333333333333333333333333333333333333"), nftcheck.TierFree) == nil {
+		t.Fatal("expected the session not in use to make room")
+	}
+	if g.GetSession(a) == nil || g.GetSession(b) != nil {
+		t.Fatal("expected the least recently used session not in use to be evicted")
+	}
+
+	// With every session in use, a new one is refused rather than evicting.
+	inUse[common.HexToAddress("0x3333333333333333333333333333333333333333")] = true
+	if g.CreateSession(common.HexToAddress("0x4444444444444444444444444444444444444444"), nftcheck.TierFree) != nil {
+		t.Fatal("expected a new session to be refused when every session is in use")
+	}
+	if n := g.ActiveSessionCount(); n != 2 || g.GetSession(a) == nil {
+		t.Errorf("sessions in use changed by a refused session: %d left", n)
+	}
+}
+
 func TestGetSessionNotFound(t *testing.T) {
 	g := testGate()
 	addr := common.HexToAddress("0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
@@ -5,65 +5,132 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/janitor"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/lru"
 )
 
 // SessionStore is a thread-safe in-memory session store.
 // For Phase 0 single-instance deployment. Replace with Redis for multi-instance.
+//
+// The store can be capped with SetMaxSessions; once full, the least recently
+// used session not reported in use by the in-use hook is evicted to make room
+// for a new one, and reported to the evict hook. If every session is in use,
+// the new one is refused.
 type SessionStore struct {
-	mu          sync.RWMutex
-	sessions    map[string]*Session
+	mu          sync.Mutex
+	sessions    *lru.Cache[string, *Session]
 	addressToID map[common.Address]string
+	onExpire    func(*Session)
+	onEvict     func(*Session)
+	inUse       func(*Session) bool
+	stopSweep   func()
 }
 
-// NewSessionStore creates an empty session store with periodic cleanup.
+// NewSessionStore creates an empty, uncapped session store with periodic cleanup.
 func NewSessionStore() *SessionStore {
 	ss := &SessionStore{
-		sessions:    make(map[string]*Session),
+		sessions:    lru.New[string, *Session](0),
 		addressToID: make(map[common.Address]string),
 	}
-	go ss.cleanup()
+	ss.stopSweep = janitor.Register("nftgate sessions", ss.removeExpired)
 	return ss
 }
 
-// Set stores or updates a session.
-func (ss *SessionStore) Set(session *Session) {
+// Close stops the periodic cleanup of expired sessions.
+func (ss *SessionStore) Close() {
+	ss.stopSweep()
+}
+
+// SetMaxSessions caps the number of stored sessions. 0 means unlimited.
+// Sessions over the new cap are evicted immediately.
+func (ss *SessionStore) SetMaxSessions(max int) {
+	var evicted []*Session
+	ss.mu.Lock()
+	ss.sessions.SetMax(max, ss.evictInto(&evicted))
+	onEvict := ss.onEvict
+	ss.mu.Unlock()
+	notify(onEvict, evicted)
+}
+
+// Set stores or updates a session. It returns false, storing nothing, if
+// the store is at its cap and every session in it is in use.
+func (ss *SessionStore) Set(session *Session) bool {
+	var evicted []*Session
 	ss.mu.Lock()
 	if session.AddressBound {
 		if oldID, ok := ss.addressToID[session.Address]; ok && oldID != session.ID {
-			delete(ss.sessions, oldID)
+			ss.sessions.Remove(oldID)
+			delete(ss.addressToID, session.Address)
 		}
+	}
+	stored := ss.sessions.AddKeeping(session.ID, session, ss.keep, ss.evictInto(&evicted))
+	if stored && session.AddressBound {
 		ss.addressToID[session.Address] = session.ID
 	}
-	ss.sessions[session.ID] = session
+	onEvict := ss.onEvict
 	ss.mu.Unlock()
+	notify(onEvict, evicted)
+	return stored
+}
+
+// keep reports whether the cap must not evict session. Called with ss.mu
+// held.
+func (ss *SessionStore) keep(_ string, session *Session) bool {
+	return ss.inUse != nil && ss.inUse(session)
+}
+
+// evictInto returns the eviction callback for the cache: it drops the
+// address index for a session pushed out by the cap and appends the session
+// to evicted, for the evict hook. Called with ss.mu held.
+func (ss *SessionStore) evictInto(evicted *[]*Session) func(string, *Session) {
+	return func(id string, session *Session) {
+		if session.AddressBound && ss.addressToID[session.Address] == id {
+			delete(ss.addressToID, session.Address)
+		}
+		*evicted = append(*evicted, session)
+	}
+}
+
+// notify passes removed sessions to an expire or evict hook, if set. Called
+// without ss.mu held.
+func notify(hook func(*Session), sessions []*Session) {
+	if hook == nil {
+		return
+	}
+	for _, session := range sessions {
+		hook(session)
+	}
 }
 
 // GetByID retrieves a session by session ID.
 func (ss *SessionStore) GetByID(id string) *Session {
-	ss.mu.RLock()
-	defer ss.mu.RUnlock()
-	return ss.sessions[id]
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	session, _ := ss.sessions.Get(id)
+	return session
 }
 
 // GetByAddress retrieves a session by wallet address. Returns nil if not found.
 func (ss *SessionStore) GetByAddress(addr common.Address) *Session {
-	ss.mu.RLock()
-	defer ss.mu.RUnlock()
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
 	id, ok := ss.addressToID[addr]
 	if !ok {
 		return nil
 	}
-	return ss.sessions[id]
+	session, _ := ss.sessions.Get(id)
+	return session
 }
 
 // DeleteByID removes a session by ID.
 func (ss *SessionStore) DeleteByID(id string) {
 	ss.mu.Lock()
-	session, ok := ss.sessions[id]
+	session, ok := ss.sessions.Peek(id)
 	if ok && session.AddressBound {
 		delete(ss.addressToID, session.Address)
 	}
-	delete(ss.sessions, id)
+	ss.sessions.Remove(id)
 	ss.mu.Unlock()
 }
 
@@ -71,35 +138,74 @@ func (ss *SessionStore) DeleteByID(id string) {
 func (ss *SessionStore) DeleteByAddress(addr common.Address) {
 	ss.mu.Lock()
 	if id, ok := ss.addressToID[addr]; ok {
-		delete(ss.sessions, id)
+		ss.sessions.Remove(id)
 		delete(ss.addressToID, addr)
 	}
 	ss.mu.Unlock()
 }
 
+// SetExpireHook registers a callback invoked for each session removed by the
+// periodic sweep because it expired. The callback runs outside the lock.
+func (ss *SessionStore) SetExpireHook(fn func(*Session)) {
+	ss.mu.Lock()
+	ss.onExpire = fn
+	ss.mu.Unlock()
+}
+
+// SetEvictHook registers a callback invoked for each session evicted,
+// unexpired, to stay under the cap. The callback runs outside the lock.
+func (ss *SessionStore) SetEvictHook(fn func(*Session)) {
+	ss.mu.Lock()
+	ss.onEvict = fn
+	ss.mu.Unlock()
+}
+
+// SetInUseHook registers a check for sessions the cap must not evict. It
+// is called with the store's lock held, so it must not call back into the
+// store.
+func (ss *SessionStore) SetInUseHook(fn func(*Session) bool) {
+	ss.mu.Lock()
+	ss.inUse = fn
+	ss.mu.Unlock()
+}
+
 // Len returns the number of sessions.
 func (ss *SessionStore) Len() int {
-	ss.mu.RLock()
-	defer ss.mu.RUnlock()
-	return len(ss.sessions)
-}
-
-// cleanup periodically removes expired sessions.
-func (ss *SessionStore) cleanup() {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		ss.mu.Lock()
-		now := time.Now()
-		for id, session := range ss.sessions {
-			if now.After(session.ExpiresAt) {
-				if session.AddressBound {
-					delete(ss.addressToID, session.Address)
-				}
-				delete(ss.sessions, id)
-			}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.sessions.Len()
+}
+
+// CountByTier returns the number of sessions per access tier name.
+func (ss *SessionStore) CountByTier() map[string]int {
+	counts := make(map[string]int)
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.sessions.Range(func(_ string, session *Session) bool {
+		counts[session.Tier.String()]++
+		return true
+	})
+	return counts
+}
+
+// removeExpired deletes sessions past their expiry and notifies the expire
+// hook. Run by the janitor.
+func (ss *SessionStore) removeExpired(now time.Time) {
+	var expired []*Session
+
+	ss.mu.Lock()
+	ss.sessions.RemoveFunc(func(_ string, session *Session) bool {
+		if !now.After(session.ExpiresAt) {
+			return false
 		}
-		ss.mu.Unlock()
-	}
+		if session.AddressBound {
+			delete(ss.addressToID, session.Address)
+		}
+		expired = append(expired, session)
+		return true
+	})
+	onExpire := ss.onExpire
+	ss.mu.Unlock()
+
+	notify(onExpire, expired)
 }
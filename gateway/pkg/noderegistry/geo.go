@@ -0,0 +1,216 @@
+package noderegistry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/lru"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
+)
+
+const (
+	// geoLookupTimeout bounds resolving one node's host and geolocating it.
+	geoLookupTimeout = 5 * time.Second
+	// maxGeoBody caps how much of a geolocation API response is read.
+	maxGeoBody = 4 << 10
+	// maxGeoEntries caps the endpoints remembered by a RegionVerifier.
+	maxGeoEntries = 4096
+
+	// GeoIPPlaceholder is replaced with the address to look up in a
+	// geolocation API URL.
+	GeoIPPlaceholder = "{ip}"
+)
+
+// euCountries are the EU member states, for "eu-" regions.
+var euCountries = []string{
+	"AT", "BE", "BG", "CY", "CZ", "DE", "DK", "EE", "ES", "FI", "FR", "GR", "HR", "HU",
+	"IE", "IT", "LT", "LU", "LV", "MT", "NL", "PL", "PT", "RO", "SE", "SI", "SK",
+}
+
+// regionCountries maps the first label of a region name ("eu" in
+// "eu-west") to the ISO 3166-1 alpha-2 countries a node in that region may
+// geolocate to. Prefixes that are not listed verify nothing; in particular
+// two-letter prefixes are not read as country codes, since "sa" is South
+// America to most cloud providers but Saudi Arabia to ISO.
+var regionCountries = map[string][]string{
+	"us": {"US"},
+	"ca": {"CA"},
+	"sa": {"AR", "BO", "BR", "CL", "CO", "EC", "GY", "PE", "PY", "SR", "UY", "VE"},
+	"eu": euCountries,
+	"uk": {"GB"},
+	"gb": {"GB"},
+	"ch": {"CH"},
+	"no": {"NO"},
+	"is": {"IS"},
+	"de": {"DE"},
+	"fr": {"FR"},
+	"nl": {"NL"},
+	"se": {"SE"},
+	"fi": {"FI"},
+	"jp": {"JP"},
+	"kr": {"KR"},
+	"sg": {"SG"},
+	"in": {"IN"},
+	"au": {"AU"},
+	"nz": {"NZ"},
+	"za": {"ZA"},
+}
+
+// RegionCountries returns the countries region may geolocate to, looked up
+// in regionCountries by the region's first label ("ch" for "ch-zurich"),
+// or nil if the region is not in the table.
+func RegionCountries(region string) []string {
+	prefix, _, _ := strings.Cut(region, "-")
+	return regionCountries[strings.ToLower(prefix)]
+}
+
+// RegionMatchesCountry reports whether country is one region may
+// geolocate to. Regions not in the table never match.
+func RegionMatchesCountry(region, country string) bool {
+	return slices.Contains(RegionCountries(region), strings.ToUpper(country))
+}
+
+// RegionVerifier geolocates each node's endpoint with an IP geolocation
+// API, so discovery can cross-check the region an operator declares
+// against where the node actually is. Like VersionProber, lookups never
+// block on the network: they return the last known country and refresh
+// stale entries in the background.
+type RegionVerifier struct {
+	client  *http.Client
+	apiURL  string // contains GeoIPPlaceholder
+	ttl     time.Duration
+	resolve func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+	mu       sync.Mutex
+	entries  *lru.Cache[string, geoEntry]
+	inflight map[string]bool
+}
+
+type geoEntry struct {
+	country   string // empty when the lookup failed
+	fetchedAt time.Time
+}
+
+// NewRegionVerifier creates a verifier that looks nodes up at apiURL, an
+// http(s) URL with GeoIPPlaceholder where the address goes, and re-checks
+// each after ttl. The API must answer with a JSON object carrying the
+// two-letter country code as "country_code", "countryCode" or "country",
+// as ipapi.co, ip-api.com and ipinfo.io do.
+func NewRegionVerifier(apiURL string, ttl time.Duration) (*RegionVerifier, error) {
+	if !strings.Contains(apiURL, GeoIPPlaceholder) {
+		return nil, fmt.Errorf("geolocation URL must contain %s", GeoIPPlaceholder)
+	}
+	u, err := url.Parse(strings.ReplaceAll(apiURL, GeoIPPlaceholder, "192.0.2.1"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("geolocation URL must be an http(s) URL")
+	}
+	return &RegionVerifier{
+		client:   &http.Client{Timeout: geoLookupTimeout, Transport: outbound.Transport()},
+		apiURL:   apiURL,
+		ttl:      ttl,
+		resolve:  net.DefaultResolver.LookupIPAddr,
+		entries:  lru.New[string, geoEntry](maxGeoEntries),
+		inflight: make(map[string]bool),
+	}, nil
+}
+
+// Country returns the upper-cased country code the node at endpoint last
+// geolocated to, or "" if it is not known yet or the lookup failed, and
+// starts a refresh when the entry is missing or older than the TTL.
+func (v *RegionVerifier) Country(endpoint string) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	e, ok := v.entries.Get(endpoint)
+	if (!ok || time.Since(e.fetchedAt) > v.ttl) && !v.inflight[endpoint] {
+		v.inflight[endpoint] = true
+		go v.refresh(endpoint)
+	}
+	return e.country
+}
+
+func (v *RegionVerifier) refresh(endpoint string) {
+	country, err := v.fetch(endpoint)
+	if err != nil {
+		log.Printf("[noderegistry] Geolocation of %s failed: %v", endpoint, err)
+	}
+	v.mu.Lock()
+	v.entries.Add(endpoint, geoEntry{country: country, fetchedAt: time.Now()}, nil)
+	delete(v.inflight, endpoint)
+	v.mu.Unlock()
+}
+
+func (v *RegionVerifier) fetch(endpoint string) (string, error) {
+	host, err := endpointHost(endpoint)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), geoLookupTimeout)
+	defer cancel()
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		addrs, err := v.resolve(ctx, host)
+		if err != nil {
+			return "", fmt.Errorf("resolving: %w", err)
+		}
+		if len(addrs) == 0 {
+			return "", fmt.Errorf("resolving: no addresses for %s", host)
+		}
+		ip = addrs[0].IP
+	}
+	if !isPublicIP(ip) {
+		return "", fmt.Errorf("non-public address %s", ip)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.ReplaceAll(v.apiURL, GeoIPPlaceholder, url.PathEscape(ip.String())), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		// The URL may carry an API key; keep it out of the logs.
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return "", fmt.Errorf("geolocation API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("geolocation API: status %d", resp.StatusCode)
+	}
+	var info struct {
+		CountryCode      string `json:"country_code"`
+		CountryCodeCamel string `json:"countryCode"`
+		Country          string `json:"country"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxGeoBody)).Decode(&info); err != nil {
+		return "", fmt.Errorf("decoding: %w", err)
+	}
+	for _, code := range []string{info.CountryCode, info.CountryCodeCamel, info.Country} {
+		if len(code) == 2 && isASCIILetters(code) {
+			return strings.ToUpper(code), nil
+		}
+	}
+	return "", fmt.Errorf("no country code for %s", ip)
+}
+
+func isASCIILetters(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
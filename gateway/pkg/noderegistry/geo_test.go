@@ -0,0 +1,111 @@
+package noderegistry
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitCountry polls v until the lookup of endpoint has finished.
+func waitCountry(t *testing.T, v *RegionVerifier, endpoint string) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		v.mu.Lock()
+		e, done := v.entries.Peek(endpoint)
+		v.mu.Unlock()
+		if done {
+			return e.country
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("geolocation did not finish")
+	return ""
+}
+
+func TestRegionVerifierGeolocatesEndpoint(t *testing.T) {
+	var hits atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path != "/203.0.113.7/json" {
+			t.Errorf("looked up %s, want /203.0.113.7/json", r.URL.Path)
+		}
+		w.Write([]byte(`{"ip":"203.0.113.7","country_code":"ch","city":"Zurich"}`))
+	}))
+	defer api.Close()
+
+	v, err := NewRegionVerifier(api.URL+"/{ip}/json", time.Hour)
+	if err != nil {
+		t.Fatalf("NewRegionVerifier: %v", err)
+	}
+	v.resolve = func(_ context.Context, host string) ([]net.IPAddr, error) {
+		if host != "node.example" {
+			t.Errorf("resolved %q, want node.example", host)
+		}
+		return []net.IPAddr{{IP: net.ParseIP("203.0.113.7")}}, nil
+	}
+
+	if c := v.Country("node.example:51820"); c != "" {
+		t.Errorf("first lookup = %q, want empty until geolocated", c)
+	}
+	if c := waitCountry(t, v, "node.example:51820"); c != "CH" {
+		t.Fatalf("country = %q, want CH", c)
+	}
+	if c := v.Country("node.example:51820"); c != "CH" {
+		t.Errorf("cached lookup = %q, want CH", c)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("API called %d times within the TTL, want 1", n)
+	}
+}
+
+func TestRegionVerifierSkipsPrivateAddresses(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("geolocated a private address")
+		w.Write([]byte(`{"country":"US"}`))
+	}))
+	defer api.Close()
+
+	v, err := NewRegionVerifier(api.URL+"/{ip}", time.Hour)
+	if err != nil {
+		t.Fatalf("NewRegionVerifier: %v", err)
+	}
+	v.Country("10.0.0.1:51820")
+	if c := waitCountry(t, v, "10.0.0.1:51820"); c != "" {
+		t.Errorf("country = %q, want empty for a private address", c)
+	}
+}
+
+func TestNewRegionVerifierValidatesURL(t *testing.T) {
+	for _, raw := range []string{"https://geo.example/json", "ftp://geo.example/{ip}", "{ip}"} {
+		if _, err := NewRegionVerifier(raw, time.Hour); err == nil {
+			t.Errorf("NewRegionVerifier(%q): expected error", raw)
+		}
+	}
+}
+
+func TestRegionMatchesCountry(t *testing.T) {
+	for _, tc := range []struct {
+		region, country string
+		want            bool
+	}{
+		{"ch-zurich", "CH", true},
+		{"US-east", "us", true},
+		{"us-east", "DE", false},
+		{"eu-west", "DE", true},
+		{"eu-west", "CH", false},
+		{"uk-london", "GB", true},
+		{"sa-east", "BR", true},
+		{"sa-east", "SA", false},
+		{"europe", "DE", false},
+		{"ch-zurich", "", false},
+	} {
+		if got := RegionMatchesCountry(tc.region, tc.country); got != tc.want {
+			t.Errorf("RegionMatchesCountry(%q, %q) = %v, want %v", tc.region, tc.country, got, tc.want)
+		}
+	}
+}
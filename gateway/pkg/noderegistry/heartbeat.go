@@ -7,26 +7,47 @@ import (
 	"log"
 	"math/big"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ethrpc"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/gaslimit"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/txtracker"
 )
 
+// TxSender is the Ethereum client HeartbeatSender sends transactions
+// through. *ethclient.Client satisfies it.
+type TxSender interface {
+	ethrpc.Transactor
+	Close()
+}
+
 // HeartbeatSender sends periodic heartbeat transactions to the NodeRegistry contract.
 type HeartbeatSender struct {
-	client       *ethclient.Client
+	client       TxSender
 	contractAddr common.Address
 	abi          abi.ABI
 	key          *ecdsa.PrivateKey
 	chainID      *big.Int
 	interval     time.Duration
+	gas          gaslimit.Policy
+	txs          *txtracker.Tracker // optional; records submitted txs
 	stopCh       chan struct{}
+	mu           sync.Mutex // held for a whole send; protects key
+	paused       atomic.Bool
 }
 
+// heartbeatFallbackGasLimit is used when eth_estimateGas fails.
+const heartbeatFallbackGasLimit = 100000
+
 const heartbeatABI = `[{
 	"inputs": [],
 	"name": "heartbeat",
@@ -37,11 +58,21 @@ const heartbeatABI = `[{
 
 // NewHeartbeatSender creates a heartbeat sender.
 func NewHeartbeatSender(rpcURL, contractAddress, privateKeyHex string, chainID int64, interval time.Duration) (*HeartbeatSender, error) {
-	client, err := ethclient.Dial(rpcURL)
+	client, err := outbound.Dial(rpcURL)
 	if err != nil {
 		return nil, fmt.Errorf("connecting to Ethereum RPC: %w", err)
 	}
+	h, err := NewHeartbeatSenderWithClient(client, contractAddress, privateKeyHex, chainID, interval)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return h, nil
+}
 
+// NewHeartbeatSenderWithClient creates a heartbeat sender on an existing
+// client, e.g. an ethrpc.Fake in tests. Stop closes the client.
+func NewHeartbeatSenderWithClient(client TxSender, contractAddress, privateKeyHex string, chainID int64, interval time.Duration) (*HeartbeatSender, error) {
 	key, err := crypto.HexToECDSA(privateKeyHex)
 	if err != nil {
 		return nil, fmt.Errorf("parsing private key: %w", err)
@@ -59,10 +90,54 @@ func NewHeartbeatSender(rpcURL, contractAddress, privateKeyHex string, chainID i
 		key:          key,
 		chainID:      big.NewInt(chainID),
 		interval:     interval,
+		gas:          gaslimit.DefaultPolicy(),
 		stopCh:       make(chan struct{}),
 	}, nil
 }
 
+// SetGasPolicy sets how heartbeat transactions size their gas limit.
+func (h *HeartbeatSender) SetGasPolicy(p gaslimit.Policy) {
+	h.gas = p
+}
+
+// SetTxTracker records submitted heartbeat transactions in t for status polling.
+func (h *HeartbeatSender) SetTxTracker(t *txtracker.Tracker) {
+	h.txs = t
+}
+
+// SetKey replaces the heartbeat signing key, e.g. on key rotation. It waits
+// for an in-flight heartbeat to finish first.
+func (h *HeartbeatSender) SetKey(key *ecdsa.PrivateKey) {
+	h.mu.Lock()
+	h.key = key
+	h.mu.Unlock()
+}
+
+// SetPaused stops or resumes sending heartbeats without stopping the loop.
+// A paused node goes heartbeat-overdue in the registry, so discovery stops
+// advertising it (used while draining).
+func (h *HeartbeatSender) SetPaused(paused bool) {
+	if h.paused.Swap(paused) != paused {
+		if paused {
+			log.Println("[heartbeat] Paused")
+		} else {
+			log.Println("[heartbeat] Resumed")
+		}
+	}
+}
+
+// Paused reports whether heartbeats are paused.
+func (h *HeartbeatSender) Paused() bool {
+	return h.paused.Load()
+}
+
+// Address returns the address heartbeats are sent from.
+func (h *HeartbeatSender) Address() common.Address {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return crypto.PubkeyToAddress(h.key.PublicKey)
+}
+
 // Start begins the heartbeat loop. Blocks until Stop is called.
 func (h *HeartbeatSender) Start(ctx context.Context) {
 	ticker := time.NewTicker(h.interval)
@@ -94,6 +169,13 @@ func (h *HeartbeatSender) Stop() {
 }
 
 func (h *HeartbeatSender) sendHeartbeat(ctx context.Context) {
+	if h.paused.Load() {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	callData, err := h.abi.Pack("heartbeat")
 	if err != nil {
 		log.Printf("[heartbeat] Error packing call: %v", err)
@@ -114,11 +196,17 @@ func (h *HeartbeatSender) sendHeartbeat(ctx context.Context) {
 		return
 	}
 
+	gasLimit := h.gas.Limit(ctx, h.client, ethereum.CallMsg{
+		From: from,
+		To:   &h.contractAddr,
+		Data: callData,
+	}, heartbeatFallbackGasLimit)
+
 	tx := types.NewTransaction(
 		nonce,
 		h.contractAddr,
 		big.NewInt(0),
-		100000, // gas limit
+		gasLimit,
 		gasPrice,
 		callData,
 	)
@@ -135,5 +223,8 @@ func (h *HeartbeatSender) sendHeartbeat(ctx context.Context) {
 		return
 	}
 
+	if h.txs != nil {
+		h.txs.Track(signedTx.Hash(), "heartbeat", "heartbeat")
+	}
 	log.Printf("[heartbeat] Sent heartbeat tx: %s", signedTx.Hash().Hex())
 }
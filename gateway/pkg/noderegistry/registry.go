@@ -14,7 +14,9 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ethrpc"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
 )
 
 // Node represents a registered VPN node from the on-chain registry.
@@ -33,7 +35,7 @@ type Node struct {
 
 // Registry reads the NodeRegistry smart contract.
 type Registry struct {
-	client       *ethclient.Client
+	client       ethrpc.Caller
 	contractAddr common.Address
 	abi          abi.ABI
 	cacheTTL     time.Duration
@@ -140,11 +142,21 @@ const nodeRegistryABIJSON = `[
 
 // NewRegistry creates a registry reader connected to an Ethereum RPC.
 func NewRegistry(rpcURL string, contractAddress string, cacheTTL time.Duration) (*Registry, error) {
-	client, err := ethclient.Dial(rpcURL)
+	client, err := outbound.Dial(rpcURL)
 	if err != nil {
 		return nil, fmt.Errorf("connecting to Ethereum RPC: %w", err)
 	}
+	r, err := NewRegistryWithClient(client, contractAddress, cacheTTL)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return r, nil
+}
 
+// NewRegistryWithClient creates a registry reader on an existing client,
+// e.g. an ethrpc.Fake in tests. Close closes the client.
+func NewRegistryWithClient(client ethrpc.Caller, contractAddress string, cacheTTL time.Duration) (*Registry, error) {
 	parsed, err := abi.JSON(strings.NewReader(nodeRegistryABIJSON))
 	if err != nil {
 		return nil, fmt.Errorf("parsing NodeRegistry ABI: %w", err)
@@ -214,16 +226,14 @@ func (r *Registry) GetNode(ctx context.Context, operator common.Address) (*Node,
 		return nil, fmt.Errorf("calling getNode: %w", err)
 	}
 
-	results, err := r.abi.Unpack("getNode", output)
-	if err != nil {
+	// UnpackIntoInterface copies a lone tuple output into the first field
+	// of the destination struct.
+	var out struct{ Node nodeTuple }
+	if err := r.abi.UnpackIntoInterface(&out, "getNode", output); err != nil {
 		return nil, fmt.Errorf("unpacking getNode: %w", err)
 	}
-
-	if len(results) != 1 {
-		return nil, fmt.Errorf("expected 1 result, got %d", len(results))
-	}
-
-	return decodeNodeStruct(results[0])
+	node := out.Node.node()
+	return &node, nil
 }
 
 // NodeCount returns the total number of registered nodes.
@@ -246,6 +256,10 @@ func (r *Registry) NodeCount(ctx context.Context) (uint64, error) {
 		return 0, fmt.Errorf("unpacking nodeCount: %w", err)
 	}
 
+	if len(results) != 1 {
+		return 0, fmt.Errorf("expected 1 result, got %d", len(results))
+	}
+
 	count, ok := results[0].(*big.Int)
 	if !ok {
 		return 0, fmt.Errorf("unexpected type for nodeCount: %T", results[0])
@@ -273,6 +287,10 @@ func (r *Registry) IsHeartbeatOverdue(ctx context.Context, operator common.Addre
 		return false, fmt.Errorf("unpacking isHeartbeatOverdue: %w", err)
 	}
 
+	if len(results) != 1 {
+		return false, fmt.Errorf("expected 1 result, got %d", len(results))
+	}
+
 	overdue, ok := results[0].(bool)
 	if !ok {
 		return false, fmt.Errorf("unexpected type: %T", results[0])
@@ -300,6 +318,10 @@ func (r *Registry) IsEligibleOperator(ctx context.Context, operator common.Addre
 		return false, fmt.Errorf("unpacking isEligibleOperator: %w", err)
 	}
 
+	if len(results) != 1 {
+		return false, fmt.Errorf("expected 1 result, got %d", len(results))
+	}
+
 	eligible, ok := results[0].(bool)
 	if !ok {
 		return false, fmt.Errorf("unexpected type: %T", results[0])
@@ -372,74 +394,43 @@ func (r *Registry) fetchActiveNodes(ctx context.Context) ([]Node, error) {
 
 // decodeNodeArray unpacks a tuple[] of Node structs.
 func (r *Registry) decodeNodeArray(method string, output []byte) ([]Node, error) {
-	results, err := r.abi.Unpack(method, output)
-	if err != nil {
+	var raw []nodeTuple
+	if err := r.abi.UnpackIntoInterface(&raw, method, output); err != nil {
 		return nil, fmt.Errorf("unpacking %s: %w", method, err)
 	}
 
-	if len(results) != 1 {
-		return nil, fmt.Errorf("expected 1 result, got %d", len(results))
-	}
-
-	// The result is a slice of structs
-	rawNodes, ok := results[0].([]struct {
-		Operator      common.Address `json:"operator"`
-		Endpoint      string         `json:"endpoint"`
-		WgPubKey      string         `json:"wgPubKey"`
-		Region        string         `json:"region"`
-		StakedAmount  *big.Int       `json:"stakedAmount"`
-		RegisteredAt  *big.Int       `json:"registeredAt"`
-		LastHeartbeat *big.Int       `json:"lastHeartbeat"`
-		Active        bool           `json:"active"`
-		Slashed       bool           `json:"slashed"`
-	})
-	if !ok {
-		return nil, fmt.Errorf("unexpected type for node array: %T", results[0])
-	}
-
-	nodes := make([]Node, len(rawNodes))
-	for i, raw := range rawNodes {
-		nodes[i] = Node{
-			Operator:      raw.Operator,
-			Endpoint:      raw.Endpoint,
-			WgPubKey:      raw.WgPubKey,
-			Region:        raw.Region,
-			StakedAmount:  raw.StakedAmount,
-			RegisteredAt:  time.Unix(raw.RegisteredAt.Int64(), 0),
-			LastHeartbeat: time.Unix(raw.LastHeartbeat.Int64(), 0),
-			Active:        raw.Active,
-			Slashed:       raw.Slashed,
-		}
+	nodes := make([]Node, len(raw))
+	for i := range raw {
+		nodes[i] = raw[i].node()
 	}
 	return nodes, nil
 }
 
-// decodeNodeStruct unpacks a single Node struct from ABI output.
-func decodeNodeStruct(raw interface{}) (*Node, error) {
-	s, ok := raw.(struct {
-		Operator      common.Address `json:"operator"`
-		Endpoint      string         `json:"endpoint"`
-		WgPubKey      string         `json:"wgPubKey"`
-		Region        string         `json:"region"`
-		StakedAmount  *big.Int       `json:"stakedAmount"`
-		RegisteredAt  *big.Int       `json:"registeredAt"`
-		LastHeartbeat *big.Int       `json:"lastHeartbeat"`
-		Active        bool           `json:"active"`
-		Slashed       bool           `json:"slashed"`
-	})
-	if !ok {
-		return nil, fmt.Errorf("unexpected node struct type: %T", raw)
-	}
-
-	return &Node{
-		Operator:      s.Operator,
-		Endpoint:      s.Endpoint,
-		WgPubKey:      s.WgPubKey,
-		Region:        s.Region,
-		StakedAmount:  s.StakedAmount,
-		RegisteredAt:  time.Unix(s.RegisteredAt.Int64(), 0),
-		LastHeartbeat: time.Unix(s.LastHeartbeat.Int64(), 0),
-		Active:        s.Active,
-		Slashed:       s.Slashed,
-	}, nil
+// nodeTuple is the NodeRegistry's Node struct as returned by getNode and
+// the getActiveNodes reads. Its fields must stay in ABI order: the decoder
+// copies the tuple into it field by field.
+type nodeTuple struct {
+	Operator      common.Address
+	Endpoint      string
+	WgPubKey      string
+	Region        string
+	StakedAmount  *big.Int
+	RegisteredAt  *big.Int
+	LastHeartbeat *big.Int
+	Active        bool
+	Slashed       bool
+}
+
+func (t *nodeTuple) node() Node {
+	return Node{
+		Operator:      t.Operator,
+		Endpoint:      t.Endpoint,
+		WgPubKey:      t.WgPubKey,
+		Region:        t.Region,
+		StakedAmount:  t.StakedAmount,
+		RegisteredAt:  time.Unix(t.RegisteredAt.Int64(), 0),
+		LastHeartbeat: time.Unix(t.LastHeartbeat.Int64(), 0),
+		Active:        t.Active,
+		Slashed:       t.Slashed,
+	}
 }
@@ -0,0 +1,222 @@
+package noderegistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ethrpc"
+)
+
+// callRPC answers every eth_call with result.
+func callRPC(t *testing.T, result string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newOutputRegistry returns a Registry whose every eth_call returns output.
+func newOutputRegistry(t *testing.T, output []byte) *Registry {
+	t.Helper()
+	contract := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	fake := ethrpc.NewFake()
+	fake.HandleCalls(contract, func(ethereum.CallMsg) ([]byte, error) { return output, nil })
+	r, err := NewRegistryWithClient(fake, contract.Hex(), time.Minute)
+	if err != nil {
+		t.Fatalf("NewRegistryWithClient: %v", err)
+	}
+	return r
+}
+
+func TestReadsRejectMalformedOutput(t *testing.T) {
+	for name, output := range ethrpc.MalformedOutputs {
+		t.Run(name, func(t *testing.T) {
+			r := newOutputRegistry(t, output)
+			ctx := context.Background()
+			op := common.Address{}
+
+			reads := map[string]func() error{
+				"GetActiveNodes":         func() error { _, err := r.GetActiveNodes(ctx); return err },
+				"GetActiveNodesByRegion": func() error { _, err := r.GetActiveNodesByRegion(ctx, "us-east"); return err },
+				"GetNode":                func() error { _, err := r.GetNode(ctx, op); return err },
+				"NodeCount":              func() error { _, err := r.NodeCount(ctx); return err },
+				"IsHeartbeatOverdue":     func() error { _, err := r.IsHeartbeatOverdue(ctx, op); return err },
+				"IsEligibleOperator":     func() error { _, err := r.IsEligibleOperator(ctx, op); return err },
+				"GetRailgunAddress":      func() error { _, err := r.GetRailgunAddress(ctx, op); return err },
+			}
+			for read, call := range reads {
+				if err := call(); err == nil {
+					t.Errorf("%s returned no error", read)
+				}
+			}
+		})
+	}
+}
+
+func TestReadsRejectOutOfRangeOffset(t *testing.T) {
+	// Dynamic return types read 0xff..ff as an out-of-range offset.
+	r := newOutputRegistry(t, bytes.Repeat([]byte{0xff}, 32))
+	ctx := context.Background()
+	op := common.Address{}
+
+	_, errNodes := r.GetActiveNodes(ctx)
+	_, errRegion := r.GetActiveNodesByRegion(ctx, "us-east")
+	_, errNode := r.GetNode(ctx, op)
+	_, errRailgun := r.GetRailgunAddress(ctx, op)
+	for _, err := range []error{errNodes, errRegion, errNode, errRailgun} {
+		if err == nil {
+			t.Error("expected error for out-of-range offset")
+		}
+	}
+}
+
+func TestHeartbeatSetKey(t *testing.T) {
+	oldKey, _ := crypto.GenerateKey()
+	newKey, _ := crypto.GenerateKey()
+
+	hb, err := NewHeartbeatSender(callRPC(t, "0x").URL, "0x0000000000000000000000000000000000000001",
+		hex.EncodeToString(crypto.FromECDSA(oldKey)), 1, time.Hour)
+	if err != nil {
+		t.Fatalf("NewHeartbeatSender: %v", err)
+	}
+	if hb.Address() != crypto.PubkeyToAddress(oldKey.PublicKey) {
+		t.Fatal("expected sender address from initial key")
+	}
+
+	hb.SetKey(newKey)
+	if hb.Address() != crypto.PubkeyToAddress(newKey.PublicKey) {
+		t.Fatal("expected sender address from rotated key")
+	}
+}
+
+func TestHeartbeatPausedSendsNothing(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	fake := ethrpc.NewFake()
+	hb, err := NewHeartbeatSenderWithClient(fake, "0x0000000000000000000000000000000000000001",
+		hex.EncodeToString(crypto.FromECDSA(key)), 1, time.Hour)
+	if err != nil {
+		t.Fatalf("NewHeartbeatSenderWithClient: %v", err)
+	}
+
+	hb.SetPaused(true)
+	hb.sendHeartbeat(context.Background())
+	if n := len(fake.Sent()); n != 0 {
+		t.Fatalf("paused sender sent %d transactions", n)
+	}
+
+	hb.SetPaused(false)
+	hb.sendHeartbeat(context.Background())
+	if n := len(fake.Sent()); n != 1 {
+		t.Fatalf("resumed sender sent %d transactions, want 1", n)
+	}
+}
+
+func TestNodeTuplesDecode(t *testing.T) {
+	contract := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	want := nodeTuple{
+		Operator:      common.HexToAddress("0xa11ce"),
+		Endpoint:      "203.0.113.10:51820",
+		WgPubKey:      "server-key",
+		Region:        "us-east",
+		StakedAmount:  big.NewInt(1e18),
+		RegisteredAt:  big.NewInt(1_700_000_000),
+		LastHeartbeat: big.NewInt(1_700_003_600),
+		Active:        true,
+	}
+	client := ethrpc.NewFake()
+	r, err := NewRegistryWithClient(client, contract.Hex(), time.Minute)
+	if err != nil {
+		t.Fatalf("NewRegistryWithClient: %v", err)
+	}
+	defer r.Close()
+	client.HandleCalls(contract, func(call ethereum.CallMsg) ([]byte, error) {
+		method, err := r.abi.MethodById(call.Data)
+		if err != nil {
+			return nil, err
+		}
+		if method.Name == "getNode" {
+			return method.Outputs.Pack(want)
+		}
+		return method.Outputs.Pack([]nodeTuple{want, want})
+	})
+
+	check := func(name string, got Node) {
+		t.Helper()
+		if got.Operator != want.Operator || got.Endpoint != want.Endpoint || got.WgPubKey != want.WgPubKey ||
+			got.Region != want.Region || got.StakedAmount.Cmp(want.StakedAmount) != 0 ||
+			got.RegisteredAt.Unix() != 1_700_000_000 || got.LastHeartbeat.Unix() != 1_700_003_600 ||
+			!got.Active || got.Slashed {
+			t.Errorf("%s = %+v, want %+v", name, got, want)
+		}
+	}
+
+	ctx := context.Background()
+	node, err := r.GetNode(ctx, want.Operator)
+	if err != nil {
+		t.Fatalf("GetNode: %v", err)
+	}
+	check("GetNode", *node)
+
+	nodes, err := r.GetActiveNodes(ctx)
+	if err != nil {
+		t.Fatalf("GetActiveNodes: %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("GetActiveNodes returned %d nodes, want 2", len(nodes))
+	}
+	check("GetActiveNodes", nodes[1])
+}
+
+func TestStubCallsServeRegistry(t *testing.T) {
+	node := Node{
+		Operator:     common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		Endpoint:     "127.0.0.1:51820",
+		WgPubKey:     "pubkey=",
+		Region:       "dev",
+		RegisteredAt: time.Unix(1700000000, 0),
+		Active:       true,
+	}
+	contract := common.HexToAddress("0x00000000000000000000000000000000000de001")
+	fake := ethrpc.NewFake()
+	fake.HandleCalls(contract, StubCalls([]Node{node}))
+	r, err := NewRegistryWithClient(fake, contract.Hex(), time.Minute)
+	if err != nil {
+		t.Fatalf("NewRegistryWithClient: %v", err)
+	}
+	ctx := context.Background()
+
+	nodes, err := r.GetActiveNodes(ctx)
+	if err != nil {
+		t.Fatalf("GetActiveNodes: %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].Endpoint != node.Endpoint || !nodes[0].RegisteredAt.Equal(node.RegisteredAt) {
+		t.Errorf("GetActiveNodes = %+v, want the stubbed node", nodes)
+	}
+	if inRegion, err := r.GetActiveNodesByRegion(ctx, "elsewhere"); err != nil || len(inRegion) != 0 {
+		t.Errorf("GetActiveNodesByRegion(elsewhere) = %v, %v; want none", inRegion, err)
+	}
+	if ok, err := r.IsEligibleOperator(ctx, node.Operator); err != nil || !ok {
+		t.Errorf("IsEligibleOperator(listed) = %v, %v; want true", ok, err)
+	}
+	if ok, err := r.IsEligibleOperator(ctx, common.Address{1}); err != nil || ok {
+		t.Errorf("IsEligibleOperator(unlisted) = %v, %v; want false", ok, err)
+	}
+}
@@ -0,0 +1,218 @@
+package noderegistry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// healthProbeTimeout bounds one node's GET /health.
+	healthProbeTimeout = 5 * time.Second
+	// maxHealthBody caps how much of a /health response is read.
+	maxHealthBody = 16 << 10
+	// statsWorkers bounds concurrent /health probes.
+	statsWorkers = 8
+	// maxReportedCount caps each count a node reports, so one misbehaving
+	// node cannot swamp the totals.
+	maxReportedCount = 1_000_000
+)
+
+// NetworkStats is the network-wide view built from the registry and each
+// active node's GET /health. Session and peer counts are self-reported by
+// operators and not verified.
+type NetworkStats struct {
+	ActiveNodes    int                    `json:"active_nodes"`
+	ReachableNodes int                    `json:"reachable_nodes"`
+	ActiveSessions int                    `json:"active_sessions"`
+	ActivePeers    int                    `json:"active_peers"`
+	Regions        map[string]RegionStats `json:"regions"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+}
+
+// RegionStats is one region's share of NetworkStats.
+type RegionStats struct {
+	Nodes          int `json:"nodes"`
+	ReachableNodes int `json:"reachable_nodes"`
+	ActiveSessions int `json:"active_sessions"`
+}
+
+// nodeHealth is the part of a node's GET /health the stats use.
+type nodeHealth struct {
+	ActiveSessions int `json:"active_sessions"`
+	ActivePeers    int `json:"active_peers"`
+}
+
+// activeNodeLister is the registry read the collector needs.
+type activeNodeLister interface {
+	GetActiveNodes(ctx context.Context) ([]Node, error)
+}
+
+// StatsCollector polls the active nodes' health on an interval and keeps
+// the latest aggregate, so serving it never waits on the network.
+type StatsCollector struct {
+	nodes    activeNodeLister
+	client   *http.Client
+	urlFor   func(endpoint string) (string, error)
+	interval time.Duration
+
+	mu    sync.RWMutex
+	stats *NetworkStats
+	stop  context.CancelFunc
+}
+
+// NewStatsCollector creates a collector re-polling the registry's active
+// nodes every interval. Call Start to begin. Like VersionProber it refuses
+// non-public node addresses unless an outbound proxy is set.
+func NewStatsCollector(registry *Registry, interval time.Duration) *StatsCollector {
+	return newStatsCollector(registry, interval)
+}
+
+func newStatsCollector(nodes activeNodeLister, interval time.Duration) *StatsCollector {
+	return &StatsCollector{
+		nodes:    nodes,
+		client:   newProbeClient(healthProbeTimeout),
+		urlFor:   GatewayURL,
+		interval: interval,
+	}
+}
+
+// Stats returns the latest aggregate, or nil before the first collection
+// has finished.
+func (c *StatsCollector) Stats() *NetworkStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.stats
+}
+
+// Start collects now and then every interval until Stop.
+func (c *StatsCollector) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.stop = cancel
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			c.collect(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends collection.
+func (c *StatsCollector) Stop() {
+	if c.stop != nil {
+		c.stop()
+	}
+}
+
+// collect probes every active node and replaces the aggregate. If the
+// registry read fails the previous aggregate is kept.
+func (c *StatsCollector) collect(ctx context.Context) {
+	nodes, err := c.nodes.GetActiveNodes(ctx)
+	if err != nil {
+		log.Printf("[noderegistry] Network stats: listing active nodes failed: %v", err)
+		return
+	}
+
+	healths := make([]*nodeHealth, len(nodes))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < statsWorkers && i < len(nodes); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				h, err := c.fetchHealth(ctx, nodes[j].Endpoint)
+				if err != nil {
+					log.Printf("[noderegistry] Health probe of %s failed: %v", nodes[j].Endpoint, err)
+					continue
+				}
+				healths[j] = h
+			}
+		}()
+	}
+	for i := range nodes {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	stats := aggregateStats(nodes, healths)
+	stats.UpdatedAt = time.Now().UTC()
+	c.mu.Lock()
+	c.stats = stats
+	c.mu.Unlock()
+}
+
+// aggregateStats sums nodes' health; healths[i] is nil for a node that did
+// not answer.
+func aggregateStats(nodes []Node, healths []*nodeHealth) *NetworkStats {
+	stats := &NetworkStats{
+		ActiveNodes: len(nodes),
+		Regions:     make(map[string]RegionStats),
+	}
+	for i, n := range nodes {
+		region := stats.Regions[n.Region]
+		region.Nodes++
+		if h := healths[i]; h != nil {
+			region.ReachableNodes++
+			region.ActiveSessions += h.ActiveSessions
+			stats.ReachableNodes++
+			stats.ActiveSessions += h.ActiveSessions
+			stats.ActivePeers += h.ActivePeers
+		}
+		stats.Regions[n.Region] = region
+	}
+	return stats
+}
+
+func (c *StatsCollector) fetchHealth(ctx context.Context, endpoint string) (*nodeHealth, error) {
+	base, err := c.urlFor(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/health", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// A degraded node answers 503 but still reports its sessions.
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var h nodeHealth
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxHealthBody)).Decode(&h); err != nil {
+		return nil, fmt.Errorf("decoding: %w", err)
+	}
+	h.sanitize()
+	return &h, nil
+}
+
+// sanitize bounds the operator-controlled figures.
+func (h *nodeHealth) sanitize() {
+	h.ActiveSessions = clampCount(h.ActiveSessions)
+	h.ActivePeers = clampCount(h.ActivePeers)
+}
+
+func clampCount(n int) int {
+	return max(0, min(n, maxReportedCount))
+}
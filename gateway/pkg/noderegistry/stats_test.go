@@ -0,0 +1,71 @@
+package noderegistry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type staticNodes []Node
+
+func (n staticNodes) GetActiveNodes(context.Context) ([]Node, error) { return n, nil }
+
+func TestStatsCollectorAggregatesNodeHealth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// One server plays every node: the path is /<host>/health.
+		host, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if path != "health" {
+			t.Errorf("probed %s, want /health", r.URL.Path)
+		}
+		switch host {
+		case "a.example":
+			w.Write([]byte(`{"status":"ok","active_sessions":3,"active_peers":2}`))
+		case "b.example":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"down","active_sessions":-5,"active_peers":99999999}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	nodes := staticNodes{
+		{Endpoint: "a.example:51820", Region: "us-east"},
+		{Endpoint: "b.example:51820", Region: "us-east"},
+		{Endpoint: "c.example:51820", Region: "eu-west"},
+	}
+	c := newStatsCollector(nodes, time.Minute)
+	c.client = srv.Client()
+	c.urlFor = func(endpoint string) (string, error) {
+		host, _, _ := strings.Cut(endpoint, ":")
+		return srv.URL + "/" + host, nil
+	}
+
+	if c.Stats() != nil {
+		t.Fatal("Stats() before the first collection should be nil")
+	}
+	c.collect(context.Background())
+	got := c.Stats()
+	if got == nil {
+		t.Fatal("Stats() = nil after collecting")
+	}
+
+	if got.ActiveNodes != 3 || got.ReachableNodes != 2 {
+		t.Errorf("nodes = %d active, %d reachable; want 3, 2", got.ActiveNodes, got.ReachableNodes)
+	}
+	if got.ActiveSessions != 3 || got.ActivePeers != 2+maxReportedCount {
+		t.Errorf("sessions = %d, peers = %d; want 3 and a clamped %d", got.ActiveSessions, got.ActivePeers, 2+maxReportedCount)
+	}
+	want := map[string]RegionStats{
+		"us-east": {Nodes: 2, ReachableNodes: 2, ActiveSessions: 3},
+		"eu-west": {Nodes: 1},
+	}
+	for region, w := range want {
+		if got.Regions[region] != w {
+			t.Errorf("region %s = %+v, want %+v", region, got.Regions[region], w)
+		}
+	}
+}
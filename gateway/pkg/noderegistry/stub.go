@@ -0,0 +1,117 @@
+package noderegistry
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ethrpc"
+)
+
+// StubCalls answers NodeRegistry reads from a fixed node list, so a
+// Registry on an ethrpc.Fake works without a deployed contract (dev mode,
+// tests). Every listed operator is eligible, none is overdue and none has
+// a RAILGUN address.
+func StubCalls(nodes []Node) ethrpc.CallHandler {
+	parsed, err := abi.JSON(strings.NewReader(nodeRegistryABIJSON))
+	if err != nil {
+		return func(ethereum.CallMsg) ([]byte, error) { return nil, fmt.Errorf("parsing NodeRegistry ABI: %w", err) }
+	}
+	tuples := make([]nodeTuple, len(nodes))
+	for i, n := range nodes {
+		tuples[i] = tupleFrom(n)
+	}
+	find := func(operator common.Address) (nodeTuple, bool) {
+		for _, t := range tuples {
+			if t.Operator == operator {
+				return t, true
+			}
+		}
+		return nodeTuple{}, false
+	}
+
+	return func(call ethereum.CallMsg) ([]byte, error) {
+		if len(call.Data) < 4 {
+			return nil, fmt.Errorf("stub NodeRegistry: short call data")
+		}
+		method, err := parsed.MethodById(call.Data[:4])
+		if err != nil {
+			return nil, fmt.Errorf("stub NodeRegistry: %w", err)
+		}
+		args, err := method.Inputs.Unpack(call.Data[4:])
+		if err != nil {
+			return nil, fmt.Errorf("stub NodeRegistry: unpacking %s: %w", method.Name, err)
+		}
+
+		switch method.Name {
+		case "getActiveNodes":
+			return method.Outputs.Pack(tuples)
+		case "getActiveNodesByRegion":
+			region, ok := args[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("stub NodeRegistry: unexpected %s argument %T", method.Name, args[0])
+			}
+			var inRegion []nodeTuple
+			for _, t := range tuples {
+				if t.Region == region {
+					inRegion = append(inRegion, t)
+				}
+			}
+			return method.Outputs.Pack(inRegion)
+		case "getNode":
+			operator, ok := args[0].(common.Address)
+			if !ok {
+				return nil, fmt.Errorf("stub NodeRegistry: unexpected %s argument %T", method.Name, args[0])
+			}
+			t, ok := find(operator)
+			if !ok {
+				t = tupleFrom(Node{}) // the contract returns a zero Node
+			}
+			return method.Outputs.Pack(t)
+		case "nodeCount":
+			return method.Outputs.Pack(big.NewInt(int64(len(tuples))))
+		case "isEligibleOperator":
+			operator, ok := args[0].(common.Address)
+			if !ok {
+				return nil, fmt.Errorf("stub NodeRegistry: unexpected %s argument %T", method.Name, args[0])
+			}
+			_, found := find(operator)
+			return method.Outputs.Pack(found)
+		case "isHeartbeatOverdue":
+			return method.Outputs.Pack(false)
+		case "getRailgunAddress":
+			return method.Outputs.Pack("")
+		}
+		return nil, fmt.Errorf("stub NodeRegistry: %s not stubbed", method.Name)
+	}
+}
+
+// tupleFrom is the inverse of nodeTuple.node.
+func tupleFrom(n Node) nodeTuple {
+	staked := n.StakedAmount
+	if staked == nil {
+		staked = new(big.Int)
+	}
+	unix := func(t time.Time) *big.Int {
+		if t.IsZero() {
+			return new(big.Int)
+		}
+		return big.NewInt(t.Unix())
+	}
+	return nodeTuple{
+		Operator:      n.Operator,
+		Endpoint:      n.Endpoint,
+		WgPubKey:      n.WgPubKey,
+		Region:        n.Region,
+		StakedAmount:  staked,
+		RegisteredAt:  unix(n.RegisteredAt),
+		LastHeartbeat: unix(n.LastHeartbeat),
+		Active:        n.Active,
+		Slashed:       n.Slashed,
+	}
+}
@@ -0,0 +1,224 @@
+package noderegistry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/feedback"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/lru"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/wireguard"
+)
+
+const (
+	// versionProbeTimeout bounds one node's GET /version.
+	versionProbeTimeout = 5 * time.Second
+	// maxVersionBody caps how much of a /version response is read.
+	maxVersionBody = 4 << 10
+	// maxVersionEntries caps the endpoints remembered by a VersionProber.
+	maxVersionEntries = 4096
+)
+
+// GatewayURL returns the HTTPS base URL of the gateway API behind a node's
+// registered WireGuard endpoint: the same host, default port.
+func GatewayURL(endpoint string) (string, error) {
+	host, err := endpointHost(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u := &url.URL{Scheme: "https", Host: host}
+	return u.String(), nil
+}
+
+// endpointHost returns the host of a registered WireGuard endpoint, with
+// any port and IPv6 brackets removed.
+func endpointHost(endpoint string) (string, error) {
+	if endpoint == "" {
+		return "", fmt.Errorf("empty endpoint")
+	}
+	host := endpoint
+	if h, _, err := net.SplitHostPort(endpoint); err == nil {
+		host = h
+	} else if h, _, err := net.SplitHostPort(endpoint + ":51820"); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "" {
+		return "", fmt.Errorf("missing host")
+	}
+	return host, nil
+}
+
+// VersionProber learns which gateway build each node runs by fetching its
+// GET /version, so discovery can show upgrade adoption and clients can
+// steer clear of known-bad releases. The same response carries the node's
+// advertised DNS policy, so clients can filter on it before connecting, its
+// connection quality score from client feedback, and whether it provisions
+// tunnels at all.
+// Lookups never block on the network:
+// they return the last known version and refresh stale entries in the
+// background.
+type VersionProber struct {
+	client *http.Client
+	ttl    time.Duration
+	urlFor func(endpoint string) (string, error)
+
+	mu       sync.Mutex
+	versions *lru.Cache[string, versionEntry]
+	inflight map[string]bool
+}
+
+type versionEntry struct {
+	version       string // empty when the node did not answer
+	dnsPolicy     *wireguard.DNSPolicy
+	quality       *feedback.Quality
+	discoveryOnly bool // the node provisions no tunnels
+	fetchedAt     time.Time
+}
+
+// NewVersionProber creates a prober that re-fetches a node's version after
+// ttl. Without an outbound proxy it refuses to connect to loopback, private
+// and link-local addresses, since endpoints are chosen by operators.
+func NewVersionProber(ttl time.Duration) *VersionProber {
+	return &VersionProber{
+		client:   newProbeClient(versionProbeTimeout),
+		ttl:      ttl,
+		urlFor:   GatewayURL,
+		versions: lru.New[string, versionEntry](maxVersionEntries),
+		inflight: make(map[string]bool),
+	}
+}
+
+// Version returns the gateway version last reported by the node at
+// endpoint, or "" if it is not known yet, and starts a refresh when the
+// entry is missing or older than the TTL.
+func (p *VersionProber) Version(endpoint string) string {
+	return p.lookup(endpoint).version
+}
+
+// DNSPolicy returns the DNS policy last advertised by the node at endpoint,
+// or nil if it advertised none or is not known yet. Like Version it starts
+// a refresh of a missing or stale entry.
+func (p *VersionProber) DNSPolicy(endpoint string) *wireguard.DNSPolicy {
+	return p.lookup(endpoint).dnsPolicy
+}
+
+// Quality returns the connection quality the node at endpoint last
+// reported, or nil if it has no score or is not known yet. Like Version it
+// starts a refresh of a missing or stale entry.
+func (p *VersionProber) Quality(endpoint string) *feedback.Quality {
+	return p.lookup(endpoint).quality
+}
+
+// DiscoveryOnly reports whether the node at endpoint last said it
+// provisions no tunnels (the none WireGuard backend), so connects to it
+// would fail. Like Version it starts a refresh of a missing or stale entry.
+func (p *VersionProber) DiscoveryOnly(endpoint string) bool {
+	return p.lookup(endpoint).discoveryOnly
+}
+
+func (p *VersionProber) lookup(endpoint string) versionEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.versions.Get(endpoint)
+	if (!ok || time.Since(e.fetchedAt) > p.ttl) && !p.inflight[endpoint] {
+		p.inflight[endpoint] = true
+		go p.refresh(endpoint)
+	}
+	return e
+}
+
+func (p *VersionProber) refresh(endpoint string) {
+	e, err := p.fetch(endpoint)
+	if err != nil {
+		log.Printf("[noderegistry] Version probe of %s failed: %v", endpoint, err)
+	}
+	e.fetchedAt = time.Now()
+	p.mu.Lock()
+	p.versions.Add(endpoint, e, nil)
+	delete(p.inflight, endpoint)
+	p.mu.Unlock()
+}
+
+func (p *VersionProber) fetch(endpoint string) (versionEntry, error) {
+	base, err := p.urlFor(endpoint)
+	if err != nil {
+		return versionEntry{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), versionProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/version", nil)
+	if err != nil {
+		return versionEntry{}, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return versionEntry{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return versionEntry{}, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var info struct {
+		Version       string               `json:"version"`
+		DNSPolicy     *wireguard.DNSPolicy `json:"dns_policy"`
+		Quality       *feedback.Quality    `json:"quality"`
+		DiscoveryOnly bool                 `json:"discovery_only"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxVersionBody)).Decode(&info); err != nil {
+		return versionEntry{}, fmt.Errorf("decoding: %w", err)
+	}
+	// Operator-controlled; keep it to a short printable token.
+	if len(info.Version) > 64 || strings.ContainsFunc(info.Version, func(r rune) bool { return r < 0x21 || r > 0x7e }) {
+		return versionEntry{}, fmt.Errorf("malformed version")
+	}
+	if q := info.Quality; q != nil && (q.Score < 0 || q.Score > 100 || q.Reports < feedback.MinReports) {
+		info.Quality = nil
+	}
+	return versionEntry{version: info.Version, dnsPolicy: info.DNSPolicy, quality: info.Quality, discoveryOnly: info.DiscoveryOnly}, nil
+}
+
+// newProbeClient returns the HTTP client for requests to operator-chosen
+// node endpoints: through the outbound proxy if one is set, else refusing
+// addresses that are not public.
+func newProbeClient(timeout time.Duration) *http.Client {
+	transport := outbound.Transport()
+	if transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.Proxy = nil
+		t.DialContext = (&net.Dialer{Timeout: timeout, Control: publicAddressesOnly}).DialContext
+		transport = t
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+// publicAddressesOnly is a net.Dialer Control hook rejecting addresses that
+// are not globally routable.
+func publicAddressesOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("refusing non-public address %s", host)
+	}
+	return nil
+}
+
+// isPublicIP reports whether ip is globally routable.
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsUnspecified() && !ip.IsMulticast()
+}
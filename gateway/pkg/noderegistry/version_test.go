@@ -0,0 +1,110 @@
+package noderegistry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitVersion polls p until the probe of endpoint has finished.
+func waitVersion(t *testing.T, p *VersionProber, endpoint string) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		p.mu.Lock()
+		e, done := p.versions.Peek(endpoint)
+		p.mu.Unlock()
+		if done {
+			return e.version
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("version probe did not finish")
+	return ""
+}
+
+func TestVersionProberCachesNodeVersion(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path != "/version" {
+			t.Errorf("probed %s, want /version", r.URL.Path)
+		}
+		w.Write([]byte(`{"version":"v1.2.0","commit":"abc123","dns_policy":{"local_resolver":true,"no_query_logs":true,"doh":false},"quality":{"reports":5,"latency_ms":42,"failure_rate":0,"score":100}}`))
+	}))
+	defer srv.Close()
+
+	p := NewVersionProber(time.Hour)
+	p.client = srv.Client()
+	p.urlFor = func(string) (string, error) { return srv.URL, nil }
+
+	if v := p.Version("node.example:51820"); v != "" {
+		t.Errorf("first lookup = %q, want empty until the probe returns", v)
+	}
+	if v := waitVersion(t, p, "node.example:51820"); v != "v1.2.0" {
+		t.Fatalf("probed version = %q, want v1.2.0", v)
+	}
+	if v := p.Version("node.example:51820"); v != "v1.2.0" {
+		t.Errorf("cached lookup = %q, want v1.2.0", v)
+	}
+	if dp := p.DNSPolicy("node.example:51820"); dp == nil || !dp.NoQueryLogs || !dp.LocalResolver || dp.DoH {
+		t.Errorf("DNS policy = %+v, want local resolver and no query logs", dp)
+	}
+	if q := p.Quality("node.example:51820"); q == nil || q.Score != 100 || q.Reports != 5 {
+		t.Errorf("quality = %+v, want score 100 from 5 reports", q)
+	}
+	if p.DiscoveryOnly("node.example:51820") {
+		t.Error("DiscoveryOnly = true for a node that did not say so")
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("node probed %d times within the TTL, want 1", n)
+	}
+}
+
+func TestVersionProberNotesDiscoveryOnlyNodes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version":"v1.2.0","discovery_only":true}`))
+	}))
+	defer srv.Close()
+
+	p := NewVersionProber(time.Hour)
+	p.client = srv.Client()
+	p.urlFor = func(string) (string, error) { return srv.URL, nil }
+	p.Version("hub.example:51820")
+	waitVersion(t, p, "hub.example:51820")
+	if !p.DiscoveryOnly("hub.example:51820") {
+		t.Error("DiscoveryOnly = false for a node reporting discovery_only")
+	}
+}
+
+func TestVersionProberRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("prober reached a loopback address")
+		w.Write([]byte(`{"version":"v1.2.0"}`))
+	}))
+	defer srv.Close()
+
+	p := NewVersionProber(time.Hour)
+	p.urlFor = func(string) (string, error) { return srv.URL, nil }
+	p.Version("10.0.0.1:51820")
+	if v := waitVersion(t, p, "10.0.0.1:51820"); v != "" {
+		t.Errorf("version = %q, want empty for a refused probe", v)
+	}
+}
+
+func TestGatewayURL(t *testing.T) {
+	for endpoint, want := range map[string]string{
+		"vpn.example.org:51820": "https://vpn.example.org",
+		"vpn.example.org":       "https://vpn.example.org",
+		"[2001:db8::1]:51820":   "https://[2001:db8::1]",
+	} {
+		if got, err := GatewayURL(endpoint); err != nil || got != want {
+			t.Errorf("GatewayURL(%q) = %q, %v; want %q", endpoint, got, err, want)
+		}
+	}
+	if _, err := GatewayURL(""); err == nil {
+		t.Error("GatewayURL(\"\"): expected error")
+	}
+}
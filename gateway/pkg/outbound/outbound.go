@@ -0,0 +1,160 @@
+// Package outbound routes the gateway's own outbound calls -- Ethereum RPC,
+// the 6529 rep and ZK APIs, NFT indexers and webhooks -- through an optional
+// proxy, for hosts that can only reach the internet through one.
+//
+// SetProxy is called once at startup, before any client is built; clients
+// take Transport or Dial when they are constructed. Without a proxy the
+// standard HTTP_PROXY/HTTPS_PROXY environment variables still apply.
+//
+// SetRPCConcurrency likewise caps how many JSON-RPC requests all clients
+// from Dial have in flight together, so a burst of checks queues instead of
+// tripping the provider's rate or connection limits.
+package outbound
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/metrics"
+)
+
+// proxyURL is written only by SetProxy during startup.
+var proxyURL *url.URL
+
+// rpcSlots bounds concurrent HTTP JSON-RPC requests; nil is unlimited.
+// Written only by SetRPCConcurrency during startup.
+var rpcSlots *semaphore.Weighted
+
+// ParseProxy validates a proxy URL. http:// and socks5:// are accepted,
+// the schemes both net/http and the RPC WebSocket dialer support; either
+// may carry user:password credentials.
+func ParseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("outbound proxy: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "socks5" {
+		return nil, fmt.Errorf("outbound proxy %s: scheme must be http or socks5", u.Redacted())
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("outbound proxy %s: missing host", u.Redacted())
+	}
+	return u, nil
+}
+
+// SetProxy routes clients built afterwards through raw; empty removes the
+// proxy.
+func SetProxy(raw string) error {
+	if raw == "" {
+		proxyURL = nil
+		return nil
+	}
+	u, err := ParseProxy(raw)
+	if err != nil {
+		return err
+	}
+	proxyURL = u
+	return nil
+}
+
+// Transport returns the RoundTripper outbound http.Clients should use: a
+// copy of http.DefaultTransport sending through the proxy, or nil, meaning
+// the default transport, when none is set.
+func Transport() http.RoundTripper {
+	if proxyURL == nil {
+		return nil
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyURL(proxyURL)
+	return t
+}
+
+// SetRPCConcurrency limits RPC clients dialed afterwards to n requests in
+// flight between them; n <= 0 removes the limit. Only http(s) endpoints are
+// limited: a WebSocket endpoint carries every call over one connection.
+func SetRPCConcurrency(n int) {
+	if n <= 0 {
+		rpcSlots = nil
+		return
+	}
+	rpcSlots = semaphore.NewWeighted(int64(n))
+}
+
+// DialRPC connects to an http(s) or ws(s) JSON-RPC endpoint through the
+// proxy, under the RPC concurrency limit.
+func DialRPC(ctx context.Context, rawURL string) (*rpc.Client, error) {
+	if proxyURL == nil && rpcSlots == nil {
+		return rpc.DialContext(ctx, rawURL)
+	}
+	var transport http.RoundTripper = http.DefaultTransport
+	if t := Transport(); t != nil {
+		transport = t
+	}
+	if rpcSlots != nil {
+		transport = &limitedTransport{base: transport, slots: rpcSlots}
+	}
+	opts := []rpc.ClientOption{rpc.WithHTTPClient(&http.Client{Transport: transport})}
+	if proxyURL != nil {
+		opts = append(opts, rpc.WithWebsocketDialer(websocket.Dialer{Proxy: http.ProxyURL(proxyURL)}))
+	}
+	return rpc.DialOptions(ctx, rawURL, opts...)
+}
+
+// limitedTransport holds a slot from acquiring it before a request is sent
+// until its response body is closed, queueing requests beyond the limit
+// for as long as their context allows.
+type limitedTransport struct {
+	base  http.RoundTripper
+	slots *semaphore.Weighted
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	if err := t.slots.Acquire(req.Context(), 1); err != nil {
+		return nil, err
+	}
+	metrics.ObserveRPCQueueWait(start)
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.slots.Release(1)
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: sync.OnceFunc(func() { t.slots.Release(1) })}
+	return resp, nil
+}
+
+// releasingBody frees its request's slot when closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// Dial is ethclient.Dial through the proxy.
+func Dial(rawURL string) (*ethclient.Client, error) {
+	return DialContext(context.Background(), rawURL)
+}
+
+// DialContext is ethclient.DialContext through the proxy.
+func DialContext(ctx context.Context, rawURL string) (*ethclient.Client, error) {
+	c, err := DialRPC(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(c), nil
+}
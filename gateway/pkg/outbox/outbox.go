@@ -0,0 +1,215 @@
+// Package outbox keeps a durable record of on-chain writes the gateway has
+// committed to making, in an embedded bbolt database. A write is recorded
+// before it is broadcast, its signed transaction once signed, and it is
+// removed once mined, so a write cut short by a crash or restart is
+// replayed on the next start instead of being lost.
+package outbox
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	entriesBucket = []byte("entries") // ID -> Entry (JSON)
+	keysBucket    = []byte("keys")    // idempotency key -> ID
+)
+
+// Entry is one on-chain write that has not been mined yet.
+type Entry struct {
+	ID        uint64            `json:"id"`
+	Key       string            `json:"key"`    // at most one pending entry per key; "" for none
+	Method    string            `json:"method"` // contract method, e.g. "closeSession"
+	Params    map[string]string `json:"params,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+
+	// RawTx is the signed transaction once one has been made. Resending it
+	// rather than signing afresh reuses its nonce, so the write is mined at
+	// most once however often it is replayed.
+	RawTx  []byte    `json:"raw_tx,omitempty"`
+	TxHash string    `json:"tx_hash,omitempty"`
+	SentAt time.Time `json:"sent_at,omitzero"`
+}
+
+// Store is a bbolt-backed outbox.
+type Store struct {
+	db  *bolt.DB
+	now func() time.Time
+}
+
+// Open opens (or creates) the outbox database at path.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening outbox db: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{entriesBucket, keysBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initializing outbox db: %w", err)
+	}
+	return &Store{db: db, now: time.Now}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add records a write to make. If an entry with the same key is still
+// pending it is returned instead and added is false. A write with an empty
+// key is always added.
+func (s *Store) Add(key, method string, params map[string]string) (e Entry, added bool, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		entries, keys := tx.Bucket(entriesBucket), tx.Bucket(keysBucket)
+		if key != "" {
+			if id := keys.Get([]byte(key)); id != nil {
+				if v := entries.Get(id); v != nil {
+					return json.Unmarshal(v, &e)
+				}
+			}
+		}
+
+		seq, err := entries.NextSequence()
+		if err != nil {
+			return err
+		}
+		e = Entry{ID: seq, Key: key, Method: method, Params: params, CreatedAt: s.now().UTC()}
+		if err := putEntry(entries, e); err != nil {
+			return err
+		}
+		added = true
+		if key == "" {
+			return nil
+		}
+		return keys.Put([]byte(key), idKey(seq))
+	})
+	if err != nil {
+		return Entry{}, false, fmt.Errorf("recording %s in outbox: %w", method, err)
+	}
+	return e, added, nil
+}
+
+// MarkSigned records the signed transaction for entry id, before it is
+// broadcast.
+func (s *Store) MarkSigned(id uint64, txHash string, rawTx []byte) error {
+	return s.update(id, func(e *Entry) {
+		e.TxHash = txHash
+		e.RawTx = rawTx
+		e.SentAt = s.now().UTC()
+	})
+}
+
+// SetParam sets param name of entry id, for a value the write looked up
+// when first made that its retries must reuse.
+func (s *Store) SetParam(id uint64, name, value string) error {
+	return s.update(id, func(e *Entry) {
+		if e.Params == nil {
+			e.Params = make(map[string]string)
+		}
+		e.Params[name] = value
+	})
+}
+
+// ClearSigned forgets entry id's transaction, so the write is signed afresh
+// with a new nonce. Only call it once the old transaction can no longer be
+// mined.
+func (s *Store) ClearSigned(id uint64) error {
+	return s.update(id, func(e *Entry) {
+		e.TxHash = ""
+		e.RawTx = nil
+		e.SentAt = time.Time{}
+	})
+}
+
+// Done removes entry id once its write has been mined, or turned out to be
+// unnecessary. Removing an entry that is already gone is not an error.
+func (s *Store) Done(id uint64) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		entries := tx.Bucket(entriesBucket)
+		v := entries.Get(idKey(id))
+		if v == nil {
+			return nil
+		}
+		var e Entry
+		if err := json.Unmarshal(v, &e); err != nil {
+			return err
+		}
+		if e.Key != "" {
+			if err := tx.Bucket(keysBucket).Delete([]byte(e.Key)); err != nil {
+				return err
+			}
+		}
+		return entries.Delete(idKey(id))
+	})
+	if err != nil {
+		return fmt.Errorf("removing outbox entry %d: %w", id, err)
+	}
+	return nil
+}
+
+// Pending returns the entries not yet mined, oldest first.
+func (s *Store) Pending() ([]Entry, error) {
+	var pending []Entry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(entriesBucket).ForEach(func(_, v []byte) error {
+			var e Entry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			pending = append(pending, e)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading outbox: %w", err)
+	}
+	return pending, nil
+}
+
+// update applies fn to entry id; an entry that is already gone is left so.
+func (s *Store) update(id uint64, fn func(*Entry)) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		entries := tx.Bucket(entriesBucket)
+		v := entries.Get(idKey(id))
+		if v == nil {
+			return nil
+		}
+		var e Entry
+		if err := json.Unmarshal(v, &e); err != nil {
+			return err
+		}
+		fn(&e)
+		return putEntry(entries, e)
+	})
+	if err != nil {
+		return fmt.Errorf("updating outbox entry %d: %w", id, err)
+	}
+	return nil
+}
+
+func putEntry(b *bolt.Bucket, e Entry) error {
+	v, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return b.Put(idKey(e.ID), v)
+}
+
+// idKey encodes id big-endian, so entries iterate oldest first.
+func idKey(id uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], id)
+	return buf[:]
+}
@@ -0,0 +1,109 @@
+package outbox
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestAddDeduplicatesPendingKeys(t *testing.T) {
+	s := openTestStore(t)
+
+	first, added, err := s.Add("closeSession:7", "closeSession", map[string]string{"session_id": "7"})
+	if err != nil || !added {
+		t.Fatalf("first Add = %v, %v; want added", added, err)
+	}
+	again, added, err := s.Add("closeSession:7", "closeSession", map[string]string{"session_id": "7"})
+	if err != nil || added || again.ID != first.ID {
+		t.Fatalf("second Add = %+v, %v, %v; want the pending entry back", again, added, err)
+	}
+
+	if err := s.Done(first.ID); err != nil {
+		t.Fatalf("Done: %v", err)
+	}
+	if err := s.Done(first.ID); err != nil {
+		t.Errorf("Done twice: %v", err)
+	}
+	next, added, err := s.Add("closeSession:7", "closeSession", nil)
+	if err != nil || !added || next.ID == first.ID {
+		t.Fatalf("Add after Done = %+v, %v, %v; want a new entry", next, added, err)
+	}
+}
+
+func TestAddWithoutKeyAlwaysAdds(t *testing.T) {
+	s := openTestStore(t)
+
+	params := map[string]string{"user": "0xabc"}
+	first, added, err := s.Add("", "closeSessionFor", params)
+	if err != nil || !added {
+		t.Fatalf("first Add = %v, %v; want added", added, err)
+	}
+	second, added, err := s.Add("", "closeSessionFor", params)
+	if err != nil || !added || second.ID == first.ID {
+		t.Fatalf("second Add = %+v, %v, %v; want a new entry", second, added, err)
+	}
+
+	if err := s.SetParam(first.ID, "session_id", "7"); err != nil {
+		t.Fatalf("SetParam: %v", err)
+	}
+	if err := s.Done(second.ID); err != nil {
+		t.Fatalf("Done: %v", err)
+	}
+	pending, err := s.Pending()
+	if err != nil {
+		t.Fatalf("Pending: %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != first.ID || pending[0].Params["session_id"] != "7" {
+		t.Errorf("pending = %+v, want the first entry with its session_id", pending)
+	}
+}
+
+func TestPendingSurvivesReopenInOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	open, _, _ := s.Add("openFreeSession:0xabc", "openFreeSession", map[string]string{"user": "0xabc"})
+	closeEntry, _, _ := s.Add("closeSession:1", "closeSession", map[string]string{"session_id": "1"})
+	if err := s.MarkSigned(closeEntry.ID, "0xhash", []byte{1, 2, 3}); err != nil {
+		t.Fatalf("MarkSigned: %v", err)
+	}
+	s.Close()
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	pending, err := s.Pending()
+	if err != nil {
+		t.Fatalf("Pending: %v", err)
+	}
+	if len(pending) != 2 || pending[0].ID != open.ID || pending[1].ID != closeEntry.ID {
+		t.Fatalf("Pending = %+v, want the open then the close", pending)
+	}
+	if pending[0].Params["user"] != "0xabc" || pending[0].RawTx != nil {
+		t.Errorf("open entry = %+v", pending[0])
+	}
+	if pending[1].TxHash != "0xhash" || len(pending[1].RawTx) != 3 || pending[1].SentAt.IsZero() {
+		t.Errorf("signed entry = %+v", pending[1])
+	}
+
+	if err := s.ClearSigned(closeEntry.ID); err != nil {
+		t.Fatalf("ClearSigned: %v", err)
+	}
+	pending, _ = s.Pending()
+	if pending[1].RawTx != nil || pending[1].TxHash != "" {
+		t.Errorf("after ClearSigned: %+v", pending[1])
+	}
+}
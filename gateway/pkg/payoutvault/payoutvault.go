@@ -11,12 +11,14 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ethrpc"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
 )
 
 // Client reads the PayoutVault smart contract.
 type Client struct {
-	client       *ethclient.Client
+	client       ethrpc.Caller
 	contractAddr common.Address
 	abi          abi.ABI
 }
@@ -55,11 +57,21 @@ const payoutVaultABIJSON = `[
 
 // NewClient creates a PayoutVault reader connected to an Ethereum RPC.
 func NewClient(rpcURL string, contractAddress string) (*Client, error) {
-	client, err := ethclient.Dial(rpcURL)
+	client, err := outbound.Dial(rpcURL)
 	if err != nil {
 		return nil, fmt.Errorf("connecting to Ethereum RPC: %w", err)
 	}
+	c, err := NewClientWithCaller(client, contractAddress)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return c, nil
+}
 
+// NewClientWithCaller creates a PayoutVault reader on an existing client,
+// e.g. an ethrpc.Fake in tests. Close closes it.
+func NewClientWithCaller(client ethrpc.Caller, contractAddress string) (*Client, error) {
 	parsed, err := abi.JSON(strings.NewReader(payoutVaultABIJSON))
 	if err != nil {
 		return nil, fmt.Errorf("parsing PayoutVault ABI: %w", err)
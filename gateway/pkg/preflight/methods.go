@@ -0,0 +1,128 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// RPC methods the gateway depends on, probed by ProbeMethod.
+const (
+	MethodChainID            = "eth_chainId"
+	MethodCall               = "eth_call"
+	MethodGetLogs            = "eth_getLogs"
+	MethodSendRawTransaction = "eth_sendRawTransaction"
+)
+
+// ErrMethodUnsupported is returned by ProbeMethod when the endpoint answers
+// but refuses the method, as restricted or allowlisting RPC providers do.
+var ErrMethodUnsupported = errors.New("method not supported by this RPC")
+
+// RPCCaller is the subset of rpc.Client used to probe methods.
+type RPCCaller interface {
+	CallContext(ctx context.Context, result any, method string, args ...any) error
+}
+
+// probeArgs are harmless arguments for each method: a call and a log query
+// against the zero address at the latest block, and a raw transaction that
+// cannot be decoded and so is never broadcast.
+var probeArgs = map[string][]any{
+	MethodChainID: nil,
+	MethodCall: {
+		map[string]string{"to": "0x0000000000000000000000000000000000000000", "data": "0x"},
+		"latest",
+	},
+	MethodGetLogs: {
+		map[string]string{"address": "0x0000000000000000000000000000000000000000", "fromBlock": "latest", "toBlock": "latest"},
+	},
+	MethodSendRawTransaction: {"0x"},
+}
+
+// ProbeMethod checks whether the endpoint behind c serves method. It returns
+// nil if it does, an error wrapping ErrMethodUnsupported if the endpoint
+// refuses it, and any other error if the probe itself failed (e.g. the
+// endpoint is down), in which case support is unknown.
+//
+// A method that exists but rejects the probe's arguments counts as
+// supported: the malformed eth_sendRawTransaction is expected to fail that
+// way.
+func ProbeMethod(ctx context.Context, c RPCCaller, method string) error {
+	args, ok := probeArgs[method]
+	if !ok {
+		return fmt.Errorf("no probe for %s", method)
+	}
+	var result any
+	err := c.CallContext(ctx, &result, method, args...)
+	switch {
+	case err == nil:
+		return nil
+	case refusesMethod(err):
+		return fmt.Errorf("%s: %w (%v)", method, ErrMethodUnsupported, err)
+	case isRPCError(err):
+		// The method ran and rejected the probe's arguments.
+		return nil
+	default:
+		return fmt.Errorf("probing %s: %w", method, err)
+	}
+}
+
+// refusesMethod reports whether err is an endpoint saying no to the method
+// itself rather than to its arguments.
+func refusesMethod(err error) bool {
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) && (httpErr.StatusCode == http.StatusForbidden || httpErr.StatusCode == http.StatusMethodNotAllowed) {
+		return true
+	}
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	if rpcErr.ErrorCode() == -32601 { // method not found
+		return true
+	}
+	msg := strings.ToLower(rpcErr.Error())
+	for _, s := range []string{"not supported", "not allowed", "not available", "not whitelisted", "unsupported method", "method not found"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRPCError reports whether err is a JSON-RPC error response, meaning the
+// endpoint was reached and processed the request.
+func isRPCError(err error) bool {
+	var rpcErr rpc.Error
+	return errors.As(err, &rpcErr)
+}
+
+// MethodNeed ties an RPC method to the gateway feature that stops working
+// without it.
+type MethodNeed struct {
+	Method  string
+	Feature string // e.g. "revocation polling"
+}
+
+// MethodResult is the outcome of probing one MethodNeed.
+type MethodResult struct {
+	MethodNeed
+	Err error // nil, ErrMethodUnsupported, or a probe failure
+}
+
+// Unsupported reports whether the endpoint refused the method.
+func (r MethodResult) Unsupported() bool {
+	return errors.Is(r.Err, ErrMethodUnsupported)
+}
+
+// ProbeMethods probes each need in turn.
+func ProbeMethods(ctx context.Context, c RPCCaller, needs []MethodNeed) []MethodResult {
+	results := make([]MethodResult, 0, len(needs))
+	for _, need := range needs {
+		results = append(results, MethodResult{MethodNeed: need, Err: ProbeMethod(ctx, c, need.Method)})
+	}
+	return results
+}
@@ -0,0 +1,107 @@
+package preflight
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// allowlistRPC serves a JSON-RPC endpoint that only honors the methods in
+// allowed, answering anything else the way restricted providers do. A
+// malformed raw transaction gets the invalid-params error a node returns.
+func allowlistRPC(t *testing.T, allowed ...string) *rpc.Client {
+	t.Helper()
+	ok := make(map[string]bool)
+	for _, m := range allowed {
+		ok[m] = true
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		switch {
+		case !ok[req.Method]:
+			resp["error"] = map[string]any{"code": -32601, "message": "the method " + req.Method + " does not exist/is not available"}
+		case req.Method == MethodChainID:
+			resp["result"] = "0x1"
+		case req.Method == MethodCall:
+			resp["result"] = "0x"
+		case req.Method == MethodGetLogs:
+			resp["result"] = []any{}
+		case req.Method == MethodSendRawTransaction:
+			resp["error"] = map[string]any{"code": -32602, "message": "typed transaction too short"}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := rpc.Dial(srv.URL)
+	if err != nil {
+		t.Fatalf("rpc.Dial: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestProbeMethods(t *testing.T) {
+	client := allowlistRPC(t, MethodChainID, MethodCall, MethodSendRawTransaction)
+	results := ProbeMethods(context.Background(), client, []MethodNeed{
+		{MethodChainID, "chain ID check"},
+		{MethodCall, "NFT access checks"},
+		{MethodGetLogs, "revocation polling"},
+		{MethodSendRawTransaction, "heartbeats"},
+	})
+
+	want := map[string]bool{MethodChainID: false, MethodCall: false, MethodGetLogs: true, MethodSendRawTransaction: false}
+	for _, res := range results {
+		if res.Unsupported() != want[res.Method] {
+			t.Errorf("%s: unsupported = %v (err %v), want %v", res.Method, res.Unsupported(), res.Err, want[res.Method])
+		}
+		if !want[res.Method] && res.Err != nil {
+			t.Errorf("%s: unexpected error %v", res.Method, res.Err)
+		}
+	}
+}
+
+func TestProbeMethodRefusals(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		unsupported bool
+		unknown     bool
+	}{
+		{"forbidden", rpc.HTTPError{StatusCode: http.StatusForbidden, Status: "403 Forbidden"}, true, false},
+		{"not whitelisted", codedError{-32000, "method eth_getLogs is not whitelisted"}, true, false},
+		{"bad params", codedError{-32602, "invalid argument 0"}, false, false},
+		{"down", errors.New("dial tcp: connection refused"), false, true},
+	}
+	for _, tt := range tests {
+		err := ProbeMethod(context.Background(), failingCaller{tt.err}, MethodGetLogs)
+		if got := errors.Is(err, ErrMethodUnsupported); got != tt.unsupported {
+			t.Errorf("%s: unsupported = %v (err %v), want %v", tt.name, got, err, tt.unsupported)
+		}
+		if got := err != nil && !tt.unsupported; got != tt.unknown {
+			t.Errorf("%s: probe failure = %v (err %v), want %v", tt.name, got, err, tt.unknown)
+		}
+	}
+}
+
+type failingCaller struct{ err error }
+
+func (f failingCaller) CallContext(context.Context, any, string, ...any) error { return f.err }
+
+type codedError struct {
+	code int
+	msg  string
+}
+
+func (e codedError) Error() string  { return e.msg }
+func (e codedError) ErrorCode() int { return e.code }
@@ -0,0 +1,88 @@
+// Package preflight checks a gateway's configuration against the chain it
+// points at: the RPC answers, the chain ID matches, and every configured
+// contract address has bytecode deployed.
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ChainReader is the subset of ethclient.Client used by the checks.
+type ChainReader interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name string
+	Err  error
+}
+
+// Report is an ordered list of check results.
+type Report []Result
+
+// Add records a check outcome.
+func (r *Report) Add(name string, err error) {
+	*r = append(*r, Result{Name: name, Err: err})
+}
+
+// OK reports whether every check passed.
+func (r Report) OK() bool {
+	for _, res := range r {
+		if res.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Print writes one PASS/FAIL line per check and a summary.
+func (r Report) Print(w io.Writer) {
+	failed := 0
+	for _, res := range r {
+		if res.Err != nil {
+			failed++
+			fmt.Fprintf(w, "FAIL  %s: %v\n", res.Name, res.Err)
+		} else {
+			fmt.Fprintf(w, "PASS  %s\n", res.Name)
+		}
+	}
+	if failed == 0 {
+		fmt.Fprintf(w, "\n%d checks passed\n", len(r))
+	} else {
+		fmt.Fprintf(w, "\n%d of %d checks failed\n", failed, len(r))
+	}
+}
+
+// CheckChainID verifies the RPC endpoint serves chain want.
+func CheckChainID(ctx context.Context, c ChainReader, want int64) error {
+	got, err := c.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("querying chain ID: %w", err)
+	}
+	if got.Int64() != want {
+		return fmt.Errorf("RPC is on chain %s, expected %d", got, want)
+	}
+	return nil
+}
+
+// CheckContract verifies addr is a well-formed address with code deployed.
+func CheckContract(ctx context.Context, c ChainReader, addr string) error {
+	if !common.IsHexAddress(addr) {
+		return fmt.Errorf("%q is not a valid address", addr)
+	}
+	code, err := c.CodeAt(ctx, common.HexToAddress(addr), nil)
+	if err != nil {
+		return fmt.Errorf("fetching bytecode: %w", err)
+	}
+	if len(code) == 0 {
+		return fmt.Errorf("no contract deployed at %s", addr)
+	}
+	return nil
+}
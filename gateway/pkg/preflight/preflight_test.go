@@ -0,0 +1,77 @@
+package preflight
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type fakeChain struct {
+	chainID int64
+	code    map[common.Address][]byte
+	err     error
+}
+
+func (f fakeChain) ChainID(context.Context) (*big.Int, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return big.NewInt(f.chainID), nil
+}
+
+func (f fakeChain) CodeAt(_ context.Context, addr common.Address, _ *big.Int) ([]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.code[addr], nil
+}
+
+func TestChecks(t *testing.T) {
+	deployed := "0x0000000000000000000000000000000000000001"
+	chain := fakeChain{
+		chainID: 11155111,
+		code:    map[common.Address][]byte{common.HexToAddress(deployed): {0x60, 0x80}},
+	}
+	ctx := context.Background()
+
+	if err := CheckChainID(ctx, chain, 11155111); err != nil {
+		t.Errorf("matching chain ID: %v", err)
+	}
+	if err := CheckChainID(ctx, chain, 1); err == nil {
+		t.Error("expected chain ID mismatch")
+	}
+	if err := CheckContract(ctx, chain, deployed); err != nil {
+		t.Errorf("deployed contract: %v", err)
+	}
+	if err := CheckContract(ctx, chain, "0x0000000000000000000000000000000000000002"); err == nil {
+		t.Error("expected error for address without code")
+	}
+	if err := CheckContract(ctx, chain, "not-an-address"); err == nil {
+		t.Error("expected error for malformed address")
+	}
+	if err := CheckChainID(ctx, fakeChain{err: errors.New("dial failed")}, 1); err == nil {
+		t.Error("expected RPC error to surface")
+	}
+}
+
+func TestReportPrint(t *testing.T) {
+	var r Report
+	r.Add("config", nil)
+	r.Add("chain id", errors.New("RPC is on chain 1, expected 11155111"))
+	if r.OK() {
+		t.Fatal("report with a failure must not be OK")
+	}
+
+	var out bytes.Buffer
+	r.Print(&out)
+	for _, want := range []string{"PASS  config", "FAIL  chain id: RPC is on chain 1", "1 of 2 checks failed"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
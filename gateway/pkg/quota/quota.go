@@ -0,0 +1,128 @@
+// Package quota accounts WireGuard data usage per wallet over a billing
+// period, built from the per-peer transfer counters reported by `wg show`.
+package quota
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultPeriod is the accounting period used when none is configured.
+const DefaultPeriod = 30 * 24 * time.Hour
+
+// Usage is a wallet's consumption in the current period.
+type Usage struct {
+	UsedBytes   uint64    `json:"used_bytes"`
+	LimitBytes  uint64    `json:"limit_bytes"` // 0 = unlimited
+	PeriodStart time.Time `json:"period_start"`
+	ResetsAt    time.Time `json:"resets_at"`
+	Exceeded    bool      `json:"exceeded"`
+}
+
+// peerCounter is the last counter total seen for a peer. Counters restart at
+// zero whenever a peer is (re)added, which is detected by assignedAt changing.
+type peerCounter struct {
+	assignedAt time.Time
+	total      uint64
+}
+
+type walletUsage struct {
+	periodStart time.Time
+	used        uint64
+}
+
+// Meter accumulates per-wallet usage from cumulative peer counters.
+type Meter struct {
+	period time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	peers   map[string]peerCounter  // keyed by WireGuard public key
+	wallets map[string]*walletUsage // keyed by wallet
+}
+
+// New creates a meter with the given accounting period (DefaultPeriod if
+// zero). Periods are aligned to multiples of period since the zero time, so
+// all wallets reset together.
+func New(period time.Duration) *Meter {
+	if period <= 0 {
+		period = DefaultPeriod
+	}
+	return &Meter{
+		period:  period,
+		now:     time.Now,
+		peers:   make(map[string]peerCounter),
+		wallets: make(map[string]*walletUsage),
+	}
+}
+
+// Observe records the cumulative byte count (sent + received) of a peer owned
+// by wallet and returns the new bytes attributed to the wallet. assignedAt is
+// when the peer was provisioned; a new value means the kernel counters were
+// reset and total is counted from zero.
+func (m *Meter) Observe(wallet, pubKey string, assignedAt time.Time, total uint64) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delta := total
+	if last, ok := m.peers[pubKey]; ok && last.assignedAt.Equal(assignedAt) {
+		if total >= last.total {
+			delta = total - last.total
+		}
+		// total < last.total without a re-add means the interface was
+		// recreated; the new total is all fresh traffic.
+	}
+	m.peers[pubKey] = peerCounter{assignedAt: assignedAt, total: total}
+
+	m.walletLocked(wallet).used += delta
+	return delta
+}
+
+// Retain drops counters for peers not in active, and wallets whose period
+// has ended (they would start from zero anyway).
+func (m *Meter) Retain(active map[string]bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for pubKey := range m.peers {
+		if !active[pubKey] {
+			delete(m.peers, pubKey)
+		}
+	}
+	start := m.now().Truncate(m.period)
+	for wallet, u := range m.wallets {
+		if u.periodStart.Before(start) {
+			delete(m.wallets, wallet)
+		}
+	}
+}
+
+// Usage reports wallet's consumption against limit (0 = unlimited).
+func (m *Meter) Usage(wallet string, limit uint64) Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u := m.walletLocked(wallet)
+	return Usage{
+		UsedBytes:   u.used,
+		LimitBytes:  limit,
+		PeriodStart: u.periodStart,
+		ResetsAt:    u.periodStart.Add(m.period),
+		Exceeded:    limit > 0 && u.used >= limit,
+	}
+}
+
+// walletLocked returns wallet's usage, starting a fresh period if the
+// previous one has ended. Caller must hold m.mu.
+func (m *Meter) walletLocked(wallet string) *walletUsage {
+	start := m.now().Truncate(m.period)
+	u, ok := m.wallets[wallet]
+	if !ok {
+		u = &walletUsage{periodStart: start}
+		m.wallets[wallet] = u
+	} else if u.periodStart.Before(start) {
+		u.periodStart = start
+		u.used = 0
+	}
+	return u
+}
@@ -0,0 +1,87 @@
+package quota
+
+import (
+	"testing"
+	"time"
+)
+
+func TestObserveAccumulatesDeltas(t *testing.T) {
+	m := New(time.Hour)
+	added := time.Now()
+
+	m.Observe("alice", "laptop", added, 100)
+	if delta := m.Observe("alice", "laptop", added, 250); delta != 150 {
+		t.Fatalf("delta = %d, want 150", delta)
+	}
+	m.Observe("alice", "phone", added, 50)
+	if used := m.Usage("alice", 0).UsedBytes; used != 300 {
+		t.Fatalf("used = %d, want 300", used)
+	}
+	if u := m.Usage("bob", 0); u.UsedBytes != 0 {
+		t.Fatalf("bob used = %d, want 0", u.UsedBytes)
+	}
+}
+
+func TestObserveHandlesCounterReset(t *testing.T) {
+	m := New(time.Hour)
+	first := time.Now()
+
+	m.Observe("alice", "laptop", first, 1000)
+
+	// Peer re-added: kernel counters restart, so the full new total counts
+	// even though it is already above the previous reading.
+	readded := first.Add(time.Minute)
+	if delta := m.Observe("alice", "laptop", readded, 1500); delta != 1500 {
+		t.Fatalf("after re-add delta = %d, want 1500", delta)
+	}
+
+	// Counter went backwards without a re-add (interface recreated).
+	if delta := m.Observe("alice", "laptop", readded, 200); delta != 200 {
+		t.Fatalf("after interface reset delta = %d, want 200", delta)
+	}
+	if used := m.Usage("alice", 0).UsedBytes; used != 2700 {
+		t.Fatalf("used = %d, want 2700", used)
+	}
+}
+
+func TestUsageExceededAndPeriodReset(t *testing.T) {
+	now := time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC)
+	m := New(time.Hour)
+	m.now = func() time.Time { return now }
+
+	m.Observe("alice", "laptop", now, 500)
+	u := m.Usage("alice", 500)
+	if !u.Exceeded {
+		t.Fatalf("expected quota exceeded, got %+v", u)
+	}
+	if want := time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC); !u.ResetsAt.Equal(want) {
+		t.Fatalf("resets_at = %s, want %s", u.ResetsAt, want)
+	}
+	if m.Usage("alice", 0).Exceeded {
+		t.Fatal("unlimited quota must never be exceeded")
+	}
+
+	now = now.Add(time.Hour)
+	if u := m.Usage("alice", 500); u.Exceeded || u.UsedBytes != 0 {
+		t.Fatalf("expected fresh period, got %+v", u)
+	}
+	// Only traffic after the boundary counts toward the new period.
+	m.Observe("alice", "laptop", now.Add(-time.Hour), 800)
+	if used := m.Usage("alice", 0).UsedBytes; used != 300 {
+		t.Fatalf("used after reset = %d, want 300", used)
+	}
+}
+
+func TestRetainDropsRemovedPeers(t *testing.T) {
+	m := New(time.Hour)
+	added := time.Now()
+
+	m.Observe("alice", "laptop", added, 100)
+	m.Retain(map[string]bool{})
+
+	// With the old reading gone, the next total is counted in full.
+	m.Observe("alice", "laptop", added, 100)
+	if used := m.Usage("alice", 0).UsedBytes; used != 200 {
+		t.Fatalf("used = %d, want 200", used)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/janitor"
 )
 
 // Limiter tracks per-IP request counts over a rolling window.
@@ -14,7 +16,7 @@ type Limiter struct {
 	limit    int
 	window   time.Duration
 	visitors map[string]*visitor
-	stopCh   chan struct{}
+	stop     func()
 }
 
 type visitor struct {
@@ -29,9 +31,8 @@ func New(limit int, window time.Duration) *Limiter {
 		limit:    limit,
 		window:   window,
 		visitors: make(map[string]*visitor),
-		stopCh:   make(chan struct{}),
 	}
-	go l.cleanup()
+	l.stop = janitor.Register("ratelimit visitors", l.removeExpired)
 	return l
 }
 
@@ -69,30 +70,20 @@ func (l *Limiter) Wrap(next http.Handler) http.Handler {
 	})
 }
 
-// Stop shuts down the background cleanup goroutine.
+// Stop removes the limiter from the janitor's periodic sweep.
 func (l *Limiter) Stop() {
-	close(l.stopCh)
+	l.stop()
 }
 
-// cleanup removes expired visitor entries every 2 minutes.
-func (l *Limiter) cleanup() {
-	ticker := time.NewTicker(2 * time.Minute)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ticker.C:
-			l.mu.Lock()
-			now := time.Now()
-			for ip, v := range l.visitors {
-				if now.After(v.resetAt) {
-					delete(l.visitors, ip)
-				}
-			}
-			l.mu.Unlock()
-		case <-l.stopCh:
-			return
+// removeExpired deletes visitors whose window has ended. Run by the janitor.
+func (l *Limiter) removeExpired(now time.Time) {
+	l.mu.Lock()
+	for ip, v := range l.visitors {
+		if now.After(v.resetAt) {
+			delete(l.visitors, ip)
 		}
 	}
+	l.mu.Unlock()
 }
 
 // extractIP returns the client IP, preferring X-Forwarded-For when present
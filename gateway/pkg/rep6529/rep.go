@@ -13,8 +13,14 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/janitor"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/lru"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
 )
 
 const (
@@ -26,6 +32,13 @@ const (
 
 	// DefaultMinRep is the minimum rep required to operate a node.
 	DefaultMinRep = 6529
+
+	// DefaultMaxCacheEntries bounds the rep cache between janitor sweeps.
+	DefaultMaxCacheEntries = 10000
+
+	// maxBackingPages bounds how many breakdown pages CheckRep walks when
+	// counting raters and backing TDH for a single wallet.
+	maxBackingPages = 10
 )
 
 // Config configures the 6529 rep checker.
@@ -35,13 +48,24 @@ type Config struct {
 	MinRep      int64         // Minimum rep required (default: 6529)
 	CacheTTL    time.Duration // How long to cache rep lookups (default: 5m)
 	HTTPTimeout time.Duration // HTTP request timeout (default: 10s)
+
+	// MaxCacheEntries caps cached lookups; the least recently used is
+	// evicted when full (default: DefaultMaxCacheEntries).
+	MaxCacheEntries int
+
+	// MinRaters and MinBackingTDH stop a single large holder from carrying
+	// a wallet over MinRep on their own. Zero disables the check.
+	MinRaters     int64 // Minimum distinct raters giving positive rep
+	MinBackingTDH int64 // Minimum combined TDH of those raters
 }
 
 // RepResult holds the result of a rep check.
 type RepResult struct {
-	Rating    int64     // Total rep in the category
-	Eligible  bool      // Whether rating >= MinRep
-	CheckedAt time.Time // When this was checked
+	Rating     int64     // Total rep in the category
+	Raters     int64     // Distinct raters giving positive rep (only when MinRaters/MinBackingTDH set)
+	BackingTDH int64     // Combined TDH of those raters (only when MinRaters/MinBackingTDH set)
+	Eligible   bool      // Whether rating >= MinRep and the backing thresholds are met
+	CheckedAt  time.Time // When this was checked
 }
 
 // Identity holds profile info from the 6529 API.
@@ -66,8 +90,13 @@ type Checker struct {
 	cacheTTL time.Duration
 	client   *http.Client
 
-	mu    sync.RWMutex
-	cache map[string]cacheEntry // wallet address → cached result
+	minRaters     int64
+	minBackingTDH int64
+
+	mu    sync.Mutex
+	cache *lru.Cache[string, cacheEntry] // wallet address → cached result
+
+	stopSweep func()
 }
 
 // NewChecker creates a new 6529 rep checker.
@@ -87,27 +116,43 @@ func NewChecker(cfg Config) *Checker {
 	if cfg.HTTPTimeout == 0 {
 		cfg.HTTPTimeout = 10 * time.Second
 	}
+	if cfg.MaxCacheEntries == 0 {
+		cfg.MaxCacheEntries = DefaultMaxCacheEntries
+	}
 
-	return &Checker{
+	c := &Checker{
 		baseURL:  cfg.BaseURL,
 		category: cfg.Category,
 		minRep:   cfg.MinRep,
 		cacheTTL: cfg.CacheTTL,
-		client:   &http.Client{Timeout: cfg.HTTPTimeout},
-		cache:    make(map[string]cacheEntry),
+		client:   &http.Client{Timeout: cfg.HTTPTimeout, Transport: outbound.Transport()},
+		cache:    lru.New[string, cacheEntry](cfg.MaxCacheEntries),
+
+		minRaters:     cfg.MinRaters,
+		minBackingTDH: cfg.MinBackingTDH,
 	}
+	c.stopSweep = janitor.Register("rep6529 cache", c.removeExpired)
+	return c
+}
+
+// Close stops the periodic cleanup of expired cache entries.
+func (c *Checker) Close() {
+	c.stopSweep()
 }
 
 // CheckRep queries the 6529 API for the wallet's rep in the VPN Operator category.
 // Returns whether the wallet has sufficient rep to operate a node.
 func (c *Checker) CheckRep(ctx context.Context, walletOrHandle string) (RepResult, error) {
 	// Check cache
-	c.mu.RLock()
-	if entry, ok := c.cache[walletOrHandle]; ok && time.Now().Before(entry.expiresAt) {
-		c.mu.RUnlock()
-		return entry.result, nil
+	c.mu.Lock()
+	if entry, ok := c.cache.Get(walletOrHandle); ok {
+		if time.Now().Before(entry.expiresAt) {
+			c.mu.Unlock()
+			return entry.result, nil
+		}
+		c.cache.Remove(walletOrHandle)
 	}
-	c.mu.RUnlock()
+	c.mu.Unlock()
 
 	// Query 6529 API
 	// GET /profiles/{identity}/rep/rating?category=VPN+Operator
@@ -145,17 +190,69 @@ func (c *Checker) CheckRep(ctx context.Context, walletOrHandle string) (RepResul
 		CheckedAt: time.Now(),
 	}
 
+	// Only walk the breakdown when the rating alone would pass; a wallet
+	// below MinRep is ineligible regardless of who backs it.
+	if result.Eligible && c.requiresBacking() {
+		raters, tdh, err := c.backing(ctx, walletOrHandle)
+		if err != nil {
+			return RepResult{}, err
+		}
+		result.Raters = raters
+		result.BackingTDH = tdh
+		result.Eligible = raters >= c.minRaters && tdh >= c.minBackingTDH
+	}
+
 	// Cache result
 	c.mu.Lock()
-	c.cache[walletOrHandle] = cacheEntry{
+	c.cache.Add(walletOrHandle, cacheEntry{
 		result:    result,
 		expiresAt: time.Now().Add(c.cacheTTL),
-	}
+	}, nil)
 	c.mu.Unlock()
 
 	return result, nil
 }
 
+func (c *Checker) requiresBacking() bool {
+	return c.minRaters > 0 || c.minBackingTDH > 0
+}
+
+// backing counts the distinct raters giving the wallet positive rep and sums
+// their TDH. Pages are walked highest rating first and the walk stops at the
+// first non-positive rating, once both thresholds are met, or after
+// maxBackingPages pages.
+func (c *Checker) backing(ctx context.Context, walletOrHandle string) (raters, tdh int64, err error) {
+	seen := make(map[string]bool)
+	for page := 1; page <= maxBackingPages; page++ {
+		bd, err := c.GetRepBreakdownPage(ctx, walletOrHandle, BreakdownQuery{
+			Page:     page,
+			PageSize: MaxBreakdownPageSize,
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, rc := range bd.Data {
+			if rc.Rating <= 0 {
+				return raters, tdh, nil
+			}
+			key := strings.ToLower(rc.Handle)
+			if key == "" && len(rc.Wallets) > 0 {
+				key = strings.ToLower(rc.Wallets[0])
+			}
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			raters++
+			tdh += rc.TDH
+		}
+		if !bd.Next || (raters >= c.minRaters && tdh >= c.minBackingTDH) {
+			break
+		}
+	}
+	return raters, tdh, nil
+}
+
 // GetIdentity fetches the full 6529 identity for a wallet or handle.
 func (c *Checker) GetIdentity(ctx context.Context, walletOrHandle string) (*Identity, error) {
 	u := fmt.Sprintf("%s/identities/%s",
@@ -189,12 +286,32 @@ func (c *Checker) GetIdentity(ctx context.Context, walletOrHandle string) (*Iden
 	return &id, nil
 }
 
-// GetRepBreakdown fetches who gave rep to this wallet in the VPN Operator category.
+// GetRepBreakdown fetches the first page of who gave rep to this wallet in the
+// VPN Operator category, highest rating first.
 func (c *Checker) GetRepBreakdown(ctx context.Context, walletOrHandle string) ([]RepContribution, error) {
-	u := fmt.Sprintf("%s/profiles/%s/rep/ratings/by-rater?category=%s&page_size=50&order=DESC&order_by=rating",
+	page, err := c.GetRepBreakdownPage(ctx, walletOrHandle, BreakdownQuery{})
+	if err != nil {
+		return nil, err
+	}
+	return page.Data, nil
+}
+
+// GetRepBreakdownPage fetches one page of rep contributions for the wallet in
+// the VPN Operator category, along with the total number of raters.
+func (c *Checker) GetRepBreakdownPage(ctx context.Context, walletOrHandle string, q BreakdownQuery) (*RepBreakdown, error) {
+	q = q.normalize()
+
+	params := url.Values{}
+	params.Set("category", c.category)
+	params.Set("page", strconv.Itoa(q.Page))
+	params.Set("page_size", strconv.Itoa(q.PageSize))
+	params.Set("order", q.Order)
+	params.Set("order_by", "rating")
+
+	u := fmt.Sprintf("%s/profiles/%s/rep/ratings/by-rater?%s",
 		c.baseURL,
 		url.PathEscape(walletOrHandle),
-		url.QueryEscape(c.category),
+		params.Encode(),
 	)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
@@ -213,13 +330,71 @@ func (c *Checker) GetRepBreakdown(ctx context.Context, walletOrHandle string) ([
 	}
 
 	var result struct {
-		Data []RepContribution `json:"data"`
+		Data  []RepContribution `json:"data"`
+		Count int64             `json:"count"`
+		Page  int               `json:"page"`
+		Next  bool              `json:"next"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("decoding breakdown: %w", err)
 	}
 
-	return result.Data, nil
+	page := &RepBreakdown{
+		Data:     result.Data,
+		Count:    result.Count,
+		Page:     result.Page,
+		PageSize: q.PageSize,
+		Next:     result.Next,
+	}
+	if page.Page == 0 {
+		page.Page = q.Page
+	}
+	if page.Data == nil {
+		page.Data = []RepContribution{}
+	}
+	return page, nil
+}
+
+// BreakdownQuery selects a page of the rep breakdown. Zero values use the
+// defaults: page 1, 50 results, highest rating first.
+type BreakdownQuery struct {
+	Page     int    // 1-based page number (default: 1)
+	PageSize int    // Results per page (default: 50, max: 100)
+	Order    string // "DESC" or "ASC" by rating (default: "DESC")
+}
+
+const (
+	// DefaultBreakdownPageSize is the page size used when none is given.
+	DefaultBreakdownPageSize = 50
+
+	// MaxBreakdownPageSize caps the page size sent to the 6529 API.
+	MaxBreakdownPageSize = 100
+)
+
+func (q BreakdownQuery) normalize() BreakdownQuery {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PageSize < 1 {
+		q.PageSize = DefaultBreakdownPageSize
+	}
+	if q.PageSize > MaxBreakdownPageSize {
+		q.PageSize = MaxBreakdownPageSize
+	}
+	q.Order = strings.ToUpper(q.Order)
+	if q.Order != "ASC" {
+		q.Order = "DESC"
+	}
+	return q
+}
+
+// RepBreakdown is one page of rep contributions.
+type RepBreakdown struct {
+	Data     []RepContribution `json:"data"`
+	Count    int64             `json:"count"`     // Total raters across all pages
+	Page     int               `json:"page"`      // Page number returned
+	PageSize int               `json:"page_size"` // Page size requested
+	Next     bool              `json:"next"`      // Whether another page follows
 }
 
 // RepContribution represents a single rep rating from one community member.
@@ -231,11 +406,36 @@ type RepContribution struct {
 	Wallets []string `json:"wallets"`
 }
 
+// Ping checks that the 6529 API is reachable. Any non-5xx response counts.
+func (c *Checker) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("querying 6529 API: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("6529 API returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
 // MinRepRequired returns the configured minimum rep threshold.
 func (c *Checker) MinRepRequired() int64 {
 	return c.minRep
 }
 
+// MinBackingRequired returns the configured minimum distinct raters and
+// backing TDH. Both are zero when only the total rating is checked.
+func (c *Checker) MinBackingRequired() (raters, tdh int64) {
+	return c.minRaters, c.minBackingTDH
+}
+
 // Category returns the configured rep category name.
 func (c *Checker) Category() string {
 	return c.category
@@ -244,6 +444,22 @@ func (c *Checker) Category() string {
 // InvalidateCache removes a cached entry for a wallet.
 func (c *Checker) InvalidateCache(walletOrHandle string) {
 	c.mu.Lock()
-	delete(c.cache, walletOrHandle)
+	c.cache.Remove(walletOrHandle)
+	c.mu.Unlock()
+}
+
+// removeExpired drops cache entries past their TTL. Run by the janitor.
+func (c *Checker) removeExpired(now time.Time) {
+	c.mu.Lock()
+	c.cache.RemoveFunc(func(_ string, entry cacheEntry) bool {
+		return now.After(entry.expiresAt)
+	})
 	c.mu.Unlock()
 }
+
+// CacheSize returns the number of cached lookups.
+func (c *Checker) CacheSize() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cache.Len()
+}
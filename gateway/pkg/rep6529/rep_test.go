@@ -3,8 +3,11 @@ package rep6529
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -183,6 +186,26 @@ func TestCheckRepCaching(t *testing.T) {
 	}
 }
 
+func TestCheckRepCacheCap(t *testing.T) {
+	api := mock6529API(map[string]int64{})
+	defer api.Close()
+
+	c := NewChecker(Config{
+		BaseURL:         api.URL + "/api",
+		CacheTTL:        time.Minute,
+		MaxCacheEntries: 5,
+	})
+
+	for i := 0; i < 25; i++ {
+		if _, err := c.CheckRep(context.Background(), fmt.Sprintf("0x%040x", i)); err != nil {
+			t.Fatalf("CheckRep: %v", err)
+		}
+	}
+	if n := c.CacheSize(); n != 5 {
+		t.Fatalf("CacheSize = %d, want 5", n)
+	}
+}
+
 func TestCheckRepCacheInvalidation(t *testing.T) {
 	callCount := 0
 	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -268,6 +291,143 @@ func TestGetRepBreakdown(t *testing.T) {
 	}
 }
 
+func TestGetRepBreakdownPaging(t *testing.T) {
+	const total = 120
+	var lastQuery url.Values
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastQuery = r.URL.Query()
+		page, _ := strconv.Atoi(lastQuery.Get("page"))
+		size, _ := strconv.Atoi(lastQuery.Get("page_size"))
+
+		data := []map[string]any{}
+		for i := (page - 1) * size; i < page*size && i < total; i++ {
+			data = append(data, map[string]any{"handle": fmt.Sprintf("rater%d", i), "rating": total - i})
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"data":  data,
+			"count": total,
+			"page":  page,
+			"next":  page*size < total,
+		})
+	}))
+	defer api.Close()
+
+	c := NewChecker(Config{BaseURL: api.URL + "/api"})
+
+	first, err := c.GetRepBreakdownPage(context.Background(), "0xOp", BreakdownQuery{})
+	if err != nil {
+		t.Fatalf("GetRepBreakdownPage: %v", err)
+	}
+	if lastQuery.Get("page") != "1" || lastQuery.Get("page_size") != "50" || lastQuery.Get("order") != "DESC" {
+		t.Errorf("unexpected default query: %v", lastQuery)
+	}
+	if first.Count != total || len(first.Data) != 50 || !first.Next {
+		t.Fatalf("first page: count=%d len=%d next=%v", first.Count, len(first.Data), first.Next)
+	}
+
+	last, err := c.GetRepBreakdownPage(context.Background(), "0xOp", BreakdownQuery{Page: 3, PageSize: 50, Order: "asc"})
+	if err != nil {
+		t.Fatalf("GetRepBreakdownPage: %v", err)
+	}
+	if lastQuery.Get("order") != "ASC" {
+		t.Errorf("expected order=ASC, got %q", lastQuery.Get("order"))
+	}
+	if last.Page != 3 || len(last.Data) != 20 || last.Next {
+		t.Fatalf("last page: page=%d len=%d next=%v", last.Page, len(last.Data), last.Next)
+	}
+	if last.Data[0].Handle != "rater100" {
+		t.Errorf("expected rater100, got %q", last.Data[0].Handle)
+	}
+
+	if _, err := c.GetRepBreakdownPage(context.Background(), "0xOp", BreakdownQuery{PageSize: 500}); err != nil {
+		t.Fatalf("GetRepBreakdownPage: %v", err)
+	}
+	if lastQuery.Get("page_size") != "100" {
+		t.Errorf("expected page_size capped at 100, got %q", lastQuery.Get("page_size"))
+	}
+}
+
+func TestCheckRepBacking(t *testing.T) {
+	// One whale supplies almost all of the rating; two small raters add the
+	// rest and a negative rater must not count toward the backing.
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/ratings/by-rater") {
+			json.NewEncoder(w).Encode(map[string]any{
+				"data": []map[string]any{
+					{"handle": "whale", "tdh": 5000000, "rating": 10000},
+					{"handle": "small1", "tdh": 1000, "rating": 50},
+					{"handle": "small2", "tdh": 2000, "rating": 10},
+					{"handle": "hater", "tdh": 900000, "rating": -500},
+				},
+				"count": 4,
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]int64{"rating": 9560})
+	}))
+	defer api.Close()
+
+	tests := []struct {
+		name      string
+		minRaters int64
+		minTDH    int64
+		eligible  bool
+	}{
+		{"rating only", 0, 0, true},
+		{"enough raters", 3, 0, true},
+		{"too few raters", 4, 0, false},
+		{"enough tdh", 0, 5003000, true},
+		{"too little tdh", 0, 5003001, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(Config{
+				BaseURL:       api.URL + "/api",
+				MinRep:        6529,
+				MinRaters:     tt.minRaters,
+				MinBackingTDH: tt.minTDH,
+			})
+			result, err := c.CheckRep(context.Background(), "0xOp")
+			if err != nil {
+				t.Fatalf("CheckRep: %v", err)
+			}
+			if result.Eligible != tt.eligible {
+				t.Fatalf("expected eligible=%v, got %+v", tt.eligible, result)
+			}
+			if tt.minRaters == 0 && tt.minTDH == 0 {
+				if result.Raters != 0 {
+					t.Errorf("expected breakdown to be skipped, got %d raters", result.Raters)
+				}
+				return
+			}
+			if result.Raters != 3 || result.BackingTDH != 5003000 {
+				t.Errorf("expected 3 raters backing 5003000 TDH, got %d/%d", result.Raters, result.BackingTDH)
+			}
+		})
+	}
+}
+
+func TestCheckRepBackingSkippedBelowMinRep(t *testing.T) {
+	var breakdownCalls int
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/ratings/by-rater") {
+			breakdownCalls++
+		}
+		json.NewEncoder(w).Encode(map[string]int64{"rating": 100})
+	}))
+	defer api.Close()
+
+	c := NewChecker(Config{BaseURL: api.URL + "/api", MinRep: 6529, MinRaters: 5})
+	result, err := c.CheckRep(context.Background(), "0xOp")
+	if err != nil {
+		t.Fatalf("CheckRep: %v", err)
+	}
+	if result.Eligible || breakdownCalls != 0 {
+		t.Fatalf("expected ineligible without breakdown lookup, got %+v after %d calls", result, breakdownCalls)
+	}
+}
+
 func TestDefaultConfig(t *testing.T) {
 	c := NewChecker(Config{})
 
@@ -280,6 +440,12 @@ func TestDefaultConfig(t *testing.T) {
 	if c.minRep != DefaultMinRep {
 		t.Errorf("expected default min rep %d, got %d", DefaultMinRep, c.minRep)
 	}
+	for i := 0; i < DefaultMaxCacheEntries+10; i++ {
+		c.cache.Add(strconv.Itoa(i), cacheEntry{}, nil)
+	}
+	if n := c.CacheSize(); n != DefaultMaxCacheEntries {
+		t.Errorf("expected default cache cap %d, got %d", DefaultMaxCacheEntries, n)
+	}
 }
 
 func TestMinRepAndCategory(t *testing.T) {
@@ -0,0 +1,163 @@
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
+)
+
+// AccessPolicy governance event signatures (keccak256). ThisCardTokenIdLocked
+// is left out: locking does not change who has access.
+var (
+	// ThisCardTokenIdSet(uint256 tokenId)
+	thisCardTokenIDSetSig = common.HexToHash("0x261c02167df5fdb1ba3e60c01235e21c8af286fdca8cd30b63ad188e544b1cc1")
+	// KnownTokenIdAdded(uint256 tokenId)
+	knownTokenIDAddedSig = common.HexToHash("0xa4023ef56192abfb489faac187a5452f0f0c9d594e78a9476c5edbdc2541d5d7")
+	// KnownTokenIdRemoved(uint256 tokenId)
+	knownTokenIDRemovedSig = common.HexToHash("0x02e9dc4fd18e293df4933095ec6927b8400a51b0fa4ba3b44db5b1c760c9ff98")
+)
+
+var policyEventNames = map[common.Hash]string{
+	thisCardTokenIDSetSig:  "ThisCardTokenIdSet",
+	knownTokenIDAddedSig:   "KnownTokenIdAdded",
+	knownTokenIDRemovedSig: "KnownTokenIdRemoved",
+}
+
+// CacheFlusher drops every cached access result.
+type CacheFlusher interface {
+	InvalidateAll()
+}
+
+// PolicyWatcher polls the AccessPolicy contract for governance changes to
+// the qualifying token IDs and flushes the access cache when one lands, so
+// tiers follow the new policy at once instead of after the cache TTL. A
+// policy change can affect any wallet, so the whole cache goes; sessions
+// are left alone and are re-checked as they renew.
+type PolicyWatcher struct {
+	client    logSource
+	closer    func()
+	policy    common.Address
+	flusher   CacheFlusher
+	interval  time.Duration
+	lastBlock uint64
+	cancel    context.CancelFunc
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+// NewPolicyWatcher creates a watcher that polls rpcURL for AccessPolicy
+// events every interval (DefaultPollInterval if zero).
+func NewPolicyWatcher(rpcURL string, policy common.Address, flusher CacheFlusher, interval time.Duration) (*PolicyWatcher, error) {
+	client, err := outbound.Dial(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	return &PolicyWatcher{
+		client:   client,
+		closer:   client.Close,
+		policy:   policy,
+		flusher:  flusher,
+		interval: interval,
+	}, nil
+}
+
+// Start begins polling from the current head. Blocks until context is
+// cancelled. Poll errors are logged and retried on the next tick without
+// skipping blocks.
+func (p *PolicyWatcher) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	log.Printf("[revocation] Polling %s for AccessPolicy changes every %s", p.policy.Hex(), p.interval)
+
+	for {
+		err := p.poll(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[revocation] Policy poll error, retrying in %s: %v", p.interval, err)
+		}
+		p.mu.Lock()
+		p.lastErr = err
+		p.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Health returns the error from the most recent poll, or nil if it succeeded.
+func (p *PolicyWatcher) Health() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastErr
+}
+
+// Stop cancels the watcher.
+func (p *PolicyWatcher) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	if p.closer != nil {
+		p.closer()
+	}
+}
+
+// poll flushes the cache once if any policy event landed in blocks after
+// lastBlock up to the current head. No confirmation depth is needed: a
+// flush undone by a reorg only costs cache misses.
+func (p *PolicyWatcher) poll(ctx context.Context) error {
+	head, err := p.client.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("fetching block number: %w", err)
+	}
+
+	var changes []string
+	err = pollLogs(ctx, p.client, policyFilter(p.policy), &p.lastBlock, head, func(vLog types.Log) {
+		if len(vLog.Topics) == 0 {
+			return
+		}
+		name, ok := policyEventNames[vLog.Topics[0]]
+		if !ok {
+			return
+		}
+		id := new(big.Int)
+		if len(vLog.Data) >= 32 {
+			id.SetBytes(vLog.Data[:32])
+		}
+		changes = append(changes, fmt.Sprintf("%s(%s)", name, id))
+	})
+	// Flush for what was seen even if a later range failed.
+	if len(changes) > 0 {
+		p.flusher.InvalidateAll()
+		log.Printf("[revocation] AccessPolicy changed (%v); flushed access cache", changes)
+	}
+	return err
+}
+
+// policyFilter matches AccessPolicy governance logs on the contract.
+func policyFilter(contract common.Address) ethereum.FilterQuery {
+	return ethereum.FilterQuery{
+		Addresses: []common.Address{contract},
+		Topics: [][]common.Hash{
+			{thisCardTokenIDSetSig, knownTokenIDAddedSig, knownTokenIDRemovedSig},
+		},
+	}
+}
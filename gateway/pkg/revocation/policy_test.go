@@ -0,0 +1,56 @@
+package revocation
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type countingFlusher struct{ flushes int }
+
+func (f *countingFlusher) InvalidateAll() { f.flushes++ }
+
+func policyLog(block uint64, sig common.Hash, tokenID int64) types.Log {
+	return types.Log{
+		BlockNumber: block,
+		Topics:      []common.Hash{sig},
+		Data:        common.BigToHash(big.NewInt(tokenID)).Bytes(),
+	}
+}
+
+func TestPolicyWatcherFlushesOncePerPollWithChanges(t *testing.T) {
+	src := &fakeLogSource{head: 100}
+	flusher := &countingFlusher{}
+	p := &PolicyWatcher{client: src, flusher: flusher}
+
+	// First poll only records the head.
+	if err := p.poll(context.Background()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+
+	src.head = 110
+	src.logs = []types.Log{
+		policyLog(104, knownTokenIDAddedSig, 12),
+		policyLog(107, knownTokenIDRemovedSig, 3),
+	}
+	if err := p.poll(context.Background()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if flusher.flushes != 1 {
+		t.Fatalf("flushes = %d after two policy events in one poll, want 1", flusher.flushes)
+	}
+
+	src.head = 120
+	if err := p.poll(context.Background()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if flusher.flushes != 1 {
+		t.Errorf("flushes = %d after a poll with no events, want still 1", flusher.flushes)
+	}
+	if got := src.ranges[len(src.ranges)-1]; got != [2]uint64{111, 120} {
+		t.Errorf("last range = %v, want [111 120]", got)
+	}
+}
@@ -0,0 +1,169 @@
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
+)
+
+const (
+	// DefaultPollInterval is how often a PollingWatcher checks for new blocks.
+	DefaultPollInterval = 15 * time.Second
+
+	// maxPollBlockRange caps the block span of a single eth_getLogs call;
+	// many hosted providers reject larger ranges.
+	maxPollBlockRange = 2000
+)
+
+// logSource is the subset of ethclient.Client used by PollingWatcher.
+type logSource interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// PollingWatcher detects ERC-1155 transfers by periodically calling
+// eth_getLogs over HTTP, for RPC plans without WebSocket subscriptions.
+// Revocation lags by up to one poll interval.
+type PollingWatcher struct {
+	client        logSource
+	closer        func()
+	memesContract common.Address
+	revoker       SessionRevoker
+	interval      time.Duration
+	confirmations uint64
+	lastBlock     uint64
+	cancel        context.CancelFunc
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+// NewPollingWatcher creates a transfer watcher that polls rpcURL for new
+// transfer logs every interval (DefaultPollInterval if zero).
+func NewPollingWatcher(rpcURL string, memesContract common.Address, revoker SessionRevoker, interval time.Duration) (*PollingWatcher, error) {
+	client, err := outbound.Dial(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	return &PollingWatcher{
+		client:        client,
+		closer:        client.Close,
+		memesContract: memesContract,
+		revoker:       revoker,
+		interval:      interval,
+	}, nil
+}
+
+// SetConfirmations makes each poll stop n blocks short of head, so only
+// transfers at least n blocks deep are acted on and a shallow reorg cannot
+// revoke a wallet whose transfer was rolled back. Call before Start.
+func (p *PollingWatcher) SetConfirmations(n uint64) {
+	p.confirmations = n
+}
+
+// Start begins polling for transfer events from the current head. Blocks
+// until context is cancelled. Poll errors are logged and retried on the next
+// tick without skipping blocks.
+func (p *PollingWatcher) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	log.Printf("[revocation] Polling %s for ERC-1155 transfers every %s", p.memesContract.Hex(), p.interval)
+
+	for {
+		err := p.poll(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[revocation] Poll error, retrying in %s: %v", p.interval, err)
+		}
+		p.mu.Lock()
+		p.lastErr = err
+		p.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Health returns the error from the most recent poll, or nil if it succeeded.
+func (p *PollingWatcher) Health() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastErr
+}
+
+// Stop cancels the watcher.
+func (p *PollingWatcher) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	if p.closer != nil {
+		p.closer()
+	}
+}
+
+// poll processes transfer logs in blocks after lastBlock up to the current
+// head less the confirmation depth. The first call only records that block
+// so history is not replayed.
+func (p *PollingWatcher) poll(ctx context.Context) error {
+	head, err := p.client.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("fetching block number: %w", err)
+	}
+	if head <= p.confirmations {
+		return nil
+	}
+	head -= p.confirmations
+
+	return pollLogs(ctx, p.client, transferFilter(p.memesContract), &p.lastBlock, head, func(vLog types.Log) {
+		handleTransferLog(p.revoker, vLog)
+	})
+}
+
+// pollLogs passes each log matching query in blocks after *last up to head
+// to handle, in ranges of at most maxPollBlockRange, and advances *last as
+// it goes. When *last is 0 it only records head, so history is not replayed.
+func pollLogs(ctx context.Context, client logSource, query ethereum.FilterQuery, last *uint64, head uint64, handle func(types.Log)) error {
+	if *last == 0 {
+		*last = head
+		return nil
+	}
+
+	for *last < head {
+		from := *last + 1
+		to := min(head, from+maxPollBlockRange-1)
+
+		query.FromBlock = new(big.Int).SetUint64(from)
+		query.ToBlock = new(big.Int).SetUint64(to)
+
+		logs, err := client.FilterLogs(ctx, query)
+		if err != nil {
+			return fmt.Errorf("filtering logs %d-%d: %w", from, to, err)
+		}
+		for _, vLog := range logs {
+			if vLog.Removed {
+				continue
+			}
+			handle(vLog)
+		}
+		*last = to
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// fakeLogSource serves a fixed head and records FilterLogs ranges.
+type fakeLogSource struct {
+	head    uint64
+	logs    []types.Log
+	ranges  [][2]uint64
+	failLog bool
+}
+
+func (f *fakeLogSource) BlockNumber(ctx context.Context) (uint64, error) {
+	return f.head, nil
+}
+
+func (f *fakeLogSource) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	if f.failLog {
+		return nil, errors.New("rpc down")
+	}
+	from, to := q.FromBlock.Uint64(), q.ToBlock.Uint64()
+	f.ranges = append(f.ranges, [2]uint64{from, to})
+
+	var out []types.Log
+	for _, l := range f.logs {
+		if l.BlockNumber >= from && l.BlockNumber <= to {
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
+
+func transferLog(block uint64, from, to common.Address) types.Log {
+	return types.Log{
+		BlockNumber: block,
+		Topics: []common.Hash{
+			transferSingleSig,
+			{},
+			common.BytesToHash(common.LeftPadBytes(from.Bytes(), 32)),
+			common.BytesToHash(common.LeftPadBytes(to.Bytes(), 32)),
+		},
+		Data: make([]byte, 64),
+	}
+}
+
+func TestPollingWatcherProcessesNewBlocks(t *testing.T) {
+	from := common.HexToAddress("0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	to := common.HexToAddress("0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+
+	src := &fakeLogSource{head: 100}
+	revoker := &mockRevoker{}
+	p := &PollingWatcher{client: src, revoker: revoker}
+
+	// First poll records the head without replaying history.
+	if err := p.poll(context.Background()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if len(src.ranges) != 0 {
+		t.Fatalf("expected no log queries on first poll, got %v", src.ranges)
+	}
+
+	src.head = 105
+	src.logs = []types.Log{transferLog(103, from, to)}
+	if err := p.poll(context.Background()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+
+	if len(src.ranges) != 1 || src.ranges[0] != [2]uint64{101, 105} {
+		t.Fatalf("unexpected ranges: %v", src.ranges)
+	}
+	if len(revoker.revoked) != 1 || revoker.revoked[0] != from {
+		t.Errorf("expected sender revoked, got %v", revoker.revoked)
+	}
+	if p.lastBlock != 105 {
+		t.Errorf("lastBlock = %d, want 105", p.lastBlock)
+	}
+}
+
+func TestPollingWatcherChunksLargeRanges(t *testing.T) {
+	src := &fakeLogSource{head: 1 + maxPollBlockRange*2 + 10}
+	p := &PollingWatcher{client: src, revoker: &mockRevoker{}, lastBlock: 1}
+
+	if err := p.poll(context.Background()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if len(src.ranges) != 3 {
+		t.Fatalf("expected 3 chunks, got %v", src.ranges)
+	}
+	if p.lastBlock != src.head {
+		t.Errorf("lastBlock = %d, want %d", p.lastBlock, src.head)
+	}
+}
+
+func TestPollingWatcherRetriesAfterError(t *testing.T) {
+	src := &fakeLogSource{head: 50, failLog: true}
+	p := &PollingWatcher{client: src, revoker: &mockRevoker{}, lastBlock: 40}
+
+	if err := p.poll(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if p.lastBlock != 40 {
+		t.Errorf("lastBlock advanced to %d after failed poll", p.lastBlock)
+	}
+
+	src.failLog = false
+	if err := p.poll(context.Background()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if len(src.ranges) != 1 || src.ranges[0] != [2]uint64{41, 50} {
+		t.Fatalf("unexpected ranges: %v", src.ranges)
+	}
+}
+
+func TestPollingWatcherWaitsForConfirmations(t *testing.T) {
+	from := common.HexToAddress("0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	src := &fakeLogSource{head: 100, logs: []types.Log{transferLog(103, from, common.Address{})}}
+	revoker := &mockRevoker{}
+	p := &PollingWatcher{client: src, revoker: revoker, lastBlock: 100}
+	p.SetConfirmations(5)
+
+	// Block 103 is only 2 deep at head 105.
+	src.head = 105
+	if err := p.poll(context.Background()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if len(src.ranges) != 0 || len(revoker.revoked) != 0 {
+		t.Fatalf("acted before confirmation: ranges %v, revoked %v", src.ranges, revoker.revoked)
+	}
+
+	src.head = 108
+	if err := p.poll(context.Background()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if len(src.ranges) != 1 || src.ranges[0] != [2]uint64{101, 103} {
+		t.Fatalf("unexpected ranges: %v", src.ranges)
+	}
+	if len(revoker.revoked) != 1 {
+		t.Errorf("expected sender revoked once confirmed, got %v", revoker.revoked)
+	}
+}
@@ -0,0 +1,82 @@
+package revocation
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/connlog"
+)
+
+// pendingTransfers holds transfer logs until they are a confirmation depth
+// below head, so a transfer that a reorg rolls back is dropped instead of
+// revoking a wallet that still holds its card.
+type pendingTransfers struct {
+	depth uint64
+	logs  []types.Log
+}
+
+// add queues vLog. A removed log cancels its queued original instead; the
+// return value reports whether one was found. Only the watcher goroutine
+// touches the queue.
+func (p *pendingTransfers) add(vLog types.Log) (cancelled bool) {
+	if !vLog.Removed {
+		p.logs = append(p.logs, vLog)
+		return false
+	}
+	for i, queued := range p.logs {
+		if sameLog(queued, vLog) {
+			p.logs = append(p.logs[:i], p.logs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// confirmed removes and returns the queued logs at least depth blocks
+// below head.
+func (p *pendingTransfers) confirmed(head uint64) []types.Log {
+	var ready []types.Log
+	kept := p.logs[:0]
+	for _, vLog := range p.logs {
+		if vLog.BlockNumber+p.depth <= head {
+			ready = append(ready, vLog)
+		} else {
+			kept = append(kept, vLog)
+		}
+	}
+	p.logs = kept
+	return ready
+}
+
+func sameLog(a, b types.Log) bool {
+	return a.BlockHash == b.BlockHash && a.TxHash == b.TxHash && a.Index == b.Index
+}
+
+// applyTransferLog acts on a transfer log, or on its removal by a reorg.
+func applyTransferLog(revoker SessionRevoker, vLog types.Log) {
+	if vLog.Removed {
+		handleRemovedTransferLog(revoker, vLog)
+		return
+	}
+	handleTransferLog(revoker, vLog)
+}
+
+// handleRemovedTransferLog handles a transfer that was already acted on and
+// then rolled back. The revoked session cannot be handed back, but both
+// wallets' cached tiers are dropped so the sender's next sign-in is checked
+// against the post-reorg chain and succeeds if it still holds the card.
+func handleRemovedTransferLog(revoker SessionRevoker, vLog types.Log) {
+	if len(vLog.Topics) < 4 {
+		return
+	}
+	from := common.BytesToAddress(vLog.Topics[2].Bytes())
+	to := common.BytesToAddress(vLog.Topics[3].Bytes())
+
+	connlog.Printf("[revocation] Transfer in block %d removed by reorg; re-checking both wallets on next sign-in", vLog.BlockNumber)
+	if from != (common.Address{}) {
+		revoker.InvalidateOnly(from)
+	}
+	if to != (common.Address{}) {
+		revoker.InvalidateOnly(to)
+	}
+}
@@ -0,0 +1,269 @@
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ethrpc"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
+)
+
+// SubscriptionManager event signatures (keccak256).
+var (
+	// Subscribed(address indexed user, address indexed node, uint8 indexed tier, uint256 payment, uint256 expiresAt)
+	subscribedSig = common.HexToHash("0x0d1c0b9f5e3db00981dbc93c3e82c73616ade71e4394b91e83f00c6144d7b9ba")
+	// Renewed(address indexed user, address indexed node, uint8 indexed tier, uint256 payment, uint256 expiresAt)
+	renewedSig = common.HexToHash("0x7c78bfa47f7511ed5c4537cfb40862f9122a2b92ce0cd2be7098e597d8583f4f")
+	// TierUpdated(uint8 indexed tierId, uint256 price, uint256 duration, bool active)
+	tierUpdatedSig = common.HexToHash("0x244b1940e94f523810f2cc7fb321793fd9f7d918b51fee60791a8f6133ddf1fa")
+)
+
+// SubscriptionCache drops cached SubscriptionManager reads. Implemented by
+// subscriptionmgr.Manager.
+type SubscriptionCache interface {
+	Invalidate(user common.Address)
+	InvalidateTiers()
+}
+
+// SubscriptionWatcher polls the SubscriptionManager contract and drops a
+// user's cached subscription reads when they subscribe or renew, and the
+// cached tier list when a tier changes, so new subscribers get access at
+// once instead of after the cache TTL.
+type SubscriptionWatcher struct {
+	client    logSource
+	closer    func()
+	contract  common.Address
+	cache     SubscriptionCache
+	interval  time.Duration
+	lastBlock uint64
+	cancel    context.CancelFunc
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+// NewSubscriptionWatcher creates a watcher that polls rpcURL for
+// SubscriptionManager events every interval (DefaultPollInterval if zero).
+func NewSubscriptionWatcher(rpcURL string, contract common.Address, cache SubscriptionCache, interval time.Duration) (*SubscriptionWatcher, error) {
+	client, err := outbound.Dial(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	return &SubscriptionWatcher{
+		client:   client,
+		closer:   client.Close,
+		contract: contract,
+		cache:    cache,
+		interval: interval,
+	}, nil
+}
+
+// Start begins polling from the current head. Blocks until context is
+// cancelled. Poll errors are logged and retried on the next tick without
+// skipping blocks.
+func (w *SubscriptionWatcher) Start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	log.Printf("[revocation] Polling %s for subscription events every %s", w.contract.Hex(), w.interval)
+
+	for {
+		err := w.poll(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[revocation] Subscription poll error, retrying in %s: %v", w.interval, err)
+		}
+		w.mu.Lock()
+		w.lastErr = err
+		w.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Health returns the error from the most recent poll, or nil if it succeeded.
+func (w *SubscriptionWatcher) Health() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastErr
+}
+
+// Stop cancels the watcher.
+func (w *SubscriptionWatcher) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	if w.closer != nil {
+		w.closer()
+	}
+}
+
+// poll invalidates the cache for every subscription event in blocks after
+// lastBlock up to the current head. As with policy changes no confirmation
+// depth is needed: an invalidation undone by a reorg only costs a re-read.
+func (w *SubscriptionWatcher) poll(ctx context.Context) error {
+	head, err := w.client.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("fetching block number: %w", err)
+	}
+
+	return pollLogs(ctx, w.client, subscriptionFilter(w.contract), &w.lastBlock, head, func(vLog types.Log) {
+		handleSubscriptionLog(w.cache, vLog)
+	})
+}
+
+// handleSubscriptionLog drops the cached reads a SubscriptionManager event
+// affects. A log removed by a reorg is treated the same way: the re-read
+// then finds the subscription gone. Shared by the polling and streaming
+// watchers.
+func handleSubscriptionLog(cache SubscriptionCache, vLog types.Log) {
+	if len(vLog.Topics) == 0 {
+		return
+	}
+	switch vLog.Topics[0] {
+	case subscribedSig, renewedSig:
+		if len(vLog.Topics) < 2 {
+			return
+		}
+		cache.Invalidate(common.BytesToAddress(vLog.Topics[1].Bytes()))
+	case tierUpdatedSig:
+		cache.InvalidateTiers()
+		log.Printf("[revocation] Subscription tier updated at block %d; flushed tier cache", vLog.BlockNumber)
+	}
+}
+
+// SubscriptionStreamWatcher is SubscriptionWatcher over a WebSocket log
+// subscription, so cached reads are dropped as soon as an event arrives
+// rather than on the next poll.
+type SubscriptionStreamWatcher struct {
+	client   ethrpc.Subscriber
+	contract common.Address
+	cache    SubscriptionCache
+	cancel   context.CancelFunc
+
+	mu         sync.Mutex
+	subscribed bool
+	lastErr    error
+}
+
+// NewSubscriptionStreamWatcher creates a streaming SubscriptionManager
+// watcher. Like NewWatcher it needs a WebSocket endpoint and fails fast with
+// ErrSubscriptionsUnsupported otherwise.
+func NewSubscriptionStreamWatcher(wsURL string, contract common.Address, cache SubscriptionCache) (*SubscriptionStreamWatcher, error) {
+	if err := checkSubscriptionScheme(wsURL); err != nil {
+		return nil, err
+	}
+
+	client, err := outbound.Dial(wsURL)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewSubscriptionStreamWatcherWithClient(client, contract, cache)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// NewSubscriptionStreamWatcherWithClient creates a streaming watcher on an
+// existing subscription-capable client, e.g. an ethrpc.Fake in tests. It
+// makes a trial subscription but leaves closing the client on error to the
+// caller; Stop closes it.
+func NewSubscriptionStreamWatcherWithClient(client ethrpc.Subscriber, contract common.Address, cache SubscriptionCache) (*SubscriptionStreamWatcher, error) {
+	w := &SubscriptionStreamWatcher{
+		client:   client,
+		contract: contract,
+		cache:    cache,
+	}
+	if err := probeSubscription(client, subscriptionFilter(contract)); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Start begins watching for subscription events. Blocks until context is
+// cancelled, reconnecting with backoff as Watcher does.
+func (w *SubscriptionStreamWatcher) Start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+	resubscribe(ctx, w.subscribe, w.setState)
+}
+
+// Health returns nil while the log subscription is active, or the error that
+// most recently dropped it.
+func (w *SubscriptionStreamWatcher) Health() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.subscribed {
+		return nil
+	}
+	if w.lastErr != nil {
+		return fmt.Errorf("subscription down: %w", w.lastErr)
+	}
+	return fmt.Errorf("not subscribed")
+}
+
+func (w *SubscriptionStreamWatcher) setState(subscribed bool, err error) {
+	w.mu.Lock()
+	w.subscribed = subscribed
+	w.lastErr = err
+	w.mu.Unlock()
+}
+
+// Stop cancels the watcher.
+func (w *SubscriptionStreamWatcher) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.client.Close()
+}
+
+// subscribe streams subscription logs until the subscription fails or ctx
+// ends. subscribed reports whether the subscription was established first.
+func (w *SubscriptionStreamWatcher) subscribe(ctx context.Context) (subscribed bool, err error) {
+	logs := make(chan types.Log)
+	sub, err := w.client.SubscribeFilterLogs(ctx, subscriptionFilter(w.contract), logs)
+	if err != nil {
+		return false, err
+	}
+	defer sub.Unsubscribe()
+
+	w.setState(true, nil)
+	log.Printf("[revocation] Watching %s for subscription events", w.contract.Hex())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case err := <-sub.Err():
+			return true, err
+		case vLog := <-logs:
+			handleSubscriptionLog(w.cache, vLog)
+		}
+	}
+}
+
+// subscriptionFilter matches the SubscriptionManager events that change
+// what the gateway reads.
+func subscriptionFilter(contract common.Address) ethereum.FilterQuery {
+	return ethereum.FilterQuery{
+		Addresses: []common.Address{contract},
+		Topics:    [][]common.Hash{{subscribedSig, renewedSig, tierUpdatedSig}},
+	}
+}
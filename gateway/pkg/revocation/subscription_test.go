@@ -0,0 +1,94 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ethrpc"
+)
+
+type recordingSubCache struct {
+	users []common.Address
+	tiers int
+}
+
+func (c *recordingSubCache) Invalidate(user common.Address) { c.users = append(c.users, user) }
+func (c *recordingSubCache) InvalidateTiers()               { c.tiers++ }
+
+func TestSubscriptionWatcherInvalidatesSubscribers(t *testing.T) {
+	src := &fakeLogSource{head: 100}
+	cache := &recordingSubCache{}
+	w := &SubscriptionWatcher{client: src, cache: cache}
+
+	if err := w.poll(context.Background()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+
+	alice := common.HexToAddress("0xa11ce")
+	bob := common.HexToAddress("0xb0b")
+	userLog := func(block uint64, sig common.Hash, user common.Address) types.Log {
+		return types.Log{BlockNumber: block, Topics: []common.Hash{sig, common.BytesToHash(user.Bytes()), {}, {}}}
+	}
+	src.head = 110
+	src.logs = []types.Log{
+		userLog(103, subscribedSig, alice),
+		userLog(105, renewedSig, bob),
+		{BlockNumber: 108, Topics: []common.Hash{tierUpdatedSig, {}}},
+	}
+	if err := w.poll(context.Background()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if len(cache.users) != 2 || cache.users[0] != alice || cache.users[1] != bob {
+		t.Errorf("invalidated %v, want [%s %s]", cache.users, alice.Hex(), bob.Hex())
+	}
+	if cache.tiers != 1 {
+		t.Errorf("tier invalidations = %d, want 1", cache.tiers)
+	}
+}
+
+// subInvalidations reports each Invalidate; InvalidateTiers is ignored.
+type subInvalidations chan common.Address
+
+func (c subInvalidations) Invalidate(user common.Address) { c <- user }
+func (c subInvalidations) InvalidateTiers()               {}
+
+func TestSubscriptionStreamWatcherInvalidatesOnEvent(t *testing.T) {
+	contract := common.HexToAddress("0x5b5c0ab0a4ab0e0c6eaa5a1dbb8e4bd9c2f4f6f2")
+	client := ethrpc.NewFake()
+	invalidated := make(subInvalidations, 1)
+	w, err := NewSubscriptionStreamWatcherWithClient(client, contract, invalidated)
+	if err != nil {
+		t.Fatalf("NewSubscriptionStreamWatcherWithClient: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Start(ctx)
+	defer w.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for w.Health() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("watcher never subscribed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	alice := common.HexToAddress("0xa11ce")
+	client.EmitLog(types.Log{
+		Address:     contract,
+		BlockNumber: 100,
+		Topics:      []common.Hash{subscribedSig, common.BytesToHash(alice.Bytes()), {}, {}},
+	})
+	select {
+	case user := <-invalidated:
+		if user != alice {
+			t.Errorf("invalidated %s, want %s", user.Hex(), alice.Hex())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Subscribed event did not invalidate the subscriber")
+	}
+}
@@ -2,26 +2,54 @@
 // VPN sessions when NFTs are transferred away from authenticated wallets.
 //
 // Subscribes to TransferSingle and TransferBatch events on the Memes contract
-// via WebSocket. When a transfer is detected, it:
+// via WebSocket, or polls for them over HTTP with a PollingWatcher when no
+// WebSocket endpoint is available. When a transfer is detected, it:
 //  1. Invalidates the NFT check cache for the sender
 //  2. Revokes the sender's VPN session
 //  3. Removes their WireGuard peer
+//
+// A PolicyWatcher likewise polls the AccessPolicy contract and flushes the
+// access cache when governance changes which cards qualify, and a
+// SubscriptionWatcher or SubscriptionStreamWatcher drops cached
+// SubscriptionManager reads when a wallet subscribes or renews.
 package revocation
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"math/big"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/connlog"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ethrpc"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
+)
+
+const (
+	// probeTimeout bounds the trial subscription made by NewWatcher.
+	probeTimeout = 10 * time.Second
+
+	// Reconnect backoff after a subscription error. Doubles on each
+	// consecutive failure and resets once a subscription is established.
+	minReconnectDelay = 10 * time.Second
+	maxReconnectDelay = 5 * time.Minute
 )
 
+// ErrSubscriptionsUnsupported is returned when the configured endpoint cannot
+// push log subscriptions, typically because it is an HTTP(S) URL.
+var ErrSubscriptionsUnsupported = errors.New("endpoint does not support log subscriptions; the revocation watcher needs a WebSocket endpoint (ws:// or wss://)")
+
 // ERC-1155 event signatures (keccak256)
 var (
 	// TransferSingle(address indexed operator, address indexed from, address indexed to, uint256 id, uint256 value)
@@ -38,13 +66,25 @@ type SessionRevoker interface {
 	InvalidateOnly(wallet common.Address)
 }
 
+// TransferInRecorder is optionally implemented by a SessionRevoker that
+// wants to know which wallets just received a card, e.g. to bridge the gap
+// before access checks see it. tokenIDs are the cards received.
+type TransferInRecorder interface {
+	RecordTransferIn(wallet common.Address, tokenIDs []*big.Int)
+}
+
 // Watcher monitors ERC-1155 transfer events for real-time session revocation.
 type Watcher struct {
-	client        *ethclient.Client
+	client        ethrpc.Subscriber
 	memesContract common.Address
 	revoker       SessionRevoker
 	erc1155ABI    abi.ABI
 	cancel        context.CancelFunc
+	pending       pendingTransfers // transfers waiting for confirmations
+
+	mu         sync.Mutex
+	subscribed bool
+	lastErr    error
 }
 
 const erc1155EventABI = `[{
@@ -72,46 +112,170 @@ const erc1155EventABI = `[{
 }]`
 
 // NewWatcher creates a transfer event watcher.
-// The wsURL should be a WebSocket Ethereum RPC endpoint (wss://).
+// The wsURL should be a WebSocket Ethereum RPC endpoint (wss://). An HTTP(S)
+// URL, or an endpoint that rejects a trial subscription, fails fast with
+// ErrSubscriptionsUnsupported instead of looping on reconnects.
 func NewWatcher(wsURL string, memesContract common.Address, revoker SessionRevoker) (*Watcher, error) {
-	client, err := ethclient.Dial(wsURL)
+	if err := checkSubscriptionScheme(wsURL); err != nil {
+		return nil, err
+	}
+
+	client, err := outbound.Dial(wsURL)
 	if err != nil {
 		return nil, err
 	}
+	w, err := NewWatcherWithClient(client, memesContract, revoker)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return w, nil
+}
 
+// NewWatcherWithClient creates a transfer event watcher on an existing
+// subscription-capable client, e.g. an ethrpc.Fake in tests. It makes the
+// same trial subscription as NewWatcher but leaves closing the client on
+// error to the caller; Stop closes it.
+func NewWatcherWithClient(client ethrpc.Subscriber, memesContract common.Address, revoker SessionRevoker) (*Watcher, error) {
 	parsedABI, err := abi.JSON(strings.NewReader(erc1155EventABI))
 	if err != nil {
 		return nil, err
 	}
 
-	return &Watcher{
+	w := &Watcher{
 		client:        client,
 		memesContract: memesContract,
 		revoker:       revoker,
 		erc1155ABI:    parsedABI,
-	}, nil
+	}
+
+	if err := w.probe(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// SetConfirmations delays acting on a transfer until it is n blocks deep,
+// so a transfer dropped by a reorg shallower than n never revokes anyone.
+// 0 (the default) acts on arrival. Call before Start.
+func (w *Watcher) SetConfirmations(n uint64) {
+	w.pending.depth = n
+}
+
+// SupportsSubscriptions reports whether the endpoint URL can carry log
+// subscriptions (ws://, wss:// or an IPC path). Use a PollingWatcher otherwise.
+func SupportsSubscriptions(endpoint string) bool {
+	return checkSubscriptionScheme(endpoint) == nil
+}
+
+// checkSubscriptionScheme rejects endpoint URLs that cannot carry subscriptions.
+// IPC paths (no scheme) are allowed.
+func checkSubscriptionScheme(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint URL: %w", err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "ws", "wss", "":
+		return nil
+	case "http", "https":
+		return fmt.Errorf("%w: got %s:// URL", ErrSubscriptionsUnsupported, u.Scheme)
+	default:
+		return fmt.Errorf("unsupported endpoint scheme %q: the revocation watcher needs ws:// or wss://", u.Scheme)
+	}
+}
+
+// probe makes a trial log subscription so a provider without subscription
+// support is reported at startup rather than in the reconnect loop.
+func (w *Watcher) probe() error {
+	return probeSubscription(w.client, w.filterQuery())
+}
+
+func probeSubscription(client ethrpc.Subscriber, query ethereum.FilterQuery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	logs := make(chan types.Log)
+	sub, err := client.SubscribeFilterLogs(ctx, query, logs)
+	if err != nil {
+		if errors.Is(err, rpc.ErrNotificationsUnsupported) {
+			return ErrSubscriptionsUnsupported
+		}
+		return fmt.Errorf("trial subscription failed: %w", err)
+	}
+	sub.Unsubscribe()
+	return nil
 }
 
 // Start begins watching for transfer events. Blocks until context is cancelled.
-// Automatically reconnects on errors.
+// Automatically reconnects on errors with exponential backoff.
 func (w *Watcher) Start(ctx context.Context) {
 	ctx, w.cancel = context.WithCancel(ctx)
+	resubscribe(ctx, w.subscribe, w.setState)
+}
 
+// resubscribe runs subscribe until ctx ends, reconnecting after each error
+// with exponential backoff. setState records each drop.
+func resubscribe(ctx context.Context, subscribe func(context.Context) (bool, error), setState func(bool, error)) {
+	delay := minReconnectDelay
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			err := w.subscribe(ctx)
-			if err != nil {
-				if ctx.Err() != nil {
-					return // context cancelled
-				}
-				log.Printf("[revocation] Subscription error, reconnecting in 10s: %v", err)
-				time.Sleep(10 * time.Second)
-			}
 		}
+
+		subscribed, err := subscribe(ctx)
+		if ctx.Err() != nil {
+			return // context cancelled
+		}
+		setState(false, err)
+		if errors.Is(err, rpc.ErrNotificationsUnsupported) {
+			log.Printf("[revocation] %v; stopping watcher", ErrSubscriptionsUnsupported)
+			return
+		}
+		if subscribed {
+			delay = minReconnectDelay
+		}
+
+		log.Printf("[revocation] Subscription error, reconnecting in %s: %v", delay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = nextReconnectDelay(delay)
+	}
+}
+
+func nextReconnectDelay(d time.Duration) time.Duration {
+	d *= 2
+	if d > maxReconnectDelay {
+		d = maxReconnectDelay
 	}
+	return d
+}
+
+// Health returns nil while the log subscription is active, or the error that
+// most recently dropped it.
+func (w *Watcher) Health() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.subscribed {
+		return nil
+	}
+	if w.lastErr != nil {
+		return fmt.Errorf("subscription down: %w", w.lastErr)
+	}
+	return fmt.Errorf("not subscribed")
+}
+
+func (w *Watcher) setState(subscribed bool, err error) {
+	w.mu.Lock()
+	w.subscribed = subscribed
+	w.lastErr = err
+	w.mu.Unlock()
 }
 
 // Stop cancels the watcher.
@@ -122,36 +286,92 @@ func (w *Watcher) Stop() {
 	w.client.Close()
 }
 
-func (w *Watcher) subscribe(ctx context.Context) error {
-	query := ethereum.FilterQuery{
-		Addresses: []common.Address{w.memesContract},
+func (w *Watcher) filterQuery() ethereum.FilterQuery {
+	return transferFilter(w.memesContract)
+}
+
+// transferFilter matches ERC-1155 TransferSingle/TransferBatch logs on the contract.
+func transferFilter(contract common.Address) ethereum.FilterQuery {
+	return ethereum.FilterQuery{
+		Addresses: []common.Address{contract},
 		Topics: [][]common.Hash{
 			{transferSingleSig, transferBatchSig},
 		},
 	}
+}
 
+// subscribe streams transfer logs until the subscription fails or ctx ends.
+// subscribed reports whether the subscription was established before the error.
+func (w *Watcher) subscribe(ctx context.Context) (subscribed bool, err error) {
 	logs := make(chan types.Log)
-	sub, err := w.client.SubscribeFilterLogs(ctx, query, logs)
+	sub, err := w.client.SubscribeFilterLogs(ctx, w.filterQuery(), logs)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer sub.Unsubscribe()
 
-	log.Printf("[revocation] Watching %s for ERC-1155 transfers", w.memesContract.Hex())
+	// New heads release queued transfers once they are deep enough. With no
+	// confirmation depth the channel stays nil and never fires.
+	var heads chan *types.Header
+	var headErr <-chan error
+	if w.pending.depth > 0 {
+		heads = make(chan *types.Header)
+		headSub, err := w.client.SubscribeNewHead(ctx, heads)
+		if err != nil {
+			return false, fmt.Errorf("subscribing to new heads: %w", err)
+		}
+		defer headSub.Unsubscribe()
+		headErr = headSub.Err()
+	}
+
+	w.setState(true, nil)
+	log.Printf("[revocation] Watching %s for ERC-1155 transfers (confirmations=%d)", w.memesContract.Hex(), w.pending.depth)
 
 	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return true, ctx.Err()
 		case err := <-sub.Err():
-			return err
+			return true, err
+		case err := <-headErr:
+			return true, err
 		case vLog := <-logs:
 			w.handleLog(vLog)
+		case head := <-heads:
+			w.handleHead(head.Number.Uint64())
 		}
 	}
 }
 
+// handleLog acts on a transfer log straight away, or queues it until it has
+// enough confirmations. A removed log cancels its queued original; if the
+// original was already acted on, both wallets are re-checked.
 func (w *Watcher) handleLog(vLog types.Log) {
+	if w.pending.depth == 0 {
+		applyTransferLog(w.revoker, vLog)
+		return
+	}
+	if vLog.Removed {
+		if w.pending.add(vLog) {
+			connlog.Printf("[revocation] Transfer in block %d removed by reorg before confirmation; ignoring", vLog.BlockNumber)
+		} else {
+			handleRemovedTransferLog(w.revoker, vLog)
+		}
+		return
+	}
+	w.pending.add(vLog)
+}
+
+// handleHead acts on queued transfers that are now confirmed at head.
+func (w *Watcher) handleHead(head uint64) {
+	for _, vLog := range w.pending.confirmed(head) {
+		handleTransferLog(w.revoker, vLog)
+	}
+}
+
+// handleTransferLog applies a TransferSingle/TransferBatch log to the revoker.
+// Shared by the WebSocket and polling watchers.
+func handleTransferLog(revoker SessionRevoker, vLog types.Log) {
 	// ERC-1155 events have 4 topics: [sig, operator(indexed), from(indexed), to(indexed)]
 	if len(vLog.Topics) < 4 {
 		return
@@ -171,23 +391,58 @@ func (w *Watcher) handleLog(vLog types.Log) {
 		if len(vLog.Data) >= 32 {
 			id.SetBytes(vLog.Data[:32])
 		}
-		log.Printf("[revocation] TransferSingle detected: tokenId=%s", id.String())
+		connlog.Printf("[revocation] TransferSingle detected: tokenId=%s", id.String())
 
 	case transferBatchSig:
-		log.Printf("[revocation] TransferBatch detected")
+		connlog.Printf("[revocation] TransferBatch detected")
 	}
 
 	// Revoke the sender's session (they no longer hold the NFT)
 	if from != zeroAddr {
-		log.Printf("[revocation] Revoking sender session after transfer")
-		w.revoker.InvalidateAndRevoke(from)
+		connlog.Printf("[revocation] Revoking sender session after transfer")
+		revoker.InvalidateAndRevoke(from)
 	}
 
 	// Also invalidate cache for the receiver (they now have new NFTs,
 	// might upgrade tier)
 	if to != zeroAddr {
-		w.revoker.InvalidateOnly(to)
+		revoker.InvalidateOnly(to)
+		if r, ok := revoker.(TransferInRecorder); ok {
+			ids, err := transferTokenIDs(vLog)
+			if err != nil {
+				log.Printf("[revocation] Ignoring transfer-in with unreadable token IDs: %v", err)
+			} else {
+				r.RecordTransferIn(to, ids)
+			}
+		}
+	}
+}
+
+// transferTokenIDs decodes the token IDs moved by a TransferSingle or
+// TransferBatch log.
+func transferTokenIDs(vLog types.Log) ([]*big.Int, error) {
+	switch vLog.Topics[0] {
+	case transferSingleSig:
+		if len(vLog.Data) < 32 {
+			return nil, fmt.Errorf("TransferSingle data is %d bytes, want 64", len(vLog.Data))
+		}
+		return []*big.Int{new(big.Int).SetBytes(vLog.Data[:32])}, nil
+	case transferBatchSig:
+		uints, err := abi.NewType("uint256[]", "", nil)
+		if err != nil {
+			return nil, err
+		}
+		values, err := abi.Arguments{{Type: uints}, {Type: uints}}.Unpack(vLog.Data)
+		if err != nil {
+			return nil, fmt.Errorf("unpacking TransferBatch: %w", err)
+		}
+		ids, ok := values[0].([]*big.Int)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type for TransferBatch ids: %T", values[0])
+		}
+		return ids, nil
 	}
+	return nil, fmt.Errorf("not a transfer event: %s", vLog.Topics[0].Hex())
 }
 
 func truncAddr(addr common.Address) string {
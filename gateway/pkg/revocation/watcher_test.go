@@ -1,10 +1,17 @@
 package revocation
 
 import (
+	"context"
+	"errors"
+	"math/big"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ethrpc"
 )
 
 // mockRevoker records calls to invalidation/revocation hooks.
@@ -130,6 +137,30 @@ func TestHandleLogTransferBatch(t *testing.T) {
 	}
 }
 
+func TestTransferTokenIDs(t *testing.T) {
+	single := types.Log{
+		Topics: []common.Hash{transferSingleSig},
+		Data:   append(common.LeftPadBytes(big.NewInt(42).Bytes(), 32), make([]byte, 32)...),
+	}
+	ids, err := transferTokenIDs(single)
+	if err != nil || len(ids) != 1 || ids[0].Int64() != 42 {
+		t.Errorf("TransferSingle: got %v, %v; want [42]", ids, err)
+	}
+
+	uints, _ := abi.NewType("uint256[]", "", nil)
+	data, err := abi.Arguments{{Type: uints}, {Type: uints}}.Pack(
+		[]*big.Int{big.NewInt(3), big.NewInt(7)},
+		[]*big.Int{big.NewInt(1), big.NewInt(1)},
+	)
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	ids, err = transferTokenIDs(types.Log{Topics: []common.Hash{transferBatchSig}, Data: data})
+	if err != nil || len(ids) != 2 || ids[0].Int64() != 3 || ids[1].Int64() != 7 {
+		t.Errorf("TransferBatch: got %v, %v; want [3 7]", ids, err)
+	}
+}
+
 func TestHandleLogTooFewTopics(t *testing.T) {
 	revoker := &mockRevoker{}
 	w := &Watcher{revoker: revoker}
@@ -155,3 +186,142 @@ func TestTruncAddr(t *testing.T) {
 		t.Logf("truncated: %s", got)
 	}
 }
+
+func TestCheckSubscriptionScheme(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		wantErr  bool
+	}{
+		{"wss://mainnet.example.com/ws", false},
+		{"ws://localhost:8546", false},
+		{"/var/run/geth.ipc", false},
+		{"https://mainnet.example.com", true},
+		{"http://localhost:8545", true},
+		{"ftp://example.com", true},
+	}
+	for _, tt := range tests {
+		err := checkSubscriptionScheme(tt.endpoint)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkSubscriptionScheme(%q) error = %v, wantErr %v", tt.endpoint, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNewWatcherRejectsHTTPEndpoint(t *testing.T) {
+	_, err := NewWatcher("https://mainnet.example.com", common.Address{}, &mockRevoker{})
+	if !errors.Is(err, ErrSubscriptionsUnsupported) {
+		t.Fatalf("expected ErrSubscriptionsUnsupported, got %v", err)
+	}
+}
+
+func TestNextReconnectDelay(t *testing.T) {
+	if got := nextReconnectDelay(minReconnectDelay); got != 2*minReconnectDelay {
+		t.Errorf("expected doubled delay, got %s", got)
+	}
+	if got := nextReconnectDelay(maxReconnectDelay); got != maxReconnectDelay {
+		t.Errorf("expected delay capped at %s, got %s", maxReconnectDelay, got)
+	}
+}
+
+func TestWatcherConfirmationsDropReorgedTransfer(t *testing.T) {
+	revoker := &mockRevoker{}
+	w := &Watcher{revoker: revoker}
+	w.SetConfirmations(3)
+
+	from := common.HexToAddress("0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	to := common.HexToAddress("0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+	reorged := transferLog(100, from, to)
+	reorged.BlockHash = common.HexToHash("0x01")
+	kept := transferLog(101, to, from)
+	kept.BlockHash = common.HexToHash("0x02")
+
+	w.handleLog(reorged)
+	w.handleLog(kept)
+	w.handleHead(102)
+	if len(revoker.revoked)+len(revoker.invalidated) != 0 {
+		t.Fatalf("acted before confirmation: %+v", revoker)
+	}
+
+	removed := reorged
+	removed.Removed = true
+	w.handleLog(removed)
+
+	w.handleHead(104)
+	if len(revoker.revoked) != 1 || revoker.revoked[0] != to {
+		t.Fatalf("revoked %v, want only the sender of the surviving transfer", revoker.revoked)
+	}
+	if len(w.pending.logs) != 0 {
+		t.Errorf("%d logs still queued", len(w.pending.logs))
+	}
+}
+
+func TestWatcherRechecksWalletsWhenActedTransferIsRemoved(t *testing.T) {
+	revoker := &mockRevoker{}
+	w := &Watcher{revoker: revoker}
+
+	from := common.HexToAddress("0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	to := common.HexToAddress("0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+	vLog := transferLog(100, from, to)
+
+	w.handleLog(vLog)
+	vLog.Removed = true
+	w.handleLog(vLog)
+
+	// The removal must not revoke again; it only drops both cached tiers.
+	if len(revoker.revoked) != 1 {
+		t.Fatalf("expected 1 revocation, got %v", revoker.revoked)
+	}
+	if len(revoker.invalidated) != 4 || revoker.invalidated[2] != from || revoker.invalidated[3] != to {
+		t.Fatalf("unexpected invalidations: %v", revoker.invalidated)
+	}
+}
+
+// revokeSignal reports each wallet InvalidateAndRevoke is called for.
+type revokeSignal chan common.Address
+
+func (r revokeSignal) InvalidateAndRevoke(wallet common.Address) { r <- wallet }
+func (r revokeSignal) InvalidateOnly(common.Address)             {}
+
+func TestWatcherRevokesAfterConfirmations(t *testing.T) {
+	memes := common.HexToAddress("0x33FD426905F149f8376e227d0C9D3340AaD17aF1")
+	client := ethrpc.NewFake()
+	revoked := make(revokeSignal, 1)
+	w, err := NewWatcherWithClient(client, memes, revoked)
+	if err != nil {
+		t.Fatalf("NewWatcherWithClient: %v", err)
+	}
+	w.SetConfirmations(2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Start(ctx)
+	defer w.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for w.Health() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("watcher never subscribed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	from := common.HexToAddress("0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	vLog := transferLog(100, from, common.HexToAddress("0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"))
+	vLog.Address = memes
+	client.EmitLog(vLog)
+	client.SetBlockNumber(101)
+	select {
+	case wallet := <-revoked:
+		t.Fatalf("revoked %s after 1 confirmation", wallet.Hex())
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	client.SetBlockNumber(102)
+	select {
+	case wallet := <-revoked:
+		if wallet != from {
+			t.Errorf("revoked %s, want the sender", wallet.Hex())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("transfer was not acted on once confirmed")
+	}
+}
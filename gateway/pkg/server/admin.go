@@ -0,0 +1,79 @@
+package server
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/metrics"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/txtracker"
+)
+
+// SetAdminToken enables the /admin endpoints, authenticated with
+// "Authorization: Bearer <token>". Empty leaves them disabled.
+func (s *Server) SetAdminToken(token string) {
+	s.adminToken = token
+}
+
+// SetTxTracker exposes operator transaction status on GET /admin/txs.
+func (s *Server) SetTxTracker(t *txtracker.Tracker) {
+	s.txs = t
+}
+
+// requireAdmin writes an error response and returns false unless the request
+// carries the admin bearer token.
+func (s *Server) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.adminToken == "" {
+		writeFeatureDisabled(w, "admin endpoints not configured")
+		return false
+	}
+	token := bearerToken(r)
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+		writeError(w, http.StatusUnauthorized, "admin token required")
+		return false
+	}
+	return true
+}
+
+// GET /admin/txs -- status of on-chain transactions sent by this gateway
+// (session open/close, heartbeats), newest first.
+func (s *Server) handleAdminTxs(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	if s.txs == nil {
+		writeFeatureDisabled(w, "transaction tracking not enabled")
+		return
+	}
+
+	txs := s.txs.List()
+	counts := map[string]int{
+		txtracker.StatusPending:  0,
+		txtracker.StatusMined:    0,
+		txtracker.StatusReverted: 0,
+	}
+	for _, tx := range txs {
+		counts[tx.Status]++
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"counts":       counts,
+		"transactions": txs,
+	})
+}
+
+// GET /metrics -- NFT check, RPC and session metrics in the Prometheus text
+// format. Scrape it with the admin token as a bearer credential.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := metrics.Default.WriteTo(w); err != nil {
+		log.Printf("Error writing metrics: %v", err)
+		return
+	}
+	if _, err := s.metrics.WriteTo(w); err != nil {
+		log.Printf("Error writing metrics: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/audit"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/nftcheck"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/nftgate"
+)
+
+// SetAuditLogger records every wallet access decision -- sign-in, connect,
+// rekey and revocation -- to l.
+func (s *Server) SetAuditLogger(l *audit.Logger) {
+	s.audit = l
+}
+
+// auditVerify records a sign-in decision for wallet.
+func (s *Server) auditVerify(wallet common.Address, result nftcheck.CheckResult, outcome, reason string, probationary bool) {
+	rec := audit.Record{
+		Event:        audit.EventVerify,
+		Outcome:      outcome,
+		Address:      wallet.Hex(),
+		Tier:         result.Tier.String(),
+		Source:       result.Source,
+		Reason:       reason,
+		Probationary: probationary,
+	}
+	if result.Vault != (common.Address{}) {
+		rec.Vault = result.Vault.Hex()
+	}
+	// verify refuses a sign-in that did not accept the terms before any
+	// decision is recorded here.
+	if s.terms != nil {
+		rec.TermsURL, rec.TermsSHA256 = s.terms.URL, s.terms.SHA256
+	}
+	s.audit.Log(rec)
+}
+
+// auditConnect records the outcome of a connect for a wallet session:
+// grant on success, otherwise the error that refused it.
+func (s *Server) auditConnect(session *nftgate.Session, pubKey string, grant *peerGrant, err error) {
+	if !session.AddressBound {
+		return
+	}
+	rec := audit.Record{
+		Event:        audit.EventConnect,
+		Outcome:      audit.OutcomeGranted,
+		Address:      session.Address.Hex(),
+		Tier:         session.Tier.String(),
+		Probationary: session.Probationary,
+		PeerKey:      pubKey,
+	}
+	if err != nil {
+		rec.Outcome, rec.Reason = audit.OutcomeDenied, err.Error()
+	} else {
+		rec.Reason = grant.via
+	}
+	s.audit.Log(rec)
+}
+
+// auditRekey records that a wallet session moved its peer from oldKey to
+// newKey.
+func (s *Server) auditRekey(session *nftgate.Session, oldKey, newKey string) {
+	if !session.AddressBound {
+		return
+	}
+	s.audit.Log(audit.Record{
+		Event:        audit.EventRekey,
+		Outcome:      audit.OutcomeGranted,
+		Address:      session.Address.Hex(),
+		Tier:         session.Tier.String(),
+		Probationary: session.Probationary,
+		PeerKey:      newKey,
+		Reason:       "replaces " + oldKey,
+	})
+}
+
+// auditRevoke records that wallet lost access for reason.
+func (s *Server) auditRevoke(wallet common.Address, reason string) {
+	s.audit.Log(audit.Record{
+		Event:   audit.EventRevoke,
+		Outcome: audit.OutcomeRevoked,
+		Address: wallet.Hex(),
+		Reason:  reason,
+	})
+}
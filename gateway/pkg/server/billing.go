@@ -0,0 +1,188 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/sessionmgr"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/subscriptionmgr"
+)
+
+// BillingStatus is returned by GET /billing/status: everything a frontend
+// needs to show and extend a wallet's paid access in one call. Parts whose
+// manager is not configured are left out; parts that could not be read are
+// named in Unavailable.
+type BillingStatus struct {
+	Address              string `json:"address"`
+	SessionsEnabled      bool   `json:"sessions_enabled"`
+	SubscriptionsEnabled bool   `json:"subscriptions_enabled"`
+
+	Session      *BillingSession      `json:"session,omitempty"`      // active on-chain session, if any
+	Subscription *BillingSubscription `json:"subscription,omitempty"` // unexpired subscription, if any
+	// RemainingSeconds is the paid access left from whichever of the two
+	// lasts longer.
+	RemainingSeconds uint64 `json:"remaining_seconds"`
+
+	SessionPricing    *sessionmgr.SessionInfo    `json:"session_pricing,omitempty"`
+	SubscriptionTiers []subscriptionmgr.TierInfo `json:"subscription_tiers,omitempty"`
+
+	Unavailable []string `json:"unavailable,omitempty"`
+}
+
+// BillingSession is the wallet's active on-chain session.
+type BillingSession struct {
+	ID               uint64 `json:"id"`
+	Node             string `json:"node"`
+	PaymentWei       string `json:"payment_wei"`
+	StartedAt        string `json:"started_at"`
+	ExpiresAt        string `json:"expires_at"`
+	RemainingSeconds uint64 `json:"remaining_seconds"`
+}
+
+// BillingSubscription is the wallet's unexpired subscription.
+type BillingSubscription struct {
+	Node             string `json:"node"`
+	Tier             uint8  `json:"tier"`
+	PaymentWei       string `json:"payment_wei"`
+	StartedAt        string `json:"started_at"`
+	ExpiresAt        string `json:"expires_at"`
+	RemainingSeconds uint64 `json:"remaining_seconds"`
+}
+
+// Parts of a BillingStatus that can be reported unavailable.
+const (
+	billingSession           = "session"
+	billingSessionPricing    = "session_pricing"
+	billingSubscription      = "subscription"
+	billingSubscriptionTiers = "subscription_tiers"
+)
+
+// GET /billing/status?session_token=<opaque-token> — the session wallet's
+// on-chain session and subscription, the time left on them, and current
+// session pricing and subscription tiers. Each call costs several RPC
+// reads, so it takes a session and is charged to the wallet's rate limit.
+// A failed read is reported in "unavailable" rather than failing the whole
+// response.
+func (s *Server) handleBillingStatus(w http.ResponseWriter, r *http.Request) {
+	if s.sessionMgr == nil && s.subMgr == nil {
+		writeFeatureDisabled(w, "neither session manager nor subscription manager is configured")
+		return
+	}
+	token := r.URL.Query().Get("session_token")
+	if token == "" {
+		token = bearerToken(r)
+	}
+	if token == "" {
+		writeError(w, http.StatusBadRequest, "session_token required")
+		return
+	}
+	session := s.gate.GetSessionByToken(token)
+	if session == nil {
+		writeError(w, http.StatusUnauthorized, "session expired or not found, re-authenticate")
+		return
+	}
+	if !session.AddressBound {
+		writeError(w, http.StatusBadRequest, "billing status is not available for anonymous sessions")
+		return
+	}
+	if err := allowKey(s.walletLimiter, sessionLimitKey(session)); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	wallet := session.Address
+	ctx := r.Context()
+	now := s.now()
+
+	resp := BillingStatus{
+		Address:              wallet.Hex(),
+		SessionsEnabled:      s.sessionMgr != nil,
+		SubscriptionsEnabled: s.subMgr != nil,
+	}
+	unavailable := func(part string, err error) {
+		log.Printf("Error reading billing %s: %v", part, err)
+		resp.Unavailable = append(resp.Unavailable, part)
+	}
+
+	if s.sessionMgr != nil {
+		if id, err := s.sessionMgr.GetActiveSessionID(ctx, wallet); err != nil {
+			unavailable(billingSession, err)
+		} else if id != 0 {
+			if onChain, err := s.sessionMgr.GetSession(ctx, id); err != nil {
+				unavailable(billingSession, err)
+			} else if onChain.Active {
+				resp.Session = billingSessionFrom(id, onChain, now)
+			}
+		}
+		if info, err := s.sessionMgr.GetSessionInfo(ctx); err != nil {
+			unavailable(billingSessionPricing, err)
+		} else {
+			resp.SessionPricing = info
+		}
+	}
+
+	if s.subMgr != nil {
+		if sub, err := s.subMgr.GetSubscription(ctx, wallet); err != nil {
+			unavailable(billingSubscription, err)
+		} else if sub.ExpiresAt > uint64(now.Unix()) {
+			resp.Subscription = billingSubscriptionFrom(sub, now)
+		}
+		if tiers, err := s.subMgr.GetTiers(ctx); err != nil {
+			unavailable(billingSubscriptionTiers, err)
+		} else {
+			resp.SubscriptionTiers = tiers
+		}
+	}
+
+	if resp.Session != nil {
+		resp.RemainingSeconds = resp.Session.RemainingSeconds
+	}
+	if resp.Subscription != nil {
+		resp.RemainingSeconds = max(resp.RemainingSeconds, resp.Subscription.RemainingSeconds)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func billingSessionFrom(id uint64, s *sessionmgr.OnChainSession, now time.Time) *BillingSession {
+	expiresAt := s.StartedAt + s.Duration
+	b := &BillingSession{
+		ID:               id,
+		Node:             s.Node.Hex(),
+		PaymentWei:       "0",
+		StartedAt:        unixRFC3339(s.StartedAt),
+		ExpiresAt:        unixRFC3339(expiresAt),
+		RemainingSeconds: secondsUntil(expiresAt, now),
+	}
+	if s.Payment != nil {
+		b.PaymentWei = s.Payment.String()
+	}
+	return b
+}
+
+func billingSubscriptionFrom(s *subscriptionmgr.OnChainSubscription, now time.Time) *BillingSubscription {
+	b := &BillingSubscription{
+		Node:             s.Node.Hex(),
+		Tier:             s.Tier,
+		PaymentWei:       "0",
+		StartedAt:        unixRFC3339(s.StartedAt),
+		ExpiresAt:        unixRFC3339(s.ExpiresAt),
+		RemainingSeconds: secondsUntil(s.ExpiresAt, now),
+	}
+	if s.Payment != nil {
+		b.PaymentWei = s.Payment.String()
+	}
+	return b
+}
+
+func unixRFC3339(secs uint64) string {
+	return time.Unix(int64(min(secs, 1<<62)), 0).UTC().Format(time.RFC3339)
+}
+
+// secondsUntil returns the whole seconds from now to the unix time end, or 0
+// if it has passed.
+func secondsUntil(end uint64, now time.Time) uint64 {
+	if n := uint64(now.Unix()); end > n {
+		return end - n
+	}
+	return 0
+}
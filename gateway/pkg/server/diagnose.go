@@ -0,0 +1,187 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/nftcheck"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/siwe"
+)
+
+// Deciding factors reported by GET /access/diagnose.
+const (
+	factorGranted    = "granted"
+	factorNoAccess   = "no_access"
+	factorCheckError = "check_error"
+	factorRepBan     = "rep_ban"
+	factorRegion     = "region"
+)
+
+// DiagnoseResponse explains the access decision for a wallet.
+type DiagnoseResponse struct {
+	Address         string           `json:"address"`
+	Checks          []DiagnoseCheck  `json:"checks"`
+	FreeTierEnabled bool             `json:"free_tier_enabled"`
+	RepBan          *DiagnoseRepBan  `json:"rep_ban,omitempty"` // omitted when the ban check is disabled
+	Decision        DiagnoseDecision `json:"decision"`
+}
+
+// DiagnoseCheck is one access source (card balance, subscription, ...).
+type DiagnoseCheck struct {
+	Source          string           `json:"source"`
+	Wallet          DiagnoseWallet   `json:"wallet"`
+	Vaults          []DiagnoseWallet `json:"vaults,omitempty"`
+	DelegationError string           `json:"delegation_error,omitempty"`
+	Tier            string           `json:"tier"`
+}
+
+// DiagnoseWallet is the tier of one wallet, or the error looking it up.
+type DiagnoseWallet struct {
+	Address  string  `json:"address"`
+	Tier     string  `json:"tier"`
+	TokenIDs []int64 `json:"token_ids,omitempty"` // cards held, for sources that read balances
+	Error    string  `json:"error,omitempty"`
+}
+
+// DiagnoseRepBan reports the 6529 rep ban check.
+type DiagnoseRepBan struct {
+	Category string `json:"category"`
+	Rating   int64  `json:"rating"`
+	Banned   bool   `json:"banned"`
+	Error    string `json:"error,omitempty"` // lookup failures do not block access
+}
+
+// DiagnoseDecision is the final outcome and what decided it.
+type DiagnoseDecision struct {
+	Allowed        bool   `json:"allowed"`
+	Tier           string `json:"tier"`
+	DecidingFactor string `json:"deciding_factor"`
+	Detail         string `json:"detail"`
+}
+
+// GET /access/diagnose -- explain why a wallet is granted or denied access.
+// Authenticate with either a session token (Authorization: Bearer) or, for
+// wallets that were denied and so hold no session, a fresh SIWE challenge
+// signed and passed as ?message=&signature=.
+func (s *Server) handleAccessDiagnose(w http.ResponseWriter, r *http.Request) {
+	wallet, ok := s.diagnoseCaller(w, r)
+	if !ok {
+		return
+	}
+
+	explanations := nftcheck.Explain(r.Context(), s.checker, wallet)
+	resp := DiagnoseResponse{
+		Address:         wallet.Hex(),
+		Checks:          make([]DiagnoseCheck, 0, len(explanations)),
+		FreeTierEnabled: s.freeTier,
+	}
+
+	best := nftcheck.TierDenied
+	var bestSource string
+	var checkErr error
+	for _, exp := range explanations {
+		check := DiagnoseCheck{
+			Source: exp.Source,
+			Wallet: diagnoseWallet(exp.Direct),
+			Tier:   exp.Tier.String(),
+		}
+		if exp.Direct.Err != nil && checkErr == nil {
+			checkErr = exp.Direct.Err
+		}
+		for _, v := range exp.Vaults {
+			check.Vaults = append(check.Vaults, diagnoseWallet(v))
+		}
+		if exp.DelegationErr != nil {
+			check.DelegationError = exp.DelegationErr.Error()
+			if checkErr == nil {
+				checkErr = exp.DelegationErr
+			}
+		}
+		if exp.Tier > best {
+			best = exp.Tier
+			bestSource = exp.Source
+			if exp.Direct.Tier < exp.Tier {
+				bestSource += " (delegated vault)"
+			}
+		}
+		resp.Checks = append(resp.Checks, check)
+	}
+
+	tier := s.effectiveTier(best)
+	resp.Decision = DiagnoseDecision{Tier: tier.String()}
+
+	if s.userRep != nil && tier != nftcheck.TierDenied {
+		ban := &DiagnoseRepBan{Category: s.userRep.Category()}
+		rep, err := s.userRep.CheckRep(r.Context(), wallet.Hex())
+		if err != nil {
+			ban.Error = err.Error()
+		} else {
+			ban.Rating = rep.Rating
+			ban.Banned = rep.Rating < 0
+		}
+		resp.RepBan = ban
+	}
+
+	switch {
+	case best == nftcheck.TierDenied && checkErr != nil:
+		resp.Decision.DecidingFactor = factorCheckError
+		resp.Decision.Detail = "access lookup failed: " + checkErr.Error()
+	case tier == nftcheck.TierDenied:
+		resp.Decision.DecidingFactor = factorNoAccess
+		resp.Decision.Detail = "no access source grants this wallet or its delegated vaults access"
+	case resp.RepBan != nil && resp.RepBan.Banned:
+		resp.Decision.Tier = nftcheck.TierDenied.String()
+		resp.Decision.DecidingFactor = factorRepBan
+		resp.Decision.Detail = "wallet banned: negative reputation in " + resp.RepBan.Category + " category"
+	case !s.regionAllows(tier):
+		resp.Decision.DecidingFactor = factorRegion
+		resp.Decision.Detail = s.regionDeniedMessage()
+	default:
+		resp.Decision.Allowed = true
+		resp.Decision.DecidingFactor = factorGranted
+		resp.Decision.Detail = tier.String() + " tier via " + bestSource
+		if tier != best {
+			resp.Decision.Detail += "; free tier is disabled on this gateway"
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// diagnoseCaller authenticates the caller and returns their wallet. It
+// writes the error response itself when authentication fails.
+func (s *Server) diagnoseCaller(w http.ResponseWriter, r *http.Request) (common.Address, bool) {
+	if token := bearerToken(r); token != "" {
+		session := s.gate.GetSessionByToken(token)
+		if session == nil {
+			writeError(w, http.StatusUnauthorized, "session expired or not found, re-authenticate")
+			return common.Address{}, false
+		}
+		if !session.AddressBound {
+			writeError(w, http.StatusBadRequest, "anonymous sessions have no wallet to diagnose")
+			return common.Address{}, false
+		}
+		return session.Address, true
+	}
+
+	message := r.URL.Query().Get("message")
+	signature := r.URL.Query().Get("signature")
+	if message == "" || signature == "" {
+		writeError(w, http.StatusBadRequest, "Authorization Bearer token or SIWE message and signature required")
+		return common.Address{}, false
+	}
+	auth, err := s.siwe.Verify(&siwe.SignedMessage{Message: message, Signature: signature})
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return common.Address{}, false
+	}
+	return auth.Address, true
+}
+
+func diagnoseWallet(c nftcheck.WalletCheck) DiagnoseWallet {
+	d := DiagnoseWallet{Address: c.Wallet.Hex(), Tier: c.Tier.String(), TokenIDs: c.TokenIDs}
+	if c.Err != nil {
+		d.Error = c.Err.Error()
+	}
+	return d
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/noderegistry"
+)
+
+// drainState is the gateway's drain mode: while draining, connects are
+// refused and existing peers run until they expire.
+type drainState struct {
+	mu               sync.Mutex
+	since            time.Time // zero when not draining
+	heartbeat        *noderegistry.HeartbeatSender
+	heartbeatStopped bool
+}
+
+// errDraining refuses connects while the node is draining. Clients should
+// pick another node rather than retry this one.
+var errDraining = &requestError{status: http.StatusServiceUnavailable, message: "node draining", code: errCodeDraining}
+
+// DrainRequest is the optional body for POST /admin/drain.
+type DrainRequest struct {
+	// StopHeartbeat also pauses registry heartbeats, so the node goes
+	// heartbeat-overdue and discovery stops advertising it.
+	StopHeartbeat bool `json:"stop_heartbeat"`
+}
+
+// DrainStatus is returned by the /admin/drain endpoints.
+type DrainStatus struct {
+	Draining         bool   `json:"draining"`
+	Since            string `json:"since,omitempty"`
+	HeartbeatStopped bool   `json:"heartbeat_stopped"`
+	ActivePeers      int    `json:"active_peers"`
+	// LastPeerExpiresAt is when the last remaining peer expires, after which
+	// the node can be taken down without cutting anyone off.
+	LastPeerExpiresAt string `json:"last_peer_expires_at,omitempty"`
+}
+
+// SetHeartbeatSender lets POST /admin/drain pause the node's registry
+// heartbeats. Call it before serving. If the node is already draining with
+// heartbeats stopped, e.g. --start-drained, h starts out paused.
+func (s *Server) SetHeartbeatSender(h *noderegistry.HeartbeatSender) {
+	s.drain.mu.Lock()
+	defer s.drain.mu.Unlock()
+	s.drain.heartbeat = h
+	if s.drain.heartbeatStopped && h != nil {
+		h.SetPaused(true)
+	}
+}
+
+// SetDraining starts or ends drain mode, e.g. for --start-drained. With
+// stopHeartbeat, heartbeats are paused until the drain ends.
+func (s *Server) SetDraining(draining, stopHeartbeat bool) {
+	s.drain.mu.Lock()
+	defer s.drain.mu.Unlock()
+
+	if !draining {
+		if !s.drain.since.IsZero() {
+			log.Printf("Drain ended after %s", s.now().Sub(s.drain.since).Round(time.Second))
+		}
+		s.drain.since = time.Time{}
+		if s.drain.heartbeatStopped && s.drain.heartbeat != nil {
+			s.drain.heartbeat.SetPaused(false)
+		}
+		s.drain.heartbeatStopped = false
+		return
+	}
+
+	if s.drain.since.IsZero() {
+		s.drain.since = s.now()
+		log.Printf("Draining: refusing new connections, %d peers left", s.wg.PeerCount())
+	}
+	if stopHeartbeat {
+		s.drain.heartbeatStopped = true
+		if s.drain.heartbeat != nil {
+			s.drain.heartbeat.SetPaused(true)
+		}
+	}
+}
+
+// draining reports whether the gateway is in drain mode.
+func (s *Server) draining() bool {
+	s.drain.mu.Lock()
+	defer s.drain.mu.Unlock()
+	return !s.drain.since.IsZero()
+}
+
+// POST /admin/drain -- stop accepting connections; existing peers keep
+// working until they expire.
+// Request (optional): { "stop_heartbeat": true }
+func (s *Server) handleAdminDrain(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	var req DrainRequest
+	if r.ContentLength != 0 && !s.decodeJSON(w, r, &req, true) {
+		return
+	}
+	if req.StopHeartbeat && s.drain.heartbeat == nil {
+		writeFeatureDisabled(w, "heartbeats not enabled on this node")
+		return
+	}
+	s.SetDraining(true, req.StopHeartbeat)
+	writeJSON(w, http.StatusOK, s.drainStatus())
+}
+
+// POST /admin/undrain -- accept connections again and resume heartbeats.
+func (s *Server) handleAdminUndrain(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	s.SetDraining(false, false)
+	writeJSON(w, http.StatusOK, s.drainStatus())
+}
+
+// GET /admin/drain -- drain state and how many peers are left.
+func (s *Server) handleAdminDrainStatus(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.drainStatus())
+}
+
+func (s *Server) drainStatus() DrainStatus {
+	s.drain.mu.Lock()
+	status := DrainStatus{
+		Draining:         !s.drain.since.IsZero(),
+		HeartbeatStopped: s.drain.heartbeatStopped && s.drain.heartbeat != nil,
+	}
+	if status.Draining {
+		status.Since = s.drain.since.UTC().Format(time.RFC3339)
+	}
+	s.drain.mu.Unlock()
+
+	var last time.Time
+	for _, peer := range s.wg.Peers() {
+		status.ActivePeers++
+		if peer.ExpiresAt.After(last) {
+			last = peer.ExpiresAt
+		}
+	}
+	if !last.IsZero() {
+		status.LastPeerExpiresAt = last.UTC().Format(time.RFC3339)
+	}
+	return status
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/feedback"
+)
+
+// FeedbackRequest is the body for POST /vpn/feedback.
+type FeedbackRequest struct {
+	SessionToken   string  `json:"session_token"`
+	PublicKey      string  `json:"public_key"` // connected peer the measurements were taken on
+	LatencyMS      float64 `json:"latency_ms"`
+	FailureRate    float64 `json:"failure_rate"`
+	ThroughputKbps float64 `json:"throughput_kbps,omitempty"`
+}
+
+// SetFeedbackStore enables POST /vpn/feedback and the quality score in
+// GET /version.
+func (s *Server) SetFeedbackStore(store *feedback.Store) {
+	s.feedback = store
+}
+
+// POST /vpn/feedback -- report the connection quality seen on one of the
+// session's peers, typically just before disconnecting it. Only a session
+// with a connected peer can report, and each wallet (or anonymous session)
+// counts once towards the node's score.
+func (s *Server) handleVPNFeedback(w http.ResponseWriter, r *http.Request) {
+	if s.feedback == nil {
+		writeFeatureDisabled(w, "connection feedback not enabled")
+		return
+	}
+	var req FeedbackRequest
+	if !s.decodeJSON(w, r, &req, true) {
+		return
+	}
+	if req.SessionToken == "" || req.PublicKey == "" {
+		writeError(w, http.StatusBadRequest, "session_token and public_key are required")
+		return
+	}
+
+	session := s.gate.GetSessionByToken(req.SessionToken)
+	if session == nil {
+		writeRequestError(w, errSessionNotFound)
+		return
+	}
+	if err := allowKey(s.walletLimiter, sessionLimitKey(session)); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if !s.peerOwnedBy(req.PublicKey, session.ID) {
+		writeError(w, http.StatusForbidden, "public key is not connected by this session")
+		return
+	}
+
+	report := feedback.Report{
+		LatencyMS:      req.LatencyMS,
+		FailureRate:    req.FailureRate,
+		ThroughputKbps: req.ThroughputKbps,
+	}
+	if err := report.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.feedback.Add(sessionLimitKey(session), report)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "recorded"})
+}
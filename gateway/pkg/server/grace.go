@@ -0,0 +1,97 @@
+package server
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/nftcheck"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/nftgate"
+)
+
+// minPeerTTL is the shortest lifetime a session-bound peer is provisioned
+// with while the config.MinConnectTTL check is on, however low it is set.
+const minPeerTTL = 30 * time.Second
+
+func (s *Server) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock.Now()
+}
+
+// transferIn is a card a wallet just received, and the tier it grants.
+type transferIn struct {
+	at   time.Time
+	tier nftcheck.AccessTier
+}
+
+// cardTier is the tier the given cards grant: free if they are all THIS
+// card, paid otherwise.
+func (s *Server) cardTier(tokenIDs []*big.Int) nftcheck.AccessTier {
+	for _, id := range tokenIDs {
+		if s.thisCardID <= 0 || !id.IsInt64() || id.Int64() != s.thisCardID {
+			return nftcheck.TierPaid
+		}
+	}
+	return nftcheck.TierFree
+}
+
+// recordTransferIn opens the wallet's grace period at the tier of the cards
+// it received. A no-op when grace is off.
+func (s *Server) recordTransferIn(wallet common.Address, tier nftcheck.AccessTier) {
+	if s.cfg.GracePeriod <= 0 {
+		return
+	}
+	s.transferMu.Lock()
+	s.transfersIn.Add(wallet, transferIn{at: s.now(), tier: tier}, nil)
+	s.transferMu.Unlock()
+}
+
+// takeGrace returns the tier of the card the wallet received within the
+// grace period, if any, and closes it: one transfer-in buys one
+// probationary session.
+func (s *Server) takeGrace(wallet common.Address) (nftcheck.AccessTier, bool) {
+	if s.cfg.GracePeriod <= 0 {
+		return nftcheck.TierDenied, false
+	}
+	s.transferMu.Lock()
+	defer s.transferMu.Unlock()
+	in, ok := s.transfersIn.Peek(wallet)
+	if !ok {
+		return nftcheck.TierDenied, false
+	}
+	s.transfersIn.Remove(wallet)
+	if s.now().Sub(in.at) > s.cfg.GracePeriod {
+		return nftcheck.TierDenied, false
+	}
+	return in.tier, true
+}
+
+// probationTTL caps a peer lifetime d at a probationary session's expiry,
+// so the tunnel ends when the wallet is due its re-check.
+func (s *Server) probationTTL(session *nftgate.Session, d time.Duration) (time.Duration, error) {
+	if !session.Probationary {
+		return d, nil
+	}
+	left, err := s.sessionPeerTTL(session)
+	if err != nil {
+		return 0, err
+	}
+	return min(d, left), nil
+}
+
+// sessionPeerTTL returns the time left on session for a peer bound to it.
+// The session was valid when looked up, but the client may have waited or
+// on-chain reads since may have run it down; a peer with less than
+// MinConnectTTL to live is not worth setting up, so the client is told to
+// sign in again instead.
+func (s *Server) sessionPeerTTL(session *nftgate.Session) (time.Duration, error) {
+	left := session.ExpiresAt.Sub(s.now())
+	floor := s.cfg.MinConnectTTLFor(session.ExpiresAt.Sub(session.CreatedAt))
+	if floor > 0 && left < max(floor, minPeerTTL) {
+		return 0, errSessionExpiring
+	}
+	return left, nil
+}
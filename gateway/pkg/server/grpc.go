@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/gatewaypb"
+)
+
+// grpcService serves the Gateway gRPC service from the same methods the HTTP
+// handlers use, so both transports enforce identical access rules.
+type grpcService struct {
+	gatewaypb.UnimplementedGatewayServer
+	s *Server
+}
+
+// GRPCServer returns a gRPC server exposing the Gateway service. Calls are
+// rate limited per client IP by the HTTP limiter, when one is configured.
+func (s *Server) GRPCServer() *grpc.Server {
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(grpcRecover, s.grpcRateLimit))
+	gatewaypb.RegisterGatewayServer(srv, &grpcService{s: s})
+	return srv
+}
+
+func (g *grpcService) Challenge(ctx context.Context, req *gatewaypb.ChallengeRequest) (*gatewaypb.ChallengeResponse, error) {
+	resp, err := g.s.newChallenge(req.GetAddress())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &gatewaypb.ChallengeResponse{Message: resp.Message, Nonce: resp.Nonce}, nil
+}
+
+func (g *grpcService) Verify(ctx context.Context, req *gatewaypb.VerifyRequest) (*gatewaypb.VerifyResponse, error) {
+	session, err := g.s.verify(ctx, verifyRequest{Message: req.GetMessage(), Signature: req.GetSignature()})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &gatewaypb.VerifyResponse{
+		Address:      session.Address.Hex(),
+		SessionToken: session.Token,
+		Tier:         session.Tier.String(),
+		ExpiresAt:    timestamppb.New(session.ExpiresAt),
+	}, nil
+}
+
+func (g *grpcService) Connect(ctx context.Context, req *gatewaypb.ConnectRequest) (*gatewaypb.ConnectResponse, error) {
+	grant, err := g.s.connect(ctx, req.GetSessionToken(), req.GetPublicKey())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &gatewaypb.ConnectResponse{
+		ServerPublicKey:     grant.peer.ServerPublicKey,
+		ServerEndpoint:      grant.peer.ServerEndpoint,
+		ClientAddress:       grant.peer.ClientAddress,
+		Dns:                 grant.peer.DNS,
+		AllowedIps:          grant.peer.AllowedIPs,
+		ExpiresAt:           timestamppb.New(grant.expiresAt),
+		Tier:                grant.tier,
+		PersistentKeepalive: int32(grant.peer.PersistentKeepalive),
+		AlternateEndpoints:  grant.peer.AlternateEndpoints,
+	}, nil
+}
+
+func (g *grpcService) Disconnect(ctx context.Context, req *gatewaypb.DisconnectRequest) (*gatewaypb.DisconnectResponse, error) {
+	removed, err := g.s.disconnect(ctx, req.GetSessionToken(), req.GetPublicKey())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &gatewaypb.DisconnectResponse{PeersRemoved: int32(removed)}, nil
+}
+
+func (g *grpcService) Status(ctx context.Context, req *gatewaypb.StatusRequest) (*gatewaypb.StatusResponse, error) {
+	if req.GetSessionToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "session_token is required")
+	}
+	st := g.s.sessionStatus(req.GetSessionToken())
+	resp := &gatewaypb.StatusResponse{Connected: st.connected, Reason: st.reason}
+	if st.session != nil {
+		resp.Tier = st.session.Tier.String()
+		resp.ExpiresAt = timestamppb.New(st.session.ExpiresAt)
+	}
+	if q := st.quota; q != nil {
+		resp.Quota = &gatewaypb.Quota{
+			UsedBytes:   q.UsedBytes,
+			LimitBytes:  q.LimitBytes,
+			PeriodStart: timestamppb.New(q.PeriodStart),
+			ResetsAt:    timestamppb.New(q.ResetsAt),
+			Exceeded:    q.Exceeded,
+		}
+	}
+	return resp, nil
+}
+
+func (g *grpcService) ListNodes(ctx context.Context, req *gatewaypb.ListNodesRequest) (*gatewaypb.ListNodesResponse, error) {
+	nodes, err := g.s.listNodes(ctx, req.GetRegion())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &gatewaypb.ListNodesResponse{Nodes: make([]*gatewaypb.Node, 0, len(nodes))}
+	for _, n := range nodes {
+		resp.Nodes = append(resp.Nodes, &gatewaypb.Node{
+			Operator:       n.Operator,
+			Endpoint:       n.Endpoint,
+			WgPubKey:       n.WgPubKey,
+			Region:         n.Region,
+			RequiredTier:   n.RequiredTier,
+			CardEligible:   n.CardEligible,
+			Active:         n.Active,
+			RailgunAddress: n.RailgunAddress,
+		})
+	}
+	return resp, nil
+}
+
+// grpcError converts a request error to a gRPC status with the code closest
+// to its HTTP status.
+func grpcError(err error) error {
+	var re *requestError
+	if !errors.As(err, &re) {
+		return status.Error(codes.Internal, "internal server error")
+	}
+	return status.Error(grpcCode(re), re.message)
+}
+
+func grpcCode(re *requestError) codes.Code {
+	if re.code == errCodeFeatureDisabled {
+		return codes.Unimplemented
+	}
+	switch re.status {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusPaymentRequired:
+		return codes.FailedPrecondition
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// grpcRecover turns a panicking handler into an Internal error, as
+// recoverMiddleware does for HTTP.
+func grpcRecover(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("Panic serving gRPC %s: %v\n%s", info.FullMethod, rec, debug.Stack())
+			err = status.Error(codes.Internal, "internal server error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func (s *Server) grpcRateLimit(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if s.limiter != nil {
+		if p, ok := peer.FromContext(ctx); ok {
+			ip := p.Addr.String()
+			if host, _, err := net.SplitHostPort(ip); err == nil {
+				ip = host
+			}
+			if !s.limiter.Allow(ip) {
+				return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+			}
+		}
+	}
+	return handler(ctx, req)
+}
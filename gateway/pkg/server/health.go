@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/buildinfo"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/feedback"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/wireguard"
+)
+
+const (
+	// healthProbeTimeout bounds each dependency probe run by GET /health.
+	healthProbeTimeout = 3 * time.Second
+
+	// healthCacheTTL is how long probe results are reused, so load balancer
+	// polling does not turn into a request per poll against every backend.
+	healthCacheTTL = 10 * time.Second
+)
+
+// Overall gateway status reported by GET /health.
+const (
+	healthStatusOK       = "ok"
+	healthStatusDegraded = "degraded"
+	healthStatusDown     = "down"
+)
+
+// HealthProbe checks one external dependency for GET /health.
+type HealthProbe struct {
+	Name     string
+	Critical bool // A failing critical probe reports the gateway as down (503)
+	Check    func(ctx context.Context) error
+}
+
+// DependencyStatus is the per-dependency entry in the /health response.
+type DependencyStatus struct {
+	Status    string `json:"status"` // "ok" or "down"
+	Critical  bool   `json:"critical"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+// healthState caches the most recent probe run.
+type healthState struct {
+	mu        sync.Mutex
+	probes    []HealthProbe
+	results   map[string]DependencyStatus
+	checkedAt time.Time
+}
+
+// AddHealthProbe registers a dependency probe for GET /health.
+func (s *Server) AddHealthProbe(p HealthProbe) {
+	s.health.mu.Lock()
+	s.health.probes = append(s.health.probes, p)
+	s.health.checkedAt = time.Time{}
+	s.health.mu.Unlock()
+}
+
+// dependencyHealth runs the registered probes (or returns cached results)
+// and derives the overall status.
+func (s *Server) dependencyHealth(ctx context.Context) (string, map[string]DependencyStatus) {
+	// Probes can take up to healthProbeTimeout, so they run outside the
+	// lock; concurrent callers that find the cache stale each probe.
+	s.health.mu.Lock()
+	results, checkedAt := s.health.results, s.health.checkedAt
+	probes := slices.Clone(s.health.probes)
+	s.health.mu.Unlock()
+
+	if results == nil || time.Since(checkedAt) >= healthCacheTTL {
+		results = runHealthProbes(ctx, probes)
+		s.health.mu.Lock()
+		s.health.results = results
+		s.health.checkedAt = time.Now()
+		s.health.mu.Unlock()
+	}
+
+	return overallHealth(results), results
+}
+
+// runHealthProbes runs all probes concurrently, each with its own timeout.
+func runHealthProbes(ctx context.Context, probes []HealthProbe) map[string]DependencyStatus {
+	results := make(map[string]DependencyStatus, len(probes))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, p := range probes {
+		wg.Add(1)
+		go func(p HealthProbe) {
+			defer wg.Done()
+
+			probeCtx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := p.Check(probeCtx)
+			status := DependencyStatus{
+				Status:    healthStatusOK,
+				Critical:  p.Critical,
+				LatencyMS: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				status.Status = healthStatusDown
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[p.Name] = status
+			mu.Unlock()
+		}(p)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// overallHealth is "down" if any critical dependency is down, "degraded" if
+// only non-critical ones are, and "ok" otherwise.
+func overallHealth(results map[string]DependencyStatus) string {
+	status := healthStatusOK
+	for _, r := range results {
+		if r.Status == healthStatusOK {
+			continue
+		}
+		if r.Critical {
+			return healthStatusDown
+		}
+		status = healthStatusDegraded
+	}
+	return status
+}
+
+// GET /livez — the process is up and serving HTTP. Never probes dependencies.
+func (s *Server) handleLivez(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+}
+
+// versionResponse is GET /version: the build, plus the node's advertised
+// DNS policy so discovery can show it before anyone connects, and whether
+// it provisions tunnels at all so discovery can leave it out if not.
+type versionResponse struct {
+	buildinfo.Info
+	DNSPolicy     *wireguard.DNSPolicy `json:"dns_policy,omitempty"`
+	Quality       *feedback.Quality    `json:"quality,omitempty"`
+	DiscoveryOnly bool                 `json:"discovery_only,omitempty"`
+}
+
+// GET /version — which build is running, so operators and the registry
+// can spot nodes on outdated releases.
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	resp := versionResponse{Info: s.build}
+	if s.wg != nil {
+		resp.DNSPolicy = s.wg.DNSPolicy()
+		resp.DiscoveryOnly = !s.wg.ProvisionsTunnels()
+	}
+	if s.feedback != nil {
+		resp.Quality = s.feedback.Quality()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GET /readyz — 200 only when every critical dependency (Ethereum RPC) is
+// reachable and the WireGuard interface exists, so orchestrators hold
+// traffic until the gateway can actually serve connects.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := make(map[string]string)
+	ready := true
+
+	_, deps := s.dependencyHealth(r.Context())
+	for name, dep := range deps {
+		if !dep.Critical {
+			continue
+		}
+		checks[name] = dep.Status
+		if dep.Status != healthStatusOK {
+			ready = false
+		}
+	}
+
+	ifaceCheck := s.ifaceCheck
+	if ifaceCheck == nil {
+		ifaceCheck = s.wg.InterfaceExists
+	}
+	if err := ifaceCheck(); err != nil {
+		checks["wireguard"] = err.Error()
+		ready = false
+	} else {
+		checks["wireguard"] = healthStatusOK
+	}
+
+	code := http.StatusOK
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]any{
+		"ready":  ready,
+		"checks": checks,
+	})
+}
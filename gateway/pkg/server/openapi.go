@@ -0,0 +1,19 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec documents every route registered in New. TestOpenAPISpecInSync
+// fails when a route or a response field is added without updating it.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// GET /openapi.json -- the OpenAPI 3 description of this API.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPISpec)
+}
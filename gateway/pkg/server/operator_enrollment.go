@@ -4,7 +4,6 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"net/http"
 	"regexp"
@@ -208,8 +207,7 @@ func (s *memoryOperatorEnrollmentStore) cleanupLocked(now time.Time) {
 
 func (s *Server) handleCreateOperatorEnrollment(w http.ResponseWriter, r *http.Request) {
 	var req createOperatorEnrollmentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if !s.decodeJSON(w, r, &req, true) {
 		return
 	}
 
@@ -285,9 +283,9 @@ func (s *Server) handleReportOperatorEnrollment(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	// Not strict: installers of different versions report different fields.
 	var report OperatorEnrollmentReport
-	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if !s.decodeJSON(w, r, &report, false) {
 		return
 	}
 
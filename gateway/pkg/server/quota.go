@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/connlog"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/nftcheck"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/nftgate"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/quota"
+)
+
+// quotaFor returns the per-period data quota for a tier in bytes
+// (0 = unlimited).
+func (s *Server) quotaFor(tier nftcheck.AccessTier) uint64 {
+	switch tier {
+	case nftcheck.TierFree:
+		return s.cfg.QuotaFreeBytes
+	case nftcheck.TierPaid:
+		return s.cfg.QuotaPaidBytes
+	default:
+		return 0
+	}
+}
+
+func (s *Server) quotasEnabled() bool {
+	return s.cfg.QuotaFreeBytes > 0 || s.cfg.QuotaPaidBytes > 0
+}
+
+// sessionQuota returns the data usage of a wallet session, or nil if quotas
+// are off or the session is anonymous.
+func (s *Server) sessionQuota(session *nftgate.Session) *quota.Usage {
+	if !s.quotasEnabled() || !session.AddressBound {
+		return nil
+	}
+	usage := s.quota.Usage(walletKey(session.Address), s.quotaFor(session.Tier))
+	return &usage
+}
+
+// quotaBlocks reports whether session is over quota, with the reason to
+// show the user.
+func (s *Server) quotaBlocks(session *nftgate.Session) (string, bool) {
+	usage := s.sessionQuota(session)
+	if usage == nil || !usage.Exceeded {
+		return "", false
+	}
+	return fmt.Sprintf("data quota exceeded, resets at %s", usage.ResetsAt.UTC().Format(time.RFC3339)), true
+}
+
+// StartUsageWorker meters peer traffic every interval until ctx is
+// cancelled, enforcing data quotas and recording usage history. Counters come
+// from the WireGuard manager's stats refresh, so interval need not be shorter
+// than its cleanup interval.
+func (s *Server) StartUsageWorker(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.meterUsage()
+			}
+		}
+	}()
+}
+
+// meterUsage adds each wallet peer's new traffic to the wallet's usage (and
+// its history, if a usage store is set) and disconnects wallets that went
+// over quota.
+func (s *Server) meterUsage() {
+	if !s.quotasEnabled() && s.usage == nil {
+		return
+	}
+
+	s.peerMu.RLock()
+	owners := make(map[string]string, len(s.peerOwners))
+	for pubKey, owner := range s.peerOwners {
+		owners[pubKey] = owner.wallet
+	}
+	s.peerMu.RUnlock()
+
+	active := make(map[string]bool)
+	wallets := make(map[string]bool)
+	for _, peer := range s.wg.Peers() {
+		active[peer.PublicKey] = true
+		wallet := owners[peer.PublicKey]
+		if wallet == "" {
+			continue // anonymous sessions are not metered
+		}
+		delta := s.quota.Observe(wallet, peer.PublicKey, peer.AssignedAt, peer.BytesReceived+peer.BytesSent)
+		if s.usage != nil {
+			if err := s.usage.Add(wallet, delta); err != nil {
+				log.Printf("[usage] Error recording usage: %v", err)
+			}
+		}
+		wallets[wallet] = true
+	}
+	s.quota.Retain(active)
+	if !s.quotasEnabled() {
+		return
+	}
+
+	for wallet := range wallets {
+		addr := common.HexToAddress(wallet)
+		session := s.gate.GetSession(addr)
+		if session == nil {
+			continue
+		}
+		usage := s.quota.Usage(wallet, s.quotaFor(session.Tier))
+		if !usage.Exceeded {
+			continue
+		}
+		n := s.removeWalletPeers(addr)
+		connlog.Printf("[quota] Disconnected %d peer(s) over quota: tier=%s used=%d limit=%d", n, session.Tier, usage.UsedBytes, usage.LimitBytes)
+	}
+}
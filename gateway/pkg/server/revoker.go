@@ -1,9 +1,12 @@
 package server
 
 import (
-	"log"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/connlog"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/webhook"
 )
 
 // Revoker implements revocation.SessionRevoker by invalidating NFT cache,
@@ -17,7 +20,8 @@ func NewRevoker(srv *Server) *Revoker {
 	return &Revoker{srv: srv}
 }
 
-// InvalidateAndRevoke invalidates the NFT check cache and revokes the session.
+// InvalidateAndRevoke invalidates the NFT check cache, revokes the session,
+// and tears down the wallet's WireGuard peers.
 func (r *Revoker) InvalidateAndRevoke(wallet common.Address) {
 	// Invalidate NFT check cache so next check hits on-chain
 	r.srv.checker.Invalidate(wallet)
@@ -25,16 +29,31 @@ func (r *Revoker) InvalidateAndRevoke(wallet common.Address) {
 	// Revoke session via the gate
 	r.srv.gate.RevokeSession(wallet)
 
+	// Drop the tunnels now rather than at credential-TTL cleanup
+	removed := r.srv.removeWalletPeers(wallet)
+
 	// Close on-chain session (fire-and-forget)
 	if r.srv.sessionMgr != nil {
 		r.srv.sessionMgr.CloseSessionFor(wallet)
 	}
 
-	log.Printf("[revoker] Invalidated cache and revoked session")
+	// Notify integrators (queued, non-blocking)
+	if r.srv.webhooks != nil {
+		r.srv.webhooks.Notify(wallet, webhook.ReasonNFTTransferred)
+	}
+	r.srv.auditRevoke(wallet, webhook.ReasonNFTTransferred)
+
+	connlog.Printf("[revoker] Invalidated cache, revoked session, removed %d peer(s)", removed)
 }
 
 // InvalidateOnly invalidates the NFT check cache without revoking the session.
 func (r *Revoker) InvalidateOnly(wallet common.Address) {
 	r.srv.checker.Invalidate(wallet)
-	log.Printf("[revoker] Invalidated cache")
+	connlog.Printf("[revoker] Invalidated cache")
+}
+
+// RecordTransferIn notes that the wallet just received the given cards,
+// opening its grace period (see config.GracePeriod).
+func (r *Revoker) RecordTransferIn(wallet common.Address, tokenIDs []*big.Int) {
+	r.srv.recordTransferIn(wallet, r.srv.cardTier(tokenIDs))
 }
@@ -2,10 +2,15 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
@@ -15,15 +20,26 @@ import (
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/config"
 
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/anonauth"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/audit"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/buildinfo"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/connlog"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/feedback"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/lru"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/metrics"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/nftcheck"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/nftgate"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/noderegistry"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/payoutvault"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/quota"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ratelimit"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/rep6529"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/sessionmgr"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/siwe"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/subscriptionmgr"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/txtracker"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/usage"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/webhook"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/wireguard"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/zkverify"
 )
@@ -40,18 +56,36 @@ type Server struct {
 	anonAuth            *anonauth.Service
 	freeTier            bool
 	siwe                *siwe.Service
+	terms               *siwe.Terms // nil when sign-in accepts no terms
 	checker             nftcheck.AccessChecker
 	gate                *nftgate.Gate
-	wg                  *wireguard.Manager
+	wg                  wireguard.PeerManager
 	registry            *noderegistry.Registry
+	nodeVersions        *noderegistry.VersionProber
+	nodeRegions         *noderegistry.RegionVerifier
+	networkStats        *noderegistry.StatsCollector
 	userRep             *rep6529.Checker
+	operatorRep         *rep6529.Checker
 	sessionMgr          *sessionmgr.Manager
+	staleSessionGrace   time.Duration // 0 = DefaultStaleSessionGrace
 	subMgr              *subscriptionmgr.Manager
 	zkClient            *zkverify.Client
 	payoutVault         *payoutvault.Client
+	webhooks            *webhook.Notifier
+	audit               *audit.Logger // nil discards records
+	txs                 *txtracker.Tracker
+	adminToken          string
+	metrics             *metrics.Registry // per-server gauges served on GET /metrics
 	thisCardID          int64
 	peerMu              sync.RWMutex
-	peerOwners          map[string]string
+	peerOwners          map[string]peerOwner // keyed by WireGuard public key
+	walletPeers         map[string][]string  // wallet -> public keys, oldest first
+	sessionPeers        map[string]int       // session ID -> peers it owns; made on first use
+	walletLocksMu       sync.Mutex
+	walletLocks         map[string]*walletLock // wallet -> lock held by its connects
+	quota               *quota.Meter
+	usage               *usage.Store
+	feedback            *feedback.Store
 	policyMu            sync.RWMutex
 	policyFetchMu       sync.Mutex
 	policyRoot          string
@@ -60,17 +94,34 @@ type Server struct {
 	mux                 *http.ServeMux
 	corsOrigin          string
 	limiter             *ratelimit.Limiter
+	challengeLimiter    *ratelimit.Limiter // per claimed address
+	walletLimiter       *ratelimit.Limiter // per verified wallet or nullifier
 	enrollments         OperatorEnrollmentStore
+	health              healthState
+	drain               drainState
+	ifaceCheck          func() error // overrides wg.InterfaceExists in tests
+	clock               clock.Clock  // nil means the wall clock
+	build               buildinfo.Info
+	transferMu          sync.Mutex
+	transfersIn         *lru.Cache[common.Address, transferIn] // wallet -> last card received, for the grace period
 }
 
 // New creates a new gateway server.
-func New(cfg *config.Config, checker nftcheck.AccessChecker, wg *wireguard.Manager) *Server {
+func New(cfg *config.Config, checker nftcheck.AccessChecker, wg wireguard.PeerManager) *Server {
 	gate := nftgate.NewGate(checker, cfg.CredentialTTL)
+	gate.SetMaxSessions(cfg.MaxSessions)
 
 	var limiter *ratelimit.Limiter
 	if cfg.RateLimitPerMinute > 0 {
 		limiter = ratelimit.New(cfg.RateLimitPerMinute, time.Minute)
 	}
+	var challengeLimiter, walletLimiter *ratelimit.Limiter
+	if cfg.ChallengeRateLimitPerMinute > 0 {
+		challengeLimiter = ratelimit.New(cfg.ChallengeRateLimitPerMinute, time.Minute)
+	}
+	if cfg.WalletRateLimitPerMinute > 0 {
+		walletLimiter = ratelimit.New(cfg.WalletRateLimitPerMinute, time.Minute)
+	}
 
 	s := &Server{
 		cfg:         cfg,
@@ -80,23 +131,65 @@ func New(cfg *config.Config, checker nftcheck.AccessChecker, wg *wireguard.Manag
 		checker:     checker,
 		gate:        gate,
 		wg:          wg,
-		peerOwners:  make(map[string]string),
+		peerOwners:  make(map[string]peerOwner),
+		walletPeers: make(map[string][]string),
+		quota:       quota.New(cfg.QuotaPeriod),
 		mux:         http.NewServeMux(),
 		limiter:     limiter,
 		enrollments: newOperatorEnrollmentStore(DefaultOperatorEnrollmentTTL),
+
+		challengeLimiter: challengeLimiter,
+		walletLimiter:    walletLimiter,
+		transfersIn:      lru.New[common.Address, transferIn](cfg.MaxCacheEntries),
+		build:            buildinfo.Resolve("", "", ""),
+	}
+
+	// The session cap must not strand live tunnels without a session to
+	// manage them.
+	gate.KeepSessionsInUse(s.sessionInUse)
+
+	if cfg.SIWEStatement != "" {
+		if err := s.siwe.SetStatement(cfg.SIWEStatement); err != nil {
+			log.Printf("Ignoring invalid SIWE statement: %v", err)
+		}
+	}
+	node := siwe.Node{Name: cfg.NodeName, Region: cfg.Region}
+	if node.Name == "" {
+		node.Name = cfg.SIWEDomain
+	}
+	if err := s.siwe.SetNode(node); err != nil {
+		log.Printf("Ignoring invalid node for the SIWE statement: %v", err)
+	}
+	if cfg.TermsURL != "" {
+		terms := siwe.Terms{URL: cfg.TermsURL, SHA256: cfg.TermsSHA256}
+		if err := s.siwe.SetTerms(terms); err != nil {
+			log.Printf("Ignoring invalid terms of service: %v", err)
+		} else {
+			s.terms = &terms
+		}
 	}
 
 	// Public endpoints (no session required)
 	s.mux.HandleFunc("GET /health", s.handleHealth)
+	s.mux.HandleFunc("GET /livez", s.handleLivez)
+	s.mux.HandleFunc("GET /readyz", s.handleReadyz)
+	s.mux.HandleFunc("GET /version", s.handleVersion)
+	s.mux.HandleFunc("GET /openapi.json", s.handleOpenAPI)
 	s.mux.HandleFunc("POST /auth/challenge", s.handleChallenge)
 	s.mux.HandleFunc("POST /auth/anonymous/challenge", s.handleAnonymousChallenge)
 	s.mux.HandleFunc("POST /auth/verify", s.handleVerify)
+	s.mux.HandleFunc("GET /access/diagnose", s.handleAccessDiagnose)
 
 	// VPN endpoints (session required via NFT gate)
 	s.mux.HandleFunc("POST /vpn/connect", s.handleVPNConnect)
 	s.mux.HandleFunc("POST /vpn/anonymous/connect", s.handleAnonymousVPNConnect)
 	s.mux.HandleFunc("POST /vpn/disconnect", s.handleVPNDisconnect)
+	s.mux.HandleFunc("POST /vpn/disconnect-all", s.handleVPNDisconnectAll)
+	s.mux.HandleFunc("POST /vpn/rekey", s.handleVPNRekey)
+	s.mux.HandleFunc("POST /vpn/feedback", s.handleVPNFeedback)
 	s.mux.HandleFunc("GET /vpn/status", s.handleVPNStatus)
+	s.mux.HandleFunc("GET /vpn/usage", s.handleVPNUsage)
+	s.mux.HandleFunc("GET /vpn/sessions", s.handleVPNSessions)
 
 	// Session info (public — returns contract/pricing for frontend)
 	s.mux.HandleFunc("GET /session/info", s.handleSessionInfo)
@@ -104,9 +197,13 @@ func New(cfg *config.Config, checker nftcheck.AccessChecker, wg *wireguard.Manag
 	// Subscription info (public — returns tiers + contract address for frontend)
 	s.mux.HandleFunc("GET /subscription/tiers", s.handleSubscriptionTiers)
 
+	// Combined session + subscription view of the session's wallet
+	s.mux.HandleFunc("GET /billing/status", s.handleBillingStatus)
+
 	// Node discovery endpoint (public)
 	s.mux.HandleFunc("GET /nodes", s.handleListNodes)
 	s.mux.HandleFunc("GET /nodes/region", s.handleListNodesByRegion)
+	s.mux.HandleFunc("GET /network/stats", s.handleNetworkStats)
 
 	// Payout status (public — returns pending payout + 0zk address for an operator)
 	s.mux.HandleFunc("GET /payout/status", s.handlePayoutStatus)
@@ -116,6 +213,26 @@ func New(cfg *config.Config, checker nftcheck.AccessChecker, wg *wireguard.Manag
 	s.mux.HandleFunc("GET /operator/enrollments/", s.handleGetOperatorEnrollment)
 	s.mux.HandleFunc("POST /operator/enrollments/", s.handleReportOperatorEnrollment)
 
+	// Operator rep breakdown (public — GET /operator/{addr}/rep?page=)
+	s.mux.HandleFunc("GET /operator/", s.handleOperatorRep)
+
+	// Operator admin (bearer admin token)
+	s.mux.HandleFunc("GET /admin/txs", s.handleAdminTxs)
+	s.mux.HandleFunc("GET /admin/drain", s.handleAdminDrainStatus)
+	s.mux.HandleFunc("POST /admin/drain", s.handleAdminDrain)
+	s.mux.HandleFunc("POST /admin/undrain", s.handleAdminUndrain)
+	s.mux.HandleFunc("POST /admin/sessions/cleanup", s.handleAdminSessionCleanup)
+	s.mux.HandleFunc("GET /metrics", s.handleMetrics)
+
+	s.metrics = metrics.NewRegistry()
+	s.metrics.NewGaugeFunc("svpn_sessions", "Active sessions by access tier.", "tier", func() map[string]float64 {
+		counts := make(map[string]float64)
+		for tier, n := range s.gate.SessionCountsByTier() {
+			counts[tier] = float64(n)
+		}
+		return counts
+	})
+
 	return s
 }
 
@@ -131,16 +248,53 @@ func (s *Server) SetChainID(chainID int) {
 	s.siwe.SetChainID(chainID)
 }
 
+// SetClock replaces the clock behind SIWE challenges, session expiry and
+// WireGuard peer expiry. Tests use it to step past TTLs without sleeping.
+func (s *Server) SetClock(c clock.Clock) {
+	s.clock = c
+	s.siwe.SetClock(c)
+	s.gate.SetClock(c)
+	if s.wg != nil {
+		s.wg.SetClock(c)
+	}
+}
+
+// SetBuildInfo sets what GET /version reports.
+func (s *Server) SetBuildInfo(info buildinfo.Info) {
+	s.build = info
+}
+
 // SetRegistry configures the node registry for node discovery endpoints.
 func (s *Server) SetRegistry(r *noderegistry.Registry) {
 	s.registry = r
 }
 
+// SetNodeVersionProber adds each node's gateway version to node listings.
+func (s *Server) SetNodeVersionProber(p *noderegistry.VersionProber) {
+	s.nodeVersions = p
+}
+
+// SetRegionVerifier geolocates each listed node and reports in node
+// listings whether it is in the region its operator declared.
+func (s *Server) SetRegionVerifier(v *noderegistry.RegionVerifier) {
+	s.nodeRegions = v
+}
+
+// SetNetworkStatsCollector enables GET /network/stats.
+func (s *Server) SetNetworkStatsCollector(c *noderegistry.StatsCollector) {
+	s.networkStats = c
+}
+
 // SetUserRepChecker configures the 6529 rep checker for user ban checking.
 func (s *Server) SetUserRepChecker(r *rep6529.Checker) {
 	s.userRep = r
 }
 
+// SetOperatorRepChecker configures the 6529 rep checker for the operator rep breakdown.
+func (s *Server) SetOperatorRepChecker(r *rep6529.Checker) {
+	s.operatorRep = r
+}
+
 // SetSessionManager configures the on-chain session manager.
 func (s *Server) SetSessionManager(m *sessionmgr.Manager) {
 	s.sessionMgr = m
@@ -166,21 +320,92 @@ func (s *Server) SetPayoutVault(c *payoutvault.Client) {
 	s.payoutVault = c
 }
 
+// SetWebhookNotifier configures outbound webhooks for session revocation,
+// expiry and eviction. Only wallet-bound sessions are reported.
+func (s *Server) SetWebhookNotifier(n *webhook.Notifier) {
+	s.webhooks = n
+	s.gate.OnSessionExpired(func(session *nftgate.Session) {
+		if session.AddressBound {
+			n.Notify(session.Address, webhook.ReasonSessionExpired)
+		}
+	})
+	s.gate.OnSessionEvicted(func(session *nftgate.Session) {
+		if session.AddressBound {
+			n.Notify(session.Address, webhook.ReasonSessionEvicted)
+		}
+	})
+}
+
+// Close stops the background cleanup of the server's session, challenge and
+// rate limit stores.
+func (s *Server) Close() {
+	s.siwe.Close()
+	s.anonAuth.Close()
+	s.gate.Close()
+	for _, l := range []*ratelimit.Limiter{s.limiter, s.challengeLimiter, s.walletLimiter} {
+		if l != nil {
+			l.Stop()
+		}
+	}
+}
+
 // SetThisCardID configures the token ID that grants free tier via ZK proof.
 func (s *Server) SetThisCardID(id int64) {
 	s.thisCardID = id
 }
 
-// Handler returns the HTTP handler with rate limiting and CORS applied.
+// Handler returns the HTTP handler with rate limiting, CORS and security
+// headers applied.
 func (s *Server) Handler() http.Handler {
 	var h http.Handler = s.mux
 	if s.corsOrigin != "" {
 		h = s.corsMiddleware(h)
 	}
+	if s.cfg.SecurityHeaders || s.cfg.HSTSMaxAge > 0 {
+		h = securityHeadersMiddleware(h, s.cfg.SecurityHeaders, s.cfg.HSTSMaxAge)
+	}
 	if s.limiter != nil {
 		h = s.limiter.Wrap(h)
 	}
-	return h
+	return recoverMiddleware(h)
+}
+
+// requestIDHeader carries a per-request ID. A caller-supplied value is kept
+// so gateway logs can be correlated with a fronting proxy.
+const requestIDHeader = "X-Request-ID"
+
+// recoverMiddleware turns a panicking handler into a 500 JSON error instead of
+// tearing down the connection, logging the stack with the request ID.
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqID := r.Header.Get(requestIDHeader)
+		if reqID == "" {
+			reqID = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, reqID)
+
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			log.Printf("Panic serving %s %s (request %s): %v\n%s", r.Method, r.URL.Path, reqID, rec, debug.Stack())
+			writeError(w, http.StatusInternalServerError, "internal server error")
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
 }
 
 // corsMiddleware wraps a handler with CORS headers for the configured origin.
@@ -200,6 +425,28 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// securityHeadersMiddleware sets browser hardening headers, and HSTS when
+// hstsMaxAge > 0. The gateway serves only JSON, so the CSP allows nothing.
+func securityHeadersMiddleware(next http.Handler, hardening bool, hstsMaxAge time.Duration) http.Handler {
+	var hsts string
+	if hstsMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(hstsMaxAge/time.Second), 10)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		if hardening {
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("Referrer-Policy", "no-referrer")
+			h.Set("X-Frame-Options", "DENY")
+			h.Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+		}
+		if hsts != "" {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // ListenAndServe starts the HTTP server.
 func (s *Server) ListenAndServe() error {
 	srv := &http.Server{
@@ -217,14 +464,25 @@ func (s *Server) ListenAndServe() error {
 //                          AUTH HANDLERS
 // =========================================================================
 
-// GET /health
+// GET /health — probes registered dependencies and returns 503 when a
+// critical one is down so load balancers route around this node.
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]any{
-		"status":            "ok",
-		"time":              time.Now().UTC(),
-		"active_sessions":   s.gate.ActiveSessionCount(),
-		"active_peers":      s.wg.PeerCount(),
-		"free_tier_enabled": s.freeTier,
+	status, deps := s.dependencyHealth(r.Context())
+
+	code := http.StatusOK
+	if status == healthStatusDown {
+		code = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, code, map[string]any{
+		"status":             status,
+		"time":               time.Now().UTC(),
+		"active_sessions":    s.gate.ActiveSessionCount(),
+		"active_peers":       s.wg.PeerCount(),
+		"free_tier_enabled":  s.freeTier,
+		"draining":           s.draining(),
+		"provisions_tunnels": s.wg.ProvisionsTunnels(),
+		"dependencies":       deps,
 	})
 }
 
@@ -232,6 +490,15 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 type ChallengeResponse struct {
 	Message string `json:"message"`
 	Nonce   string `json:"nonce"`
+	// Terms are the terms of service that signing Message accepts, so the
+	// client can show them first. Absent when the node has none.
+	Terms *TermsInfo `json:"terms,omitempty"`
+}
+
+// TermsInfo identifies the terms of service a sign-in accepts.
+type TermsInfo struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256,omitempty"` // hex SHA-256 of the terms document, if pinned
 }
 
 // AnonymousChallengeResponse is returned by POST /auth/anonymous/challenge.
@@ -251,28 +518,40 @@ func (s *Server) handleChallenge(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Address string `json:"address"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if !s.decodeJSON(w, r, &req, true) {
 		return
 	}
-	if req.Address == "" {
-		writeError(w, http.StatusBadRequest, "address is required")
+	resp, err := s.newChallenge(req.Address)
+	if err != nil {
+		writeRequestError(w, err)
 		return
 	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// newChallenge issues a SIWE challenge for address.
+func (s *Server) newChallenge(address string) (ChallengeResponse, error) {
+	if address == "" {
+		return ChallengeResponse{}, badRequest("address is required")
+	}
+	if err := allowKey(s.challengeLimiter, addressLimitKey(address)); err != nil {
+		return ChallengeResponse{}, err
+	}
 
 	challenge, err := s.siwe.NewChallenge(s.cfg.NonceLength)
 	if err != nil {
 		log.Printf("Error generating challenge: %v", err)
-		writeError(w, http.StatusInternalServerError, "failed to generate challenge")
-		return
+		return ChallengeResponse{}, &requestError{status: http.StatusInternalServerError, message: "failed to generate challenge"}
 	}
 
-	message := siwe.FormatMessage(challenge, req.Address)
-
-	writeJSON(w, http.StatusOK, ChallengeResponse{
-		Message: message,
+	resp := ChallengeResponse{
+		Message: siwe.FormatMessage(challenge, address),
 		Nonce:   challenge.Nonce,
-	})
+	}
+	if s.terms != nil {
+		resp.Terms = &TermsInfo{URL: s.terms.URL, SHA256: s.terms.SHA256}
+	}
+	return resp, nil
 }
 
 // POST /auth/anonymous/challenge
@@ -280,7 +559,7 @@ func (s *Server) handleChallenge(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleAnonymousChallenge(w http.ResponseWriter, r *http.Request) {
 	if err := s.syncAnonymousPolicyEpoch(r.Context()); err != nil {
 		log.Printf("Error syncing anonymous policy epoch: %v", err)
-		writeError(w, http.StatusServiceUnavailable, "anonymous policy metadata unavailable")
+		writeUnavailable(w, "anonymous policy metadata unavailable")
 		return
 	}
 
@@ -307,6 +586,7 @@ type VerifyResponse struct {
 	SessionToken string `json:"session_token"`
 	Tier         string `json:"tier"`
 	ExpiresAt    string `json:"expires_at"`
+	Probationary bool   `json:"probationary,omitempty"`
 }
 
 // zkProofPayload is an optional ZK proof included in the verify request.
@@ -328,21 +608,63 @@ type verifyRequest struct {
 // Response: { "address": "0x...", "session_token": "<opaque>", "tier": "free|paid|denied", "expires_at": "..." }
 func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
 	var req verifyRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if !s.decodeJSON(w, r, &req, true) {
 		return
 	}
-	if req.Message == "" || req.Signature == "" {
-		writeError(w, http.StatusBadRequest, "message and signature are required")
+	session, err := s.verify(r.Context(), req)
+	if err != nil {
+		writeRequestError(w, err)
 		return
 	}
 
+	writeJSON(w, http.StatusOK, VerifyResponse{
+		Address:      session.Address.Hex(),
+		SessionToken: session.Token,
+		Tier:         session.Tier.String(),
+		ExpiresAt:    session.ExpiresAt.UTC().Format(time.RFC3339),
+		Probationary: session.Probationary,
+	})
+}
+
+// verify checks a signed SIWE message and the wallet's access, and opens a
+// session. Denials are returned as 403 request errors.
+func (s *Server) verify(ctx context.Context, req verifyRequest) (*nftgate.Session, error) {
+	if req.Message == "" || req.Signature == "" {
+		return nil, badRequest("message and signature are required")
+	}
+
 	// Step 1: Verify SIWE signature, recover wallet address
 	signed := &siwe.SignedMessage{Message: req.Message, Signature: req.Signature}
 	auth, err := s.siwe.Verify(signed)
 	if err != nil {
-		writeError(w, http.StatusUnauthorized, err.Error())
-		return
+		if errors.Is(err, siwe.ErrInvalidNonce) || errors.Is(err, siwe.ErrMessageExpired) {
+			return nil, &requestError{status: http.StatusUnauthorized, message: err.Error(), code: errCodeChallengeExpired}
+		}
+		return nil, &requestError{status: http.StatusUnauthorized, message: err.Error()}
+	}
+	if err := allowKey(s.walletLimiter, addressLimitKey(auth.Address.Hex())); err != nil {
+		return nil, err
+	}
+	if s.terms != nil && auth.Terms == nil {
+		s.audit.Log(audit.Record{
+			Event:   audit.EventVerify,
+			Outcome: audit.OutcomeDenied,
+			Address: auth.Address.Hex(),
+			Reason:  "terms_not_accepted",
+		})
+		return nil, &requestError{
+			status:  http.StatusForbidden,
+			message: "signed message does not accept the terms of service at " + s.terms.URL,
+			code:    errCodeTermsNotAccepted,
+		}
+	}
+	denied := &requestError{
+		status:  http.StatusForbidden,
+		message: "access denied",
+		body: VerifyResponse{
+			Address: auth.Address.Hex(),
+			Tier:    nftcheck.TierDenied.String(),
+		},
 	}
 
 	// Step 2: Determine access tier — ZK proof path or on-chain path
@@ -350,89 +672,98 @@ func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
 
 	if req.ZKProof != nil && s.zkClient != nil {
 		// ZK path: forward proof to ZK API for verification
-		zkResult, err := s.zkClient.VerifyProof(r.Context(), zkverify.ProofPayload{
+		zkResult, err := s.zkClient.VerifyProof(ctx, zkverify.ProofPayload{
 			ProofType:     req.ZKProof.ProofType,
 			Proof:         req.ZKProof.Proof,
 			PublicSignals: req.ZKProof.PublicSignals,
 		})
 		if err != nil {
 			log.Printf("ZK API error during proof verification: %v", err)
-			writeError(w, http.StatusBadGateway, "ZK verification service unavailable")
-			return
+			return nil, &requestError{status: http.StatusBadGateway, message: "ZK verification service unavailable"}
 		}
 
 		if !zkResult.Valid {
-			log.Printf("ZK proof invalid: type=%s reason=%s", req.ZKProof.ProofType, zkResult.Reason)
-			writeJSON(w, http.StatusForbidden, VerifyResponse{
-				Address: auth.Address.Hex(),
-				Tier:    nftcheck.TierDenied.String(),
-			})
-			return
+			connlog.Printf("ZK proof invalid: type=%s reason=%s", req.ZKProof.ProofType, zkResult.Reason)
+			s.auditVerify(auth.Address, nftcheck.CheckResult{Source: "zk_proof"}, audit.OutcomeDenied, "zk_proof_invalid", false)
+			return nil, denied
 		}
 
 		// Determine tier from public signals
 		result = nftcheck.CheckResult{
 			Tier:      s.tierFromZKProof(req.ZKProof),
 			CheckedAt: time.Now(),
+			Source:    "zk_proof",
 		}
-		log.Printf("ZK proof valid: type=%s tier=%s", req.ZKProof.ProofType, result.Tier)
+		connlog.Printf("ZK proof valid: type=%s tier=%s", req.ZKProof.ProofType, result.Tier)
 	} else {
 		// On-chain path: existing NFT check
-		result, err = s.checker.Check(r.Context(), auth.Address)
+		result, err = s.checker.Check(ctx, auth.Address)
 		if err != nil {
+			// Contract reverts already come back as a denial; anything
+			// left is the RPC provider failing, which is worth a retry.
 			log.Printf("Error checking NFT access: %v", err)
-			writeError(w, http.StatusInternalServerError, "failed to check NFT access")
-			return
+			return nil, &requestError{status: http.StatusServiceUnavailable, message: "NFT access check unavailable, try again shortly", code: errCodeUnavailable}
 		}
 	}
 
-	// Step 3: Deny if no access
+	// Step 3: Deny if no access, unless the wallet just received a card the
+	// checker does not see yet
 	result.Tier = s.effectiveTier(result.Tier)
+	probation := false
 	if result.Tier == nftcheck.TierDenied {
-		writeJSON(w, http.StatusForbidden, VerifyResponse{
-			Address: auth.Address.Hex(),
-			Tier:    result.Tier.String(),
-		})
-		return
+		graceTier, ok := s.takeGrace(auth.Address)
+		if req.ZKProof != nil || !ok {
+			s.auditVerify(auth.Address, result, audit.OutcomeDenied, "no_access", false)
+			return nil, denied
+		}
+		// Drop the denial just cached so the next sign-in re-checks on-chain
+		s.checker.Invalidate(auth.Address)
+		result.Tier, result.Source = s.effectiveTier(graceTier), "grace_period"
+		probation = true
+		connlog.Printf("Access denied within grace period of a card transfer-in: probationary session for %s", s.cfg.GraceSessionTTL)
 	}
 
 	// Step 3b: Check user rep ban list (if enabled)
 	if s.userRep != nil {
-		repResult, err := s.userRep.CheckRep(r.Context(), auth.Address.Hex())
+		repResult, err := s.userRep.CheckRep(ctx, auth.Address.Hex())
 		if err != nil {
 			log.Printf("Warning: user rep check failed (allowing access): %v", err)
 		} else if repResult.Rating < 0 {
-			log.Printf("Access denied (banned): rep=%d category=%q", repResult.Rating, s.userRep.Category())
-			writeJSON(w, http.StatusForbidden, map[string]string{
-				"address": auth.Address.Hex(),
-				"tier":    "denied",
-				"error":   "wallet banned: negative reputation in VPN User category",
-			})
-			return
+			connlog.Printf("Access denied (banned): rep=%d category=%q", repResult.Rating, s.userRep.Category())
+			s.auditVerify(auth.Address, result, audit.OutcomeDenied, "rep_banned", probation)
+			const reason = "wallet banned: negative reputation in VPN User category"
+			return nil, &requestError{
+				status:  http.StatusForbidden,
+				message: reason,
+				body: map[string]string{
+					"address": auth.Address.Hex(),
+					"tier":    "denied",
+					"error":   reason,
+				},
+			}
 		}
 	}
 
 	// Step 4: Create a session
-	session := s.gate.CreateSession(auth.Address, result.Tier)
+	var session *nftgate.Session
+	if probation {
+		session = s.gate.CreateProbationarySession(auth.Address, result.Tier, s.cfg.GraceSessionTTL)
+	} else {
+		session = s.gate.CreateSession(auth.Address, result.Tier)
+	}
 	if session == nil {
-		writeError(w, http.StatusInternalServerError, "failed to create session")
-		return
+		return nil, &requestError{status: http.StatusInternalServerError, message: "failed to create session"}
 	}
 
 	// Step 5: Record free session on-chain (fire-and-forget).
 	// Paid sessions are opened by the user directly via the contract.
 	if s.sessionMgr != nil && result.Tier == nftcheck.TierFree {
-		s.sessionMgr.OpenFreeSession(auth.Address, uint64(s.cfg.CredentialTTL.Seconds()))
+		s.sessionMgr.OpenFreeSession(auth.Address, session.ID, uint64(s.cfg.CredentialTTL.Seconds()))
 	}
 
-	log.Printf("Access granted: tier=%s", result.Tier)
-
-	writeJSON(w, http.StatusOK, VerifyResponse{
-		Address:      auth.Address.Hex(),
-		SessionToken: session.Token,
-		Tier:         result.Tier.String(),
-		ExpiresAt:    session.ExpiresAt.UTC().Format(time.RFC3339),
-	})
+	connlog.Printf("Access granted: tier=%s", result.Tier)
+	s.auditVerify(auth.Address, result, audit.OutcomeGranted, "", probation)
+	return session, nil
 }
 
 // tierFromZKProof determines the access tier from a validated ZK proof.
@@ -460,7 +791,7 @@ func (s *Server) tierFromZKProof(proof *zkProofPayload) nftcheck.AccessTier {
 // for the frontend to construct the openSession transaction.
 func (s *Server) handleSessionInfo(w http.ResponseWriter, r *http.Request) {
 	if s.sessionMgr == nil {
-		writeError(w, http.StatusServiceUnavailable, "session manager not configured")
+		writeFeatureDisabled(w, "session manager not configured")
 		return
 	}
 	info, err := s.sessionMgr.GetSessionInfo(r.Context())
@@ -476,7 +807,7 @@ func (s *Server) handleSessionInfo(w http.ResponseWriter, r *http.Request) {
 // for the frontend to construct subscribe transactions.
 func (s *Server) handleSubscriptionTiers(w http.ResponseWriter, r *http.Request) {
 	if s.subMgr == nil {
-		writeError(w, http.StatusServiceUnavailable, "subscription manager not configured")
+		writeFeatureDisabled(w, "subscription manager not configured")
 		return
 	}
 	tiers, err := s.subMgr.GetTiers(r.Context())
@@ -522,6 +853,11 @@ type ConnectResponse struct {
 	AllowedIPs      string `json:"allowed_ips"`
 	ExpiresAt       string `json:"expires_at"`
 	Tier            string `json:"tier"`
+
+	AlternateEndpoints  []string `json:"alternate_endpoints,omitempty"`  // e.g. the IPv6 endpoint of a dual-stack node
+	PersistentKeepalive int      `json:"persistent_keepalive,omitempty"` // suggested keepalive interval in seconds
+
+	DNSPolicy *wireguard.DNSPolicy `json:"dns_policy,omitempty"` // operator's DNS assertions; absent if none
 }
 
 // POST /vpn/connect -- provision a WireGuard peer for an authenticated session
@@ -529,126 +865,174 @@ type ConnectResponse struct {
 // Response: WireGuard configuration
 func (s *Server) handleVPNConnect(w http.ResponseWriter, r *http.Request) {
 	var req ConnectRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if !s.decodeJSON(w, r, &req, true) {
 		return
 	}
-	if req.SessionToken == "" || req.PublicKey == "" {
-		writeError(w, http.StatusBadRequest, "session_token and public_key are required")
+
+	grant, err := s.connect(r.Context(), req.SessionToken, req.PublicKey)
+	if err != nil {
+		writeRequestError(w, err)
 		return
 	}
+	writeJSON(w, http.StatusOK, grant.response())
+}
+
+// peerGrant is a provisioned WireGuard peer.
+type peerGrant struct {
+	peer      *wireguard.PeerConfig
+	expiresAt time.Time
+	tier      string // session tier, or "subscription"
+	via       string // what paid for the peer: subscription, paid_session or session
+}
+
+func (g *peerGrant) response() ConnectResponse {
+	return ConnectResponse{
+		ServerPublicKey:     g.peer.ServerPublicKey,
+		ServerEndpoint:      g.peer.ServerEndpoint,
+		ClientAddress:       g.peer.ClientAddress,
+		DNS:                 g.peer.DNS,
+		AllowedIPs:          g.peer.AllowedIPs,
+		AlternateEndpoints:  g.peer.AlternateEndpoints,
+		PersistentKeepalive: g.peer.PersistentKeepalive,
+		DNSPolicy:           g.peer.DNSPolicy,
+		ExpiresAt:           g.expiresAt.UTC().Format(time.RFC3339),
+		Tier:                g.tier,
+	}
+}
+
+// connect provisions pubKey for the session identified by token.
+func (s *Server) connect(ctx context.Context, token, pubKey string) (grant *peerGrant, err error) {
+	if token == "" || pubKey == "" {
+		return nil, badRequest("session_token and public_key are required")
+	}
+	if s.draining() {
+		return nil, errDraining
+	}
 
 	// Validate session
-	session := s.gate.GetSessionByToken(req.SessionToken)
+	session := s.gate.GetSessionByToken(token)
 	if session == nil {
-		writeError(w, http.StatusUnauthorized, "session expired or not found, re-authenticate")
-		return
+		return nil, errSessionNotFound
 	}
-	if !s.claimsPeer(req.PublicKey, session.ID) {
-		writeError(w, http.StatusForbidden, "public key is already bound to another session")
-		return
+	defer func() { s.auditConnect(session, pubKey, grant, err) }()
+	if err := allowKey(s.walletLimiter, sessionLimitKey(session)); err != nil {
+		return nil, err
+	}
+	if !s.claimsPeer(pubKey, session.ID) {
+		return nil, forbidden("public key is already bound to another session")
 	}
 
 	if session.Tier == nftcheck.TierDenied {
-		writeError(w, http.StatusForbidden, "access denied")
-		return
+		return nil, forbidden("access denied")
+	}
+	if !s.regionAllows(session.Tier) {
+		return nil, forbidden(s.regionDeniedMessage())
+	}
+	if reason, blocked := s.quotaBlocks(session); blocked {
+		return nil, forbidden(reason)
+	}
+	if session.AddressBound {
+		// Held until the new peer is owned, so concurrent connects by one
+		// wallet cannot all pass the device limit.
+		defer s.lockWallet(walletKey(session.Address))()
+	}
+	evict, err := s.admitPeer(session, pubKey)
+	if err != nil {
+		return nil, &requestError{status: http.StatusTooManyRequests, message: err.Error()}
 	}
+	// Only make room once the new device is actually connected, so a
+	// connect refused below leaves the wallet's other devices alone.
+	defer func() {
+		if err == nil {
+			s.evictPeers(session, evict)
+		}
+	}()
 
 	// For paid tier, check subscription first, then fall back to 24h session
 	if session.Tier == nftcheck.TierPaid {
 		// Path 1: Check active subscription
 		if s.subMgr != nil {
-			sub, err := s.subMgr.GetSubscription(r.Context(), session.Address)
-			if err == nil && sub.ExpiresAt > uint64(time.Now().Unix()) {
-				remaining := time.Duration(sub.ExpiresAt-uint64(time.Now().Unix())) * time.Second
-				peerCfg, err := s.wg.AddPeer(req.PublicKey, remaining)
+			sub, err := s.subMgr.GetSubscription(ctx, session.Address)
+			if now := uint64(s.now().Unix()); err == nil && sub.ExpiresAt > now {
+				remaining, err := s.probationTTL(session, time.Duration(sub.ExpiresAt-now)*time.Second)
 				if err != nil {
-					log.Printf("Error adding WireGuard peer: %v", err)
-					writeError(w, http.StatusInternalServerError, "failed to provision VPN connection")
-					return
+					return nil, err
 				}
-				expiresAt := time.Now().Add(remaining)
-				s.setPeerOwner(req.PublicKey, session.ID)
-				log.Printf("VPN connected (subscription): remaining=%s", remaining)
-				writeJSON(w, http.StatusOK, ConnectResponse{
-					ServerPublicKey: peerCfg.ServerPublicKey,
-					ServerEndpoint:  peerCfg.ServerEndpoint,
-					ClientAddress:   peerCfg.ClientAddress,
-					DNS:             peerCfg.DNS,
-					AllowedIPs:      peerCfg.AllowedIPs,
-					ExpiresAt:       expiresAt.UTC().Format(time.RFC3339),
-					Tier:            "subscription",
-				})
-				return
+				peerCfg, err := s.wg.AddPeer(pubKey, session.Tier.String(), remaining)
+				if err != nil {
+					return nil, provisionError(err)
+				}
+				s.setPeerOwner(pubKey, session)
+				connlog.Printf("VPN connected (subscription): remaining=%s", remaining)
+				return &peerGrant{peer: peerCfg, expiresAt: s.now().Add(remaining), tier: "subscription", via: "subscription"}, nil
 			}
 		}
 
-		// Path 2: Fall back to 24h session
+		// Path 2: Fall back to 24h session. The peer lives for what is left
+		// of it, not its full duration, so reconnecting late in a session
+		// does not extend it.
 		if s.sessionMgr != nil {
-			sessionID, err := s.sessionMgr.GetActiveSessionID(r.Context(), session.Address)
+			sessionID, err := s.sessionMgr.GetActiveSessionID(ctx, session.Address)
 			if err == nil && sessionID != 0 {
-				onChain, err := s.sessionMgr.GetSession(r.Context(), sessionID)
-				if err == nil && onChain.Payment.Sign() > 0 {
-					peerCfg, err := s.wg.AddPeer(req.PublicKey, time.Duration(onChain.Duration)*time.Second)
+				onChain, err := s.sessionMgr.GetSession(ctx, sessionID)
+				if now := uint64(s.now().Unix()); err == nil && onChain.Active && onChain.Payment.Sign() > 0 && onChain.StartedAt+onChain.Duration > now {
+					duration, err := s.probationTTL(session, time.Duration(onChain.StartedAt+onChain.Duration-now)*time.Second)
+					if err != nil {
+						return nil, err
+					}
+					peerCfg, err := s.wg.AddPeer(pubKey, session.Tier.String(), duration)
 					if err != nil {
-						log.Printf("Error adding WireGuard peer: %v", err)
-						writeError(w, http.StatusInternalServerError, "failed to provision VPN connection")
-						return
+						return nil, provisionError(err)
 					}
-					expiresAt := time.Now().Add(time.Duration(onChain.Duration) * time.Second)
-					s.setPeerOwner(req.PublicKey, session.ID)
-					log.Printf("VPN connected (paid): duration=%ds", onChain.Duration)
-					writeJSON(w, http.StatusOK, ConnectResponse{
-						ServerPublicKey: peerCfg.ServerPublicKey,
-						ServerEndpoint:  peerCfg.ServerEndpoint,
-						ClientAddress:   peerCfg.ClientAddress,
-						DNS:             peerCfg.DNS,
-						AllowedIPs:      peerCfg.AllowedIPs,
-						ExpiresAt:       expiresAt.UTC().Format(time.RFC3339),
-						Tier:            session.Tier.String(),
-					})
-					return
+					s.setPeerOwner(pubKey, session)
+					connlog.Printf("VPN connected (paid): duration=%s", duration)
+					return &peerGrant{peer: peerCfg, expiresAt: s.now().Add(duration), tier: session.Tier.String(), via: "paid_session"}, nil
 				}
 			}
 		}
 
-		writeError(w, http.StatusPaymentRequired, "on-chain payment required for paid tier")
-		return
+		return nil, &requestError{status: http.StatusPaymentRequired, message: "on-chain payment required for paid tier"}
 	}
 
 	// Provision WireGuard peer (free tier or no session manager)
-	peerCfg, err := s.wg.AddPeer(req.PublicKey, time.Until(session.ExpiresAt))
+	ttl, err := s.sessionPeerTTL(session)
 	if err != nil {
-		log.Printf("Error adding WireGuard peer: %v", err)
-		writeError(w, http.StatusInternalServerError, "failed to provision VPN connection")
-		return
+		return nil, err
+	}
+	peerCfg, err := s.wg.AddPeer(pubKey, session.Tier.String(), ttl)
+	if err != nil {
+		return nil, provisionError(err)
 	}
 
-	log.Printf("VPN connected: tier=%s", session.Tier)
-	s.setPeerOwner(req.PublicKey, session.ID)
+	connlog.Printf("VPN connected: tier=%s", session.Tier)
+	s.setPeerOwner(pubKey, session)
+	return &peerGrant{peer: peerCfg, expiresAt: session.ExpiresAt, tier: session.Tier.String(), via: "session"}, nil
+}
 
-	writeJSON(w, http.StatusOK, ConnectResponse{
-		ServerPublicKey: peerCfg.ServerPublicKey,
-		ServerEndpoint:  peerCfg.ServerEndpoint,
-		ClientAddress:   peerCfg.ClientAddress,
-		DNS:             peerCfg.DNS,
-		AllowedIPs:      peerCfg.AllowedIPs,
-		ExpiresAt:       session.ExpiresAt.UTC().Format(time.RFC3339),
-		Tier:            session.Tier.String(),
-	})
+// provisionError maps a failure to add a WireGuard peer to the client's
+// error, logging unexpected ones.
+func provisionError(err error) error {
+	if errors.Is(err, wireguard.ErrNoTunnels) {
+		return errNoTunnels
+	}
+	log.Printf("Error adding WireGuard peer: %v", err)
+	return errProvisionFailed
 }
 
 // POST /vpn/anonymous/connect -- provision a WireGuard peer for an anonymous authenticated session.
 func (s *Server) handleAnonymousVPNConnect(w http.ResponseWriter, r *http.Request) {
 	var req AnonymousConnectRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if !s.decodeJSON(w, r, &req, true) {
 		return
 	}
 	if req.ChallengeID == "" || req.ProofType == "" || req.NullifierHash == "" || req.SessionKeyHash == "" || req.PublicKey == "" {
 		writeError(w, http.StatusBadRequest, "challenge_id, proof_type, nullifier_hash, session_key_hash, and public_key are required")
 		return
 	}
+	if s.draining() {
+		writeRequestError(w, errDraining)
+		return
+	}
 
 	challenge := s.anonAuth.GetChallenge(req.ChallengeID)
 	if challenge == nil {
@@ -660,7 +1044,7 @@ func (s *Server) handleAnonymousVPNConnect(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	if s.zkClient == nil {
-		writeError(w, http.StatusServiceUnavailable, "anonymous verifier not configured")
+		writeFeatureDisabled(w, "anonymous verifier not configured")
 		return
 	}
 
@@ -668,7 +1052,7 @@ func (s *Server) handleAnonymousVPNConnect(w http.ResponseWriter, r *http.Reques
 	if req.ProofType == vpnAccessV1ProofType {
 		if err := s.refreshAnonymousPolicyEpoch(r.Context(), true); err != nil {
 			log.Printf("Error refreshing anonymous policy state: %v", err)
-			writeError(w, http.StatusServiceUnavailable, "anonymous policy metadata unavailable")
+			writeUnavailable(w, "anonymous policy metadata unavailable")
 			return
 		}
 		validated, err := validateVPNAccessV1Signals(challenge, req)
@@ -678,7 +1062,7 @@ func (s *Server) handleAnonymousVPNConnect(w http.ResponseWriter, r *http.Reques
 		}
 		currentRoot := s.currentAnonymousPolicyRoot()
 		if currentRoot == "" {
-			writeError(w, http.StatusServiceUnavailable, "anonymous policy metadata unavailable")
+			writeUnavailable(w, "anonymous policy metadata unavailable")
 			return
 		}
 		if validated.Root != currentRoot {
@@ -699,7 +1083,7 @@ func (s *Server) handleAnonymousVPNConnect(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	if !zkResult.Valid {
-		log.Printf("Anonymous ZK proof invalid: type=%s reason=%s", req.ProofType, zkResult.Reason)
+		connlog.Printf("Anonymous ZK proof invalid: type=%s reason=%s", req.ProofType, zkResult.Reason)
 		writeError(w, http.StatusForbidden, "anonymous proof invalid")
 		return
 	}
@@ -720,12 +1104,16 @@ func (s *Server) handleAnonymousVPNConnect(w http.ResponseWriter, r *http.Reques
 		req.NullifierHash = validatedVPNAccess.NullifierHash
 		req.SessionKeyHash = validatedVPNAccess.SessionKeyHash
 	}
+	if !s.regionAllows(tier) {
+		writeError(w, http.StatusForbidden, s.regionDeniedMessage())
+		return
+	}
 	if !s.anonAuth.ConsumeNullifier(req.NullifierHash, s.cfg.CredentialTTL) {
 		writeError(w, http.StatusConflict, "nullifier already used")
 		return
 	}
 
-	sessionExpiresAt := time.Now().Add(s.cfg.CredentialTTL)
+	sessionExpiresAt := s.now().Add(s.cfg.CredentialTTL)
 	if validatedVPNAccess != nil && validatedVPNAccess.ExpiryBucket.Before(sessionExpiresAt) {
 		sessionExpiresAt = validatedVPNAccess.ExpiryBucket
 	}
@@ -743,20 +1131,38 @@ func (s *Server) handleAnonymousVPNConnect(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	peerCfg, err := s.wg.AddPeer(req.PublicKey, time.Until(session.ExpiresAt))
+	ttl, err := s.sessionPeerTTL(session)
 	if err != nil {
+		// The access proof's expiry bucket is about to close.
 		s.gate.DeleteSessionByID(session.ID)
 		s.anonAuth.ReleaseNullifier(req.NullifierHash)
+		writeRequestError(w, err)
+		return
+	}
+	if !s.claimsPeer(req.PublicKey, session.ID) {
+		s.gate.DeleteSessionByID(session.ID)
+		s.anonAuth.ReleaseNullifier(req.NullifierHash)
+		writeRequestError(w, forbidden("public key is already bound to another session"))
+		return
+	}
+	peerCfg, err := s.wg.AddPeer(req.PublicKey, session.Tier.String(), ttl)
+	if err != nil {
+		s.gate.DeleteSessionByID(session.ID)
+		s.anonAuth.ReleaseNullifier(req.NullifierHash)
+		if errors.Is(err, wireguard.ErrNoTunnels) {
+			writeRequestError(w, errNoTunnels)
+			return
+		}
 		log.Printf("Error adding anonymous WireGuard peer: %v", err)
 		writeError(w, http.StatusInternalServerError, "failed to provision VPN connection")
 		return
 	}
 
 	s.anonAuth.DeleteChallenge(req.ChallengeID)
-	s.setPeerOwner(req.PublicKey, session.ID)
-	log.Printf("VPN connected: anonymous tier=%s epoch=%d", session.Tier, session.PolicyEpoch)
+	s.setPeerOwner(req.PublicKey, session)
+	connlog.Printf("VPN connected: anonymous tier=%s epoch=%d", session.Tier, session.PolicyEpoch)
 
-	writeJSON(w, http.StatusOK, map[string]any{
+	resp := map[string]any{
 		"session_token":     session.Token,
 		"server_public_key": peerCfg.ServerPublicKey,
 		"server_endpoint":   peerCfg.ServerEndpoint,
@@ -765,58 +1171,205 @@ func (s *Server) handleAnonymousVPNConnect(w http.ResponseWriter, r *http.Reques
 		"allowed_ips":       peerCfg.AllowedIPs,
 		"expires_at":        session.ExpiresAt.UTC().Format(time.RFC3339),
 		"tier":              session.Tier.String(),
-	})
+
+		"persistent_keepalive": peerCfg.PersistentKeepalive,
+	}
+	if len(peerCfg.AlternateEndpoints) > 0 {
+		resp["alternate_endpoints"] = peerCfg.AlternateEndpoints
+	}
+	if peerCfg.DNSPolicy != nil {
+		resp["dns_policy"] = peerCfg.DNSPolicy
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // POST /vpn/disconnect -- remove a WireGuard peer
 // Request: { "session_token": "<opaque-token>", "public_key": "base64-wg-pubkey" }
+// A wallet's session may remove any of the wallet's peers, e.g. one found
+// with GET /vpn/sessions that was connected under an earlier sign-in.
+// Without public_key, every peer the session's wallet (or, for anonymous
+// sessions, the session itself) provisioned is removed.
 func (s *Server) handleVPNDisconnect(w http.ResponseWriter, r *http.Request) {
 	var req ConnectRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if !s.decodeJSON(w, r, &req, true) {
 		return
 	}
-	if req.SessionToken == "" || req.PublicKey == "" {
-		writeError(w, http.StatusBadRequest, "session_token and public_key are required")
+
+	removed, err := s.disconnect(r.Context(), req.SessionToken, req.PublicKey)
+	if err != nil {
+		writeRequestError(w, err)
 		return
 	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "disconnected", "peers_removed": removed})
+}
 
-	session := s.gate.GetSessionByToken(req.SessionToken)
+// disconnect removes pubKey, or every peer of the session when pubKey is
+// empty, and returns how many peers were removed.
+func (s *Server) disconnect(ctx context.Context, token, pubKey string) (int, error) {
+	if token == "" {
+		return 0, badRequest("session_token is required")
+	}
+
+	session := s.gate.GetSessionByToken(token)
+	if session == nil {
+		return 0, errSessionNotFound
+	}
+	if err := allowKey(s.walletLimiter, sessionLimitKey(session)); err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	if pubKey != "" {
+		if !s.peerOwnedBy(pubKey, session.ID) && !s.peerOwnedByWallet(pubKey, session) {
+			return 0, forbidden("public key is not owned by this session")
+		}
+		if err := s.wg.RemovePeer(pubKey); err != nil {
+			return 0, &requestError{status: http.StatusNotFound, message: err.Error()}
+		}
+		s.deletePeerOwner(pubKey)
+		removed = 1
+	} else {
+		if session.AddressBound {
+			removed = s.removeWalletPeers(session.Address)
+		} else {
+			removed = s.removeSessionPeers(session.ID)
+		}
+		if removed == 0 {
+			return 0, &requestError{status: http.StatusNotFound, message: "no connected peers for this session"}
+		}
+	}
+	s.closeOnChainSession(ctx, session)
+	return removed, nil
+}
+
+// POST /vpn/disconnect-all -- remove every WireGuard peer the session's
+// wallet has connected, on any device ("log out everywhere").
+// Request: { "session_token": "<opaque-token>" } or Authorization: Bearer <opaque-token>
+func (s *Server) handleVPNDisconnectAll(w http.ResponseWriter, r *http.Request) {
+	token := bearerToken(r)
+	if token == "" {
+		var req ConnectRequest
+		if !s.decodeJSON(w, r, &req, true) {
+			return
+		}
+		token = req.SessionToken
+	}
+	if token == "" {
+		writeError(w, http.StatusBadRequest, "session_token is required")
+		return
+	}
+
+	session := s.gate.GetSessionByToken(token)
 	if session == nil {
 		writeError(w, http.StatusUnauthorized, "session expired or not found, re-authenticate")
 		return
 	}
-	if !s.peerOwnedBy(req.PublicKey, session.ID) {
-		writeError(w, http.StatusForbidden, "public key is not owned by this session")
+	if err := allowKey(s.walletLimiter, sessionLimitKey(session)); err != nil {
+		writeRequestError(w, err)
 		return
 	}
 
-	if err := s.wg.RemovePeer(req.PublicKey); err != nil {
-		writeError(w, http.StatusNotFound, err.Error())
+	var removed int
+	if session.AddressBound {
+		removed = s.removeWalletPeers(session.Address)
+	} else {
+		removed = s.removeSessionPeers(session.ID)
+	}
+	if removed > 0 {
+		s.closeOnChainSession(r.Context(), session)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "disconnected", "peers_removed": removed})
+}
+
+// RekeyRequest is the body for POST /vpn/rekey.
+type RekeyRequest struct {
+	SessionToken string `json:"session_token"`  // Opaque session token from /auth/verify
+	OldPublicKey string `json:"old_public_key"` // WireGuard public key being retired
+	NewPublicKey string `json:"new_public_key"` // WireGuard public key replacing it
+}
+
+// POST /vpn/rekey -- move a connected peer to a new WireGuard key
+// Request: { "session_token": "<opaque-token>", "old_public_key": "...", "new_public_key": "..." }
+// Response: WireGuard configuration with the same address and expiry. The
+// peer's remaining time is kept rather than re-checked, so a client can
+// rotate keys without signing in again or paying twice.
+func (s *Server) handleVPNRekey(w http.ResponseWriter, r *http.Request) {
+	var req RekeyRequest
+	if !s.decodeJSON(w, r, &req, true) {
 		return
 	}
-	s.deletePeerOwner(req.PublicKey)
 
-	// Close on-chain session (fire-and-forget) — skip for subscribers
-	// (subscription stays valid; user can reconnect freely)
-	if !session.AddressBound {
-		writeJSON(w, http.StatusOK, map[string]string{"status": "disconnected"})
+	resp, err := s.rekey(req.SessionToken, req.OldPublicKey, req.NewPublicKey)
+	if err != nil {
+		writeRequestError(w, err)
 		return
 	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// rekey replaces the session's peer oldKey with newKey.
+func (s *Server) rekey(token, oldKey, newKey string) (*ConnectResponse, error) {
+	if token == "" || oldKey == "" || newKey == "" {
+		return nil, badRequest("session_token, old_public_key and new_public_key are required")
+	}
+	if oldKey == newKey {
+		return nil, badRequest("new_public_key must differ from old_public_key")
+	}
+
+	session := s.gate.GetSessionByToken(token)
+	if session == nil {
+		return nil, errSessionNotFound
+	}
+	if err := allowKey(s.walletLimiter, sessionLimitKey(session)); err != nil {
+		return nil, err
+	}
+	if !s.peerOwnedBy(oldKey, session.ID) {
+		return nil, forbidden("old public key is not owned by this session")
+	}
+	if !s.claimsPeer(newKey, session.ID) {
+		return nil, forbidden("new public key is already bound to another session")
+	}
+	if s.wg.GetPeer(oldKey) == nil {
+		// Already expired out of the interface by the cleanup worker.
+		s.deletePeerOwner(oldKey)
+		return nil, &requestError{status: http.StatusNotFound, message: "peer not found, connect again"}
+	}
+	if s.wg.GetPeer(newKey) != nil {
+		return nil, &requestError{status: http.StatusConflict, message: "new public key is already connected"}
+	}
 
-	addr := session.Address
-	isSubscriber := false
+	peerCfg, err := s.wg.RekeyPeer(oldKey, newKey)
+	if err != nil {
+		log.Printf("Error rekeying WireGuard peer: %v", err)
+		return nil, errProvisionFailed
+	}
+	s.renamePeerOwner(oldKey, newKey)
+	s.auditRekey(session, oldKey, newKey)
+
+	var expiresAt time.Time
+	if peer := s.wg.GetPeer(newKey); peer != nil {
+		expiresAt = peer.ExpiresAt
+	}
+	connlog.Printf("VPN peer rekeyed: tier=%s", session.Tier)
+	grant := peerGrant{peer: peerCfg, expiresAt: expiresAt, tier: session.Tier.String()}
+	resp := grant.response()
+	return &resp, nil
+}
+
+// closeOnChainSession closes the wallet's on-chain session (fire-and-forget)
+// after a disconnect. Subscribers are skipped: their subscription stays
+// valid and they can reconnect freely.
+func (s *Server) closeOnChainSession(ctx context.Context, session *nftgate.Session) {
+	if !session.AddressBound || s.sessionMgr == nil {
+		return
+	}
 	if s.subMgr != nil {
-		active, err := s.subMgr.HasActiveSubscription(r.Context(), addr)
+		active, err := s.subMgr.HasActiveSubscription(ctx, session.Address)
 		if err == nil && active {
-			isSubscriber = true
+			return
 		}
 	}
-	if !isSubscriber && s.sessionMgr != nil {
-		s.sessionMgr.CloseSessionFor(addr)
-	}
-
-	writeJSON(w, http.StatusOK, map[string]string{"status": "disconnected"})
+	s.sessionMgr.CloseSessionFor(session.Address)
 }
 
 func bearerToken(r *http.Request) string {
@@ -842,20 +1395,44 @@ func (s *Server) handleVPNStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	st := s.sessionStatus(token)
+	resp := map[string]any{"connected": st.connected}
+	if st.session != nil {
+		resp["tier"] = st.session.Tier.String()
+		resp["expires_at"] = st.session.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if st.reason != "" {
+		resp["reason"] = st.reason
+	}
+	if st.quota != nil {
+		resp["quota"] = st.quota
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// vpnStatus is the state reported by GET /vpn/status.
+type vpnStatus struct {
+	session   *nftgate.Session // nil when the token matches no session
+	connected bool
+	reason    string
+	quota     *quota.Usage
+}
+
+func (s *Server) sessionStatus(token string) vpnStatus {
 	session := s.gate.GetSessionByToken(token)
 	if session == nil {
-		writeJSON(w, http.StatusOK, map[string]any{
-			"connected": false,
-			"reason":    "no active session",
-		})
-		return
+		return vpnStatus{reason: "no active session"}
 	}
 
-	writeJSON(w, http.StatusOK, map[string]any{
-		"connected":  true,
-		"tier":       session.Tier.String(),
-		"expires_at": session.ExpiresAt.UTC().Format(time.RFC3339),
-	})
+	st := vpnStatus{session: session, connected: true}
+	if usage := s.sessionQuota(session); usage != nil {
+		st.quota = usage
+		if reason, blocked := s.quotaBlocks(session); blocked {
+			st.connected = false
+			st.reason = reason
+		}
+	}
+	return st
 }
 
 // =========================================================================
@@ -864,33 +1441,35 @@ func (s *Server) handleVPNStatus(w http.ResponseWriter, r *http.Request) {
 
 // NodeResponse is a public-facing node representation.
 type NodeResponse struct {
-	Operator       string `json:"operator"`
-	Endpoint       string `json:"endpoint"`
-	WgPubKey       string `json:"wg_pub_key"`
-	Region         string `json:"region"`
-	CardEligible   bool   `json:"card_eligible"` // whether operator holds the required card
+	Operator string `json:"operator"`
+	Endpoint string `json:"endpoint"`
+	WgPubKey string `json:"wg_pub_key"`
+	Region   string `json:"region"`
+	// RegionVerified is set when the node's endpoint geolocates to the
+	// country its declared region names; GeoCountry is where it geolocated
+	// to, absent until looked up or when geolocation is not enabled.
+	RegionVerified bool   `json:"region_verified"`
+	GeoCountry     string `json:"geo_country,omitempty"`
+	RequiredTier   string `json:"required_tier,omitempty"` // "free" for regions reserved to THIS-card holders
+	CardEligible   bool   `json:"card_eligible"`           // whether operator holds the required card
 	Active         bool   `json:"active"`
 	RailgunAddress string `json:"railgun_address,omitempty"` // RAILGUN 0zk address
+	GatewayVersion string `json:"gateway_version,omitempty"` // from the node's GET /version; empty until probed
+
+	DNSPolicy *wireguard.DNSPolicy `json:"dns_policy,omitempty"` // also from GET /version; absent until probed or if none
+	Quality   *feedback.Quality    `json:"quality,omitempty"`    // also from GET /version; absent until the node has enough feedback
 }
 
 // GET /nodes — list all active VPN nodes from the on-chain registry.
 // Only returns nodes whose operators hold the required card.
 // TODO(prod-scale): Move to paginated/indexed node reads before large-node mainnet rollout.
 func (s *Server) handleListNodes(w http.ResponseWriter, r *http.Request) {
-	if s.registry == nil {
-		writeError(w, http.StatusServiceUnavailable, "node registry not configured")
-		return
-	}
-
-	nodes, err := s.registry.GetActiveNodes(r.Context())
+	resp, err := s.listNodes(r.Context(), "")
 	if err != nil {
-		log.Printf("Error fetching active nodes: %v", err)
-		writeError(w, http.StatusInternalServerError, "failed to fetch nodes")
+		writeRequestError(w, err)
 		return
 	}
 
-	resp := s.enrichNodesWithCardCheck(r.Context(), nodes)
-
 	writeJSON(w, http.StatusOK, map[string]any{
 		"nodes": resp,
 		"count": len(resp),
@@ -898,11 +1477,26 @@ func (s *Server) handleListNodes(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GET /network/stats — aggregate sessions, peers and reachability across
+// the registry's active nodes, from the collector's latest poll.
+func (s *Server) handleNetworkStats(w http.ResponseWriter, r *http.Request) {
+	if s.networkStats == nil {
+		writeFeatureDisabled(w, "network stats not configured")
+		return
+	}
+	stats := s.networkStats.Stats()
+	if stats == nil {
+		writeUnavailable(w, "network stats not collected yet")
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
 // GET /nodes/region?region=us-east — list active nodes in a region.
 // TODO(prod-scale): Move to paginated/indexed node reads before large-node mainnet rollout.
 func (s *Server) handleListNodesByRegion(w http.ResponseWriter, r *http.Request) {
 	if s.registry == nil {
-		writeError(w, http.StatusServiceUnavailable, "node registry not configured")
+		writeFeatureDisabled(w, "node registry not configured")
 		return
 	}
 
@@ -912,15 +1506,12 @@ func (s *Server) handleListNodesByRegion(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	nodes, err := s.registry.GetActiveNodesByRegion(r.Context(), region)
+	resp, err := s.listNodes(r.Context(), region)
 	if err != nil {
-		log.Printf("Error fetching nodes for region %s: %v", region, err)
-		writeError(w, http.StatusInternalServerError, "failed to fetch nodes")
+		writeRequestError(w, err)
 		return
 	}
 
-	resp := s.enrichNodesWithCardCheck(r.Context(), nodes)
-
 	writeJSON(w, http.StatusOK, map[string]any{
 		"nodes":  resp,
 		"count":  len(resp),
@@ -929,6 +1520,30 @@ func (s *Server) handleListNodesByRegion(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// listNodes returns the card-eligible active nodes, in region if it is set.
+func (s *Server) listNodes(ctx context.Context, region string) ([]NodeResponse, error) {
+	if s.registry == nil {
+		return nil, &requestError{status: http.StatusServiceUnavailable, message: "node registry not configured", code: errCodeFeatureDisabled}
+	}
+
+	var nodes []noderegistry.Node
+	var err error
+	if region == "" {
+		nodes, err = s.registry.GetActiveNodes(ctx)
+	} else {
+		nodes, err = s.registry.GetActiveNodesByRegion(ctx, region)
+	}
+	if err != nil {
+		if region == "" {
+			log.Printf("Error fetching active nodes: %v", err)
+		} else {
+			log.Printf("Error fetching nodes for region %s: %v", region, err)
+		}
+		return nil, &requestError{status: http.StatusInternalServerError, message: "failed to fetch nodes"}
+	}
+	return s.enrichNodesWithCardCheck(ctx, nodes), nil
+}
+
 // enrichNodesWithCardCheck checks on-chain card ownership for each node and filters out ineligible.
 func (s *Server) enrichNodesWithCardCheck(ctx context.Context, nodes []noderegistry.Node) []NodeResponse {
 	var eligible []NodeResponse
@@ -940,6 +1555,9 @@ func (s *Server) enrichNodesWithCardCheck(ctx context.Context, nodes []noderegis
 			Region:   n.Region,
 			Active:   n.Active,
 		}
+		if s.cfg.RegionTiers != nil {
+			nr.RequiredTier = s.cfg.RegionTiers[n.Region]
+		}
 
 		// Check on-chain card ownership via NodeRegistry.isEligibleOperator
 		cardOk, err := s.registry.IsEligibleOperator(ctx, n.Operator)
@@ -958,6 +1576,21 @@ func (s *Server) enrichNodesWithCardCheck(ctx context.Context, nodes []noderegis
 			}
 		}
 
+		if s.nodeVersions != nil {
+			// A discovery-only gateway in the registry cannot take a
+			// connect; leave it out so clients do not pick it.
+			if s.nodeVersions.DiscoveryOnly(n.Endpoint) {
+				continue
+			}
+			nr.GatewayVersion = s.nodeVersions.Version(n.Endpoint)
+			nr.DNSPolicy = s.nodeVersions.DNSPolicy(n.Endpoint)
+			nr.Quality = s.nodeVersions.Quality(n.Endpoint)
+		}
+		if s.nodeRegions != nil {
+			nr.GeoCountry = s.nodeRegions.Country(n.Endpoint)
+			nr.RegionVerified = noderegistry.RegionMatchesCountry(n.Region, nr.GeoCountry)
+		}
+
 		// Only include card-eligible nodes in the response
 		if nr.CardEligible {
 			eligible = append(eligible, nr)
@@ -966,6 +1599,16 @@ func (s *Server) enrichNodesWithCardCheck(ctx context.Context, nodes []noderegis
 	return eligible
 }
 
+// regionAllows reports whether a session of the given tier may connect in
+// this gateway's region. Free-only regions turn away paid-tier sessions.
+func (s *Server) regionAllows(tier nftcheck.AccessTier) bool {
+	return !s.cfg.RegionRequiresFree(s.cfg.Region) || tier == nftcheck.TierFree
+}
+
+func (s *Server) regionDeniedMessage() string {
+	return fmt.Sprintf("region %s is reserved for free-tier (THIS card) holders", s.cfg.Region)
+}
+
 // =========================================================================
 //                          PAYOUT HANDLERS
 // =========================================================================
@@ -1016,6 +1659,74 @@ func (s *Server) handlePayoutStatus(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// =========================================================================
+//                          OPERATOR REP HANDLERS
+// =========================================================================
+
+// GET /operator/{addr}/rep?page=&page_size=&order= — returns one page of the
+// community members who gave the operator rep, plus the total rater count.
+func (s *Server) handleOperatorRep(w http.ResponseWriter, r *http.Request) {
+	operatorHex, ok := operatorFromRepPath(r.URL.Path)
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if !common.IsHexAddress(operatorHex) {
+		writeError(w, http.StatusBadRequest, "invalid operator address")
+		return
+	}
+	if s.operatorRep == nil {
+		writeFeatureDisabled(w, "operator rep not configured")
+		return
+	}
+
+	q := rep6529.BreakdownQuery{Order: r.URL.Query().Get("order")}
+	if v := r.URL.Query().Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			writeError(w, http.StatusBadRequest, "page must be a positive integer")
+			return
+		}
+		q.Page = page
+	}
+	if v := r.URL.Query().Get("page_size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < 1 {
+			writeError(w, http.StatusBadRequest, "page_size must be a positive integer")
+			return
+		}
+		q.PageSize = size
+	}
+
+	operator := common.HexToAddress(operatorHex)
+	page, err := s.operatorRep.GetRepBreakdownPage(r.Context(), operator.Hex(), q)
+	if err != nil {
+		log.Printf("Error fetching rep breakdown: %v", err)
+		writeError(w, http.StatusBadGateway, "6529 rep API unavailable")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"operator":  operator.Hex(),
+		"category":  s.operatorRep.Category(),
+		"data":      page.Data,
+		"count":     page.Count,
+		"page":      page.Page,
+		"page_size": page.PageSize,
+		"next":      page.Next,
+	})
+}
+
+// operatorFromRepPath extracts {addr} from /operator/{addr}/rep.
+func operatorFromRepPath(path string) (string, bool) {
+	rest := strings.Trim(strings.TrimPrefix(path, "/operator/"), "/")
+	addr, suffix, found := strings.Cut(rest, "/")
+	if !found || suffix != "rep" || addr == "" {
+		return "", false
+	}
+	return addr, true
+}
+
 // =========================================================================
 //                          HELPERS
 // =========================================================================
@@ -1030,6 +1741,156 @@ func writeError(w http.ResponseWriter, status int, message string) {
 	writeJSON(w, status, map[string]string{"error": message})
 }
 
+// decodeJSON reads a single JSON value from the request body into dst. It
+// answers 413 for bodies over the configured limit and 400 for malformed
+// JSON, trailing data or, when strict is set, fields dst does not have, and
+// reports whether decoding succeeded.
+func (s *Server) decodeJSON(w http.ResponseWriter, r *http.Request, dst any, strict bool) bool {
+	limit := int64(config.DefaultMaxBodyBytes)
+	if s.cfg != nil && s.cfg.MaxBodyBytes > 0 {
+		limit = s.cfg.MaxBodyBytes
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+	dec := json.NewDecoder(r.Body)
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	err := dec.Decode(dst)
+	if err == nil && dec.Decode(&json.RawMessage{}) != io.EOF {
+		err = errors.New("unexpected data after JSON body")
+	}
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+		return false
+	}
+	writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+	return false
+}
+
+// Error codes attached to 503 responses so clients can tell a feature that
+// this gateway does not offer apart from a dependency that is briefly down.
+// errCodeChallengeExpired marks a 401 for a SIWE challenge that expired or
+// was already used, which the client fixes by signing a fresh one, and
+// errCodeSessionExpired a 401 for a session too close to expiry to connect
+// on, which it fixes by signing in again. errCodeDraining marks a 503 from a
+// node that is draining for maintenance: try another node.
+// errCodeTermsNotAccepted marks a 403 for a signed message that left out the
+// terms of service clause; the client signs the challenge message as issued.
+const (
+	errCodeFeatureDisabled  = "feature_disabled"
+	errCodeUnavailable      = "temporarily_unavailable"
+	errCodeChallengeExpired = "challenge_expired"
+	errCodeSessionExpired   = "session_expired"
+	errCodeDraining         = "node_draining"
+	errCodeTermsNotAccepted = "terms_not_accepted"
+)
+
+// unavailableRetryAfter is the Retry-After hint (seconds) sent with transient 503s.
+const unavailableRetryAfter = "30"
+
+// writeFeatureDisabled reports that an optional feature is not configured on
+// this gateway. Clients should hide the feature rather than retry.
+func writeFeatureDisabled(w http.ResponseWriter, message string) {
+	writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+		"error": message,
+		"code":  errCodeFeatureDisabled,
+	})
+}
+
+// writeUnavailable reports a transient dependency failure. Clients may retry
+// after the Retry-After interval.
+func writeUnavailable(w http.ResponseWriter, message string) {
+	w.Header().Set("Retry-After", unavailableRetryAfter)
+	writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+		"error": message,
+		"code":  errCodeUnavailable,
+	})
+}
+
+// requestError is a request failure and the HTTP status it maps to. The gRPC
+// service translates the status to a gRPC code.
+type requestError struct {
+	status  int
+	message string
+	code    string // one of the errCode constants; empty for none
+	body    any    // HTTP response body to send instead of {"error": message}
+}
+
+func (e *requestError) Error() string { return e.message }
+
+var (
+	errSessionNotFound = &requestError{status: http.StatusUnauthorized, message: "session expired or not found, re-authenticate"}
+	errProvisionFailed = &requestError{status: http.StatusInternalServerError, message: "failed to provision VPN connection"}
+	errSessionExpiring = &requestError{status: http.StatusUnauthorized, message: "session expires too soon to connect, re-authenticate", code: errCodeSessionExpired}
+	errRateLimited     = &requestError{status: http.StatusTooManyRequests, message: "rate limit exceeded"}
+	errNoTunnels       = &requestError{status: http.StatusServiceUnavailable, message: "this gateway does not provision tunnels; connect through a node listed at /nodes", code: errCodeFeatureDisabled}
+)
+
+func badRequest(message string) *requestError {
+	return &requestError{status: http.StatusBadRequest, message: message}
+}
+
+func forbidden(message string) *requestError {
+	return &requestError{status: http.StatusForbidden, message: message}
+}
+
+// writeRequestError writes err as an HTTP error response. Errors that are not
+// a *requestError become a 500.
+func writeRequestError(w http.ResponseWriter, err error) {
+	var re *requestError
+	if !errors.As(err, &re) {
+		writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	switch {
+	case re.body != nil:
+		writeJSON(w, re.status, re.body)
+	case re.code == errCodeFeatureDisabled:
+		writeFeatureDisabled(w, re.message)
+	case re.code == errCodeUnavailable:
+		writeUnavailable(w, re.message)
+	case re.status == http.StatusTooManyRequests:
+		w.Header().Set("Retry-After", "60")
+		writeError(w, re.status, re.message)
+	case re.code != "":
+		writeJSON(w, re.status, map[string]string{"error": re.message, "code": re.code})
+	default:
+		writeError(w, re.status, re.message)
+	}
+}
+
+// allowKey charges one request to key on an identity limiter (challenge or
+// wallet). Unlike the per-IP limiter, these follow a wallet across IPs and
+// don't let users behind one NAT use up each other's allowance. A nil
+// limiter allows everything.
+func allowKey(l *ratelimit.Limiter, key string) error {
+	if l == nil || l.Allow(key) {
+		return nil
+	}
+	return errRateLimited
+}
+
+// addressLimitKey normalizes an address so checksum and lower-case spellings
+// share one allowance.
+func addressLimitKey(address string) string {
+	return "addr:" + strings.ToLower(strings.TrimSpace(address))
+}
+
+// sessionLimitKey is the wallet of an address-bound session, or the
+// nullifier of an anonymous one.
+func sessionLimitKey(session *nftgate.Session) string {
+	if session.AddressBound {
+		return addressLimitKey(session.Address.Hex())
+	}
+	return "nullifier:" + session.NullifierHash
+}
+
 func (s *Server) effectiveTier(tier nftcheck.AccessTier) nftcheck.AccessTier {
 	if tier == nftcheck.TierFree && !s.freeTier {
 		return nftcheck.TierPaid
@@ -1190,34 +2051,279 @@ func policyEpochFromMetadata(metadata map[string]any) (uint64, error) {
 	}
 }
 
+// peerOwner records which session provisioned a WireGuard peer.
+type peerOwner struct {
+	sessionID string
+	wallet    string // lowercase hex address; empty for anonymous sessions
+}
+
+// walletLock serializes one wallet's connects; refs counts the holders and
+// waiters, so the lock is dropped once none are left.
+type walletLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lockWallet locks wallet's connects and returns the func that unlocks them.
+func (s *Server) lockWallet(wallet string) func() {
+	s.walletLocksMu.Lock()
+	if s.walletLocks == nil {
+		s.walletLocks = make(map[string]*walletLock)
+	}
+	l := s.walletLocks[wallet]
+	if l == nil {
+		l = &walletLock{}
+		s.walletLocks[wallet] = l
+	}
+	l.refs++
+	s.walletLocksMu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		s.walletLocksMu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(s.walletLocks, wallet)
+		}
+		s.walletLocksMu.Unlock()
+	}
+}
+
+// errPeerLimit is returned by admitPeer when a wallet already has as many
+// devices connected as its tier allows and the policy is to reject.
+var errPeerLimit = errors.New("device limit reached for this wallet, disconnect another device first")
+
+func walletKey(addr common.Address) string {
+	return strings.ToLower(addr.Hex())
+}
+
 func (s *Server) claimsPeer(pubKey string, ownerID string) bool {
 	s.peerMu.Lock()
 	defer s.peerMu.Unlock()
-	if existing, ok := s.peerOwners[pubKey]; ok && existing != ownerID {
+	if existing, ok := s.peerOwners[pubKey]; ok && existing.sessionID != ownerID {
 		return false
 	}
 	return true
 }
 
-func (s *Server) setPeerOwner(pubKey string, ownerID string) {
+func (s *Server) setPeerOwner(pubKey string, session *nftgate.Session) {
+	owner := peerOwner{sessionID: session.ID}
+	if session.AddressBound {
+		owner.wallet = walletKey(session.Address)
+	}
+
 	s.peerMu.Lock()
-	s.peerOwners[pubKey] = ownerID
-	s.peerMu.Unlock()
+	defer s.peerMu.Unlock()
+	s.deletePeerOwnerLocked(pubKey)
+	s.peerOwners[pubKey] = owner
+	if s.sessionPeers == nil {
+		s.sessionPeers = make(map[string]int)
+	}
+	s.sessionPeers[owner.sessionID]++
+	if owner.wallet != "" {
+		s.walletPeers[owner.wallet] = append(s.walletPeers[owner.wallet], pubKey)
+	}
+}
+
+// renamePeerOwner moves oldKey's owner to newKey, keeping its place in the
+// wallet's oldest-first peer list.
+func (s *Server) renamePeerOwner(oldKey, newKey string) {
+	s.peerMu.Lock()
+	defer s.peerMu.Unlock()
+	owner, ok := s.peerOwners[oldKey]
+	if !ok {
+		return
+	}
+	delete(s.peerOwners, oldKey)
+	s.peerOwners[newKey] = owner
+	if owner.wallet == "" {
+		return
+	}
+	for i, k := range s.walletPeers[owner.wallet] {
+		if k == oldKey {
+			s.walletPeers[owner.wallet][i] = newKey
+			break
+		}
+	}
+}
+
+// sessionInUse reports whether session owns WireGuard peers, or is the
+// wallet's session and the wallet does, so the session cap keeps it.
+func (s *Server) sessionInUse(session *nftgate.Session) bool {
+	s.peerMu.RLock()
+	defer s.peerMu.RUnlock()
+	if s.sessionPeers[session.ID] > 0 {
+		return true
+	}
+	return session.AddressBound && len(s.walletPeers[walletKey(session.Address)]) > 0
 }
 
 func (s *Server) peerOwnedBy(pubKey string, ownerID string) bool {
 	s.peerMu.RLock()
 	defer s.peerMu.RUnlock()
 	existing, ok := s.peerOwners[pubKey]
-	return ok && existing == ownerID
+	return ok && existing.sessionID == ownerID
+}
+
+// peerOwnedByWallet reports whether pubKey was provisioned by the wallet of
+// an address-bound session, under this or an earlier session.
+func (s *Server) peerOwnedByWallet(pubKey string, session *nftgate.Session) bool {
+	if !session.AddressBound {
+		return false
+	}
+	s.peerMu.RLock()
+	defer s.peerMu.RUnlock()
+	existing, ok := s.peerOwners[pubKey]
+	return ok && existing.wallet == walletKey(session.Address)
 }
 
 func (s *Server) deletePeerOwner(pubKey string) {
 	s.peerMu.Lock()
-	delete(s.peerOwners, pubKey)
+	s.deletePeerOwnerLocked(pubKey)
 	s.peerMu.Unlock()
 }
 
+func (s *Server) deletePeerOwnerLocked(pubKey string) {
+	owner, ok := s.peerOwners[pubKey]
+	if !ok {
+		return
+	}
+	delete(s.peerOwners, pubKey)
+	if n := s.sessionPeers[owner.sessionID]; n > 1 {
+		s.sessionPeers[owner.sessionID] = n - 1
+	} else {
+		delete(s.sessionPeers, owner.sessionID)
+	}
+	if owner.wallet == "" {
+		return
+	}
+
+	keys := s.walletPeers[owner.wallet]
+	for i, k := range keys {
+		if k == pubKey {
+			keys = append(keys[:i:i], keys[i+1:]...)
+			break
+		}
+	}
+	if len(keys) == 0 {
+		delete(s.walletPeers, owner.wallet)
+	} else {
+		s.walletPeers[owner.wallet] = keys
+	}
+}
+
+// walletPeerKeys returns the public keys of the WireGuard peers provisioned
+// by wallet, oldest first.
+func (s *Server) walletPeerKeys(wallet common.Address) []string {
+	s.peerMu.RLock()
+	defer s.peerMu.RUnlock()
+	return append([]string(nil), s.walletPeers[walletKey(wallet)]...)
+}
+
+// sessionPeerKeys returns the public keys of the WireGuard peers provisioned
+// by the session.
+func (s *Server) sessionPeerKeys(sessionID string) []string {
+	s.peerMu.RLock()
+	defer s.peerMu.RUnlock()
+	var keys []string
+	for pubKey, owner := range s.peerOwners {
+		if owner.sessionID == sessionID {
+			keys = append(keys, pubKey)
+		}
+	}
+	return keys
+}
+
+// removeWalletPeers tears down every WireGuard peer provisioned by wallet and
+// returns how many were removed from the interface.
+func (s *Server) removeWalletPeers(wallet common.Address) int {
+	removed := 0
+	for _, pubKey := range s.walletPeerKeys(wallet) {
+		if err := s.wg.RemovePeer(pubKey); err != nil {
+			log.Printf("Error removing WireGuard peer: %v", err)
+		} else {
+			removed++
+		}
+		s.deletePeerOwner(pubKey)
+	}
+	return removed
+}
+
+// removeSessionPeers tears down every WireGuard peer provisioned by the
+// session and returns how many were removed from the interface. Used for
+// anonymous sessions, which have no wallet to group peers by.
+func (s *Server) removeSessionPeers(sessionID string) int {
+	removed := 0
+	for _, pubKey := range s.sessionPeerKeys(sessionID) {
+		if err := s.wg.RemovePeer(pubKey); err != nil {
+			log.Printf("Error removing WireGuard peer: %v", err)
+		} else {
+			removed++
+		}
+		s.deletePeerOwner(pubKey)
+	}
+	return removed
+}
+
+// maxPeersFor returns the concurrent device limit for a tier (0 = unlimited).
+func (s *Server) maxPeersFor(tier nftcheck.AccessTier) int {
+	switch tier {
+	case nftcheck.TierFree:
+		return s.cfg.MaxPeersFree
+	case nftcheck.TierPaid:
+		return s.cfg.MaxPeersPaid
+	default:
+		return 0
+	}
+}
+
+// admitPeer enforces the per-wallet device limit before newKey is provisioned
+// for session. Callers hold the wallet's lockWallet until newKey is owned. Reconnecting a key the wallet already holds is not a new
+// device. Under the evict_oldest policy it returns the wallet's
+// longest-connected peers, which evictPeers removes once newKey is added;
+// under reject, errPeerLimit is returned.
+func (s *Server) admitPeer(session *nftgate.Session, newKey string) ([]string, error) {
+	if !session.AddressBound {
+		return nil, nil
+	}
+	limit := s.maxPeersFor(session.Tier)
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	var owned []string
+	for _, pubKey := range s.walletPeerKeys(session.Address) {
+		if pubKey == newKey {
+			return nil, nil
+		}
+		if s.wg.GetPeer(pubKey) == nil {
+			// Already expired out of the interface by the cleanup worker.
+			s.deletePeerOwner(pubKey)
+			continue
+		}
+		owned = append(owned, pubKey)
+	}
+
+	if len(owned) < limit {
+		return nil, nil
+	}
+	if s.cfg.PeerLimitPolicy == config.PeerLimitReject {
+		return nil, errPeerLimit
+	}
+	return owned[:len(owned)-limit+1], nil
+}
+
+// evictPeers removes the devices admitPeer chose to make room for a new one.
+func (s *Server) evictPeers(session *nftgate.Session, evict []string) {
+	for _, pubKey := range evict {
+		if err := s.wg.RemovePeer(pubKey); err != nil {
+			log.Printf("Error evicting WireGuard peer over device limit: %v", err)
+		}
+		s.deletePeerOwner(pubKey)
+		connlog.Printf("Evicted oldest device: tier=%s limit=%d", session.Tier, s.maxPeersFor(session.Tier))
+	}
+}
+
 func parseAddress(s string) (addr [20]byte) {
 	if !common.IsHexAddress(s) {
 		return addr
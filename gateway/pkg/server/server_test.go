@@ -5,20 +5,55 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"encoding/json"
+	"errors"
+	"log"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/anonauth"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/audit"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/buildinfo"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/config"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/connlog"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ethrpc"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/feedback"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/gatewaypb"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/metrics"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/nftcheck"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/nftgate"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/noderegistry"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/quota"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/rep6529"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/sessionmgr"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/siwe"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/subscriptionmgr"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/txtracker"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/usage"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/webhook"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/wireguard"
 	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/zkverify"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 func TestParseAddress(t *testing.T) {
@@ -631,6 +666,104 @@ func TestHandleAnonymousConnectRejectsStalePublishedRoot(t *testing.T) {
 	}
 }
 
+// anonymousZKAPI serves a vpn_access_v1 root for policy epoch 1 and
+// accepts every proof.
+func anonymousZKAPI(t *testing.T) *zkverify.Client {
+	t.Helper()
+	zkAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			writeJSON(w, http.StatusOK, map[string]any{"success": true, "valid": true})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"success": true,
+			"data": map[string]any{
+				"root":       "root_current",
+				"depth":      20,
+				"entryCount": 1,
+				"createdAt":  time.Now().UTC().Format(time.RFC3339),
+				"metadata": map[string]any{
+					"policyEpoch": "1",
+				},
+			},
+		})
+	}))
+	t.Cleanup(zkAPI.Close)
+	return zkverify.New(zkAPI.URL, "")
+}
+
+// anonymousConnect POSTs /vpn/anonymous/connect for pubKey with a fresh
+// challenge, against a server using anonymousZKAPI.
+func anonymousConnect(t *testing.T, s *Server, pubKey, nullifier string) *httptest.ResponseRecorder {
+	t.Helper()
+	challenge, err := s.anonAuth.NewChallenge()
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	sessionKeyHash := deriveVPNAccessV1SessionKeyHash(pubKey)
+	body, _ := json.Marshal(AnonymousConnectRequest{
+		ChallengeID:    challenge.ID,
+		ProofType:      vpnAccessV1ProofType,
+		NullifierHash:  nullifier,
+		SessionKeyHash: sessionKeyHash,
+		PublicSignals:  []string{"root_current", "1", "2", "4102444800", nullifier, deriveVPNAccessV1ChallengeHash(challenge), sessionKeyHash},
+		PublicKey:      pubKey,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/vpn/anonymous/connect", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.handleAnonymousVPNConnect(rec, req)
+	return rec
+}
+
+func TestAnonymousSessionExpiryUsesClock(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	s.SetZKClient(anonymousZKAPI(t))
+	clk := clock.NewFake(time.Now().Add(24 * time.Hour).Truncate(time.Second))
+	s.SetClock(clk)
+
+	rec := anonymousConnect(t, s, "anon-key", "nul_1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("anonymous connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if want := clk.Now().Add(s.cfg.CredentialTTL); !resp.ExpiresAt.Equal(want) {
+		t.Errorf("expires_at = %s, want %s from the server clock", resp.ExpiresAt, want)
+	}
+}
+
+func TestAnonymousConnectCannotTakeAnotherSessionsPeer(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	s.SetZKClient(anonymousZKAPI(t))
+
+	session := s.gate.CreateSession(common.HexToAddress("0x000000000000000000000000000000000000dEaD"), nftcheck.TierFree)
+	if rec := connectPeer(t, s, session.Token, "wallet-key"); rec.Code != http.StatusOK {
+		t.Fatalf("wallet connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	expiresAt := s.wg.GetPeer("wallet-key").ExpiresAt
+
+	rec := anonymousConnect(t, s, "wallet-key", "nul_1")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("anonymous connect with the wallet's key: expected 403, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := s.wg.GetPeer("wallet-key").ExpiresAt; !got.Equal(expiresAt) {
+		t.Errorf("peer expiry changed to %s, want it left at %s", got, expiresAt)
+	}
+	if !s.claimsPeer("wallet-key", session.ID) {
+		t.Error("peer handed to the anonymous session")
+	}
+
+	// The rejected request did not use up its nullifier.
+	if rec := anonymousConnect(t, s, "anon-key", "nul_1"); rec.Code != http.StatusOK {
+		t.Errorf("anonymous connect with its own key: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
 func TestHandleAnonymousConnectMissingChallenge(t *testing.T) {
 	s := &Server{
 		anonAuth: anonauth.NewService(time.Minute, 8, "vpn_access_v1", 7),
@@ -804,3 +937,2532 @@ func TestEffectiveTierKeepsFreeWhenEnabled(t *testing.T) {
 		t.Fatalf("effectiveTier(free) = %s, want free", got)
 	}
 }
+
+func TestFeatureDisabledResponse(t *testing.T) {
+	s := &Server{}
+	req := httptest.NewRequest(http.MethodGet, "/session/info", nil)
+	rec := httptest.NewRecorder()
+
+	s.handleSessionInfo(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "" {
+		t.Fatalf("Retry-After = %q, want empty for disabled feature", got)
+	}
+
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Decode response: %v", err)
+	}
+	if body["code"] != errCodeFeatureDisabled {
+		t.Fatalf("code = %q, want %q", body["code"], errCodeFeatureDisabled)
+	}
+}
+
+func TestBillingStatusWithSubscriptionOnly(t *testing.T) {
+	s := newTestHealthServer(t)
+	contract := common.HexToAddress("0x5b5c0ab0a4ab0e0c6eaa5a1dbb8e4bd9c2f4f6f2")
+	wallet := common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	session := s.gate.CreateSession(wallet, nftcheck.TierPaid)
+	status := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.handleBillingStatus(rec, httptest.NewRequest(http.MethodGet, "/billing/status"+query, nil))
+		return rec
+	}
+
+	if rec := status("?session_token=" + session.Token); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d with no managers, want 503", rec.Code)
+	}
+
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	client := ethrpc.NewFake()
+	client.HandleCalls(contract, func(call ethereum.CallMsg) ([]byte, error) {
+		if !bytes.HasPrefix(call.Data, crypto.Keccak256([]byte("getSubscription(address)"))[:4]) {
+			return nil, errors.New("rpc down")
+		}
+		// (user, node, payment, startedAt, expiresAt, tier)
+		var out []byte
+		for _, word := range []common.Hash{
+			common.BytesToHash(wallet.Bytes()),
+			{},
+			common.BigToHash(big.NewInt(1e15)),
+			common.BigToHash(big.NewInt(expiresAt - 3600)),
+			common.BigToHash(big.NewInt(expiresAt)),
+			common.BigToHash(big.NewInt(2)),
+		} {
+			out = append(out, word.Bytes()...)
+		}
+		return out, nil
+	})
+	subMgr, err := subscriptionmgr.NewWithClient(client, contract.Hex(), 1)
+	if err != nil {
+		t.Fatalf("subscriptionmgr.NewWithClient: %v", err)
+	}
+	defer subMgr.Close()
+	s.SetSubscriptionManager(subMgr)
+
+	if rec := status(""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d without a session token, want 400", rec.Code)
+	}
+	if rec := status("?session_token=bogus"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d for an unknown session, want 401", rec.Code)
+	}
+
+	rec := status("?session_token=" + session.Token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var got BillingStatus
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("Decode response: %v", err)
+	}
+	if got.SessionsEnabled || !got.SubscriptionsEnabled || got.Session != nil || got.SessionPricing != nil {
+		t.Errorf("session parts present without a session manager: %+v", got)
+	}
+	if got.Subscription == nil || got.Subscription.Tier != 2 || got.Subscription.PaymentWei != "1000000000000000" {
+		t.Fatalf("subscription = %+v, want tier 2 paid 1e15 wei", got.Subscription)
+	}
+	if got.RemainingSeconds == 0 || got.RemainingSeconds > 3600 || got.RemainingSeconds != got.Subscription.RemainingSeconds {
+		t.Errorf("remaining = %d (subscription %d), want the subscription's ~3600s", got.RemainingSeconds, got.Subscription.RemainingSeconds)
+	}
+	if !reflect.DeepEqual(got.Unavailable, []string{"subscription_tiers"}) {
+		t.Errorf("unavailable = %v, want [subscription_tiers]", got.Unavailable)
+	}
+}
+
+func TestNetworkStatsBeforeCollection(t *testing.T) {
+	s := &Server{}
+	for _, tc := range []struct {
+		name      string
+		collector *noderegistry.StatsCollector
+		wantCode  string
+	}{
+		{"not configured", nil, errCodeFeatureDisabled},
+		{"not collected yet", noderegistry.NewStatsCollector(nil, time.Minute), errCodeUnavailable},
+	} {
+		s.SetNetworkStatsCollector(tc.collector)
+		rec := httptest.NewRecorder()
+		s.handleNetworkStats(rec, httptest.NewRequest(http.MethodGet, "/network/stats", nil))
+
+		var body map[string]string
+		json.NewDecoder(rec.Body).Decode(&body)
+		if rec.Code != http.StatusServiceUnavailable || body["code"] != tc.wantCode {
+			t.Errorf("%s: status %d, code %q; want 503, %q", tc.name, rec.Code, body["code"], tc.wantCode)
+		}
+	}
+}
+
+func TestUnavailableResponse(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeUnavailable(rec, "dependency down")
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got == "" {
+		t.Fatal("expected Retry-After header")
+	}
+
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Decode response: %v", err)
+	}
+	if body["code"] != errCodeUnavailable {
+		t.Fatalf("code = %q, want %q", body["code"], errCodeUnavailable)
+	}
+}
+
+func TestHandleOperatorRepPaging(t *testing.T) {
+	var gotQuery url.Values
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+		json.NewEncoder(w).Encode(map[string]any{
+			"data":  []map[string]any{{"handle": "supporter", "rating": 100}},
+			"count": 51,
+			"page":  2,
+			"next":  false,
+		})
+	}))
+	defer api.Close()
+
+	s := New(config.DefaultConfig(), nil, nil)
+	s.SetOperatorRepChecker(rep6529.NewChecker(rep6529.Config{BaseURL: api.URL}))
+
+	operator := "0x1234567890abcdef1234567890abcdef12345678"
+	req := httptest.NewRequest(http.MethodGet, "/operator/"+operator+"/rep?page=2", nil)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if gotQuery.Get("page") != "2" {
+		t.Fatalf("expected page=2 forwarded, got %q", gotQuery.Get("page"))
+	}
+
+	var resp struct {
+		Count int64 `json:"count"`
+		Page  int   `json:"page"`
+		Next  bool  `json:"next"`
+		Data  []rep6529.RepContribution
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Decode response: %v", err)
+	}
+	if resp.Count != 51 || resp.Page != 2 || resp.Next || len(resp.Data) != 1 {
+		t.Fatalf("unexpected page: %+v", resp)
+	}
+}
+
+func TestHandleOperatorRepValidation(t *testing.T) {
+	s := &Server{operatorRep: rep6529.NewChecker(rep6529.Config{})}
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/operator/0x1234567890abcdef1234567890abcdef12345678/other", http.StatusNotFound},
+		{"/operator/not-an-address/rep", http.StatusBadRequest},
+		{"/operator/0x1234567890abcdef1234567890abcdef12345678/rep?page=0", http.StatusBadRequest},
+		{"/operator/0x1234567890abcdef1234567890abcdef12345678/rep?page_size=x", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.handleOperatorRep(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.want, rec.Code)
+		}
+	}
+}
+
+func newTestHealthServer(t *testing.T) *Server {
+	t.Helper()
+	wg, err := wireguard.NewManager(wireguard.Config{Interface: "wg0", Subnet: "10.8.0.0/24"})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	return New(config.DefaultConfig(), nil, wg)
+}
+
+func TestHealthReportsDependencyStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		probes     []HealthProbe
+		wantStatus string
+		wantCode   int
+	}{
+		{
+			name:       "all ok",
+			probes:     []HealthProbe{{Name: "rpc", Critical: true, Check: func(context.Context) error { return nil }}},
+			wantStatus: "ok",
+			wantCode:   http.StatusOK,
+		},
+		{
+			name: "non-critical down",
+			probes: []HealthProbe{
+				{Name: "rpc", Critical: true, Check: func(context.Context) error { return nil }},
+				{Name: "rep_api", Check: func(context.Context) error { return errors.New("timeout") }},
+			},
+			wantStatus: "degraded",
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "critical down",
+			probes:     []HealthProbe{{Name: "rpc", Critical: true, Check: func(context.Context) error { return errors.New("dial failed") }}},
+			wantStatus: "down",
+			wantCode:   http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestHealthServer(t)
+			for _, p := range tt.probes {
+				s.AddHealthProbe(p)
+			}
+
+			rec := httptest.NewRecorder()
+			s.handleHealth(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d", tt.wantCode, rec.Code)
+			}
+			var resp struct {
+				Status       string                      `json:"status"`
+				Dependencies map[string]DependencyStatus `json:"dependencies"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Decode response: %v", err)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", resp.Status, tt.wantStatus)
+			}
+			if len(resp.Dependencies) != len(tt.probes) {
+				t.Errorf("expected %d dependencies, got %d", len(tt.probes), len(resp.Dependencies))
+			}
+		})
+	}
+}
+
+func TestHealthCachesProbeResults(t *testing.T) {
+	s := newTestHealthServer(t)
+	calls := 0
+	s.AddHealthProbe(HealthProbe{Name: "rpc", Check: func(context.Context) error {
+		calls++
+		return nil
+	}})
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		s.handleHealth(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	}
+	if calls != 1 {
+		t.Errorf("expected probe to run once within cache TTL, ran %d times", calls)
+	}
+}
+
+func TestHealthProbesRunOutsideLock(t *testing.T) {
+	s := newTestHealthServer(t)
+	started, release := make(chan struct{}), make(chan struct{})
+	s.AddHealthProbe(HealthProbe{Name: "slow", Check: func(context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.handleHealth(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	}()
+	<-started
+
+	// Registering a probe must not wait for the slow probe to finish.
+	added := make(chan struct{})
+	go func() {
+		s.AddHealthProbe(HealthProbe{Name: "fast", Check: func(context.Context) error { return nil }})
+		close(added)
+	}()
+	select {
+	case <-added:
+	case <-time.After(2 * time.Second):
+		t.Fatal("AddHealthProbe blocked while a probe was running")
+	}
+	close(release)
+	<-done
+}
+
+func TestLivez(t *testing.T) {
+	s := newTestHealthServer(t)
+	s.AddHealthProbe(HealthProbe{Name: "rpc", Critical: true, Check: func(context.Context) error {
+		return errors.New("down")
+	}})
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 regardless of dependencies, got %d", rec.Code)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	s := newTestHealthServer(t)
+	s.SetBuildInfo(buildinfo.Resolve("v1.2.0", "abc123", "2026-01-02T15:04:05Z"))
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var got buildinfo.Info
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if got.Version != "v1.2.0" || got.Commit != "abc123" || got.BuildTime != "2026-01-02T15:04:05Z" || got.GoVersion == "" {
+		t.Errorf("got %+v", got)
+	}
+	if strings.Contains(rec.Body.String(), "dns_policy") {
+		t.Errorf("advertised a DNS policy none was configured: %s", rec.Body)
+	}
+}
+
+func TestVersionAdvertisesDNSPolicy(t *testing.T) {
+	wg, err := wireguard.NewManager(wireguard.Config{
+		Interface: "wg0",
+		Subnet:    "10.8.0.0/24",
+		DNSPolicy: &wireguard.DNSPolicy{LocalResolver: true, NoQueryLogs: true},
+	})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	s := New(config.DefaultConfig(), nil, wg)
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	var got struct {
+		DNSPolicy *wireguard.DNSPolicy `json:"dns_policy"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if got.DNSPolicy == nil || *got.DNSPolicy != (wireguard.DNSPolicy{LocalResolver: true, NoQueryLogs: true}) {
+		t.Errorf("dns_policy = %+v, want local resolver and no query logs", got.DNSPolicy)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	s := newTestHealthServer(t)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q, want nosniff by default", got)
+	}
+	if got := rec.Header().Get("Content-Security-Policy"); !strings.Contains(got, "default-src 'none'") {
+		t.Errorf("Content-Security-Policy = %q", got)
+	}
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("HSTS sent without hsts_max_age: %q", got)
+	}
+
+	s.cfg.SecurityHeaders = false
+	s.cfg.HSTSMaxAge = 365 * 24 * time.Hour
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "max-age=31536000" {
+		t.Errorf("Strict-Transport-Security = %q, want max-age=31536000", got)
+	}
+	if got := rec.Header().Get("X-Content-Type-Options"); got != "" {
+		t.Errorf("hardening headers sent with security_headers off: %q", got)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name     string
+		rpcErr   error
+		ifaceErr error
+		wantCode int
+	}{
+		{"ready", nil, nil, http.StatusOK},
+		{"rpc down", errors.New("dial failed"), nil, http.StatusServiceUnavailable},
+		{"interface missing", nil, errors.New("no such device"), http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestHealthServer(t)
+			s.AddHealthProbe(HealthProbe{Name: "ethereum_rpc", Critical: true, Check: func(context.Context) error {
+				return tt.rpcErr
+			}})
+			s.AddHealthProbe(HealthProbe{Name: "rep_api", Check: func(context.Context) error {
+				return errors.New("non-critical probes do not affect readiness")
+			}})
+			s.ifaceCheck = func() error { return tt.ifaceErr }
+
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+// stubWGOnPath puts a no-op `wg` executable first on PATH so peer
+// provisioning succeeds without a real interface.
+func stubWGOnPath(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "wg"), []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatalf("writing wg stub: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func connectPeer(t *testing.T, s *Server, token, pubKey string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(ConnectRequest{SessionToken: token, PublicKey: pubKey})
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/vpn/connect", bytes.NewReader(body)))
+	return rec
+}
+
+func TestConnectAdvertisesAlternateEndpoints(t *testing.T) {
+	stubWGOnPath(t)
+	wg, err := wireguard.NewManager(wireguard.Config{
+		Interface:          "wg0",
+		Subnet:             "10.8.0.0/24",
+		ServerEndpoint:     "203.0.113.10:51820",
+		AlternateEndpoints: []string{"[2001:db8::1]:51820"},
+	})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	s := New(config.DefaultConfig(), nil, wg)
+	session := s.gate.CreateSession(common.HexToAddress("0x000000000000000000000000000000000000dEaD"), nftcheck.TierFree)
+
+	rec := connectPeer(t, s, session.Token, "laptop-key")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp ConnectResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.ServerEndpoint != "203.0.113.10:51820" {
+		t.Errorf("server_endpoint = %q", resp.ServerEndpoint)
+	}
+	if len(resp.AlternateEndpoints) != 1 || resp.AlternateEndpoints[0] != "[2001:db8::1]:51820" {
+		t.Errorf("alternate_endpoints = %v", resp.AlternateEndpoints)
+	}
+}
+
+// fakePeers is a PeerManager with no WireGuard behind it.
+type fakePeers struct {
+	peers map[string]wireguard.Peer
+	err   error // returned by AddPeer when set
+}
+
+func (f *fakePeers) AddPeer(pubKey, tier string, ttl time.Duration) (*wireguard.PeerConfig, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.peers[pubKey] = wireguard.Peer{PublicKey: pubKey, ClientIP: "10.9.0.2", Tier: tier, ExpiresAt: time.Now().Add(ttl)}
+	return &wireguard.PeerConfig{ServerEndpoint: "fake:51820", ClientAddress: "10.9.0.2/32"}, nil
+}
+
+func (f *fakePeers) RemovePeer(pubKey string) error {
+	delete(f.peers, pubKey)
+	return nil
+}
+
+func (f *fakePeers) RekeyPeer(oldKey, newKey string) (*wireguard.PeerConfig, error) {
+	old := f.peers[oldKey]
+	f.RemovePeer(oldKey)
+	return f.AddPeer(newKey, old.Tier, time.Hour)
+}
+
+func (f *fakePeers) GetPeer(pubKey string) *wireguard.Peer {
+	if p, ok := f.peers[pubKey]; ok {
+		return &p
+	}
+	return nil
+}
+
+func (f *fakePeers) Peers() []wireguard.Peer {
+	var out []wireguard.Peer
+	for _, p := range f.peers {
+		out = append(out, p)
+	}
+	return out
+}
+
+func (f *fakePeers) PeerCount() int                   { return len(f.peers) }
+func (f *fakePeers) CleanExpired() int                { return 0 }
+func (f *fakePeers) StartCleanupWorker(time.Duration) {}
+func (f *fakePeers) DNSPolicy() *wireguard.DNSPolicy  { return nil }
+func (f *fakePeers) ProvisionsTunnels() bool          { return true }
+func (f *fakePeers) InterfaceExists() error           { return nil }
+func (f *fakePeers) SetClock(clock.Clock)             {}
+
+func TestServerRunsOnAnyPeerManager(t *testing.T) {
+	peers := &fakePeers{peers: make(map[string]wireguard.Peer)}
+	s := New(config.DefaultConfig(), nil, peers)
+	session := s.gate.CreateSession(common.HexToAddress("0x000000000000000000000000000000000000dEaD"), nftcheck.TierFree)
+
+	rec := connectPeer(t, s, session.Token, "laptop-key")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "fake:51820") {
+		t.Errorf("connect response %s does not come from the peer manager", rec.Body.String())
+	}
+	if p := peers.GetPeer("laptop-key"); p == nil || p.Tier != "free" {
+		t.Errorf("peer manager has %+v, want the free-tier peer", p)
+	}
+}
+
+func TestDiscoveryOnlyGatewayRefusesConnect(t *testing.T) {
+	wg, err := wireguard.NewManager(wireguard.Config{Interface: "wg0", Subnet: "10.8.0.0/24", Backend: wireguard.BackendNone})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	s := New(config.DefaultConfig(), nil, wg)
+	session := s.gate.CreateSession(common.HexToAddress("0x000000000000000000000000000000000000dEaD"), nftcheck.TierFree)
+
+	rec := connectPeer(t, s, session.Token, "laptop-key")
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), errCodeFeatureDisabled) {
+		t.Fatalf("connect: expected 503 feature_disabled, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if wg.PeerCount() != 0 {
+		t.Errorf("peer count = %d, want 0", wg.PeerCount())
+	}
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &health)
+	if health["provisions_tunnels"] != false {
+		t.Errorf("/health provisions_tunnels = %v, want false", health["provisions_tunnels"])
+	}
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	var version versionResponse
+	json.Unmarshal(rec.Body.Bytes(), &version)
+	if !version.DiscoveryOnly {
+		t.Errorf("/version = %s, want discovery_only", rec.Body.String())
+	}
+}
+
+func TestPeerLimitEvictsOldest(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	s.cfg.MaxPeersFree = 1
+	s.cfg.PeerLimitPolicy = config.PeerLimitEvictOldest
+
+	wallet := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	session := s.gate.CreateSession(wallet, nftcheck.TierFree)
+
+	if rec := connectPeer(t, s, session.Token, "laptop-key"); rec.Code != http.StatusOK {
+		t.Fatalf("first connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := connectPeer(t, s, session.Token, "phone-key"); rec.Code != http.StatusOK {
+		t.Fatalf("second connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if s.wg.GetPeer("laptop-key") != nil {
+		t.Fatal("expected oldest peer to be evicted")
+	}
+	if s.wg.GetPeer("phone-key") == nil {
+		t.Fatal("expected newest peer to be provisioned")
+	}
+	if n := s.wg.PeerCount(); n != 1 {
+		t.Fatalf("expected 1 peer, got %d", n)
+	}
+}
+
+func TestPeerLimitKeepsDevicesWhenConnectFails(t *testing.T) {
+	peers := &fakePeers{peers: make(map[string]wireguard.Peer)}
+	cfg := config.DefaultConfig()
+	cfg.MaxPeersFree = 1
+	cfg.PeerLimitPolicy = config.PeerLimitEvictOldest
+	s := New(cfg, nil, peers)
+	session := s.gate.CreateSession(common.HexToAddress("0x000000000000000000000000000000000000dEaD"), nftcheck.TierFree)
+
+	if rec := connectPeer(t, s, session.Token, "laptop-key"); rec.Code != http.StatusOK {
+		t.Fatalf("first connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	peers.err = wireguard.ErrNoTunnels
+	if rec := connectPeer(t, s, session.Token, "phone-key"); rec.Code == http.StatusOK {
+		t.Fatalf("second connect: expected failure, got 200: %s", rec.Body.String())
+	}
+	if peers.GetPeer("laptop-key") == nil {
+		t.Fatal("failed connect evicted the wallet's existing device")
+	}
+}
+
+func TestPeerLimitRejects(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	s.cfg.MaxPeersFree = 1
+	s.cfg.PeerLimitPolicy = config.PeerLimitReject
+
+	wallet := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	session := s.gate.CreateSession(wallet, nftcheck.TierFree)
+
+	if rec := connectPeer(t, s, session.Token, "laptop-key"); rec.Code != http.StatusOK {
+		t.Fatalf("first connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// Reconnecting the same device is not a new peer.
+	if rec := connectPeer(t, s, session.Token, "laptop-key"); rec.Code != http.StatusOK {
+		t.Fatalf("reconnect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := connectPeer(t, s, session.Token, "phone-key"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second device: expected 429, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if s.wg.GetPeer("laptop-key") == nil {
+		t.Fatal("expected existing peer to be kept")
+	}
+}
+
+func TestSessionCapKeepsSessionsWithPeers(t *testing.T) {
+	stubWGOnPath(t)
+	cfg := config.DefaultConfig()
+	cfg.MaxSessions = 2
+	wg, err := wireguard.NewManager(wireguard.Config{Interface: "wg0", Subnet: "10.8.0.0/24"})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	s := New(cfg, nil, wg)
+
+	events := make(chan webhook.Event, 8)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev webhook.Event
+		json.NewDecoder(r.Body).Decode(&ev)
+		events <- ev
+	}))
+	defer hook.Close()
+	n, err := webhook.NewNotifier(webhook.Config{URL: hook.URL, Secret: "secret"})
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	defer n.Close()
+	s.SetWebhookNotifier(n)
+
+	connected := s.gate.CreateSession(common.HexToAddress("0x000000000000000000000000000000000000dEaD"), nftcheck.TierFree)
+	if rec := connectPeer(t, s, connected.Token, "live-key"); rec.Code != http.StatusOK {
+		t.Fatalf("connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	idle := common.HexToAddress("0x000000000000000000000000000000000000bEEF")
+	s.gate.CreateSession(idle, nftcheck.TierFree)
+
+	// A flood of fresh sign-ins pushes out the idle session, never the one
+	// with a tunnel.
+	for i := range 10 {
+		s.gate.CreateSession(common.BigToAddress(big.NewInt(int64(1000+i))), nftcheck.TierFree)
+	}
+	if s.gate.GetSessionByToken(connected.Token) == nil {
+		t.Fatal("session with a live peer was evicted")
+	}
+
+	select {
+	case ev := <-events:
+		if ev.Address != idle.Hex() || ev.Reason != webhook.ReasonSessionEvicted {
+			t.Errorf("first webhook = %+v, want %s evicted", ev, idle.Hex())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no webhook for the evicted session")
+	}
+}
+
+func TestPeerLimitRejectsConcurrentConnects(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	s.cfg.MaxPeersFree = 1
+	s.cfg.PeerLimitPolicy = config.PeerLimitReject
+
+	session := s.gate.CreateSession(common.HexToAddress("0x000000000000000000000000000000000000dEaD"), nftcheck.TierFree)
+
+	const devices = 8
+	codes := make(chan int, devices)
+	for i := range devices {
+		go func() {
+			codes <- connectPeer(t, s, session.Token, "device-"+strconv.Itoa(i)).Code
+		}()
+	}
+	connected := 0
+	for range devices {
+		if <-codes == http.StatusOK {
+			connected++
+		}
+	}
+
+	if connected != 1 {
+		t.Fatalf("%d concurrent connects admitted, want 1", connected)
+	}
+	if n := s.wg.PeerCount(); n != 1 {
+		t.Fatalf("%d peers provisioned, want 1", n)
+	}
+}
+
+func TestFreeOnlyRegionRejectsPaidTier(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	s.cfg.Region = "ch-zurich"
+	s.cfg.RegionTiers = map[string]string{"ch-zurich": config.RegionTierFree}
+
+	paid := s.gate.CreateSession(common.HexToAddress("0x000000000000000000000000000000000000bEEF"), nftcheck.TierPaid)
+	rec := connectPeer(t, s, paid.Token, "paid-key")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("paid tier in free-only region: expected 403, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "ch-zurich") {
+		t.Errorf("expected error to name the region, got %s", rec.Body.String())
+	}
+
+	free := s.gate.CreateSession(common.HexToAddress("0x000000000000000000000000000000000000dEaD"), nftcheck.TierFree)
+	if rec := connectPeer(t, s, free.Token, "free-key"); rec.Code != http.StatusOK {
+		t.Fatalf("free tier in free-only region: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestQuotaDisconnectsOverQuotaWallet(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	s.cfg.QuotaFreeBytes = 1000
+
+	wallet := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	session := s.gate.CreateSession(wallet, nftcheck.TierFree)
+	if rec := connectPeer(t, s, session.Token, "laptop-key"); rec.Code != http.StatusOK {
+		t.Fatalf("connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	s.meterUsage()
+	if s.wg.GetPeer("laptop-key") == nil {
+		t.Fatal("expected peer under quota to stay connected")
+	}
+
+	// Traffic from an earlier device this period pushes the wallet over.
+	s.quota.Observe(walletKey(wallet), "old-key", time.Now(), 1500)
+	s.meterUsage()
+	if s.wg.GetPeer("laptop-key") != nil {
+		t.Fatal("expected over-quota peer to be disconnected")
+	}
+
+	if rec := connectPeer(t, s, session.Token, "laptop-key"); rec.Code != http.StatusForbidden {
+		t.Fatalf("reconnect over quota: expected 403, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/vpn/status", nil)
+	req.Header.Set("Authorization", "Bearer "+session.Token)
+	s.Handler().ServeHTTP(rec, req)
+	var status struct {
+		Connected bool   `json:"connected"`
+		Reason    string `json:"reason"`
+		Quota     struct {
+			UsedBytes uint64 `json:"used_bytes"`
+			Exceeded  bool   `json:"exceeded"`
+		} `json:"quota"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decoding status: %v", err)
+	}
+	if status.Connected || !status.Quota.Exceeded || status.Quota.UsedBytes != 1500 || !strings.Contains(status.Reason, "quota") {
+		t.Fatalf("unexpected status: %s", rec.Body.String())
+	}
+}
+
+func TestVPNUsageHistory(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	store, err := usage.Open(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("usage.Open: %v", err)
+	}
+	defer store.Close()
+	s.SetUsageStore(store)
+
+	wallet := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	session := s.gate.CreateSession(wallet, nftcheck.TierFree)
+	if rec := connectPeer(t, s, session.Token, "laptop-key"); rec.Code != http.StatusOK {
+		t.Fatalf("connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	s.wg.GetPeer("laptop-key").BytesSent = 1000
+	s.meterUsage()
+	// Renewing the credential keeps the kernel counters; only new bytes count.
+	if rec := connectPeer(t, s, session.Token, "laptop-key"); rec.Code != http.StatusOK {
+		t.Fatalf("renew: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	s.wg.GetPeer("laptop-key").BytesSent = 1500
+	s.meterUsage()
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/vpn/usage?days=7&session_token="+session.Token, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Days       []usage.Day `json:"days"`
+		TotalBytes uint64      `json:"total_bytes"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding usage: %v", err)
+	}
+	if len(resp.Days) != 7 || resp.TotalBytes != 1500 || resp.Days[6].Bytes != 1500 {
+		t.Fatalf("unexpected usage history: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/vpn/usage?session_token=bogus", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("bad token: expected 401, got %d", rec.Code)
+	}
+}
+
+func TestDisconnectWithoutPublicKeyRemovesWalletPeers(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	s.cfg.MaxPeersFree = 2
+
+	wallet := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	session := s.gate.CreateSession(wallet, nftcheck.TierFree)
+	for _, key := range []string{"laptop-key", "phone-key"} {
+		if rec := connectPeer(t, s, session.Token, key); rec.Code != http.StatusOK {
+			t.Fatalf("connect %s: expected 200, got %d: %s", key, rec.Code, rec.Body.String())
+		}
+	}
+
+	disconnect := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(ConnectRequest{SessionToken: session.Token})
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/vpn/disconnect", bytes.NewReader(body)))
+		return rec
+	}
+
+	rec := disconnect()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		PeersRemoved int `json:"peers_removed"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Decode response: %v", err)
+	}
+	if resp.PeersRemoved != 2 {
+		t.Fatalf("expected 2 peers removed, got %d", resp.PeersRemoved)
+	}
+	if s.wg.GetPeer("laptop-key") != nil || s.wg.GetPeer("phone-key") != nil {
+		t.Fatal("expected all wallet peers to be removed")
+	}
+
+	if rec := disconnect(); rec.Code != http.StatusNotFound {
+		t.Fatalf("second disconnect: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestConnectAndStatusRequireSessionToken(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+
+	victim := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	session := s.gate.CreateSession(victim, nftcheck.TierFree)
+
+	// A live session for the wallet does not let others provision against
+	// it by naming the address.
+	if rec := connectPeer(t, s, victim.Hex(), "attacker-key"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("connect with address: expected 401, got %d", rec.Code)
+	}
+	if s.wg.GetPeer("attacker-key") != nil {
+		t.Fatal("peer provisioned without the session token")
+	}
+
+	status := func(token string) string {
+		req := httptest.NewRequest(http.MethodGet, "/vpn/status", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+	if body := status(victim.Hex()); strings.Contains(body, `"tier"`) {
+		t.Fatalf("status with address leaked session details: %s", body)
+	}
+
+	if rec := connectPeer(t, s, session.Token, "victim-key"); rec.Code != http.StatusOK {
+		t.Fatalf("connect with token: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if body := status(session.Token); !strings.Contains(body, `"connected":true`) {
+		t.Fatalf("status with token: %s", body)
+	}
+}
+
+func TestChallengeStatementNamesNode(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SIWEStatement = "Sign in to node {node} ({region}) for VPN access."
+	cfg.Region = "us-east"
+	s := New(cfg, nil, nil)
+
+	resp, err := s.newChallenge("0x000000000000000000000000000000000000dEaD")
+	if err != nil {
+		t.Fatalf("newChallenge: %v", err)
+	}
+	want := "Sign in to node " + cfg.SIWEDomain + " (us-east) for VPN access."
+	if !strings.Contains(resp.Message, "\n"+want+"\n") {
+		t.Errorf("challenge message does not name the node:\n%s", resp.Message)
+	}
+
+	cfg.NodeName = "us-east-1"
+	s = New(cfg, nil, nil)
+	resp, _ = s.newChallenge("0x000000000000000000000000000000000000dEaD")
+	if !strings.Contains(resp.Message, "Sign in to node us-east-1 (us-east)") {
+		t.Errorf("challenge message does not use node_name:\n%s", resp.Message)
+	}
+}
+
+func TestVerifyRequiresTermsAcceptance(t *testing.T) {
+	wg, err := wireguard.NewManager(wireguard.Config{Interface: "wg0", Subnet: "10.8.0.0/24"})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.TermsURL = "https://vpn.example/terms"
+	cfg.TermsSHA256 = strings.Repeat("ab", 32)
+	s := New(cfg, tierChecker{tier: nftcheck.TierPaid}, wg)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := audit.Open(path)
+	if err != nil {
+		t.Fatalf("audit.Open: %v", err)
+	}
+	defer auditLog.Close()
+	s.SetAuditLogger(auditLog)
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	wallet := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	resp, err := s.newChallenge(wallet)
+	if err != nil {
+		t.Fatalf("newChallenge: %v", err)
+	}
+	if resp.Terms == nil || resp.Terms.URL != cfg.TermsURL || resp.Terms.SHA256 != cfg.TermsSHA256 {
+		t.Fatalf("challenge does not name the terms: %+v", resp.Terms)
+	}
+	if !strings.Contains(resp.Message, "I accept the terms of service at "+cfg.TermsURL+" (sha256:"+cfg.TermsSHA256+").") {
+		t.Fatalf("challenge statement does not accept the terms:\n%s", resp.Message)
+	}
+
+	verify := func(message string) *httptest.ResponseRecorder {
+		t.Helper()
+		sig, err := signEnrollmentMessage(key, message)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		body, _ := json.Marshal(map[string]string{"message": message, "signature": sig})
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/verify", bytes.NewReader(body)))
+		return rec
+	}
+
+	// The message as issued accepts the terms.
+	if rec := verify(resp.Message); rec.Code != http.StatusOK {
+		t.Fatalf("verify: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// A client that signs the message without the clause is refused.
+	challenge, err := s.siwe.NewChallenge(16)
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	challenge.Statement = siwe.DefaultStatement
+	rec := verify(siwe.FormatMessage(challenge, wallet))
+	var errResp map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &errResp)
+	if rec.Code != http.StatusForbidden || errResp["code"] != errCodeTermsNotAccepted {
+		t.Fatalf("verify without the terms: got %d %s, want 403 with code %s", rec.Code, rec.Body.String(), errCodeTermsNotAccepted)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []audit.Record
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var r audit.Record
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("audit line %q: %v", line, err)
+		}
+		r.Time = time.Time{}
+		got = append(got, r)
+	}
+	want := []audit.Record{
+		{Event: audit.EventVerify, Outcome: audit.OutcomeGranted, Address: wallet, Tier: "paid", TermsURL: cfg.TermsURL, TermsSHA256: cfg.TermsSHA256},
+		{Event: audit.EventVerify, Outcome: audit.OutcomeDenied, Address: wallet, Reason: "terms_not_accepted"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("audit records:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestChallengeRateLimitedPerClaimedAddress(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.RateLimitPerMinute = 0
+	cfg.ChallengeRateLimitPerMinute = 2
+	s := New(cfg, nil, nil)
+
+	challenge := func(address, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/auth/challenge", strings.NewReader(`{"address":"`+address+`"}`))
+		req.RemoteAddr = ip + ":40000"
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Spreading requests over IPs and address spellings does not reset the
+	// allowance.
+	wallet := "0x000000000000000000000000000000000000dEaD"
+	for i, ip := range []string{"192.0.2.1", "192.0.2.2"} {
+		if rec := challenge(wallet, ip); rec.Code != http.StatusOK {
+			t.Fatalf("challenge %d: expected 200, got %d", i+1, rec.Code)
+		}
+	}
+	rec := challenge(strings.ToLower(wallet), "192.0.2.3")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("third challenge: expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After on 429")
+	}
+
+	// Another address on the same IP is unaffected.
+	if rec := challenge("0x000000000000000000000000000000000000bEEF", "192.0.2.3"); rec.Code != http.StatusOK {
+		t.Fatalf("other address: expected 200, got %d", rec.Code)
+	}
+}
+
+func TestSessionEndpointsRateLimitedPerWallet(t *testing.T) {
+	stubWGOnPath(t)
+	wg, err := wireguard.NewManager(wireguard.Config{Interface: "wg0", Subnet: "10.8.0.0/24"})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.RateLimitPerMinute = 0
+	cfg.WalletRateLimitPerMinute = 2
+	cfg.MaxPeersFree = 0
+	s := New(cfg, nil, wg)
+
+	wallet := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	first := s.gate.CreateSession(wallet, nftcheck.TierFree)
+	if rec := connectPeer(t, s, first.Token, "key-1"); rec.Code != http.StatusOK {
+		t.Fatalf("connect 1: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// A second session for the same wallet shares the allowance.
+	second := s.gate.CreateSession(wallet, nftcheck.TierFree)
+	if rec := connectPeer(t, s, second.Token, "key-2"); rec.Code != http.StatusOK {
+		t.Fatalf("connect 2: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := connectPeer(t, s, second.Token, "key-3"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("connect 3: expected 429, got %d", rec.Code)
+	}
+	if s.wg.GetPeer("key-3") != nil {
+		t.Fatal("peer provisioned past the wallet limit")
+	}
+
+	other := s.gate.CreateSession(common.HexToAddress("0x000000000000000000000000000000000000bEEF"), nftcheck.TierFree)
+	if rec := connectPeer(t, s, other.Token, "key-4"); rec.Code != http.StatusOK {
+		t.Fatalf("other wallet: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSessionAndPeerExpireWithClock(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	clk := clock.NewFake(time.Now())
+	s.SetClock(clk)
+
+	session := s.gate.CreateSession(common.HexToAddress("0x000000000000000000000000000000000000dEaD"), nftcheck.TierFree)
+	if rec := connectPeer(t, s, session.Token, "expiring-key"); rec.Code != http.StatusOK {
+		t.Fatalf("connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	clk.Advance(s.cfg.CredentialTTL + time.Second)
+	if rec := connectPeer(t, s, session.Token, "late-key"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("connect after expiry: expected 401, got %d", rec.Code)
+	}
+	if n := s.wg.CleanExpired(); n != 1 {
+		t.Fatalf("CleanExpired removed %d peers, want 1", n)
+	}
+}
+
+func TestConnectRejectsSessionAboutToExpire(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	clk := clock.NewFake(time.Now())
+	s.SetClock(clk)
+
+	minConnectTTL := 10 * time.Minute
+	s.cfg.MinConnectTTL = &minConnectTTL
+
+	session := s.gate.CreateSession(common.HexToAddress("0x000000000000000000000000000000000000dEaD"), nftcheck.TierFree)
+	clk.Advance(s.cfg.CredentialTTL - 11*time.Minute)
+	if rec := connectPeer(t, s, session.Token, "early-key"); rec.Code != http.StatusOK {
+		t.Fatalf("connect with 11m left: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Still valid to the gate, but with less than MinConnectTTL left.
+	clk.Advance(2 * time.Minute)
+	if s.gate.GetSessionByToken(session.Token) == nil {
+		t.Fatal("session should still be valid")
+	}
+	rec := connectPeer(t, s, session.Token, "late-key")
+	var resp map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusUnauthorized || resp["code"] != errCodeSessionExpired {
+		t.Fatalf("connect: got %d %s, want 401 with code %s", rec.Code, rec.Body.String(), errCodeSessionExpired)
+	}
+	if s.wg.GetPeer("late-key") != nil {
+		t.Fatal("peer provisioned for a session about to expire")
+	}
+}
+
+func TestConnectMinTTLDefaultsToFractionOfSession(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	clk := clock.NewFake(time.Now())
+	s.SetClock(clk)
+
+	session := s.gate.CreateSession(common.HexToAddress("0x000000000000000000000000000000000000dEaD"), nftcheck.TierFree)
+	floor := s.cfg.CredentialTTL / config.DefaultMinConnectDivisor
+	clk.Advance(s.cfg.CredentialTTL - floor + time.Minute)
+	if rec := connectPeer(t, s, session.Token, "late-key"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("connect inside the default floor: got %d, want 401", rec.Code)
+	}
+
+	off := time.Duration(0)
+	s.cfg.MinConnectTTL = &off
+	if rec := connectPeer(t, s, session.Token, "late-key"); rec.Code != http.StatusOK {
+		t.Fatalf("connect with min_connect_ttl 0: got %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRekeyKeepsAddressAndExpiry(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+
+	wallet := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	session := s.gate.CreateSession(wallet, nftcheck.TierFree)
+	rec := connectPeer(t, s, session.Token, "old-key")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var connected ConnectResponse
+	json.Unmarshal(rec.Body.Bytes(), &connected)
+	other := s.gate.CreateSession(common.HexToAddress("0x000000000000000000000000000000000000bEEF"), nftcheck.TierFree)
+	if rec := connectPeer(t, s, other.Token, "other-key"); rec.Code != http.StatusOK {
+		t.Fatalf("connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rekey := func(token, oldKey, newKey string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(RekeyRequest{SessionToken: token, OldPublicKey: oldKey, NewPublicKey: newKey})
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/vpn/rekey", bytes.NewReader(body)))
+		return rec
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		oldKey string
+		newKey string
+		want   int
+	}{
+		{"missing new key", session.Token, "old-key", "", http.StatusBadRequest},
+		{"same key", session.Token, "old-key", "old-key", http.StatusBadRequest},
+		{"unknown session", "bogus", "old-key", "new-key", http.StatusUnauthorized},
+		{"another session's peer", other.Token, "old-key", "new-key", http.StatusForbidden},
+		{"key bound to another session", session.Token, "old-key", "other-key", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if rec := rekey(tt.token, tt.oldKey, tt.newKey); rec.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec = rekey(session.Token, "old-key", "new-key")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rekey: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var rekeyed ConnectResponse
+	json.Unmarshal(rec.Body.Bytes(), &rekeyed)
+	if rekeyed.ClientAddress != connected.ClientAddress || rekeyed.ExpiresAt != connected.ExpiresAt {
+		t.Errorf("rekey changed the peer: %+v, was %+v", rekeyed, connected)
+	}
+	if s.wg.GetPeer("old-key") != nil || s.wg.GetPeer("new-key") == nil {
+		t.Error("rekey did not swap the peer on the interface")
+	}
+	if keys := s.walletPeerKeys(wallet); len(keys) != 1 || keys[0] != "new-key" {
+		t.Errorf("wallet peers after rekey = %v, want [new-key]", keys)
+	}
+	if !s.peerOwnedBy("new-key", session.ID) || s.peerOwnedBy("old-key", session.ID) {
+		t.Error("rekey did not move peer ownership")
+	}
+}
+
+func TestDisconnectRequiresSessionProof(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+
+	victim := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	session := s.gate.CreateSession(victim, nftcheck.TierFree)
+	if rec := connectPeer(t, s, session.Token, "victim-key"); rec.Code != http.StatusOK {
+		t.Fatalf("connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	attacker := s.gate.CreateSession(common.HexToAddress("0x000000000000000000000000000000000000bEEF"), nftcheck.TierFree)
+
+	disconnect := func(path, token, pubKey string) int {
+		body, _ := json.Marshal(ConnectRequest{SessionToken: token, PublicKey: pubKey})
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)))
+		return rec.Code
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		pubKey string
+		want   int
+	}{
+		// Knowing the wallet address and WireGuard key is not enough.
+		{"address as token", "/vpn/disconnect", victim.Hex(), "victim-key", http.StatusUnauthorized},
+		{"tampered token", "/vpn/disconnect", session.Token + "x", "victim-key", http.StatusUnauthorized},
+		{"address as token, all devices", "/vpn/disconnect-all", victim.Hex(), "", http.StatusUnauthorized},
+		// Another wallet's valid session cannot remove the victim's peer.
+		{"other session", "/vpn/disconnect", attacker.Token, "victim-key", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if got := disconnect(tt.path, tt.token, tt.pubKey); got != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, got)
+		}
+	}
+	if s.wg.GetPeer("victim-key") == nil {
+		t.Fatal("victim's peer was removed without their session token")
+	}
+
+	if got := disconnect("/vpn/disconnect", session.Token, "victim-key"); got != http.StatusOK {
+		t.Fatalf("owner disconnect: expected 200, got %d", got)
+	}
+}
+
+func TestDisconnectAllRemovesEveryDevice(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	s.cfg.MaxPeersFree = 3
+
+	wallet := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	other := common.HexToAddress("0x000000000000000000000000000000000000bEEF")
+	session := s.gate.CreateSession(wallet, nftcheck.TierFree)
+	otherSession := s.gate.CreateSession(other, nftcheck.TierFree)
+
+	for _, key := range []string{"laptop-key", "phone-key"} {
+		if rec := connectPeer(t, s, session.Token, key); rec.Code != http.StatusOK {
+			t.Fatalf("connect %s: expected 200, got %d: %s", key, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := connectPeer(t, s, otherSession.Token, "other-key"); rec.Code != http.StatusOK {
+		t.Fatalf("connect other-key: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/vpn/disconnect-all", nil)
+	req.Header.Set("Authorization", "Bearer "+session.Token)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"peers_removed":2`) {
+		t.Fatalf("expected 2 peers removed, got %s", rec.Body.String())
+	}
+	if s.wg.GetPeer("laptop-key") != nil || s.wg.GetPeer("phone-key") != nil {
+		t.Fatal("expected every device of the wallet to be disconnected")
+	}
+	if s.wg.GetPeer("other-key") == nil {
+		t.Fatal("expected other wallet's peer to stay connected")
+	}
+
+	// The freed addresses are reusable: reconnecting succeeds.
+	if rec := connectPeer(t, s, session.Token, "laptop-key"); rec.Code != http.StatusOK {
+		t.Fatalf("reconnect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestOpenAPISpecServed(t *testing.T) {
+	s := New(&config.Config{}, nil, nil)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil || !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Fatalf("not an OpenAPI 3 document: %v", err)
+	}
+}
+
+// TestOpenAPISpecInSync fails when a route is registered or a response type
+// gains a field without openapi.json being updated.
+func TestOpenAPISpecInSync(t *testing.T) {
+	var spec struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+
+	sources, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	routeRE := regexp.MustCompile(`HandleFunc\("([A-Z]+) ([^"]+)"`)
+	routes := 0
+	for _, name := range sources {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range routeRE.FindAllStringSubmatch(string(src), -1) {
+			routes++
+			method, pattern := strings.ToLower(m[1]), m[2]
+			documented := false
+			for path, ops := range spec.Paths {
+				// Subtree patterns ("/operator/") are documented by their
+				// concrete paths ("/operator/{address}/rep").
+				matches := path == pattern || (strings.HasSuffix(pattern, "/") && strings.HasPrefix(path, pattern))
+				if _, ok := ops[method]; ok && matches {
+					documented = true
+					break
+				}
+			}
+			if !documented {
+				t.Errorf("%s %s is not documented in openapi.json", m[1], pattern)
+			}
+		}
+	}
+	if routes == 0 {
+		t.Fatal("no routes found; has route registration moved?")
+	}
+
+	schemas := map[string]any{
+		"ChallengeResponse":               ChallengeResponse{},
+		"TermsInfo":                       TermsInfo{},
+		"AnonymousChallengeResponse":      AnonymousChallengeResponse{},
+		"ZKProof":                         zkProofPayload{},
+		"VerifyRequest":                   verifyRequest{},
+		"VerifyResponse":                  VerifyResponse{},
+		"ConnectRequest":                  ConnectRequest{},
+		"RekeyRequest":                    RekeyRequest{},
+		"FeedbackRequest":                 FeedbackRequest{},
+		"DevicePeer":                      DevicePeer{},
+		"Quality":                         feedback.Quality{},
+		"AnonymousConnectRequest":         AnonymousConnectRequest{},
+		"ConnectResponse":                 ConnectResponse{},
+		"NodeResponse":                    NodeResponse{},
+		"DependencyStatus":                DependencyStatus{},
+		"BuildInfo":                       buildinfo.Info{},
+		"DiagnoseResponse":                DiagnoseResponse{},
+		"DiagnoseCheck":                   DiagnoseCheck{},
+		"DiagnoseWallet":                  DiagnoseWallet{},
+		"DiagnoseRepBan":                  DiagnoseRepBan{},
+		"DiagnoseDecision":                DiagnoseDecision{},
+		"CreateOperatorEnrollmentRequest": createOperatorEnrollmentRequest{},
+		"OperatorEnrollment":              OperatorEnrollment{},
+		"OperatorEnrollmentReport":        OperatorEnrollmentReport{},
+		"QuotaUsage":                      quota.Usage{},
+		"UsageDay":                        usage.Day{},
+		"Tx":                              txtracker.Tx{},
+		"SessionInfo":                     sessionmgr.SessionInfo{},
+		"SubscriptionTier":                subscriptionmgr.TierInfo{},
+		"BillingStatus":                   BillingStatus{},
+		"DrainRequest":                    DrainRequest{},
+		"DrainStatus":                     DrainStatus{},
+		"SessionSweep":                    SessionSweep{},
+		"BillingSession":                  BillingSession{},
+		"BillingSubscription":             BillingSubscription{},
+		"RepContribution":                 rep6529.RepContribution{},
+		"NetworkStats":                    noderegistry.NetworkStats{},
+		"RegionStats":                     noderegistry.RegionStats{},
+		"DNSPolicy":                       wireguard.DNSPolicy{},
+	}
+	for name, v := range schemas {
+		schema, ok := spec.Components.Schemas[name]
+		if !ok {
+			t.Errorf("schema %s missing from openapi.json", name)
+			continue
+		}
+		typ := reflect.TypeOf(v)
+		fields := map[string]bool{}
+		for i := 0; i < typ.NumField(); i++ {
+			tag, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			if tag == "" || tag == "-" {
+				continue
+			}
+			fields[tag] = true
+			if _, ok := schema.Properties[tag]; !ok {
+				t.Errorf("schema %s is missing property %q (%s.%s)", name, tag, typ.Name(), typ.Field(i).Name)
+			}
+		}
+		for prop := range schema.Properties {
+			if !fields[prop] {
+				t.Errorf("schema %s documents %q, which %s does not have", name, prop, typ.Name())
+			}
+		}
+	}
+}
+
+func TestRequestBodyLimits(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.MaxBodyBytes = 1024
+	s := New(cfg, nil, nil)
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		wantCode int
+		wantErr  string
+	}{
+		{"oversized", "/auth/challenge", `{"address":"` + strings.Repeat("a", 2048) + `"}`, http.StatusRequestEntityTooLarge, "exceeds 1024 bytes"},
+		{"unknown field", "/auth/verify", `{"message":"m","signature":"s","sig":"x"}`, http.StatusBadRequest, "unknown field"},
+		{"trailing data", "/vpn/connect", `{"session_token":"t","public_key":"k"} {}`, http.StatusBadRequest, "unexpected data"},
+		{"malformed", "/vpn/disconnect", `{"session_token":`, http.StatusBadRequest, "invalid request body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %s", tt.wantErr, rec.Body.String())
+			}
+		})
+	}
+
+	// Lenient decoding still enforces the size limit but ignores extra fields.
+	var report OperatorEnrollmentReport
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"region":"eu","future_field":1}`))
+	if !s.decodeJSON(httptest.NewRecorder(), req, &report, false) || report.Region != "eu" {
+		t.Fatalf("lenient decode failed: %+v", report)
+	}
+}
+
+// dialGRPC serves s over an in-memory listener and returns a client for it.
+func dialGRPC(t *testing.T, s *Server) gatewaypb.GatewayClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := s.GRPCServer()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return gatewaypb.NewGatewayClient(conn)
+}
+
+func TestGRPCConnectFlow(t *testing.T) {
+	stubWGOnPath(t)
+	cfg := config.DefaultConfig()
+	cfg.EnableFreeTier = true
+	wg, err := wireguard.NewManager(wireguard.Config{Interface: "wg0", Subnet: "10.8.0.0/24"})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	s := New(cfg, tierChecker{tier: nftcheck.TierFree}, wg)
+	client := dialGRPC(t, s)
+	ctx := context.Background()
+
+	key, _ := crypto.GenerateKey()
+	wallet := crypto.PubkeyToAddress(key.PublicKey)
+
+	challenge, err := client.Challenge(ctx, &gatewaypb.ChallengeRequest{Address: wallet.Hex()})
+	if err != nil {
+		t.Fatalf("Challenge: %v", err)
+	}
+	sig, err := signEnrollmentMessage(key, challenge.Message)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	verified, err := client.Verify(ctx, &gatewaypb.VerifyRequest{Message: challenge.Message, Signature: sig})
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if verified.Address != wallet.Hex() || verified.Tier != "free" || verified.SessionToken == "" {
+		t.Fatalf("Verify = %+v", verified)
+	}
+
+	connected, err := client.Connect(ctx, &gatewaypb.ConnectRequest{SessionToken: verified.SessionToken, PublicKey: "laptop-key"})
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if connected.ClientAddress == "" || connected.Tier != "free" || !connected.ExpiresAt.AsTime().After(time.Now()) {
+		t.Fatalf("Connect = %+v", connected)
+	}
+	if s.wg.GetPeer("laptop-key") == nil {
+		t.Fatal("expected peer to be provisioned")
+	}
+
+	st, err := client.Status(ctx, &gatewaypb.StatusRequest{SessionToken: verified.SessionToken})
+	if err != nil || !st.Connected || st.Tier != "free" {
+		t.Fatalf("Status = %+v, %v", st, err)
+	}
+
+	disconnected, err := client.Disconnect(ctx, &gatewaypb.DisconnectRequest{SessionToken: verified.SessionToken})
+	if err != nil || disconnected.PeersRemoved != 1 {
+		t.Fatalf("Disconnect = %+v, %v", disconnected, err)
+	}
+}
+
+func TestGRPCErrorCodes(t *testing.T) {
+	s := New(config.DefaultConfig(), tierChecker{}, nil)
+	client := dialGRPC(t, s)
+	ctx := context.Background()
+
+	_, err := client.Challenge(ctx, &gatewaypb.ChallengeRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Challenge without address: got %v, want InvalidArgument", err)
+	}
+
+	key, _ := crypto.GenerateKey()
+	challenge, err := client.Challenge(ctx, &gatewaypb.ChallengeRequest{Address: crypto.PubkeyToAddress(key.PublicKey).Hex()})
+	if err != nil {
+		t.Fatalf("Challenge: %v", err)
+	}
+	sig, _ := signEnrollmentMessage(key, challenge.Message)
+	_, err = client.Verify(ctx, &gatewaypb.VerifyRequest{Message: challenge.Message, Signature: sig})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Verify without access: got %v, want PermissionDenied", err)
+	}
+
+	_, err = client.Connect(ctx, &gatewaypb.ConnectRequest{SessionToken: "unknown", PublicKey: "key"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Connect with unknown session: got %v, want Unauthenticated", err)
+	}
+
+	st, err := client.Status(ctx, &gatewaypb.StatusRequest{SessionToken: "unknown"})
+	if err != nil || st.Connected || st.Reason != "no active session" {
+		t.Errorf("Status with unknown session = %+v, %v", st, err)
+	}
+
+	_, err = client.ListNodes(ctx, &gatewaypb.ListNodesRequest{})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("ListNodes without registry: got %v, want Unimplemented", err)
+	}
+}
+
+type tierChecker struct {
+	tier nftcheck.AccessTier
+	err  error
+}
+
+func (c tierChecker) Check(context.Context, common.Address) (nftcheck.CheckResult, error) {
+	return nftcheck.CheckResult{Tier: c.tier}, c.err
+}
+
+func (tierChecker) Invalidate(common.Address) {}
+func (tierChecker) Close()                    {}
+
+func TestAccessDiagnose(t *testing.T) {
+	wallet := common.HexToAddress("0x000000000000000000000000000000000000bEEF")
+	rpcDown := errors.New("rpc down")
+
+	tests := []struct {
+		name       string
+		checker    nftcheck.AccessChecker
+		wantFactor string
+		wantTier   string
+		allowed    bool
+	}{
+		{
+			name:       "granted by second source",
+			checker:    nftcheck.NewCompositeChecker(tierChecker{err: rpcDown}, tierChecker{tier: nftcheck.TierPaid}),
+			wantFactor: "granted",
+			wantTier:   "paid",
+			allowed:    true,
+		},
+		{
+			name:       "denied with lookup error",
+			checker:    nftcheck.NewCompositeChecker(tierChecker{err: rpcDown}, tierChecker{}),
+			wantFactor: "check_error",
+			wantTier:   "denied",
+		},
+		{
+			name:       "denied",
+			checker:    tierChecker{},
+			wantFactor: "no_access",
+			wantTier:   "denied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(config.DefaultConfig(), tt.checker, nil)
+			session := s.gate.CreateSession(wallet, nftcheck.TierPaid)
+
+			req := httptest.NewRequest(http.MethodGet, "/access/diagnose", nil)
+			req.Header.Set("Authorization", "Bearer "+session.Token)
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+
+			var resp DiagnoseResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Decode response: %v", err)
+			}
+			if resp.Address != wallet.Hex() {
+				t.Errorf("address = %s, want %s", resp.Address, wallet.Hex())
+			}
+			d := resp.Decision
+			if d.DecidingFactor != tt.wantFactor || d.Tier != tt.wantTier || d.Allowed != tt.allowed {
+				t.Fatalf("decision = %+v, want factor=%s tier=%s allowed=%v", d, tt.wantFactor, tt.wantTier, tt.allowed)
+			}
+			if tt.wantFactor == "check_error" && !strings.Contains(d.Detail, "rpc down") {
+				t.Errorf("expected detail to carry the lookup error, got %q", d.Detail)
+			}
+		})
+	}
+}
+
+func TestAccessDiagnoseRequiresAuth(t *testing.T) {
+	s := New(config.DefaultConfig(), tierChecker{}, nil)
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/access/diagnose", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("no credentials: expected 400, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/access/diagnose", nil)
+	req.Header.Set("Authorization", "Bearer unknown")
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("unknown session: expected 401, got %d", rec.Code)
+	}
+}
+
+type stubAccessChecker struct {
+	invalidated []common.Address
+}
+
+func (c *stubAccessChecker) Check(context.Context, common.Address) (nftcheck.CheckResult, error) {
+	return nftcheck.CheckResult{}, nil
+}
+
+func (c *stubAccessChecker) Invalidate(wallet common.Address) {
+	c.invalidated = append(c.invalidated, wallet)
+}
+
+func (c *stubAccessChecker) Close() {}
+
+func TestRevokerRemovesWalletPeers(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	checker := &stubAccessChecker{}
+	s.checker = checker
+	s.cfg.MaxPeersFree = 2
+
+	revoked := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	other := common.HexToAddress("0x000000000000000000000000000000000000bEEF")
+	revokedSession := s.gate.CreateSession(revoked, nftcheck.TierFree)
+	otherSession := s.gate.CreateSession(other, nftcheck.TierFree)
+
+	for _, key := range []string{"laptop-key", "phone-key"} {
+		if rec := connectPeer(t, s, revokedSession.Token, key); rec.Code != http.StatusOK {
+			t.Fatalf("connect %s: expected 200, got %d: %s", key, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := connectPeer(t, s, otherSession.Token, "other-key"); rec.Code != http.StatusOK {
+		t.Fatalf("connect other: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := s.walletPeerKeys(revoked); len(got) != 2 || got[0] != "laptop-key" {
+		t.Fatalf("expected wallet peers [laptop-key phone-key], got %v", got)
+	}
+
+	NewRevoker(s).InvalidateAndRevoke(revoked)
+
+	if len(checker.invalidated) != 1 || checker.invalidated[0] != revoked {
+		t.Fatalf("expected cache invalidated for revoked wallet, got %v", checker.invalidated)
+	}
+	if s.wg.GetPeer("laptop-key") != nil || s.wg.GetPeer("phone-key") != nil {
+		t.Fatal("expected revoked wallet's peers to be removed")
+	}
+	if got := s.walletPeerKeys(revoked); len(got) != 0 {
+		t.Fatalf("expected no tracked peers for revoked wallet, got %v", got)
+	}
+	if s.wg.GetPeer("other-key") == nil {
+		t.Fatal("expected other wallet's peer to be kept")
+	}
+	if s.gate.GetSession(revoked) != nil {
+		t.Fatal("expected revoked wallet's session to be gone")
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	s := newTestHealthServer(t)
+	s.mux.HandleFunc("GET /panic", func(http.ResponseWriter, *http.Request) {
+		var m map[string]int
+		m["boom"]++ // nil map write
+	})
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/panic", nil)
+	req.Header.Set(requestIDHeader, "req-123")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request to panicking handler: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get(requestIDHeader); got != "req-123" {
+		t.Errorf("expected request ID echoed, got %q", got)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding error body: %v", err)
+	}
+	if body["error"] != "internal server error" {
+		t.Errorf("unexpected error body: %v", body)
+	}
+
+	// The server keeps serving after the panic.
+	resp, err = http.Get(ts.URL + "/livez")
+	if err != nil {
+		t.Fatalf("request after panic: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 after panic, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get(requestIDHeader) == "" {
+		t.Error("expected a generated request ID")
+	}
+}
+
+func TestHandleAdminTxs(t *testing.T) {
+	s := newTestHealthServer(t)
+
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin/txs", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("secret"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without admin token configured, got %d", rec.Code)
+	}
+
+	s.SetAdminToken("secret")
+	tracker := txtracker.New(nil, 0)
+	tracker.Track(common.HexToHash("0x01"), "heartbeat", "heartbeat")
+	s.SetTxTracker(tracker)
+
+	if rec := get(""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", rec.Code)
+	}
+	if rec := get("wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with wrong token, got %d", rec.Code)
+	}
+
+	rec := get("secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Counts       map[string]int `json:"counts"`
+		Transactions []txtracker.Tx `json:"transactions"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if body.Counts[txtracker.StatusPending] != 1 || len(body.Transactions) != 1 {
+		t.Fatalf("unexpected body: %+v", body)
+	}
+	if body.Transactions[0].Method != "heartbeat" {
+		t.Errorf("expected heartbeat tx, got %+v", body.Transactions[0])
+	}
+}
+
+func TestDrainRefusesConnectsButKeepsPeers(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	s.SetAdminToken("secret")
+
+	admin := func(path string) DrainStatus {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", path, rec.Code, rec.Body.String())
+		}
+		var status DrainStatus
+		json.Unmarshal(rec.Body.Bytes(), &status)
+		return status
+	}
+
+	session := s.gate.CreateSession(common.HexToAddress("0x000000000000000000000000000000000000dEaD"), nftcheck.TierFree)
+	if rec := connectPeer(t, s, session.Token, "before-key"); rec.Code != http.StatusOK {
+		t.Fatalf("connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	status := admin("/admin/drain")
+	if !status.Draining || status.ActivePeers != 1 || status.LastPeerExpiresAt == "" {
+		t.Errorf("unexpected drain status: %+v", status)
+	}
+
+	rec := connectPeer(t, s, session.Token, "during-key")
+	var resp map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusServiceUnavailable || resp["code"] != errCodeDraining {
+		t.Fatalf("connect while draining: got %d %s, want 503 with code %s", rec.Code, rec.Body.String(), errCodeDraining)
+	}
+	if s.wg.GetPeer("before-key") == nil {
+		t.Error("drain removed an existing peer")
+	}
+
+	if status := admin("/admin/undrain"); status.Draining {
+		t.Errorf("still draining after undrain: %+v", status)
+	}
+	if rec := connectPeer(t, s, session.Token, "during-key"); rec.Code != http.StatusOK {
+		t.Fatalf("connect after undrain: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestStartDrainedPausesLaterHeartbeatSender(t *testing.T) {
+	s := newTestHealthServer(t)
+	s.SetDraining(true, true)
+
+	key, _ := crypto.GenerateKey()
+	hb, err := noderegistry.NewHeartbeatSenderWithClient(ethrpc.NewFake(), "0x0000000000000000000000000000000000005e55", hexutil.Encode(crypto.FromECDSA(key))[2:], 1, time.Minute)
+	if err != nil {
+		t.Fatalf("NewHeartbeatSenderWithClient: %v", err)
+	}
+	s.SetHeartbeatSender(hb)
+	if !hb.Paused() {
+		t.Error("heartbeat sender set after a drain with stop_heartbeat is not paused")
+	}
+	if status := s.drainStatus(); !status.Draining || !status.HeartbeatStopped {
+		t.Errorf("drain status = %+v, want draining with heartbeat stopped", status)
+	}
+}
+
+func TestAdminSessionCleanupClosesStaleSessions(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	s.SetAdminToken("secret")
+
+	contract := common.HexToAddress("0x0000000000000000000000000000000000005e55")
+	key, _ := crypto.GenerateKey()
+	node := crypto.PubkeyToAddress(key.PublicKey)
+	now := time.Now().Unix()
+	type onChain struct {
+		user                         common.Address
+		payment, startedAt, duration int64
+	}
+	connected := common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	sessions := map[int64]onChain{
+		1: {connected, 0, now - 7200, 86400},                             // peer connected
+		2: {common.HexToAddress("0xb0b"), 0, now - 3600, 86400},          // free, no peer: stale
+		3: {common.HexToAddress("0xca11"), 0, now - 60, 86400},           // free, within grace
+		4: {common.HexToAddress("0xd0d0"), 1e15, now - 3600, 4 * 3600},   // paid, time left
+		5: {common.HexToAddress("0xe1e1"), 1e15, now - 5*3600, 4 * 3600}, // paid, run out: stale
+	}
+	client := ethrpc.NewFake()
+	client.HandleCalls(contract, func(call ethereum.CallMsg) ([]byte, error) {
+		words := func(ws ...common.Hash) []byte {
+			var out []byte
+			for _, w := range ws {
+				out = append(out, w.Bytes()...)
+			}
+			return out
+		}
+		switch {
+		case bytes.HasPrefix(call.Data, crypto.Keccak256([]byte("getActiveNodeSessions(address)"))[:4]):
+			if common.BytesToAddress(call.Data[4:36]) != node {
+				return nil, errors.New("unexpected node")
+			}
+			ws := []common.Hash{common.BigToHash(big.NewInt(32)), common.BigToHash(big.NewInt(int64(len(sessions))))}
+			for id := int64(1); id <= int64(len(sessions)); id++ {
+				ws = append(ws, common.BigToHash(big.NewInt(id)))
+			}
+			return words(ws...), nil
+		case bytes.HasPrefix(call.Data, crypto.Keccak256([]byte("getSession(uint256)"))[:4]):
+			sess := sessions[new(big.Int).SetBytes(call.Data[4:36]).Int64()]
+			// (user, node, payment, startedAt, duration, active, settled)
+			return words(
+				common.BytesToHash(sess.user.Bytes()),
+				common.BytesToHash(node.Bytes()),
+				common.BigToHash(big.NewInt(sess.payment)),
+				common.BigToHash(big.NewInt(sess.startedAt)),
+				common.BigToHash(big.NewInt(sess.duration)),
+				common.BigToHash(big.NewInt(1)),
+				common.Hash{},
+			), nil
+		}
+		return nil, errors.New("unexpected call")
+	})
+	sm, err := sessionmgr.NewWithClient(client, contract.Hex(), hexutil.Encode(crypto.FromECDSA(key))[2:], 1)
+	if err != nil {
+		t.Fatalf("sessionmgr.NewWithClient: %v", err)
+	}
+	defer sm.Close()
+	s.SetSessionManager(sm)
+
+	session := s.gate.CreateSession(connected, nftcheck.TierFree)
+	if rec := connectPeer(t, s, session.Token, "connected-key"); rec.Code != http.StatusOK {
+		t.Fatalf("connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	cleanup := func(query string) SessionSweep {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/admin/sessions/cleanup"+query, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("cleanup%s: expected 200, got %d: %s", query, rec.Code, rec.Body.String())
+		}
+		var sweep SessionSweep
+		json.Unmarshal(rec.Body.Bytes(), &sweep)
+		return sweep
+	}
+
+	sweep := cleanup("?dry_run=true")
+	if !sweep.DryRun || sweep.Node != node.Hex() || sweep.Active != 5 || sweep.Live != 1 ||
+		!reflect.DeepEqual(sweep.Stale, []uint64{2, 5}) {
+		t.Errorf("dry run sweep = %+v, want sessions 2 and 5 stale of 5 active, 1 live", sweep)
+	}
+	if len(client.Sent()) != 0 {
+		t.Fatal("dry run sent transactions")
+	}
+
+	if sweep := cleanup(""); sweep.DryRun || !reflect.DeepEqual(sweep.Stale, []uint64{2, 5}) {
+		t.Errorf("sweep = %+v, want sessions 2 and 5 closed", sweep)
+	}
+	if err := sm.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	closed := map[int64]bool{}
+	selector := crypto.Keccak256([]byte("closeSession(uint256)"))[:4]
+	for _, tx := range client.Sent() {
+		if !bytes.HasPrefix(tx.Data(), selector) {
+			t.Fatalf("sent %x, want closeSession", tx.Data())
+		}
+		closed[new(big.Int).SetBytes(tx.Data()[4:36]).Int64()] = true
+	}
+	if !reflect.DeepEqual(closed, map[int64]bool{2: true, 5: true}) {
+		t.Errorf("closed sessions %v, want 2 and 5", closed)
+	}
+}
+
+func TestAdminSessionCleanupWithStubSessionManager(t *testing.T) {
+	s := newTestHealthServer(t)
+	s.SetAdminToken("secret")
+
+	contract := common.HexToAddress("0x00000000000000000000000000000000000de002")
+	key, _ := crypto.GenerateKey()
+	client := ethrpc.NewFake()
+	client.HandleCalls(contract, sessionmgr.StubCalls(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1e9), 24*time.Hour))
+	sm, err := sessionmgr.NewWithClient(client, contract.Hex(), hexutil.Encode(crypto.FromECDSA(key))[2:], 1)
+	if err != nil {
+		t.Fatalf("sessionmgr.NewWithClient: %v", err)
+	}
+	defer sm.Close()
+	s.SetSessionManager(sm)
+	if _, err := sm.GetActiveSessionID(context.Background(), common.HexToAddress("0xb0b")); err != nil {
+		t.Fatalf("GetActiveSessionID: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/sessions/cleanup", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("cleanup: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var sweep SessionSweep
+	json.Unmarshal(rec.Body.Bytes(), &sweep)
+	if sweep.Active != 1 || len(sweep.Stale) != 0 {
+		t.Errorf("sweep = %+v, want one active paid session left open", sweep)
+	}
+}
+
+func TestSessionSweeperOffWithoutNodeSessionList(t *testing.T) {
+	s := newTestHealthServer(t)
+	s.SetAdminToken("secret")
+
+	contract := common.HexToAddress("0x0000000000000000000000000000000000005e55")
+	key, _ := crypto.GenerateKey()
+	client := ethrpc.NewFake()
+	// An older SessionManager: the call hits no function and returns nothing.
+	client.HandleCalls(contract, func(ethereum.CallMsg) ([]byte, error) { return nil, nil })
+	sm, err := sessionmgr.NewWithClient(client, contract.Hex(), hexutil.Encode(crypto.FromECDSA(key))[2:], 1)
+	if err != nil {
+		t.Fatalf("sessionmgr.NewWithClient: %v", err)
+	}
+	defer sm.Close()
+	s.SetSessionManager(sm)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if s.StartSessionSweeper(ctx, time.Minute) {
+		t.Error("StartSessionSweeper started against a contract that cannot list sessions")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/sessions/cleanup?dry_run=true", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("cleanup: expected 503, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestVPNSessionsListsWalletDevices(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	s.cfg.MaxPeersFree = 2
+
+	wallet := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	other := s.gate.CreateSession(common.HexToAddress("0x000000000000000000000000000000000000bEEF"), nftcheck.TierFree)
+	// Signing in again replaces the wallet's session; the laptop's peer
+	// stays up under the old one.
+	var phone *nftgate.Session
+	for _, key := range []string{"laptop-key", "phone-key"} {
+		phone = s.gate.CreateSession(wallet, nftcheck.TierFree)
+		if rec := connectPeer(t, s, phone.Token, key); rec.Code != http.StatusOK {
+			t.Fatalf("connect %s: expected 200, got %d: %s", key, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := connectPeer(t, s, other.Token, "other-key"); rec.Code != http.StatusOK {
+		t.Fatalf("connect other-key: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	list := func(token string) (int, []DevicePeer) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/vpn/sessions?session_token="+url.QueryEscape(token), nil))
+		var resp struct {
+			Devices []DevicePeer `json:"devices"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp.Devices
+	}
+
+	code, devices := list(phone.Token)
+	if code != http.StatusOK || len(devices) != 2 {
+		t.Fatalf("expected the wallet's 2 devices, got %d %+v", code, devices)
+	}
+	if devices[0].PublicKey != "laptop-key" || devices[0].Current || devices[1].PublicKey != "phone-key" || !devices[1].Current {
+		t.Errorf("unexpected devices: %+v", devices)
+	}
+	if devices[0].AssignedIP == "" || devices[0].ExpiresAt == "" || devices[0].LastHandshake != "" {
+		t.Errorf("unexpected device details: %+v", devices[0])
+	}
+
+	if code, _ := list("no-such-token"); code != http.StatusUnauthorized {
+		t.Errorf("unknown session: expected 401, got %d", code)
+	}
+
+	// The listed devices can be disconnected one at a time, but not
+	// another wallet's.
+	disconnect := func(key string) int {
+		body, _ := json.Marshal(ConnectRequest{SessionToken: phone.Token, PublicKey: key})
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/vpn/disconnect", bytes.NewReader(body)))
+		return rec.Code
+	}
+	if code := disconnect("other-key"); code != http.StatusForbidden {
+		t.Errorf("other wallet's key: expected 403, got %d", code)
+	}
+	if code := disconnect("laptop-key"); code != http.StatusOK {
+		t.Errorf("earlier session's key: expected 200, got %d", code)
+	}
+	if _, devices := list(phone.Token); len(devices) != 1 || devices[0].PublicKey != "phone-key" {
+		t.Errorf("expected only the phone left, got %+v", devices)
+	}
+}
+
+func TestFeedbackFromConnectedSessionsFeedsVersion(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	s.SetFeedbackStore(feedback.NewStore(0, 0))
+
+	post := func(req FeedbackRequest) *httptest.ResponseRecorder {
+		t.Helper()
+		body, _ := json.Marshal(req)
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/vpn/feedback", bytes.NewReader(body)))
+		return rec
+	}
+	quality := func() *feedback.Quality {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+		var resp struct {
+			Quality *feedback.Quality `json:"quality"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp.Quality
+	}
+
+	var tokens []string
+	for i, wallet := range []string{"0x0000000000000000000000000000000000000001", "0x0000000000000000000000000000000000000002", "0x0000000000000000000000000000000000000003"} {
+		session := s.gate.CreateSession(common.HexToAddress(wallet), nftcheck.TierFree)
+		key := "feedback-key-" + strconv.Itoa(i)
+		if rec := connectPeer(t, s, session.Token, key); rec.Code != http.StatusOK {
+			t.Fatalf("connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		tokens = append(tokens, session.Token)
+	}
+
+	if rec := post(FeedbackRequest{SessionToken: tokens[0], PublicKey: "feedback-key-1", LatencyMS: 30}); rec.Code != http.StatusForbidden {
+		t.Errorf("feedback on another session's peer: expected 403, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := post(FeedbackRequest{SessionToken: tokens[0], PublicKey: "feedback-key-0", LatencyMS: 30, FailureRate: 2}); rec.Code != http.StatusBadRequest {
+		t.Errorf("impossible failure rate: expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// The first wallet reports twice; only its latest report counts.
+	for i, latency := range []float64{900, 30, 40, 50} {
+		n := max(i-1, 0)
+		key := "feedback-key-" + strconv.Itoa(n)
+		if rec := post(FeedbackRequest{SessionToken: tokens[n], PublicKey: key, LatencyMS: latency}); rec.Code != http.StatusOK {
+			t.Fatalf("feedback: expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if i < 3 && quality() != nil {
+			t.Fatalf("quality published after %d reporters", n+1)
+		}
+	}
+	q := quality()
+	if q == nil || q.Reports != 3 || q.LatencyMS != 40 || q.Score != 100 {
+		t.Errorf("quality = %+v, want 3 reports with median latency 40", q)
+	}
+}
+
+func TestHandleMetrics(t *testing.T) {
+	s := newTestHealthServer(t)
+	s.SetAdminToken("secret")
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", rec.Code)
+	}
+
+	metrics.ObserveRPC("checkAccess", time.Now(), context.DeadlineExceeded)
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE svpn_rpc_call_duration_seconds histogram",
+		`svpn_rpc_errors_total{method="checkAccess",kind="timeout"}`,
+		"# TYPE svpn_sessions gauge",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestVerifyReportsRPCFailureAsRetryable(t *testing.T) {
+	s := newTestHealthServer(t)
+	s.checker = tierChecker{err: errors.New("calling AccessPolicy.checkAccess: connection refused")}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	challenge, err := s.siwe.NewChallenge(16)
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	message := siwe.FormatMessage(challenge, crypto.PubkeyToAddress(key.PublicKey).Hex())
+	sig, err := signEnrollmentMessage(key, message)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	body, _ := json.Marshal(map[string]string{"message": message, "signature": sig})
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/verify", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Retry-After") == "" || !strings.Contains(rec.Body.String(), errCodeUnavailable) {
+		t.Errorf("expected a retryable 503, got headers %v body %s", rec.Header(), rec.Body.String())
+	}
+}
+
+func TestOperationalLogsOmitWallet(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	s.checker = tierChecker{tier: nftcheck.TierFree}
+	s.freeTier = true
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		connlog.SetEnabled(true)
+	})
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	wallet := crypto.PubkeyToAddress(key.PublicKey)
+	challenge, err := s.siwe.NewChallenge(16)
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	message := siwe.FormatMessage(challenge, wallet.Hex())
+	sig, err := signEnrollmentMessage(key, message)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	body, _ := json.Marshal(map[string]string{"message": message, "signature": sig})
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/verify", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("verify: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var verified VerifyResponse
+	json.Unmarshal(rec.Body.Bytes(), &verified)
+	if rec := connectPeer(t, s, verified.SessionToken, "logged-key"); rec.Code != http.StatusOK {
+		t.Fatalf("connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	out := strings.ToLower(logs.String())
+	if !strings.Contains(out, "access granted") || !strings.Contains(out, "vpn connected") {
+		t.Fatalf("expected sign-in and connect lines, got:\n%s", logs.String())
+	}
+	if strings.Contains(out, strings.ToLower(wallet.Hex()[2:])) {
+		t.Errorf("log names the wallet:\n%s", logs.String())
+	}
+
+	// With private logs the connect leaves no line at all.
+	connlog.SetEnabled(false)
+	logs.Reset()
+	if rec := connectPeer(t, s, verified.SessionToken, "quiet-key"); rec.Code != http.StatusOK {
+		t.Fatalf("private connect: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if logs.Len() != 0 {
+		t.Errorf("private logs still logged:\n%s", logs.String())
+	}
+}
+
+func TestVerifyMarksStaleChallenge(t *testing.T) {
+	s := newTestHealthServer(t)
+	s.checker = tierChecker{tier: nftcheck.TierPaid}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	challenge, err := s.siwe.NewChallenge(16)
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	// A nonce the gateway no longer holds, as after the challenge TTL.
+	challenge.Nonce = "stalenonce0000000000"
+	message := siwe.FormatMessage(challenge, crypto.PubkeyToAddress(key.PublicKey).Hex())
+	sig, err := signEnrollmentMessage(key, message)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	post := func(sig string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"message": message, "signature": sig})
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/verify", bytes.NewReader(body)))
+		return rec
+	}
+
+	rec := post(sig)
+	var resp map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusUnauthorized || resp["code"] != errCodeChallengeExpired {
+		t.Fatalf("stale nonce: got %d %s, want 401 with code %s", rec.Code, rec.Body.String(), errCodeChallengeExpired)
+	}
+
+	// A bad signature is not fixed by a new challenge and carries no code.
+	rec = post("0x" + strings.Repeat("00", 65))
+	resp = nil
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusUnauthorized || resp["code"] != "" {
+		t.Errorf("bad signature: got %d %s, want 401 without a code", rec.Code, rec.Body.String())
+	}
+}
+
+func TestVerifyGracePeriodAfterTransferIn(t *testing.T) {
+	s := newTestHealthServer(t)
+	s.checker = tierChecker{tier: nftcheck.TierDenied}
+	s.cfg.GracePeriod = 10 * time.Minute
+	s.cfg.GraceSessionTTL = 5 * time.Minute
+	clk := clock.NewFake(time.Now())
+	s.SetClock(clk)
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	wallet := crypto.PubkeyToAddress(key.PublicKey)
+	verify := func() *httptest.ResponseRecorder {
+		t.Helper()
+		challenge, err := s.siwe.NewChallenge(16)
+		if err != nil {
+			t.Fatalf("NewChallenge: %v", err)
+		}
+		message := siwe.FormatMessage(challenge, wallet.Hex())
+		sig, err := signEnrollmentMessage(key, message)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		body, _ := json.Marshal(map[string]string{"message": message, "signature": sig})
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/verify", bytes.NewReader(body)))
+		return rec
+	}
+
+	if rec := verify(); rec.Code != http.StatusForbidden {
+		t.Fatalf("denied wallet without a transfer-in: got %d, want 403", rec.Code)
+	}
+
+	NewRevoker(s).RecordTransferIn(wallet, []*big.Int{big.NewInt(7)})
+	rec := verify()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("denied wallet just after a transfer-in: got %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var resp VerifyResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	expires, _ := time.Parse(time.RFC3339, resp.ExpiresAt)
+	if !resp.Probationary || resp.Tier != "paid" || expires.After(clk.Now().Add(5*time.Minute)) {
+		t.Errorf("got %+v, want a paid probationary session of at most 5m", resp)
+	}
+
+	if rec := verify(); rec.Code != http.StatusForbidden {
+		t.Errorf("second sign-in on the same transfer-in: got %d, want 403", rec.Code)
+	}
+
+	// Receiving THIS card grants a free session, not a paid one.
+	s.SetThisCardID(7)
+	s.freeTier = true
+	NewRevoker(s).RecordTransferIn(wallet, []*big.Int{big.NewInt(7)})
+	rec = verify()
+	resp = VerifyResponse{}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusOK || resp.Tier != "free" {
+		t.Errorf("transfer-in of THIS card: got %d %+v, want a free session", rec.Code, resp)
+	}
+
+	NewRevoker(s).RecordTransferIn(wallet, []*big.Int{big.NewInt(7)})
+	clk.Advance(11 * time.Minute)
+	if rec := verify(); rec.Code != http.StatusForbidden {
+		t.Errorf("sign-in after the grace period: got %d, want 403", rec.Code)
+	}
+}
+
+// resultChecker returns a fixed CheckResult.
+type resultChecker struct {
+	result nftcheck.CheckResult
+}
+
+func (c *resultChecker) Check(context.Context, common.Address) (nftcheck.CheckResult, error) {
+	return c.result, nil
+}
+
+func (*resultChecker) Invalidate(common.Address) {}
+func (*resultChecker) Close()                    {}
+
+func TestAuditLogRecordsAccessDecisions(t *testing.T) {
+	stubWGOnPath(t)
+	s := newTestHealthServer(t)
+	vault := common.HexToAddress("0x000000000000000000000000000000000000cAFE")
+	checker := &resultChecker{result: nftcheck.CheckResult{Tier: nftcheck.TierPaid, Source: "direct", Vault: vault}}
+	s.checker = checker
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := audit.Open(path)
+	if err != nil {
+		t.Fatalf("audit.Open: %v", err)
+	}
+	defer auditLog.Close()
+	s.SetAuditLogger(auditLog)
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	wallet := crypto.PubkeyToAddress(key.PublicKey)
+	verify := func() *httptest.ResponseRecorder {
+		t.Helper()
+		challenge, err := s.siwe.NewChallenge(16)
+		if err != nil {
+			t.Fatalf("NewChallenge: %v", err)
+		}
+		message := siwe.FormatMessage(challenge, wallet.Hex())
+		sig, err := signEnrollmentMessage(key, message)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		body, _ := json.Marshal(map[string]string{"message": message, "signature": sig})
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/verify", bytes.NewReader(body)))
+		return rec
+	}
+
+	rec := verify()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("verify: got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp VerifyResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	// Paid sessions need an on-chain payment this server has no way to see.
+	if rec := connectPeer(t, s, resp.SessionToken, "laptop-key"); rec.Code != http.StatusPaymentRequired {
+		t.Fatalf("connect: got %d, want 402", rec.Code)
+	}
+	NewRevoker(s).InvalidateAndRevoke(wallet)
+	checker.result = nftcheck.CheckResult{Tier: nftcheck.TierDenied, Source: "direct"}
+	if rec := verify(); rec.Code != http.StatusForbidden {
+		t.Fatalf("verify after the card moved: got %d, want 403", rec.Code)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []audit.Record
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var r audit.Record
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("audit line %q: %v", line, err)
+		}
+		if r.Address != wallet.Hex() || r.Time.IsZero() {
+			t.Errorf("record %+v lacks the wallet or a timestamp", r)
+		}
+		r.Address, r.Time = "", time.Time{}
+		got = append(got, r)
+	}
+	want := []audit.Record{
+		{Event: audit.EventVerify, Outcome: audit.OutcomeGranted, Tier: "paid", Source: "direct", Vault: vault.Hex()},
+		{Event: audit.EventConnect, Outcome: audit.OutcomeDenied, Tier: "paid", Reason: "on-chain payment required for paid tier", PeerKey: "laptop-key"},
+		{Event: audit.EventRevoke, Outcome: audit.OutcomeRevoked, Reason: "nft_transferred"},
+		{Event: audit.EventVerify, Outcome: audit.OutcomeDenied, Tier: "denied", Source: "direct", Reason: "no_access"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("audit records:\n got %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/sessionmgr"
+)
+
+// DefaultStaleSessionGrace is how long a free on-chain session may go
+// without a connected peer before the sweeper closes it: the wallet opens
+// it at sign-in and usually connects within moments.
+const DefaultStaleSessionGrace = 15 * time.Minute
+
+// sessionSweepProbeTimeout bounds the call StartSessionSweeper makes to
+// check that the contract can list a node's sessions.
+const sessionSweepProbeTimeout = 10 * time.Second
+
+// SessionSweep is the result of reconciling this node's active on-chain
+// sessions with its connected peers.
+type SessionSweep struct {
+	Node   string `json:"node"`
+	DryRun bool   `json:"dry_run"`
+	Active int    `json:"active"` // active on-chain sessions attributed to this node
+	Live   int    `json:"live"`   // of those, whose wallet has a peer connected here
+	// Stale are the sessions without a peer that were closed, or would be
+	// under dry_run: free sessions older than the grace period and paid
+	// sessions that have run out.
+	Stale []uint64 `json:"stale"`
+	// Unreadable are sessions whose details could not be read; they are
+	// left alone until the next sweep.
+	Unreadable []uint64 `json:"unreadable,omitempty"`
+}
+
+// SetStaleSessionGrace sets how long a free on-chain session may go without
+// a connected peer before a sweep closes it. Zero uses
+// DefaultStaleSessionGrace.
+func (s *Server) SetStaleSessionGrace(d time.Duration) {
+	s.staleSessionGrace = d
+}
+
+// StartSessionSweeper closes this node's stale on-chain sessions every
+// interval until ctx is cancelled. Sessions are left open when the client
+// crashed or the gateway restarted before the disconnect closed them.
+//
+// It first asks the contract for this node's sessions once; if the
+// contract cannot list them (sessionmgr.ErrNodeSessionsUnsupported) no
+// sweeper is started and false is returned, rather than failing every
+// interval. Any other error is logged and the sweeper starts anyway.
+func (s *Server) StartSessionSweeper(ctx context.Context, interval time.Duration) bool {
+	probeCtx, cancel := context.WithTimeout(ctx, sessionSweepProbeTimeout)
+	_, err := s.sessionMgr.GetActiveNodeSessions(probeCtx, s.sessionMgr.NodeOperator())
+	cancel()
+	if errors.Is(err, sessionmgr.ErrNodeSessionsUnsupported) {
+		log.Printf("Stale session sweep disabled: %v", err)
+		return false
+	}
+	if err != nil {
+		log.Printf("Warning: could not list on-chain sessions at startup: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.sweepSessions(ctx, false); err != nil {
+					log.Printf("Stale session sweep failed: %v", err)
+				}
+			}
+		}
+	}()
+	return true
+}
+
+// POST /admin/sessions/cleanup -- close this node's on-chain sessions that
+// have no connected peer. ?dry_run=true only reports them.
+func (s *Server) handleAdminSessionCleanup(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAdmin(w, r) {
+		return
+	}
+	if s.sessionMgr == nil {
+		writeFeatureDisabled(w, "session manager not configured")
+		return
+	}
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, "dry_run must be true or false")
+			return
+		}
+	}
+	if !dryRun && s.sessionMgr.ReadOnly() {
+		writeFeatureDisabled(w, "session manager has no signing key; only dry_run is available")
+		return
+	}
+
+	sweep, err := s.sweepSessions(r.Context(), dryRun)
+	if errors.Is(err, sessionmgr.ErrNodeSessionsUnsupported) {
+		writeFeatureDisabled(w, "the SessionManager contract cannot list a node's sessions")
+		return
+	}
+	if err != nil {
+		log.Printf("Error sweeping stale sessions: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to read on-chain sessions")
+		return
+	}
+	writeJSON(w, http.StatusOK, sweep)
+}
+
+// sweepSessions finds this node's active on-chain sessions whose wallet has
+// no peer connected here and closes the stale ones, unless dryRun. Paid
+// sessions are only closed once they have run out, so a paying wallet that
+// has not connected yet keeps its time; free sessions cost nothing to
+// reopen and are closed after the grace period.
+func (s *Server) sweepSessions(ctx context.Context, dryRun bool) (*SessionSweep, error) {
+	node := s.sessionMgr.NodeOperator()
+	ids, err := s.sessionMgr.GetActiveNodeSessions(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	grace := s.staleSessionGrace
+	if grace <= 0 {
+		grace = DefaultStaleSessionGrace
+	}
+	now := uint64(s.now().Unix())
+
+	sweep := &SessionSweep{Node: node.Hex(), DryRun: dryRun, Stale: []uint64{}}
+	for _, id := range ids {
+		onChain, err := s.sessionMgr.GetSession(ctx, id)
+		if err != nil {
+			log.Printf("Error reading on-chain session %d: %v", id, err)
+			sweep.Unreadable = append(sweep.Unreadable, id)
+			continue
+		}
+		if !onChain.Active {
+			continue // closed since the list was read
+		}
+		sweep.Active++
+		if len(s.walletPeerKeys(onChain.User)) > 0 {
+			sweep.Live++
+			continue
+		}
+		if onChain.Payment != nil && onChain.Payment.Sign() > 0 {
+			if now < onChain.StartedAt+onChain.Duration {
+				continue
+			}
+		} else if now < onChain.StartedAt+uint64(grace.Seconds()) {
+			continue
+		}
+		sweep.Stale = append(sweep.Stale, id)
+		if !dryRun {
+			s.sessionMgr.CloseSession(id)
+		}
+	}
+
+	if len(sweep.Stale) > 0 && !dryRun {
+		log.Printf("Closing %d stale on-chain session(s) with no connected peer: %v", len(sweep.Stale), sweep.Stale)
+	}
+	return sweep, nil
+}
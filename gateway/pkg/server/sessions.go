@@ -0,0 +1,91 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/wireguard"
+)
+
+// DevicePeer is one connected device in GET /vpn/sessions.
+type DevicePeer struct {
+	PublicKey     string `json:"public_key"`
+	AssignedIP    string `json:"assigned_ip"`
+	CreatedAt     string `json:"created_at"`
+	ExpiresAt     string `json:"expires_at"`
+	LastHandshake string `json:"last_handshake,omitempty"` // absent until the device has completed a handshake
+	BytesReceived uint64 `json:"bytes_received"`
+	BytesSent     uint64 `json:"bytes_sent"`
+	// Current marks peers connected with the session making the request.
+	Current bool `json:"current"`
+}
+
+// GET /vpn/sessions?session_token=<opaque-token> -- the devices the
+// session's wallet has connected, oldest first, so a user can find one to
+// disconnect or rekey. An anonymous session sees only its own peers.
+// Authorization: Bearer <opaque-token> also works.
+func (s *Server) handleVPNSessions(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("session_token")
+	if token == "" {
+		token = bearerToken(r)
+	}
+	if token == "" {
+		writeError(w, http.StatusBadRequest, "session_token required")
+		return
+	}
+	session := s.gate.GetSessionByToken(token)
+	if session == nil {
+		writeRequestError(w, errSessionNotFound)
+		return
+	}
+	if err := allowKey(s.walletLimiter, sessionLimitKey(session)); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	var keys []string
+	if session.AddressBound {
+		keys = s.walletPeerKeys(session.Address)
+	} else {
+		keys = s.sessionPeerKeys(session.ID)
+	}
+
+	peers := make(map[string]wireguard.Peer)
+	if len(keys) > 0 {
+		for _, p := range s.wg.Peers() {
+			peers[p.PublicKey] = p
+		}
+	}
+
+	devices := make([]DevicePeer, 0, len(keys))
+	for _, pubKey := range keys {
+		peer, ok := peers[pubKey]
+		if !ok {
+			continue
+		}
+		d := DevicePeer{
+			PublicKey:     pubKey,
+			AssignedIP:    peer.ClientIP,
+			CreatedAt:     peer.AssignedAt.UTC().Format(time.RFC3339),
+			ExpiresAt:     peer.ExpiresAt.UTC().Format(time.RFC3339),
+			BytesReceived: peer.BytesReceived,
+			BytesSent:     peer.BytesSent,
+			Current:       s.peerOwnedBy(pubKey, session.ID),
+		}
+		if !peer.LastHandshake.IsZero() {
+			d.LastHandshake = peer.LastHandshake.UTC().Format(time.RFC3339)
+		}
+		devices = append(devices, d)
+	}
+	// Anonymous sessions' keys come from a map; keep the order stable.
+	if !session.AddressBound {
+		slices.SortFunc(devices, func(a, b DevicePeer) int { return strings.Compare(a.CreatedAt, b.CreatedAt) })
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"devices": devices,
+		"count":   len(devices),
+	})
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/usage"
+)
+
+// SetUsageStore enables per-wallet usage history on GET /vpn/usage.
+func (s *Server) SetUsageStore(store *usage.Store) {
+	s.usage = store
+}
+
+// GET /vpn/usage?session_token=<opaque-token>&days=7 -- daily data usage
+// history for the session's wallet, oldest first (default 30 days).
+func (s *Server) handleVPNUsage(w http.ResponseWriter, r *http.Request) {
+	if s.usage == nil {
+		writeFeatureDisabled(w, "usage history not enabled")
+		return
+	}
+
+	token := r.URL.Query().Get("session_token")
+	if token == "" {
+		token = bearerToken(r)
+	}
+	if token == "" {
+		writeError(w, http.StatusBadRequest, "session_token required")
+		return
+	}
+	session := s.gate.GetSessionByToken(token)
+	if session == nil {
+		writeError(w, http.StatusUnauthorized, "session expired or not found, re-authenticate")
+		return
+	}
+	if !session.AddressBound {
+		writeError(w, http.StatusBadRequest, "usage history is not kept for anonymous sessions")
+		return
+	}
+
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "days must be a positive integer")
+			return
+		}
+		days = n
+	}
+
+	history, err := s.usage.History(walletKey(session.Address), days)
+	if err != nil {
+		log.Printf("[usage] Error reading usage history: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to read usage history")
+		return
+	}
+
+	var total uint64
+	for _, d := range history {
+		total += d.Bytes
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"days":        history,
+		"total_bytes": total,
+	})
+}
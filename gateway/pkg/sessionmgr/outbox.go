@@ -0,0 +1,251 @@
+package sessionmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbox"
+)
+
+// Writes recorded in the outbox, by Entry.Method.
+const (
+	writeOpenFreeSession = "openFreeSession" // params: user, node, duration
+	writeCloseSession    = "closeSession"    // params: session_id
+	writeCloseSessionFor = "closeSessionFor" // params: user, and session_id once looked up
+)
+
+// errUnknownWrite marks an outbox entry this version cannot make.
+var errUnknownWrite = errors.New("unknown write")
+
+// SetOutbox records every write in o before it is sent and removes it once
+// mined. Call ResumeOutbox to make what a previous run left in o.
+func (m *Manager) SetOutbox(o *outbox.Store) {
+	m.outbox = o
+}
+
+// ResumeOutbox makes the writes left in the outbox by a previous run, then
+// every interval until ctx ends removes mined writes from it and retries
+// the rest: unsigned writes are signed again, and signed ones unmined after
+// an interval are rebroadcast unchanged.
+func (m *Manager) ResumeOutbox(ctx context.Context, interval time.Duration) {
+	go func() {
+		m.retryOutbox(ctx, 0)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.retryOutbox(ctx, interval)
+			}
+		}
+	}()
+}
+
+// write records a fire-and-forget write in the outbox, if there is one, and
+// makes it in the background. A write already pending is not repeated.
+func (m *Manager) write(key, method string, params map[string]string) {
+	if e, ok := m.record(key, method, params); ok {
+		m.goWrite(e)
+	}
+}
+
+// record adds a write to the outbox, if there is one. It returns false if
+// the same write is already pending.
+func (m *Manager) record(key, method string, params map[string]string) (outbox.Entry, bool) {
+	e := outbox.Entry{Key: key, Method: method, Params: params}
+	if m.outbox == nil {
+		return e, true
+	}
+	recorded, added, err := m.outbox.Add(key, method, params)
+	switch {
+	case err != nil:
+		log.Printf("[sessionmgr] Warning: %v; sending without a record", err)
+		return e, true
+	case !added:
+		return e, false
+	}
+	return recorded, true
+}
+
+// perform signs and sends the write e describes. If that fails e stays in
+// the outbox for the next retry.
+func (m *Manager) perform(e outbox.Entry) {
+	if e.Method == writeCloseSessionFor && e.Params["session_id"] == "" {
+		var active bool
+		var err error
+		if e, active, err = m.pinActiveSession(e); err != nil {
+			log.Printf("[sessionmgr] Error preparing %s: %v", e.Method, err)
+			return
+		}
+		if !active {
+			m.outboxDone(e) // no active session on-chain
+			return
+		}
+	}
+	callData, method, err := m.callData(e)
+	if err != nil {
+		log.Printf("[sessionmgr] Error preparing %s: %v", e.Method, err)
+		if errors.Is(err, errUnknownWrite) {
+			m.outboxDone(e)
+		}
+		return
+	}
+	m.sendTx(callData, method, e.ID)
+}
+
+// callData packs the contract call for e and names its method.
+func (m *Manager) callData(e outbox.Entry) ([]byte, string, error) {
+	switch e.Method {
+	case writeOpenFreeSession:
+		duration, err := strconv.ParseUint(e.Params["duration"], 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("duration: %w", err)
+		}
+		data, err := m.abi.Pack("openFreeSession",
+			common.HexToAddress(e.Params["user"]),
+			common.HexToAddress(e.Params["node"]),
+			new(big.Int).SetUint64(duration))
+		return data, "openFreeSession", err
+	case writeCloseSession, writeCloseSessionFor:
+		sessionID, err := strconv.ParseUint(e.Params["session_id"], 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("session_id: %w", err)
+		}
+		data, err := m.abi.Pack("closeSession", new(big.Int).SetUint64(sessionID))
+		return data, "closeSession", err
+	}
+	return nil, "", fmt.Errorf("%w %q", errUnknownWrite, e.Method)
+}
+
+// pinActiveSession looks up the session a closeSessionFor write closes and
+// records its ID in the outbox, so retries close that session even if the
+// user has opened another since. active is false if there is none.
+func (m *Manager) pinActiveSession(e outbox.Entry) (pinned outbox.Entry, active bool, err error) {
+	sessionID, err := m.GetActiveSessionID(context.Background(), common.HexToAddress(e.Params["user"]))
+	if err != nil {
+		return e, false, fmt.Errorf("getting active session: %w", err)
+	}
+	if sessionID == 0 {
+		return e, false, nil
+	}
+	id := strconv.FormatUint(sessionID, 10)
+	if m.outbox != nil && e.ID != 0 {
+		if err := m.outbox.SetParam(e.ID, "session_id", id); err != nil {
+			return e, false, err
+		}
+	}
+	e.Params = maps.Clone(e.Params)
+	e.Params["session_id"] = id
+	return e, true, nil
+}
+
+// retryOutbox removes mined writes from the outbox and retries the others
+// that are not being written now. Unsigned writes are made one after
+// another, oldest first, so a user's open is never sent after their close.
+// Signed writes are only rebroadcast once they have gone unmined for
+// resendAfter.
+func (m *Manager) retryOutbox(ctx context.Context, resendAfter time.Duration) {
+	if m.outbox == nil {
+		return
+	}
+	pending, err := m.outbox.Pending()
+	if err != nil {
+		log.Printf("[sessionmgr] Error reading outbox: %v", err)
+		return
+	}
+	var unsigned []outbox.Entry
+	for _, e := range pending {
+		if m.isPerforming(e.ID) {
+			continue
+		}
+		if e.RawTx == nil {
+			unsigned = append(unsigned, e)
+			continue
+		}
+
+		receipt, err := m.client.TransactionReceipt(ctx, common.HexToHash(e.TxHash))
+		if err == nil {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				log.Printf("[sessionmgr] %s tx %s reverted", e.Method, e.TxHash)
+			}
+			m.outboxDone(e)
+			continue
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			log.Printf("[sessionmgr] Error reading receipt for %s: %v", e.TxHash, err)
+			continue
+		}
+		if time.Since(e.SentAt) >= resendAfter {
+			m.resend(ctx, e)
+		}
+	}
+	if len(unsigned) > 0 {
+		m.goWrite(unsigned...)
+	}
+}
+
+// resend rebroadcasts e's signed transaction. If its nonce has been used it
+// may be by this very transaction, mined since the receipt was checked, so
+// the receipt is read again; only if it is still missing can the
+// transaction never be mined, and the write is signed afresh.
+func (m *Manager) resend(ctx context.Context, e outbox.Entry) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(e.RawTx); err != nil {
+		log.Printf("[sessionmgr] Error decoding outbox tx %s: %v", e.TxHash, err)
+		return
+	}
+	err := m.client.SendTransaction(ctx, tx)
+	switch {
+	case err == nil:
+		log.Printf("[sessionmgr] %s tx rebroadcast: %s", e.Method, e.TxHash)
+	case strings.Contains(err.Error(), "already known"):
+		// still waiting in the mempool
+	case strings.Contains(err.Error(), "nonce too low"):
+		_, err := m.client.TransactionReceipt(ctx, tx.Hash())
+		if err == nil {
+			m.outboxDone(e) // mined after all
+			return
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			log.Printf("[sessionmgr] Error reading receipt for %s: %v", e.TxHash, err)
+			return
+		}
+		if err := m.outbox.ClearSigned(e.ID); err != nil {
+			log.Printf("[sessionmgr] Error: %v", err)
+			return
+		}
+		e.RawTx, e.TxHash = nil, ""
+		m.goWrite(e)
+	default:
+		log.Printf("[sessionmgr] Error rebroadcasting %s tx %s: %v", e.Method, e.TxHash, err)
+	}
+}
+
+func (m *Manager) isPerforming(id uint64) bool {
+	m.writesMu.Lock()
+	defer m.writesMu.Unlock()
+	return m.performing[id]
+}
+
+// outboxDone removes e from the outbox, if it was recorded there.
+func (m *Manager) outboxDone(e outbox.Entry) {
+	if m.outbox == nil || e.ID == 0 {
+		return
+	}
+	if err := m.outbox.Done(e.ID); err != nil {
+		log.Printf("[sessionmgr] Error: %v", err)
+	}
+}
@@ -3,9 +3,11 @@ package sessionmgr
 import (
 	"context"
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -14,21 +16,47 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ethrpc"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/gaslimit"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/metrics"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbox"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/txtracker"
 )
 
+// Client is the Ethereum client a Manager reads the contract and sends
+// transactions through. *ethclient.Client satisfies it.
+type Client interface {
+	ethrpc.Caller
+	ethrpc.Transactor
+}
+
 // Manager interacts with the SessionManager smart contract for on-chain session tracking.
 type Manager struct {
-	client       *ethclient.Client
+	client       Client
 	contractAddr common.Address
 	abi          abi.ABI
 	key          *ecdsa.PrivateKey // nil = read-only (no writes)
 	signerAddr   common.Address    // derived from key — tx sender
 	nodeAddr     common.Address    // the actual node operator for session attribution
 	chainID      *big.Int
-	mu           sync.Mutex // protects nonce management
+	gas          gaslimit.Policy
+	txs          *txtracker.Tracker // optional; records submitted txs
+	mu           sync.Mutex         // protects nonce management; held for a whole send
+	keyMu        sync.RWMutex       // protects key, signerAddr, nodeAddr
+
+	writesMu   sync.Mutex      // protects draining and performing, and serializes inflight.Add with Drain
+	draining   bool            // set by Drain; new writes are dropped
+	inflight   sync.WaitGroup  // fire-and-forget writes not yet sent
+	performing map[uint64]bool // outbox entries being written now
+
+	outbox *outbox.Store // optional; records writes until they are mined
 }
 
+// fallbackGasLimit is used when eth_estimateGas fails.
+const fallbackGasLimit = 150000
+
 // SessionInfo holds pricing and contract details returned by GET /session/info.
 type SessionInfo struct {
 	Contract     string `json:"contract"`
@@ -76,6 +104,13 @@ const sessionManagerABI = `[
 		"stateMutability": "view",
 		"type": "function"
 	},
+	{
+		"inputs": [{"name": "node", "type": "address"}],
+		"name": "getActiveNodeSessions",
+		"outputs": [{"name": "", "type": "uint256[]"}],
+		"stateMutability": "view",
+		"type": "function"
+	},
 	{
 		"inputs": [],
 		"name": "pricePerHour",
@@ -122,11 +157,21 @@ const sessionManagerABI = `[
 
 // New creates a SessionManager client. If privateKeyHex is empty, operates in read-only mode.
 func New(rpcURL, contractAddr, privateKeyHex string, chainID int64) (*Manager, error) {
-	client, err := ethclient.Dial(rpcURL)
+	client, err := outbound.Dial(rpcURL)
 	if err != nil {
 		return nil, fmt.Errorf("connecting to Ethereum RPC: %w", err)
 	}
+	m, err := NewWithClient(client, contractAddr, privateKeyHex, chainID)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return m, nil
+}
 
+// NewWithClient creates a SessionManager client on an existing client, e.g.
+// an ethrpc.Fake in tests. Close closes the client.
+func NewWithClient(client Client, contractAddr, privateKeyHex string, chainID int64) (*Manager, error) {
 	parsed, err := abi.JSON(strings.NewReader(sessionManagerABI))
 	if err != nil {
 		return nil, fmt.Errorf("parsing SessionManager ABI: %w", err)
@@ -137,6 +182,7 @@ func New(rpcURL, contractAddr, privateKeyHex string, chainID int64) (*Manager, e
 		contractAddr: common.HexToAddress(contractAddr),
 		abi:          parsed,
 		chainID:      big.NewInt(chainID),
+		gas:          gaslimit.DefaultPolicy(),
 	}
 
 	if privateKeyHex != "" {
@@ -151,16 +197,55 @@ func New(rpcURL, contractAddr, privateKeyHex string, chainID int64) (*Manager, e
 	return m, nil
 }
 
+// SetGasPolicy sets how write transactions size their gas limit.
+func (m *Manager) SetGasPolicy(p gaslimit.Policy) {
+	m.gas = p
+}
+
+// SetTxTracker records submitted transactions in t for status polling.
+func (m *Manager) SetTxTracker(t *txtracker.Tracker) {
+	m.txs = t
+}
+
 // SetNodeOperator sets the node operator address used for session attribution.
 // This must be called before OpenFreeSession or GetSessionInfo if the tx signer
 // is not the node operator (e.g. signer is the contract owner, not the node).
 func (m *Manager) SetNodeOperator(addr common.Address) {
+	m.keyMu.Lock()
 	m.nodeAddr = addr
+	m.keyMu.Unlock()
+}
+
+// SetKey replaces the transaction signing key, e.g. on key rotation. It waits
+// for any in-flight send to finish so a transaction is never signed with one
+// key using the other key's nonce.
+func (m *Manager) SetKey(key *ecdsa.PrivateKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keyMu.Lock()
+	defer m.keyMu.Unlock()
+	m.key = key
+	m.signerAddr = crypto.PubkeyToAddress(key.PublicKey)
+}
+
+// hasKey reports whether a signing key is configured.
+func (m *Manager) hasKey() bool {
+	m.keyMu.RLock()
+	defer m.keyMu.RUnlock()
+	return m.key != nil
+}
+
+// ReadOnly reports whether the manager has no signing key and so cannot
+// open or close sessions.
+func (m *Manager) ReadOnly() bool {
+	return !m.hasKey()
 }
 
-// nodeOperator returns the node address for session attribution.
+// NodeOperator returns the node address for session attribution.
 // Falls back to the signer address if no explicit node operator was set.
-func (m *Manager) nodeOperator() common.Address {
+func (m *Manager) NodeOperator() common.Address {
+	m.keyMu.RLock()
+	defer m.keyMu.RUnlock()
 	if m.nodeAddr != (common.Address{}) {
 		return m.nodeAddr
 	}
@@ -168,49 +253,107 @@ func (m *Manager) nodeOperator() common.Address {
 }
 
 // OpenFreeSession sends an openFreeSession tx in a background goroutine (fire-and-forget).
-func (m *Manager) OpenFreeSession(user common.Address, durationSecs uint64) {
-	if m.key == nil {
+// sessionID is the gateway session being opened, so a wallet signing in
+// again before its last open is mined gets a second open rather than none.
+func (m *Manager) OpenFreeSession(user common.Address, sessionID string, durationSecs uint64) {
+	if !m.hasKey() {
 		log.Printf("[sessionmgr] Warning: read-only mode, cannot open session")
 		return
 	}
 
-	go func() {
-		callData, err := m.abi.Pack("openFreeSession", user, m.nodeOperator(), new(big.Int).SetUint64(durationSecs))
-		if err != nil {
-			log.Printf("[sessionmgr] Error packing openFreeSession: %v", err)
-			return
-		}
-
-		m.sendTx(callData, "openFreeSession")
-	}()
+	m.write("openFreeSession:"+sessionID, writeOpenFreeSession, map[string]string{
+		"user":     user.Hex(),
+		"node":     m.NodeOperator().Hex(),
+		"duration": strconv.FormatUint(durationSecs, 10),
+	})
 }
 
 // CloseSessionFor queries the active session ID for a user and closes it on-chain (fire-and-forget).
+// The close is recorded before the lookup, and the ID once found, so a
+// retry closes this session rather than whichever one the user has open by
+// then. Each call is its own close.
 func (m *Manager) CloseSessionFor(user common.Address) {
-	if m.key == nil {
+	if !m.hasKey() {
 		log.Printf("[sessionmgr] Warning: read-only mode, cannot close session")
 		return
 	}
 
-	go func() {
-		ctx := context.Background()
-		sessionID, err := m.GetActiveSessionID(ctx, user)
-		if err != nil {
-			log.Printf("[sessionmgr] Error getting active session: %v", err)
-			return
+	m.write("", writeCloseSessionFor, map[string]string{"user": user.Hex()})
+}
+
+// CloseSession closes the session with the given ID on-chain
+// (fire-and-forget). The signer must be the session's user, its node
+// operator or the contract owner.
+func (m *Manager) CloseSession(sessionID uint64) {
+	if !m.hasKey() {
+		log.Printf("[sessionmgr] Warning: read-only mode, cannot close session")
+		return
+	}
+	id := strconv.FormatUint(sessionID, 10)
+	m.write("closeSession:"+id, writeCloseSession, map[string]string{"session_id": id})
+}
+
+// goWrite makes the writes es in the background, one after another in
+// order, tracked so Drain can wait for them. Writes started after Drain are
+// dropped, as are outbox entries that are already being written.
+func (m *Manager) goWrite(es ...outbox.Entry) {
+	m.writesMu.Lock()
+	defer m.writesMu.Unlock()
+	if m.draining {
+		for _, e := range es {
+			log.Printf("[sessionmgr] Warning: shutting down, dropping %s", e.Method)
 		}
-		if sessionID == 0 {
-			return // no active session on-chain
+		return
+	}
+	var claimed []outbox.Entry
+	for _, e := range es {
+		if e.ID != 0 {
+			if m.performing[e.ID] {
+				continue
+			}
+			if m.performing == nil {
+				m.performing = make(map[uint64]bool)
+			}
+			m.performing[e.ID] = true
 		}
-
-		callData, err := m.abi.Pack("closeSession", new(big.Int).SetUint64(sessionID))
-		if err != nil {
-			log.Printf("[sessionmgr] Error packing closeSession: %v", err)
-			return
+		claimed = append(claimed, e)
+	}
+	if len(claimed) == 0 {
+		return
+	}
+	m.inflight.Add(1)
+	go func() {
+		defer m.inflight.Done()
+		for _, e := range claimed {
+			m.perform(e)
+			if e.ID != 0 {
+				m.writesMu.Lock()
+				delete(m.performing, e.ID)
+				m.writesMu.Unlock()
+			}
 		}
+	}()
+}
+
+// Drain stops accepting new writes and waits for in-flight ones to be sent,
+// so a shutdown does not close the client under a half-broadcast session
+// open/close. It returns ctx.Err() if ctx ends first. Call it before Close.
+func (m *Manager) Drain(ctx context.Context) error {
+	m.writesMu.Lock()
+	m.draining = true
+	m.writesMu.Unlock()
 
-		m.sendTx(callData, "closeSession")
+	done := make(chan struct{})
+	go func() {
+		m.inflight.Wait()
+		close(done)
 	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // GetActiveSessionID returns the active on-chain session ID for a user (0 = none).
@@ -228,9 +371,9 @@ func (m *Manager) GetActiveSessionID(ctx context.Context, user common.Address) (
 		return 0, fmt.Errorf("calling getActiveSessionId: %w", err)
 	}
 
-	results, err := m.abi.Unpack("getActiveSessionId", output)
+	results, err := ethrpc.Unpack(m.abi, "getActiveSessionId", output, 1)
 	if err != nil {
-		return 0, fmt.Errorf("unpacking getActiveSessionId: %w", err)
+		return 0, err
 	}
 
 	id, ok := results[0].(*big.Int)
@@ -240,6 +383,44 @@ func (m *Manager) GetActiveSessionID(ctx context.Context, user common.Address) (
 	return id.Uint64(), nil
 }
 
+// ErrNodeSessionsUnsupported is returned by GetActiveNodeSessions when the
+// contract does not implement getActiveNodeSessions: the call reverts or
+// comes back empty, as it does on SessionManagers deployed before it.
+var ErrNodeSessionsUnsupported = errors.New("SessionManager does not implement getActiveNodeSessions")
+
+// GetActiveNodeSessions returns the IDs of the active on-chain sessions
+// attributed to node, in no particular order.
+func (m *Manager) GetActiveNodeSessions(ctx context.Context, node common.Address) ([]uint64, error) {
+	callData, err := m.abi.Pack("getActiveNodeSessions", node)
+	if err != nil {
+		return nil, fmt.Errorf("packing call data: %w", err)
+	}
+
+	output, err := m.client.CallContract(ctx, ethereum.CallMsg{
+		To:   &m.contractAddr,
+		Data: callData,
+	}, nil)
+	if err != nil {
+		if metrics.RPCErrorKind(err) == metrics.RPCErrorReverted {
+			return nil, fmt.Errorf("%w: %v", ErrNodeSessionsUnsupported, err)
+		}
+		return nil, fmt.Errorf("calling getActiveNodeSessions: %w", err)
+	}
+	if len(output) == 0 {
+		return nil, ErrNodeSessionsUnsupported
+	}
+
+	var raw []*big.Int
+	if err := m.abi.UnpackIntoInterface(&raw, "getActiveNodeSessions", output); err != nil {
+		return nil, fmt.Errorf("unpacking getActiveNodeSessions: %w", err)
+	}
+	ids := make([]uint64, len(raw))
+	for i, id := range raw {
+		ids[i] = id.Uint64()
+	}
+	return ids, nil
+}
+
 // GetSessionInfo reads pricing and contract details from the on-chain SessionManager.
 func (m *Manager) GetSessionInfo(ctx context.Context) (*SessionInfo, error) {
 	// Read maxSessionDuration
@@ -251,11 +432,15 @@ func (m *Manager) GetSessionInfo(ctx context.Context) (*SessionInfo, error) {
 	if err != nil {
 		return nil, fmt.Errorf("calling maxSessionDuration: %w", err)
 	}
-	durResults, err := m.abi.Unpack("maxSessionDuration", durOut)
+	durResults, err := ethrpc.Unpack(m.abi, "maxSessionDuration", durOut, 1)
 	if err != nil {
-		return nil, fmt.Errorf("unpacking maxSessionDuration: %w", err)
+		return nil, err
 	}
-	duration := durResults[0].(*big.Int).Uint64()
+	durationBig, ok := durResults[0].(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type for maxSessionDuration: %T", durResults[0])
+	}
+	duration := durationBig.Uint64()
 
 	// Read pricePerHour
 	pphData, err := m.abi.Pack("pricePerHour")
@@ -266,11 +451,14 @@ func (m *Manager) GetSessionInfo(ctx context.Context) (*SessionInfo, error) {
 	if err != nil {
 		return nil, fmt.Errorf("calling pricePerHour: %w", err)
 	}
-	pphResults, err := m.abi.Unpack("pricePerHour", pphOut)
+	pphResults, err := ethrpc.Unpack(m.abi, "pricePerHour", pphOut, 1)
 	if err != nil {
-		return nil, fmt.Errorf("unpacking pricePerHour: %w", err)
+		return nil, err
+	}
+	pricePerHour, ok := pphResults[0].(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type for pricePerHour: %T", pphResults[0])
 	}
-	pricePerHour := pphResults[0].(*big.Int)
 
 	// Read calculatePrice(duration)
 	cpData, err := m.abi.Pack("calculatePrice", new(big.Int).SetUint64(duration))
@@ -281,22 +469,38 @@ func (m *Manager) GetSessionInfo(ctx context.Context) (*SessionInfo, error) {
 	if err != nil {
 		return nil, fmt.Errorf("calling calculatePrice: %w", err)
 	}
-	cpResults, err := m.abi.Unpack("calculatePrice", cpOut)
+	cpResults, err := ethrpc.Unpack(m.abi, "calculatePrice", cpOut, 1)
 	if err != nil {
-		return nil, fmt.Errorf("unpacking calculatePrice: %w", err)
+		return nil, err
+	}
+	cost, ok := cpResults[0].(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type for calculatePrice: %T", cpResults[0])
 	}
-	cost := cpResults[0].(*big.Int)
 
 	return &SessionInfo{
 		Contract:     m.contractAddr.Hex(),
 		ChainID:      m.chainID.Int64(),
-		NodeOperator: m.nodeOperator().Hex(),
+		NodeOperator: m.NodeOperator().Hex(),
 		PricePerHour: pricePerHour.String(),
 		Duration:     duration,
 		CostWei:      cost.String(),
 	}, nil
 }
 
+// sessionTuple is the Session struct returned by getSession. The ABI
+// decoder copies the tuple into it field by field, in ABI order, so it does
+// not depend on the exact struct type go-ethereum builds for the tuple.
+type sessionTuple struct {
+	User      common.Address
+	Node      common.Address
+	Payment   *big.Int
+	StartedAt *big.Int
+	Duration  *big.Int
+	Active    bool
+	Settled   bool
+}
+
 // GetSession reads a session's details from the on-chain SessionManager.
 func (m *Manager) GetSession(ctx context.Context, sessionID uint64) (*OnChainSession, error) {
 	callData, err := m.abi.Pack("getSession", new(big.Int).SetUint64(sessionID))
@@ -312,34 +516,13 @@ func (m *Manager) GetSession(ctx context.Context, sessionID uint64) (*OnChainSes
 		return nil, fmt.Errorf("calling getSession: %w", err)
 	}
 
-	results, err := m.abi.Unpack("getSession", output)
-	if err != nil {
+	// UnpackIntoInterface copies a lone tuple output into the first field
+	// of the destination struct.
+	var out struct{ Session sessionTuple }
+	if err := m.abi.UnpackIntoInterface(&out, "getSession", output); err != nil {
 		return nil, fmt.Errorf("unpacking getSession: %w", err)
 	}
-
-	// The ABI decoder returns the tuple as an anonymous struct
-	type sessionTuple struct {
-		User      common.Address
-		Node      common.Address
-		Payment   *big.Int
-		StartedAt *big.Int
-		Duration  *big.Int
-		Active    bool
-		Settled   bool
-	}
-
-	s, ok := results[0].(struct {
-		User      common.Address `json:"user"`
-		Node      common.Address `json:"node"`
-		Payment   *big.Int       `json:"payment"`
-		StartedAt *big.Int       `json:"startedAt"`
-		Duration  *big.Int       `json:"duration"`
-		Active    bool           `json:"active"`
-		Settled   bool           `json:"settled"`
-	})
-	if !ok {
-		return nil, fmt.Errorf("unexpected type for session tuple: %T", results[0])
-	}
+	s := out.Session
 
 	return &OnChainSession{
 		User:      s.User,
@@ -357,14 +540,19 @@ func (m *Manager) Close() {
 	m.client.Close()
 }
 
-// sendTx signs and sends a transaction to the SessionManager contract.
-// Must be called from a goroutine — logs errors instead of returning them.
-func (m *Manager) sendTx(callData []byte, method string) {
+// sendTx signs and sends a transaction to the SessionManager contract,
+// recording it in outbox entry entryID first (0 = none). Must be called
+// from a goroutine — logs errors instead of returning them.
+func (m *Manager) sendTx(callData []byte, method string, entryID uint64) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.keyMu.RLock()
+	key := m.key
+	m.keyMu.RUnlock()
+
 	ctx := context.Background()
-	from := crypto.PubkeyToAddress(m.key.PublicKey)
+	from := crypto.PubkeyToAddress(key.PublicKey)
 
 	nonce, err := m.client.PendingNonceAt(ctx, from)
 	if err != nil {
@@ -378,26 +566,45 @@ func (m *Manager) sendTx(callData []byte, method string) {
 		return
 	}
 
+	gasLimit := m.gas.Limit(ctx, m.client, ethereum.CallMsg{
+		From: from,
+		To:   &m.contractAddr,
+		Data: callData,
+	}, fallbackGasLimit)
+
 	tx := types.NewTransaction(
 		nonce,
 		m.contractAddr,
 		big.NewInt(0),
-		150000, // gas limit
+		gasLimit,
 		gasPrice,
 		callData,
 	)
 
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(m.chainID), m.key)
+	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(m.chainID), key)
 	if err != nil {
 		log.Printf("[sessionmgr] Error signing tx: %v", err)
 		return
 	}
 
+	if m.outbox != nil && entryID != 0 {
+		raw, err := signedTx.MarshalBinary()
+		if err == nil {
+			err = m.outbox.MarkSigned(entryID, signedTx.Hash().Hex(), raw)
+		}
+		if err != nil {
+			log.Printf("[sessionmgr] Warning: recording %s tx in outbox: %v", method, err)
+		}
+	}
+
 	err = m.client.SendTransaction(ctx, signedTx)
 	if err != nil {
 		log.Printf("[sessionmgr] Error sending %s tx: %v", method, err)
 		return
 	}
 
+	if m.txs != nil {
+		m.txs.Track(signedTx.Hash(), "sessionmgr", method)
+	}
 	log.Printf("[sessionmgr] %s tx sent: %s", method, signedTx.Hash().Hex())
 }
@@ -0,0 +1,568 @@
+package sessionmgr
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ethrpc"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbox"
+)
+
+// newReadManager returns a read-only Manager whose eth_calls all return output.
+func newReadManager(t *testing.T, output []byte) *Manager {
+	t.Helper()
+	contract := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	client := ethrpc.NewFake()
+	client.HandleCalls(contract, func(ethereum.CallMsg) ([]byte, error) { return output, nil })
+	m, err := NewWithClient(client, contract.Hex(), "", 1)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	t.Cleanup(m.Close)
+	return m
+}
+
+func TestReadsRejectMalformedOutput(t *testing.T) {
+	for name, output := range ethrpc.MalformedOutputs {
+		t.Run(name, func(t *testing.T) {
+			m := newReadManager(t, output)
+			ctx := context.Background()
+
+			reads := map[string]func() error{
+				"GetActiveSessionID": func() error { _, err := m.GetActiveSessionID(ctx, common.Address{}); return err },
+				"GetSessionInfo":     func() error { _, err := m.GetSessionInfo(ctx); return err },
+				"GetSession":         func() error { _, err := m.GetSession(ctx, 1); return err },
+				"GetActiveNodeSessions": func() error {
+					_, err := m.GetActiveNodeSessions(ctx, common.Address{})
+					return err
+				},
+			}
+			for read, call := range reads {
+				if err := call(); err == nil {
+					t.Errorf("%s returned no error", read)
+				}
+			}
+		})
+	}
+}
+
+func TestGetSessionRejectsOutOfRangeOffset(t *testing.T) {
+	// Whole words, but the offset points past the end of the data.
+	output := append(common.LeftPadBytes([]byte{0x20}, 32), bytes.Repeat([]byte{0xff}, 32)...)
+	m := newReadManager(t, output)
+	if _, err := m.GetSession(context.Background(), 1); err == nil {
+		t.Error("expected error decoding getSession from a bad offset")
+	}
+}
+
+func TestCloseSessionForClosesActiveSession(t *testing.T) {
+	contract := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	client := ethrpc.NewFake()
+	client.HandleCalls(contract, func(ethereum.CallMsg) ([]byte, error) {
+		return common.LeftPadBytes([]byte{7}, 32), nil // getActiveSessionId → 7
+	})
+	key, _ := crypto.GenerateKey()
+	m, err := NewWithClient(client, contract.Hex(), hex.EncodeToString(crypto.FromECDSA(key)), 1)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer m.Close()
+
+	m.CloseSessionFor(common.HexToAddress("0x000000000000000000000000000000000000dEaD"))
+	if err := m.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+
+	sent := client.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d transactions, want 1", len(sent))
+	}
+	tx := sent[0]
+	if from, err := types.Sender(types.NewEIP155Signer(tx.ChainId()), tx); err != nil || from != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Errorf("tx sender = %s, %v; want the manager's key", from.Hex(), err)
+	}
+	want, _ := m.abi.Pack("closeSession", big.NewInt(7))
+	if *tx.To() != contract || !bytes.Equal(tx.Data(), want) {
+		t.Errorf("tx to %s with data %x, want closeSession(7) on the contract", tx.To().Hex(), tx.Data())
+	}
+}
+
+func TestCloseSessionForRecordsCloseBeforeLookup(t *testing.T) {
+	contract := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	client := ethrpc.NewFake()
+	var active atomic.Int32 // 0: the RPC node is down
+	client.HandleCalls(contract, func(ethereum.CallMsg) ([]byte, error) {
+		if active.Load() == 0 {
+			return nil, errors.New("connection refused")
+		}
+		return common.LeftPadBytes([]byte{byte(active.Load())}, 32), nil // getActiveSessionId
+	})
+	key, _ := crypto.GenerateKey()
+	m, err := NewWithClient(client, contract.Hex(), hex.EncodeToString(crypto.FromECDSA(key)), 1)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer m.Close()
+	ob := openTestOutbox(t)
+	m.SetOutbox(ob)
+
+	// The lookup fails, but the close stays recorded.
+	user := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	m.CloseSessionFor(user)
+	m.inflight.Wait()
+	pending, _ := ob.Pending()
+	if len(pending) != 1 || pending[0].Method != writeCloseSessionFor || pending[0].Params["session_id"] != "" {
+		t.Fatalf("pending after a failed lookup = %+v, want the unresolved close", pending)
+	}
+
+	// The retry finds session 7 and keeps its ID, though the send fails.
+	active.Store(7)
+	client.FailSends(errors.New("connection refused"))
+	m.retryOutbox(context.Background(), 0)
+	m.inflight.Wait()
+	pending, _ = ob.Pending()
+	if len(pending) != 1 || pending[0].Params["session_id"] != "7" || pending[0].RawTx == nil {
+		t.Fatalf("pending after a failed send = %+v, want the signed close of session 7", pending)
+	}
+
+	// The user has opened session 8 by the next retry; 7 is still what closes,
+	// and a second disconnect gets a close of its own.
+	active.Store(8)
+	client.FailSends(nil)
+	m.retryOutbox(context.Background(), 0)
+	m.CloseSessionFor(user)
+	if err := m.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	sent := client.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("sent %d txs, want 2", len(sent))
+	}
+	for i, id := range []int64{7, 8} {
+		want, _ := m.abi.Pack("closeSession", big.NewInt(id))
+		if !bytes.Equal(sent[i].Data(), want) {
+			t.Errorf("tx %d data %x, want closeSession(%d)", i, sent[i].Data(), id)
+		}
+	}
+}
+
+// senderRPC accepts transactions and records the address each nonce lookup
+// was made for.
+func senderRPC(t *testing.T, senders *[]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params []any           `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		var result any = "0x1"
+		switch req.Method {
+		case "eth_getTransactionCount":
+			*senders = append(*senders, strings.ToLower(req.Params[0].(string)))
+		case "eth_sendRawTransaction":
+			result = "0x" + strings.Repeat("ab", 32)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSetKeyRotatesSigner(t *testing.T) {
+	oldKey, _ := crypto.GenerateKey()
+	newKey, _ := crypto.GenerateKey()
+
+	var senders []string
+	m, err := New(senderRPC(t, &senders).URL, "0x0000000000000000000000000000000000000001",
+		hex.EncodeToString(crypto.FromECDSA(oldKey)), 1)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer m.Close()
+
+	m.sendTx([]byte{0x01}, "test", 0)
+	m.SetKey(newKey)
+	m.sendTx([]byte{0x01}, "test", 0)
+
+	oldAddr := strings.ToLower(crypto.PubkeyToAddress(oldKey.PublicKey).Hex())
+	newAddr := strings.ToLower(crypto.PubkeyToAddress(newKey.PublicKey).Hex())
+	if len(senders) != 2 || senders[0] != oldAddr || senders[1] != newAddr {
+		t.Fatalf("senders = %v, want [%s %s]", senders, oldAddr, newAddr)
+	}
+	if got := m.NodeOperator(); got != crypto.PubkeyToAddress(newKey.PublicKey) {
+		t.Errorf("nodeOperator = %s, want rotated signer", got.Hex())
+	}
+}
+
+func TestDrainWaitsForInFlightWrites(t *testing.T) {
+	release := make(chan struct{})
+	var sent atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		var result any = "0x1"
+		if req.Method == "eth_sendRawTransaction" {
+			<-release
+			sent.Add(1)
+			result = "0x" + strings.Repeat("ab", 32)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	t.Cleanup(srv.Close)
+
+	key, _ := crypto.GenerateKey()
+	m, err := New(srv.URL, "0x0000000000000000000000000000000000000001", hex.EncodeToString(crypto.FromECDSA(key)), 1)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer m.Close()
+
+	m.OpenFreeSession(common.HexToAddress("0x000000000000000000000000000000000000dEaD"), "s1", 3600)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := m.Drain(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Drain with a blocked send = %v, want DeadlineExceeded", err)
+	}
+
+	// Writes started while draining are dropped, not sent.
+	m.OpenFreeSession(common.HexToAddress("0x000000000000000000000000000000000000bEEF"), "s2", 3600)
+
+	close(release)
+	if err := m.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if n := sent.Load(); n != 1 {
+		t.Fatalf("sent %d txs, want 1", n)
+	}
+}
+
+func openTestOutbox(t *testing.T) *outbox.Store {
+	t.Helper()
+	o, err := outbox.Open(filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatalf("outbox.Open: %v", err)
+	}
+	t.Cleanup(func() { o.Close() })
+	return o
+}
+
+func TestOutboxRebroadcastsUnminedWrite(t *testing.T) {
+	client := ethrpc.NewFake()
+	key, _ := crypto.GenerateKey()
+	m, err := NewWithClient(client, "0x0000000000000000000000000000000000000001", hex.EncodeToString(crypto.FromECDSA(key)), 1)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer m.Close()
+	ob := openTestOutbox(t)
+	m.SetOutbox(ob)
+
+	client.FailSends(errors.New("connection refused"))
+	m.OpenFreeSession(common.HexToAddress("0x000000000000000000000000000000000000dEaD"), "s1", 3600)
+	if err := m.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	pending, _ := ob.Pending()
+	if len(pending) != 1 || pending[0].RawTx == nil {
+		t.Fatalf("pending after a failed send = %+v, want the signed write", pending)
+	}
+
+	client.FailSends(nil)
+	m.retryOutbox(context.Background(), 0)
+	sent := client.Sent()
+	if len(sent) != 1 || sent[0].Hash().Hex() != pending[0].TxHash {
+		t.Fatalf("sent %d txs, want the recorded tx %s rebroadcast", len(sent), pending[0].TxHash)
+	}
+
+	m.retryOutbox(context.Background(), 0)
+	if pending, _ := ob.Pending(); len(pending) != 0 {
+		t.Errorf("pending after the tx was mined = %+v, want none", pending)
+	}
+}
+
+func TestOutboxResendSeesTxMinedSinceReceiptCheck(t *testing.T) {
+	client := ethrpc.NewFake()
+	key, _ := crypto.GenerateKey()
+	m, err := NewWithClient(client, "0x0000000000000000000000000000000000000001", hex.EncodeToString(crypto.FromECDSA(key)), 1)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer m.Close()
+	ob := openTestOutbox(t)
+	m.SetOutbox(ob)
+
+	m.OpenFreeSession(common.HexToAddress("0x000000000000000000000000000000000000dEaD"), "s1", 3600)
+	m.inflight.Wait()
+	pending, _ := ob.Pending()
+	if len(pending) != 1 || pending[0].RawTx == nil {
+		t.Fatalf("pending = %+v, want the signed open", pending)
+	}
+
+	// The open is mined between the receipt check and the rebroadcast, so
+	// its nonce is used by the very same transaction.
+	client.FailSends(errors.New("nonce too low"))
+	m.resend(context.Background(), pending[0])
+	if err := m.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if pending, _ := ob.Pending(); len(pending) != 0 {
+		t.Errorf("pending = %+v, want the mined open done, not signed again", pending)
+	}
+	if sent := client.Sent(); len(sent) != 1 {
+		t.Errorf("sent %d txs, want 1", len(sent))
+	}
+}
+
+func TestOutboxKeepsEachSignInsOpen(t *testing.T) {
+	client := ethrpc.NewFake()
+	key, _ := crypto.GenerateKey()
+	m, err := NewWithClient(client, "0x0000000000000000000000000000000000000001", hex.EncodeToString(crypto.FromECDSA(key)), 1)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer m.Close()
+	ob := openTestOutbox(t)
+	m.SetOutbox(ob)
+
+	// The wallet signs in again before its first open is mined.
+	user := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	m.OpenFreeSession(user, "s1", 3600)
+	m.inflight.Wait()
+	m.OpenFreeSession(user, "s2", 3600)
+	if err := m.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+
+	if sent := client.Sent(); len(sent) != 2 {
+		t.Errorf("sent %d txs, want an open per sign-in", len(sent))
+	}
+	if pending, _ := ob.Pending(); len(pending) != 2 {
+		t.Errorf("pending = %+v, want both opens until mined", pending)
+	}
+}
+
+func TestOutboxReplaysWritesLeftByPreviousRun(t *testing.T) {
+	client := ethrpc.NewFake()
+	key, _ := crypto.GenerateKey()
+	m, err := NewWithClient(client, "0x0000000000000000000000000000000000000001", hex.EncodeToString(crypto.FromECDSA(key)), 1)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer m.Close()
+
+	// A close recorded by a run that stopped before signing it.
+	ob := openTestOutbox(t)
+	if _, _, err := ob.Add("closeSession:9", writeCloseSession, map[string]string{"session_id": "9"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	m.SetOutbox(ob)
+
+	m.CloseSession(9) // already pending, so not written twice
+	m.retryOutbox(context.Background(), 0)
+	if err := m.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	sent := client.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d txs, want 1", len(sent))
+	}
+	want, _ := m.abi.Pack("closeSession", big.NewInt(9))
+	if !bytes.Equal(sent[0].Data(), want) {
+		t.Errorf("tx data %x, want closeSession(9)", sent[0].Data())
+	}
+
+	m.retryOutbox(context.Background(), 0)
+	if pending, _ := ob.Pending(); len(pending) != 0 {
+		t.Errorf("pending after the tx was mined = %+v, want none", pending)
+	}
+}
+
+func TestOutboxReplaysUnsignedWritesInOrder(t *testing.T) {
+	client := ethrpc.NewFake()
+	key, _ := crypto.GenerateKey()
+	m, err := NewWithClient(client, "0x0000000000000000000000000000000000000001", hex.EncodeToString(crypto.FromECDSA(key)), 1)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer m.Close()
+
+	// An open and then a close of the same user's session, neither signed.
+	user := common.HexToAddress("0x000000000000000000000000000000000000dEaD").Hex()
+	ob := openTestOutbox(t)
+	if _, _, err := ob.Add("openFreeSession:s1", writeOpenFreeSession, map[string]string{
+		"user": user, "node": user, "duration": "3600",
+	}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if _, _, err := ob.Add("closeSession:9", writeCloseSession, map[string]string{"session_id": "9"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	m.SetOutbox(ob)
+
+	m.retryOutbox(context.Background(), 0)
+	if err := m.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	sent := client.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("sent %d txs, want 2", len(sent))
+	}
+	if got, want := sent[0].Data()[:4], m.abi.Methods["openFreeSession"].ID; !bytes.Equal(got, want) {
+		t.Errorf("first tx calls %x, want openFreeSession", got)
+	}
+	if got, want := sent[1].Data()[:4], m.abi.Methods["closeSession"].ID; !bytes.Equal(got, want) {
+		t.Errorf("second tx calls %x, want closeSession", got)
+	}
+	if sent[0].Nonce() >= sent[1].Nonce() {
+		t.Errorf("nonces %d then %d, want the open first", sent[0].Nonce(), sent[1].Nonce())
+	}
+}
+
+func TestGetSessionDecodesTuple(t *testing.T) {
+	contract := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	want := sessionTuple{
+		User:      common.HexToAddress("0xa11ce"),
+		Node:      common.HexToAddress("0xb0b"),
+		Payment:   big.NewInt(1e15),
+		StartedAt: big.NewInt(1_700_000_000),
+		Duration:  big.NewInt(86400),
+		Active:    true,
+	}
+	client := ethrpc.NewFake()
+	m, err := NewWithClient(client, contract.Hex(), "", 1)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer m.Close()
+	client.HandleCalls(contract, func(call ethereum.CallMsg) ([]byte, error) {
+		return m.abi.Methods["getSession"].Outputs.Pack(want)
+	})
+
+	got, err := m.GetSession(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if got.User != want.User || got.Node != want.Node || got.Payment.Cmp(want.Payment) != 0 ||
+		got.StartedAt != 1_700_000_000 || got.Duration != 86400 || !got.Active || got.Settled {
+		t.Errorf("GetSession = %+v, want %+v", got, want)
+	}
+}
+
+func TestGetActiveNodeSessions(t *testing.T) {
+	contract := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	node := common.HexToAddress("0xb0b")
+	client := ethrpc.NewFake()
+	m, err := NewWithClient(client, contract.Hex(), "", 1)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer m.Close()
+	client.HandleCalls(contract, func(call ethereum.CallMsg) ([]byte, error) {
+		want, _ := m.abi.Pack("getActiveNodeSessions", node)
+		if !bytes.Equal(call.Data, want) {
+			t.Errorf("call data %x, want getActiveNodeSessions(%s)", call.Data, node.Hex())
+		}
+		return m.abi.Methods["getActiveNodeSessions"].Outputs.Pack([]*big.Int{big.NewInt(3), big.NewInt(9)})
+	})
+
+	ids, err := m.GetActiveNodeSessions(context.Background(), node)
+	if err != nil {
+		t.Fatalf("GetActiveNodeSessions: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != 3 || ids[1] != 9 {
+		t.Errorf("GetActiveNodeSessions = %v, want [3 9]", ids)
+	}
+}
+
+type revertError struct{}
+
+func (revertError) Error() string  { return "execution reverted" }
+func (revertError) ErrorCode() int { return 3 }
+
+func TestGetActiveNodeSessionsUnsupported(t *testing.T) {
+	contract := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	client := ethrpc.NewFake()
+	m, err := NewWithClient(client, contract.Hex(), "", 1)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer m.Close()
+
+	for name, answer := range map[string]ethrpc.CallHandler{
+		"reverted": func(ethereum.CallMsg) ([]byte, error) { return nil, revertError{} },
+		"empty":    func(ethereum.CallMsg) ([]byte, error) { return nil, nil },
+	} {
+		client.HandleCalls(contract, answer)
+		if _, err := m.GetActiveNodeSessions(context.Background(), common.HexToAddress("0xb0b")); !errors.Is(err, ErrNodeSessionsUnsupported) {
+			t.Errorf("%s: err = %v, want ErrNodeSessionsUnsupported", name, err)
+		}
+	}
+
+	client.HandleCalls(contract, func(ethereum.CallMsg) ([]byte, error) { return nil, errors.New("connection refused") })
+	if _, err := m.GetActiveNodeSessions(context.Background(), common.HexToAddress("0xb0b")); err == nil || errors.Is(err, ErrNodeSessionsUnsupported) {
+		t.Errorf("transport error: err = %v, want it reported as is", err)
+	}
+}
+
+func TestStubCallsGiveEveryWalletAPaidSession(t *testing.T) {
+	node := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	user := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	contract := common.HexToAddress("0x00000000000000000000000000000000000de002")
+	fake := ethrpc.NewFake()
+	fake.HandleCalls(contract, StubCalls(node, big.NewInt(3600), time.Hour))
+	m, err := NewWithClient(fake, contract.Hex(), "", 1)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	ctx := context.Background()
+
+	id, err := m.GetActiveSessionID(ctx, user)
+	if err != nil || id == 0 {
+		t.Fatalf("GetActiveSessionID = %d, %v; want a session", id, err)
+	}
+	s, err := m.GetSession(ctx, id)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if s.User != user || s.Node != node || !s.Active || s.Duration != 3600 || s.Payment.Int64() != 3600 {
+		t.Errorf("GetSession = %+v, want an hour-long paid session for the user on node", s)
+	}
+	if again, _ := m.GetActiveSessionID(ctx, user); again != id {
+		t.Errorf("second GetActiveSessionID = %d, want %d", again, id)
+	}
+	if s, err := m.GetSession(ctx, id+1); err != nil || s.Active {
+		t.Errorf("GetSession(unknown) = %+v, %v; want an inactive session", s, err)
+	}
+	if ids, err := m.GetActiveNodeSessions(ctx, node); err != nil || len(ids) != 1 || ids[0] != id {
+		t.Errorf("GetActiveNodeSessions = %v, %v; want [%d]", ids, err, id)
+	}
+	if ids, err := m.GetActiveNodeSessions(ctx, user); err != nil || len(ids) != 0 {
+		t.Errorf("GetActiveNodeSessions(other node) = %v, %v; want none", ids, err)
+	}
+}
@@ -0,0 +1,121 @@
+package sessionmgr
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ethrpc"
+)
+
+// StubCalls answers SessionManager reads without a deployed contract, so a
+// Manager on an ethrpc.Fake works in dev mode and tests. Every wallet has
+// an active paid session with node that has just started and runs for
+// maxDuration, numbered in the order wallets are first seen, and node lists
+// all of them as its active sessions. Writes go to the Fake and change
+// nothing.
+func StubCalls(node common.Address, pricePerHour *big.Int, maxDuration time.Duration) ethrpc.CallHandler {
+	parsed, err := abi.JSON(strings.NewReader(sessionManagerABI))
+	if err != nil {
+		return func(ethereum.CallMsg) ([]byte, error) { return nil, fmt.Errorf("parsing SessionManager ABI: %w", err) }
+	}
+	var (
+		mu    sync.Mutex
+		ids   = make(map[common.Address]int64)
+		users []common.Address // session ID - 1 -> wallet
+	)
+	maxSecs := big.NewInt(int64(maxDuration.Seconds()))
+	price := func(duration *big.Int) *big.Int {
+		p := new(big.Int).Mul(pricePerHour, duration)
+		return p.Div(p, big.NewInt(3600))
+	}
+
+	return func(call ethereum.CallMsg) ([]byte, error) {
+		if len(call.Data) < 4 {
+			return nil, fmt.Errorf("stub SessionManager: short call data")
+		}
+		method, err := parsed.MethodById(call.Data[:4])
+		if err != nil {
+			return nil, fmt.Errorf("stub SessionManager: %w", err)
+		}
+		args, err := method.Inputs.Unpack(call.Data[4:])
+		if err != nil {
+			return nil, fmt.Errorf("stub SessionManager: unpacking %s: %w", method.Name, err)
+		}
+
+		switch method.Name {
+		case "getActiveSessionId":
+			user, ok := args[0].(common.Address)
+			if !ok {
+				return nil, fmt.Errorf("stub SessionManager: unexpected %s argument %T", method.Name, args[0])
+			}
+			mu.Lock()
+			id, ok := ids[user]
+			if !ok {
+				users = append(users, user)
+				id = int64(len(users))
+				ids[user] = id
+			}
+			mu.Unlock()
+			return method.Outputs.Pack(big.NewInt(id))
+		case "getSession":
+			id, ok := args[0].(*big.Int)
+			if !ok {
+				return nil, fmt.Errorf("stub SessionManager: unexpected %s argument %T", method.Name, args[0])
+			}
+			mu.Lock()
+			known := id.IsInt64() && id.Int64() >= 1 && id.Int64() <= int64(len(users))
+			var user common.Address
+			if known {
+				user = users[id.Int64()-1]
+			}
+			mu.Unlock()
+			if !known {
+				return method.Outputs.Pack(sessionTuple{Payment: new(big.Int), StartedAt: new(big.Int), Duration: new(big.Int)})
+			}
+			payment := price(maxSecs)
+			if payment.Sign() == 0 {
+				payment.SetInt64(1) // the gateway only honours paid sessions
+			}
+			return method.Outputs.Pack(sessionTuple{
+				User:      user,
+				Node:      node,
+				Payment:   payment,
+				StartedAt: big.NewInt(time.Now().Unix()),
+				Duration:  maxSecs,
+				Active:    true,
+			})
+		case "getActiveNodeSessions":
+			n, ok := args[0].(common.Address)
+			if !ok {
+				return nil, fmt.Errorf("stub SessionManager: unexpected %s argument %T", method.Name, args[0])
+			}
+			active := []*big.Int{}
+			if n == node {
+				mu.Lock()
+				for i := range users {
+					active = append(active, big.NewInt(int64(i+1)))
+				}
+				mu.Unlock()
+			}
+			return method.Outputs.Pack(active)
+		case "pricePerHour":
+			return method.Outputs.Pack(pricePerHour)
+		case "maxSessionDuration":
+			return method.Outputs.Pack(maxSecs)
+		case "calculatePrice":
+			duration, ok := args[0].(*big.Int)
+			if !ok {
+				return nil, fmt.Errorf("stub SessionManager: unexpected %s argument %T", method.Name, args[0])
+			}
+			return method.Outputs.Pack(price(duration))
+		}
+		return nil, fmt.Errorf("stub SessionManager: %s not stubbed", method.Name)
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"encoding/hex"
 	"sync"
 	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/janitor"
 )
 
 // NonceStore tracks issued nonces and prevents replay attacks.
@@ -13,6 +16,9 @@ type NonceStore struct {
 	mu     sync.Mutex
 	nonces map[string]time.Time // nonce -> expiry time
 	ttl    time.Duration
+	clock  clock.Clock
+
+	stopSweep func()
 }
 
 // NewNonceStore creates a nonce store with the given TTL for challenges.
@@ -20,11 +26,24 @@ func NewNonceStore(ttl time.Duration) *NonceStore {
 	ns := &NonceStore{
 		nonces: make(map[string]time.Time),
 		ttl:    ttl,
+		clock:  clock.Real{},
 	}
-	go ns.cleanup()
+	ns.stopSweep = janitor.Register("siwe nonces", ns.removeExpired)
 	return ns
 }
 
+// Close stops the periodic cleanup of expired nonces.
+func (ns *NonceStore) Close() {
+	ns.stopSweep()
+}
+
+// SetClock replaces the clock used to stamp and check nonce expiry.
+func (ns *NonceStore) SetClock(c clock.Clock) {
+	ns.mu.Lock()
+	ns.clock = c
+	ns.mu.Unlock()
+}
+
 // Generate creates a new random nonce and stores it.
 func (ns *NonceStore) Generate(length int) (string, error) {
 	bytes := make([]byte, length)
@@ -34,7 +53,7 @@ func (ns *NonceStore) Generate(length int) (string, error) {
 	nonce := hex.EncodeToString(bytes)
 
 	ns.mu.Lock()
-	ns.nonces[nonce] = time.Now().Add(ns.ttl)
+	ns.nonces[nonce] = ns.clock.Now().Add(ns.ttl)
 	ns.mu.Unlock()
 
 	return nonce, nil
@@ -53,22 +72,16 @@ func (ns *NonceStore) Consume(nonce string) bool {
 
 	delete(ns.nonces, nonce)
 
-	return time.Now().Before(expiry)
+	return ns.clock.Now().Before(expiry)
 }
 
-// cleanup periodically removes expired nonces.
-func (ns *NonceStore) cleanup() {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		ns.mu.Lock()
-		now := time.Now()
-		for nonce, expiry := range ns.nonces {
-			if now.After(expiry) {
-				delete(ns.nonces, nonce)
-			}
+// removeExpired deletes nonces that were never consumed. Run by the janitor.
+func (ns *NonceStore) removeExpired(now time.Time) {
+	ns.mu.Lock()
+	for nonce, expiry := range ns.nonces {
+		if now.After(expiry) {
+			delete(ns.nonces, nonce)
 		}
-		ns.mu.Unlock()
 	}
+	ns.mu.Unlock()
 }
@@ -0,0 +1,92 @@
+package siwe
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/janitor"
+)
+
+// SignatureCache remembers signatures Verify has accepted until their
+// message expires, so the exact same signature cannot be submitted twice.
+// It backs up the nonce store: a replay is refused even if the nonce check
+// were ever to let one through.
+type SignatureCache struct {
+	mu    sync.Mutex
+	seen  map[[32]byte]time.Time // signature key -> expiry
+	clock clock.Clock
+
+	stopSweep func()
+}
+
+// NewSignatureCache creates an empty cache.
+func NewSignatureCache() *SignatureCache {
+	c := &SignatureCache{
+		seen:  make(map[[32]byte]time.Time),
+		clock: clock.Real{},
+	}
+	c.stopSweep = janitor.Register("siwe signatures", c.removeExpired)
+	return c
+}
+
+// Close stops the periodic cleanup of expired signatures.
+func (c *SignatureCache) Close() {
+	c.stopSweep()
+}
+
+// SetClock replaces the clock used to check entry expiry.
+func (c *SignatureCache) SetClock(clk clock.Clock) {
+	c.mu.Lock()
+	c.clock = clk
+	c.mu.Unlock()
+}
+
+// Claim records key until expiry and reports whether it was unseen.
+func (c *SignatureCache) Claim(key [32]byte, expiry time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if prev, ok := c.seen[key]; ok && c.clock.Now().Before(prev) {
+		return false
+	}
+	c.seen[key] = expiry
+	return true
+}
+
+// Release forgets key, for a claim whose sign-in failed afterwards.
+func (c *SignatureCache) Release(key [32]byte) {
+	c.mu.Lock()
+	delete(c.seen, key)
+	c.mu.Unlock()
+}
+
+// removeExpired drops entries whose message has expired. Run by the janitor.
+func (c *SignatureCache) removeExpired(now time.Time) {
+	c.mu.Lock()
+	for key, expiry := range c.seen {
+		if now.After(expiry) {
+			delete(c.seen, key)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// secp256k1HalfN is half the curve order; ECDSA accepts s and n-s alike.
+var secp256k1HalfN = new(big.Int).Rsh(crypto.S256().Params().N, 1)
+
+// signatureKey identifies a 65-byte signature by r and the low-s form of s,
+// ignoring the recovery ID, so re-encoding the same signature -- 27/28 for
+// 0/1, or n-s for s -- yields the same key.
+func signatureKey(sig []byte) [32]byte {
+	s := new(big.Int).SetBytes(sig[32:64])
+	if s.Cmp(secp256k1HalfN) > 0 {
+		s.Sub(crypto.S256().Params().N, s)
+	}
+	var rs [64]byte
+	copy(rs[:32], sig[:32])
+	s.FillBytes(rs[32:])
+	return crypto.Keccak256Hash(rs[:])
+}
@@ -1,7 +1,9 @@
 package siwe
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -9,6 +11,8 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
 )
 
 // Challenge represents a SIWE challenge issued to a client.
@@ -33,33 +37,154 @@ type SignedMessage struct {
 // VerifiedAuth is the result of a successful SIWE verification.
 type VerifiedAuth struct {
 	Address common.Address `json:"address"` // The recovered wallet address
+	Nonce   string         `json:"-"`       // Nonce from the message; Verify consumes it
+	// Terms are the terms of service the signed statement accepts, or nil
+	// if the service has none or the statement leaves them out.
+	Terms *Terms `json:"-"`
+
+	sigKey    [32]byte  // see signatureKey
+	expiresAt time.Time // the message's expiration time
+}
+
+// ErrInvalidNonce and ErrMessageExpired mean the challenge is no longer
+// usable: the client should request a fresh one and sign again.
+var (
+	ErrInvalidNonce   = errors.New("invalid or expired nonce")
+	ErrMessageExpired = errors.New("siwe message has expired")
+)
+
+// DefaultStatement is the statement shown in the wallet signing prompt when
+// the operator has not configured one.
+const DefaultStatement = "Sign in to Sovereign VPN with your Ethereum account."
+
+// Placeholders a statement may contain. Each challenge replaces them with
+// the node set by SetNode, so the wallet prompt names the node the user is
+// signing in to, e.g. "Sign in to node {node} ({region}) for VPN access."
+const (
+	StatementNode   = "{node}"
+	StatementRegion = "{region}"
+)
+
+var statementPlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// Terms identifies the terms of service a sign-in accepts. When set, each
+// challenge's statement ends with Clause, so the signature over the message
+// is the user's acceptance of that exact version of the terms.
+type Terms struct {
+	URL    string // where the terms are published
+	SHA256 string // optional hex SHA-256 of the terms document, pinning a version
+}
+
+// Clause is the sentence appended to the statement.
+func (t Terms) Clause() string {
+	if t.SHA256 != "" {
+		return fmt.Sprintf("I accept the terms of service at %s (sha256:%s).", t.URL, t.SHA256)
+	}
+	return fmt.Sprintf("I accept the terms of service at %s.", t.URL)
+}
+
+// Node identifies the gateway a challenge signs in to.
+type Node struct {
+	Name   string // e.g. "us-east-1"
+	Region string // e.g. "us-east"
 }
 
 // Service handles SIWE challenge generation and verification.
 type Service struct {
 	domain       string
 	uri          string
+	statement    string
+	node         *strings.Replacer // fills statement placeholders
+	terms        *Terms            // nil when sign-in accepts no terms
 	nonceStore   *NonceStore
+	signatures   *SignatureCache
 	chainID      int
 	challengeTTL time.Duration
+	clock        clock.Clock
 }
 
 // NewService creates a SIWE service.
 func NewService(domain, uri string, challengeTTL time.Duration, nonceLength int) *Service {
 	return &Service{
-		domain:     domain,
-		uri:        uri,
-		nonceStore: NewNonceStore(challengeTTL),
-		chainID:    1, // Ethereum mainnet; Sepolia = 11155111
+		domain:       domain,
+		uri:          uri,
+		statement:    DefaultStatement,
+		nonceStore:   NewNonceStore(challengeTTL),
+		signatures:   NewSignatureCache(),
+		chainID:      1, // Ethereum mainnet; Sepolia = 11155111
 		challengeTTL: challengeTTL,
+		clock:        clock.Real{},
 	}
 }
 
+// Close stops the background cleanup of the nonce store and replay cache.
+func (s *Service) Close() {
+	s.nonceStore.Close()
+	s.signatures.Close()
+}
+
 // SetChainID sets the expected chain ID (1 = mainnet, 11155111 = Sepolia).
 func (s *Service) SetChainID(chainID int) {
 	s.chainID = chainID
 }
 
+// SetClock replaces the clock used for challenge timestamps, message
+// expiry, nonce expiry and the signature replay cache.
+func (s *Service) SetClock(c clock.Clock) {
+	s.clock = c
+	s.nonceStore.SetClock(c)
+	s.signatures.SetClock(c)
+}
+
+// SetStatement sets the statement included in new challenges, letting
+// branded deployments customize the wallet signing prompt.
+func (s *Service) SetStatement(statement string) error {
+	if err := ValidateStatement(statement); err != nil {
+		return err
+	}
+	s.statement = statement
+	return nil
+}
+
+// SetNode sets the node named by the statement's placeholders.
+func (s *Service) SetNode(node Node) error {
+	if strings.ContainsAny(node.Name+node.Region, "\r\n") {
+		return fmt.Errorf("node name and region must not contain line breaks")
+	}
+	s.node = strings.NewReplacer(StatementNode, node.Name, StatementRegion, node.Region)
+	return nil
+}
+
+// SetTerms makes new challenges accept terms; a zero Terms removes them.
+func (s *Service) SetTerms(terms Terms) error {
+	if terms == (Terms{}) {
+		s.terms = nil
+		return nil
+	}
+	if terms.URL == "" {
+		return fmt.Errorf("terms need a URL")
+	}
+	if strings.ContainsAny(terms.URL+terms.SHA256, " \t\r\n") {
+		return fmt.Errorf("terms URL and hash must not contain whitespace")
+	}
+	s.terms = &terms
+	return nil
+}
+
+// ValidateStatement checks that a statement fits on the single line EIP-4361
+// reserves for it and uses no unknown placeholders.
+func ValidateStatement(statement string) error {
+	if strings.ContainsAny(statement, "\r\n") {
+		return fmt.Errorf("statement must not contain line breaks")
+	}
+	for _, p := range statementPlaceholder.FindAllString(statement, -1) {
+		if p != StatementNode && p != StatementRegion {
+			return fmt.Errorf("statement has unknown placeholder %s (want %s or %s)", p, StatementNode, StatementRegion)
+		}
+	}
+	return nil
+}
+
 // NewChallenge generates a SIWE challenge for the client to sign.
 func (s *Service) NewChallenge(nonceLength int) (*Challenge, error) {
 	nonce, err := s.nonceStore.Generate(nonceLength)
@@ -67,7 +192,15 @@ func (s *Service) NewChallenge(nonceLength int) (*Challenge, error) {
 		return nil, fmt.Errorf("generating nonce: %w", err)
 	}
 
-	issuedAt := time.Now().UTC()
+	statement := s.statement
+	if s.node != nil {
+		statement = s.node.Replace(statement)
+	}
+	if s.terms != nil {
+		statement = strings.TrimSpace(statement + " " + s.terms.Clause())
+	}
+
+	issuedAt := s.clock.Now().UTC()
 	return &Challenge{
 		Domain:         s.domain,
 		URI:            s.uri,
@@ -76,7 +209,7 @@ func (s *Service) NewChallenge(nonceLength int) (*Challenge, error) {
 		Nonce:          nonce,
 		IssuedAt:       issuedAt,
 		ExpirationTime: issuedAt.Add(s.challengeTTL),
-		Statement:      "Sign in to Sovereign VPN with your Ethereum account.",
+		Statement:      statement,
 	}, nil
 }
 
@@ -113,11 +246,39 @@ func FormatMessage(c *Challenge, address string) string {
 
 // Verify checks a signed SIWE message:
 // 1. Recovers the signer address from the signature
-// 2. Parses the message to extract the nonce
-// 3. Validates the nonce (single-use, not expired)
-// 4. Validates the domain and URI match
+// 2. Parses the message and validates domain, URI, chain ID and timestamps
+// 3. Rejects a signature it has already accepted
+// 4. Validates the nonce (single-use, not expired)
 // Returns the verified wallet address.
 func (s *Service) Verify(signed *SignedMessage) (*VerifiedAuth, error) {
+	auth, err := s.RecoverAndValidate(signed)
+	if err != nil {
+		return nil, err
+	}
+
+	// Claim the signature until the message expires, or at most as long as
+	// its nonce could still be valid
+	expiry := auth.expiresAt
+	if ttlEnd := s.clock.Now().Add(s.challengeTTL); ttlEnd.Before(expiry) {
+		expiry = ttlEnd
+	}
+	if !s.signatures.Claim(auth.sigKey, expiry) {
+		return nil, fmt.Errorf("signature already used")
+	}
+
+	// Consume nonce (single-use)
+	if !s.nonceStore.Consume(auth.Nonce) {
+		s.signatures.Release(auth.sigKey)
+		return nil, ErrInvalidNonce
+	}
+
+	return auth, nil
+}
+
+// RecoverAndValidate runs steps 1 and 2 of Verify without touching the
+// nonce store, so it can re-check a message the service did not issue, or
+// one whose nonce was already spent. It is not a defense against replay.
+func (s *Service) RecoverAndValidate(signed *SignedMessage) (*VerifiedAuth, error) {
 	// Decode the signature
 	sigBytes, err := hexutil.Decode(signed.Signature)
 	if err != nil {
@@ -174,22 +335,24 @@ func (s *Service) Verify(signed *SignedMessage) (*VerifiedAuth, error) {
 		return nil, fmt.Errorf("expiration time is required")
 	}
 
-	now := time.Now().UTC()
+	now := s.clock.Now().UTC()
 	if parsed.issuedAt.After(now.Add(5 * time.Minute)) {
 		return nil, fmt.Errorf("issued-at is in the future")
 	}
 	if now.After(parsed.expirationTime) {
-		return nil, fmt.Errorf("siwe message has expired")
+		return nil, ErrMessageExpired
 	}
 
-	// Consume nonce (single-use)
-	if !s.nonceStore.Consume(parsed.nonce) {
-		return nil, fmt.Errorf("invalid or expired nonce")
+	auth := &VerifiedAuth{
+		Address:   recoveredAddr,
+		Nonce:     parsed.nonce,
+		sigKey:    signatureKey(sigBytes),
+		expiresAt: parsed.expirationTime,
 	}
-
-	return &VerifiedAuth{
-		Address: recoveredAddr,
-	}, nil
+	if s.terms != nil && strings.HasSuffix(parsed.statement, s.terms.Clause()) {
+		auth.Terms = s.terms
+	}
+	return auth, nil
 }
 
 // signHash computes the Ethereum signed message hash (ERC-191).
@@ -202,6 +365,7 @@ func signHash(data []byte) []byte {
 type parsedMessage struct {
 	domain         string
 	address        string
+	statement      string
 	uri            string
 	nonce          string
 	chainID        int
@@ -232,6 +396,11 @@ func parseMessage(msg string) (*parsedMessage, error) {
 		return nil, fmt.Errorf("invalid address: %q", parsed.address)
 	}
 
+	// Line 3: the optional statement, between blank lines
+	if len(lines) > 4 && lines[2] == "" && lines[4] == "" && !strings.HasPrefix(lines[3], "URI: ") {
+		parsed.statement = lines[3]
+	}
+
 	// Find nonce line
 	for _, line := range lines {
 		switch {
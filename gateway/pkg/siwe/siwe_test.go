@@ -2,11 +2,15 @@ package siwe
 
 import (
 	"crypto/ecdsa"
+	"math/big"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
 )
 
 // Helper: sign a message with a private key using ERC-191 personal_sign.
@@ -40,6 +44,112 @@ func TestNewChallenge(t *testing.T) {
 	}
 }
 
+func TestNewChallengeUsesConfiguredStatement(t *testing.T) {
+	svc := NewService("test.example.com", "https://test.example.com", 5*time.Minute, 16)
+
+	challenge, err := svc.NewChallenge(16)
+	if err != nil {
+		t.Fatalf("NewChallenge failed: %v", err)
+	}
+	if challenge.Statement != DefaultStatement {
+		t.Errorf("expected default statement, got %q", challenge.Statement)
+	}
+
+	if err := svc.SetStatement("Sign in to Example VPN."); err != nil {
+		t.Fatalf("SetStatement failed: %v", err)
+	}
+	challenge, err = svc.NewChallenge(16)
+	if err != nil {
+		t.Fatalf("NewChallenge failed: %v", err)
+	}
+	if challenge.Statement != "Sign in to Example VPN." {
+		t.Errorf("expected custom statement, got %q", challenge.Statement)
+	}
+}
+
+func TestSetStatementRejectsLineBreaks(t *testing.T) {
+	svc := NewService("test.example.com", "https://test.example.com", 5*time.Minute, 16)
+
+	for _, statement := range []string{"line one\nURI: https://evil.example", "carriage\rreturn"} {
+		if err := svc.SetStatement(statement); err == nil {
+			t.Errorf("expected error for %q", statement)
+		}
+	}
+	challenge, _ := svc.NewChallenge(16)
+	if challenge.Statement != DefaultStatement {
+		t.Errorf("rejected statement should not replace default, got %q", challenge.Statement)
+	}
+}
+
+func TestNewChallengeFillsNodePlaceholders(t *testing.T) {
+	svc := NewService("test.example.com", "https://test.example.com", 5*time.Minute, 16)
+	if err := svc.SetStatement("Sign in to node {node} ({region}) for VPN access."); err != nil {
+		t.Fatalf("SetStatement failed: %v", err)
+	}
+	if err := svc.SetNode(Node{Name: "us-east-1", Region: "us-east"}); err != nil {
+		t.Fatalf("SetNode failed: %v", err)
+	}
+
+	challenge, err := svc.NewChallenge(16)
+	if err != nil {
+		t.Fatalf("NewChallenge failed: %v", err)
+	}
+	if want := "Sign in to node us-east-1 (us-east) for VPN access."; challenge.Statement != want {
+		t.Errorf("expected %q, got %q", want, challenge.Statement)
+	}
+
+	if err := svc.SetStatement("Sign in to {operator}."); err == nil {
+		t.Error("expected error for unknown placeholder")
+	}
+	if err := svc.SetNode(Node{Name: "evil\nURI: https://evil.example"}); err == nil {
+		t.Error("expected error for node name with a line break")
+	}
+}
+
+func TestVerifyReportsAcceptedTerms(t *testing.T) {
+	svc := NewService("test.example.com", "https://test.example.com", 5*time.Minute, 16)
+	terms := Terms{URL: "https://test.example.com/terms", SHA256: strings.Repeat("ab", 32)}
+	if err := svc.SetTerms(terms); err != nil {
+		t.Fatalf("SetTerms failed: %v", err)
+	}
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+
+	challenge, err := svc.NewChallenge(16)
+	if err != nil {
+		t.Fatalf("NewChallenge failed: %v", err)
+	}
+	if want := DefaultStatement + " " + terms.Clause(); challenge.Statement != want {
+		t.Fatalf("expected statement %q, got %q", want, challenge.Statement)
+	}
+
+	sign := func(c *Challenge) *VerifiedAuth {
+		t.Helper()
+		message := FormatMessage(c, address)
+		sig, _ := personalSign(key, message)
+		auth, err := svc.Verify(&SignedMessage{Message: message, Signature: sig})
+		if err != nil {
+			t.Fatalf("Verify failed: %v", err)
+		}
+		return auth
+	}
+	if auth := sign(challenge); auth.Terms == nil || *auth.Terms != terms {
+		t.Errorf("expected the terms to be accepted, got %+v", auth.Terms)
+	}
+
+	// A client that drops the clause from the message it signs has not
+	// accepted them.
+	challenge, _ = svc.NewChallenge(16)
+	challenge.Statement = DefaultStatement
+	if auth := sign(challenge); auth.Terms != nil {
+		t.Errorf("expected no accepted terms without the clause, got %+v", auth.Terms)
+	}
+
+	if err := svc.SetTerms(Terms{URL: "https://x.example/terms\nURI: https://evil.example"}); err == nil {
+		t.Error("expected error for terms URL with a line break")
+	}
+}
+
 func TestFormatMessage(t *testing.T) {
 	challenge := &Challenge{
 		Domain:         "test.example.com",
@@ -141,6 +251,83 @@ func TestVerifyRejectsReplayedNonce(t *testing.T) {
 	}
 }
 
+func TestVerifyRejectsReplayedSignature(t *testing.T) {
+	svc := NewService("test.example.com", "https://test.example.com", 5*time.Minute, 16)
+
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	challenge, _ := svc.NewChallenge(16)
+	message := FormatMessage(challenge, address.Hex())
+	sig, _ := personalSign(key, message)
+
+	if _, err := svc.Verify(&SignedMessage{Message: message, Signature: sig}); err != nil {
+		t.Fatalf("First verify should succeed: %v", err)
+	}
+
+	// The same signature, re-encoded with a 0/1 recovery ID
+	raw := hexutil.MustDecode(sig)
+	lowV := append([]byte(nil), raw...)
+	lowV[64] -= 27
+	for name, replay := range map[string]string{"exact": sig, "0/1 recovery ID": hexutil.Encode(lowV)} {
+		_, err := svc.Verify(&SignedMessage{Message: message, Signature: replay})
+		if err == nil || !strings.Contains(err.Error(), "signature already used") {
+			t.Errorf("%s replay: got %v, want signature already used", name, err)
+		}
+	}
+
+	// or as (r, n-s), which some secp256k1 backends recover too
+	highS := append([]byte(nil), raw...)
+	s := new(big.Int).SetBytes(raw[32:64])
+	new(big.Int).Sub(crypto.S256().Params().N, s).FillBytes(highS[32:64])
+	highS[64] ^= 1
+	if _, err := svc.Verify(&SignedMessage{Message: message, Signature: hexutil.Encode(highS)}); err == nil {
+		t.Error("high-s replay was accepted")
+	}
+	if signatureKey(highS) != signatureKey(raw) {
+		t.Error("high-s form maps to a different replay key")
+	}
+}
+
+func TestVerifyReleasesSignatureOnBadNonce(t *testing.T) {
+	svc := NewService("test.example.com", "https://test.example.com", 5*time.Minute, 16)
+
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	challenge, _ := svc.NewChallenge(16)
+	challenge.Nonce = "00000000000000000000000000000000" // never issued
+	message := FormatMessage(challenge, address.Hex())
+	sig, _ := personalSign(key, message)
+
+	if _, err := svc.Verify(&SignedMessage{Message: message, Signature: sig}); err == nil {
+		t.Fatal("verify with an unissued nonce should fail")
+	}
+	if n := len(svc.signatures.seen); n != 0 {
+		t.Errorf("signature cache holds %d entries after a failed sign-in, want 0", n)
+	}
+}
+
+func TestSignatureCacheExpiry(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	c := NewSignatureCache()
+	c.SetClock(clk)
+	key := [32]byte{1}
+
+	if !c.Claim(key, clk.Now().Add(time.Minute)) {
+		t.Fatal("first claim should succeed")
+	}
+	if c.Claim(key, clk.Now().Add(time.Minute)) {
+		t.Fatal("second claim within expiry should fail")
+	}
+	clk.Advance(2 * time.Minute)
+	c.removeExpired(clk.Now())
+	if len(c.seen) != 0 {
+		t.Fatalf("expired entry not removed")
+	}
+	if !c.Claim(key, clk.Now().Add(time.Minute)) {
+		t.Error("claim after expiry should succeed")
+	}
+}
+
 func TestVerifyRejectsWrongDomain(t *testing.T) {
 	svc := NewService("real.example.com", "https://real.example.com", 5*time.Minute, 16)
 
@@ -179,6 +366,79 @@ func TestVerifyRejectsWrongSigner(t *testing.T) {
 	}
 }
 
+func TestRecoverAndValidateLeavesNonceUnspent(t *testing.T) {
+	svc := NewService("test.example.com", "https://test.example.com", 5*time.Minute, 16)
+
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	challenge, _ := svc.NewChallenge(16)
+	message := FormatMessage(challenge, address.Hex())
+	sig, _ := personalSign(key, message)
+	signed := &SignedMessage{Message: message, Signature: sig}
+
+	for i := 0; i < 2; i++ {
+		auth, err := svc.RecoverAndValidate(signed)
+		if err != nil {
+			t.Fatalf("RecoverAndValidate #%d failed: %v", i+1, err)
+		}
+		if auth.Address != address || auth.Nonce != challenge.Nonce {
+			t.Errorf("got address %s nonce %q, want %s %q", auth.Address.Hex(), auth.Nonce, address.Hex(), challenge.Nonce)
+		}
+	}
+
+	// The nonce is still live for the real sign-in.
+	if _, err := svc.Verify(signed); err != nil {
+		t.Fatalf("Verify after RecoverAndValidate failed: %v", err)
+	}
+	// And a spent nonce does not stop re-validation.
+	if _, err := svc.RecoverAndValidate(signed); err != nil {
+		t.Fatalf("RecoverAndValidate after Verify failed: %v", err)
+	}
+}
+
+func TestRecoverAndValidateAcceptsForeignNonce(t *testing.T) {
+	issuer := NewService("test.example.com", "https://test.example.com", 5*time.Minute, 16)
+	checker := NewService("test.example.com", "https://test.example.com", 5*time.Minute, 16)
+
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	challenge, _ := issuer.NewChallenge(16)
+	message := FormatMessage(challenge, address.Hex())
+	sig, _ := personalSign(key, message)
+	signed := &SignedMessage{Message: message, Signature: sig}
+
+	if _, err := checker.RecoverAndValidate(signed); err != nil {
+		t.Fatalf("RecoverAndValidate failed: %v", err)
+	}
+	if _, err := checker.Verify(signed); err == nil {
+		t.Fatal("Verify should reject a nonce this service did not issue")
+	}
+}
+
+func TestRecoverAndValidateRejectsExpired(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	svc := NewService("test.example.com", "https://test.example.com", 5*time.Minute, 16)
+	svc.SetClock(clk)
+
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	challenge, _ := svc.NewChallenge(16)
+	message := FormatMessage(challenge, address.Hex())
+	sig, _ := personalSign(key, message)
+	signed := &SignedMessage{Message: message, Signature: sig}
+
+	if _, err := svc.RecoverAndValidate(signed); err != nil {
+		t.Fatalf("RecoverAndValidate before expiry failed: %v", err)
+	}
+	clk.Advance(6 * time.Minute)
+	if _, err := svc.RecoverAndValidate(signed); err == nil {
+		t.Fatal("Should reject an expired message")
+	}
+}
+
 func TestNonceStoreConsume(t *testing.T) {
 	store := NewNonceStore(5 * time.Minute)
 
@@ -196,12 +456,13 @@ func TestNonceStoreConsume(t *testing.T) {
 }
 
 func TestNonceStoreExpiry(t *testing.T) {
-	store := NewNonceStore(1 * time.Millisecond)
+	clk := clock.NewFake(time.Now())
+	store := NewNonceStore(time.Minute)
+	store.SetClock(clk)
 
 	nonce, _ := store.Generate(16)
 
-	// Wait for expiry
-	time.Sleep(5 * time.Millisecond)
+	clk.Advance(time.Minute + time.Second)
 
 	if store.Consume(nonce) {
 		t.Fatal("Should reject expired nonce")
@@ -0,0 +1,148 @@
+package subscriptionmgr
+
+import (
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+)
+
+const (
+	// DefaultCacheTTL is how long a read is served from the cache before the
+	// contract is asked again.
+	DefaultCacheTTL = 30 * time.Second
+	// DefaultStaleTTL is how long after it was read a cached result may
+	// still be served when the RPC endpoint is failing.
+	DefaultStaleTTL = 10 * time.Minute
+)
+
+// Cached read kinds. tiers is not per user and is keyed with the zero address.
+const (
+	readActive       = "hasActiveSubscription"
+	readSubscription = "getSubscription"
+	readRemaining    = "remainingTime"
+	readTiers        = "tiers"
+)
+
+type cacheKey struct {
+	read string
+	user common.Address
+}
+
+// cacheEntry holds one read's result. remainingTime is stored as the
+// deadline it implies, so a cached answer keeps counting down.
+type cacheEntry struct {
+	value     any
+	fetchedAt time.Time
+}
+
+// SetCacheTTL sets how long reads are served from the cache. 0 disables
+// caching, and with it the stale fallback. Call it before the manager is in
+// use.
+func (m *Manager) SetCacheTTL(ttl time.Duration) {
+	m.cacheTTL = ttl
+}
+
+// SetStaleTTL sets how old a cached result may be and still be served when
+// the contract read fails. 0 turns the fallback off. Call it before the
+// manager is in use.
+func (m *Manager) SetStaleTTL(ttl time.Duration) {
+	m.staleTTL = ttl
+}
+
+// SetClock replaces the clock used to stamp and expire cached results. Call
+// it before the manager is in use.
+func (m *Manager) SetClock(clk clock.Clock) {
+	m.clock = clk
+}
+
+func (m *Manager) now() time.Time {
+	if m.clock == nil {
+		return time.Now()
+	}
+	return m.clock.Now()
+}
+
+// SetMaxCacheEntries caps the number of cached reads. When full, the least
+// recently used one is evicted. 0 means unlimited.
+func (m *Manager) SetMaxCacheEntries(max int) {
+	m.mu.Lock()
+	m.cache.SetMax(max, nil)
+	m.mu.Unlock()
+}
+
+// Invalidate drops the cached reads for a user (used when a Subscribed or
+// Renewed event names them).
+func (m *Manager) Invalidate(user common.Address) {
+	m.mu.Lock()
+	m.gen++
+	m.cache.RemoveFunc(func(k cacheKey, _ cacheEntry) bool { return k.read != readTiers && k.user == user })
+	m.mu.Unlock()
+}
+
+// InvalidateTiers drops the cached tier list (used when a TierUpdated event
+// lands).
+func (m *Manager) InvalidateTiers() {
+	m.mu.Lock()
+	m.gen++
+	m.cache.Remove(cacheKey{read: readTiers})
+	m.mu.Unlock()
+}
+
+// CacheSize returns the number of cached reads (for monitoring).
+func (m *Manager) CacheSize() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cache.Len()
+}
+
+// cached serves key from the cache while it is fresh, otherwise calls fetch
+// and caches its result. If fetch fails, a result younger than the stale
+// TTL is served instead so a flaky RPC endpoint does not lock subscribers
+// out.
+func cached[T any](m *Manager, key cacheKey, fetch func() (T, error)) (T, error) {
+	if m.cacheTTL <= 0 {
+		return fetch()
+	}
+
+	m.mu.Lock()
+	entry, ok := m.cache.Get(key)
+	gen := m.gen
+	m.mu.Unlock()
+	now := m.now()
+	if ok && now.Before(entry.fetchedAt.Add(m.cacheTTL)) {
+		return entry.value.(T), nil
+	}
+
+	v, err := fetch()
+	if err != nil {
+		if ok && now.Before(entry.fetchedAt.Add(max(m.staleTTL, m.cacheTTL))) {
+			log.Printf("[subscriptionmgr] %s failed, serving result from %s ago: %v",
+				key.read, now.Sub(entry.fetchedAt).Round(time.Second), err)
+			return entry.value.(T), nil
+		}
+		return v, err
+	}
+
+	m.mu.Lock()
+	// An invalidation that raced the read may have been for a change this
+	// result predates, so only cache it if none happened.
+	if m.gen == gen {
+		m.cache.Add(key, cacheEntry{value: v, fetchedAt: now}, nil)
+	}
+	m.mu.Unlock()
+	return v, nil
+}
+
+// removeExpired drops entries too old to serve even as a fallback. Run by
+// the janitor.
+func (m *Manager) removeExpired(now time.Time) {
+	keep := max(m.staleTTL, m.cacheTTL)
+	m.mu.Lock()
+	m.cache.RemoveFunc(func(_ cacheKey, entry cacheEntry) bool {
+		return now.After(entry.fetchedAt.Add(keep))
+	})
+	m.mu.Unlock()
+}
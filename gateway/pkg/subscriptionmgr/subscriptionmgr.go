@@ -4,21 +4,37 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ethrpc"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/janitor"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/lru"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
 )
 
 // Manager interacts with the SubscriptionManager smart contract (read-only).
 // Users call subscribe() directly from the frontend — the gateway only reads state.
+// Reads are cached for DefaultCacheTTL unless SetCacheTTL says otherwise.
 type Manager struct {
-	client       *ethclient.Client
+	client       ethrpc.Caller
 	contractAddr common.Address
 	abi          abi.ABI
 	chainID      *big.Int
+	cacheTTL     time.Duration
+	staleTTL     time.Duration
+	clock        clock.Clock // nil means the wall clock
+	mu           sync.Mutex
+	cache        *lru.Cache[cacheKey, cacheEntry]
+	gen          uint64 // bumped by every invalidation
+	stopSweep    func()
 }
 
 // OnChainSubscription represents a subscription read from the smart contract.
@@ -96,26 +112,47 @@ const subscriptionManagerABI = `[
 
 // New creates a read-only SubscriptionManager client.
 func New(rpcURL, contractAddr string, chainID int64) (*Manager, error) {
-	client, err := ethclient.Dial(rpcURL)
+	client, err := outbound.Dial(rpcURL)
 	if err != nil {
 		return nil, fmt.Errorf("connecting to Ethereum RPC: %w", err)
 	}
+	m, err := NewWithClient(client, contractAddr, chainID)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return m, nil
+}
 
+// NewWithClient creates a read-only SubscriptionManager client on an
+// existing client, e.g. an ethrpc.Fake in tests. Close closes the client.
+func NewWithClient(client ethrpc.Caller, contractAddr string, chainID int64) (*Manager, error) {
 	parsed, err := abi.JSON(strings.NewReader(subscriptionManagerABI))
 	if err != nil {
 		return nil, fmt.Errorf("parsing SubscriptionManager ABI: %w", err)
 	}
 
-	return &Manager{
+	m := &Manager{
 		client:       client,
 		contractAddr: common.HexToAddress(contractAddr),
 		abi:          parsed,
 		chainID:      big.NewInt(chainID),
-	}, nil
+		cacheTTL:     DefaultCacheTTL,
+		staleTTL:     DefaultStaleTTL,
+		cache:        lru.New[cacheKey, cacheEntry](0),
+	}
+	m.stopSweep = janitor.Register("subscriptionmgr cache", m.removeExpired)
+	return m, nil
 }
 
 // HasActiveSubscription checks if a user has an active subscription on-chain.
 func (m *Manager) HasActiveSubscription(ctx context.Context, user common.Address) (bool, error) {
+	return cached(m, cacheKey{read: readActive, user: user}, func() (bool, error) {
+		return m.fetchActive(ctx, user)
+	})
+}
+
+func (m *Manager) fetchActive(ctx context.Context, user common.Address) (bool, error) {
 	callData, err := m.abi.Pack("hasActiveSubscription", user)
 	if err != nil {
 		return false, fmt.Errorf("packing call data: %w", err)
@@ -129,9 +166,9 @@ func (m *Manager) HasActiveSubscription(ctx context.Context, user common.Address
 		return false, fmt.Errorf("calling hasActiveSubscription: %w", err)
 	}
 
-	results, err := m.abi.Unpack("hasActiveSubscription", output)
+	results, err := ethrpc.Unpack(m.abi, "hasActiveSubscription", output, 1)
 	if err != nil {
-		return false, fmt.Errorf("unpacking hasActiveSubscription: %w", err)
+		return false, err
 	}
 
 	active, ok := results[0].(bool)
@@ -143,6 +180,33 @@ func (m *Manager) HasActiveSubscription(ctx context.Context, user common.Address
 
 // GetSubscription reads a user's subscription details from the on-chain contract.
 func (m *Manager) GetSubscription(ctx context.Context, user common.Address) (*OnChainSubscription, error) {
+	sub, err := cached(m, cacheKey{read: readSubscription, user: user}, func() (*OnChainSubscription, error) {
+		return m.fetchSubscription(ctx, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Callers get their own copy of the cached value.
+	cp := *sub
+	if sub.Payment != nil {
+		cp.Payment = new(big.Int).Set(sub.Payment)
+	}
+	return &cp, nil
+}
+
+// subscriptionTuple is the Subscription struct returned by getSubscription.
+// Its fields must stay in ABI order: the decoder copies the tuple into it
+// field by field.
+type subscriptionTuple struct {
+	User      common.Address
+	Node      common.Address
+	Payment   *big.Int
+	StartedAt *big.Int
+	ExpiresAt *big.Int
+	Tier      uint8
+}
+
+func (m *Manager) fetchSubscription(ctx context.Context, user common.Address) (*OnChainSubscription, error) {
 	callData, err := m.abi.Pack("getSubscription", user)
 	if err != nil {
 		return nil, fmt.Errorf("packing getSubscription: %w", err)
@@ -156,22 +220,13 @@ func (m *Manager) GetSubscription(ctx context.Context, user common.Address) (*On
 		return nil, fmt.Errorf("calling getSubscription: %w", err)
 	}
 
-	results, err := m.abi.Unpack("getSubscription", output)
-	if err != nil {
+	// UnpackIntoInterface copies a lone tuple output into the first field
+	// of the destination struct.
+	var out struct{ Subscription subscriptionTuple }
+	if err := m.abi.UnpackIntoInterface(&out, "getSubscription", output); err != nil {
 		return nil, fmt.Errorf("unpacking getSubscription: %w", err)
 	}
-
-	s, ok := results[0].(struct {
-		User      common.Address `json:"user"`
-		Node      common.Address `json:"node"`
-		Payment   *big.Int       `json:"payment"`
-		StartedAt *big.Int       `json:"startedAt"`
-		ExpiresAt *big.Int       `json:"expiresAt"`
-		Tier      uint8          `json:"tier"`
-	})
-	if !ok {
-		return nil, fmt.Errorf("unexpected type for subscription tuple: %T", results[0])
-	}
+	s := out.Subscription
 
 	return &OnChainSubscription{
 		User:      s.User,
@@ -185,6 +240,29 @@ func (m *Manager) GetSubscription(ctx context.Context, user common.Address) (*On
 
 // RemainingTime returns the remaining subscription time in seconds (0 if expired).
 func (m *Manager) RemainingTime(ctx context.Context, user common.Address) (uint64, error) {
+	// Cache the deadline rather than the count, so a cached answer is
+	// still accurate.
+	deadline, err := cached(m, cacheKey{read: readRemaining, user: user}, func() (time.Time, error) {
+		remaining, err := m.fetchRemaining(ctx, user)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return m.now().Add(time.Duration(min(remaining, uint64(maxRemaining/time.Second))) * time.Second), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	left := deadline.Sub(m.now())
+	if left <= 0 {
+		return 0, nil
+	}
+	return uint64((left + time.Second - 1) / time.Second), nil
+}
+
+// maxRemaining bounds the remainingTime a cached deadline can represent.
+const maxRemaining = 100 * 365 * 24 * time.Hour
+
+func (m *Manager) fetchRemaining(ctx context.Context, user common.Address) (uint64, error) {
 	callData, err := m.abi.Pack("remainingTime", user)
 	if err != nil {
 		return 0, fmt.Errorf("packing remainingTime: %w", err)
@@ -198,9 +276,9 @@ func (m *Manager) RemainingTime(ctx context.Context, user common.Address) (uint6
 		return 0, fmt.Errorf("calling remainingTime: %w", err)
 	}
 
-	results, err := m.abi.Unpack("remainingTime", output)
+	results, err := ethrpc.Unpack(m.abi, "remainingTime", output, 1)
 	if err != nil {
-		return 0, fmt.Errorf("unpacking remainingTime: %w", err)
+		return 0, err
 	}
 
 	remaining, ok := results[0].(*big.Int)
@@ -212,6 +290,13 @@ func (m *Manager) RemainingTime(ctx context.Context, user common.Address) (uint6
 
 // GetTiers fetches all active tier configurations from the contract.
 func (m *Manager) GetTiers(ctx context.Context) ([]TierInfo, error) {
+	tiers, err := cached(m, cacheKey{read: readTiers}, func() ([]TierInfo, error) {
+		return m.fetchTiers(ctx)
+	})
+	return slices.Clone(tiers), err
+}
+
+func (m *Manager) fetchTiers(ctx context.Context) ([]TierInfo, error) {
 	// Step 1: get active tier IDs
 	idsData, err := m.abi.Pack("getActiveTierIds")
 	if err != nil {
@@ -226,9 +311,9 @@ func (m *Manager) GetTiers(ctx context.Context) ([]TierInfo, error) {
 		return nil, fmt.Errorf("calling getActiveTierIds: %w", err)
 	}
 
-	idsResults, err := m.abi.Unpack("getActiveTierIds", idsOut)
+	idsResults, err := ethrpc.Unpack(m.abi, "getActiveTierIds", idsOut, 1)
 	if err != nil {
-		return nil, fmt.Errorf("unpacking getActiveTierIds: %w", err)
+		return nil, err
 	}
 
 	tierIds, ok := idsResults[0].([]uint8)
@@ -252,14 +337,23 @@ func (m *Manager) GetTiers(ctx context.Context) ([]TierInfo, error) {
 			return nil, fmt.Errorf("calling tiers(%d): %w", id, err)
 		}
 
-		tierResults, err := m.abi.Unpack("tiers", tierOut)
+		tierResults, err := ethrpc.Unpack(m.abi, "tiers", tierOut, 3)
 		if err != nil {
-			return nil, fmt.Errorf("unpacking tiers(%d): %w", id, err)
+			return nil, fmt.Errorf("tier %d: %w", id, err)
 		}
 
-		price, _ := tierResults[0].(*big.Int)
-		duration, _ := tierResults[1].(*big.Int)
-		active, _ := tierResults[2].(bool)
+		price, ok := tierResults[0].(*big.Int)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type for tiers(%d) price: %T", id, tierResults[0])
+		}
+		duration, ok := tierResults[1].(*big.Int)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type for tiers(%d) duration: %T", id, tierResults[1])
+		}
+		active, ok := tierResults[2].(bool)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type for tiers(%d) active: %T", id, tierResults[2])
+		}
 
 		result = append(result, TierInfo{
 			ID:       id,
@@ -282,7 +376,8 @@ func (m *Manager) ChainID() int64 {
 	return m.chainID.Int64()
 }
 
-// Close shuts down the Ethereum client.
+// Close stops the cache sweep and shuts down the Ethereum client.
 func (m *Manager) Close() {
+	m.stopSweep()
 	m.client.Close()
 }
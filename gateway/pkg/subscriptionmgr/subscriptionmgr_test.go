@@ -0,0 +1,263 @@
+package subscriptionmgr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"math/big"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/ethrpc"
+)
+
+const testContract = "0x0000000000000000000000000000000000000001"
+
+// newTestManager returns a Manager whose eth_calls are answered with
+// respond(calldata).
+func newTestManager(t *testing.T, respond func(data []byte) string) *Manager {
+	t.Helper()
+	client := ethrpc.NewFake()
+	client.HandleCalls(common.HexToAddress(testContract), func(call ethereum.CallMsg) ([]byte, error) {
+		return hexutil.Decode(respond(call.Data))
+	})
+	m, err := NewWithClient(client, testContract, 1)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	t.Cleanup(m.Close)
+	return m
+}
+
+func TestReadsRejectMalformedOutput(t *testing.T) {
+	for name, output := range ethrpc.MalformedOutputs {
+		t.Run(name, func(t *testing.T) {
+			m := newTestManager(t, func([]byte) string { return hexutil.Encode(output) })
+			ctx := context.Background()
+
+			reads := map[string]func() error{
+				"HasActiveSubscription": func() error { _, err := m.HasActiveSubscription(ctx, common.Address{}); return err },
+				"GetSubscription":       func() error { _, err := m.GetSubscription(ctx, common.Address{}); return err },
+				"RemainingTime":         func() error { _, err := m.RemainingTime(ctx, common.Address{}); return err },
+				"GetTiers":              func() error { _, err := m.GetTiers(ctx); return err },
+			}
+			for read, call := range reads {
+				if err := call(); err == nil {
+					t.Errorf("%s returned no error", read)
+				}
+			}
+		})
+	}
+}
+
+func TestGetTiersRejectsMalformedTier(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(subscriptionManagerABI))
+	if err != nil {
+		t.Fatalf("parsing ABI: %v", err)
+	}
+	idsSelector := parsed.Methods["getActiveTierIds"].ID
+	// uint8[] containing a single tier ID (1).
+	tierIDs := "0x" +
+		strings.Repeat("00", 31) + "20" +
+		strings.Repeat("00", 31) + "01" +
+		strings.Repeat("00", 31) + "01"
+
+	// Whole words, but no valid tiers() tuple, besides the shared malformed outputs.
+	outputs := maps.Clone(ethrpc.MalformedOutputs)
+	outputs["all ones"] = bytes.Repeat([]byte{0xff}, 32)
+	outputs["bad offset"] = append(common.LeftPadBytes([]byte{0x20}, 32), bytes.Repeat([]byte{0xff}, 32)...)
+
+	for name, output := range outputs {
+		t.Run(name, func(t *testing.T) {
+			m := newTestManager(t, func(data []byte) string {
+				if len(data) >= 4 && string(data[:4]) == string(idsSelector) {
+					return tierIDs
+				}
+				return hexutil.Encode(output)
+			})
+
+			if _, err := m.GetTiers(context.Background()); err == nil {
+				t.Fatal("expected error for malformed tiers() output")
+			}
+		})
+	}
+}
+
+// countingContract answers hasActiveSubscription and remainingTime from its
+// fields, counting calls; failing makes every call error.
+type countingContract struct {
+	calls     atomic.Int32
+	active    atomic.Bool
+	remaining atomic.Uint64
+	failing   atomic.Bool
+}
+
+func newCachedTestManager(t *testing.T, c *countingContract) (*Manager, *clock.Fake) {
+	t.Helper()
+	parsed, err := abi.JSON(strings.NewReader(subscriptionManagerABI))
+	if err != nil {
+		t.Fatalf("parsing ABI: %v", err)
+	}
+	client := ethrpc.NewFake()
+	client.HandleCalls(common.HexToAddress(testContract), func(call ethereum.CallMsg) ([]byte, error) {
+		c.calls.Add(1)
+		if c.failing.Load() {
+			return nil, errors.New("rpc down")
+		}
+		method, err := parsed.MethodById(call.Data)
+		if err != nil {
+			return nil, err
+		}
+		switch method.Name {
+		case "hasActiveSubscription":
+			return method.Outputs.Pack(c.active.Load())
+		case "remainingTime":
+			return method.Outputs.Pack(new(big.Int).SetUint64(c.remaining.Load()))
+		}
+		return nil, errors.New("unexpected call to " + method.Name)
+	})
+	m, err := NewWithClient(client, testContract, 1)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	t.Cleanup(m.Close)
+	clk := clock.NewFake(time.Unix(1_700_000_000, 0))
+	m.SetClock(clk)
+	return m, clk
+}
+
+func TestReadsAreCachedForTTL(t *testing.T) {
+	c := &countingContract{}
+	c.active.Store(true)
+	m, clk := newCachedTestManager(t, c)
+	ctx := context.Background()
+	user := common.HexToAddress("0xa11ce")
+
+	for range 3 {
+		active, err := m.HasActiveSubscription(ctx, user)
+		if err != nil || !active {
+			t.Fatalf("HasActiveSubscription = %v, %v; want true", active, err)
+		}
+	}
+	if n := c.calls.Load(); n != 1 {
+		t.Fatalf("eth_calls = %d after three reads within the TTL, want 1", n)
+	}
+
+	c.active.Store(false)
+	clk.Advance(DefaultCacheTTL)
+	if active, _ := m.HasActiveSubscription(ctx, user); active {
+		t.Error("read after the TTL returned the cached result")
+	}
+	if n := c.calls.Load(); n != 2 {
+		t.Errorf("eth_calls = %d after the TTL, want 2", n)
+	}
+
+	m.SetCacheTTL(0)
+	m.HasActiveSubscription(ctx, user)
+	m.HasActiveSubscription(ctx, user)
+	if n := c.calls.Load(); n != 4 {
+		t.Errorf("eth_calls = %d with caching off, want 4", n)
+	}
+}
+
+func TestRemainingTimeCountsDownFromCache(t *testing.T) {
+	c := &countingContract{}
+	c.remaining.Store(3600)
+	m, clk := newCachedTestManager(t, c)
+	user := common.HexToAddress("0xa11ce")
+
+	if got, _ := m.RemainingTime(context.Background(), user); got != 3600 {
+		t.Fatalf("RemainingTime = %d, want 3600", got)
+	}
+	clk.Advance(10 * time.Second)
+	if got, _ := m.RemainingTime(context.Background(), user); got != 3590 {
+		t.Errorf("cached RemainingTime = %d, want 3590", got)
+	}
+	if n := c.calls.Load(); n != 1 {
+		t.Errorf("eth_calls = %d, want 1", n)
+	}
+}
+
+func TestStaleResultServedWhileRPCFails(t *testing.T) {
+	c := &countingContract{}
+	c.active.Store(true)
+	m, clk := newCachedTestManager(t, c)
+	ctx := context.Background()
+	user := common.HexToAddress("0xa11ce")
+
+	m.HasActiveSubscription(ctx, user)
+	c.failing.Store(true)
+	clk.Advance(DefaultCacheTTL + time.Second)
+	if active, err := m.HasActiveSubscription(ctx, user); err != nil || !active {
+		t.Fatalf("HasActiveSubscription with RPC down = %v, %v; want the stale true", active, err)
+	}
+
+	clk.Advance(DefaultStaleTTL)
+	if _, err := m.HasActiveSubscription(ctx, user); err == nil {
+		t.Error("expected an error once the cached result is past the stale TTL")
+	}
+	if _, err := m.HasActiveSubscription(ctx, common.HexToAddress("0xb0b")); err == nil {
+		t.Error("expected an error for an uncached user with RPC down")
+	}
+}
+
+func TestInvalidateForcesReread(t *testing.T) {
+	c := &countingContract{}
+	m, _ := newCachedTestManager(t, c)
+	ctx := context.Background()
+	alice := common.HexToAddress("0xa11ce")
+	bob := common.HexToAddress("0xb0b")
+
+	m.HasActiveSubscription(ctx, alice)
+	m.HasActiveSubscription(ctx, bob)
+	c.active.Store(true)
+	m.Invalidate(alice)
+
+	if active, _ := m.HasActiveSubscription(ctx, alice); !active {
+		t.Error("alice still sees the cached result after Invalidate")
+	}
+	if active, _ := m.HasActiveSubscription(ctx, bob); active {
+		t.Error("Invalidate(alice) dropped bob's cached result")
+	}
+	if n := c.calls.Load(); n != 3 {
+		t.Errorf("eth_calls = %d, want 3", n)
+	}
+}
+
+func TestGetSubscriptionDecodesTuple(t *testing.T) {
+	want := subscriptionTuple{
+		User:      common.HexToAddress("0xa11ce"),
+		Node:      common.HexToAddress("0xb0b"),
+		Payment:   big.NewInt(1e15),
+		StartedAt: big.NewInt(1_700_000_000),
+		ExpiresAt: big.NewInt(1_702_592_000),
+		Tier:      2,
+	}
+	client := ethrpc.NewFake()
+	m, err := NewWithClient(client, testContract, 1)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer m.Close()
+	client.HandleCalls(common.HexToAddress(testContract), func(call ethereum.CallMsg) ([]byte, error) {
+		return m.abi.Methods["getSubscription"].Outputs.Pack(want)
+	})
+
+	got, err := m.GetSubscription(context.Background(), want.User)
+	if err != nil {
+		t.Fatalf("GetSubscription: %v", err)
+	}
+	if got.User != want.User || got.Node != want.Node || got.Payment.Cmp(want.Payment) != 0 ||
+		got.StartedAt != 1_700_000_000 || got.ExpiresAt != 1_702_592_000 || got.Tier != 2 {
+		t.Errorf("GetSubscription = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,184 @@
+// Package txtracker records transactions the gateway submits on-chain and
+// polls their receipts, so operators can see whether writes are landing.
+package txtracker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Transaction statuses.
+const (
+	StatusPending  = "pending"
+	StatusMined    = "mined"
+	StatusReverted = "reverted"
+)
+
+const (
+	// DefaultPollInterval is how often pending receipts are checked.
+	DefaultPollInterval = 15 * time.Second
+
+	// maxTracked bounds memory; the oldest settled entries are dropped first.
+	maxTracked = 500
+)
+
+// ReceiptSource is the subset of ethclient.Client used to poll receipts.
+type ReceiptSource interface {
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// Tx is one tracked transaction.
+type Tx struct {
+	Hash        string    `json:"hash"`
+	Source      string    `json:"source"` // e.g. "sessionmgr", "heartbeat"
+	Method      string    `json:"method"`
+	Status      string    `json:"status"`
+	SubmittedAt time.Time `json:"submitted_at"`
+	BlockNumber uint64    `json:"block_number,omitempty"`
+	GasUsed     uint64    `json:"gas_used,omitempty"`
+	Error       string    `json:"error,omitempty"` // last receipt lookup error
+}
+
+// Tracker holds submitted transactions and their latest known status.
+type Tracker struct {
+	client   ReceiptSource
+	interval time.Duration
+
+	mu  sync.Mutex
+	txs map[common.Hash]*Tx
+}
+
+// New creates a tracker that polls client every interval
+// (DefaultPollInterval if zero).
+func New(client ReceiptSource, interval time.Duration) *Tracker {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	return &Tracker{
+		client:   client,
+		interval: interval,
+		txs:      make(map[common.Hash]*Tx),
+	}
+}
+
+// Track registers a submitted transaction as pending.
+func (t *Tracker) Track(hash common.Hash, source, method string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.txs[hash] = &Tx{
+		Hash:        hash.Hex(),
+		Source:      source,
+		Method:      method,
+		Status:      StatusPending,
+		SubmittedAt: time.Now(),
+	}
+	t.pruneLocked()
+}
+
+// List returns all tracked transactions, newest first.
+func (t *Tracker) List() []Tx {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	list := make([]Tx, 0, len(t.txs))
+	for _, tx := range t.txs {
+		list = append(list, *tx)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].SubmittedAt.After(list[j].SubmittedAt)
+	})
+	return list
+}
+
+// Start polls receipts for pending transactions. Blocks until ctx is cancelled.
+func (t *Tracker) Start(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.poll(ctx)
+		}
+	}
+}
+
+// poll checks the receipt of every pending transaction once.
+func (t *Tracker) poll(ctx context.Context) {
+	t.mu.Lock()
+	var pending []common.Hash
+	for hash, tx := range t.txs {
+		if tx.Status == StatusPending {
+			pending = append(pending, hash)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, hash := range pending {
+		receipt, err := t.client.TransactionReceipt(ctx, hash)
+
+		t.mu.Lock()
+		tx, ok := t.txs[hash]
+		if !ok {
+			t.mu.Unlock()
+			continue
+		}
+		switch {
+		case errors.Is(err, ethereum.NotFound):
+			tx.Error = ""
+		case err != nil:
+			tx.Error = err.Error()
+		default:
+			tx.Error = ""
+			tx.GasUsed = receipt.GasUsed
+			if receipt.BlockNumber != nil {
+				tx.BlockNumber = receipt.BlockNumber.Uint64()
+			}
+			if receipt.Status == types.ReceiptStatusSuccessful {
+				tx.Status = StatusMined
+			} else {
+				tx.Status = StatusReverted
+				log.Printf("[txtracker] %s %s tx reverted: %s", tx.Source, tx.Method, tx.Hash)
+			}
+		}
+		t.mu.Unlock()
+	}
+}
+
+// pruneLocked drops the oldest entries beyond maxTracked, preferring settled
+// ones so a pending tx is not forgotten while a burst of newer writes lands.
+func (t *Tracker) pruneLocked() {
+	if len(t.txs) <= maxTracked {
+		return
+	}
+
+	type entry struct {
+		hash common.Hash
+		tx   *Tx
+	}
+	entries := make([]entry, 0, len(t.txs))
+	for h, tx := range t.txs {
+		entries = append(entries, entry{h, tx})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		pi, pj := entries[i].tx.Status == StatusPending, entries[j].tx.Status == StatusPending
+		if pi != pj {
+			return !pi
+		}
+		return entries[i].tx.SubmittedAt.Before(entries[j].tx.SubmittedAt)
+	})
+	for _, e := range entries[:len(t.txs)-maxTracked] {
+		delete(t.txs, e.hash)
+	}
+}
@@ -0,0 +1,84 @@
+package txtracker
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type fakeReceipts map[common.Hash]*types.Receipt
+
+func (f fakeReceipts) TransactionReceipt(_ context.Context, hash common.Hash) (*types.Receipt, error) {
+	if hash == common.HexToHash("0xe1") {
+		return nil, errors.New("rpc unavailable")
+	}
+	r, ok := f[hash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return r, nil
+}
+
+func TestPollUpdatesStatus(t *testing.T) {
+	mined := common.HexToHash("0x01")
+	reverted := common.HexToHash("0x02")
+	pending := common.HexToHash("0x03")
+	failing := common.HexToHash("0xe1")
+
+	tr := New(fakeReceipts{
+		mined:    {Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(42), GasUsed: 51000},
+		reverted: {Status: types.ReceiptStatusFailed, BlockNumber: big.NewInt(43)},
+	}, 0)
+	tr.Track(mined, "sessionmgr", "openSession")
+	tr.Track(reverted, "sessionmgr", "closeSession")
+	tr.Track(pending, "heartbeat", "heartbeat")
+	tr.Track(failing, "heartbeat", "heartbeat")
+
+	tr.poll(context.Background())
+
+	got := make(map[string]Tx)
+	for _, tx := range tr.List() {
+		got[tx.Hash] = tx
+	}
+	if tx := got[mined.Hex()]; tx.Status != StatusMined || tx.BlockNumber != 42 || tx.GasUsed != 51000 {
+		t.Errorf("mined tx = %+v", tx)
+	}
+	if tx := got[reverted.Hex()]; tx.Status != StatusReverted {
+		t.Errorf("reverted tx = %+v", tx)
+	}
+	if tx := got[pending.Hex()]; tx.Status != StatusPending || tx.Error != "" {
+		t.Errorf("pending tx = %+v", tx)
+	}
+	if tx := got[failing.Hex()]; tx.Status != StatusPending || tx.Error == "" {
+		t.Errorf("expected lookup error recorded on pending tx, got %+v", tx)
+	}
+}
+
+func TestTrackPrunesOldestSettled(t *testing.T) {
+	tr := New(fakeReceipts{}, 0)
+	first := common.BigToHash(big.NewInt(1))
+	tr.Track(first, "heartbeat", "heartbeat") // stays pending
+
+	second := common.BigToHash(big.NewInt(2))
+	tr.Track(second, "heartbeat", "heartbeat")
+	tr.txs[second].Status = StatusMined
+
+	for i := 3; i <= maxTracked+1; i++ {
+		tr.Track(common.BigToHash(big.NewInt(int64(i))), "heartbeat", "heartbeat")
+	}
+
+	if n := len(tr.List()); n != maxTracked {
+		t.Fatalf("expected %d tracked, got %d", maxTracked, n)
+	}
+	if _, ok := tr.txs[second]; ok {
+		t.Error("expected oldest settled tx to be pruned first")
+	}
+	if _, ok := tr.txs[first]; !ok {
+		t.Error("expected pending tx to be kept")
+	}
+}
@@ -0,0 +1,124 @@
+// Package usage keeps a rolling per-wallet history of VPN data usage in
+// daily buckets, stored in an embedded bbolt database, so users can see what
+// they used this week and operators can reconcile billing.
+package usage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultRetention is how many days of history are kept per wallet.
+const DefaultRetention = 90
+
+// dayLayout keys buckets by UTC date, which also sorts them chronologically.
+const dayLayout = "2006-01-02"
+
+var walletsBucket = []byte("wallets")
+
+// Day is one day of usage.
+type Day struct {
+	Date  string `json:"date"` // YYYY-MM-DD, UTC
+	Bytes uint64 `json:"bytes"`
+}
+
+// Store is a bbolt-backed usage history.
+type Store struct {
+	db        *bolt.DB
+	retention int
+	now       func() time.Time
+}
+
+// Open opens (or creates) the usage database at path.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening usage db: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(walletsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initializing usage db: %w", err)
+	}
+	return &Store{db: db, retention: DefaultRetention, now: time.Now}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add attributes bytes to wallet's bucket for today and drops buckets older
+// than the retention window.
+func (s *Store) Add(wallet string, bytes uint64) error {
+	if bytes == 0 {
+		return nil
+	}
+	now := s.now().UTC()
+	today := []byte(now.Format(dayLayout))
+	cutoff := []byte(now.AddDate(0, 0, -s.retention+1).Format(dayLayout))
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(walletsBucket).CreateBucketIfNotExists([]byte(wallet))
+		if err != nil {
+			return err
+		}
+
+		var total uint64
+		if v := b.Get(today); len(v) == 8 {
+			total = binary.BigEndian.Uint64(v)
+		}
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], total+bytes)
+		if err := b.Put(today, buf[:]); err != nil {
+			return err
+		}
+
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && string(k) < string(cutoff); k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// History returns wallet's usage for the last days days (including today),
+// oldest first, with zero entries for days without traffic.
+func (s *Store) History(wallet string, days int) ([]Day, error) {
+	if days <= 0 || days > s.retention {
+		days = s.retention
+	}
+	now := s.now().UTC()
+
+	recorded := make(map[string]uint64)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(walletsBucket).Bucket([]byte(wallet))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			if len(v) == 8 {
+				recorded[string(k)] = binary.BigEndian.Uint64(v)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading usage history: %w", err)
+	}
+
+	history := make([]Day, days)
+	for i := range history {
+		date := now.AddDate(0, 0, i-days+1).Format(dayLayout)
+		history[i] = Day{Date: date, Bytes: recorded[date]}
+	}
+	return history, nil
+}
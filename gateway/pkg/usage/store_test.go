@@ -0,0 +1,89 @@
+package usage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestHistoryDailyBuckets(t *testing.T) {
+	s := openTestStore(t)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	s.Add("alice", 100)
+	s.Add("alice", 50)
+	now = now.Add(24 * time.Hour)
+	s.Add("alice", 7)
+	s.Add("bob", 1)
+
+	history, err := s.History("alice", 3)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	want := []Day{{"2026-03-09", 0}, {"2026-03-10", 150}, {"2026-03-11", 7}}
+	if len(history) != len(want) {
+		t.Fatalf("got %d days, want %d", len(history), len(want))
+	}
+	for i := range want {
+		if history[i] != want[i] {
+			t.Errorf("day %d = %+v, want %+v", i, history[i], want[i])
+		}
+	}
+
+	if history, _ := s.History("carol", 2); history[0].Bytes != 0 || history[1].Bytes != 0 {
+		t.Errorf("unknown wallet should have empty history, got %+v", history)
+	}
+}
+
+func TestAddPrunesBeyondRetention(t *testing.T) {
+	s := openTestStore(t)
+	s.retention = 2
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	s.Add("alice", 100)
+	now = now.AddDate(0, 0, 2)
+	s.Add("alice", 1)
+
+	var days int
+	s.db.View(func(tx *bolt.Tx) error {
+		days = tx.Bucket(walletsBucket).Bucket([]byte("alice")).Stats().KeyN
+		return nil
+	})
+	if days != 1 {
+		t.Fatalf("expected old bucket pruned, %d buckets remain", days)
+	}
+}
+
+func TestHistoryPersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	s.Add("alice", 42)
+	s.Close()
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	history, err := s.History("alice", 1)
+	if err != nil || history[0].Bytes != 42 {
+		t.Fatalf("History after reopen = %+v, %v; want 42 bytes today", history, err)
+	}
+}
@@ -0,0 +1,208 @@
+// Package webhook delivers session lifecycle events (revocation, expiry, eviction) to an
+// external HTTP endpoint so integrators such as billing backends or chat bots
+// can react when a user loses access.
+//
+// Each event is POSTed as JSON with an HMAC-SHA256 signature of the raw body
+// in the X-SVPN-Signature header ("sha256=<hex>"). Deliveries are queued and
+// retried with exponential backoff; when the queue is full new events are
+// dropped rather than blocking the caller.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/outbound"
+)
+
+const (
+	// SignatureHeader carries the hex HMAC-SHA256 of the request body.
+	SignatureHeader = "X-SVPN-Signature"
+
+	// ReasonNFTTransferred is sent when a transfer event removes the wallet's access.
+	ReasonNFTTransferred = "nft_transferred"
+
+	// ReasonSessionExpired is sent when a session reaches its expiry time.
+	ReasonSessionExpired = "session_expired"
+
+	// ReasonSessionEvicted is sent when a session is dropped before its
+	// expiry to stay under the gateway's session cap.
+	ReasonSessionEvicted = "session_evicted"
+
+	// DefaultQueueSize is the number of undelivered events held in memory.
+	DefaultQueueSize = 256
+
+	// DefaultMaxAttempts is how many times a delivery is tried before it is dropped.
+	DefaultMaxAttempts = 5
+)
+
+// Event is the JSON payload posted to the webhook URL.
+type Event struct {
+	Address   string    `json:"address"`
+	Reason    string    `json:"reason"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Config configures a Notifier.
+type Config struct {
+	URL         string        // Endpoint to POST events to
+	Secret      string        // HMAC key for the signature header
+	QueueSize   int           // Pending event capacity (default: 256)
+	MaxAttempts int           // Delivery attempts per event (default: 5)
+	BaseBackoff time.Duration // Delay before the first retry, doubled each time (default: 1s)
+	HTTPTimeout time.Duration // Per-request timeout (default: 10s)
+}
+
+// Notifier queues events and delivers them from a background worker.
+type Notifier struct {
+	url         string
+	secret      []byte
+	maxAttempts int
+	baseBackoff time.Duration
+	client      *http.Client
+
+	queue  chan Event
+	stopCh chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewNotifier creates a webhook notifier and starts its delivery worker.
+func NewNotifier(cfg Config) (*Notifier, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook URL is required")
+	}
+	if cfg.Secret == "" {
+		return nil, fmt.Errorf("webhook secret is required")
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = time.Second
+	}
+	if cfg.HTTPTimeout <= 0 {
+		cfg.HTTPTimeout = 10 * time.Second
+	}
+
+	n := &Notifier{
+		url:         cfg.URL,
+		secret:      []byte(cfg.Secret),
+		maxAttempts: cfg.MaxAttempts,
+		baseBackoff: cfg.BaseBackoff,
+		client:      &http.Client{Timeout: cfg.HTTPTimeout, Transport: outbound.Transport()},
+		queue:       make(chan Event, cfg.QueueSize),
+		stopCh:      make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go n.run()
+	return n, nil
+}
+
+// Notify queues an event for the wallet. It never blocks; if the queue is
+// full the event is dropped and logged.
+func (n *Notifier) Notify(wallet common.Address, reason string) {
+	ev := Event{
+		Address:   wallet.Hex(),
+		Reason:    reason,
+		Timestamp: time.Now().UTC(),
+	}
+	select {
+	case n.queue <- ev:
+	default:
+		log.Printf("[webhook] Queue full, dropping %s event", reason)
+	}
+}
+
+// Close stops the delivery worker. Events still queued are discarded.
+func (n *Notifier) Close() {
+	n.once.Do(func() {
+		close(n.stopCh)
+		<-n.done
+	})
+}
+
+func (n *Notifier) run() {
+	defer close(n.done)
+	for {
+		select {
+		case <-n.stopCh:
+			return
+		case ev := <-n.queue:
+			n.deliver(ev)
+		}
+	}
+}
+
+// deliver posts the event, retrying with exponential backoff until it is
+// accepted, the attempt budget runs out, or the notifier is closed.
+func (n *Notifier) deliver(ev Event) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("[webhook] Failed to encode event: %v", err)
+		return
+	}
+
+	backoff := n.baseBackoff
+	for attempt := 1; attempt <= n.maxAttempts; attempt++ {
+		err = n.post(body)
+		if err == nil {
+			return
+		}
+		if attempt == n.maxAttempts {
+			break
+		}
+		select {
+		case <-n.stopCh:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	log.Printf("[webhook] Giving up on %s event after %d attempts: %v", ev.Reason, n.maxAttempts, err)
+}
+
+func (n *Notifier) post(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), n.client.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, "sha256="+Sign(n.secret, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting webhook: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of body under secret. Receivers can use it
+// to verify the X-SVPN-Signature header.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestNotifySignsPayload(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	n, err := NewNotifier(Config{URL: srv.URL, Secret: "s3cret"})
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	defer n.Close()
+
+	wallet := common.HexToAddress("0x1234567890abcdef1234567890abcdef12345678")
+	n.Notify(wallet, ReasonNFTTransferred)
+
+	var req *http.Request
+	var body []byte
+	select {
+	case req = <-received:
+		body = <-bodies
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+
+	if got, want := req.Header.Get(SignatureHeader), "sha256="+Sign([]byte("s3cret"), body); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+
+	var ev Event
+	if err := json.Unmarshal(body, &ev); err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+	if ev.Address != wallet.Hex() {
+		t.Errorf("address = %q, want %q", ev.Address, wallet.Hex())
+	}
+	if ev.Reason != ReasonNFTTransferred {
+		t.Errorf("reason = %q, want %q", ev.Reason, ReasonNFTTransferred)
+	}
+	if ev.Timestamp.IsZero() {
+		t.Error("expected timestamp")
+	}
+}
+
+func TestNotifyRetriesOnFailure(t *testing.T) {
+	var calls atomic.Int32
+	delivered := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		close(delivered)
+	}))
+	defer srv.Close()
+
+	n, err := NewNotifier(Config{URL: srv.URL, Secret: "s", BaseBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	defer n.Close()
+
+	n.Notify(common.Address{}, ReasonSessionExpired)
+
+	select {
+	case <-delivered:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected delivery after retries, got %d calls", calls.Load())
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls.Load())
+	}
+}
+
+func TestNotifyGivesUpAfterMaxAttempts(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	n, err := NewNotifier(Config{URL: srv.URL, Secret: "s", MaxAttempts: 2, BaseBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+	defer n.Close()
+
+	n.Notify(common.Address{}, ReasonSessionExpired)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for calls.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 attempts, got %d", calls.Load())
+	}
+}
+
+func TestNotifyDropsWhenQueueFull(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	n, err := NewNotifier(Config{URL: srv.URL, Secret: "s", QueueSize: 1})
+	if err != nil {
+		t.Fatalf("NewNotifier: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			n.Notify(common.Address{}, ReasonNFTTransferred)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Notify blocked on a full queue")
+	}
+}
+
+func TestNewNotifierRequiresURLAndSecret(t *testing.T) {
+	if _, err := NewNotifier(Config{Secret: "s"}); err == nil {
+		t.Error("expected error without URL")
+	}
+	if _, err := NewNotifier(Config{URL: "http://example.com"}); err == nil {
+		t.Error("expected error without secret")
+	}
+}
@@ -0,0 +1,215 @@
+package wireguard
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backend names accepted in Config.Backend.
+const (
+	// BackendShell configures peers by running the `wg` tool (default).
+	BackendShell = "wg"
+
+	// BackendNetlink configures peers in-process through the kernel netlink
+	// API using wgctrl. Requires a gateway built with the wgctrl build tag.
+	BackendNetlink = "netlink"
+
+	// BackendMemory only records peers in memory and touches no interface,
+	// for running the gateway without WireGuard in dev mode. Clients get
+	// configs for a tunnel that does not exist.
+	BackendMemory = "memory"
+
+	// BackendNone provisions no tunnels at all, for a discovery-only
+	// gateway that lists nodes and signs wallets in while other nodes
+	// carry the traffic. Adding a peer fails with ErrNoTunnels.
+	BackendNone = "none"
+)
+
+// ErrNoTunnels is returned when adding a peer to a manager using
+// BackendNone.
+var ErrNoTunnels = errors.New("this gateway does not provision tunnels")
+
+// Backend applies peer changes to a WireGuard interface.
+type Backend interface {
+	// SetPeer adds or updates a peer with a single allowed IP (/32).
+	SetPeer(iface, pubKey, clientIP string) error
+	// RemovePeer removes a peer from the interface.
+	RemovePeer(iface, pubKey string) error
+	// PrivateKey returns the interface's base64 private key, or "" if none
+	// is set. It returns an error if the interface does not exist.
+	PrivateKey(iface string) (string, error)
+	// PeerStats returns handshake and transfer counters keyed by public key.
+	PeerStats(iface string) (map[string]PeerStats, error)
+}
+
+// PeerStats holds kernel-reported counters for one peer.
+type PeerStats struct {
+	LastHandshake time.Time
+	BytesReceived uint64
+	BytesSent     uint64
+}
+
+// newBackend returns the backend for a Config.Backend value.
+func newBackend(name string) (Backend, error) {
+	switch name {
+	case "", BackendShell:
+		return shellBackend{}, nil
+	case BackendNetlink:
+		return newNetlinkBackend()
+	case BackendMemory:
+		return &memoryBackend{peers: make(map[string]string)}, nil
+	case BackendNone:
+		return noneBackend{}, nil
+	default:
+		return nil, fmt.Errorf("unknown WireGuard backend %q (want %q, %q, %q or %q)", name, BackendShell, BackendNetlink, BackendMemory, BackendNone)
+	}
+}
+
+// memoryBackend is BackendMemory: it tracks peers per interface in a map.
+type memoryBackend struct {
+	mu    sync.Mutex
+	peers map[string]string // iface + "/" + public key -> client IP
+}
+
+func (b *memoryBackend) SetPeer(iface, pubKey, clientIP string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.peers[iface+"/"+pubKey] = clientIP
+	return nil
+}
+
+func (b *memoryBackend) RemovePeer(iface, pubKey string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.peers, iface+"/"+pubKey)
+	return nil
+}
+
+// PrivateKey reports a placeholder key: every interface "exists".
+func (b *memoryBackend) PrivateKey(iface string) (string, error) {
+	return "(memory)", nil
+}
+
+// PeerStats reports every peer as never having shaken hands.
+func (b *memoryBackend) PeerStats(iface string) (map[string]PeerStats, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := make(map[string]PeerStats)
+	for key := range b.peers {
+		if name, pubKey, _ := strings.Cut(key, "/"); name == iface {
+			stats[pubKey] = PeerStats{}
+		}
+	}
+	return stats, nil
+}
+
+// noneBackend is BackendNone: it refuses every peer.
+type noneBackend struct{}
+
+func (noneBackend) SetPeer(iface, pubKey, clientIP string) error { return ErrNoTunnels }
+
+func (noneBackend) RemovePeer(iface, pubKey string) error { return nil }
+
+// PrivateKey reports a placeholder key so health checks pass without an
+// interface.
+func (noneBackend) PrivateKey(iface string) (string, error) { return "(none)", nil }
+
+func (noneBackend) PeerStats(iface string) (map[string]PeerStats, error) {
+	return map[string]PeerStats{}, nil
+}
+
+// shellBackend shells out to the `wg` tool.
+type shellBackend struct{}
+
+// runWG runs the wg tool with the given arguments and returns its combined output.
+// Tests replace it to avoid needing a real interface.
+var runWG = func(args ...string) ([]byte, error) {
+	return exec.Command("wg", args...).CombinedOutput()
+}
+
+func (shellBackend) SetPeer(iface, pubKey, clientIP string) error {
+	// wg set wg0 peer <pubkey> allowed-ips <clientIP>/32
+	output, err := runWG("set", iface,
+		"peer", pubKey,
+		"allowed-ips", clientIP+"/32",
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+func (shellBackend) RemovePeer(iface, pubKey string) error {
+	// wg set wg0 peer <pubkey> remove
+	output, err := runWG("set", iface,
+		"peer", pubKey, "remove",
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+func (shellBackend) PrivateKey(iface string) (string, error) {
+	output, err := runWG("show", iface, "private-key")
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", strings.TrimSpace(string(output)), err)
+	}
+	key := strings.TrimSpace(string(output))
+	if key == "(none)" {
+		return "", nil
+	}
+	return key, nil
+}
+
+func (shellBackend) PeerStats(iface string) (map[string]PeerStats, error) {
+	output, err := runWG("show", iface, "dump")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return parseDump(string(output))
+}
+
+// parseDump parses `wg show <iface> dump`. The first line describes the
+// interface; each following line is a tab-separated peer:
+// public-key, preshared-key, endpoint, allowed-ips, latest-handshake,
+// transfer-rx, transfer-tx, persistent-keepalive.
+func parseDump(dump string) (map[string]PeerStats, error) {
+	stats := make(map[string]PeerStats)
+	lines := strings.Split(strings.TrimSpace(dump), "\n")
+	for _, line := range lines[1:] {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 8 {
+			return nil, fmt.Errorf("malformed wg dump line: %d fields", len(fields))
+		}
+
+		handshake, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing latest-handshake: %w", err)
+		}
+		rx, err := strconv.ParseUint(fields[5], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing transfer-rx: %w", err)
+		}
+		tx, err := strconv.ParseUint(fields[6], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing transfer-tx: %w", err)
+		}
+
+		var last time.Time
+		if handshake > 0 {
+			last = time.Unix(handshake, 0)
+		}
+		stats[fields[0]] = PeerStats{
+			LastHandshake: last,
+			BytesReceived: rx,
+			BytesSent:     tx,
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,39 @@
+package wireguard
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DNSPolicy is what an operator asserts about the resolver their node hands
+// to clients. It is self-reported and cannot be verified remotely; a node
+// that advertises nothing makes no claim either way.
+type DNSPolicy struct {
+	LocalResolver bool `json:"local_resolver"` // queries are resolved on the node
+	NoQueryLogs   bool `json:"no_query_logs"`  // the resolver keeps no query logs
+	DoH           bool `json:"doh"`            // upstream lookups use DNS-over-HTTPS
+}
+
+// ParseDNSPolicy parses a comma-separated list of "local-resolver",
+// "no-logs" and "doh". "none" advertises a policy asserting none of them;
+// an empty string advertises no policy and returns nil.
+func ParseDNSPolicy(s string) (*DNSPolicy, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	p := &DNSPolicy{}
+	for _, item := range strings.Split(s, ",") {
+		switch strings.TrimSpace(item) {
+		case "local-resolver":
+			p.LocalResolver = true
+		case "no-logs":
+			p.NoQueryLogs = true
+		case "doh":
+			p.DoH = true
+		case "none":
+		default:
+			return nil, fmt.Errorf("unknown DNS policy %q (want local-resolver, no-logs, doh or none)", strings.TrimSpace(item))
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,180 @@
+package wireguard
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHookTimeout bounds one run of a peer hook script.
+const DefaultHookTimeout = 10 * time.Second
+
+// hookQueueSize is how many add events may wait for their hook to run;
+// adds beyond it are dropped rather than holding up peer changes. Remove
+// events are never dropped, so scripts that set something up per peer
+// always get to tear it down; a remove is merged into the peer's waiting
+// remove unless an add for the peer is queued after it, which bounds them
+// by the peers the manager held.
+const hookQueueSize = 256
+
+// Hook events, passed to scripts in SVPN_PEER_EVENT.
+const (
+	hookEventAdd    = "add"
+	hookEventRemove = "remove"
+)
+
+// peerEvent is a peer added to or removed from an interface.
+type peerEvent struct {
+	kind   string // hookEventAdd or hookEventRemove
+	script string
+	peer   Peer
+	seq    uint64 // order queued
+}
+
+// hookRunner runs Config.OnPeerAdd and Config.OnPeerRemove scripts one at a
+// time, in the order peers changed, off the manager's lock.
+type hookRunner struct {
+	onAdd, onRemove string
+	timeout         time.Duration
+
+	mu      sync.Mutex
+	pending []peerEvent       // waiting to run, oldest first
+	queued  uint64            // events queued so far, for peerEvent.seq
+	adds    int               // add events in pending
+	removes map[string]uint64 // peerKey -> seq of its remove, while the last pending event for the peer
+	wake    chan struct{}     // signalled when an event is queued
+}
+
+// newHookRunner starts a runner for cfg's hooks, or returns nil if none is
+// set. It fails if a script cannot be found or is not executable.
+func newHookRunner(cfg Config) (*hookRunner, error) {
+	if cfg.OnPeerAdd == "" && cfg.OnPeerRemove == "" {
+		return nil, nil
+	}
+	for _, script := range []string{cfg.OnPeerAdd, cfg.OnPeerRemove} {
+		if script == "" {
+			continue
+		}
+		if _, err := exec.LookPath(script); err != nil {
+			return nil, fmt.Errorf("peer hook: %w", err)
+		}
+	}
+	timeout := cfg.HookTimeout
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+	h := &hookRunner{
+		onAdd:    cfg.OnPeerAdd,
+		onRemove: cfg.OnPeerRemove,
+		timeout:  timeout,
+		removes:  make(map[string]uint64),
+		wake:     make(chan struct{}, 1),
+	}
+	go h.run()
+	return h, nil
+}
+
+// added queues the add hook for peer. Safe on a nil runner.
+func (h *hookRunner) added(peer Peer) {
+	if h != nil && h.onAdd != "" {
+		h.queue(peerEvent{kind: hookEventAdd, script: h.onAdd, peer: peer})
+	}
+}
+
+// removed queues the remove hook for peer. Safe on a nil runner.
+func (h *hookRunner) removed(peer Peer) {
+	if h != nil && h.onRemove != "" {
+		h.queue(peerEvent{kind: hookEventRemove, script: h.onRemove, peer: peer})
+	}
+}
+
+// queue adds ev to the pending events without blocking, as it is called
+// with the manager's lock held. See hookQueueSize for what is dropped.
+func (h *hookRunner) queue(ev peerEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := peerKey(ev.peer)
+	switch ev.kind {
+	case hookEventAdd:
+		if h.adds >= hookQueueSize {
+			log.Printf("[wireguard] Peer add hook queue full; skipping %s", ev.script)
+			return
+		}
+		h.adds++
+		delete(h.removes, key) // a later remove must run after this add
+	case hookEventRemove:
+		if _, ok := h.removes[key]; ok {
+			return // the waiting remove covers it
+		}
+	}
+	h.queued++
+	ev.seq = h.queued
+	if ev.kind == hookEventRemove {
+		h.removes[key] = ev.seq
+	}
+	h.pending = append(h.pending, ev)
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next takes the oldest pending event, if any.
+func (h *hookRunner) next() (peerEvent, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.pending) == 0 {
+		h.pending = nil // let the drained backing array go
+		return peerEvent{}, false
+	}
+	ev := h.pending[0]
+	h.pending = h.pending[1:]
+	if ev.kind == hookEventAdd {
+		h.adds--
+	} else if key := peerKey(ev.peer); h.removes[key] == ev.seq {
+		delete(h.removes, key)
+	}
+	return ev, true
+}
+
+func (h *hookRunner) run() {
+	for range h.wake {
+		for ev, ok := h.next(); ok; ev, ok = h.next() {
+			h.exec(ev)
+		}
+	}
+}
+
+// peerKey identifies a peer's placement for coalescing remove events.
+func peerKey(p Peer) string {
+	return p.Interface + "/" + p.PublicKey + "/" + p.ClientIP
+}
+
+// exec runs one hook as `script <public key> <client IP> <tier>`, with the
+// same values and the interface in SVPN_PEER_* variables. Failures are only
+// logged: the peer change has already been made.
+func (h *hookRunner) exec(ev peerEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, ev.script, ev.peer.PublicKey, ev.peer.ClientIP, ev.peer.Tier)
+	cmd.Env = append(os.Environ(),
+		"SVPN_PEER_EVENT="+ev.kind,
+		"SVPN_PEER_PUBLIC_KEY="+ev.peer.PublicKey,
+		"SVPN_PEER_CLIENT_IP="+ev.peer.ClientIP,
+		"SVPN_PEER_TIER="+ev.peer.Tier,
+		"SVPN_PEER_INTERFACE="+ev.peer.Interface,
+	)
+	cmd.WaitDelay = time.Second // stop waiting on output held open by the script's children
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		log.Printf("[wireguard] Peer %s hook %s timed out after %s", ev.kind, ev.script, h.timeout)
+	} else if err != nil {
+		log.Printf("[wireguard] Peer %s hook %s failed: %v: %s", ev.kind, ev.script, err, strings.TrimSpace(string(output)))
+	}
+}
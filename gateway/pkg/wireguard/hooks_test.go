@@ -0,0 +1,143 @@
+package wireguard
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeScript writes an executable shell script to dir.
+func writeScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// waitLines polls path until it has n lines.
+func waitLines(t *testing.T, path string, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		data, _ := os.ReadFile(path)
+		if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(data) > 0 && len(lines) >= n {
+			return lines
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s did not get %d lines", path, n)
+	return nil
+}
+
+func TestPeerHooksRunOnAddAndRemove(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "events")
+	script := writeScript(t, dir, "hook.sh", `echo "$SVPN_PEER_EVENT $1 $2 $3 $SVPN_PEER_INTERFACE" >> `+out)
+
+	m, err := NewManager(Config{
+		Interface:    "wg0",
+		Subnet:       "10.8.0.0/24",
+		Backend:      BackendMemory,
+		OnPeerAdd:    script,
+		OnPeerRemove: script,
+	})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if _, err := m.AddPeer("peer-key", "paid", time.Hour); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	if err := m.RemovePeer("peer-key"); err != nil {
+		t.Fatalf("RemovePeer: %v", err)
+	}
+
+	lines := waitLines(t, out, 2)
+	if lines[0] != "add peer-key 10.8.0.2 paid wg0" || lines[1] != "remove peer-key 10.8.0.2 paid wg0" {
+		t.Errorf("hook saw %q", lines)
+	}
+}
+
+func TestPeerHookFailuresDoNotBlockPeers(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "events")
+	m, err := NewManager(Config{
+		Interface:    "wg0",
+		Subnet:       "10.8.0.0/24",
+		Backend:      BackendMemory,
+		OnPeerAdd:    writeScript(t, dir, "slow.sh", "sleep 5"),
+		OnPeerRemove: writeScript(t, dir, "done.sh", "echo $1 >> "+out+"; exit 1"),
+		HookTimeout:  50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := m.AddPeer("peer-key", "free", time.Hour); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	if err := m.RemovePeer("peer-key"); err != nil {
+		t.Fatalf("RemovePeer: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("peer changes took %s waiting on hooks", elapsed)
+	}
+	// The timed-out add hook is killed and the remove hook still runs.
+	if lines := waitLines(t, out, 1); lines[0] != "peer-key" {
+		t.Errorf("remove hook saw %q", lines)
+	}
+}
+
+func TestPeerHookQueueKeepsRemoves(t *testing.T) {
+	// Not started, so everything queued stays pending.
+	h := &hookRunner{onAdd: "add.sh", onRemove: "remove.sh", removes: make(map[string]uint64), wake: make(chan struct{}, 1)}
+	for i := 0; i < hookQueueSize+10; i++ {
+		h.added(Peer{PublicKey: fmt.Sprintf("key-%d", i), ClientIP: "10.8.0.2", Interface: "wg0"})
+	}
+	gone := Peer{PublicKey: "key-0", ClientIP: "10.8.0.2", Interface: "wg0"}
+	h.removed(gone)
+	h.removed(gone)
+	h.removed(Peer{PublicKey: "key-1", ClientIP: "10.8.0.3", Interface: "wg0"})
+
+	var adds, removes int
+	for ev, ok := h.next(); ok; ev, ok = h.next() {
+		if ev.kind == hookEventAdd {
+			adds++
+		} else {
+			removes++
+		}
+	}
+	if adds != hookQueueSize || removes != 2 {
+		t.Errorf("ran %d adds and %d removes, want %d adds and 2 removes (one per peer)", adds, removes, hookQueueSize)
+	}
+}
+
+func TestPeerHookQueueKeepsRemoveAfterReAdd(t *testing.T) {
+	h := &hookRunner{onAdd: "add.sh", onRemove: "remove.sh", removes: make(map[string]uint64), wake: make(chan struct{}, 1)}
+	peer := Peer{PublicKey: "key-0", ClientIP: "10.8.0.2", Interface: "wg0"}
+	h.removed(peer)
+	h.added(peer)
+	h.removed(peer)
+	h.removed(peer) // merged into the one before
+
+	var kinds []string
+	for ev, ok := h.next(); ok; ev, ok = h.next() {
+		kinds = append(kinds, ev.kind)
+	}
+	if want := []string{hookEventRemove, hookEventAdd, hookEventRemove}; !slices.Equal(kinds, want) {
+		t.Errorf("ran %v, want %v", kinds, want)
+	}
+}
+
+func TestNewManagerRejectsMissingHook(t *testing.T) {
+	_, err := NewManager(Config{Interface: "wg0", Subnet: "10.8.0.0/24", OnPeerAdd: filepath.Join(t.TempDir(), "missing.sh")})
+	if err == nil {
+		t.Fatal("expected error for a hook script that does not exist")
+	}
+}
@@ -1,6 +1,8 @@
 // Package wireguard manages WireGuard peers for the Sovereign VPN.
-// This is the Phase 0 standalone implementation. It shells out to `wg` and `ip`
-// commands to manage peers on a pre-configured WireGuard interface.
+// This is the Phase 0 standalone implementation. It manages peers on one or
+// more pre-configured WireGuard interfaces, by default by shelling out to `wg`.
+// Gateways built with the wgctrl build tag can instead configure the
+// interface in-process over netlink (Config.Backend = "netlink").
 //
 // In Phase 1+, this may be replaced by Sentinel's service layer, but the
 // interface stays the same.
@@ -11,11 +13,16 @@ import (
 	"encoding/base64"
 	"fmt"
 	"log"
+	"maps"
 	"net"
-	"os/exec"
 	"strings"
 	"sync"
 	"time"
+
+	"golang.org/x/crypto/curve25519"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/connlog"
 )
 
 // PeerConfig is the WireGuard configuration returned to the client.
@@ -25,14 +32,30 @@ type PeerConfig struct {
 	ClientAddress   string `json:"client_address"`  // e.g. "10.8.0.2/24"
 	DNS             string `json:"dns"`             // e.g. "1.1.1.1"
 	AllowedIPs      string `json:"allowed_ips"`     // e.g. "0.0.0.0/0, ::/0"
+
+	// AlternateEndpoints are further ways to reach the server, e.g. its IPv6
+	// address when ServerEndpoint is IPv4. Clients fall back to them when
+	// they cannot reach ServerEndpoint.
+	AlternateEndpoints []string `json:"alternate_endpoints,omitempty"`
+
+	// PersistentKeepalive is the keepalive interval (seconds) suggested to
+	// clients; 0 leaves it to the client.
+	PersistentKeepalive int `json:"persistent_keepalive,omitempty"`
+
+	// DNSPolicy is what the operator asserts about the DNS resolver; nil
+	// when they advertise nothing.
+	DNSPolicy *DNSPolicy `json:"dns_policy,omitempty"`
 }
 
 // Peer tracks an active WireGuard peer.
 type Peer struct {
 	PublicKey     string
+	Interface     string // interface the peer is configured on
 	ClientIP      string
+	Tier          string // access tier the peer was added for, e.g. "free"
 	AssignedAt    time.Time
 	ExpiresAt     time.Time
+	LastHandshake time.Time
 	BytesReceived uint64
 	BytesSent     uint64
 }
@@ -41,68 +64,358 @@ type Peer struct {
 type Config struct {
 	Interface       string // WireGuard interface name (e.g. "wg0")
 	ServerPublicKey string // Server's WG public key
-	ServerEndpoint  string // Public endpoint (e.g. "vpn.example.com:51820")
+	ServerEndpoint  string // Preferred public endpoint (e.g. "vpn.example.com:51820")
 	Subnet          string // Client IP subnet (e.g. "10.8.0.0/24")
 	DNS             string // DNS server for clients
+	Backend         string // "wg" (default, shells out) or "netlink" (wgctrl)
+
+	// AlternateEndpoints are advertised after ServerEndpoint, typically the
+	// other address family of a dual-stack node (e.g. "[2001:db8::1]:51820").
+	AlternateEndpoints []string
+
+	// PersistentKeepalive is suggested to clients in PeerConfig. Lower it
+	// when the gateway sits behind a NAT with short mapping timeouts.
+	PersistentKeepalive int
+
+	// DNSPolicy is advertised to clients with their peer config and in
+	// GET /version; nil advertises nothing.
+	DNSPolicy *DNSPolicy
+
+	// ExtraInterfaces are further interfaces, each with its own subnet, that
+	// new peers are spread over together with Interface. A peer stays on the
+	// interface it was added to until it is removed.
+	ExtraInterfaces []Interface
+
+	// Placement picks the interface for a new peer: PlacementLeastLoaded
+	// (default) or PlacementRoundRobin.
+	Placement string
+
+	// OnPeerAdd and OnPeerRemove are scripts run after a peer is added or
+	// removed, e.g. to update a firewall, with the peer's public key, client
+	// IP and tier as arguments. They run in the background, one at a time,
+	// for at most HookTimeout (default DefaultHookTimeout); a failing
+	// script is logged and does not affect the peer.
+	OnPeerAdd    string
+	OnPeerRemove string
+	HookTimeout  time.Duration
+}
+
+// Interface is one WireGuard interface peers can be placed on. Each listens
+// on its own port, so clients are sent its endpoint rather than the
+// primary one.
+type Interface struct {
+	Name               string   // e.g. "wg1"
+	Subnet             string   // e.g. "10.9.0.0/24"; must differ from every other interface's
+	ServerPublicKey    string   // empty uses Config.ServerPublicKey
+	ServerEndpoint     string   // e.g. "vpn.example.com:51821"
+	AlternateEndpoints []string // as Config.AlternateEndpoints
+}
+
+// Placement policies accepted in Config.Placement.
+const (
+	// PlacementLeastLoaded puts a new peer on the interface with the most
+	// free addresses.
+	PlacementLeastLoaded = "least_loaded"
+
+	// PlacementRoundRobin cycles through the interfaces, skipping full ones.
+	PlacementRoundRobin = "round_robin"
+)
+
+// PeerManager is the peer lifecycle the gateway server drives. Manager,
+// configured with one of the Backends, is the implementation the gateway
+// ships; tests and alternative deployments can supply their own.
+type PeerManager interface {
+	AddPeer(clientPubKey, tier string, ttl time.Duration) (*PeerConfig, error)
+	RemovePeer(clientPubKey string) error
+	RekeyPeer(oldPubKey, newPubKey string) (*PeerConfig, error)
+	GetPeer(clientPubKey string) *Peer
+	Peers() []Peer
+	PeerCount() int
+	CleanExpired() int
+	StartCleanupWorker(interval time.Duration)
+	DNSPolicy() *DNSPolicy
+	ProvisionsTunnels() bool
+	InterfaceExists() error
+	SetClock(c clock.Clock)
 }
 
+var _ PeerManager = (*Manager)(nil)
+
 // Manager handles WireGuard peer lifecycle.
 type Manager struct {
-	cfg    Config
-	mu     sync.Mutex
-	peers  map[string]*Peer // keyed by client public key
-	ipPool *ipPool
+	cfg     Config
+	backend Backend
+	mu      sync.Mutex
+	peers   map[string]*Peer // keyed by client public key
+	ifaces  []*wgInterface   // Config.Interface first
+	next    int              // next interface to try under PlacementRoundRobin
+	clock   clock.Clock      // nil means the wall clock
+	hooks   *hookRunner      // nil when no peer hooks are configured
+}
+
+// wgInterface is a managed interface and its address pool.
+type wgInterface struct {
+	Interface
+	pool *ipPool
 }
 
 // NewManager creates a WireGuard peer manager.
 func NewManager(cfg Config) (*Manager, error) {
-	pool, err := newIPPool(cfg.Subnet)
+	switch cfg.Placement {
+	case "", PlacementLeastLoaded, PlacementRoundRobin:
+	default:
+		return nil, fmt.Errorf("unknown peer placement %q (want %q or %q)", cfg.Placement, PlacementLeastLoaded, PlacementRoundRobin)
+	}
+
+	ifaces, err := newInterfaces(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("initializing IP pool: %w", err)
+		return nil, err
+	}
+
+	backend, err := newBackend(cfg.Backend)
+	if err != nil {
+		return nil, err
+	}
+	hooks, err := newHookRunner(cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Manager{
-		cfg:    cfg,
-		peers:  make(map[string]*Peer),
-		ipPool: pool,
+		cfg:     cfg,
+		backend: backend,
+		peers:   make(map[string]*Peer),
+		ifaces:  ifaces,
+		hooks:   hooks,
 	}, nil
 }
 
-// AddPeer registers a new WireGuard peer and returns the client configuration.
-func (m *Manager) AddPeer(clientPubKey string, ttl time.Duration) (*PeerConfig, error) {
+// newInterfaces builds the primary interface from cfg followed by
+// cfg.ExtraInterfaces, each with its own address pool.
+func newInterfaces(cfg Config) ([]*wgInterface, error) {
+	all := append([]Interface{{
+		Name:               cfg.Interface,
+		Subnet:             cfg.Subnet,
+		ServerPublicKey:    cfg.ServerPublicKey,
+		ServerEndpoint:     cfg.ServerEndpoint,
+		AlternateEndpoints: cfg.AlternateEndpoints,
+	}}, cfg.ExtraInterfaces...)
+
+	ifaces := make([]*wgInterface, 0, len(all))
+	names := make(map[string]bool)
+	subnets := make(map[string]string)
+	for i, ifc := range all {
+		pool, err := newIPPool(ifc.Subnet)
+		if err != nil {
+			return nil, fmt.Errorf("initializing IP pool: %w", err)
+		}
+		if i > 0 {
+			if ifc.Name == "" || ifc.ServerEndpoint == "" {
+				return nil, fmt.Errorf("extra interface %d needs a name and an endpoint", i)
+			}
+			if ifc.ServerPublicKey == "" {
+				ifc.ServerPublicKey = cfg.ServerPublicKey
+			}
+		}
+		if names[ifc.Name] {
+			return nil, fmt.Errorf("interface %s listed twice", ifc.Name)
+		}
+		names[ifc.Name] = true
+		prefix := pool.baseIP.Mask(net.CIDRMask(24, 32)).String()
+		if other, ok := subnets[prefix]; ok {
+			return nil, fmt.Errorf("interfaces %s and %s share subnet %s", other, ifc.Name, ifc.Subnet)
+		}
+		subnets[prefix] = ifc.Name
+		ifaces = append(ifaces, &wgInterface{Interface: ifc, pool: pool})
+	}
+	return ifaces, nil
+}
+
+// SetClock replaces the clock used to stamp and expire peers.
+func (m *Manager) SetClock(c clock.Clock) {
+	m.mu.Lock()
+	m.clock = c
+	m.mu.Unlock()
+}
+
+// now reads the clock. Called with m.mu held.
+func (m *Manager) now() time.Time {
+	if m.clock == nil {
+		return time.Now()
+	}
+	return m.clock.Now()
+}
+
+// ParseEndpoints splits a comma-separated endpoint list, preferred first, as
+// given to --wg-endpoint (e.g. "203.0.113.10:51820,[2001:db8::1]:51820").
+// Every entry must be host:port.
+func ParseEndpoints(list string) (preferred string, alternates []string, err error) {
+	for _, ep := range strings.Split(list, ",") {
+		ep = strings.TrimSpace(ep)
+		if ep == "" {
+			continue
+		}
+		if _, port, err := net.SplitHostPort(ep); err != nil || port == "" {
+			return "", nil, fmt.Errorf("endpoint %q: want host:port (IPv6 as [addr]:port)", ep)
+		}
+		if preferred == "" {
+			preferred = ep
+		} else {
+			alternates = append(alternates, ep)
+		}
+	}
+	return preferred, alternates, nil
+}
+
+// ParseInterfaces parses the extra interfaces given to --wg-extra-interfaces:
+// entries separated by ";", each "name,subnet,pubkey,endpoint[,endpoint...]"
+// with endpoints as in ParseEndpoints. An empty pubkey uses the primary
+// interface's.
+func ParseInterfaces(list string) ([]Interface, error) {
+	var ifaces []Interface
+	for _, entry := range strings.Split(list, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		fields := strings.SplitN(entry, ",", 4)
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("interface %q: want name,subnet,pubkey,endpoint", entry)
+		}
+		preferred, alternates, err := ParseEndpoints(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("interface %q: %w", entry, err)
+		}
+		if preferred == "" {
+			return nil, fmt.Errorf("interface %q: no endpoint", entry)
+		}
+		ifaces = append(ifaces, Interface{
+			Name:               strings.TrimSpace(fields[0]),
+			Subnet:             strings.TrimSpace(fields[1]),
+			ServerPublicKey:    strings.TrimSpace(fields[2]),
+			ServerEndpoint:     preferred,
+			AlternateEndpoints: alternates,
+		})
+	}
+	return ifaces, nil
+}
+
+// AddPeer registers a new WireGuard peer for an access tier and returns the
+// client configuration.
+// Re-adding a key that is already a peer (a credential renewal) keeps its
+// address, AssignedAt and counters and only extends its expiry; the kernel
+// likewise keeps transfer counters for a peer reconfigured in place. A
+// non-positive ttl is rejected rather than creating an already-expired peer.
+func (m *Manager) AddPeer(clientPubKey, tier string, ttl time.Duration) (*PeerConfig, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("peer TTL must be positive, got %s", ttl)
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Allocate a client IP
-	clientIP, err := m.ipPool.Allocate()
+	if peer, ok := m.peers[clientPubKey]; ok {
+		ifc := m.iface(peer.Interface)
+		if err := m.backend.SetPeer(ifc.Name, clientPubKey, peer.ClientIP); err != nil {
+			return nil, fmt.Errorf("renewing WireGuard peer: %w", err)
+		}
+		peer.ExpiresAt = m.now().Add(ttl)
+		peer.Tier = tier
+		connlog.Printf("[wireguard] Peer renewed (expires %s)", peer.ExpiresAt.Format(time.RFC3339))
+		return m.peerConfig(ifc, peer.ClientIP), nil
+	}
+
+	// Allocate a client IP on the interface the placement policy picks
+	ifc := m.place()
+	if ifc == nil {
+		return nil, fmt.Errorf("no available IPs: IP pool exhausted")
+	}
+	clientIP, err := ifc.pool.Allocate()
 	if err != nil {
 		return nil, fmt.Errorf("no available IPs: %w", err)
 	}
 
 	// Add peer to WireGuard interface
-	if err := m.wgSetPeer(clientPubKey, clientIP); err != nil {
-		m.ipPool.Release(clientIP)
+	if err := m.backend.SetPeer(ifc.Name, clientPubKey, clientIP); err != nil {
+		ifc.pool.Release(clientIP)
 		return nil, fmt.Errorf("adding WireGuard peer: %w", err)
 	}
 
-	now := time.Now()
-	m.peers[clientPubKey] = &Peer{
+	now := m.now()
+	peer := &Peer{
 		PublicKey:  clientPubKey,
+		Interface:  ifc.Name,
 		ClientIP:   clientIP,
+		Tier:       tier,
 		AssignedAt: now,
 		ExpiresAt:  now.Add(ttl),
 	}
+	m.peers[clientPubKey] = peer
+	m.hooks.added(*peer)
 
-	log.Printf("[wireguard] Peer added (expires %s)",
+	connlog.Printf("[wireguard] Peer added (expires %s)",
 		now.Add(ttl).Format(time.RFC3339))
 
+	return m.peerConfig(ifc, clientIP), nil
+}
+
+// ProvisionsTunnels reports whether the manager can add peers, i.e. it is
+// not using BackendNone.
+func (m *Manager) ProvisionsTunnels() bool {
+	_, none := m.backend.(noneBackend)
+	return !none
+}
+
+// place picks the interface for a new peer, or nil if every pool is full.
+// Called with m.mu held.
+func (m *Manager) place() *wgInterface {
+	if m.cfg.Placement == PlacementRoundRobin {
+		for i := range m.ifaces {
+			ifc := m.ifaces[(m.next+i)%len(m.ifaces)]
+			if ifc.pool.Available() > 0 {
+				m.next = (m.next + i + 1) % len(m.ifaces)
+				return ifc
+			}
+		}
+		return nil
+	}
+
+	var best *wgInterface
+	for _, ifc := range m.ifaces {
+		if free := ifc.pool.Available(); free > 0 && (best == nil || free > best.pool.Available()) {
+			best = ifc
+		}
+	}
+	return best
+}
+
+// iface returns the managed interface called name; a peer with no
+// interface recorded is on the primary one. Called with m.mu held.
+func (m *Manager) iface(name string) *wgInterface {
+	for _, ifc := range m.ifaces {
+		if ifc.Name == name {
+			return ifc
+		}
+	}
+	return m.ifaces[0]
+}
+
+func (m *Manager) peerConfig(ifc *wgInterface, clientIP string) *PeerConfig {
 	return &PeerConfig{
-		ServerPublicKey: m.cfg.ServerPublicKey,
-		ServerEndpoint:  m.cfg.ServerEndpoint,
+		ServerPublicKey: ifc.ServerPublicKey,
+		ServerEndpoint:  ifc.ServerEndpoint,
 		ClientAddress:   clientIP + "/24",
 		DNS:             m.cfg.DNS,
 		AllowedIPs:      "0.0.0.0/0, ::/0",
-	}, nil
+
+		AlternateEndpoints:  ifc.AlternateEndpoints,
+		PersistentKeepalive: m.cfg.PersistentKeepalive,
+		DNSPolicy:           m.cfg.DNSPolicy,
+	}
+}
+
+// DNSPolicy returns the DNS policy the node advertises, or nil.
+func (m *Manager) DNSPolicy() *DNSPolicy {
+	return m.cfg.DNSPolicy
 }
 
 // RemovePeer removes a WireGuard peer.
@@ -115,31 +428,81 @@ func (m *Manager) RemovePeer(clientPubKey string) error {
 		return fmt.Errorf("peer not found: %s", truncateKey(clientPubKey))
 	}
 
-	if err := m.wgRemovePeer(clientPubKey); err != nil {
+	ifc := m.iface(peer.Interface)
+	if err := m.backend.RemovePeer(ifc.Name, clientPubKey); err != nil {
 		return fmt.Errorf("removing WireGuard peer: %w", err)
 	}
 
-	m.ipPool.Release(peer.ClientIP)
+	ifc.pool.Release(peer.ClientIP)
 	delete(m.peers, clientPubKey)
+	m.hooks.removed(*peer)
 
-	log.Printf("[wireguard] Peer removed")
+	connlog.Printf("[wireguard] Peer removed")
 	return nil
 }
 
+// RekeyPeer moves the peer oldPubKey to newPubKey, keeping its address,
+// AssignedAt and expiry, and returns the client configuration. Counters and
+// handshake start over, as the kernel's do for a new peer. If the new key
+// cannot be added the old peer is restored.
+func (m *Manager) RekeyPeer(oldPubKey, newPubKey string) (*PeerConfig, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	peer, exists := m.peers[oldPubKey]
+	if !exists {
+		return nil, fmt.Errorf("peer not found: %s", truncateKey(oldPubKey))
+	}
+	if _, taken := m.peers[newPubKey]; taken {
+		return nil, fmt.Errorf("peer already exists: %s", truncateKey(newPubKey))
+	}
+
+	// The address can only be routed to one peer, so the old key has to go
+	// before the new one is added.
+	ifc := m.iface(peer.Interface)
+	if err := m.backend.RemovePeer(ifc.Name, oldPubKey); err != nil {
+		return nil, fmt.Errorf("removing WireGuard peer: %w", err)
+	}
+	if err := m.backend.SetPeer(ifc.Name, newPubKey, peer.ClientIP); err != nil {
+		if rerr := m.backend.SetPeer(ifc.Name, oldPubKey, peer.ClientIP); rerr != nil {
+			log.Printf("[wireguard] Restoring peer after failed rekey: %v", rerr)
+		}
+		return nil, fmt.Errorf("adding WireGuard peer: %w", err)
+	}
+
+	delete(m.peers, oldPubKey)
+	rekeyed := &Peer{
+		PublicKey:  newPubKey,
+		Interface:  ifc.Name,
+		ClientIP:   peer.ClientIP,
+		Tier:       peer.Tier,
+		AssignedAt: peer.AssignedAt,
+		ExpiresAt:  peer.ExpiresAt,
+	}
+	m.peers[newPubKey] = rekeyed
+	m.hooks.removed(*peer)
+	m.hooks.added(*rekeyed)
+
+	connlog.Printf("[wireguard] Peer rekeyed (expires %s)", peer.ExpiresAt.Format(time.RFC3339))
+	return m.peerConfig(ifc, peer.ClientIP), nil
+}
+
 // CleanExpired removes all peers whose credentials have expired.
 func (m *Manager) CleanExpired() int {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	now := time.Now()
+	now := m.now()
 	removed := 0
 	for pubKey, peer := range m.peers {
 		if now.After(peer.ExpiresAt) {
-			_ = m.wgRemovePeer(pubKey)
-			m.ipPool.Release(peer.ClientIP)
+			ifc := m.iface(peer.Interface)
+			_ = m.backend.RemovePeer(ifc.Name, pubKey)
+			ifc.pool.Release(peer.ClientIP)
 			delete(m.peers, pubKey)
+			m.hooks.removed(*peer)
 			removed++
-			log.Printf("[wireguard] Expired peer removed")
+			connlog.Printf("[wireguard] Expired peer removed")
 		}
 	}
 	return removed
@@ -152,6 +515,25 @@ func (m *Manager) PeerCount() int {
 	return len(m.peers)
 }
 
+// AvailableIPs returns how many client addresses are free across all
+// interfaces.
+func (m *Manager) AvailableIPs() int {
+	n := 0
+	for _, ifc := range m.ifaces {
+		n += ifc.pool.Available()
+	}
+	return n
+}
+
+// Interfaces returns the names of the managed interfaces, primary first.
+func (m *Manager) Interfaces() []string {
+	names := make([]string, len(m.ifaces))
+	for i, ifc := range m.ifaces {
+		names[i] = ifc.Name
+	}
+	return names
+}
+
 // GetPeer returns peer info by public key.
 func (m *Manager) GetPeer(clientPubKey string) *Peer {
 	m.mu.Lock()
@@ -159,7 +541,43 @@ func (m *Manager) GetPeer(clientPubKey string) *Peer {
 	return m.peers[clientPubKey]
 }
 
-// StartCleanupWorker starts a background goroutine that removes expired peers.
+// Peers returns a snapshot of all tracked peers.
+func (m *Manager) Peers() []Peer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	peers := make([]Peer, 0, len(m.peers))
+	for _, p := range m.peers {
+		peers = append(peers, *p)
+	}
+	return peers
+}
+
+// RefreshStats updates handshake and transfer counters on tracked peers from
+// the interfaces.
+func (m *Manager) RefreshStats() error {
+	stats := make(map[string]PeerStats)
+	for _, ifc := range m.ifaces {
+		st, err := m.backend.PeerStats(ifc.Name)
+		if err != nil {
+			return fmt.Errorf("reading peer stats on %s: %w", ifc.Name, err)
+		}
+		maps.Copy(stats, st)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for pubKey, peer := range m.peers {
+		if st, ok := stats[pubKey]; ok {
+			peer.LastHandshake = st.LastHandshake
+			peer.BytesReceived = st.BytesReceived
+			peer.BytesSent = st.BytesSent
+		}
+	}
+	return nil
+}
+
+// StartCleanupWorker starts a background goroutine that removes expired peers
+// and refreshes peer stats.
 func (m *Manager) StartCleanupWorker(interval time.Duration) {
 	go func() {
 		ticker := time.NewTicker(interval)
@@ -168,59 +586,100 @@ func (m *Manager) StartCleanupWorker(interval time.Duration) {
 			if n := m.CleanExpired(); n > 0 {
 				log.Printf("[wireguard] Cleaned %d expired peers", n)
 			}
+			if err := m.RefreshStats(); err != nil {
+				log.Printf("[wireguard] %v", err)
+			}
 		}
 	}()
 }
 
-// --- WireGuard commands ---
+// InterfaceExists reports whether the configured interfaces are present.
+// It returns nil if they all exist.
+func (m *Manager) InterfaceExists() error {
+	for _, ifc := range m.ifaces {
+		if _, err := m.backend.PrivateKey(ifc.Name); err != nil {
+			return fmt.Errorf("interface %s not available: %w", ifc.Name, err)
+		}
+	}
+	return nil
+}
 
-func (m *Manager) wgSetPeer(pubKey, clientIP string) error {
-	// wg set wg0 peer <pubkey> allowed-ips <clientIP>/32
-	cmd := exec.Command("wg", "set", m.cfg.Interface,
-		"peer", pubKey,
-		"allowed-ips", clientIP+"/32",
-	)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("%s: %w", strings.TrimSpace(string(output)), err)
+// Verify checks that every configured interface exists, has a private key,
+// and is up. Call it at startup so a missing or half-configured interface is
+// a clear boot-time error instead of a failed `wg set` on the first connect.
+func (m *Manager) Verify() error {
+	for _, ifc := range m.ifaces {
+		if err := m.verifyInterface(ifc.Name); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (m *Manager) wgRemovePeer(pubKey string) error {
-	// wg set wg0 peer <pubkey> remove
-	cmd := exec.Command("wg", "set", m.cfg.Interface,
-		"peer", pubKey, "remove",
-	)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("%s: %w", strings.TrimSpace(string(output)), err)
+func (m *Manager) verifyInterface(iface string) error {
+	key, err := m.backend.PrivateKey(iface)
+	if err != nil {
+		return fmt.Errorf("WireGuard interface %q not found (%v): create it with "+
+			"`ip link add %s type wireguard` or `wg-quick up %s`",
+			iface, err, iface, iface)
+	}
+	if key == "" {
+		return fmt.Errorf("WireGuard interface %q has no private key: run "+
+			"`wg genkey > /etc/wireguard/private.key && wg set %s private-key /etc/wireguard/private.key`",
+			iface, iface)
 	}
+
+	link, err := interfaceByName(iface)
+	if err != nil {
+		return fmt.Errorf("looking up interface %q: %w", iface, err)
+	}
+	if link.Flags&net.FlagUp == 0 {
+		return fmt.Errorf("WireGuard interface %q is down: run `ip link set %s up`", iface, iface)
+	}
+
 	return nil
 }
 
+// interfaceByName is net.InterfaceByName; tests replace it.
+var interfaceByName = net.InterfaceByName
+
 // GenerateKeyPair generates a WireGuard keypair (for testing).
 func GenerateKeyPair() (privateKey, publicKey string, err error) {
 	// Generate 32 random bytes for private key
-	key := make([]byte, 32)
-	if _, err := rand.Read(key); err != nil {
+	var key [32]byte
+	if _, err := rand.Read(key[:]); err != nil {
 		return "", "", err
 	}
 	// Clamp the private key per Curve25519
 	key[0] &= 248
 	key[31] &= 127
 	key[31] |= 64
-	privateKey = base64.StdEncoding.EncodeToString(key)
+	privateKey = base64.StdEncoding.EncodeToString(key[:])
 
-	// Derive public key using wg pubkey
-	cmd := exec.Command("wg", "pubkey")
-	cmd.Stdin = strings.NewReader(privateKey)
-	out, err := cmd.Output()
+	publicKey, err = PublicKey(privateKey)
 	if err != nil {
-		return "", "", fmt.Errorf("deriving public key: %w", err)
+		return "", "", err
 	}
-	publicKey = strings.TrimSpace(string(out))
 	return privateKey, publicKey, nil
 }
 
+// PublicKey derives the base64 WireGuard public key for a base64 private key,
+// matching `wg pubkey`.
+func PublicKey(privateKey string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("decoding private key: %w", err)
+	}
+	if len(raw) != 32 {
+		return "", fmt.Errorf("private key must be 32 bytes, got %d", len(raw))
+	}
+
+	var priv, pub [32]byte
+	copy(priv[:], raw)
+	curve25519.ScalarBaseMult(&pub, &priv)
+	return base64.StdEncoding.EncodeToString(pub[:]), nil
+}
+
 func truncateKey(key string) string {
 	if len(key) > 8 {
 		return key[:8] + "..."
@@ -268,6 +727,13 @@ func (p *ipPool) Allocate() (string, error) {
 	return "", fmt.Errorf("IP pool exhausted")
 }
 
+// Available returns the number of unallocated client addresses.
+func (p *ipPool) Available() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return 253 - len(p.allocated)
+}
+
 func (p *ipPool) Release(ip string) {
 	p.mu.Lock()
 	delete(p.allocated, ip)
@@ -1,8 +1,14 @@
 package wireguard
 
 import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
 )
 
 // These tests exercise the IP pool and in-memory peer tracking.
@@ -54,6 +60,26 @@ func TestIPPoolRelease(t *testing.T) {
 	_ = ip2 // used
 }
 
+func TestIPPoolAvailable(t *testing.T) {
+	pool, err := newIPPool("10.8.0.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := pool.Available(); n != 253 {
+		t.Fatalf("expected 253 free addresses, got %d", n)
+	}
+
+	ip, _ := pool.Allocate()
+	if n := pool.Available(); n != 252 {
+		t.Fatalf("expected 252 free addresses after allocate, got %d", n)
+	}
+
+	pool.Release(ip)
+	if n := pool.Available(); n != 253 {
+		t.Fatalf("expected 253 free addresses after release, got %d", n)
+	}
+}
+
 func TestIPPoolExhaustion(t *testing.T) {
 	pool, err := newIPPool("10.8.0.0/24")
 	if err != nil {
@@ -158,9 +184,9 @@ func TestPeerTracking(t *testing.T) {
 func TestCleanExpired(t *testing.T) {
 	pool, _ := newIPPool("10.8.0.0/24")
 	m := &Manager{
-		peers:  make(map[string]*Peer),
-		ipPool: pool,
-		cfg:    Config{Interface: "wg-test"},
+		peers:   make(map[string]*Peer),
+		ifaces:  []*wgInterface{{Interface: Interface{Name: "wg-test"}, pool: pool}},
+		backend: shellBackend{},
 	}
 
 	now := time.Now()
@@ -237,6 +263,144 @@ func TestNewManagerValidConfig(t *testing.T) {
 	}
 }
 
+func TestParseEndpoints(t *testing.T) {
+	preferred, alternates, err := ParseEndpoints("203.0.113.10:51820, [2001:db8::1]:51820,vpn.example.com:51820")
+	if err != nil {
+		t.Fatalf("ParseEndpoints: %v", err)
+	}
+	if preferred != "203.0.113.10:51820" {
+		t.Errorf("preferred = %q", preferred)
+	}
+	if want := []string{"[2001:db8::1]:51820", "vpn.example.com:51820"}; !reflect.DeepEqual(alternates, want) {
+		t.Errorf("alternates = %v, want %v", alternates, want)
+	}
+
+	for _, bad := range []string{"2001:db8::1:51820", "vpn.example.com", "1.2.3.4:51820,nope"} {
+		if _, _, err := ParseEndpoints(bad); err == nil {
+			t.Errorf("ParseEndpoints(%q): expected error", bad)
+		}
+	}
+}
+
+func TestPeerConfigAdvertisesAlternates(t *testing.T) {
+	m, err := NewManager(Config{
+		Interface:          "wg0",
+		Subnet:             "10.8.0.0/24",
+		ServerEndpoint:     "203.0.113.10:51820",
+		AlternateEndpoints: []string{"[2001:db8::1]:51820"},
+	})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	pc := m.peerConfig(m.ifaces[0], "10.8.0.2")
+	if pc.ServerEndpoint != "203.0.113.10:51820" || !reflect.DeepEqual(pc.AlternateEndpoints, []string{"[2001:db8::1]:51820"}) {
+		t.Errorf("peer config endpoints = %q + %v", pc.ServerEndpoint, pc.AlternateEndpoints)
+	}
+}
+
+func TestParseInterfaces(t *testing.T) {
+	got, err := ParseInterfaces("wg1,10.9.0.0/24,,203.0.113.10:51821,[2001:db8::1]:51821; wg2,10.10.0.0/24,key2=,203.0.113.10:51822")
+	if err != nil {
+		t.Fatalf("ParseInterfaces: %v", err)
+	}
+	want := []Interface{
+		{Name: "wg1", Subnet: "10.9.0.0/24", ServerEndpoint: "203.0.113.10:51821", AlternateEndpoints: []string{"[2001:db8::1]:51821"}},
+		{Name: "wg2", Subnet: "10.10.0.0/24", ServerPublicKey: "key2=", ServerEndpoint: "203.0.113.10:51822"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseInterfaces = %+v, want %+v", got, want)
+	}
+
+	for _, bad := range []string{"wg1,10.9.0.0/24", "wg1,10.9.0.0/24,,", "wg1,10.9.0.0/24,,no-port"} {
+		if _, err := ParseInterfaces(bad); err == nil {
+			t.Errorf("ParseInterfaces(%q): expected error", bad)
+		}
+	}
+}
+
+func TestNewManagerRejectsBadInterfaces(t *testing.T) {
+	base := Config{Interface: "wg0", Subnet: "10.8.0.0/24"}
+	tests := map[string][]Interface{
+		"no endpoint":    {{Name: "wg1", Subnet: "10.9.0.0/24"}},
+		"duplicate name": {{Name: "wg0", Subnet: "10.9.0.0/24", ServerEndpoint: "h:51821"}},
+		"shared subnet":  {{Name: "wg1", Subnet: "10.8.0.0/24", ServerEndpoint: "h:51821"}},
+		"bad subnet":     {{Name: "wg1", Subnet: "nope", ServerEndpoint: "h:51821"}},
+	}
+	for name, extra := range tests {
+		cfg := base
+		cfg.ExtraInterfaces = extra
+		if _, err := NewManager(cfg); err == nil {
+			t.Errorf("%s: NewManager succeeded", name)
+		}
+	}
+	if _, err := NewManager(Config{Interface: "wg0", Subnet: "10.8.0.0/24", Placement: "random"}); err == nil {
+		t.Error("NewManager accepted an unknown placement")
+	}
+}
+
+func TestAddPeerSpreadsAcrossInterfaces(t *testing.T) {
+	newManager := func(placement string) *Manager {
+		t.Helper()
+		m, err := NewManager(Config{
+			Interface:       "wg0",
+			Subnet:          "10.8.0.0/24",
+			ServerPublicKey: "primary=",
+			ServerEndpoint:  "203.0.113.10:51820",
+			ExtraInterfaces: []Interface{
+				{Name: "wg1", Subnet: "10.9.0.0/24", ServerEndpoint: "203.0.113.10:51821"},
+			},
+			Placement: placement,
+		})
+		if err != nil {
+			t.Fatalf("NewManager: %v", err)
+		}
+		m.backend = &countingBackend{}
+		return m
+	}
+
+	m := newManager(PlacementRoundRobin)
+	for _, key := range []string{"a", "b", "c"} {
+		if _, err := m.AddPeer(key, "free", time.Hour); err != nil {
+			t.Fatalf("AddPeer %s: %v", key, err)
+		}
+	}
+	if got := []string{m.GetPeer("a").Interface, m.GetPeer("b").Interface, m.GetPeer("c").Interface}; !reflect.DeepEqual(got, []string{"wg0", "wg1", "wg0"}) {
+		t.Errorf("round robin placed peers on %v", got)
+	}
+
+	m = newManager(PlacementLeastLoaded)
+	if _, err := m.AddPeer("a", "free", time.Hour); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	if _, err := m.AddPeer("b", "free", time.Hour); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	if err := m.RemovePeer("a"); err != nil {
+		t.Fatalf("RemovePeer: %v", err)
+	}
+	cfg, err := m.AddPeer("c", "free", time.Hour)
+	if err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	// wg0 emptied out, so it is the least loaded again.
+	if m.GetPeer("c").Interface != "wg0" || cfg.ServerEndpoint != "203.0.113.10:51820" {
+		t.Errorf("least loaded placed c on %s (%s)", m.GetPeer("c").Interface, cfg.ServerEndpoint)
+	}
+
+	// A renewal stays on its interface and gets that interface's endpoint;
+	// the extra interface inherits the primary public key.
+	renewed, err := m.AddPeer("b", "free", time.Hour)
+	if err != nil {
+		t.Fatalf("AddPeer renew: %v", err)
+	}
+	if renewed.ServerEndpoint != "203.0.113.10:51821" || renewed.ServerPublicKey != "primary=" || !strings.HasPrefix(renewed.ClientAddress, "10.9.0.") {
+		t.Errorf("renewed config = %+v", renewed)
+	}
+	if n := m.AvailableIPs(); n != 2*253-2 {
+		t.Errorf("AvailableIPs = %d, want %d", n, 2*253-2)
+	}
+}
+
 func TestNewManagerInvalidSubnet(t *testing.T) {
 	_, err := NewManager(Config{
 		Subnet: "invalid",
@@ -245,3 +409,414 @@ func TestNewManagerInvalidSubnet(t *testing.T) {
 		t.Error("expected error for invalid subnet")
 	}
 }
+
+func TestInterfaceExists(t *testing.T) {
+	orig := runWG
+	defer func() { runWG = orig }()
+
+	m, err := NewManager(Config{Interface: "wg0", Subnet: "10.8.0.0/24"})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	var gotArgs []string
+	runWG = func(args ...string) ([]byte, error) {
+		gotArgs = args
+		return []byte("interface: wg0\n"), nil
+	}
+	if err := m.InterfaceExists(); err != nil {
+		t.Fatalf("InterfaceExists: %v", err)
+	}
+	if len(gotArgs) < 2 || gotArgs[0] != "show" || gotArgs[1] != "wg0" {
+		t.Errorf("unexpected wg args: %v", gotArgs)
+	}
+
+	runWG = func(args ...string) ([]byte, error) {
+		return []byte("Unable to access interface: No such device"), errors.New("exit status 1")
+	}
+	if err := m.InterfaceExists(); err == nil {
+		t.Fatal("expected error for missing interface")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	origWG, origIface := runWG, interfaceByName
+	defer func() { runWG, interfaceByName = origWG, origIface }()
+
+	m, err := NewManager(Config{Interface: "wg0", Subnet: "10.8.0.0/24"})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		output  string
+		wgErr   error
+		flags   net.Flags
+		wantErr string
+	}{
+		{"ok", "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=\n", nil, net.FlagUp, ""},
+		{"missing interface", "Unable to access interface: No such device", errors.New("exit status 1"), 0, "not found"},
+		{"no private key", "(none)\n", nil, net.FlagUp, "no private key"},
+		{"interface down", "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=\n", nil, 0, "is down"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runWG = func(args ...string) ([]byte, error) { return []byte(tt.output), tt.wgErr }
+			interfaceByName = func(name string) (*net.Interface, error) {
+				return &net.Interface{Name: name, Flags: tt.flags}, nil
+			}
+
+			err := m.Verify()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNewManagerBackendSelection(t *testing.T) {
+	m, err := NewManager(Config{Interface: "wg0", Subnet: "10.8.0.0/24"})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if _, ok := m.backend.(shellBackend); !ok {
+		t.Errorf("expected shell backend by default, got %T", m.backend)
+	}
+
+	if _, err := NewManager(Config{Interface: "wg0", Subnet: "10.8.0.0/24", Backend: "bogus"}); err == nil {
+		t.Error("expected error for unknown backend")
+	}
+}
+
+func TestMemoryBackendTracksPeers(t *testing.T) {
+	m, err := NewManager(Config{Interface: "wg0", Subnet: "10.8.0.0/24", Backend: BackendMemory})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	_, pub, _ := GenerateKeyPair()
+	if _, err := m.AddPeer(pub, "free", time.Hour); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	stats, err := m.backend.PeerStats("wg0")
+	if err != nil {
+		t.Fatalf("PeerStats: %v", err)
+	}
+	if _, ok := stats[pub]; !ok || len(stats) != 1 {
+		t.Errorf("stats = %v, want only the added peer", stats)
+	}
+	if err := m.RemovePeer(pub); err != nil {
+		t.Fatalf("RemovePeer: %v", err)
+	}
+	if stats, _ := m.backend.PeerStats("wg0"); len(stats) != 0 {
+		t.Errorf("stats after RemovePeer = %v, want none", stats)
+	}
+}
+
+func TestNoneBackendRefusesPeers(t *testing.T) {
+	m, err := NewManager(Config{Interface: "wg0", Subnet: "10.8.0.0/24", Backend: BackendNone})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if m.ProvisionsTunnels() {
+		t.Error("ProvisionsTunnels = true for the none backend")
+	}
+	if _, err := m.AddPeer("peer-key", "free", time.Hour); !errors.Is(err, ErrNoTunnels) {
+		t.Fatalf("AddPeer error = %v, want ErrNoTunnels", err)
+	}
+	if m.PeerCount() != 0 {
+		t.Errorf("PeerCount = %d, want 0", m.PeerCount())
+	}
+	if err := m.InterfaceExists(); err != nil {
+		t.Errorf("InterfaceExists: %v", err)
+	}
+}
+
+func TestParseDump(t *testing.T) {
+	dump := "cHJpdmF0ZQ==\tcHVibGlj\t51820\toff\n" +
+		"peerA=\t(none)\t1.2.3.4:5555\t10.8.0.2/32\t1700000000\t1024\t2048\toff\n" +
+		"peerB=\t(none)\t(none)\t10.8.0.3/32\t0\t0\t0\toff\n"
+
+	stats, err := parseDump(dump)
+	if err != nil {
+		t.Fatalf("parseDump: %v", err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 peers, got %d", len(stats))
+	}
+
+	a := stats["peerA="]
+	if a.BytesReceived != 1024 || a.BytesSent != 2048 {
+		t.Errorf("peerA transfer = %d/%d, want 1024/2048", a.BytesReceived, a.BytesSent)
+	}
+	if !a.LastHandshake.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("peerA handshake = %v", a.LastHandshake)
+	}
+	if !stats["peerB="].LastHandshake.IsZero() {
+		t.Error("peerB without handshake should have zero time")
+	}
+
+	if _, err := parseDump("iface\nshort\tline\n"); err == nil {
+		t.Error("expected error for malformed line")
+	}
+}
+
+func TestRefreshStats(t *testing.T) {
+	orig := runWG
+	defer func() { runWG = orig }()
+
+	pool, _ := newIPPool("10.8.0.0/24")
+	m := &Manager{
+		peers:   map[string]*Peer{"peerA=": {PublicKey: "peerA="}},
+		ifaces:  []*wgInterface{{Interface: Interface{Name: "wg0"}, pool: pool}},
+		backend: shellBackend{},
+	}
+
+	runWG = func(args ...string) ([]byte, error) {
+		return []byte("priv\tpub\t51820\toff\npeerA=\t(none)\t(none)\t10.8.0.2/32\t1700000000\t10\t20\toff\n"), nil
+	}
+	if err := m.RefreshStats(); err != nil {
+		t.Fatalf("RefreshStats: %v", err)
+	}
+
+	peer := m.GetPeer("peerA=")
+	if peer.BytesReceived != 10 || peer.BytesSent != 20 || peer.LastHandshake.IsZero() {
+		t.Errorf("stats not applied: %+v", peer)
+	}
+}
+
+func TestPublicKeyMatchesWgPubkey(t *testing.T) {
+	// RFC 7748 section 6.1 test vector; `echo <private> | wg pubkey` prints the
+	// same public key.
+	const (
+		private = "dwdtCnMYpX08FsFyUbJmRd9ML4frwJkqsXf7pR25LCo="
+		public  = "hSDwCYkwp1R0i33ctD73Wg2/Og0mOBr066SpjqqbTmo="
+	)
+
+	got, err := PublicKey(private)
+	if err != nil {
+		t.Fatalf("PublicKey: %v", err)
+	}
+	if got != public {
+		t.Errorf("PublicKey = %s, want %s", got, public)
+	}
+
+	if _, err := PublicKey("not-base64!"); err == nil {
+		t.Error("expected error for invalid base64")
+	}
+	if _, err := PublicKey("c2hvcnQ="); err == nil {
+		t.Error("expected error for short key")
+	}
+}
+
+func TestGenerateKeyPair(t *testing.T) {
+	priv, pub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair: %v", err)
+	}
+	derived, err := PublicKey(priv)
+	if err != nil {
+		t.Fatalf("PublicKey: %v", err)
+	}
+	if derived != pub {
+		t.Errorf("public key mismatch: %s != %s", derived, pub)
+	}
+}
+
+// countingBackend accepts every change except setting failKey, counts
+// SetPeer calls and records removed keys.
+type countingBackend struct {
+	shellBackend
+	sets    int
+	removed []string
+	failKey string
+}
+
+func (b *countingBackend) SetPeer(_, pubKey, _ string) error {
+	if pubKey == b.failKey {
+		return errors.New("wg set failed")
+	}
+	b.sets++
+	return nil
+}
+
+func (b *countingBackend) RemovePeer(_, pubKey string) error {
+	b.removed = append(b.removed, pubKey)
+	return nil
+}
+
+func TestAddPeerRenewKeepsAddressAndCounters(t *testing.T) {
+	pool, _ := newIPPool("10.8.0.0/24")
+	backend := &countingBackend{}
+	clk := clock.NewFake(time.Now())
+	m := &Manager{
+		peers:   make(map[string]*Peer),
+		ifaces:  []*wgInterface{{Interface: Interface{Name: "wg-test"}, pool: pool}},
+		backend: backend,
+		clock:   clk,
+	}
+
+	first, err := m.AddPeer("renew-key", "free", time.Minute)
+	if err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	peer := m.GetPeer("renew-key")
+	assignedAt := peer.AssignedAt
+	peer.BytesSent = 4096
+
+	second, err := m.AddPeer("renew-key", "free", time.Hour)
+	if err != nil {
+		t.Fatalf("AddPeer renew: %v", err)
+	}
+	if second.ClientAddress != first.ClientAddress {
+		t.Errorf("renewal changed address: %s -> %s", first.ClientAddress, second.ClientAddress)
+	}
+	if n := pool.Available(); n != 252 {
+		t.Errorf("renewal leaked an address: %d free, want 252", n)
+	}
+	peer = m.GetPeer("renew-key")
+	if !peer.AssignedAt.Equal(assignedAt) || peer.BytesSent != 4096 {
+		t.Errorf("renewal reset peer accounting: %+v", peer)
+	}
+	if peer.ExpiresAt.Sub(clk.Now()) != time.Hour {
+		t.Errorf("renewal did not extend expiry: %s", peer.ExpiresAt)
+	}
+	if backend.sets != 2 {
+		t.Errorf("expected renewal to reapply the peer, got %d SetPeer calls", backend.sets)
+	}
+
+	if _, err := m.AddPeer("late-key", "free", -time.Second); err == nil {
+		t.Error("AddPeer with a negative TTL succeeded")
+	}
+	if m.GetPeer("late-key") != nil || backend.sets != 2 {
+		t.Error("AddPeer with a negative TTL configured a peer")
+	}
+}
+
+func TestRekeyPeerKeepsAddressAndExpiry(t *testing.T) {
+	pool, _ := newIPPool("10.8.0.0/24")
+	backend := &countingBackend{}
+	clk := clock.NewFake(time.Now())
+	m := &Manager{
+		peers:   make(map[string]*Peer),
+		ifaces:  []*wgInterface{{Interface: Interface{Name: "wg-test"}, pool: pool}},
+		backend: backend,
+		clock:   clk,
+	}
+
+	first, err := m.AddPeer("old-key", "free", time.Hour)
+	if err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	if _, err := m.AddPeer("other-key", "free", time.Hour); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	old := *m.GetPeer("old-key")
+	m.GetPeer("old-key").BytesSent = 4096
+
+	if _, err := m.RekeyPeer("old-key", "other-key"); err == nil {
+		t.Error("rekeying onto an existing peer succeeded")
+	}
+	if _, err := m.RekeyPeer("missing-key", "new-key"); err == nil {
+		t.Error("rekeying an unknown peer succeeded")
+	}
+
+	clk.Advance(10 * time.Minute)
+	cfg, err := m.RekeyPeer("old-key", "new-key")
+	if err != nil {
+		t.Fatalf("RekeyPeer: %v", err)
+	}
+	if cfg.ClientAddress != first.ClientAddress {
+		t.Errorf("rekey changed address: %s -> %s", first.ClientAddress, cfg.ClientAddress)
+	}
+	if m.GetPeer("old-key") != nil {
+		t.Error("old key still tracked after rekey")
+	}
+	peer := m.GetPeer("new-key")
+	if peer == nil {
+		t.Fatal("new key not tracked after rekey")
+	}
+	if !peer.AssignedAt.Equal(old.AssignedAt) || !peer.ExpiresAt.Equal(old.ExpiresAt) {
+		t.Errorf("rekey changed peer lifetime: %+v, was %+v", peer, old)
+	}
+	if peer.BytesSent != 0 {
+		t.Errorf("rekey carried over counters: %+v", peer)
+	}
+	if n := pool.Available(); n != 251 {
+		t.Errorf("rekey changed the pool: %d free, want 251", n)
+	}
+	if len(backend.removed) != 1 || backend.removed[0] != "old-key" {
+		t.Errorf("removed %v from the interface, want [old-key]", backend.removed)
+	}
+
+	// A failed add puts the old key back.
+	backend.failKey = "bad-key"
+	if _, err := m.RekeyPeer("new-key", "bad-key"); err == nil {
+		t.Fatal("RekeyPeer succeeded despite a failing backend")
+	}
+	if m.GetPeer("new-key") == nil || m.GetPeer("bad-key") != nil {
+		t.Error("failed rekey changed tracked peers")
+	}
+	if backend.sets != 4 {
+		t.Errorf("expected the old peer to be restored, got %d SetPeer calls", backend.sets)
+	}
+}
+
+func TestCleanExpiredFollowsClock(t *testing.T) {
+	pool, _ := newIPPool("10.8.0.0/24")
+	clk := clock.NewFake(time.Now())
+	m := &Manager{
+		peers:   make(map[string]*Peer),
+		ifaces:  []*wgInterface{{Interface: Interface{Name: "wg-test"}, pool: pool}},
+		backend: &countingBackend{},
+		clock:   clk,
+	}
+
+	if _, err := m.AddPeer("short-key", "free", time.Minute); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	if _, err := m.AddPeer("long-key", "free", time.Hour); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+
+	clk.Advance(59 * time.Second)
+	if n := m.CleanExpired(); n != 0 {
+		t.Fatalf("CleanExpired before expiry removed %d peers", n)
+	}
+
+	clk.Advance(2 * time.Second)
+	if n := m.CleanExpired(); n != 1 {
+		t.Fatalf("CleanExpired removed %d peers, want 1", n)
+	}
+	if m.GetPeer("short-key") != nil || m.GetPeer("long-key") == nil {
+		t.Error("expected only the short-lived peer to expire")
+	}
+}
+
+func TestParseDNSPolicy(t *testing.T) {
+	tests := []struct {
+		in   string
+		want *DNSPolicy
+	}{
+		{"", nil},
+		{"none", &DNSPolicy{}},
+		{"local-resolver, no-logs", &DNSPolicy{LocalResolver: true, NoQueryLogs: true}},
+		{"doh", &DNSPolicy{DoH: true}},
+	}
+	for _, tt := range tests {
+		got, err := ParseDNSPolicy(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseDNSPolicy(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := ParseDNSPolicy("no-logs,logs-everything"); err == nil {
+		t.Error("ParseDNSPolicy accepted an unknown policy")
+	}
+}
@@ -0,0 +1,83 @@
+//go:build wgctrl
+
+package wireguard
+
+import (
+	"fmt"
+	"net"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// netlinkBackend configures the interface through the kernel netlink API,
+// without exec'ing `wg`.
+type netlinkBackend struct {
+	client *wgctrl.Client
+}
+
+func newNetlinkBackend() (Backend, error) {
+	client, err := wgctrl.New()
+	if err != nil {
+		return nil, fmt.Errorf("opening wgctrl client: %w", err)
+	}
+	return &netlinkBackend{client: client}, nil
+}
+
+func (b *netlinkBackend) SetPeer(iface, pubKey, clientIP string) error {
+	key, err := wgtypes.ParseKey(pubKey)
+	if err != nil {
+		return fmt.Errorf("parsing public key: %w", err)
+	}
+	ip := net.ParseIP(clientIP).To4()
+	if ip == nil {
+		return fmt.Errorf("invalid client IP %q", clientIP)
+	}
+
+	return b.client.ConfigureDevice(iface, wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey:         key,
+			ReplaceAllowedIPs: true,
+			AllowedIPs:        []net.IPNet{{IP: ip, Mask: net.CIDRMask(32, 32)}},
+		}},
+	})
+}
+
+func (b *netlinkBackend) RemovePeer(iface, pubKey string) error {
+	key, err := wgtypes.ParseKey(pubKey)
+	if err != nil {
+		return fmt.Errorf("parsing public key: %w", err)
+	}
+
+	return b.client.ConfigureDevice(iface, wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{{PublicKey: key, Remove: true}},
+	})
+}
+
+func (b *netlinkBackend) PrivateKey(iface string) (string, error) {
+	dev, err := b.client.Device(iface)
+	if err != nil {
+		return "", err
+	}
+	if dev.PrivateKey == (wgtypes.Key{}) {
+		return "", nil
+	}
+	return dev.PrivateKey.String(), nil
+}
+
+func (b *netlinkBackend) PeerStats(iface string) (map[string]PeerStats, error) {
+	dev, err := b.client.Device(iface)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]PeerStats, len(dev.Peers))
+	for _, p := range dev.Peers {
+		stats[p.PublicKey.String()] = PeerStats{
+			LastHandshake: p.LastHandshakeTime,
+			BytesReceived: uint64(p.ReceiveBytes),
+			BytesSent:     uint64(p.TransmitBytes),
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,11 @@
+//go:build !wgctrl
+
+package wireguard
+
+import "fmt"
+
+// newNetlinkBackend reports that netlink support was not compiled in.
+// Build with `-tags wgctrl` to enable it.
+func newNetlinkBackend() (Backend, error) {
+	return nil, fmt.Errorf("%s backend unavailable: gateway was built without the wgctrl build tag", BackendNetlink)
+}
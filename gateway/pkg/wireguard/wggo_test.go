@@ -0,0 +1,262 @@
+//go:build wggo
+
+// Tests in this file drive the Manager against a real userspace WireGuard
+// interface (wireguard-go) inside a throwaway network namespace, so the
+// `wg set`/`wg show` paths run against the real tool instead of a stub.
+// They need root, iproute2, wg and wireguard-go, and skip otherwise:
+//
+//	sudo -E go test -tags wggo -run WireGuardGo ./pkg/wireguard/
+//
+// Set WIREGUARD_GO to use a wireguard-go binary that is not on PATH.
+
+package wireguard
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/clock"
+)
+
+var wgGoSeq int
+
+// wgGoInterface is a wireguard-go interface running in its own namespace.
+type wgGoInterface struct {
+	t     *testing.T
+	ns    string
+	iface string
+}
+
+// newWireGuardGo starts wireguard-go in a fresh network namespace and points
+// runWG and interfaceByName at it for the rest of the test.
+func newWireGuardGo(t *testing.T) *wgGoInterface {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("wireguard-go tests need root")
+	}
+	wgGo := os.Getenv("WIREGUARD_GO")
+	if wgGo == "" {
+		wgGo = "wireguard-go"
+	}
+	for _, tool := range []string{"ip", "wg", wgGo} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found: %v", tool, err)
+		}
+	}
+
+	// Interface names are limited to 15 bytes, and wireguard-go's control
+	// socket in /var/run/wireguard is shared by every namespace, so each
+	// test gets its own name.
+	wgGoSeq++
+	id := (os.Getpid()%1000)*100 + wgGoSeq%100
+	w := &wgGoInterface{
+		t:     t,
+		ns:    fmt.Sprintf("svpn-test-%d", id),
+		iface: fmt.Sprintf("svpnt%d", id),
+	}
+
+	w.run("ip", "netns", "add", w.ns)
+	t.Cleanup(func() { exec.Command("ip", "netns", "del", w.ns).Run() })
+
+	daemon := exec.Command("ip", "netns", "exec", w.ns, wgGo, "-f", w.iface)
+	daemon.Env = append(os.Environ(), "LOG_LEVEL=error")
+	if err := daemon.Start(); err != nil {
+		t.Fatalf("starting wireguard-go: %v", err)
+	}
+	t.Cleanup(func() {
+		// SIGTERM lets wireguard-go remove its control socket.
+		daemon.Process.Signal(syscall.SIGTERM)
+		daemon.Wait()
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for exec.Command("ip", "netns", "exec", w.ns, "wg", "show", w.iface).Run() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("wireguard-go interface did not come up")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	priv, _, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair: %v", err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "private.key")
+	if err := os.WriteFile(keyFile, []byte(priv), 0o600); err != nil {
+		t.Fatalf("writing key: %v", err)
+	}
+	w.run("ip", "netns", "exec", w.ns, "wg", "set", w.iface, "private-key", keyFile, "listen-port", "0")
+	w.run("ip", "-n", w.ns, "address", "add", "10.8.0.1/24", "dev", w.iface)
+	w.run("ip", "-n", w.ns, "link", "set", w.iface, "up")
+
+	origWG, origIface := runWG, interfaceByName
+	t.Cleanup(func() { runWG, interfaceByName = origWG, origIface })
+	runWG = func(args ...string) ([]byte, error) {
+		return exec.Command("ip", append([]string{"netns", "exec", w.ns, "wg"}, args...)...).CombinedOutput()
+	}
+	interfaceByName = w.interfaceByName
+
+	return w
+}
+
+func (w *wgGoInterface) run(name string, args ...string) string {
+	w.t.Helper()
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		w.t.Fatalf("%s %s: %v\n%s", name, strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+// interfaceByName looks the interface up inside the namespace; the test
+// process itself stays in the host namespace.
+func (w *wgGoInterface) interfaceByName(name string) (*net.Interface, error) {
+	out, err := exec.Command("ip", "-n", w.ns, "-o", "link", "show", name).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, out)
+	}
+	iface := &net.Interface{Name: name}
+	if _, rest, ok := strings.Cut(string(out), "<"); ok {
+		flags, _, _ := strings.Cut(rest, ">")
+		for _, f := range strings.Split(flags, ",") {
+			if f == "UP" {
+				iface.Flags |= net.FlagUp
+			}
+		}
+	}
+	return iface, nil
+}
+
+// allowedIPs returns the interface's peers as public key -> allowed IPs.
+func (w *wgGoInterface) allowedIPs() map[string]string {
+	w.t.Helper()
+	peers := make(map[string]string)
+	out := w.run("ip", "netns", "exec", w.ns, "wg", "show", w.iface, "allowed-ips")
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if key, ips, ok := strings.Cut(line, "\t"); ok {
+			peers[key] = ips
+		}
+	}
+	return peers
+}
+
+func (w *wgGoInterface) manager() *Manager {
+	w.t.Helper()
+	m, err := NewManager(Config{Interface: w.iface, Subnet: "10.8.0.0/24"})
+	if err != nil {
+		w.t.Fatalf("NewManager: %v", err)
+	}
+	return m
+}
+
+func testPeerKey(t *testing.T) string {
+	t.Helper()
+	_, pub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair: %v", err)
+	}
+	return pub
+}
+
+func TestWireGuardGoVerify(t *testing.T) {
+	w := newWireGuardGo(t)
+	m := w.manager()
+
+	if err := m.Verify(); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	w.run("ip", "-n", w.ns, "link", "set", w.iface, "down")
+	if err := m.Verify(); err == nil || !strings.Contains(err.Error(), "is down") {
+		t.Fatalf("Verify on a down interface: got %v", err)
+	}
+}
+
+func TestWireGuardGoAddRenewRemovePeer(t *testing.T) {
+	w := newWireGuardGo(t)
+	m := w.manager()
+	key := testPeerKey(t)
+
+	cfg, err := m.AddPeer(key, "free", time.Hour)
+	if err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	clientIP := strings.TrimSuffix(cfg.ClientAddress, "/24")
+	if got := w.allowedIPs()[key]; got != clientIP+"/32" {
+		t.Fatalf("allowed-ips after AddPeer = %q, want %s/32", got, clientIP)
+	}
+
+	// Renewing keeps the address on the interface.
+	renewed, err := m.AddPeer(key, "free", 2*time.Hour)
+	if err != nil {
+		t.Fatalf("renew AddPeer: %v", err)
+	}
+	if renewed.ClientAddress != cfg.ClientAddress {
+		t.Fatalf("renewal moved peer from %s to %s", cfg.ClientAddress, renewed.ClientAddress)
+	}
+	if got := w.allowedIPs()[key]; got != clientIP+"/32" {
+		t.Fatalf("allowed-ips after renewal = %q", got)
+	}
+
+	if err := m.RemovePeer(key); err != nil {
+		t.Fatalf("RemovePeer: %v", err)
+	}
+	if _, ok := w.allowedIPs()[key]; ok {
+		t.Fatal("peer still on the interface after RemovePeer")
+	}
+}
+
+func TestWireGuardGoCleanExpired(t *testing.T) {
+	w := newWireGuardGo(t)
+	m := w.manager()
+	clk := clock.NewFake(time.Now())
+	m.SetClock(clk)
+	available := m.AvailableIPs()
+
+	expiring, kept := testPeerKey(t), testPeerKey(t)
+	if _, err := m.AddPeer(expiring, "free", time.Minute); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	if _, err := m.AddPeer(kept, "free", time.Hour); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	clk.Advance(2 * time.Minute)
+
+	if n := m.CleanExpired(); n != 1 {
+		t.Fatalf("CleanExpired removed %d peers, want 1", n)
+	}
+	peers := w.allowedIPs()
+	if _, ok := peers[expiring]; ok {
+		t.Error("expired peer still on the interface")
+	}
+	if _, ok := peers[kept]; !ok {
+		t.Error("unexpired peer removed from the interface")
+	}
+	if got := m.AvailableIPs(); got != available-1 {
+		t.Errorf("AvailableIPs = %d, want %d (expired address released)", got, available-1)
+	}
+}
+
+func TestWireGuardGoRefreshStats(t *testing.T) {
+	w := newWireGuardGo(t)
+	m := w.manager()
+	key := testPeerKey(t)
+
+	if _, err := m.AddPeer(key, "free", time.Hour); err != nil {
+		t.Fatalf("AddPeer: %v", err)
+	}
+	if err := m.RefreshStats(); err != nil {
+		t.Fatalf("RefreshStats: %v", err)
+	}
+	peer := m.GetPeer(key)
+	if peer == nil || !peer.LastHandshake.IsZero() || peer.BytesReceived != 0 {
+		t.Fatalf("fresh peer stats = %+v, want no handshake and no traffic", peer)
+	}
+}
@@ -0,0 +1,333 @@
+package integration
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/maybehotcarl/sovereign-vpn/client/pkg/api"
+	"github.com/maybehotcarl/sovereign-vpn/client/pkg/wallet"
+	"github.com/maybehotcarl/sovereign-vpn/client/pkg/wgconf"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/config"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/nftcheck"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/server"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/sessionmgr"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/wireguard"
+)
+
+// paidRPCABI is the part of the AccessPolicy and SessionManager ABIs the
+// gateway reads on a paid-tier connect.
+const paidRPCABI = `[
+	{"name": "checkAccess", "type": "function", "stateMutability": "view",
+	 "inputs": [{"name": "user", "type": "address"}],
+	 "outputs": [{"name": "access", "type": "bool"}, {"name": "free", "type": "bool"}]},
+	{"name": "getActiveSessionId", "type": "function", "stateMutability": "view",
+	 "inputs": [{"name": "user", "type": "address"}],
+	 "outputs": [{"name": "", "type": "uint256"}]},
+	{"name": "getSession", "type": "function", "stateMutability": "view",
+	 "inputs": [{"name": "sessionId", "type": "uint256"}],
+	 "outputs": [{"name": "", "type": "tuple", "components": [
+		{"name": "user", "type": "address"},
+		{"name": "node", "type": "address"},
+		{"name": "payment", "type": "uint256"},
+		{"name": "startedAt", "type": "uint256"},
+		{"name": "duration", "type": "uint256"},
+		{"name": "active", "type": "bool"},
+		{"name": "settled", "type": "bool"}
+	 ]}]}
+]`
+
+// onChainSession mirrors the SessionManager's session tuple for packing.
+type onChainSession struct {
+	User      common.Address
+	Node      common.Address
+	Payment   *big.Int
+	StartedAt *big.Int
+	Duration  *big.Int
+	Active    bool
+	Settled   bool
+}
+
+// paidChain is the on-chain state served by mockPaidRPC: every wallet has
+// paid-tier access, and the SessionManager reports session for any wallet
+// (none while session is nil).
+type paidChain struct {
+	mu      sync.Mutex
+	session *onChainSession
+}
+
+func (c *paidChain) setSession(s *onChainSession) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session = s
+}
+
+// paidSessionID is the on-chain session ID mockPaidRPC hands out.
+const paidSessionID = 7
+
+// mockPaidRPC simulates a JSON-RPC endpoint serving AccessPolicy.checkAccess
+// → (true, false), i.e. paid tier, and the SessionManager reads in chain.
+func mockPaidRPC(t *testing.T, chain *paidChain) *httptest.Server {
+	t.Helper()
+	parsed, err := abi.JSON(strings.NewReader(paidRPCABI))
+	if err != nil {
+		t.Fatalf("parsing ABI: %v", err)
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			ID     json.RawMessage `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+
+		reply := func(result any) {
+			json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+		}
+		if req.Method != "eth_call" {
+			reply("0x1")
+			return
+		}
+
+		var params []struct {
+			Input hexutil.Bytes `json:"input"`
+			Data  hexutil.Bytes `json:"data"`
+		}
+		json.Unmarshal(req.Params, &params)
+		var input []byte
+		if len(params) > 0 {
+			input = params[0].Input
+			if len(input) == 0 {
+				input = params[0].Data
+			}
+		}
+		if len(input) < 4 {
+			reply("0x")
+			return
+		}
+		method, err := parsed.MethodById(input[:4])
+		if err != nil {
+			reply("0x")
+			return
+		}
+
+		var out []byte
+		switch method.Name {
+		case "checkAccess":
+			out, err = method.Outputs.Pack(true, false)
+		case "getActiveSessionId":
+			id := big.NewInt(0)
+			chain.mu.Lock()
+			if chain.session != nil {
+				id.SetUint64(paidSessionID)
+			}
+			chain.mu.Unlock()
+			out, err = method.Outputs.Pack(id)
+		case "getSession":
+			chain.mu.Lock()
+			s := onChainSession{Payment: new(big.Int), StartedAt: new(big.Int), Duration: new(big.Int)}
+			if chain.session != nil {
+				s = *chain.session
+			}
+			chain.mu.Unlock()
+			out, err = method.Outputs.Pack(s)
+		}
+		if err != nil {
+			t.Errorf("packing %s: %v", method.Name, err)
+		}
+		reply("0x" + hex.EncodeToString(out))
+	}))
+}
+
+func TestPaidTierConnectFlow(t *testing.T) {
+	stubWGOnPath(t)
+
+	chain := &paidChain{}
+	ethRPC := mockPaidRPC(t, chain)
+	defer ethRPC.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.AccessPolicyContract = "0x0000000000000000000000000000000000000001"
+	cfg.MemesContract = "0x0000000000000000000000000000000000000002"
+	cfg.EthereumRPC = ethRPC.URL
+	cfg.SIWEDomain = "test.local"
+	cfg.SIWEUri = "https://test.local"
+	cfg.CredentialTTL = 1 * time.Hour
+	cfg.NonceLength = 16
+
+	checker, err := nftcheck.NewChecker(ethRPC.URL, cfg.AccessPolicyContract, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("nftcheck.NewChecker: %v", err)
+	}
+	defer checker.Close()
+
+	sessionMgr, err := sessionmgr.New(ethRPC.URL, "0x0000000000000000000000000000000000000003", "", 11155111)
+	if err != nil {
+		t.Fatalf("sessionmgr.New: %v", err)
+	}
+	defer sessionMgr.Close()
+
+	wgMgr, err := wireguard.NewManager(wireguard.Config{
+		Interface: "wg-test", Subnet: "10.99.0.0/24",
+	})
+	if err != nil {
+		t.Fatalf("wireguard.NewManager: %v", err)
+	}
+
+	srv := server.New(cfg, checker, wgMgr)
+	srv.SetChainID(11155111)
+	srv.SetSessionManager(sessionMgr)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	w, err := wallet.Generate()
+	if err != nil {
+		t.Fatalf("wallet.Generate: %v", err)
+	}
+	client := api.NewClient(ts.URL)
+	challenge, err := client.GetChallenge(w.AddressHex())
+	if err != nil {
+		t.Fatalf("GetChallenge: %v", err)
+	}
+	sig, err := w.SignMessage(challenge.Message)
+	if err != nil {
+		t.Fatalf("SignMessage: %v", err)
+	}
+	verifyResp, err := client.Verify(challenge.Message, sig)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if verifyResp.Tier != nftcheck.TierPaid.String() {
+		t.Fatalf("expected paid tier, got %q", verifyResp.Tier)
+	}
+
+	// Every connect below is refused with 402 and provisions nothing.
+	paymentRequired := func(t *testing.T) {
+		t.Helper()
+		keys, err := wgconf.GenerateKeyPair()
+		if err != nil {
+			t.Fatalf("GenerateKeyPair: %v", err)
+		}
+		_, err = client.Connect(verifyResp.SessionToken, keys.PublicKey)
+		var apiErr *api.Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusPaymentRequired {
+			t.Fatalf("expected 402, got %v", err)
+		}
+		if wgMgr.GetPeer(keys.PublicKey) != nil {
+			t.Error("expected no peer without payment")
+		}
+	}
+
+	startedAt := time.Now().Add(-1 * time.Hour).Unix()
+	paid := func(payment int64, active bool) *onChainSession {
+		return &onChainSession{
+			User:      w.Address(),
+			Node:      common.HexToAddress("0x0000000000000000000000000000000000000004"),
+			Payment:   big.NewInt(payment),
+			StartedAt: big.NewInt(startedAt),
+			Duration:  big.NewInt(int64((4 * time.Hour).Seconds())),
+			Active:    active,
+		}
+	}
+
+	t.Run("no session", func(t *testing.T) {
+		chain.setSession(nil)
+		paymentRequired(t)
+	})
+
+	t.Run("unpaid session", func(t *testing.T) {
+		chain.setSession(paid(0, true))
+		paymentRequired(t)
+	})
+
+	t.Run("closed session", func(t *testing.T) {
+		chain.setSession(paid(1e15, false))
+		paymentRequired(t)
+	})
+
+	t.Run("expired session", func(t *testing.T) {
+		s := paid(1e15, true)
+		s.StartedAt = big.NewInt(time.Now().Add(-5 * time.Hour).Unix())
+		chain.setSession(s)
+		paymentRequired(t)
+	})
+
+	t.Run("paid session", func(t *testing.T) {
+		chain.setSession(paid(1e15, true))
+		keys, err := wgconf.GenerateKeyPair()
+		if err != nil {
+			t.Fatalf("GenerateKeyPair: %v", err)
+		}
+		conn, err := client.Connect(verifyResp.SessionToken, keys.PublicKey)
+		if err != nil {
+			t.Fatalf("Connect: %v", err)
+		}
+
+		// The peer lasts until the on-chain session ends, not the
+		// credential TTL nor a fresh full duration.
+		want := time.Unix(startedAt, 0).Add(4 * time.Hour)
+		expiresAt, err := time.Parse(time.RFC3339, conn.ExpiresAt)
+		if err != nil {
+			t.Fatalf("parsing expires_at %q: %v", conn.ExpiresAt, err)
+		}
+		if d := expiresAt.Sub(want).Abs(); d > 5*time.Second {
+			t.Errorf("expected expires_at near %s, got %s", want.UTC().Format(time.RFC3339), conn.ExpiresAt)
+		}
+		peer := wgMgr.GetPeer(keys.PublicKey)
+		if peer == nil {
+			t.Fatal("expected a peer for the paid session")
+		}
+		if d := peer.ExpiresAt.Sub(want).Abs(); d > 5*time.Second {
+			t.Errorf("expected peer to expire near %s, got %s", want, peer.ExpiresAt)
+		}
+	})
+}
+
+// TestPaidRPCEncodesSessionTuple checks the mock's getSession encoding
+// against the gateway's decoder, so a failure above is not the mock's.
+func TestPaidRPCEncodesSessionTuple(t *testing.T) {
+	want := &onChainSession{
+		User:      common.HexToAddress("0x00000000000000000000000000000000000000aa"),
+		Node:      common.HexToAddress("0x00000000000000000000000000000000000000bb"),
+		Payment:   big.NewInt(1e15),
+		StartedAt: big.NewInt(1_700_000_000),
+		Duration:  big.NewInt(86400),
+		Active:    true,
+	}
+	chain := &paidChain{session: want}
+	ethRPC := mockPaidRPC(t, chain)
+	defer ethRPC.Close()
+
+	m, err := sessionmgr.New(ethRPC.URL, "0x0000000000000000000000000000000000000003", "", 1)
+	if err != nil {
+		t.Fatalf("sessionmgr.New: %v", err)
+	}
+	defer m.Close()
+
+	ctx := t.Context()
+	id, err := m.GetActiveSessionID(ctx, want.User)
+	if err != nil || id != paidSessionID {
+		t.Fatalf("GetActiveSessionID = %d, %v; want %d", id, err, paidSessionID)
+	}
+	got, err := m.GetSession(ctx, id)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if got.User != want.User || got.Node != want.Node || got.Payment.Cmp(want.Payment) != 0 ||
+		got.StartedAt != 1_700_000_000 || got.Duration != 86400 || !got.Active || got.Settled {
+		t.Errorf("decoded session %+v does not match %+v", got, want)
+	}
+}
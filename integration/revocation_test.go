@@ -0,0 +1,206 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/maybehotcarl/sovereign-vpn/client/pkg/api"
+	"github.com/maybehotcarl/sovereign-vpn/client/pkg/wallet"
+	"github.com/maybehotcarl/sovereign-vpn/client/pkg/wgconf"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/config"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/nftcheck"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/revocation"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/server"
+	"github.com/maybehotcarl/sovereign-vpn/gateway/pkg/wireguard"
+)
+
+// stubWGOnPath puts a no-op `wg` executable first on PATH so the gateway
+// can provision and remove peers without a real interface.
+func stubWGOnPath(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "wg"), []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatalf("writing wg stub: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// mockTransferRPC simulates the JSON-RPC endpoint polled by the revocation
+// watcher. Once transfer is set, the head advances one block and
+// eth_getLogs reports a Memes TransferSingle from `from` in that block.
+func mockTransferRPC(memes, from common.Address, transfer *atomic.Bool) *httptest.Server {
+	const startBlock = 100
+	transferSingle := crypto.Keccak256Hash([]byte("TransferSingle(address,address,address,uint256,uint256)"))
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+			ID     int    `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		head := uint64(startBlock)
+		if transfer.Load() {
+			head++
+		}
+
+		var result any
+		switch req.Method {
+		case "eth_blockNumber":
+			result = hexutil.Uint64(head)
+		case "eth_getLogs":
+			logs := []*types.Log{}
+			if transfer.Load() {
+				data := make([]byte, 64)
+				big.NewInt(1).FillBytes(data[:32]) // tokenId
+				big.NewInt(1).FillBytes(data[32:]) // value
+				logs = append(logs, &types.Log{
+					Address: memes,
+					Topics: []common.Hash{
+						transferSingle,
+						common.BytesToHash(from.Bytes()), // operator
+						common.BytesToHash(from.Bytes()), // from
+						common.HexToHash("0xbeef"),       // to
+					},
+					Data:        data,
+					BlockNumber: head,
+					TxHash:      common.HexToHash("0x01"),
+					BlockHash:   common.HexToHash("0x02"),
+				})
+			}
+			result = logs
+		default:
+			result = "0x1"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      req.ID,
+			"result":  result,
+		})
+	}))
+}
+
+// TestRevocationRemovesPeer connects a wallet, feeds a transfer of its Memes
+// card through the polling watcher, and checks the WireGuard peer is torn
+// down and its client IP returned to the pool.
+func TestRevocationRemovesPeer(t *testing.T) {
+	stubWGOnPath(t)
+
+	w, err := wallet.Generate()
+	if err != nil {
+		t.Fatalf("wallet.Generate: %v", err)
+	}
+
+	ethRPC := mockEthRPC(map[common.Address]bool{w.Address(): true})
+	defer ethRPC.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.AccessPolicyContract = "0x0000000000000000000000000000000000000001"
+	cfg.MemesContract = "0x0000000000000000000000000000000000000002"
+	cfg.EthereumRPC = ethRPC.URL
+	cfg.SIWEDomain = "test.local"
+	cfg.SIWEUri = "https://test.local"
+	cfg.CredentialTTL = 1 * time.Hour
+	cfg.NonceLength = 16
+	cfg.EnableFreeTier = true
+
+	checker, err := nftcheck.NewChecker(ethRPC.URL, cfg.AccessPolicyContract, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("nftcheck.NewChecker: %v", err)
+	}
+	defer checker.Close()
+
+	wgMgr, err := wireguard.NewManager(wireguard.Config{
+		Interface: "wg-test", Subnet: "10.99.0.0/24",
+	})
+	if err != nil {
+		t.Fatalf("wireguard.NewManager: %v", err)
+	}
+	freeIPs := wgMgr.AvailableIPs()
+
+	srv := server.New(cfg, checker, wgMgr)
+	srv.SetChainID(11155111)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	// Connect
+	client := api.NewClient(ts.URL)
+	challenge, err := client.GetChallenge(w.AddressHex())
+	if err != nil {
+		t.Fatalf("GetChallenge: %v", err)
+	}
+	sig, err := w.SignMessage(challenge.Message)
+	if err != nil {
+		t.Fatalf("SignMessage: %v", err)
+	}
+	verifyResp, err := client.Verify(challenge.Message, sig)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	keys, err := wgconf.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair: %v", err)
+	}
+	if _, err := client.Connect(verifyResp.SessionToken, keys.PublicKey); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if n := wgMgr.PeerCount(); n != 1 {
+		t.Fatalf("expected 1 peer after connect, got %d", n)
+	}
+	if n := wgMgr.AvailableIPs(); n != freeIPs-1 {
+		t.Fatalf("expected %d free IPs after connect, got %d", freeIPs-1, n)
+	}
+
+	// Watch for transfers
+	var transfer atomic.Bool
+	chainRPC := mockTransferRPC(common.HexToAddress(cfg.MemesContract), w.Address(), &transfer)
+	defer chainRPC.Close()
+
+	watcher, err := revocation.NewPollingWatcher(chainRPC.URL, common.HexToAddress(cfg.MemesContract), server.NewRevoker(srv), 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewPollingWatcher: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watcher.Start(ctx)
+	defer watcher.Stop()
+
+	// Let the first poll record the head before the transfer lands.
+	time.Sleep(100 * time.Millisecond)
+	transfer.Store(true)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for wgMgr.PeerCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("peer not removed after transfer: %d peers remain", wgMgr.PeerCount())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if n := wgMgr.AvailableIPs(); n != freeIPs {
+		t.Errorf("expected client IP released (%d free), got %d free", freeIPs, n)
+	}
+	if wgMgr.GetPeer(keys.PublicKey) != nil {
+		t.Error("expected revoked peer to be gone")
+	}
+	if status, err := client.Status(verifyResp.SessionToken); err != nil {
+		t.Errorf("Status: %v", err)
+	} else if status.Connected {
+		t.Error("expected session to be revoked after transfer")
+	}
+}
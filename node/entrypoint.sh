@@ -45,7 +45,7 @@ fi
 echo "  NAT iface:   $DEFAULT_IF"
 
 echo "=== Sovereign VPN Node ==="
-echo "  Public IP:   $PUBLIC_IP"
+echo "  Public IP:   $PUBLIC_IP${PUBLIC_IP6:+, $PUBLIC_IP6}"
 echo "  WG PubKey:   $PUB_KEY"
 echo "  WG Port:     $WG_PORT"
 echo "  Gateway:     :$GATEWAY_PORT"
@@ -66,6 +66,12 @@ chmod 600 "$WG_DIR/$WG_INTERFACE.conf"
 wg-quick up "$WG_INTERFACE"
 
 # ---- Build gateway arguments ----
+# Dual-stack nodes advertise the IPv6 endpoint as an alternate.
+WG_ENDPOINTS="$PUBLIC_IP:$WG_PORT"
+if [ -n "${PUBLIC_IP6:-}" ]; then
+    WG_ENDPOINTS="$WG_ENDPOINTS,[$PUBLIC_IP6]:$WG_PORT"
+fi
+
 ARGS=(
     --direct-mode
     --listen ":$GATEWAY_PORT"
@@ -78,11 +84,21 @@ ARGS=(
     --max-token-id "$MAX_TOKEN_ID"
     --wg-interface "$WG_INTERFACE"
     --wg-pubkey "$PUB_KEY"
-    --wg-endpoint "$PUBLIC_IP:$WG_PORT"
+    --wg-endpoint "$WG_ENDPOINTS"
     --wg-subnet "$WG_SUBNET"
     --wg-dns "${WG_DNS:-1.1.1.1}"
 )
 
+# Optional: raise MAX_TOKEN_ID from the chain as new cards mint
+if [ -n "${MAX_TOKEN_ID_REFRESH:-}" ]; then
+    ARGS+=(--max-token-id-refresh "$MAX_TOKEN_ID_REFRESH")
+fi
+
+# Optional: explicit token ID list (e.g. "1-50,77,90-120") instead of 1..MAX_TOKEN_ID
+if [ -n "${TOKEN_IDS:-}" ]; then
+    ARGS+=(--token-ids "$TOKEN_IDS")
+fi
+
 # Optional: delegation
 if [ "${DELEGATION:-false}" = "true" ]; then
     ARGS+=(--delegation)
@@ -123,7 +139,8 @@ fi
 if [ -n "${ZK_API_URL:-}" ]; then
     ARGS+=(--zk-api-url "$ZK_API_URL")
     if [ -n "${ZK_API_KEY:-}" ]; then
-        ARGS+=(--zk-api-key "$ZK_API_KEY")
+        # Via the environment, not a flag, so the key stays out of `ps`.
+        export SVPN_ZK_API_KEY="$ZK_API_KEY"
     fi
 fi
 